@@ -8,6 +8,12 @@ import (
 
 	"github.com/michael-freling/anime-image-viewer/internal/backup"
 	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/michael-freling/anime-image-viewer/internal/import_images"
+	"github.com/michael-freling/anime-image-viewer/internal/statistics"
+	"github.com/michael-freling/anime-image-viewer/internal/tag"
+	"github.com/michael-freling/anime-image-viewer/internal/xmp"
 	"github.com/spf13/cobra"
 )
 
@@ -97,5 +103,202 @@ func runMain(logger *slog.Logger) error {
 	restoreFlags.StringVar(&restoreOptions.targetDir, "target-dir", "", "restore database and images to this directory instead of the defaults")
 	rootCommand.AddCommand(&restoreCommand)
 
+	xmpCommand := cobra.Command{
+		Use:   "xmp",
+		Short: "Manage XMP sidecars of images",
+	}
+	var xmpSyncOptions struct {
+		configPath   string
+		imageFileIDs []uint
+	}
+	xmpSyncCommand := cobra.Command{
+		Use:   "sync",
+		Short: "Write tags and characters into the XMP sidecars of images for DigiKam and other tools",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := config.ReadConfig(xmpSyncOptions.configPath)
+			if err != nil {
+				return fmt.Errorf("config.ReadConfig: %w", err)
+			}
+			dbClient, err := db.FromConfig(conf, logger)
+			if err != nil {
+				return fmt.Errorf("db.FromConfig: %w", err)
+			}
+
+			writer := xmp.NewWriter(logger, conf, dbClient)
+			var result xmp.SyncResult
+			if len(xmpSyncOptions.imageFileIDs) > 0 {
+				result, err = writer.Sync(context.Background(), xmpSyncOptions.imageFileIDs)
+			} else {
+				result, err = writer.SyncAll(context.Background())
+			}
+			if err != nil {
+				return fmt.Errorf("writer.Sync: %w", err)
+			}
+			logger.Info("XMP sync completed",
+				"written", result.Written,
+				"unchanged", result.Unchanged,
+			)
+			return nil
+		},
+	}
+	xmpSyncFlags := xmpSyncCommand.Flags()
+	xmpSyncFlags.StringVar(&xmpSyncOptions.configPath, "config", "", "path to the configuration file")
+	xmpSyncFlags.UintSliceVar(&xmpSyncOptions.imageFileIDs, "image-ids", nil, "sync only the images with the IDs instead of the whole library")
+	xmpCommand.AddCommand(&xmpSyncCommand)
+	rootCommand.AddCommand(&xmpCommand)
+
+	var importOptions struct {
+		configPath     string
+		directoryID    uint
+		onNameConflict string
+		onDuplicate    string
+	}
+	importCommand := cobra.Command{
+		Use:   "import <archive>",
+		Short: "Import images from a zip, cbz or tar archive into a directory",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := config.ReadConfig(importOptions.configPath)
+			if err != nil {
+				return fmt.Errorf("config.ReadConfig: %w", err)
+			}
+			dbClient, err := db.FromConfig(conf, logger)
+			if err != nil {
+				return fmt.Errorf("db.FromConfig: %w", err)
+			}
+
+			directoryReader := image.NewDirectoryReader(conf, dbClient)
+			directory, err := directoryReader.ReadDirectory(importOptions.directoryID)
+			if err != nil {
+				return fmt.Errorf("directoryReader.ReadDirectory: %w", err)
+			}
+			importer := import_images.NewBatchImageImporter(
+				logger,
+				dbClient,
+				image.NewImageFileConverter(conf),
+				tag.NewReader(dbClient, directoryReader),
+				nil,
+			)
+			progressNotifier := import_images.NewProgressNotifier()
+			_, err = importer.ImportArchive(context.Background(), directory, args[0], import_images.ImportOptions{
+				OnNameConflict: import_images.ConflictPolicy(importOptions.onNameConflict),
+				OnDuplicate:    import_images.ConflictPolicy(importOptions.onDuplicate),
+			}, progressNotifier)
+			for i, failedPath := range progressNotifier.FailedPaths {
+				logger.Warn("failed to import an image",
+					"path", failedPath,
+					"error", progressNotifier.FailedErrors[i],
+				)
+			}
+			logger.Info("Import completed",
+				"total", progressNotifier.Total,
+				"completed", progressNotifier.Completed,
+				"skipped", progressNotifier.Skipped,
+				"failed", progressNotifier.Failed,
+			)
+			if err != nil {
+				return fmt.Errorf("importer.ImportArchive: %w", err)
+			}
+			return nil
+		},
+	}
+	importFlags := importCommand.Flags()
+	importFlags.StringVar(&importOptions.configPath, "config", "", "path to the configuration file")
+	importFlags.UintVar(&importOptions.directoryID, "directory-id", db.RootDirectoryID, "ID of the directory to import images into")
+	importFlags.StringVar(&importOptions.onNameConflict, "on-name-conflict", "fail", "what to do with an image whose name is taken: fail, skip, rename, replace or link-tags")
+	importFlags.StringVar(&importOptions.onDuplicate, "on-duplicate", "", "what to do with an image already in the library: fail, skip or link-tags, or import it again by default")
+	rootCommand.AddCommand(&importCommand)
+
+	var statsOptions struct {
+		configPath string
+		metric     string
+		ranking    statistics.RankingOptions
+	}
+	// newStatisticsService returns the statistics of the library in the
+	// configuration, ranked by the options
+	newStatisticsService := func() (*statistics.Service, statistics.RankingOptions, error) {
+		conf, err := config.ReadConfig(statsOptions.configPath)
+		if err != nil {
+			return nil, statistics.RankingOptions{}, fmt.Errorf("config.ReadConfig: %w", err)
+		}
+		dbClient, err := db.FromConfig(conf, logger)
+		if err != nil {
+			return nil, statistics.RankingOptions{}, fmt.Errorf("db.FromConfig: %w", err)
+		}
+		options := statsOptions.ranking
+		options.Metric = statistics.Metric(statsOptions.metric)
+		return statistics.NewService(dbClient), options, nil
+	}
+	statsCommand := cobra.Command{
+		Use:   "stats",
+		Short: "Show which tags and characters are on the same images",
+	}
+	statsFlags := statsCommand.PersistentFlags()
+	statsFlags.StringVar(&statsOptions.configPath, "config", "", "path to the configuration file")
+	statsFlags.StringVar(&statsOptions.metric, "metric", string(statistics.MetricLift), "what pairs are ranked by: lift, pmi, count or confidence")
+	statsFlags.UintVar(&statsOptions.ranking.MinCount, "min-count", 2, "exclude pairs on fewer images")
+	statsFlags.IntVar(&statsOptions.ranking.Limit, "limit", 20, "number of pairs to show. A negative limit shows every pair")
+	statsFlags.UintVar(&statsOptions.ranking.TagID, "tag-id", 0, "show only the pairs with the tag")
+
+	statsTagsCommand := cobra.Command{
+		Use:   "tags",
+		Short: "Rank pairs of tags used together by lift or PMI",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			service, options, err := newStatisticsService()
+			if err != nil {
+				return err
+			}
+			pairs, err := service.RankTagPairs(cmd.Context(), options)
+			if err != nil {
+				return fmt.Errorf("service.RankTagPairs: %w", err)
+			}
+			fmt.Printf("%-30s  %-30s  %6s  %10s  %7s  %7s\n", "tag", "other tag", "count", "confidence", "lift", "pmi")
+			for _, pair := range pairs {
+				fmt.Printf("%-30s  %-30s  %6d  %10.3f  %7.3f  %7.3f\n",
+					pair.TagName,
+					pair.OtherTagName,
+					pair.Count,
+					pair.Confidence,
+					pair.Lift,
+					pair.PMI,
+				)
+			}
+			return nil
+		},
+	}
+	statsCommand.AddCommand(&statsTagsCommand)
+
+	statsCharactersCommand := cobra.Command{
+		Use:   "characters",
+		Short: "Rank pairs of a tag and a character used together by lift or PMI",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			service, options, err := newStatisticsService()
+			if err != nil {
+				return err
+			}
+			pairs, err := service.RankTagCharacterPairs(cmd.Context(), options)
+			if err != nil {
+				return fmt.Errorf("service.RankTagCharacterPairs: %w", err)
+			}
+			fmt.Printf("%-30s  %-30s  %6s  %10s  %7s  %7s\n", "character", "tag", "count", "confidence", "lift", "pmi")
+			for _, pair := range pairs {
+				fmt.Printf("%-30s  %-30s  %6d  %10.3f  %7.3f  %7.3f\n",
+					pair.CharacterName,
+					pair.TagName,
+					pair.Count,
+					pair.Confidence,
+					pair.Lift,
+					pair.PMI,
+				)
+			}
+			return nil
+		},
+	}
+	statsCommand.AddCommand(&statsCharactersCommand)
+	rootCommand.AddCommand(&statsCommand)
+
 	return rootCommand.Execute()
 }
@@ -2,14 +2,29 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/michael-freling/anime-image-viewer/internal/config"
 	"github.com/michael-freling/anime-image-viewer/internal/db"
 	"github.com/michael-freling/anime-image-viewer/internal/export"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/michael-freling/anime-image-viewer/internal/plugin"
+	"github.com/michael-freling/anime-image-viewer/internal/tag"
+	tag_suggestionv1 "github.com/michael-freling/anime-image-viewer/plugins/plugins-protos/gen/go/tag_suggestion/v1"
+	tag_suggestionv2 "github.com/michael-freling/anime-image-viewer/plugins/plugins-protos/gen/go/tag_suggestion/v2"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func main() {
@@ -26,6 +41,13 @@ func main() {
 type exportCLIOptions struct {
 	configPath             string
 	isDirectoryTagExcluded bool
+	split                  export.SplitOptions
+	isIncremental          bool
+	labelFormat            string
+	format                 string
+	caption                export.CaptionOptions
+	webDataset             export.WebDatasetOptions
+	query                  export.Query
 }
 
 func runMain(logger *slog.Logger) error {
@@ -52,8 +74,25 @@ func runMain(logger *slog.Logger) error {
 				return fmt.Errorf("db.FromConfig: %w", err)
 			}
 
+			var format export.Format
+			switch exportOptions.format {
+			case "huggingface":
+				format = export.HuggingFaceFormat{}
+			case "caption":
+				format = export.NewCaptionFormat(exportOptions.caption)
+			case "webdataset":
+				format = export.NewWebDatasetFormat(exportOptions.webDataset)
+			default:
+				return fmt.Errorf("unknown export format: %s", exportOptions.format)
+			}
+
 			service := export.NewBatchImageExporter(logger, conf, dbClient, export.BatchImageExporterOptions{
 				IsDirectoryTagExcluded: exportOptions.isDirectoryTagExcluded,
+				Split:                  exportOptions.split,
+				Incremental:            exportOptions.isIncremental,
+				LabelFormat:            export.LabelFormat(exportOptions.labelFormat),
+				Format:                 format,
+				Query:                  exportOptions.query,
 			})
 			if err := service.Export(context.Background(), exportDirectory); err != nil {
 				return fmt.Errorf("service.ExportAll: %w", err)
@@ -71,7 +110,435 @@ func runMain(logger *slog.Logger) error {
 		true,
 		"Exclude directory tags. If this is true, images without their own tags and tags from directories are NOT exported. default: true",
 	)
+	exportFlags.Float64Var(&exportOptions.split.TrainRatio, "train-ratio", 0, "relative size of the train split")
+	exportFlags.Float64Var(&exportOptions.split.ValidationRatio, "validation-ratio", 0, "relative size of the validation split")
+	exportFlags.Float64Var(&exportOptions.split.TestRatio, "test-ratio", 0, "relative size of the test split. If no ratio is set, every image is exported to the train split")
+	exportFlags.Uint64Var(&exportOptions.split.Seed, "seed", 0, "seed of the split assignment. The same seed and library give the same splits")
+	exportFlags.BoolVar(
+		&exportOptions.isIncremental,
+		"incremental",
+		false,
+		"Update an existing export by copying only new and changed images, and removing images that are no longer exported",
+	)
+	exportFlags.StringVar(
+		&exportOptions.labelFormat,
+		"labels",
+		string(export.LabelFormatDense),
+		"How tags and characters are written to metadata.jsonl: dense for vectors indexed by ID, or sparse for lists of names",
+	)
+	exportFlags.StringVar(
+		&exportOptions.format,
+		"format",
+		"huggingface",
+		"Dataset format: huggingface for metadata.jsonl, or caption for a .txt caption next to each image to fine-tune diffusion models, or webdataset for tar shards",
+	)
+	exportFlags.StringSliceVar(&exportOptions.caption.TriggerWords, "trigger-words", nil, "words to start every caption with (caption format)")
+	exportFlags.StringSliceVar(&exportOptions.caption.CategoryOrder, "category-order", nil, "tag categories in the order of tags in a caption (caption format)")
+	exportFlags.StringSliceVar(&exportOptions.caption.ExcludedTags, "exclude-tags", nil, "tags to leave out of captions (caption format)")
+	exportFlags.IntVar(&exportOptions.caption.Resolution, "resolution", 0, "resize images into aspect ratio buckets of about resolution x resolution pixels. 0 copies images as they are (caption format)")
+	exportFlags.IntVar(&exportOptions.caption.BucketStep, "bucket-step", 64, "what the sides of a bucket are multiples of (caption format)")
+	exportFlags.IntVar(&exportOptions.webDataset.ShardMaxSamples, "shard-max-samples", 10000, "the most images in a tar shard (webdataset format)")
+	exportFlags.Int64Var(&exportOptions.webDataset.ShardMaxBytes, "shard-max-bytes", 1<<30, "the largest size of a tar shard in bytes (webdataset format)")
+	exportFlags.UintSliceVar(&exportOptions.query.AnimeIDs, "anime-ids", nil, "export only the images of any of the anime")
+	exportFlags.UintSliceVar(&exportOptions.query.DirectoryIDs, "directory-ids", nil, "export only the images under any of the directories, e.g. seasons")
+	exportFlags.UintSliceVar(&exportOptions.query.ImageFileIDs, "image-ids", nil, "export only the images with the IDs")
+	exportFlags.UintSliceVar(&exportOptions.query.TagIDs, "tag-ids", nil, "export only the images with all of the tags")
+	exportFlags.UintSliceVar(&exportOptions.query.ExcludedTagIDs, "exclude-tag-ids", nil, "do not export the images with any of the tags")
+	exportFlags.UintSliceVar(&exportOptions.query.CharacterIDs, "character-ids", nil, "export only the images of all of the characters")
 	rootCommand.AddCommand(&exportCommand)
+	rootCommand.AddCommand(newSuggestionsCommand(logger))
+	rootCommand.AddCommand(newEvaluateCommand(logger))
+	rootCommand.AddCommand(newAnalysisCommand(logger))
+	rootCommand.AddCommand(newServeFakeCommand(logger))
 
 	return rootCommand.Execute()
 }
+
+type suggestionsCLIOptions struct {
+	configPath   string
+	modelVersion string
+	days         int
+}
+
+func newSuggestionsCommand(logger *slog.Logger) *cobra.Command {
+	var options suggestionsCLIOptions
+	newExporter := func() (*export.SuggestionFeedbackExporter, error) {
+		conf, err := config.ReadConfig(options.configPath)
+		if err != nil {
+			return nil, fmt.Errorf("config.ReadConfig: %w", err)
+		}
+		dbClient, err := db.FromConfig(conf, logger)
+		if err != nil {
+			return nil, fmt.Errorf("db.FromConfig: %w", err)
+		}
+		return export.NewSuggestionFeedbackExporter(conf, dbClient), nil
+	}
+
+	suggestionsCommand := cobra.Command{
+		Use:   "suggestions",
+		Short: "Export feedback on tag suggestions",
+	}
+	suggestionsCommand.PersistentFlags().StringVar(&options.configPath, "config", "", "path to the configuration file")
+
+	exportNegativesCommand := cobra.Command{
+		Use:   "export-negatives [outputFile]",
+		Short: "Export rejected suggestions as JSON lines, as hard negatives for retraining",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			exporter, err := newExporter()
+			if err != nil {
+				return err
+			}
+			file, err := os.Create(args[0])
+			if err != nil {
+				return fmt.Errorf("os.Create: %w", err)
+			}
+			defer file.Close()
+
+			count, err := exporter.ExportHardNegatives(cmd.Context(), file, options.modelVersion)
+			if err != nil {
+				return fmt.Errorf("exporter.ExportHardNegatives: %w", err)
+			}
+			logger.Info("Exported hard negatives", "outputFile", args[0], "count", count)
+			return nil
+		},
+	}
+	exportNegativesCommand.Flags().StringVar(&options.modelVersion, "model-version", "", "export only the suggestions of the model version")
+	suggestionsCommand.AddCommand(&exportNegativesCommand)
+
+	precisionCommand := cobra.Command{
+		Use:   "precision",
+		Short: "Show the share of accepted suggestions for each model version and day",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			exporter, err := newExporter()
+			if err != nil {
+				return err
+			}
+			since := time.Now().AddDate(0, 0, -options.days)
+			precisions, err := exporter.MeasurePrecision(cmd.Context(), since)
+			if err != nil {
+				return fmt.Errorf("exporter.MeasurePrecision: %w", err)
+			}
+			fmt.Printf("%-10s  %-30s  %8s  %8s  %9s\n", "date", "model", "accepted", "rejected", "precision")
+			for _, precision := range precisions {
+				fmt.Printf("%-10s  %-30s  %8d  %8d  %9.3f\n",
+					precision.Date,
+					precision.ModelName+"@"+precision.ModelVersion,
+					precision.Accepted,
+					precision.Rejected,
+					precision.Precision(),
+				)
+			}
+			return nil
+		},
+	}
+	precisionCommand.Flags().IntVar(&options.days, "days", 30, "measure the suggestions decided in the last days")
+	suggestionsCommand.AddCommand(&precisionCommand)
+
+	return &suggestionsCommand
+}
+
+type evaluateCLIOptions struct {
+	configPath string
+	address    string
+	protocol   string
+	outputPath string
+	evaluation export.SuggestionEvaluatorOptions
+}
+
+func newEvaluateCommand(logger *slog.Logger) *cobra.Command {
+	var options evaluateCLIOptions
+	evaluateCommand := cobra.Command{
+		Use:   "evaluate",
+		Short: "Evaluate a tag suggestion plugin against the tags users added to held-out images",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := config.ReadConfig(options.configPath)
+			if err != nil {
+				return fmt.Errorf("config.ReadConfig: %w", err)
+			}
+			dbClient, err := db.FromConfig(conf, logger)
+			if err != nil {
+				return fmt.Errorf("db.FromConfig: %w", err)
+			}
+
+			address := options.address
+			if address == "" {
+				address = conf.TagSuggestion.Address
+			}
+			if address == "" {
+				return fmt.Errorf("the address of a tag suggestion plugin is required")
+			}
+			connection, err := grpc.NewClient(
+				address,
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+			)
+			if err != nil {
+				return fmt.Errorf("grpc.NewClient: %w", err)
+			}
+			defer connection.Close()
+
+			directoryReader := image.NewDirectoryReader(conf, dbClient)
+			tagReader := tag.NewReader(dbClient, directoryReader)
+			imageReader := image.NewReader(dbClient, directoryReader, image.NewImageFileConverter(conf))
+			var suggestionService *tag.SuggestionService
+			switch options.protocol {
+			case "v1":
+				suggestionService = tag.NewSuggestionService(
+					dbClient,
+					tag_suggestionv1.NewTagSuggestionServiceClient(connection),
+					tagReader,
+					imageReader,
+				)
+			case "v2":
+				suggestionService = tag.NewSuggestionServiceV2(
+					dbClient,
+					tag_suggestionv2.NewTagSuggestionServiceClient(connection),
+					image.NewResizer(logger),
+					conf.TagSuggestion.ThumbnailWidth,
+					tagReader,
+					imageReader,
+				)
+			default:
+				return fmt.Errorf("unknown tag suggestion protocol: %s", options.protocol)
+			}
+
+			evaluator := export.NewSuggestionEvaluator(logger, conf, dbClient, suggestionService, options.evaluation)
+			evaluation, err := evaluator.Evaluate(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("evaluator.Evaluate: %w", err)
+			}
+			if options.outputPath != "" {
+				if err := writeEvaluation(options.outputPath, evaluation); err != nil {
+					return fmt.Errorf("writeEvaluation: %w", err)
+				}
+				logger.Info("Wrote the evaluation", "outputFile", options.outputPath)
+			}
+			printEvaluation(evaluation)
+			return nil
+		},
+	}
+	flags := evaluateCommand.Flags()
+	flags.StringVar(&options.configPath, "config", "", "path to the configuration file")
+	flags.StringVar(&options.address, "address", "", "address of the tag suggestion plugin. default: tag_suggestion.address in the configuration")
+	flags.StringVar(&options.protocol, "protocol", "v2", "version of the tag suggestion protocol of the plugin: v1 or v2")
+	flags.StringVar(&options.outputPath, "output", "", "path to write the evaluation as JSON, including the curves of each tag")
+	flags.Float64Var(&options.evaluation.Threshold, "threshold", 0.5, "score at which a tag is suggested")
+	flags.IntVar(&options.evaluation.BatchSize, "batch-size", 32, "number of images sent to the plugin at once")
+	flags.BoolVar(
+		&options.evaluation.IsDirectoryTagExcluded,
+		"exclude-directory-tag",
+		true,
+		"Select images in the same way as the export command with this option",
+	)
+	flags.StringVar(&options.evaluation.EvaluatedSplit, "split", "", "split to evaluate, e.g. test, with the same ratios and seed as the export. default: every selected image")
+	flags.Float64Var(&options.evaluation.Split.TrainRatio, "train-ratio", 0, "relative size of the train split")
+	flags.Float64Var(&options.evaluation.Split.ValidationRatio, "validation-ratio", 0, "relative size of the validation split")
+	flags.Float64Var(&options.evaluation.Split.TestRatio, "test-ratio", 0, "relative size of the test split")
+	flags.Uint64Var(&options.evaluation.Split.Seed, "seed", 0, "seed of the split assignment")
+	flags.UintSliceVar(&options.evaluation.Query.AnimeIDs, "anime-ids", nil, "evaluate only the images of any of the anime")
+	flags.UintSliceVar(&options.evaluation.Query.DirectoryIDs, "directory-ids", nil, "evaluate only the images under any of the directories, e.g. seasons")
+	flags.UintSliceVar(&options.evaluation.Query.ImageFileIDs, "image-ids", nil, "evaluate only the images with the IDs")
+	flags.UintSliceVar(&options.evaluation.Query.TagIDs, "tag-ids", nil, "evaluate only the images with all of the tags")
+	flags.UintSliceVar(&options.evaluation.Query.ExcludedTagIDs, "exclude-tag-ids", nil, "do not evaluate the images with any of the tags")
+	flags.UintSliceVar(&options.evaluation.Query.CharacterIDs, "character-ids", nil, "evaluate only the images of all of the characters")
+	return &evaluateCommand
+}
+
+type analysisCLIOptions struct {
+	configPath   string
+	imageFileIDs []uint
+}
+
+func newAnalysisCommand(logger *slog.Logger) *cobra.Command {
+	var options analysisCLIOptions
+	// withRegistry runs a function with the registry of the analysis plugins
+	// in the configuration, and stops the plugins after it
+	withRegistry := func(f func(*plugin.Registry) error) error {
+		conf, err := config.ReadConfig(options.configPath)
+		if err != nil {
+			return fmt.Errorf("config.ReadConfig: %w", err)
+		}
+		dbClient, err := db.FromConfig(conf, logger)
+		if err != nil {
+			return fmt.Errorf("db.FromConfig: %w", err)
+		}
+		pluginManager, err := plugin.NewManager(logger, conf)
+		if err != nil {
+			return fmt.Errorf("plugin.NewManager: %w", err)
+		}
+		defer pluginManager.Stop()
+		analyzers, err := pluginManager.DialAnalyzers(conf.Analysis.Plugins)
+		if err != nil {
+			return fmt.Errorf("pluginManager.DialAnalyzers: %w", err)
+		}
+
+		directoryReader := image.NewDirectoryReader(conf, dbClient)
+		imageReader := image.NewReader(dbClient, directoryReader, image.NewImageFileConverter(conf))
+		return f(plugin.NewRegistry(logger, dbClient, imageReader, plugin.AnnotationDirectory(conf), analyzers))
+	}
+
+	analysisCommand := cobra.Command{
+		Use:   "analysis",
+		Short: "Analyze images by the plugins in analysis.plugins of the configuration",
+	}
+	analysisCommand.PersistentFlags().StringVar(&options.configPath, "config", "", "path to the configuration file")
+
+	capabilitiesCommand := cobra.Command{
+		Use:   "capabilities",
+		Short: "Show the capabilities of the analysis plugins",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withRegistry(func(registry *plugin.Registry) error {
+				fmt.Printf("%-20s  %-20s  %-20s  %s\n", "plugin", "capability", "annotations", "description")
+				for _, capability := range registry.Capabilities(cmd.Context()) {
+					annotationTypes := make([]string, len(capability.AnnotationTypes))
+					for index, annotationType := range capability.AnnotationTypes {
+						annotationTypes[index] = string(annotationType)
+					}
+					fmt.Printf("%-20s  %-20s  %-20s  %s\n",
+						capability.PluginName+"@"+capability.PluginVersion,
+						capability.Name,
+						strings.Join(annotationTypes, ","),
+						capability.Description,
+					)
+				}
+				return nil
+			})
+		},
+	}
+	analysisCommand.AddCommand(&capabilitiesCommand)
+
+	runCommand := cobra.Command{
+		Use:   "run [capability]",
+		Short: "Run a capability on images and store the results as their annotations",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(options.imageFileIDs) == 0 {
+				return fmt.Errorf("--image-ids is required")
+			}
+			return withRegistry(func(registry *plugin.Registry) error {
+				results, err := registry.Analyze(cmd.Context(), args[0], options.imageFileIDs)
+				if err != nil {
+					return fmt.Errorf("registry.Analyze: %w", err)
+				}
+				for _, result := range results {
+					logger.Info("Analyzed an image",
+						"imageFileID", result.ImageFileID,
+						"plugin", result.PluginName,
+						"annotations", len(result.Annotations),
+					)
+				}
+				return nil
+			})
+		},
+	}
+	runCommand.Flags().UintSliceVar(&options.imageFileIDs, "image-ids", nil, "IDs of the images to analyze")
+	analysisCommand.AddCommand(&runCommand)
+
+	return &analysisCommand
+}
+
+type serveFakeCLIOptions struct {
+	configPath string
+	address    string
+	fake       tag.FakeSuggestionServerOptions
+}
+
+func newServeFakeCommand(logger *slog.Logger) *cobra.Command {
+	var options serveFakeCLIOptions
+	serveFakeCommand := cobra.Command{
+		Use:   "serve-fake",
+		Short: "Serve a tag_suggestion.v2 plugin which suggests tags from the library by heuristics, for development without the Python plugin",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := config.ReadConfig(options.configPath)
+			if err != nil {
+				return fmt.Errorf("config.ReadConfig: %w", err)
+			}
+			dbClient, err := db.FromConfig(conf, logger)
+			if err != nil {
+				return fmt.Errorf("db.FromConfig: %w", err)
+			}
+
+			// the same address as {address} of a managed plugin
+			network, address := "tcp", options.address
+			if socket, ok := strings.CutPrefix(options.address, "unix:"); ok {
+				network, address = "unix", socket
+			}
+			listener, err := net.Listen(network, address)
+			if err != nil {
+				return fmt.Errorf("net.Listen: %w", err)
+			}
+
+			directoryReader := image.NewDirectoryReader(conf, dbClient)
+			imageReader := image.NewReader(dbClient, directoryReader, image.NewImageFileConverter(conf))
+			server := grpc.NewServer()
+			tag_suggestionv2.RegisterTagSuggestionServiceServer(server, tag.NewFakeSuggestionServer(logger, dbClient, imageReader, options.fake))
+			healthpb.RegisterHealthServer(server, health.NewServer())
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			go func() {
+				<-ctx.Done()
+				server.GracefulStop()
+			}()
+			logger.Info("Serving a fake tag suggestion plugin", "address", options.address)
+			if err := server.Serve(listener); err != nil {
+				return fmt.Errorf("server.Serve: %w", err)
+			}
+			return nil
+		},
+	}
+	flags := serveFakeCommand.Flags()
+	flags.StringVar(&options.configPath, "config", "", "path to the configuration file")
+	flags.StringVar(&options.address, "address", "localhost:50051", "address to listen on, host:port or unix:/path/to/socket")
+	flags.IntVar(&options.fake.Neighbors, "neighbors", 5, "number of the most similar images by perceptual hashes whose tags are suggested")
+	flags.IntVar(&options.fake.MaxDistance, "max-distance", 10, "the largest hamming distance of perceptual hashes out of 64 bits for similar images")
+	return &serveFakeCommand
+}
+
+func writeEvaluation(outputPath string, evaluation export.SuggestionEvaluation) error {
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("os.Create: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(evaluation); err != nil {
+		return fmt.Errorf("encoder.Encode: %w", err)
+	}
+	return nil
+}
+
+func printEvaluation(evaluation export.SuggestionEvaluation) {
+	fmt.Printf("model: %s@%s, images: %d\n\n", evaluation.Model.Name, evaluation.Model.Version, evaluation.ImageCount)
+
+	fmt.Printf("%-30s  %7s  %9s  %6s  %6s  %6s\n", "tag", "support", "precision", "recall", "f1", "ap")
+	for _, tagEvaluation := range evaluation.Tags {
+		fmt.Printf("%-30s  %7d  %9.3f  %6.3f  %6.3f  %6.3f\n",
+			tagEvaluation.TagName,
+			tagEvaluation.Support,
+			tagEvaluation.Precision,
+			tagEvaluation.Recall,
+			tagEvaluation.F1,
+			tagEvaluation.AveragePrecision,
+		)
+	}
+	fmt.Printf("%-30s  %7s  %9.3f  %6.3f  %6.3f  %6.3f\n\n",
+		"micro / mAP",
+		"",
+		evaluation.Micro.Precision,
+		evaluation.Micro.Recall,
+		evaluation.Micro.F1,
+		evaluation.MeanAveragePrecision,
+	)
+
+	fmt.Printf("%9s  %9s  %6s  %6s\n", "threshold", "precision", "recall", "f1")
+	for _, metrics := range evaluation.Curve {
+		fmt.Printf("%9.2f  %9.3f  %6.3f  %6.3f\n", metrics.Threshold, metrics.Precision, metrics.Recall, metrics.F1)
+	}
+}
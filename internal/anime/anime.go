@@ -8,6 +8,7 @@ var (
 	ErrAnimeNotFound      = errors.New("anime not found")
 	ErrAnimeAlreadyExists = errors.New("anime already exists")
 	ErrAnimeAncestorAssigned = errors.New("an ancestor folder is already assigned to an anime")
+	ErrFranchiseNotFound     = errors.New("franchise not found")
 )
 
 // Anime is the JSON-friendly anime model used by the frontend service.
@@ -0,0 +1,66 @@
+package anime
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+)
+
+// removeAnimeFromCharacters takes an anime that is being deleted out of its
+// characters. A character that still belongs to another anime is kept, moving
+// to that anime if it was created under the deleted one; the rest are deleted
+// with their image links.
+func (s *Service) removeAnimeFromCharacters(ctx context.Context, animeID uint) error {
+	characters, err := s.dbClient.Character().FindByAnimeID(ctx, animeID)
+	if err != nil {
+		return fmt.Errorf("Character.FindByAnimeID: %w", err)
+	}
+	animeIDs, err := s.dbClient.AnimeCharacter().FindAnimeIDsByCharacters(ctx, characters)
+	if err != nil {
+		return fmt.Errorf("AnimeCharacter.FindAnimeIDsByCharacters: %w", err)
+	}
+
+	deletedIDs := make([]uint, 0)
+	for _, c := range characters {
+		var remaining []uint
+		for _, id := range animeIDs[c.ID] {
+			if id != animeID {
+				remaining = append(remaining, id)
+			}
+		}
+		if len(remaining) == 0 {
+			deletedIDs = append(deletedIDs, c.ID)
+			continue
+		}
+		if c.AnimeID == animeID {
+			c.AnimeID = remaining[0]
+			if err := s.dbClient.Character().Update(ctx, &c); err != nil {
+				return fmt.Errorf("Character.Update: %w", err)
+			}
+			// The character now belongs to that anime without a share.
+			if err := s.dbClient.AnimeCharacter().BatchDelete(ctx, []db.AnimeCharacter{{AnimeID: c.AnimeID, CharacterID: c.ID}}); err != nil {
+				return fmt.Errorf("AnimeCharacter.BatchDelete: %w", err)
+			}
+		}
+		// Keep its titles from being deleted with the anime's.
+		if err := s.dbClient.LocalizedTitle().UpdateAnimeID(ctx, db.LocalizedTitleEntityCharacter, []uint{c.ID}, animeID, remaining[0]); err != nil {
+			return fmt.Errorf("LocalizedTitle.UpdateAnimeID: %w", err)
+		}
+	}
+
+	// Images outside of the anime's folders can also have its characters.
+	if err := s.dbClient.FileCharacter().DeleteByCharacterIDs(ctx, deletedIDs); err != nil {
+		return fmt.Errorf("FileCharacter.DeleteByCharacterIDs: %w", err)
+	}
+	if err := s.dbClient.Character().DeleteByIDs(ctx, deletedIDs); err != nil {
+		return fmt.Errorf("Character.DeleteByIDs: %w", err)
+	}
+	if err := s.dbClient.AnimeCharacter().DeleteByAnimeID(ctx, animeID); err != nil {
+		return fmt.Errorf("AnimeCharacter.DeleteByAnimeID: %w", err)
+	}
+	if err := s.dbClient.AnimeCharacter().DeleteByCharacterIDs(ctx, deletedIDs); err != nil {
+		return fmt.Errorf("AnimeCharacter.DeleteByCharacterIDs: %w", err)
+	}
+	return nil
+}
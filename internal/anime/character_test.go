@@ -0,0 +1,157 @@
+package anime
+
+import (
+	"context"
+	"testing"
+
+	"github.com/michael-freling/anime-image-viewer/internal/animemetadata"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_SharedCharacters(t *testing.T) {
+	ctx := context.Background()
+
+	saber := animemetadata.Character{
+		ID:   "artoria-pendragon",
+		Name: "Saber",
+		Appearances: []animemetadata.CharacterAppearance{
+			{SeriesID: "fate-zero"},
+			{SeriesID: "fate-stay-night"},
+		},
+	}
+	newMock := func() *mockMetadataClient {
+		return &mockMetadataClient{
+			series: map[string]*animemetadata.Series{
+				"fate-zero": {
+					ID:         "fate-zero",
+					Characters: []animemetadata.Character{saber, {ID: "kiritsugu", Name: "Kiritsugu"}},
+				},
+				"fate-stay-night": {
+					ID:         "fate-stay-night",
+					Characters: []animemetadata.Character{saber, {ID: "shirou", Name: "Shirou"}},
+				},
+			},
+		}
+	}
+	characterNames := func(t *testing.T, te tester, animeID uint) []string {
+		t.Helper()
+		characters, err := te.dbClient.Client.Character().FindByAnimeID(context.Background(), animeID)
+		require.NoError(t, err)
+		names := make([]string, len(characters))
+		for i, c := range characters {
+			names[i] = c.Name
+		}
+		return names
+	}
+
+	t.Run("a character in two series is imported once", func(t *testing.T) {
+		te := newTester(t)
+		service := te.serviceWithMetadata(newMock())
+
+		zero, err := service.Create(ctx, "Fate Zero")
+		require.NoError(t, err)
+		_, err = service.ImportFromMetadata(ctx, zero.ID, "fate-zero")
+		require.NoError(t, err)
+		stayNight, err := service.Create(ctx, "Fate Stay Night")
+		require.NoError(t, err)
+		result, err := service.ImportFromMetadata(ctx, stayNight.ID, "fate-stay-night")
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, result.CharactersCreated, "only Shirou is new")
+		assert.Equal(t, 1, result.CharactersUpdated, "Saber is shared")
+		assert.Len(t, db.MustGetAll[db.Character](t, te.dbClient), 3)
+		assert.ElementsMatch(t, []string{"Saber", "Kiritsugu"}, characterNames(t, te, zero.ID))
+		assert.ElementsMatch(t, []string{"Saber", "Shirou"}, characterNames(t, te, stayNight.ID))
+
+		derived, err := service.DeriveCharactersForAnime(context.Background(), stayNight.ID)
+		require.NoError(t, err)
+		for _, c := range derived {
+			if c.CharacterName == "Saber" {
+				assert.Equal(t, []uint{zero.ID, stayNight.ID}, c.AnimeIDs)
+			} else {
+				assert.Equal(t, []uint{stayNight.ID}, c.AnimeIDs)
+			}
+		}
+
+		// A re-import finds the shared row and changes nothing.
+		result, err = service.ImportFromMetadata(ctx, stayNight.ID, "fate-stay-night")
+		require.NoError(t, err)
+		assert.Equal(t, MetadataImportResult{}, *result)
+	})
+
+	t.Run("appearances share a character with anime linked to the other series", func(t *testing.T) {
+		te := newTester(t)
+		service := te.serviceWithMetadata(newMock())
+
+		stayNight, err := service.Create(ctx, "Fate Stay Night")
+		require.NoError(t, err)
+		require.NoError(t, service.LinkMetadataSeries(ctx, stayNight.ID, "fate-stay-night", 0))
+		zero, err := service.Create(ctx, "Fate Zero")
+		require.NoError(t, err)
+		_, err = service.ImportFromMetadata(ctx, zero.ID, "fate-zero")
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"Saber"}, characterNames(t, te, stayNight.ID),
+			"shared before Fate Stay Night is imported")
+	})
+
+	t.Run("deleting an anime keeps characters another anime shares", func(t *testing.T) {
+		te := newTester(t)
+		te.dbClient.Truncate(t, db.LocalizedTitle{})
+		te.config.MetadataLanguages = []string{"en"}
+		service := te.serviceWithMetadata(newMock())
+
+		zero, err := service.Create(ctx, "Fate Zero")
+		require.NoError(t, err)
+		_, err = service.ImportFromMetadata(ctx, zero.ID, "fate-zero")
+		require.NoError(t, err)
+		stayNight, err := service.Create(ctx, "Fate Stay Night")
+		require.NoError(t, err)
+		_, err = service.ImportFromMetadata(ctx, stayNight.ID, "fate-stay-night")
+		require.NoError(t, err)
+		// Saber's titles now belong to the anime imported last; import the
+		// first one again so that deleting it has titles to move.
+		_, err = service.ImportFromMetadata(ctx, zero.ID, "fate-zero")
+		require.NoError(t, err)
+
+		// An image of Fate Stay Night has both characters of Fate Zero.
+		rootFolder, err := service.FindAnimeRootFolder(stayNight.ID)
+		require.NoError(t, err)
+		require.NotNil(t, rootFolder)
+		imageFile := db.File{ParentID: rootFolder.ID, Name: "image.jpg", Type: db.FileTypeImage}
+		require.NoError(t, te.dbClient.Client.File().Create(ctx, &imageFile))
+		zeroCharacters, err := te.dbClient.Client.Character().FindByAnimeID(ctx, zero.ID)
+		require.NoError(t, err)
+		require.Len(t, zeroCharacters, 2)
+		fileCharacters := make([]db.FileCharacter, 0, len(zeroCharacters))
+		for _, c := range zeroCharacters {
+			fileCharacters = append(fileCharacters, db.FileCharacter{FileID: imageFile.ID, CharacterID: c.ID, AddedBy: db.FileTagAddedByUser})
+		}
+		require.NoError(t, db.BatchCreate(te.dbClient.Client, fileCharacters))
+
+		require.NoError(t, service.Delete(ctx, zero.ID))
+
+		characters, err := te.dbClient.Client.Character().FindByAnimeID(context.Background(), stayNight.ID)
+		require.NoError(t, err)
+		require.Len(t, characters, 2)
+		for _, c := range characters {
+			assert.Equal(t, stayNight.ID, c.AnimeID, "Saber moves to the remaining anime")
+		}
+		assert.Len(t, db.MustGetAll[db.Character](t, te.dbClient), 2, "Kiritsugu is deleted")
+		gotFileCharacters := db.MustGetAll[db.FileCharacter](t, te.dbClient)
+		require.Len(t, gotFileCharacters, 1, "Kiritsugu is removed from the image")
+		for _, c := range characters {
+			if c.ID == gotFileCharacters[0].CharacterID {
+				assert.Equal(t, "Saber", c.Name)
+			}
+		}
+		assert.Empty(t, db.MustGetAll[db.AnimeCharacter](t, te.dbClient))
+
+		titles, err := service.SearchTitles(ctx, "Saber")
+		require.NoError(t, err)
+		require.Len(t, titles, 1)
+		assert.Equal(t, stayNight.ID, titles[0].AnimeID)
+	})
+}
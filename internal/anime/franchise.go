@@ -0,0 +1,214 @@
+package anime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/michael-freling/anime-image-viewer/internal/animemetadata"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+)
+
+// Franchise groups several anime, e.g. every Fate series.
+type Franchise struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	// MetadataFranchiseID is the franchise id in the anime metadata database,
+	// or "" for a franchise created by hand.
+	MetadataFranchiseID string `json:"metadataFranchiseId"`
+	AnimeIDs            []uint `json:"animeIds"`
+}
+
+func newFranchise(row db.Franchise, animeIDs []uint) Franchise {
+	franchise := Franchise{
+		ID:       row.ID,
+		Name:     row.Name,
+		AnimeIDs: animeIDs,
+	}
+	if row.MetadataFranchiseID != nil {
+		franchise.MetadataFranchiseID = *row.MetadataFranchiseID
+	}
+	return franchise
+}
+
+// linkFranchise puts an imported anime in the franchise its series belongs to
+// upstream, creating the franchise on first sight.
+//
+// An anime the user moved into or out of a franchise stays where it is, in the
+// same way a renamed folder keeps its name on re-import. A standalone series
+// leaves the anime's franchise alone.
+func (s *Service) linkFranchise(ctx context.Context, animeID uint, metadataFranchiseID string) error {
+	if metadataFranchiseID == "" {
+		return nil
+	}
+
+	row, err := s.dbClient.Anime().FindByValue(ctx, &db.Anime{ID: animeID})
+	if err != nil {
+		return fmt.Errorf("Anime.FindByValue: %w", err)
+	}
+	if row.FranchiseSetByUser {
+		return nil
+	}
+
+	franchise, err := s.dbClient.Franchise().FindByMetadataFranchiseID(ctx, metadataFranchiseID)
+	if errors.Is(err, db.ErrRecordNotFound) {
+		franchise = db.Franchise{
+			Name:                s.franchiseName(ctx, metadataFranchiseID),
+			MetadataFranchiseID: &metadataFranchiseID,
+		}
+		if err := s.dbClient.Franchise().Create(ctx, &franchise); err != nil {
+			return fmt.Errorf("Franchise.Create: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("Franchise.FindByMetadataFranchiseID: %w", err)
+	}
+
+	if equalUintPtr(row.FranchiseID, &franchise.ID) {
+		return nil
+	}
+	row.FranchiseID = &franchise.ID
+	if err := s.dbClient.Anime().Update(ctx, &row); err != nil {
+		return fmt.Errorf("Anime.Update: %w", err)
+	}
+	return nil
+}
+
+// franchiseName looks up a franchise's title. GetSeries only returns the
+// franchise id, so the title comes from a search, which matches franchise ids
+// like "fate" against titles like "Fate". The id is used when the search does
+// not find it.
+func (s *Service) franchiseName(ctx context.Context, metadataFranchiseID string) string {
+	results, err := s.metadataClient.Search(ctx, metadataFranchiseID, 0)
+	if err != nil {
+		return metadataFranchiseID
+	}
+	for _, result := range results {
+		if result.Kind == animemetadata.EntryKindFranchise && result.ID == metadataFranchiseID && result.Title != "" {
+			return result.Title
+		}
+	}
+	return metadataFranchiseID
+}
+
+// ReadFranchises returns every franchise with its anime, sorted by name.
+func (s *Service) ReadFranchises(ctx context.Context) ([]Franchise, error) {
+	rows, err := s.dbClient.Franchise().GetAll()
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	animeRows, err := s.dbClient.Anime().GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("Anime.GetAll: %w", err)
+	}
+	animeIDs := make(map[uint][]uint)
+	for _, a := range animeRows {
+		if a.FranchiseID != nil {
+			animeIDs[*a.FranchiseID] = append(animeIDs[*a.FranchiseID], a.ID)
+		}
+	}
+
+	result := make([]Franchise, len(rows))
+	for i, row := range rows {
+		result[i] = newFranchise(row, animeIDs[row.ID])
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+	})
+	return result, nil
+}
+
+// ReadFranchise returns a single franchise with its anime.
+func (s *Service) ReadFranchise(ctx context.Context, id uint) (Franchise, error) {
+	row, err := s.dbClient.Franchise().FindByValue(ctx, &db.Franchise{ID: id})
+	if errors.Is(err, db.ErrRecordNotFound) {
+		return Franchise{}, fmt.Errorf("%w: id %d", ErrFranchiseNotFound, id)
+	}
+	if err != nil {
+		return Franchise{}, err
+	}
+
+	animeRows, err := s.dbClient.Anime().FindByFranchiseID(ctx, id)
+	if err != nil {
+		return Franchise{}, fmt.Errorf("Anime.FindByFranchiseID: %w", err)
+	}
+	animeIDs := make([]uint, len(animeRows))
+	for i, a := range animeRows {
+		animeIDs[i] = a.ID
+	}
+	return newFranchise(row, animeIDs), nil
+}
+
+// CreateFranchise creates a franchise that is not linked to the metadata
+// database, for anime it does not group.
+func (s *Service) CreateFranchise(ctx context.Context, name string) (Franchise, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Franchise{}, fmt.Errorf("%w: name is required", xerrors.ErrInvalidArgument)
+	}
+	row := db.Franchise{Name: name}
+	if err := s.dbClient.Franchise().Create(ctx, &row); err != nil {
+		return Franchise{}, fmt.Errorf("Franchise.Create: %w", err)
+	}
+	return newFranchise(row, nil), nil
+}
+
+// RenameFranchise changes a franchise's display name. A re-import does not
+// change it back.
+func (s *Service) RenameFranchise(ctx context.Context, id uint, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("%w: name is required", xerrors.ErrInvalidArgument)
+	}
+	row, err := s.dbClient.Franchise().FindByValue(ctx, &db.Franchise{ID: id})
+	if errors.Is(err, db.ErrRecordNotFound) {
+		return fmt.Errorf("%w: id %d", ErrFranchiseNotFound, id)
+	}
+	if err != nil {
+		return err
+	}
+	row.Name = name
+	return s.dbClient.Franchise().Update(ctx, &row)
+}
+
+// DeleteFranchise deletes a franchise. Its anime are kept and become
+// standalone.
+func (s *Service) DeleteFranchise(ctx context.Context, id uint) error {
+	if _, err := s.ReadFranchise(ctx, id); err != nil {
+		return err
+	}
+	return db.NewTransaction(ctx, s.dbClient, func(ctx context.Context) error {
+		if err := s.dbClient.Anime().ClearFranchiseID(ctx, id); err != nil {
+			return fmt.Errorf("Anime.ClearFranchiseID: %w", err)
+		}
+		if err := s.dbClient.Franchise().BatchDelete(ctx, []db.Franchise{{ID: id}}); err != nil {
+			return fmt.Errorf("Franchise.BatchDelete: %w", err)
+		}
+		return nil
+	})
+}
+
+// SetAnimeFranchise moves an anime into a franchise, or out of any franchise
+// when franchiseID is 0. A re-import keeps the choice.
+func (s *Service) SetAnimeFranchise(ctx context.Context, animeID uint, franchiseID uint) error {
+	row, err := s.dbClient.Anime().FindByValue(ctx, &db.Anime{ID: animeID})
+	if errors.Is(err, db.ErrRecordNotFound) {
+		return fmt.Errorf("%w: id %d", ErrAnimeNotFound, animeID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if franchiseID == 0 {
+		row.FranchiseID = nil
+	} else {
+		if _, err := s.ReadFranchise(ctx, franchiseID); err != nil {
+			return err
+		}
+		row.FranchiseID = &franchiseID
+	}
+	row.FranchiseSetByUser = true
+	return s.dbClient.Anime().Update(ctx, &row)
+}
@@ -0,0 +1,192 @@
+package anime
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/michael-freling/anime-image-viewer/internal/animemetadata"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_ImportFromMetadata_Franchise(t *testing.T) {
+	ctx := context.Background()
+
+	newMock := func() *mockMetadataClient {
+		return &mockMetadataClient{
+			searchResults: []animemetadata.SearchResult{
+				{Kind: animemetadata.EntryKindFranchise, ID: "fate", Title: "Fate"},
+			},
+			series: map[string]*animemetadata.Series{
+				"fate-zero":       {ID: "fate-zero", FranchiseID: "fate"},
+				"fate-stay-night": {ID: "fate-stay-night", FranchiseID: "fate"},
+				"gundam":          {ID: "gundam", FranchiseID: "gundam"},
+				"bocchi":          {ID: "bocchi"},
+			},
+		}
+	}
+	importAnime := func(t *testing.T, service *Service, name string, seriesID string) uint {
+		t.Helper()
+		a, err := service.Create(ctx, name)
+		require.NoError(t, err)
+		_, err = service.ImportFromMetadata(ctx, a.ID, seriesID)
+		require.NoError(t, err)
+		return a.ID
+	}
+
+	t.Run("groups anime of one franchise", func(t *testing.T) {
+		te := newTester(t)
+		te.dbClient.Truncate(t, db.Franchise{})
+		service := te.serviceWithMetadata(newMock())
+
+		zero := importAnime(t, service, "Fate/Zero", "fate-zero")
+		stayNight := importAnime(t, service, "Fate/stay night", "fate-stay-night")
+		importAnime(t, service, "Bocchi", "bocchi")
+
+		franchises, err := service.ReadFranchises(ctx)
+		require.NoError(t, err)
+		require.Len(t, franchises, 1, "a standalone series creates no franchise")
+		assert.Equal(t, "Fate", franchises[0].Name, "the title comes from the search")
+		assert.Equal(t, "fate", franchises[0].MetadataFranchiseID)
+		assert.Equal(t, []uint{zero, stayNight}, franchises[0].AnimeIDs)
+	})
+
+	t.Run("falls back to the franchise id when search does not find it", func(t *testing.T) {
+		te := newTester(t)
+		te.dbClient.Truncate(t, db.Franchise{})
+		mock := newMock()
+		mock.searchErr = errors.New("unavailable")
+		service := te.serviceWithMetadata(mock)
+
+		importAnime(t, service, "Gundam", "gundam")
+
+		franchises, err := service.ReadFranchises(ctx)
+		require.NoError(t, err)
+		require.Len(t, franchises, 1)
+		assert.Equal(t, "gundam", franchises[0].Name)
+	})
+
+	t.Run("re-import keeps the user's name and hand-made franchise", func(t *testing.T) {
+		te := newTester(t)
+		te.dbClient.Truncate(t, db.Franchise{})
+		service := te.serviceWithMetadata(newMock())
+
+		zero := importAnime(t, service, "Fate/Zero", "fate-zero")
+		stayNight := importAnime(t, service, "Fate/stay night", "fate-stay-night")
+		franchises, err := service.ReadFranchises(ctx)
+		require.NoError(t, err)
+		require.Len(t, franchises, 1)
+		require.NoError(t, service.RenameFranchise(ctx, franchises[0].ID, "Type-Moon"))
+
+		mine, err := service.CreateFranchise(ctx, "Favourites")
+		require.NoError(t, err)
+		require.NoError(t, service.SetAnimeFranchise(ctx, stayNight, mine.ID))
+
+		_, err = service.ImportFromMetadata(ctx, zero, "fate-zero")
+		require.NoError(t, err)
+		_, err = service.ImportFromMetadata(ctx, stayNight, "fate-stay-night")
+		require.NoError(t, err)
+
+		fate, err := service.ReadFranchise(ctx, franchises[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Type-Moon", fate.Name)
+		assert.Equal(t, []uint{zero}, fate.AnimeIDs)
+		favourites, err := service.ReadFranchise(ctx, mine.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []uint{stayNight}, favourites.AnimeIDs)
+	})
+
+	t.Run("re-import keeps an anime the user moved out of its franchise", func(t *testing.T) {
+		te := newTester(t)
+		te.dbClient.Truncate(t, db.Franchise{})
+		service := te.serviceWithMetadata(newMock())
+
+		zero := importAnime(t, service, "Fate/Zero", "fate-zero")
+		stayNight := importAnime(t, service, "Fate/stay night", "fate-stay-night")
+		gundam := importAnime(t, service, "Gundam", "gundam")
+		franchises, err := service.ReadFranchises(ctx)
+		require.NoError(t, err)
+		require.Len(t, franchises, 2)
+		fateID := franchises[0].ID
+		gundamID := franchises[1].ID
+
+		// a franchise from the metadata database is chosen by hand
+		require.NoError(t, service.SetAnimeFranchise(ctx, zero, gundamID))
+		require.NoError(t, service.SetAnimeFranchise(ctx, stayNight, 0))
+		require.NoError(t, service.DeleteFranchise(ctx, gundamID))
+
+		_, err = service.ImportFromMetadata(ctx, zero, "fate-zero")
+		require.NoError(t, err)
+		_, err = service.ImportFromMetadata(ctx, stayNight, "fate-stay-night")
+		require.NoError(t, err)
+		_, err = service.ImportFromMetadata(ctx, gundam, "gundam")
+		require.NoError(t, err)
+
+		fate, err := service.ReadFranchise(ctx, fateID)
+		require.NoError(t, err)
+		assert.Empty(t, fate.AnimeIDs)
+		franchises, err = service.ReadFranchises(ctx)
+		require.NoError(t, err)
+		assert.Len(t, franchises, 1, "a deleted franchise isn't created again")
+	})
+}
+
+func TestService_Franchise(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("delete keeps the anime", func(t *testing.T) {
+		te := newTester(t)
+		te.dbClient.Truncate(t, db.Franchise{})
+		service := te.service()
+
+		a, err := service.Create(ctx, "Gundam")
+		require.NoError(t, err)
+		f, err := service.CreateFranchise(ctx, "Gundam")
+		require.NoError(t, err)
+		require.NoError(t, service.SetAnimeFranchise(ctx, a.ID, f.ID))
+
+		require.NoError(t, service.DeleteFranchise(ctx, f.ID))
+
+		_, err = service.ReadFranchise(ctx, f.ID)
+		assert.ErrorIs(t, err, ErrFranchiseNotFound)
+		row, err := te.dbClient.Client.Anime().FindByValue(ctx, &db.Anime{ID: a.ID})
+		require.NoError(t, err)
+		assert.Nil(t, row.FranchiseID)
+	})
+
+	t.Run("set to 0 detaches the anime", func(t *testing.T) {
+		te := newTester(t)
+		te.dbClient.Truncate(t, db.Franchise{})
+		service := te.service()
+
+		a, err := service.Create(ctx, "Gundam")
+		require.NoError(t, err)
+		f, err := service.CreateFranchise(ctx, "Gundam")
+		require.NoError(t, err)
+		require.NoError(t, service.SetAnimeFranchise(ctx, a.ID, f.ID))
+		require.NoError(t, service.SetAnimeFranchise(ctx, a.ID, 0))
+
+		got, err := service.ReadFranchise(ctx, f.ID)
+		require.NoError(t, err)
+		assert.Empty(t, got.AnimeIDs)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		te := newTester(t)
+		te.dbClient.Truncate(t, db.Franchise{})
+		service := te.service()
+
+		a, err := service.Create(ctx, "Gundam")
+		require.NoError(t, err)
+
+		_, err = service.CreateFranchise(ctx, "  ")
+		assert.ErrorIs(t, err, xerrors.ErrInvalidArgument)
+		assert.ErrorIs(t, service.RenameFranchise(ctx, 999, "x"), ErrFranchiseNotFound)
+		assert.ErrorIs(t, service.DeleteFranchise(ctx, 999), ErrFranchiseNotFound)
+		assert.ErrorIs(t, service.SetAnimeFranchise(ctx, a.ID, 999), ErrFranchiseNotFound)
+		assert.ErrorIs(t, service.SetAnimeFranchise(ctx, 999, 0), ErrAnimeNotFound)
+	})
+}
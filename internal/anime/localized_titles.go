@@ -0,0 +1,147 @@
+package anime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+)
+
+// TitleMatch is an anime, season or character whose title in one of the
+// imported languages matches a search.
+type TitleMatch struct {
+	EntityType string `json:"entityType"`
+	EntityID   uint   `json:"entityId"`
+	AnimeID    uint   `json:"animeId"`
+	Language   string `json:"language"`
+	Title      string `json:"title"`
+}
+
+// importLocalizedTitles stores the titles of the series, its top-level entries
+// and its cast in every configured language.
+//
+// Folder and character names are not touched: those follow the most preferred
+// language through the regular import, and renaming a folder moves it on
+// disk. The UI picks which stored title to display instead.
+func (s *Service) importLocalizedTitles(ctx context.Context, animeID uint, rootFolderID uint, seriesID string) error {
+	languages := s.config.MetadataLanguages
+	if len(languages) == 0 {
+		return nil
+	}
+
+	topLevel, err := s.dbClient.File().FindDirectChildDirectories(rootFolderID)
+	if err != nil {
+		return fmt.Errorf("File.FindDirectChildDirectories: %w", err)
+	}
+	folderIDs := make(map[string]uint, len(topLevel))
+	for _, folder := range topLevel {
+		if isLinked(folder) {
+			folderIDs[*folder.MetadataEntryID] = folder.ID
+		}
+	}
+
+	characters, err := s.dbClient.Character().FindByAnimeID(ctx, animeID)
+	if err != nil {
+		return fmt.Errorf("Character.FindByAnimeID: %w", err)
+	}
+	characterIDs := make(map[string]uint, len(characters))
+	for _, character := range characters {
+		if character.MetadataCharacterID != nil && *character.MetadataCharacterID != "" {
+			characterIDs[*character.MetadataCharacterID] = character.ID
+		}
+	}
+
+	titles := make([]db.LocalizedTitle, 0)
+	add := func(entityType db.LocalizedTitleEntity, entityID uint, language string, title string) {
+		title = strings.TrimSpace(title)
+		if entityID == 0 || title == "" {
+			return
+		}
+		titles = append(titles, db.LocalizedTitle{
+			EntityType: entityType,
+			EntityID:   entityID,
+			AnimeID:    animeID,
+			Language:   language,
+			Title:      title,
+		})
+	}
+
+	for _, language := range languages {
+		series, err := s.metadataClient.GetSeriesInLanguage(ctx, seriesID, language)
+		if err != nil {
+			return fmt.Errorf("animemetadata.GetSeriesInLanguage(%q, %q): %w", seriesID, language, err)
+		}
+
+		add(db.LocalizedTitleEntityAnime, animeID, language, series.Title)
+		for _, group := range groupSeasons(series.Seasons) {
+			// An untitled season is "Season N" in every language, so only
+			// upstream titles are worth storing. Parts are positional.
+			add(db.LocalizedTitleEntityFile, folderIDs[group.parts[0].ID], language, upstreamSeasonTitle(group))
+		}
+		for _, movie := range series.Movies {
+			add(db.LocalizedTitleEntityFile, folderIDs[movie.ID], language, movie.Title)
+		}
+		for _, special := range series.Specials {
+			add(db.LocalizedTitleEntityFile, folderIDs[special.ID], language, special.Title)
+		}
+		for _, character := range series.Characters {
+			add(db.LocalizedTitleEntityCharacter, characterIDs[character.ID], language, character.Name)
+		}
+	}
+
+	if err := s.dbClient.LocalizedTitle().BatchUpsert(ctx, titles); err != nil {
+		return fmt.Errorf("LocalizedTitle.BatchUpsert: %w", err)
+	}
+	return nil
+}
+
+// upstreamSeasonTitle returns the first title upstream gives a season group,
+// or "" when none of its parts carries one.
+func upstreamSeasonTitle(group seasonGroup) string {
+	for _, part := range group.parts {
+		if strings.TrimSpace(part.Title) != "" {
+			return part.Title
+		}
+	}
+	return ""
+}
+
+// ReadLocalizedTitles returns the stored titles of the given anime, season
+// folders or characters, keyed by id and then by language.
+func (s *Service) ReadLocalizedTitles(
+	ctx context.Context,
+	entityType db.LocalizedTitleEntity,
+	ids []uint,
+) (map[uint]map[string]string, error) {
+	titles, err := s.dbClient.LocalizedTitle().FindByEntityIDs(ctx, entityType, ids)
+	if err != nil {
+		return nil, fmt.Errorf("LocalizedTitle.FindByEntityIDs: %w", err)
+	}
+	return titles.ToMap(), nil
+}
+
+// SearchTitles finds anime, seasons and characters by their title in any of
+// the imported languages, so a series can be found by its Japanese or romaji
+// title even though its folder is named in English.
+func (s *Service) SearchTitles(ctx context.Context, query string) ([]TitleMatch, error) {
+	titles, err := s.dbClient.LocalizedTitle().SearchByTitle(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("LocalizedTitle.SearchByTitle: %w", err)
+	}
+	if len(titles) == 0 {
+		return nil, nil
+	}
+
+	result := make([]TitleMatch, len(titles))
+	for i, title := range titles {
+		result[i] = TitleMatch{
+			EntityType: string(title.EntityType),
+			EntityID:   title.EntityID,
+			AnimeID:    title.AnimeID,
+			Language:   title.Language,
+			Title:      title.Title,
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,138 @@
+package anime
+
+import (
+	"context"
+	"testing"
+
+	"github.com/michael-freling/anime-image-viewer/internal/animemetadata"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_ImportLocalizedTitles(t *testing.T) {
+	ctx := context.Background()
+
+	newMock := func() *mockMetadataClient {
+		return &mockMetadataClient{
+			series: map[string]*animemetadata.Series{
+				"frieren": {
+					ID:    "frieren",
+					Title: "Frieren",
+					Seasons: []animemetadata.Season{
+						{ID: "frieren-s1", Number: 1, Title: "Journey's End"},
+						{ID: "frieren-s2", Number: 2},
+					},
+					Characters: []animemetadata.Character{{ID: "frieren-c", Name: "Frieren"}},
+				},
+			},
+			localizedSeries: map[string]map[string]*animemetadata.Series{
+				"ja": {
+					"frieren": {
+						ID:    "frieren",
+						Title: "葬送のフリーレン",
+						Seasons: []animemetadata.Season{
+							{ID: "frieren-s1", Number: 1, Title: "旅の終わり"},
+							{ID: "frieren-s2", Number: 2},
+						},
+						Characters: []animemetadata.Character{{ID: "frieren-c", Name: "フリーレン"}},
+					},
+				},
+			},
+		}
+	}
+
+	t.Run("stores a title per language without renaming folders", func(t *testing.T) {
+		te := newTester(t)
+		te.dbClient.Truncate(t, db.LocalizedTitle{})
+		te.config.MetadataLanguages = []string{"en", "ja"}
+		service := te.serviceWithMetadata(newMock())
+
+		a, err := service.Create(ctx, "Frieren")
+		require.NoError(t, err)
+		_, err = service.ImportFromMetadata(ctx, a.ID, "frieren")
+		require.NoError(t, err)
+
+		seasons, err := service.GetAnimeSeasons(a.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Journey's End", "Season 2"}, seasonNames(seasons))
+
+		animeTitles, err := service.ReadLocalizedTitles(ctx, db.LocalizedTitleEntityAnime, []uint{a.ID})
+		require.NoError(t, err)
+		assert.Equal(t, map[uint]map[string]string{
+			a.ID: {"en": "Frieren", "ja": "葬送のフリーレン"},
+		}, animeTitles)
+
+		seasonTitles, err := service.ReadLocalizedTitles(ctx, db.LocalizedTitleEntityFile, []uint{seasons[0].ID, seasons[1].ID})
+		require.NoError(t, err)
+		assert.Equal(t, map[uint]map[string]string{
+			seasons[0].ID: {"en": "Journey's End", "ja": "旅の終わり"},
+		}, seasonTitles, "an untitled season has no titles to store")
+
+		characters, err := te.dbClient.Client.Character().FindByAnimeID(context.Background(), a.ID)
+		require.NoError(t, err)
+		require.Len(t, characters, 1)
+		assert.Equal(t, "Frieren", characters[0].Name)
+		characterTitles, err := service.ReadLocalizedTitles(ctx, db.LocalizedTitleEntityCharacter, []uint{characters[0].ID})
+		require.NoError(t, err)
+		assert.Equal(t, "フリーレン", characterTitles[characters[0].ID]["ja"])
+
+		matches, err := service.SearchTitles(ctx, "フリーレン")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []TitleMatch{
+			{EntityType: "anime", EntityID: a.ID, AnimeID: a.ID, Language: "ja", Title: "葬送のフリーレン"},
+			{EntityType: "character", EntityID: characters[0].ID, AnimeID: a.ID, Language: "ja", Title: "フリーレン"},
+		}, matches)
+	})
+
+	t.Run("re-import updates titles in place", func(t *testing.T) {
+		te := newTester(t)
+		te.dbClient.Truncate(t, db.LocalizedTitle{})
+		te.config.MetadataLanguages = []string{"en", "ja"}
+		mock := newMock()
+		service := te.serviceWithMetadata(mock)
+
+		a, err := service.Create(ctx, "Frieren")
+		require.NoError(t, err)
+		_, err = service.ImportFromMetadata(ctx, a.ID, "frieren")
+		require.NoError(t, err)
+
+		mock.localizedSeries["ja"]["frieren"].Title = "葬送のフリーレン 第2期"
+		_, err = service.ImportFromMetadata(ctx, a.ID, "frieren")
+		require.NoError(t, err)
+
+		titles, err := service.ReadLocalizedTitles(ctx, db.LocalizedTitleEntityAnime, []uint{a.ID})
+		require.NoError(t, err)
+		assert.Equal(t, "葬送のフリーレン 第2期", titles[a.ID]["ja"])
+		assert.Len(t, db.MustGetAll[db.LocalizedTitle](t, te.dbClient), 6, "3 entities in 2 languages, no duplicates")
+	})
+
+	t.Run("no languages configured stores nothing", func(t *testing.T) {
+		te := newTester(t)
+		te.dbClient.Truncate(t, db.LocalizedTitle{})
+		service := te.serviceWithMetadata(newMock())
+
+		a, err := service.Create(ctx, "Frieren")
+		require.NoError(t, err)
+		_, err = service.ImportFromMetadata(ctx, a.ID, "frieren")
+		require.NoError(t, err)
+
+		assert.Empty(t, db.MustGetAll[db.LocalizedTitle](t, te.dbClient))
+	})
+
+	t.Run("deleting the anime removes its titles", func(t *testing.T) {
+		te := newTester(t)
+		te.dbClient.Truncate(t, db.LocalizedTitle{})
+		te.config.MetadataLanguages = []string{"en", "ja"}
+		service := te.serviceWithMetadata(newMock())
+
+		a, err := service.Create(ctx, "Frieren")
+		require.NoError(t, err)
+		_, err = service.ImportFromMetadata(ctx, a.ID, "frieren")
+		require.NoError(t, err)
+		require.NotEmpty(t, db.MustGetAll[db.LocalizedTitle](t, te.dbClient))
+
+		require.NoError(t, service.Delete(ctx, a.ID))
+		assert.Empty(t, db.MustGetAll[db.LocalizedTitle](t, te.dbClient))
+	})
+}
@@ -17,7 +17,7 @@ type tester struct {
 func newTester(t *testing.T) tester {
 	t.Helper()
 	dbClient := db.NewTestClient(t)
-	dbClient.Truncate(t, db.File{}, db.Tag{}, db.Anime{}, db.FileTag{}, db.Character{}, db.FileCharacter{})
+	dbClient.Truncate(t, db.File{}, db.Tag{}, db.Anime{}, db.FileTag{}, db.Character{}, db.FileCharacter{}, db.AnimeCharacter{})
 	cfg := config.Config{
 		ImageRootDirectory: t.TempDir(),
 	}
@@ -32,9 +32,9 @@ func (te tester) directoryReader() *image.DirectoryReader {
 }
 
 func (te tester) service() *Service {
-	return NewService(te.dbClient.Client, te.directoryReader(), te.config, nil)
+	return NewService(te.dbClient.Client, te.directoryReader(), te.config, nil, nil)
 }
 
 func (te tester) serviceWithMetadata(client animemetadata.Client) *Service {
-	return NewService(te.dbClient.Client, te.directoryReader(), te.config, client)
+	return NewService(te.dbClient.Client, te.directoryReader(), te.config, client, nil)
 }
@@ -8,6 +8,7 @@ import (
 
 	"github.com/michael-freling/anime-image-viewer/internal/animemetadata"
 	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/event"
 	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
 )
 
@@ -19,6 +20,9 @@ type MetadataImportResult struct {
 	SeasonsUpdated    int `json:"seasonsUpdated"`
 	CharactersCreated int `json:"charactersCreated"`
 	CharactersUpdated int `json:"charactersUpdated"`
+	// NewSeasons names the folders the import created, as "Season" or
+	// "Season/Part" for a split-cour part.
+	NewSeasons []string `json:"newSeasons"`
 }
 
 // sanitizeFolderName replaces characters that are invalid in folder names
@@ -280,6 +284,9 @@ func (s *Service) ImportFromMetadata(ctx context.Context, animeID uint, seriesID
 	if err := s.LinkMetadataSeries(ctx, animeID, series.ID, seriesAniListID(series)); err != nil {
 		return nil, fmt.Errorf("LinkMetadataSeries: %w", err)
 	}
+	if err := s.linkFranchise(ctx, animeID, series.FranchiseID); err != nil {
+		return nil, fmt.Errorf("linkFranchise: %w", err)
+	}
 
 	rootFolder, err := s.FindAnimeRootFolder(animeID)
 	if err != nil {
@@ -307,7 +314,15 @@ func (s *Service) ImportFromMetadata(ctx context.Context, animeID uint, seriesID
 	if err := s.importCharacters(ctx, animeID, series.Characters, result); err != nil {
 		return nil, err
 	}
+	if err := s.importLocalizedTitles(ctx, animeID, rootFolder.ID, series.ID); err != nil {
+		return nil, err
+	}
 
+	s.eventBus.Publish(ctx, event.TypeAnimeMetadataImported, event.AnimeMetadataImported{
+		AnimeID:    animeID,
+		SeriesID:   series.ID,
+		NewSeasons: result.NewSeasons,
+	})
 	return result, nil
 }
 
@@ -358,6 +373,10 @@ func (s *Service) importSeasons(
 			return fmt.Errorf("File.FindDirectChildDirectories for season %d: %w", seasonNumber, err)
 		}
 		partIndex := newFolderIndex(children)
+		parent, err := s.dbClient.File().FindByValue(ctx, &db.File{ID: parentID})
+		if err != nil {
+			return fmt.Errorf("File.FindByValue for season %d: %w", seasonNumber, err)
+		}
 
 		for position, part := range group.parts {
 			number := partNumber(part)
@@ -366,7 +385,7 @@ func (s *Service) importSeasons(
 				// part at its sorted position.
 				number = position + 1
 			}
-			if err := s.importSeasonPart(ctx, parentID, partIndex, number, part, result); err != nil {
+			if err := s.importSeasonPart(ctx, parent, partIndex, number, part, result); err != nil {
 				return err
 			}
 		}
@@ -379,7 +398,7 @@ func (s *Service) importSeasons(
 // existing folder instead of leaving a stale one behind.
 func (s *Service) importSeasonPart(
 	ctx context.Context,
-	parentID uint,
+	parent db.File,
 	index *folderIndex,
 	number int,
 	part animemetadata.Season,
@@ -404,7 +423,7 @@ func (s *Service) importSeasonPart(
 		return nil
 	}
 
-	created, err := s.CreateSubSeason(ctx, parentID, partName)
+	created, err := s.CreateSubSeason(ctx, parent.ID, partName)
 	if err != nil {
 		if isAlreadyExists(err) {
 			return nil
@@ -412,6 +431,7 @@ func (s *Service) importSeasonPart(
 		return fmt.Errorf("CreateSubSeason %s: %w", partName, err)
 	}
 	result.SeasonsCreated++
+	result.NewSeasons = append(result.NewSeasons, parent.Name+"/"+created.Name)
 
 	if err := s.updateSeasonAiringInfo(ctx, created.ID, part.ReleaseSeason, part.ReleaseYear); err != nil {
 		return fmt.Errorf("updateSeasonAiringInfo for %s: %w", partName, err)
@@ -511,6 +531,7 @@ func (s *Service) ensureEntry(
 		return 0, fmt.Errorf("CreateSeason %s %q: %w", spec.seasonType, spec.title, err)
 	}
 	result.SeasonsCreated++
+	result.NewSeasons = append(result.NewSeasons, created.Name)
 
 	if err := s.updateSeasonAiringInfo(ctx, created.ID, spec.releaseSeason, spec.releaseYear); err != nil {
 		return 0, fmt.Errorf("updateSeasonAiringInfo for %s %q: %w", spec.seasonType, spec.title, err)
@@ -611,13 +632,17 @@ func (s *Service) renameImportedFolder(ctx context.Context, file db.File, folder
 // importCharacters upserts the series' cast. Characters are matched by their
 // upstream id so a rename upstream updates the existing row, keeping the image
 // links (FileCharacter) that point at it.
+//
+// A character already imported for another anime, e.g. Saber for Fate/Zero and
+// Fate/stay night, is shared with this one rather than copied, and one that
+// appears in other linked series is shared with their anime as well.
 func (s *Service) importCharacters(
 	ctx context.Context,
 	animeID uint,
 	characters []animemetadata.Character,
 	result *MetadataImportResult,
 ) error {
-	existing, err := s.dbClient.Character().FindByAnimeID(animeID)
+	existing, err := s.dbClient.Character().FindByAnimeID(ctx, animeID)
 	if err != nil {
 		return fmt.Errorf("Character.FindByAnimeID: %w", err)
 	}
@@ -632,6 +657,41 @@ func (s *Service) importCharacters(
 		byName[strings.ToLower(character.Name)] = character
 	}
 
+	missingIDs := make([]string, 0)
+	for _, character := range characters {
+		if _, ok := byMetadataID[character.ID]; !ok && character.ID != "" {
+			missingIDs = append(missingIDs, character.ID)
+		}
+	}
+	elsewhere, err := s.dbClient.Character().FindByMetadataCharacterIDs(ctx, missingIDs)
+	if err != nil {
+		return fmt.Errorf("Character.FindByMetadataCharacterIDs: %w", err)
+	}
+	sharedFromElsewhere := make(map[uint]bool, len(elsewhere))
+	for _, character := range elsewhere {
+		// The oldest row wins if duplicates predate the merge.
+		if _, ok := byMetadataID[*character.MetadataCharacterID]; !ok {
+			byMetadataID[*character.MetadataCharacterID] = character
+			sharedFromElsewhere[character.ID] = true
+		}
+	}
+
+	animeBySeriesID, err := s.linkedAnimeBySeriesID(ctx)
+	if err != nil {
+		return err
+	}
+
+	shares := make([]db.AnimeCharacter, 0)
+	share := func(row db.Character, appearances []animemetadata.CharacterAppearance) {
+		for _, appearance := range appearances {
+			for _, otherAnimeID := range animeBySeriesID[appearance.SeriesID] {
+				if otherAnimeID != row.AnimeID {
+					shares = append(shares, db.AnimeCharacter{AnimeID: otherAnimeID, CharacterID: row.ID})
+				}
+			}
+		}
+	}
+
 	for _, character := range characters {
 		// The dataset carries no name for some characters yet; there is
 		// nothing to store for those.
@@ -646,9 +706,14 @@ func (s *Service) importCharacters(
 			if err != nil {
 				return err
 			}
+			if sharedFromElsewhere[row.ID] {
+				shares = append(shares, db.AnimeCharacter{AnimeID: animeID, CharacterID: row.ID})
+				changed = true
+			}
 			if changed {
 				result.CharactersUpdated++
 			}
+			share(row, character.Appearances)
 			continue
 		}
 
@@ -663,10 +728,28 @@ func (s *Service) importCharacters(
 		byName[strings.ToLower(name)] = newRow
 		claimed[newRow.ID] = true
 		result.CharactersCreated++
+		share(newRow, character.Appearances)
+	}
+
+	if err := s.dbClient.AnimeCharacter().BatchCreateIgnoringExisting(ctx, shares); err != nil {
+		return fmt.Errorf("AnimeCharacter.BatchCreateIgnoringExisting: %w", err)
 	}
 	return nil
 }
 
+// linkedAnimeBySeriesID maps each linked series id to its anime.
+func (s *Service) linkedAnimeBySeriesID(ctx context.Context) (map[string][]uint, error) {
+	linked, err := s.dbClient.Anime().FindAllLinkedToMetadata(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Anime.FindAllLinkedToMetadata: %w", err)
+	}
+	result := make(map[string][]uint, len(linked))
+	for _, a := range linked {
+		result[*a.MetadataSeriesID] = append(result[*a.MetadataSeriesID], a.ID)
+	}
+	return result, nil
+}
+
 // matchCharacter resolves an upstream character to an existing row, preferring
 // its id and adopting an unlinked same-name row otherwise.
 func matchCharacter(
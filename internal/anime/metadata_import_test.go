@@ -21,6 +21,9 @@ type mockMetadataClient struct {
 	series         map[string]*animemetadata.Series
 	seriesErr      error
 	getSeriesCalls int
+	// localizedSeries maps a language to the series as that language names
+	// it. A language without an entry falls back to series.
+	localizedSeries map[string]map[string]*animemetadata.Series
 }
 
 func (m *mockMetadataClient) Search(_ context.Context, _ string, _ int) ([]animemetadata.SearchResult, error) {
@@ -39,6 +42,20 @@ func (m *mockMetadataClient) GetSeries(_ context.Context, id string) (*animemeta
 	return series, nil
 }
 
+func (m *mockMetadataClient) GetSeriesInLanguage(_ context.Context, id string, language string) (*animemetadata.Series, error) {
+	if m.seriesErr != nil {
+		return nil, m.seriesErr
+	}
+	if series, ok := m.localizedSeries[language][id]; ok {
+		return series, nil
+	}
+	series, ok := m.series[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: series %q", animemetadata.ErrNotFound, id)
+	}
+	return series, nil
+}
+
 func intPtr(v int) *int { return &v }
 
 // seasonNames flattens the season tree into "name" / "name/child" strings so
@@ -402,7 +419,7 @@ func TestService_ImportFromMetadata(t *testing.T) {
 		assert.Equal(t, db.SeasonTypeOther, special.SeasonType)
 		assert.Nil(t, special.SeasonNumber)
 
-		characters, err := te.dbClient.Client.Character().FindByAnimeID(anime.ID)
+		characters, err := te.dbClient.Client.Character().FindByAnimeID(context.Background(), anime.ID)
 		require.NoError(t, err)
 		require.Len(t, characters, 2)
 	})
@@ -552,7 +569,7 @@ func TestService_ImportFromMetadata(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, 1, result.CharactersCreated)
 
-		characters, err := te.dbClient.Client.Character().FindByAnimeID(anime.ID)
+		characters, err := te.dbClient.Client.Character().FindByAnimeID(context.Background(), anime.ID)
 		require.NoError(t, err)
 		assert.Len(t, characters, 2)
 	})
@@ -1119,7 +1136,7 @@ func TestService_ImportFromMetadata_Upsert(t *testing.T) {
 		_, err = service.ImportFromMetadata(ctx, anime.ID, "fz")
 		require.NoError(t, err)
 
-		before, err := te.dbClient.Client.Character().FindByAnimeID(anime.ID)
+		before, err := te.dbClient.Client.Character().FindByAnimeID(context.Background(), anime.ID)
 		require.NoError(t, err)
 		require.Len(t, before, 1)
 		originalID := before[0].ID
@@ -1132,7 +1149,7 @@ func TestService_ImportFromMetadata_Upsert(t *testing.T) {
 		assert.Equal(t, 0, result.CharactersCreated)
 		assert.Equal(t, 1, result.CharactersUpdated)
 
-		after, err := te.dbClient.Client.Character().FindByAnimeID(anime.ID)
+		after, err := te.dbClient.Client.Character().FindByAnimeID(context.Background(), anime.ID)
 		require.NoError(t, err)
 		require.Len(t, after, 1, "the character was updated, not duplicated")
 		assert.Equal(t, originalID, after[0].ID, "the row id is stable, so FileCharacter links survive")
@@ -1156,7 +1173,7 @@ func TestService_ImportFromMetadata_Upsert(t *testing.T) {
 		_, err = service.ImportFromMetadata(ctx, anime.ID, "cr")
 		require.NoError(t, err)
 
-		rows, err := te.dbClient.Client.Character().FindByAnimeID(anime.ID)
+		rows, err := te.dbClient.Client.Character().FindByAnimeID(context.Background(), anime.ID)
 		require.NoError(t, err)
 		require.Len(t, rows, 1)
 		rows[0].Name = "My Saber"
@@ -1167,7 +1184,7 @@ func TestService_ImportFromMetadata_Upsert(t *testing.T) {
 		_, err = service.ImportFromMetadata(ctx, anime.ID, "cr")
 		require.NoError(t, err)
 
-		after, err := te.dbClient.Client.Character().FindByAnimeID(anime.ID)
+		after, err := te.dbClient.Client.Character().FindByAnimeID(context.Background(), anime.ID)
 		require.NoError(t, err)
 		require.Len(t, after, 1)
 		assert.Equal(t, "My Saber", after[0].Name)
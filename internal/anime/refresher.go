@@ -0,0 +1,327 @@
+package anime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+)
+
+// ErrRefreshInProgress is returned when a refresh is requested while another
+// one is still running.
+var ErrRefreshInProgress = errors.New("metadata refresh is already in progress")
+
+// refreshStartupDelay postpones an overdue refresh after the app starts, so it
+// does not compete with the startup image scan for SQLite.
+const refreshStartupDelay = 2 * time.Minute
+
+// NewSeasonsEvent reports the folders a refresh created for one anime.
+type NewSeasonsEvent struct {
+	AnimeID   uint     `json:"animeId"`
+	AnimeName string   `json:"animeName"`
+	Seasons   []string `json:"seasons"`
+}
+
+// RefreshNotifier is told when a refresh creates seasons, so the UI can tell
+// the user about them. frontend implements it by emitting a Wails event.
+type RefreshNotifier interface {
+	NotifyNewSeasons(ctx context.Context, event NewSeasonsEvent)
+}
+
+// RefreshChange is what one refresh run changed on one anime.
+type RefreshChange struct {
+	AnimeID   uint
+	AnimeName string
+	MetadataImportResult
+	// Error is set when the import for this anime failed; the run carries on
+	// with the rest.
+	Error string
+}
+
+// RefreshRun is one pass over every linked anime. Changes lists only anime
+// that were changed or failed.
+type RefreshRun struct {
+	ID          uint
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	AnimeCount  int
+	FailedCount int
+	Changes     []RefreshChange
+}
+
+// MetadataRefresher periodically re-imports every anime linked to the anime
+// metadata database, so that seasons and characters added upstream show up
+// without a manual import.
+//
+// It goes through ImportFromMetadata, so folders and characters the user
+// renamed keep their names exactly as they do on a manual re-import.
+type MetadataRefresher struct {
+	logger   *slog.Logger
+	service  *Service
+	dbClient *db.Client
+	config   config.MetadataRefreshConfig
+	notifier RefreshNotifier
+
+	// mutex serialises the scheduled runs with ones requested from the UI.
+	mutex sync.Mutex
+	now   func() time.Time
+}
+
+// NewMetadataRefresher creates a new MetadataRefresher. notifier may be nil.
+func NewMetadataRefresher(
+	logger *slog.Logger,
+	service *Service,
+	dbClient *db.Client,
+	conf config.Config,
+	notifier RefreshNotifier,
+) *MetadataRefresher {
+	return &MetadataRefresher{
+		logger:   logger,
+		service:  service,
+		dbClient: dbClient,
+		config:   conf.MetadataRefresh,
+		notifier: notifier,
+		now:      time.Now,
+	}
+}
+
+// Start launches the refresh loop. It returns immediately and does not block
+// the caller. The loop stops when ctx is cancelled (e.g. on app shutdown).
+func (r *MetadataRefresher) Start(ctx context.Context) {
+	if r.config.Disabled {
+		r.logger.InfoContext(ctx, "metadata refresh is disabled")
+		return
+	}
+	go r.run(ctx)
+}
+
+func (r *MetadataRefresher) interval() time.Duration {
+	return time.Duration(r.config.IntervalHours) * time.Hour
+}
+
+func (r *MetadataRefresher) run(ctx context.Context) {
+	delay, err := r.nextRunDelay(ctx)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "metadata refresh: failed to read the last run", "error", err)
+		delay = r.interval()
+	}
+	r.logger.InfoContext(ctx, "metadata refresh scheduled", "in", delay)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		if _, err := r.Refresh(ctx); err != nil && !errors.Is(err, ErrRefreshInProgress) {
+			r.logger.ErrorContext(ctx, "metadata refresh failed", "error", err)
+		}
+		timer.Reset(r.interval())
+	}
+}
+
+// nextRunDelay schedules the first run relative to the last recorded one, so
+// that restarting the app neither skips nor repeats a refresh.
+func (r *MetadataRefresher) nextRunDelay(ctx context.Context) (time.Duration, error) {
+	runs, err := r.dbClient.MetadataRefreshRun().FindLatest(ctx, 1)
+	if err != nil {
+		return 0, fmt.Errorf("MetadataRefreshRun.FindLatest: %w", err)
+	}
+	if len(runs) == 0 {
+		return refreshStartupDelay, nil
+	}
+
+	next := time.Unix(int64(runs[0].StartedAt), 0).Add(r.interval())
+	delay := next.Sub(r.now())
+	if delay < refreshStartupDelay {
+		return refreshStartupDelay, nil
+	}
+	return delay, nil
+}
+
+// Refresh re-imports every linked anime once and records the run. A failure
+// for one anime is recorded in its change and does not stop the others.
+func (r *MetadataRefresher) Refresh(ctx context.Context) (RefreshRun, error) {
+	if !r.mutex.TryLock() {
+		return RefreshRun{}, ErrRefreshInProgress
+	}
+	defer r.mutex.Unlock()
+
+	animeList, err := r.dbClient.Anime().FindAllLinkedToMetadata(ctx)
+	if err != nil {
+		return RefreshRun{}, fmt.Errorf("Anime.FindAllLinkedToMetadata: %w", err)
+	}
+
+	startedAt := r.now()
+	row := db.MetadataRefreshRun{
+		StartedAt:  uint(startedAt.Unix()),
+		AnimeCount: len(animeList),
+	}
+	if err := r.dbClient.MetadataRefreshRun().Create(ctx, &row); err != nil {
+		return RefreshRun{}, fmt.Errorf("MetadataRefreshRun.Create: %w", err)
+	}
+	r.logger.InfoContext(ctx, "metadata refresh started", "runID", row.ID, "animeCount", len(animeList))
+
+	run := RefreshRun{
+		ID:         row.ID,
+		StartedAt:  startedAt,
+		AnimeCount: len(animeList),
+	}
+	for _, a := range animeList {
+		if ctx.Err() != nil {
+			r.logger.WarnContext(ctx, "metadata refresh cancelled", "runID", row.ID)
+			break
+		}
+
+		change, ok := r.refreshAnime(ctx, a)
+		if !ok {
+			continue
+		}
+		if change.Error != "" {
+			run.FailedCount++
+		}
+		if err := r.recordChange(ctx, row.ID, change); err != nil {
+			return RefreshRun{}, err
+		}
+		run.Changes = append(run.Changes, change)
+
+		if len(change.NewSeasons) > 0 && r.notifier != nil {
+			r.notifier.NotifyNewSeasons(ctx, NewSeasonsEvent{
+				AnimeID:   a.ID,
+				AnimeName: a.Name,
+				Seasons:   change.NewSeasons,
+			})
+		}
+	}
+
+	run.FinishedAt = r.now()
+	row.FinishedAt = uint(run.FinishedAt.Unix())
+	row.FailedCount = run.FailedCount
+	// The run is recorded even when cancelled so that the schedule advances.
+	if err := r.dbClient.MetadataRefreshRun().Update(context.WithoutCancel(ctx), &row); err != nil {
+		return RefreshRun{}, fmt.Errorf("MetadataRefreshRun.Update: %w", err)
+	}
+
+	r.logger.InfoContext(ctx, "metadata refresh completed",
+		"runID", row.ID,
+		"animeCount", run.AnimeCount,
+		"changed", len(run.Changes)-run.FailedCount,
+		"failed", run.FailedCount,
+	)
+	return run, nil
+}
+
+// refreshAnime re-imports one anime, reporting false when nothing changed.
+func (r *MetadataRefresher) refreshAnime(ctx context.Context, a db.Anime) (RefreshChange, bool) {
+	change := RefreshChange{AnimeID: a.ID, AnimeName: a.Name}
+
+	result, err := r.service.ImportFromMetadata(ctx, a.ID, *a.MetadataSeriesID)
+	if err != nil {
+		r.logger.WarnContext(ctx, "metadata refresh: import failed",
+			"animeID", a.ID, "seriesID", *a.MetadataSeriesID, "error", err,
+		)
+		change.Error = err.Error()
+		return change, true
+	}
+	change.MetadataImportResult = *result
+
+	if result.SeasonsCreated == 0 && result.SeasonsUpdated == 0 &&
+		result.CharactersCreated == 0 && result.CharactersUpdated == 0 {
+		return change, false
+	}
+	return change, true
+}
+
+func (r *MetadataRefresher) recordChange(ctx context.Context, runID uint, change RefreshChange) error {
+	row := db.MetadataRefreshChange{
+		RunID:             runID,
+		AnimeID:           change.AnimeID,
+		SeasonsCreated:    change.SeasonsCreated,
+		SeasonsUpdated:    change.SeasonsUpdated,
+		CharactersCreated: change.CharactersCreated,
+		CharactersUpdated: change.CharactersUpdated,
+		NewSeasons:        strings.Join(change.NewSeasons, "\n"),
+		Error:             change.Error,
+	}
+	if err := r.dbClient.MetadataRefreshChange().Create(ctx, &row); err != nil {
+		return fmt.Errorf("MetadataRefreshChange.Create for anime %d: %w", change.AnimeID, err)
+	}
+	return nil
+}
+
+// ReadRuns returns the latest runs with their change logs, newest first.
+func (r *MetadataRefresher) ReadRuns(ctx context.Context, limit int) ([]RefreshRun, error) {
+	rows, err := r.dbClient.MetadataRefreshRun().FindLatest(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("MetadataRefreshRun.FindLatest: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	runIDs := make([]uint, len(rows))
+	for i, row := range rows {
+		runIDs[i] = row.ID
+	}
+	changeRows, err := r.dbClient.MetadataRefreshChange().FindByRunIDs(ctx, runIDs)
+	if err != nil {
+		return nil, fmt.Errorf("MetadataRefreshChange.FindByRunIDs: %w", err)
+	}
+
+	animeIDs := make([]uint, 0, len(changeRows))
+	for _, changeRow := range changeRows {
+		animeIDs = append(animeIDs, changeRow.AnimeID)
+	}
+	animeList, err := r.dbClient.Anime().FindAllByIDs(animeIDs)
+	if err != nil {
+		return nil, fmt.Errorf("Anime.FindAllByIDs: %w", err)
+	}
+	animeNames := make(map[uint]string, len(animeList))
+	for _, a := range animeList {
+		animeNames[a.ID] = a.Name
+	}
+
+	changesByRunID := make(map[uint][]RefreshChange)
+	for _, changeRow := range changeRows {
+		var newSeasons []string
+		if changeRow.NewSeasons != "" {
+			newSeasons = strings.Split(changeRow.NewSeasons, "\n")
+		}
+		changesByRunID[changeRow.RunID] = append(changesByRunID[changeRow.RunID], RefreshChange{
+			AnimeID:   changeRow.AnimeID,
+			AnimeName: animeNames[changeRow.AnimeID],
+			MetadataImportResult: MetadataImportResult{
+				SeasonsCreated:    changeRow.SeasonsCreated,
+				SeasonsUpdated:    changeRow.SeasonsUpdated,
+				CharactersCreated: changeRow.CharactersCreated,
+				CharactersUpdated: changeRow.CharactersUpdated,
+				NewSeasons:        newSeasons,
+			},
+			Error: changeRow.Error,
+		})
+	}
+
+	runs := make([]RefreshRun, len(rows))
+	for i, row := range rows {
+		runs[i] = RefreshRun{
+			ID:          row.ID,
+			StartedAt:   time.Unix(int64(row.StartedAt), 0),
+			AnimeCount:  row.AnimeCount,
+			FailedCount: row.FailedCount,
+			Changes:     changesByRunID[row.ID],
+		}
+		if row.FinishedAt != 0 {
+			runs[i].FinishedAt = time.Unix(int64(row.FinishedAt), 0)
+		}
+	}
+	return runs, nil
+}
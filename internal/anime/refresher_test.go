@@ -0,0 +1,229 @@
+package anime
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/animemetadata"
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingNotifier struct {
+	events []NewSeasonsEvent
+}
+
+func (n *recordingNotifier) NotifyNewSeasons(_ context.Context, event NewSeasonsEvent) {
+	n.events = append(n.events, event)
+}
+
+func (te tester) refresher(client animemetadata.Client, notifier RefreshNotifier) *MetadataRefresher {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	conf := te.config
+	conf.MetadataRefresh = config.MetadataRefreshConfig{IntervalHours: 24}
+	return NewMetadataRefresher(logger, te.serviceWithMetadata(client), te.dbClient.Client, conf, notifier)
+}
+
+func TestMetadataRefresher_Refresh(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("imports new seasons of linked anime and records the change", func(t *testing.T) {
+		te := newTester(t)
+		te.dbClient.Truncate(t, db.MetadataRefreshRun{}, db.MetadataRefreshChange{})
+		mock := &mockMetadataClient{
+			series: map[string]*animemetadata.Series{
+				"bocchi": {
+					ID:      "bocchi",
+					Seasons: []animemetadata.Season{{ID: "bocchi-s1", Number: 1}},
+				},
+				"frieren": {
+					ID:      "frieren",
+					Seasons: []animemetadata.Season{{ID: "frieren-s1", Number: 1}},
+				},
+			},
+		}
+		service := te.serviceWithMetadata(mock)
+
+		bocchi, err := service.Create(ctx, "Bocchi")
+		require.NoError(t, err)
+		_, err = service.ImportFromMetadata(ctx, bocchi.ID, "bocchi")
+		require.NoError(t, err)
+		frieren, err := service.Create(ctx, "Frieren")
+		require.NoError(t, err)
+		_, err = service.ImportFromMetadata(ctx, frieren.ID, "frieren")
+		require.NoError(t, err)
+		_, err = service.Create(ctx, "Unlinked")
+		require.NoError(t, err)
+
+		// Upstream announces a second season for one of them.
+		mock.series["bocchi"].Seasons = append(mock.series["bocchi"].Seasons,
+			animemetadata.Season{ID: "bocchi-s2", Number: 2},
+		)
+		mock.getSeriesCalls = 0
+
+		notifier := &recordingNotifier{}
+		run, err := te.refresher(mock, notifier).Refresh(ctx)
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, mock.getSeriesCalls, "only linked anime are refreshed")
+		assert.Equal(t, 2, run.AnimeCount)
+		assert.Equal(t, 0, run.FailedCount)
+		require.Len(t, run.Changes, 1, "an unchanged anime is not logged")
+		assert.Equal(t, bocchi.ID, run.Changes[0].AnimeID)
+		assert.Equal(t, 1, run.Changes[0].SeasonsCreated)
+		assert.Equal(t, []string{"Season 2"}, run.Changes[0].NewSeasons)
+
+		assert.Equal(t, []NewSeasonsEvent{
+			{AnimeID: bocchi.ID, AnimeName: "Bocchi", Seasons: []string{"Season 2"}},
+		}, notifier.events)
+
+		runs, err := te.refresher(mock, nil).ReadRuns(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, runs, 1)
+		assert.Equal(t, run.ID, runs[0].ID)
+		assert.Equal(t, 2, runs[0].AnimeCount)
+		assert.False(t, runs[0].FinishedAt.IsZero())
+		require.Len(t, runs[0].Changes, 1)
+		assert.Equal(t, "Bocchi", runs[0].Changes[0].AnimeName)
+		assert.Equal(t, []string{"Season 2"}, runs[0].Changes[0].NewSeasons)
+	})
+
+	t.Run("keeps folders the user renamed", func(t *testing.T) {
+		te := newTester(t)
+		te.dbClient.Truncate(t, db.MetadataRefreshRun{}, db.MetadataRefreshChange{})
+		mock := &mockMetadataClient{
+			series: map[string]*animemetadata.Series{
+				"renamed": {
+					ID:         "renamed",
+					Seasons:    []animemetadata.Season{{ID: "renamed-s1", Number: 1, Title: "Upstream Title"}},
+					Characters: []animemetadata.Character{{ID: "hero", Name: "Hero"}},
+				},
+			},
+		}
+		service := te.serviceWithMetadata(mock)
+
+		anime, err := service.Create(ctx, "Renamed")
+		require.NoError(t, err)
+		_, err = service.ImportFromMetadata(ctx, anime.ID, "renamed")
+		require.NoError(t, err)
+
+		seasons, err := service.GetAnimeSeasons(anime.ID)
+		require.NoError(t, err)
+		require.Len(t, seasons, 1)
+		require.NoError(t, service.RenameSeason(ctx, seasons[0].ID, "My Name For It"))
+		characters, err := te.dbClient.Client.Character().FindByAnimeID(context.Background(), anime.ID)
+		require.NoError(t, err)
+		require.Len(t, characters, 1)
+		characters[0].Name = "My Hero"
+		require.NoError(t, te.dbClient.Client.Character().Update(ctx, &characters[0]))
+
+		mock.series["renamed"].Seasons[0].Title = "Retitled Upstream"
+		mock.series["renamed"].Characters[0].Name = "Renamed Hero"
+
+		_, err = te.refresher(mock, nil).Refresh(ctx)
+		require.NoError(t, err)
+
+		seasons, err = service.GetAnimeSeasons(anime.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"My Name For It"}, seasonNames(seasons))
+		characters, err = te.dbClient.Client.Character().FindByAnimeID(context.Background(), anime.ID)
+		require.NoError(t, err)
+		require.Len(t, characters, 1)
+		assert.Equal(t, "My Hero", characters[0].Name)
+	})
+
+	t.Run("records a failed anime and carries on", func(t *testing.T) {
+		te := newTester(t)
+		te.dbClient.Truncate(t, db.MetadataRefreshRun{}, db.MetadataRefreshChange{})
+		mock := &mockMetadataClient{
+			series: map[string]*animemetadata.Series{
+				"kept": {ID: "kept"},
+			},
+		}
+		service := te.serviceWithMetadata(mock)
+
+		gone, err := service.Create(ctx, "Gone Upstream")
+		require.NoError(t, err)
+		require.NoError(t, service.LinkMetadataSeries(ctx, gone.ID, "gone", 0))
+		kept, err := service.Create(ctx, "Kept")
+		require.NoError(t, err)
+		require.NoError(t, service.LinkMetadataSeries(ctx, kept.ID, "kept", 0))
+		mock.series["kept"].Seasons = []animemetadata.Season{{ID: "kept-s1", Number: 1}}
+
+		run, err := te.refresher(mock, nil).Refresh(ctx)
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, run.FailedCount)
+		require.Len(t, run.Changes, 2)
+		assert.Equal(t, gone.ID, run.Changes[0].AnimeID)
+		assert.Contains(t, run.Changes[0].Error, "not found")
+		assert.Equal(t, kept.ID, run.Changes[1].AnimeID)
+		assert.Equal(t, 1, run.Changes[1].SeasonsCreated)
+
+		runs, err := te.refresher(mock, nil).ReadRuns(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, runs, 1)
+		assert.Equal(t, 1, runs[0].FailedCount)
+	})
+
+	t.Run("rejects a concurrent refresh", func(t *testing.T) {
+		te := newTester(t)
+		refresher := te.refresher(&mockMetadataClient{}, nil)
+		refresher.mutex.Lock()
+		defer refresher.mutex.Unlock()
+
+		_, err := refresher.Refresh(ctx)
+		assert.ErrorIs(t, err, ErrRefreshInProgress)
+	})
+}
+
+func TestMetadataRefresher_NextRunDelay(t *testing.T) {
+	now := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name    string
+		lastRun *time.Time
+		want    time.Duration
+	}{
+		{
+			name: "never run waits for startup to settle",
+			want: refreshStartupDelay,
+		},
+		{
+			name:    "recent run waits for the rest of the interval",
+			lastRun: ptr(now.Add(-20 * time.Hour)),
+			want:    4 * time.Hour,
+		},
+		{
+			name:    "overdue run waits for startup to settle",
+			lastRun: ptr(now.Add(-48 * time.Hour)),
+			want:    refreshStartupDelay,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			te := newTester(t)
+			te.dbClient.Truncate(t, db.MetadataRefreshRun{})
+			if tc.lastRun != nil {
+				db.LoadTestData(t, te.dbClient, []db.MetadataRefreshRun{
+					{StartedAt: uint(tc.lastRun.Unix())},
+				})
+			}
+
+			refresher := te.refresher(&mockMetadataClient{}, nil)
+			refresher.now = func() time.Time { return now }
+
+			got, err := refresher.nextRunDelay(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
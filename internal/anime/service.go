@@ -13,6 +13,7 @@ import (
 	"github.com/michael-freling/anime-image-viewer/internal/animemetadata"
 	"github.com/michael-freling/anime-image-viewer/internal/config"
 	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/event"
 	"github.com/michael-freling/anime-image-viewer/internal/image"
 	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
 )
@@ -27,14 +28,16 @@ type Service struct {
 	directoryReader *image.DirectoryReader
 	config          config.Config
 	metadataClient  animemetadata.Client
+	eventBus        *event.Bus
 }
 
-func NewService(dbClient *db.Client, directoryReader *image.DirectoryReader, cfg config.Config, metadataClient animemetadata.Client) *Service {
+func NewService(dbClient *db.Client, directoryReader *image.DirectoryReader, cfg config.Config, metadataClient animemetadata.Client, eventBus *event.Bus) *Service {
 	return &Service{
 		dbClient:        dbClient,
 		directoryReader: directoryReader,
 		config:          cfg,
 		metadataClient:  metadataClient,
+		eventBus:        eventBus,
 	}
 }
 
@@ -89,6 +92,7 @@ func (s *Service) Create(ctx context.Context, name string) (Anime, error) {
 	if err != nil {
 		return Anime{}, err
 	}
+	s.eventBus.Publish(ctx, event.TypeAnimeCreated, event.Anime{ID: row.ID, Name: row.Name})
 	return Anime{ID: row.ID, Name: row.Name}, nil
 }
 
@@ -146,7 +150,11 @@ func (s *Service) Rename(ctx context.Context, id uint, name string) (Anime, erro
 		}
 		return nil
 	})
-	return Anime{ID: updated.ID, Name: updated.Name}, err
+	if err != nil {
+		return Anime{ID: updated.ID, Name: updated.Name}, err
+	}
+	s.eventBus.Publish(ctx, event.TypeAnimeRenamed, event.Anime{ID: updated.ID, Name: updated.Name})
+	return Anime{ID: updated.ID, Name: updated.Name}, nil
 }
 
 // Delete removes an anime, deletes its root folder from disk and from the DB
@@ -154,7 +162,7 @@ func (s *Service) Rename(ctx context.Context, id uint, name string) (Anime, erro
 // anime row itself.
 func (s *Service) Delete(ctx context.Context, id uint) error {
 	// verify exists for a clean error
-	_, err := s.dbClient.Anime().FindByValue(ctx, &db.Anime{ID: id})
+	row, err := s.dbClient.Anime().FindByValue(ctx, &db.Anime{ID: id})
 	if errors.Is(err, db.ErrRecordNotFound) {
 		return fmt.Errorf("%w: id %d", ErrAnimeNotFound, id)
 	}
@@ -203,6 +211,12 @@ func (s *Service) Delete(ctx context.Context, id uint) error {
 			if err := s.dbClient.FileCharacter().DeleteByFileIDs(ctx, allFileIDs); err != nil {
 				return fmt.Errorf("FileCharacter.DeleteByFileIDs: %w", err)
 			}
+			if err := s.dbClient.SuggestionReview().DeleteByFileIDs(ctx, allFileIDs); err != nil {
+				return fmt.Errorf("SuggestionReview.DeleteByFileIDs: %w", err)
+			}
+			if err := s.dbClient.AutoTaggedFile().DeleteByFileIDs(ctx, allFileIDs); err != nil {
+				return fmt.Errorf("AutoTaggedFile.DeleteByFileIDs: %w", err)
+			}
 			// Delete all file rows
 			if err := s.dbClient.File().DeleteByIDs(ctx, allFileIDs); err != nil {
 				return fmt.Errorf("File.DeleteByIDs: %w", err)
@@ -216,20 +230,16 @@ func (s *Service) Delete(ctx context.Context, id uint) error {
 			return fmt.Errorf("Tag.ClearAnimeIDByAnimeID: %w", err)
 		}
 
-		// Delete characters and their file associations for this anime.
-		animeChars, err := s.dbClient.Character().FindByAnimeID(id)
-		if err != nil {
-			return fmt.Errorf("Character.FindByAnimeID: %w", err)
+		// Delete the characters of this anime, keeping those another anime
+		// shares.
+		if err := s.removeAnimeFromCharacters(ctx, id); err != nil {
+			return err
 		}
-		if len(animeChars) > 0 {
-			for _, c := range animeChars {
-				if err := s.dbClient.FileCharacter().DeleteByCharacterID(ctx, c.ID); err != nil {
-					return fmt.Errorf("FileCharacter.DeleteByCharacterID: %w", err)
-				}
-			}
-			if err := s.dbClient.Character().DeleteByAnimeID(ctx, id); err != nil {
-				return fmt.Errorf("Character.DeleteByAnimeID: %w", err)
-			}
+
+		// Titles of the anime, its seasons and its characters all carry the
+		// anime id.
+		if err := s.dbClient.LocalizedTitle().DeleteByAnimeID(ctx, id); err != nil {
+			return fmt.Errorf("LocalizedTitle.DeleteByAnimeID: %w", err)
 		}
 
 		// Delete the anime row
@@ -244,6 +254,7 @@ func (s *Service) Delete(ctx context.Context, id uint) error {
 			}
 		}
 
+		s.eventBus.Publish(ctx, event.TypeAnimeDeleted, event.Anime{ID: row.ID, Name: row.Name})
 		return nil
 	})
 }
@@ -285,14 +296,18 @@ type DerivedCharacterCount struct {
 	CharacterID   uint   `json:"characterId"`
 	CharacterName string `json:"characterName"`
 	ImageCount    uint   `json:"imageCount"`
+	// AnimeIDs lists every anime the character belongs to, so a character
+	// shared with other anime can be shown as such.
+	AnimeIDs []uint `json:"animeIds"`
 }
 
 // DeriveCharactersForAnime computes the characters for an anime by finding all
 // image files in its folder tree, looking up which characters are applied to
 // those images via the file_characters table, and returning unique characters
-// with counts. It also includes characters that have no images yet (count 0).
-func (s *Service) DeriveCharactersForAnime(animeID uint) ([]DerivedCharacterCount, error) {
-	characters, err := s.dbClient.Character().FindByAnimeID(animeID)
+// with counts. It also includes characters that have no images yet (count 0)
+// and characters shared with this anime by another one.
+func (s *Service) DeriveCharactersForAnime(ctx context.Context, animeID uint) ([]DerivedCharacterCount, error) {
+	characters, err := s.dbClient.Character().FindByAnimeID(ctx, animeID)
 	if err != nil {
 		return nil, fmt.Errorf("Character.FindByAnimeID: %w", err)
 	}
@@ -325,12 +340,19 @@ func (s *Service) DeriveCharactersForAnime(animeID uint) ([]DerivedCharacterCoun
 		}
 	}
 
+	// Counts only cover this anime's images, also for a shared character.
+	animeIDs, err := s.dbClient.AnimeCharacter().FindAnimeIDsByCharacters(ctx, characters)
+	if err != nil {
+		return nil, fmt.Errorf("AnimeCharacter.FindAnimeIDsByCharacters: %w", err)
+	}
+
 	result := make([]DerivedCharacterCount, 0, len(characters))
 	for _, c := range characters {
 		result = append(result, DerivedCharacterCount{
 			CharacterID:   c.ID,
 			CharacterName: c.Name,
 			ImageCount:    charCounts[c.ID],
+			AnimeIDs:      animeIDs[c.ID],
 		})
 	}
 	return result, nil
@@ -486,7 +508,11 @@ func (s *Service) AssignFolder(ctx context.Context, animeID, folderID uint) erro
 	}
 
 	id := animeID
-	return s.dbClient.File().SetAnimeID(ctx, folderID, &id)
+	if err := s.dbClient.File().SetAnimeID(ctx, folderID, &id); err != nil {
+		return err
+	}
+	s.eventBus.Publish(ctx, event.TypeAnimeFolderAssigned, event.AnimeFolder{AnimeID: animeID, FolderID: folderID})
+	return nil
 }
 
 // UnassignFolder clears anime_id on the given folder. The folder must
@@ -506,7 +532,11 @@ func (s *Service) UnassignFolder(ctx context.Context, folderID uint) error {
 	if row.AnimeID == nil {
 		return nil
 	}
-	return s.dbClient.File().SetAnimeID(ctx, folderID, nil)
+	if err := s.dbClient.File().SetAnimeID(ctx, folderID, nil); err != nil {
+		return err
+	}
+	s.eventBus.Publish(ctx, event.TypeAnimeFolderUnassigned, event.AnimeFolder{AnimeID: *row.AnimeID, FolderID: folderID})
+	return nil
 }
 
 // findAncestorAnimeID walks up the folder hierarchy of the given folder
@@ -1307,9 +1337,18 @@ func (s *Service) DeleteSeason(ctx context.Context, seasonID uint) error {
 			if err := s.dbClient.FileCharacter().DeleteByFileIDs(ctx, allFileIDs); err != nil {
 				return fmt.Errorf("FileCharacter.DeleteByFileIDs: %w", err)
 			}
+			if err := s.dbClient.SuggestionReview().DeleteByFileIDs(ctx, allFileIDs); err != nil {
+				return fmt.Errorf("SuggestionReview.DeleteByFileIDs: %w", err)
+			}
+			if err := s.dbClient.AutoTaggedFile().DeleteByFileIDs(ctx, allFileIDs); err != nil {
+				return fmt.Errorf("AutoTaggedFile.DeleteByFileIDs: %w", err)
+			}
 			if err := s.dbClient.File().DeleteByIDs(ctx, allFileIDs); err != nil {
 				return fmt.Errorf("File.DeleteByIDs: %w", err)
 			}
+			if err := s.dbClient.LocalizedTitle().DeleteByEntityIDs(ctx, db.LocalizedTitleEntityFile, allFileIDs); err != nil {
+				return fmt.Errorf("LocalizedTitle.DeleteByEntityIDs: %w", err)
+			}
 		}
 		if err := os.RemoveAll(diskPath); err != nil && !os.IsNotExist(err) {
 			return fmt.Errorf("os.RemoveAll: %w", err)
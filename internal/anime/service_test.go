@@ -3,11 +3,15 @@ package anime
 import (
 	"context"
 	"errors"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/event"
 	"github.com/michael-freling/anime-image-viewer/internal/image"
 	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
 	"github.com/stretchr/testify/assert"
@@ -299,7 +303,7 @@ func TestService_Delete(t *testing.T) {
 		require.NoError(t, svc.Delete(ctx, a.ID))
 
 		// Verify characters are gone
-		chars, err := te.dbClient.Character().FindByAnimeID(a.ID)
+		chars, err := te.dbClient.Character().FindByAnimeID(context.Background(), a.ID)
 		require.NoError(t, err)
 		assert.Empty(t, chars)
 
@@ -2078,7 +2082,7 @@ func TestService_DeriveCharactersForAnime(t *testing.T) {
 	}
 	require.NoError(t, db.BatchCreate(te.dbClient.Client, fileChars))
 
-	derived, err := svc.DeriveCharactersForAnime(a.ID)
+	derived, err := svc.DeriveCharactersForAnime(context.Background(), a.ID)
 	require.NoError(t, err)
 	require.Len(t, derived, 2)
 
@@ -2095,7 +2099,7 @@ func TestService_DeriveCharactersForAnime(t *testing.T) {
 	t.Run("returns nil for anime with no characters", func(t *testing.T) {
 		noChars, err := svc.Create(ctx, "NoCharsAnime")
 		require.NoError(t, err)
-		derived, err := svc.DeriveCharactersForAnime(noChars.ID)
+		derived, err := svc.DeriveCharactersForAnime(context.Background(), noChars.ID)
 		require.NoError(t, err)
 		assert.Nil(t, derived)
 	})
@@ -2107,10 +2111,62 @@ func TestService_DeriveCharactersForAnime(t *testing.T) {
 		zeroChar := db.Character{Name: "Ryo", AnimeID: zeroAnime.ID}
 		require.NoError(t, te.dbClient.Character().Create(ctx, &zeroChar))
 
-		derived, err := svc.DeriveCharactersForAnime(zeroAnime.ID)
+		derived, err := svc.DeriveCharactersForAnime(context.Background(), zeroAnime.ID)
 		require.NoError(t, err)
 		require.Len(t, derived, 1)
 		assert.Equal(t, "Ryo", derived[0].CharacterName)
 		assert.Equal(t, uint(0), derived[0].ImageCount)
 	})
 }
+
+func TestService_Events(t *testing.T) {
+	te := newTester(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventBus := event.NewBus(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	events := make(chan event.Event, 10)
+	eventBus.Subscribe("test", nil, func(ctx context.Context, e event.Event) error {
+		events <- e
+		return nil
+	})
+	eventBus.Start(ctx)
+	svc := NewService(te.dbClient.Client, te.directoryReader(), te.config, nil, eventBus)
+
+	a, err := svc.Create(ctx, "Original")
+	require.NoError(t, err)
+	_, err = svc.Rename(ctx, a.ID, "Renamed")
+	require.NoError(t, err)
+	// a failed change isn't published
+	_, err = svc.Rename(ctx, a.ID+100, "Not found")
+	require.ErrorIs(t, err, ErrAnimeNotFound)
+	te.dbClient.Truncate(t, db.File{})
+	db.LoadTestData(t, te.dbClient, []db.File{
+		{ID: 10, Name: "folder", ParentID: db.RootDirectoryID, Type: db.FileTypeDirectory},
+	})
+	require.NoError(t, svc.AssignFolder(ctx, a.ID, 10))
+	require.NoError(t, svc.UnassignFolder(ctx, 10))
+	require.NoError(t, svc.Delete(ctx, a.ID))
+
+	want := []event.Event{
+		{Type: event.TypeAnimeCreated, Data: event.Anime{ID: a.ID, Name: "Original"}},
+		{Type: event.TypeAnimeRenamed, Data: event.Anime{ID: a.ID, Name: "Renamed"}},
+		{Type: event.TypeAnimeFolderAssigned, Data: event.AnimeFolder{AnimeID: a.ID, FolderID: 10}},
+		{Type: event.TypeAnimeFolderUnassigned, Data: event.AnimeFolder{AnimeID: a.ID, FolderID: 10}},
+		{Type: event.TypeAnimeDeleted, Data: event.Anime{ID: a.ID, Name: "Renamed"}},
+	}
+	for _, wantEvent := range want {
+		select {
+		case got := <-events:
+			assert.Equal(t, wantEvent.Type, got.Type)
+			assert.Equal(t, wantEvent.Data, got.Data)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s is not published", wantEvent.Type)
+		}
+	}
+	select {
+	case got := <-events:
+		t.Fatalf("unexpected event: %v", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -32,6 +32,9 @@ const defaultTimeout = 15 * time.Second
 // defaultSearchLimit caps search results when the caller does not specify one.
 const defaultSearchLimit = 10
 
+// defaultLanguage is requested when no languages are configured.
+const defaultLanguage = "en"
+
 // ErrNotFound is returned when the API reports that an id is not in the
 // dataset (Connect code "not_found").
 var ErrNotFound = errors.New("animemetadata: not found")
@@ -40,27 +43,72 @@ var ErrNotFound = errors.New("animemetadata: not found")
 // service testable without network access.
 type Client interface {
 	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
+	// GetSeries resolves names for the client's preferred languages, falling
+	// back in order.
 	GetSeries(ctx context.Context, id string) (*Series, error)
+	// GetSeriesInLanguage resolves names for exactly one language, which is
+	// how the titles of every configured language are collected.
+	GetSeriesInLanguage(ctx context.Context, id string, language string) (*Series, error)
 }
 
 // HTTPClient is the production implementation.
 type HTTPClient struct {
 	endpoint   string
-	language   string
+	languages  []string
 	httpClient *http.Client
 }
 
+type HTTPClientOption func(*HTTPClient)
+
+// WithLanguages sets the preferred languages as BCP 47 tags, most preferred
+// first. An empty list keeps the default of English.
+func WithLanguages(languages []string) HTTPClientOption {
+	return func(c *HTTPClient) {
+		trimmed := make([]string, 0, len(languages))
+		for _, language := range languages {
+			if language = strings.TrimSpace(language); language != "" {
+				trimmed = append(trimmed, language)
+			}
+		}
+		if len(trimmed) > 0 {
+			c.languages = trimmed
+		}
+	}
+}
+
 // NewHTTPClient creates a client for the given endpoint. An empty endpoint
 // falls back to DefaultEndpoint.
-func NewHTTPClient(endpoint string) *HTTPClient {
+func NewHTTPClient(endpoint string, opts ...HTTPClientOption) *HTTPClient {
 	if strings.TrimSpace(endpoint) == "" {
 		endpoint = DefaultEndpoint
 	}
-	return &HTTPClient{
+	client := &HTTPClient{
 		endpoint:   strings.TrimRight(strings.TrimSpace(endpoint), "/"),
-		language:   "en",
+		languages:  []string{defaultLanguage},
 		httpClient: &http.Client{Timeout: defaultTimeout},
 	}
+	for _, opt := range opts {
+		opt(client)
+	}
+	return client
+}
+
+// acceptLanguage formats languages as an Accept-Language header value, giving
+// each a lower quality than the one before it.
+func acceptLanguage(languages []string) string {
+	values := make([]string, len(languages))
+	for i, language := range languages {
+		if i == 0 {
+			values[i] = language
+			continue
+		}
+		quality := 1.0 - float64(i)*0.1
+		if quality < 0.1 {
+			quality = 0.1
+		}
+		values[i] = fmt.Sprintf("%s;q=%.1f", language, quality)
+	}
+	return strings.Join(values, ", ")
 }
 
 // connectError is the JSON body Connect returns for a failed RPC.
@@ -94,7 +142,7 @@ func (c *HTTPClient) Search(ctx context.Context, query string, limit int) ([]Sea
 		limit = defaultSearchLimit
 	}
 	var resp searchResponse
-	if err := c.call(ctx, "Search", searchRequest{Query: query, Limit: limit}, &resp); err != nil {
+	if err := c.call(ctx, "Search", acceptLanguage(c.languages), searchRequest{Query: query, Limit: limit}, &resp); err != nil {
 		return nil, err
 	}
 	return resp.Results, nil
@@ -103,18 +151,30 @@ func (c *HTTPClient) Search(ctx context.Context, query string, limit int) ([]Sea
 // GetSeries returns one series by id, with its seasons, movies, specials and
 // cast. It returns ErrNotFound when the id is not in the dataset.
 func (c *HTTPClient) GetSeries(ctx context.Context, id string) (*Series, error) {
+	return c.getSeries(ctx, id, acceptLanguage(c.languages))
+}
+
+// GetSeriesInLanguage is GetSeries with names resolved for one language only.
+func (c *HTTPClient) GetSeriesInLanguage(ctx context.Context, id string, language string) (*Series, error) {
+	return c.getSeries(ctx, id, language)
+}
+
+func (c *HTTPClient) getSeries(ctx context.Context, id string, language string) (*Series, error) {
 	var resp getSeriesResponse
-	if err := c.call(ctx, "GetSeries", getSeriesRequest{ID: id}, &resp); err != nil {
+	if err := c.call(ctx, "GetSeries", language, getSeriesRequest{ID: id}, &resp); err != nil {
 		return nil, err
 	}
 	if resp.Series == nil {
 		return nil, fmt.Errorf("%w: series %q", ErrNotFound, id)
 	}
+	if resp.FranchiseID != "" {
+		resp.Series.FranchiseID = resp.FranchiseID
+	}
 	return resp.Series, nil
 }
 
 // call performs one Connect RPC and decodes the response into out.
-func (c *HTTPClient) call(ctx context.Context, method string, reqBody any, out any) error {
+func (c *HTTPClient) call(ctx context.Context, method string, language string, reqBody any, out any) error {
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
 		return fmt.Errorf("animemetadata: failed to marshal %s request: %w", method, err)
@@ -126,7 +186,7 @@ func (c *HTTPClient) call(ctx context.Context, method string, reqBody any, out a
 		return fmt.Errorf("animemetadata: failed to create %s request: %w", method, err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept-Language", c.language)
+	req.Header.Set("Accept-Language", language)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
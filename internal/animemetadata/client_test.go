@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -160,6 +161,7 @@ func TestHTTPClient_GetSeries(t *testing.T) {
 
 		assert.Equal(t, "fate-zero", series.ID)
 		assert.Equal(t, "Fate/Zero", series.Title)
+		assert.Equal(t, "fate", series.FranchiseID)
 
 		require.Len(t, series.Seasons, 2)
 		require.NotNil(t, series.Seasons[0].Part)
@@ -308,3 +310,58 @@ func TestHTTPClient_Errors(t *testing.T) {
 		assert.True(t, errors.Is(err, context.Canceled))
 	})
 }
+
+func TestHTTPClient_Languages(t *testing.T) {
+	var gotLanguages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotLanguages = append(gotLanguages, r.Header.Get("Accept-Language"))
+		_, _ = w.Write([]byte(`{"series":{"id":"x"}}`))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	testCases := []struct {
+		name      string
+		languages []string
+		want      string
+	}{
+		{
+			name: "defaults to English",
+			want: "en",
+		},
+		{
+			name:      "blank entries are dropped",
+			languages: []string{" ", ""},
+			want:      "en",
+		},
+		{
+			name:      "later languages get a lower quality",
+			languages: []string{"ja", " en ", "ja-Latn"},
+			want:      "ja, en;q=0.9, ja-Latn;q=0.8",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotLanguages = nil
+			_, err := NewHTTPClient(server.URL, WithLanguages(tc.languages)).GetSeries(ctx, "x")
+			require.NoError(t, err)
+			assert.Equal(t, []string{tc.want}, gotLanguages)
+		})
+	}
+
+	t.Run("GetSeriesInLanguage requests exactly one language", func(t *testing.T) {
+		gotLanguages = nil
+		client := NewHTTPClient(server.URL, WithLanguages([]string{"en", "ja"}))
+		_, err := client.GetSeriesInLanguage(ctx, "x", "ja-Latn")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"ja-Latn"}, gotLanguages)
+	})
+}
+
+func TestAcceptLanguage(t *testing.T) {
+	languages := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"}
+	got := acceptLanguage(languages)
+	assert.True(t, strings.HasSuffix(got, "k;q=0.1"), got)
+	assert.Contains(t, got, "j;q=0.1")
+}
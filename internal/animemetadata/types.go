@@ -119,6 +119,10 @@ type Series struct {
 	Movies     []Movie     `json:"movies"`
 	Specials   []Special   `json:"specials"`
 	Characters []Character `json:"characters"`
+	// FranchiseID is the franchise the series belongs to, or "" for a
+	// standalone series. The API returns it beside the series rather than in
+	// it; GetSeries copies it over.
+	FranchiseID string `json:"franchiseId"`
 }
 
 // SearchResult is one match: a top-level franchise or a series. FranchiseID is
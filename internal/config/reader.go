@@ -11,11 +11,19 @@ import (
 
 // writableConfig contains only user-editable fields (excludes Environment).
 type writableConfig struct {
-	ImageRootDirectory       string       `toml:"image_root_directory"`
-	ConfigDirectory          string       `toml:"config_directory"`
-	LogDirectory             string       `toml:"log_directory"`
-	AnimeMetadataAPIEndpoint string       `toml:"anime_metadata_api_endpoint"`
-	Backup                   BackupConfig `toml:"backup"`
+	ImageRootDirectory       string                `toml:"image_root_directory"`
+	ConfigDirectory          string                `toml:"config_directory"`
+	LogDirectory             string                `toml:"log_directory"`
+	AnimeMetadataAPIEndpoint string                `toml:"anime_metadata_api_endpoint"`
+	MetadataLanguages        []string              `toml:"metadata_languages"`
+	Backup                   BackupConfig          `toml:"backup"`
+	MetadataRefresh          MetadataRefreshConfig `toml:"metadata_refresh"`
+	History                  HistoryConfig         `toml:"history"`
+	XMP                      XMPConfig             `toml:"xmp"`
+	TagSuggestion            TagSuggestionConfig   `toml:"tag_suggestion"`
+	Analysis                 AnalysisConfig        `toml:"analysis"`
+	Plugins                  []PluginConfig        `toml:"plugins"`
+	Hooks                    HooksConfig           `toml:"hooks"`
 }
 
 type env string
@@ -33,6 +41,149 @@ type BackupConfig struct {
 	IdleMinutes             int    `toml:"idle_minutes"`
 }
 
+// MetadataRefreshConfig controls the background job that re-imports every
+// anime linked to the anime metadata database. It is opt-out rather than
+// opt-in so that existing config files, which have no [metadata_refresh]
+// table, pick up new seasons without any change.
+type MetadataRefreshConfig struct {
+	Disabled      bool `toml:"disabled"`
+	IntervalHours int  `toml:"interval_hours"`
+}
+
+// HistoryConfig controls the operation history of tag, character and file
+// edits. UserName is recorded as the author of each operation so that a
+// library shared between several people shows who changed what; it defaults
+// to the name of the OS user.
+type HistoryConfig struct {
+	UserName string `toml:"user_name"`
+	// UndoLimit is how many of the latest operations can be undone. Older
+	// operations stay in the history but can no longer be reverted.
+	UndoLimit int `toml:"undo_limit"`
+}
+
+// XMPConfig controls the XMP sidecars written next to images for DigiKam
+// and other tools.
+type XMPConfig struct {
+	// SyncOnTagEdit writes the sidecars of images whenever their tags or
+	// characters are edited, undone or redone, or they are imported, and
+	// deletes the sidecars of deleted images. Otherwise sidecars are only
+	// written by `aivcli xmp sync`.
+	SyncOnTagEdit bool `toml:"sync_on_tag_edit"`
+}
+
+// TagSuggestionProtocol is the version of the gRPC API of a tag suggestion
+// plugin
+type TagSuggestionProtocol string
+
+const (
+	// TagSuggestionProtocolV1 is tag_suggestion.v1, which is sent the paths
+	// of images
+	TagSuggestionProtocolV1 TagSuggestionProtocol = "v1"
+	// TagSuggestionProtocolV2 is tag_suggestion.v2, which is sent the
+	// contents of images
+	TagSuggestionProtocolV2 TagSuggestionProtocol = "v2"
+)
+
+// TagSuggestionConfig connects the app to a tag suggestion plugin of
+// tag_suggestion.v2, or of v1 by Protocol. Suggestions are disabled if both
+// Plugin and Address are empty.
+type TagSuggestionConfig struct {
+	// Protocol is the API of the plugin, v1 or v2. It defaults to v2.
+	Protocol TagSuggestionProtocol `toml:"protocol"`
+	// Plugin is the name of a managed plugin in Plugins, which is started
+	// when tags are suggested first. It takes precedence over Address.
+	Plugin string `toml:"plugin"`
+	// Address is the gRPC address of a plugin which is started by hand, like
+	// localhost:50051
+	Address string `toml:"address"`
+	// ThumbnailWidth resizes images wider than it before they are sent to
+	// a plugin of v2. 0 sends the original files.
+	ThumbnailWidth uint              `toml:"thumbnail_width"`
+	AutoTagging    AutoTaggingConfig `toml:"auto_tagging"`
+}
+
+// AutoTaggingConfig controls the background job that suggests tags for
+// untagged and newly imported images. A tag whose score is at least its
+// threshold is added to an image, and one whose score is at least
+// ReviewThreshold is queued for a user to review.
+type AutoTaggingConfig struct {
+	Enabled         bool `toml:"enabled"`
+	IntervalMinutes int  `toml:"interval_minutes"`
+	// BatchSize is how many images are sent to the plugin at once
+	BatchSize int `toml:"batch_size"`
+	// Threshold is the score to add a tag without a review
+	Threshold float64 `toml:"threshold"`
+	// TagThresholds override Threshold for tags by their names
+	TagThresholds   map[string]float64 `toml:"tag_thresholds"`
+	ReviewThreshold float64            `toml:"review_threshold"`
+}
+
+// AnalysisConfig lists the image analysis plugins of plugins.v1, like OCR,
+// upscaling or face detection. An image is analyzed by the first plugin
+// capable of what is requested, in the order of Plugins.
+type AnalysisConfig struct {
+	// Plugins are the names of managed plugins in Plugins
+	Plugins []string `toml:"plugins"`
+}
+
+// PluginConfig is a plugin process which the app starts on demand, restarts
+// when it crashes, and stops when the app shuts down. The plugin listens on
+// either localhost:Port or the unix socket Socket, and serves the standard
+// gRPC health service.
+type PluginConfig struct {
+	Name    string `toml:"name"`
+	Command string `toml:"command"`
+	// Args are the arguments of Command. {address} in them is replaced with
+	// the address the plugin must listen on, like localhost:50051 or
+	// unix:/tmp/plugin.sock
+	Args []string `toml:"args"`
+	// Env is added to the environment of the app for the plugin
+	Env map[string]string `toml:"env"`
+	// Directory is the working directory of the plugin. It defaults to the
+	// one of the app.
+	Directory string `toml:"directory"`
+	Port      int    `toml:"port"`
+	Socket    string `toml:"socket"`
+	// StartTimeoutSeconds is how long the plugin can take to become healthy,
+	// e.g. while it loads a model
+	StartTimeoutSeconds int `toml:"start_timeout_seconds"`
+}
+
+// HooksConfig notifies programs outside of the app, like a wallpaper rotator
+// or a chat bot, when images are imported, tagged or deleted, or anime are
+// changed. Events are the names of the events a hook receives, like
+// images.imported, and a hook receives every event if they are empty.
+type HooksConfig struct {
+	Webhooks []WebhookConfig  `toml:"webhooks"`
+	Exec     []ExecHookConfig `toml:"exec"`
+}
+
+// WebhookConfig posts each event as JSON to URL. A failed request is retried
+// with a backoff.
+type WebhookConfig struct {
+	URL string `toml:"url"`
+	// Secret signs the body with HMAC-SHA256, so that the receiver can verify
+	// the request came from the app. Requests aren't signed if it's empty.
+	Secret string   `toml:"secret"`
+	Events []string `toml:"events"`
+	// MaxRetries is how many times a failed request is retried. A negative
+	// value doesn't retry.
+	MaxRetries     int `toml:"max_retries"`
+	TimeoutSeconds int `toml:"timeout_seconds"`
+}
+
+// ExecHookConfig runs a command for each event, with the event as JSON on its
+// standard input.
+type ExecHookConfig struct {
+	Command string   `toml:"command"`
+	Args    []string `toml:"args"`
+	// Directory is the working directory of the command. It defaults to the
+	// one of the app.
+	Directory      string   `toml:"directory"`
+	Events         []string `toml:"events"`
+	TimeoutSeconds int      `toml:"timeout_seconds"`
+}
+
 type Config struct {
 	ImageRootDirectory string `toml:"image_root_directory"`
 	ConfigDirectory    string `toml:"config_directory"`
@@ -40,9 +191,20 @@ type Config struct {
 	// AnimeMetadataAPIEndpoint overrides the anime metadata database the app
 	// reads from, e.g. a locally running `go run ./cmd/api`. Empty uses
 	// animemetadata.DefaultEndpoint.
-	AnimeMetadataAPIEndpoint string       `toml:"anime_metadata_api_endpoint"`
-	Backup                   BackupConfig `toml:"backup"`
-	Environment              env
+	AnimeMetadataAPIEndpoint string `toml:"anime_metadata_api_endpoint"`
+	// MetadataLanguages are the languages titles are imported in, as BCP 47
+	// tags, most preferred first. The first one names the folders on disk;
+	// the rest are stored alongside so they can be searched and displayed.
+	MetadataLanguages []string              `toml:"metadata_languages"`
+	Backup            BackupConfig          `toml:"backup"`
+	MetadataRefresh   MetadataRefreshConfig `toml:"metadata_refresh"`
+	History           HistoryConfig         `toml:"history"`
+	XMP               XMPConfig             `toml:"xmp"`
+	TagSuggestion     TagSuggestionConfig   `toml:"tag_suggestion"`
+	Analysis          AnalysisConfig        `toml:"analysis"`
+	Plugins           []PluginConfig        `toml:"plugins"`
+	Hooks             HooksConfig           `toml:"hooks"`
+	Environment       env
 }
 
 // WriteConfig writes the config to a TOML file.
@@ -71,7 +233,15 @@ func WriteConfig(configFile string, conf Config) error {
 		ConfigDirectory:          conf.ConfigDirectory,
 		LogDirectory:             conf.LogDirectory,
 		AnimeMetadataAPIEndpoint: conf.AnimeMetadataAPIEndpoint,
+		MetadataLanguages:        conf.MetadataLanguages,
 		Backup:                   conf.Backup,
+		MetadataRefresh:          conf.MetadataRefresh,
+		History:                  conf.History,
+		XMP:                      conf.XMP,
+		TagSuggestion:            conf.TagSuggestion,
+		Analysis:                 conf.Analysis,
+		Plugins:                  conf.Plugins,
+		Hooks:                    conf.Hooks,
 	}
 	encoder := toml.NewEncoder(file)
 	if err := encoder.Encode(writable); err != nil {
@@ -111,12 +281,22 @@ func ReadConfig(configFile string) (Config, error) {
 			return Config{}, fmt.Errorf("config file %s does not exist", configFile)
 		}
 
-		var conf Config
+		conf := Config{
+			TagSuggestion: defaultTagSuggestionConfig(),
+		}
 		if _, err := decodeConfigFile(configFile, &conf); err != nil {
 			return conf, err
 		}
 		conf.Environment = runtimeEnv
 		applyBackupDefaults(&conf)
+		applyMetadataDefaults(&conf)
+		applyHistoryDefaults(&conf)
+		applyTagSuggestionDefaults(&conf)
+		applyPluginDefaults(&conf)
+		applyHookDefaults(&conf)
+		if err := validateTagSuggestionConfig(conf.TagSuggestion); err != nil {
+			return conf, err
+		}
 		return conf, nil
 	}
 
@@ -140,6 +320,7 @@ func ReadConfig(configFile string) (Config, error) {
 		ImageRootDirectory: defaultImageRootDirectory(homeDir),
 		ConfigDirectory:    configDir,
 		LogDirectory:       filepath.Join(tempDir, "anime-image-viewer", "logs"),
+		TagSuggestion:      defaultTagSuggestionConfig(),
 		Environment:        runtimeEnv,
 	}
 
@@ -177,6 +358,14 @@ func ReadConfig(configFile string) (Config, error) {
 	} else {
 		applyBackupDefaults(&conf)
 	}
+	applyMetadataDefaults(&conf)
+	applyHistoryDefaults(&conf)
+	applyTagSuggestionDefaults(&conf)
+	applyPluginDefaults(&conf)
+	applyHookDefaults(&conf)
+	if err := validateTagSuggestionConfig(conf.TagSuggestion); err != nil {
+		return conf, err
+	}
 
 	conf.Environment = runtimeEnv
 	return conf, nil
@@ -195,6 +384,10 @@ func DefaultConfig() (Config, error) {
 		ConfigDirectory:    configDir,
 		LogDirectory:       filepath.Join(tempDir, "anime-image-viewer", "logs"),
 		Backup:             defaultBackupConfig(configDir),
+		MetadataLanguages:  defaultMetadataLanguages(),
+		MetadataRefresh:    defaultMetadataRefreshConfig(),
+		History:            defaultHistoryConfig(),
+		TagSuggestion:      defaultTagSuggestionConfig(),
 		Environment:        runtimeEnv,
 	}, nil
 }
@@ -234,3 +427,115 @@ func applyBackupDefaults(conf *Config) {
 	// (used when no config file exists). When a config file is present, the decoded
 	// value is kept as-is.
 }
+
+func defaultMetadataRefreshConfig() MetadataRefreshConfig {
+	return MetadataRefreshConfig{
+		IntervalHours: 24,
+	}
+}
+
+// defaultMetadataLanguages keeps English first so that folders are named as
+// they were before the setting existed, and adds Japanese and its romanization.
+func defaultMetadataLanguages() []string {
+	return []string{"en", "ja", "ja-Latn"}
+}
+
+func applyMetadataDefaults(conf *Config) {
+	if len(conf.MetadataLanguages) == 0 {
+		conf.MetadataLanguages = defaultMetadataLanguages()
+	}
+	if conf.MetadataRefresh.IntervalHours <= 0 {
+		conf.MetadataRefresh.IntervalHours = defaultMetadataRefreshConfig().IntervalHours
+	}
+}
+
+func defaultHistoryConfig() HistoryConfig {
+	return HistoryConfig{
+		UndoLimit: 50,
+	}
+}
+
+func applyHistoryDefaults(conf *Config) {
+	if conf.History.UndoLimit <= 0 {
+		conf.History.UndoLimit = defaultHistoryConfig().UndoLimit
+	}
+}
+
+func defaultTagSuggestionConfig() TagSuggestionConfig {
+	return TagSuggestionConfig{
+		Protocol: TagSuggestionProtocolV2,
+		AutoTagging: AutoTaggingConfig{
+			IntervalMinutes: 10,
+			BatchSize:       32,
+			Threshold:       0.9,
+			ReviewThreshold: 0.5,
+		},
+	}
+}
+
+func applyTagSuggestionDefaults(conf *Config) {
+	if conf.TagSuggestion.Protocol == "" {
+		conf.TagSuggestion.Protocol = defaultTagSuggestionConfig().Protocol
+	}
+	defaults := defaultTagSuggestionConfig().AutoTagging
+	autoTagging := &conf.TagSuggestion.AutoTagging
+	if autoTagging.IntervalMinutes <= 0 {
+		autoTagging.IntervalMinutes = defaults.IntervalMinutes
+	}
+	if autoTagging.BatchSize <= 0 {
+		autoTagging.BatchSize = defaults.BatchSize
+	}
+	if autoTagging.Threshold <= 0 {
+		autoTagging.Threshold = defaults.Threshold
+	}
+	// ReviewThreshold is decoded over its default, so that an explicit 0,
+	// which queues every suggestion below Threshold for a review, is kept.
+}
+
+func validateTagSuggestionConfig(conf TagSuggestionConfig) error {
+	if conf.Protocol != TagSuggestionProtocolV1 && conf.Protocol != TagSuggestionProtocolV2 {
+		return fmt.Errorf("tag_suggestion.protocol must be v1 or v2: %s", conf.Protocol)
+	}
+	autoTagging := conf.AutoTagging
+	if autoTagging.ReviewThreshold < 0 {
+		return fmt.Errorf("tag_suggestion.auto_tagging.review_threshold must not be negative: %v", autoTagging.ReviewThreshold)
+	}
+	if autoTagging.Threshold < autoTagging.ReviewThreshold {
+		return fmt.Errorf("tag_suggestion.auto_tagging.threshold %v must not be lower than review_threshold %v",
+			autoTagging.Threshold, autoTagging.ReviewThreshold)
+	}
+	return nil
+}
+
+const defaultPluginStartTimeoutSeconds = 120
+
+func applyPluginDefaults(conf *Config) {
+	for index := range conf.Plugins {
+		if conf.Plugins[index].StartTimeoutSeconds <= 0 {
+			conf.Plugins[index].StartTimeoutSeconds = defaultPluginStartTimeoutSeconds
+		}
+	}
+}
+
+const (
+	defaultWebhookMaxRetries      = 5
+	defaultWebhookTimeoutSeconds  = 10
+	defaultExecHookTimeoutSeconds = 60
+)
+
+func applyHookDefaults(conf *Config) {
+	for index := range conf.Hooks.Webhooks {
+		webhook := &conf.Hooks.Webhooks[index]
+		if webhook.MaxRetries == 0 {
+			webhook.MaxRetries = defaultWebhookMaxRetries
+		}
+		if webhook.TimeoutSeconds <= 0 {
+			webhook.TimeoutSeconds = defaultWebhookTimeoutSeconds
+		}
+	}
+	for index := range conf.Hooks.Exec {
+		if conf.Hooks.Exec[index].TimeoutSeconds <= 0 {
+			conf.Hooks.Exec[index].TimeoutSeconds = defaultExecHookTimeoutSeconds
+		}
+	}
+}
@@ -496,3 +496,334 @@ idle_minutes = 60
 	assert.False(t, conf.Backup.IdleBackupEnabled)
 	assert.Equal(t, 60, conf.Backup.IdleMinutes)
 }
+
+func TestReadConfig_MetadataRefresh(t *testing.T) {
+	testCases := []struct {
+		name        string
+		tomlContent string
+		want        MetadataRefreshConfig
+	}{
+		{
+			name:        "defaults when the table is absent",
+			tomlContent: `config_directory = "/tmp/cfg"`,
+			want:        MetadataRefreshConfig{IntervalHours: 24},
+		},
+		{
+			name: "explicit values",
+			tomlContent: `
+[metadata_refresh]
+disabled = true
+interval_hours = 6
+`,
+			want: MetadataRefreshConfig{Disabled: true, IntervalHours: 6},
+		},
+		{
+			name: "non-positive interval falls back to the default",
+			tomlContent: `
+[metadata_refresh]
+interval_hours = -1
+`,
+			want: MetadataRefreshConfig{IntervalHours: 24},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpFile := filepath.Join(t.TempDir(), "refresh.toml")
+			require.NoError(t, os.WriteFile(tmpFile, []byte(tc.tomlContent), 0644))
+
+			conf, err := ReadConfig(tmpFile)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, conf.MetadataRefresh)
+		})
+	}
+}
+
+func TestWriteConfig_MetadataRefreshRoundTrip(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "refresh.toml")
+
+	original := Config{
+		ConfigDirectory: "/tmp/config",
+		MetadataRefresh: MetadataRefreshConfig{
+			Disabled:      true,
+			IntervalHours: 12,
+		},
+	}
+	require.NoError(t, WriteConfig(tmpFile, original))
+
+	got, err := ReadConfig(tmpFile)
+	require.NoError(t, err)
+	assert.Equal(t, original.MetadataRefresh, got.MetadataRefresh)
+}
+
+func TestReadConfig_History(t *testing.T) {
+	testCases := []struct {
+		name        string
+		tomlContent string
+		want        HistoryConfig
+	}{
+		{
+			name:        "defaults when the table is absent",
+			tomlContent: `config_directory = "/tmp/cfg"`,
+			want:        HistoryConfig{UndoLimit: 50},
+		},
+		{
+			name: "explicit values",
+			tomlContent: `
+[history]
+user_name = "michael"
+undo_limit = 10
+`,
+			want: HistoryConfig{UserName: "michael", UndoLimit: 10},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpFile := filepath.Join(t.TempDir(), "history.toml")
+			require.NoError(t, os.WriteFile(tmpFile, []byte(tc.tomlContent), 0644))
+
+			conf, err := ReadConfig(tmpFile)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, conf.History)
+		})
+	}
+}
+
+func TestReadConfig_MetadataLanguages(t *testing.T) {
+	testCases := []struct {
+		name        string
+		tomlContent string
+		want        []string
+	}{
+		{
+			name:        "defaults when unset",
+			tomlContent: `config_directory = "/tmp/cfg"`,
+			want:        []string{"en", "ja", "ja-Latn"},
+		},
+		{
+			name:        "explicit preference order",
+			tomlContent: `metadata_languages = ["ja", "en"]`,
+			want:        []string{"ja", "en"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpFile := filepath.Join(t.TempDir(), "languages.toml")
+			require.NoError(t, os.WriteFile(tmpFile, []byte(tc.tomlContent), 0644))
+
+			conf, err := ReadConfig(tmpFile)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, conf.MetadataLanguages)
+		})
+	}
+}
+
+func TestReadConfig_TagSuggestion(t *testing.T) {
+	testCases := []struct {
+		name        string
+		tomlContent string
+		want        TagSuggestionConfig
+		wantErr     bool
+	}{
+		{
+			name:        "defaults when the table is absent",
+			tomlContent: `config_directory = "/tmp/cfg"`,
+			want: TagSuggestionConfig{
+				Protocol:    TagSuggestionProtocolV2,
+				AutoTagging: AutoTaggingConfig{IntervalMinutes: 10, BatchSize: 32, Threshold: 0.9, ReviewThreshold: 0.5},
+			},
+		},
+		{
+			name: "explicit values",
+			tomlContent: `
+[tag_suggestion]
+protocol = "v1"
+address = "localhost:50051"
+thumbnail_width = 512
+
+[tag_suggestion.auto_tagging]
+enabled = true
+interval_minutes = 5
+batch_size = 8
+threshold = 0.8
+review_threshold = 0.4
+
+[tag_suggestion.auto_tagging.tag_thresholds]
+"1girl" = 0.95
+`,
+			want: TagSuggestionConfig{
+				Protocol:       TagSuggestionProtocolV1,
+				Address:        "localhost:50051",
+				ThumbnailWidth: 512,
+				AutoTagging: AutoTaggingConfig{
+					Enabled:         true,
+					IntervalMinutes: 5,
+					BatchSize:       8,
+					Threshold:       0.8,
+					TagThresholds:   map[string]float64{"1girl": 0.95},
+					ReviewThreshold: 0.4,
+				},
+			},
+		},
+		{
+			name: "an explicit review threshold of 0 is kept",
+			tomlContent: `
+[tag_suggestion.auto_tagging]
+review_threshold = 0
+`,
+			want: TagSuggestionConfig{
+				Protocol:    TagSuggestionProtocolV2,
+				AutoTagging: AutoTaggingConfig{IntervalMinutes: 10, BatchSize: 32, Threshold: 0.9, ReviewThreshold: 0},
+			},
+		},
+		{
+			name: "an empty protocol is v2",
+			tomlContent: `
+[tag_suggestion]
+protocol = ""
+`,
+			want: TagSuggestionConfig{
+				Protocol:    TagSuggestionProtocolV2,
+				AutoTagging: AutoTaggingConfig{IntervalMinutes: 10, BatchSize: 32, Threshold: 0.9, ReviewThreshold: 0.5},
+			},
+		},
+		{
+			name: "an unknown protocol",
+			tomlContent: `
+[tag_suggestion]
+protocol = "v3"
+`,
+			wantErr: true,
+		},
+		{
+			name: "a threshold lower than the review threshold",
+			tomlContent: `
+[tag_suggestion.auto_tagging]
+threshold = 0.4
+review_threshold = 0.5
+`,
+			wantErr: true,
+		},
+		{
+			name: "a negative review threshold",
+			tomlContent: `
+[tag_suggestion.auto_tagging]
+review_threshold = -0.1
+`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpFile := filepath.Join(t.TempDir(), "tag_suggestion.toml")
+			require.NoError(t, os.WriteFile(tmpFile, []byte(tc.tomlContent), 0644))
+
+			conf, err := ReadConfig(tmpFile)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, conf.TagSuggestion)
+		})
+	}
+}
+
+func TestReadConfig_Plugins(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "plugins.toml")
+	require.NoError(t, os.WriteFile(tmpFile, []byte(`
+[tag_suggestion]
+plugin = "tag-suggestion"
+
+[analysis]
+plugins = ["other"]
+
+[[plugins]]
+name = "tag-suggestion"
+command = "pdm"
+args = ["run", "python", "src/main.py", "server", "model", "--address", "{address}"]
+directory = "plugins/tag-suggestion"
+port = 50051
+
+[plugins.env]
+CUDA_VISIBLE_DEVICES = "0"
+
+[[plugins]]
+name = "other"
+command = "other-plugin"
+socket = "/tmp/other.sock"
+start_timeout_seconds = 10
+`), 0644))
+
+	conf, err := ReadConfig(tmpFile)
+	require.NoError(t, err)
+	assert.Equal(t, "tag-suggestion", conf.TagSuggestion.Plugin)
+	assert.Equal(t, []string{"other"}, conf.Analysis.Plugins)
+	assert.Equal(t, []PluginConfig{
+		{
+			Name:                "tag-suggestion",
+			Command:             "pdm",
+			Args:                []string{"run", "python", "src/main.py", "server", "model", "--address", "{address}"},
+			Env:                 map[string]string{"CUDA_VISIBLE_DEVICES": "0"},
+			Directory:           "plugins/tag-suggestion",
+			Port:                50051,
+			StartTimeoutSeconds: 120,
+		},
+		{
+			Name:                "other",
+			Command:             "other-plugin",
+			Socket:              "/tmp/other.sock",
+			StartTimeoutSeconds: 10,
+		},
+	}, conf.Plugins)
+}
+
+func TestReadConfig_Hooks(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "hooks.toml")
+	require.NoError(t, os.WriteFile(tmpFile, []byte(`
+[[hooks.webhooks]]
+url = "http://localhost:8080/events"
+secret = "secret"
+events = ["images.imported", "images.deleted"]
+
+[[hooks.webhooks]]
+url = "http://localhost:8081/events"
+max_retries = -1
+timeout_seconds = 3
+
+[[hooks.exec]]
+command = "wallpaper-rotator"
+args = ["--reload"]
+events = ["images.imported"]
+`), 0644))
+
+	conf, err := ReadConfig(tmpFile)
+	require.NoError(t, err)
+	assert.Equal(t, HooksConfig{
+		Webhooks: []WebhookConfig{
+			{
+				URL:            "http://localhost:8080/events",
+				Secret:         "secret",
+				Events:         []string{"images.imported", "images.deleted"},
+				MaxRetries:     5,
+				TimeoutSeconds: 10,
+			},
+			{
+				URL:            "http://localhost:8081/events",
+				MaxRetries:     -1,
+				TimeoutSeconds: 3,
+			},
+		},
+		Exec: []ExecHookConfig{
+			{
+				Command:        "wallpaper-rotator",
+				Args:           []string{"--reload"},
+				Events:         []string{"images.imported"},
+				TimeoutSeconds: 60,
+			},
+		},
+	}, conf.Hooks)
+}
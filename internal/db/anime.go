@@ -18,8 +18,14 @@ type Anime struct {
 	// backfilled from the metadata database's externalIds; the AniList API is
 	// never called.
 	AniListID *int `gorm:"index"`
-	CreatedAt uint `gorm:"autoCreateTime"`
-	UpdatedAt uint `gorm:"autoUpdateTime"`
+	// FranchiseID groups this anime with the others of its franchise. It is
+	// set from the metadata database on import, or by hand.
+	FranchiseID *uint `gorm:"index"`
+	// FranchiseSetByUser is set once the user moves the anime into or out of
+	// a franchise, so that a re-import doesn't change its franchise again.
+	FranchiseSetByUser bool
+	CreatedAt          uint `gorm:"autoCreateTime"`
+	UpdatedAt          uint `gorm:"autoUpdateTime"`
 }
 
 type AnimeList []Anime
@@ -56,3 +62,38 @@ func (client AnimeClient) FindByName(ctx context.Context, name string) (Anime, e
 	return value, err
 }
 
+// FindAllLinkedToMetadata returns every anime linked to a series in the anime
+// metadata database.
+func (client AnimeClient) FindAllLinkedToMetadata(ctx context.Context) (AnimeList, error) {
+	var values []Anime
+	err := client.getTransaction(ctx).
+		Where("metadata_series_id IS NOT NULL AND metadata_series_id != ''").
+		Order("id").
+		Find(&values).
+		Error
+	return values, err
+}
+
+// FindByFranchiseID returns the anime of a franchise, ordered by id.
+func (client AnimeClient) FindByFranchiseID(ctx context.Context, franchiseID uint) (AnimeList, error) {
+	var values []Anime
+	err := client.getTransaction(ctx).
+		Where("franchise_id = ?", franchiseID).
+		Order("id").
+		Find(&values).
+		Error
+	return values, err
+}
+
+// ClearFranchiseID detaches every anime from a franchise, as the user's
+// choice which a re-import keeps.
+func (client AnimeClient) ClearFranchiseID(ctx context.Context, franchiseID uint) error {
+	return client.getTransaction(ctx).
+		Model(&Anime{}).
+		Where("franchise_id = ?", franchiseID).
+		Updates(map[string]any{
+			"franchise_id":          nil,
+			"franchise_set_by_user": true,
+		}).
+		Error
+}
@@ -139,3 +139,50 @@ func TestFileClient_AnimeID(t *testing.T) {
 		assert.Len(t, got, 1)
 	})
 }
+
+func TestAnimeClient_FindAllLinkedToMetadata(t *testing.T) {
+	testClient := NewTestClient(t)
+	testClient.Truncate(t, Anime{})
+
+	linked := "fate-zero"
+	empty := ""
+	LoadTestData(t, testClient, []Anime{
+		{ID: 7201, Name: "Fate/Zero", MetadataSeriesID: &linked},
+		{ID: 7202, Name: "Unlinked"},
+		{ID: 7203, Name: "Cleared", MetadataSeriesID: &empty},
+	})
+
+	got, err := testClient.Anime().FindAllLinkedToMetadata(context.Background())
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, uint(7201), got[0].ID)
+}
+
+func TestAnimeClient_FranchiseID(t *testing.T) {
+	testClient := NewTestClient(t)
+	testClient.Truncate(t, Anime{})
+	ctx := context.Background()
+
+	fate := uint(1)
+	gundam := uint(2)
+	LoadTestData(t, testClient, []Anime{
+		{ID: 7302, Name: "Fate/Zero", FranchiseID: &fate},
+		{ID: 7301, Name: "Fate/stay night", FranchiseID: &fate},
+		{ID: 7303, Name: "Mobile Suit Gundam", FranchiseID: &gundam},
+		{ID: 7304, Name: "Standalone"},
+	})
+
+	got, err := testClient.Anime().FindByFranchiseID(ctx, fate)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, uint(7301), got[0].ID)
+	assert.Equal(t, uint(7302), got[1].ID)
+
+	require.NoError(t, testClient.Anime().ClearFranchiseID(ctx, fate))
+	got, err = testClient.Anime().FindByFranchiseID(ctx, fate)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+	got, err = testClient.Anime().FindByFranchiseID(ctx, gundam)
+	require.NoError(t, err)
+	assert.Len(t, got, 1, "other franchises are untouched")
+}
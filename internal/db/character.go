@@ -2,12 +2,16 @@ package db
 
 import (
 	"context"
+	"slices"
 )
 
 type Character struct {
-	ID      uint   `gorm:"primarykey"`
-	Name    string `gorm:"not null"`
-	AnimeID uint   `gorm:"index;not null"`
+	ID   uint   `gorm:"primarykey"`
+	Name string `gorm:"not null"`
+	// AnimeID is the anime the character was created under. A character
+	// shared by several anime, e.g. across Fate spin-offs, also belongs to
+	// every anime it has an AnimeCharacter row for.
+	AnimeID uint `gorm:"index;not null"`
 
 	// MetadataCharacterID is the id this character was imported under from the
 	// anime metadata database (e.g. "artoria-pendragon"). It is the identity a
@@ -36,9 +40,27 @@ func (client *Client) Character() *CharacterClient {
 	}
 }
 
-func (client CharacterClient) FindByAnimeID(animeID uint) ([]Character, error) {
+// FindByAnimeID returns the characters of an anime, including those shared
+// with it from another anime.
+func (client CharacterClient) FindByAnimeID(ctx context.Context, animeID uint) ([]Character, error) {
 	var values []Character
-	err := client.connection.Where("anime_id = ?", animeID).
+	err := client.getTransaction(ctx).
+		Where("anime_id = ? OR id IN (SELECT character_id FROM anime_characters WHERE anime_id = ?)", animeID, animeID).
+		Find(&values).
+		Error
+	return values, err
+}
+
+// FindByMetadataCharacterIDs returns the characters imported under any of the
+// given upstream ids, whichever anime they belong to.
+func (client CharacterClient) FindByMetadataCharacterIDs(ctx context.Context, metadataIDs []string) ([]Character, error) {
+	if len(metadataIDs) == 0 {
+		return nil, nil
+	}
+	var values []Character
+	err := client.getTransaction(ctx).
+		Where("metadata_character_id IN ?", metadataIDs).
+		Order("id").
 		Find(&values).
 		Error
 	return values, err
@@ -61,6 +83,16 @@ func (client CharacterClient) DeleteByID(ctx context.Context, id uint) error {
 		Error
 }
 
+func (client CharacterClient) DeleteByIDs(ctx context.Context, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return client.getTransaction(ctx).
+		Where("id IN ?", ids).
+		Delete(&Character{}).
+		Error
+}
+
 func (client CharacterClient) DeleteByAnimeID(ctx context.Context, animeID uint) error {
 	return client.getTransaction(ctx).
 		Where("anime_id = ?", animeID).
@@ -68,6 +100,88 @@ func (client CharacterClient) DeleteByAnimeID(ctx context.Context, animeID uint)
 		Error
 }
 
+// AnimeCharacter shares a character with an anime other than the one it was
+// created under.
+type AnimeCharacter struct {
+	AnimeID     uint `gorm:"primaryKey;autoIncrement:false"`
+	CharacterID uint `gorm:"primaryKey;autoIncrement:false;index"`
+	CreatedAt   uint `gorm:"autoCreateTime"`
+}
+
+type AnimeCharacterClient struct {
+	*ORMClient[AnimeCharacter]
+}
+
+func (client *Client) AnimeCharacter() *AnimeCharacterClient {
+	return &AnimeCharacterClient{
+		ORMClient: &ORMClient[AnimeCharacter]{
+			connection: client.connection,
+		},
+	}
+}
+
+func (client AnimeCharacterClient) FindByCharacterIDs(ctx context.Context, characterIDs []uint) ([]AnimeCharacter, error) {
+	if len(characterIDs) == 0 {
+		return nil, nil
+	}
+	var values []AnimeCharacter
+	err := client.getTransaction(ctx).
+		Where("character_id IN ?", characterIDs).
+		Order("anime_id").
+		Find(&values).
+		Error
+	return values, err
+}
+
+// FindAnimeIDsByCharacters returns every anime each character belongs to: the
+// one it was created under and those it is shared with, in id order.
+func (client AnimeCharacterClient) FindAnimeIDsByCharacters(ctx context.Context, characters []Character) (map[uint][]uint, error) {
+	if len(characters) == 0 {
+		return nil, nil
+	}
+	characterIDs := make([]uint, len(characters))
+	for i, c := range characters {
+		characterIDs[i] = c.ID
+	}
+	shared, err := client.FindByCharacterIDs(ctx, characterIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[uint][]uint, len(characters))
+	for _, c := range characters {
+		result[c.ID] = []uint{c.AnimeID}
+	}
+	for _, row := range shared {
+		animeIDs, ok := result[row.CharacterID]
+		if !ok || slices.Contains(animeIDs, row.AnimeID) {
+			continue
+		}
+		result[row.CharacterID] = append(animeIDs, row.AnimeID)
+	}
+	for _, animeIDs := range result {
+		slices.Sort(animeIDs)
+	}
+	return result, nil
+}
+
+func (client AnimeCharacterClient) DeleteByAnimeID(ctx context.Context, animeID uint) error {
+	return client.getTransaction(ctx).
+		Where("anime_id = ?", animeID).
+		Delete(&AnimeCharacter{}).
+		Error
+}
+
+func (client AnimeCharacterClient) DeleteByCharacterIDs(ctx context.Context, characterIDs []uint) error {
+	if len(characterIDs) == 0 {
+		return nil
+	}
+	return client.getTransaction(ctx).
+		Where("character_id IN ?", characterIDs).
+		Delete(&AnimeCharacter{}).
+		Error
+}
+
 type FileCharacter struct {
 	CharacterID uint           `gorm:"primaryKey;autoIncrement:false"`
 	FileID      uint           `gorm:"primaryKey;autoIncrement:false"`
@@ -116,6 +230,16 @@ func (client *FileCharacterClient) DeleteByCharacterID(ctx context.Context, char
 		Error
 }
 
+func (client *FileCharacterClient) DeleteByCharacterIDs(ctx context.Context, characterIDs []uint) error {
+	if len(characterIDs) == 0 {
+		return nil
+	}
+	return client.getTransaction(ctx).
+		Where("character_id IN ?", characterIDs).
+		Delete(&FileCharacter{}).
+		Error
+}
+
 func (client *FileCharacterClient) DeleteByFileIDs(ctx context.Context, fileIDs []uint) error {
 	if len(fileIDs) == 0 {
 		return nil
@@ -37,23 +37,57 @@ func TestCharacterClient_FindByAnimeID(t *testing.T) {
 	charClient := testClient.Character()
 
 	t.Run("finds characters for anime 10", func(t *testing.T) {
-		got, err := charClient.FindByAnimeID(10)
+		got, err := charClient.FindByAnimeID(context.Background(), 10)
 		assert.NoError(t, err)
 		assert.Len(t, got, 2)
 	})
 
 	t.Run("finds characters for anime 20", func(t *testing.T) {
-		got, err := charClient.FindByAnimeID(20)
+		got, err := charClient.FindByAnimeID(context.Background(), 20)
 		assert.NoError(t, err)
 		assert.Len(t, got, 1)
 		assert.Equal(t, "Char C", got[0].Name)
 	})
 
 	t.Run("returns empty for unknown anime", func(t *testing.T) {
-		got, err := charClient.FindByAnimeID(999)
+		got, err := charClient.FindByAnimeID(context.Background(), 999)
 		assert.NoError(t, err)
 		assert.Empty(t, got)
 	})
+
+	t.Run("includes characters shared with the anime", func(t *testing.T) {
+		testClient.Truncate(t, AnimeCharacter{})
+		LoadTestData(t, testClient, []AnimeCharacter{{AnimeID: 20, CharacterID: 1001}})
+
+		got, err := charClient.FindByAnimeID(context.Background(), 20)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"Char A", "Char C"}, []string{got[0].Name, got[1].Name})
+	})
+}
+
+func TestAnimeCharacterClient_FindAnimeIDsByCharacters(t *testing.T) {
+	testClient := NewTestClient(t)
+	testClient.Truncate(t, AnimeCharacter{})
+	ctx := context.Background()
+	client := testClient.AnimeCharacter()
+
+	require.NoError(t, client.BatchCreateIgnoringExisting(ctx, []AnimeCharacter{
+		{AnimeID: 30, CharacterID: 1001},
+		{AnimeID: 5, CharacterID: 1001},
+	}))
+	require.NoError(t, client.BatchCreateIgnoringExisting(ctx, []AnimeCharacter{
+		{AnimeID: 30, CharacterID: 1001},
+	}), "an existing pair is skipped")
+
+	got, err := client.FindAnimeIDsByCharacters(ctx, []Character{
+		{ID: 1001, AnimeID: 10},
+		{ID: 1002, AnimeID: 10},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[uint][]uint{
+		1001: {5, 10, 30},
+		1002: {10},
+	}, got)
 }
 
 func TestCharacterClient_FindByIDs(t *testing.T) {
@@ -135,12 +169,12 @@ func TestCharacterClient_DeleteByAnimeID(t *testing.T) {
 	require.NoError(t, err)
 
 	// Characters for anime 10 should be gone
-	got, err := charClient.FindByAnimeID(10)
+	got, err := charClient.FindByAnimeID(context.Background(), 10)
 	assert.NoError(t, err)
 	assert.Empty(t, got)
 
 	// Characters for anime 20 should remain
-	got, err = charClient.FindByAnimeID(20)
+	got, err = charClient.FindByAnimeID(context.Background(), 20)
 	assert.NoError(t, err)
 	assert.Len(t, got, 1)
 	assert.Equal(t, "Char C", got[0].Name)
@@ -102,6 +102,17 @@ func (client *Client) Migrate() error {
 		&Anime{},
 		&Character{},
 		&FileCharacter{},
+		&AnimeCharacter{},
+		&MetadataRefreshRun{},
+		&MetadataRefreshChange{},
+		&LocalizedTitle{},
+		&Franchise{},
+		&Operation{},
+		&SuggestionEvent{},
+		&SuggestionReview{},
+		&AutoTaggedFile{},
+		&AutoTaggingRun{},
+		&FileAnnotation{},
 	); err != nil {
 		return fmt.Errorf("AutoMigrate: %w", err)
 	}
@@ -109,6 +120,9 @@ func (client *Client) Migrate() error {
 	if err := client.migrateCharactersFromTags(); err != nil {
 		return fmt.Errorf("migrateCharactersFromTags: %w", err)
 	}
+	if err := client.mergeDuplicateCharacters(); err != nil {
+		return fmt.Errorf("mergeDuplicateCharacters: %w", err)
+	}
 
 	return nil
 }
@@ -256,6 +270,81 @@ func (client *Client) migrateCharactersFromTags() error {
 	})
 }
 
+// mergeDuplicateCharacters merges characters imported under the same upstream
+// id into one row. Before characters could be shared between anime, importing
+// two series with the same character created a copy per anime and split its
+// images between them.
+//
+// The oldest row is kept and shared with the anime of the others; their image
+// links move to it. It is idempotent: without duplicates it is a no-op.
+func (client *Client) mergeDuplicateCharacters() error {
+	var duplicateIDs []string
+	if err := client.connection.Model(&Character{}).
+		Where("metadata_character_id IS NOT NULL AND metadata_character_id != ''").
+		Group("metadata_character_id").
+		Having("COUNT(*) > 1").
+		Pluck("metadata_character_id", &duplicateIDs).
+		Error; err != nil {
+		return fmt.Errorf("find duplicate characters: %w", err)
+	}
+	if len(duplicateIDs) == 0 {
+		return nil
+	}
+
+	slog.Info("mergeDuplicateCharacters: found characters to merge", "count", len(duplicateIDs))
+
+	return client.connection.Transaction(func(tx *gorm.DB) error {
+		for _, metadataID := range duplicateIDs {
+			var characters []Character
+			if err := tx.Where("metadata_character_id = ?", metadataID).Order("id").Find(&characters).Error; err != nil {
+				return fmt.Errorf("find characters %q: %w", metadataID, err)
+			}
+			kept := characters[0]
+
+			for _, duplicate := range characters[1:] {
+				if duplicate.AnimeID != kept.AnimeID {
+					if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+						Create(&AnimeCharacter{AnimeID: duplicate.AnimeID, CharacterID: kept.ID}).
+						Error; err != nil {
+						return fmt.Errorf("share character %d with anime %d: %w", kept.ID, duplicate.AnimeID, err)
+					}
+				}
+				if err := tx.Exec(
+					"INSERT OR IGNORE INTO anime_characters (anime_id, character_id, created_at) "+
+						"SELECT anime_id, ?, created_at FROM anime_characters WHERE character_id = ? AND anime_id != ?",
+					kept.ID, duplicate.ID, kept.AnimeID,
+				).Error; err != nil {
+					return fmt.Errorf("move anime of character %d: %w", duplicate.ID, err)
+				}
+				if err := tx.Exec(
+					"INSERT OR IGNORE INTO file_characters (character_id, file_id, added_by, created_at) "+
+						"SELECT ?, file_id, added_by, created_at FROM file_characters WHERE character_id = ?",
+					kept.ID, duplicate.ID,
+				).Error; err != nil {
+					return fmt.Errorf("move images of character %d: %w", duplicate.ID, err)
+				}
+
+				if err := tx.Where("character_id = ?", duplicate.ID).Delete(&FileCharacter{}).Error; err != nil {
+					return fmt.Errorf("delete file characters of %d: %w", duplicate.ID, err)
+				}
+				if err := tx.Where("character_id = ?", duplicate.ID).Delete(&AnimeCharacter{}).Error; err != nil {
+					return fmt.Errorf("delete anime characters of %d: %w", duplicate.ID, err)
+				}
+				if err := tx.Where("entity_type = ? AND entity_id = ?", LocalizedTitleEntityCharacter, duplicate.ID).
+					Delete(&LocalizedTitle{}).Error; err != nil {
+					return fmt.Errorf("delete titles of character %d: %w", duplicate.ID, err)
+				}
+				if err := tx.Delete(&Character{}, duplicate.ID).Error; err != nil {
+					return fmt.Errorf("delete character %d: %w", duplicate.ID, err)
+				}
+			}
+			slog.Info("mergeDuplicateCharacters: merged character",
+				"metadataCharacterID", metadataID, "keptID", kept.ID, "merged", len(characters)-1)
+		}
+		return nil
+	})
+}
+
 // walkUpForAnime walks up the file tree from the given file ID until it finds
 // a directory with AnimeID set, and returns that AnimeID. Returns 0 if no
 // anime is found or an error occurs.
@@ -360,6 +449,18 @@ func (ormClient *ORMClient[Model]) BatchCreate(ctx context.Context, values []Mod
 		Error
 }
 
+// BatchCreateIgnoringExisting creates the values, skipping those whose
+// primary key already exists.
+func (ormClient *ORMClient[Model]) BatchCreateIgnoringExisting(ctx context.Context, values []Model) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return ormClient.getTransaction(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&values).
+		Error
+}
+
 func (ormClient *ORMClient[Model]) BatchDelete(ctx context.Context, values []Model) error {
 	return ormClient.getTransaction(ctx).
 		Delete(values).
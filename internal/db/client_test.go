@@ -877,6 +877,67 @@ func TestMigrateCharactersFromTags(t *testing.T) {
 }
 
 // Tests for FindAllByValue (generic)
+func TestMergeDuplicateCharacters(t *testing.T) {
+	tmpDir := t.TempDir()
+	dsn := DSNFromFilePath(tmpDir, "merge_char_test.sqlite")
+	client, err := NewClient(dsn, WithNopLogger())
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	require.NoError(t, client.Migrate())
+
+	saber := "artoria-pendragon"
+	shirou := "shirou-emiya"
+	characters := []Character{
+		{ID: 1, Name: "Saber", AnimeID: 100, MetadataCharacterID: &saber},
+		{ID: 2, Name: "Saber", AnimeID: 200, MetadataCharacterID: &saber},
+		{ID: 3, Name: "Saber Alter", AnimeID: 300, MetadataCharacterID: &saber},
+		{ID: 4, Name: "Shirou", AnimeID: 200, MetadataCharacterID: &shirou},
+		{ID: 5, Name: "Hand-made", AnimeID: 100},
+		{ID: 6, Name: "Hand-made", AnimeID: 200},
+	}
+	require.NoError(t, client.connection.Create(&characters).Error)
+	require.NoError(t, client.connection.Create(&[]FileCharacter{
+		{CharacterID: 1, FileID: 10, AddedBy: FileTagAddedByUser},
+		{CharacterID: 2, FileID: 20, AddedBy: FileTagAddedByUser},
+		{CharacterID: 3, FileID: 10, AddedBy: FileTagAddedByUser},
+	}).Error)
+	require.NoError(t, client.connection.Create(&[]LocalizedTitle{
+		{EntityType: LocalizedTitleEntityCharacter, EntityID: 1, AnimeID: 100, Language: "en", Title: "Saber"},
+		{EntityType: LocalizedTitleEntityCharacter, EntityID: 2, AnimeID: 200, Language: "en", Title: "Saber"},
+	}).Error)
+
+	require.NoError(t, client.Migrate())
+	// A second run has nothing left to merge.
+	require.NoError(t, client.Migrate())
+
+	var gotCharacters []Character
+	require.NoError(t, client.connection.Order("id").Find(&gotCharacters).Error)
+	gotIDs := make([]uint, len(gotCharacters))
+	for i, c := range gotCharacters {
+		gotIDs[i] = c.ID
+	}
+	assert.Equal(t, []uint{1, 4, 5, 6}, gotIDs, "only characters imported under the same id are merged")
+
+	var shared []AnimeCharacter
+	require.NoError(t, client.connection.Order("anime_id").Find(&shared).Error)
+	gotShared := make([][2]uint, len(shared))
+	for i, row := range shared {
+		gotShared[i] = [2]uint{row.AnimeID, row.CharacterID}
+	}
+	assert.Equal(t, [][2]uint{{200, 1}, {300, 1}}, gotShared, "the kept row is shared with the others' anime")
+
+	var fileCharacters []FileCharacter
+	require.NoError(t, client.connection.Order("file_id").Find(&fileCharacters).Error)
+	require.Len(t, fileCharacters, 2, "an image tagged with two copies keeps one link")
+	assert.Equal(t, uint(1), fileCharacters[0].CharacterID)
+	assert.Equal(t, uint(1), fileCharacters[1].CharacterID)
+
+	var titles []LocalizedTitle
+	require.NoError(t, client.connection.Find(&titles).Error)
+	require.Len(t, titles, 1)
+	assert.Equal(t, uint(1), titles[0].EntityID)
+}
+
 func TestFindAllByValue(t *testing.T) {
 	client := newIsolatedTableClient(t)
 
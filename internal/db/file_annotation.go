@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+)
+
+type FileAnnotationType string
+
+const (
+	FileAnnotationTypeText         FileAnnotationType = "text"
+	FileAnnotationTypeBoundingBox  FileAnnotationType = "bounding_box"
+	FileAnnotationTypeDerivedImage FileAnnotationType = "derived_image"
+)
+
+// FileAnnotation is a result of an image analysis plugin for a file, like a
+// subtitle read by OCR, the face of a character or an upscaled image.
+// The annotations of a file by a capability of a plugin are replaced each time
+// the file is analyzed.
+type FileAnnotation struct {
+	ID            uint   `gorm:"primarykey"`
+	FileID        uint   `gorm:"index;not null"`
+	PluginName    string `gorm:"not null"`
+	PluginVersion string
+	Capability    string             `gorm:"not null"`
+	Type          FileAnnotationType `gorm:"not null"`
+	Score         float64
+
+	// Text and Language are set for a text
+	Text     string
+	Language string
+	// Label is set for a bounding box
+	Label string
+	// X, Y, Width and Height are the region of a text or a bounding box,
+	// relative to the size of the image. They are all 0 without a region.
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+	// DerivedImagePath is the path of a derived image, relative to the
+	// annotation directory
+	DerivedImagePath string
+
+	CreatedAt uint `gorm:"autoCreateTime"`
+}
+
+type FileAnnotationClient struct {
+	*ORMClient[FileAnnotation]
+}
+
+func (client *Client) FileAnnotation() *FileAnnotationClient {
+	return &FileAnnotationClient{
+		ORMClient: &ORMClient[FileAnnotation]{
+			connection: client.connection,
+		},
+	}
+}
+
+// FindByFileIDs returns the annotations of the files, ordered by file and
+// then as they were created.
+func (client FileAnnotationClient) FindByFileIDs(ctx context.Context, fileIDs []uint) ([]FileAnnotation, error) {
+	var values []FileAnnotation
+	err := client.getTransaction(ctx).
+		Where("file_id IN ?", fileIDs).
+		Order("file_id").
+		Order("id").
+		Find(&values).
+		Error
+	return values, err
+}
+
+// FindByCapability returns the annotations of a file by a capability of a
+// plugin.
+func (client FileAnnotationClient) FindByCapability(ctx context.Context, fileID uint, pluginName string, capability string) ([]FileAnnotation, error) {
+	var values []FileAnnotation
+	err := client.getTransaction(ctx).
+		Where("file_id = ? AND plugin_name = ? AND capability = ?", fileID, pluginName, capability).
+		Order("id").
+		Find(&values).
+		Error
+	return values, err
+}
+
+// DeleteByCapability deletes the annotations of a file by a capability of a
+// plugin.
+func (client FileAnnotationClient) DeleteByCapability(ctx context.Context, fileID uint, pluginName string, capability string) error {
+	return client.getTransaction(ctx).
+		Where("file_id = ? AND plugin_name = ? AND capability = ?", fileID, pluginName, capability).
+		Delete(&FileAnnotation{}).
+		Error
+}
+
+func (client FileAnnotationClient) DeleteByFileIDs(ctx context.Context, fileIDs []uint) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	return client.getTransaction(ctx).
+		Where("file_id IN ?", fileIDs).
+		Delete(&FileAnnotation{}).
+		Error
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileAnnotationClient(t *testing.T) {
+	ctx := context.Background()
+	testClient := NewTestClient(t)
+	testClient.Truncate(t, FileAnnotation{})
+	LoadTestData(t, testClient, []FileAnnotation{
+		{ID: 1, FileID: 10, PluginName: "ocr", Capability: "ocr", Type: FileAnnotationTypeText, Text: "text1"},
+		{ID: 2, FileID: 11, PluginName: "ocr", Capability: "ocr", Type: FileAnnotationTypeText, Text: "text2"},
+		{ID: 3, FileID: 10, PluginName: "face", Capability: "face_detection", Type: FileAnnotationTypeBoundingBox, Label: "face"},
+		{ID: 4, FileID: 10, PluginName: "ocr", Capability: "ocr", Type: FileAnnotationTypeText, Text: "text3"},
+		{ID: 5, FileID: 12, PluginName: "upscaler", Capability: "upscale", Type: FileAnnotationTypeDerivedImage},
+	})
+	client := testClient.FileAnnotation()
+
+	ids := func(annotations []FileAnnotation) []uint {
+		result := make([]uint, len(annotations))
+		for i, annotation := range annotations {
+			result[i] = annotation.ID
+		}
+		return result
+	}
+
+	got, err := client.FindByFileIDs(ctx, []uint{11, 10})
+	require.NoError(t, err)
+	assert.Equal(t, []uint{1, 3, 4, 2}, ids(got))
+
+	got, err = client.FindByCapability(ctx, 10, "ocr", "ocr")
+	require.NoError(t, err)
+	assert.Equal(t, []uint{1, 4}, ids(got))
+
+	require.NoError(t, client.DeleteByCapability(ctx, 10, "ocr", "ocr"))
+	assert.Equal(t, []uint{2, 3, 5}, ids(MustGetAll[FileAnnotation](t, testClient)))
+
+	require.NoError(t, client.DeleteByFileIDs(ctx, []uint{10, 12}))
+	assert.Equal(t, []uint{2}, ids(MustGetAll[FileAnnotation](t, testClient)))
+}
@@ -70,8 +70,9 @@ type File struct {
 	MetadataTitle *string `gorm:"column:metadata_title"`
 
 	// ContentHash stores a hex-encoded SHA256 hash of the image file content.
-	// It is computed on import and used for fast corruption detection.
-	ContentHash string
+	// It is computed on import and used for fast corruption detection and to
+	// find duplicates of an image being imported.
+	ContentHash string `gorm:"index"`
 
 	// ImageWidth is the pixel width of the source image, populated on import
 	// and backfilled for existing images. NULL for directories or unknown.
@@ -81,6 +82,13 @@ type File struct {
 	// and backfilled for existing images. NULL for directories or unknown.
 	ImageHeight *uint
 
+	// Rating is the 1 to 5 star rating of an image, imported from its
+	// metadata. NULL for an unrated image.
+	Rating *uint
+
+	// Description is the caption of an image, imported from its metadata.
+	Description string
+
 	// CreatedAt is a timestamp of the record creation
 	CreatedAt uint `gorm:"autoCreateTime,index:parent_id_created_at"`
 	UpdatedAt uint
@@ -132,6 +140,22 @@ func (client *FileClient) FindImageFilesByIDs(ids []uint) ([]File, error) {
 	return images, err
 }
 
+// FindImageFilesByContentHashes returns the images whose content hash is one
+// of the hashes, ordered by ID.
+func (client *FileClient) FindImageFilesByContentHashes(contentHashes []string) ([]File, error) {
+	var images []File
+	if len(contentHashes) == 0 {
+		return images, nil
+	}
+	err := client.connection.
+		Order("id").
+		Where("content_hash IN ?", contentHashes).
+		Where("type = ?", FileTypeImage).
+		Find(&images).
+		Error
+	return images, err
+}
+
 func (client *FileClient) FindDirectoriesByIDs(ids []uint) ([]File, error) {
 	var images []File
 	err := client.connection.
@@ -143,6 +167,52 @@ func (client *FileClient) FindDirectoriesByIDs(ids []uint) ([]File, error) {
 	return images, err
 }
 
+// FindAnimeIDsByDirectoryIDs returns the anime assigned to each directory or
+// its closest ancestor. A directory without any anime is not in the result.
+// It reads through the transaction in ctx, if any.
+func (client *FileClient) FindAnimeIDsByDirectoryIDs(ctx context.Context, directoryIDs []uint) (map[uint]uint, error) {
+	result := make(map[uint]uint)
+	// descendants are the directories of the arguments waiting for each
+	// ancestor to be read
+	descendants := make(map[uint][]uint)
+	for _, id := range directoryIDs {
+		if id != RootDirectoryID {
+			descendants[id] = append(descendants[id], id)
+		}
+	}
+	for len(descendants) > 0 {
+		ids := make([]uint, 0, len(descendants))
+		for id := range descendants {
+			ids = append(ids, id)
+		}
+		var directories []File
+		if err := client.getTransaction(ctx).
+			Select("id", "parent_id", "anime_id").
+			Where("id IN ?", ids).
+			Where("type = ?", FileTypeDirectory).
+			Find(&directories).
+			Error; err != nil {
+			return nil, err
+		}
+
+		next := make(map[uint][]uint)
+		for _, directory := range directories {
+			if directory.AnimeID != nil {
+				for _, id := range descendants[directory.ID] {
+					result[id] = *directory.AnimeID
+				}
+				continue
+			}
+			if directory.ParentID == RootDirectoryID || directory.ParentID == directory.ID {
+				continue
+			}
+			next[directory.ParentID] = append(next[directory.ParentID], descendants[directory.ID]...)
+		}
+		descendants = next
+	}
+	return result, nil
+}
+
 // FindDirectoriesByAnimeID returns all directory rows whose AnimeID equals
 // the provided id. Used to look up explicitly-assigned anime root folders.
 func (client *FileClient) FindDirectoriesByAnimeID(animeID uint) ([]File, error) {
@@ -311,6 +381,23 @@ func (client *FileClient) UpdateContentHash(id uint, hash string) error {
 		Error
 }
 
+// UpdateImageContent updates the columns of an image that come from its
+// content, after the file was replaced with another one.
+func (client *FileClient) UpdateImageContent(ctx context.Context, file File) error {
+	return client.getTransaction(ctx).
+		Model(&File{}).
+		Where("id = ?", file.ID).
+		Updates(map[string]any{
+			"content_hash":     file.ContentHash,
+			"image_width":      file.ImageWidth,
+			"image_height":     file.ImageHeight,
+			"image_created_at": file.ImageCreatedAt,
+			"rating":           file.Rating,
+			"description":      file.Description,
+		}).
+		Error
+}
+
 // ImageDimensions holds the pixel width and height for an image file.
 type ImageDimensions struct {
 	Width  uint
@@ -331,6 +331,45 @@ func TestFileClient_FindDirectoriesByIDs(t *testing.T) {
 	})
 }
 
+func TestFileClient_FindAnimeIDsByDirectoryIDs(t *testing.T) {
+	testClient := NewTestClient(t)
+	testClient.Truncate(t, File{})
+
+	animeID := uint(1)
+	otherAnimeID := uint(2)
+	files := []File{
+		{ID: 4101, ParentID: 0, Name: "anime", Type: FileTypeDirectory, AnimeID: &animeID},
+		{ID: 4102, ParentID: 4101, Name: "season 1", Type: FileTypeDirectory},
+		{ID: 4103, ParentID: 4102, Name: "episode 1", Type: FileTypeDirectory},
+		{ID: 4104, ParentID: 4101, Name: "movie", Type: FileTypeDirectory, AnimeID: &otherAnimeID},
+		{ID: 4105, ParentID: 0, Name: "unassigned", Type: FileTypeDirectory},
+		{ID: 4106, ParentID: 4105, Name: "child", Type: FileTypeDirectory},
+	}
+	LoadTestData(t, testClient, files)
+
+	fileClient := testClient.File()
+	ctx := context.Background()
+
+	t.Run("closest anime of each directory", func(t *testing.T) {
+		got, err := fileClient.FindAnimeIDsByDirectoryIDs(ctx, []uint{4101, 4103, 4104, 4106, 9999, RootDirectoryID})
+		require.NoError(t, err)
+		assert.Equal(t, map[uint]uint{4101: animeID, 4103: animeID, 4104: otherAnimeID}, got)
+	})
+
+	t.Run("in a transaction", func(t *testing.T) {
+		err := NewTransaction(ctx, testClient.Client, func(ctx context.Context) error {
+			if err := fileClient.Create(ctx, &File{ID: 4107, ParentID: 4103, Name: "new", Type: FileTypeDirectory}); err != nil {
+				return err
+			}
+			got, err := fileClient.FindAnimeIDsByDirectoryIDs(ctx, []uint{4107})
+			require.NoError(t, err)
+			assert.Equal(t, map[uint]uint{4107: animeID}, got)
+			return nil
+		})
+		require.NoError(t, err)
+	})
+}
+
 func TestFileClient_FindAllImageFiles(t *testing.T) {
 	testClient := NewTestClient(t)
 	testClient.Truncate(t, File{})
@@ -390,6 +429,70 @@ func TestFileClient_UpdateContentHash(t *testing.T) {
 	})
 }
 
+func TestFileClient_FindImageFilesByContentHashes(t *testing.T) {
+	testClient := NewTestClient(t)
+	testClient.Truncate(t, File{})
+
+	files := []File{
+		{ID: 6101, ParentID: 0, Name: "dir1", Type: FileTypeDirectory, ContentHash: "aaaa"},
+		{ID: 6102, ParentID: 6101, Name: "img1.jpg", Type: FileTypeImage, ContentHash: "aaaa"},
+		{ID: 6103, ParentID: 6101, Name: "img2.jpg", Type: FileTypeImage, ContentHash: "bbbb"},
+		{ID: 6104, ParentID: 6101, Name: "img3.jpg", Type: FileTypeImage, ContentHash: "aaaa"},
+		{ID: 6105, ParentID: 6101, Name: "img4.jpg", Type: FileTypeImage},
+	}
+	LoadTestData(t, testClient, files)
+
+	fileClient := testClient.File()
+
+	t.Run("find images by hashes", func(t *testing.T) {
+		got, err := fileClient.FindImageFilesByContentHashes([]string{"aaaa", "cccc"})
+		assert.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.Equal(t, uint(6102), got[0].ID)
+		assert.Equal(t, uint(6104), got[1].ID)
+	})
+
+	t.Run("no hashes", func(t *testing.T) {
+		got, err := fileClient.FindImageFilesByContentHashes(nil)
+		assert.NoError(t, err)
+		assert.Empty(t, got)
+	})
+}
+
+func TestFileClient_UpdateImageContent(t *testing.T) {
+	testClient := NewTestClient(t)
+	testClient.Truncate(t, File{})
+
+	rating := uint(4)
+	width, height := uint(32), uint(16)
+	files := []File{
+		{ID: 6201, ParentID: 0, Name: "dir1", Type: FileTypeDirectory},
+		{ID: 6202, ParentID: 6201, Name: "img1.jpg", Type: FileTypeImage, ContentHash: "aaaa", ImageCreatedAt: 100, Rating: &rating, Description: "old"},
+	}
+	LoadTestData(t, testClient, files)
+
+	err := testClient.File().UpdateImageContent(context.Background(), File{
+		ID:             6202,
+		ContentHash:    "bbbb",
+		ImageWidth:     &width,
+		ImageHeight:    &height,
+		ImageCreatedAt: 200,
+	})
+	assert.NoError(t, err)
+
+	images, err := testClient.File().FindImageFilesByIDs([]uint{6202})
+	assert.NoError(t, err)
+	require.Len(t, images, 1)
+	assert.Equal(t, "img1.jpg", images[0].Name)
+	assert.Equal(t, uint(6201), images[0].ParentID)
+	assert.Equal(t, "bbbb", images[0].ContentHash)
+	assert.Equal(t, &width, images[0].ImageWidth)
+	assert.Equal(t, &height, images[0].ImageHeight)
+	assert.Equal(t, uint(200), images[0].ImageCreatedAt)
+	assert.Nil(t, images[0].Rating)
+	assert.Empty(t, images[0].Description)
+}
+
 func TestFileClient_BatchUpdateContentHashes(t *testing.T) {
 	testClient := NewTestClient(t)
 	testClient.Truncate(t, File{})
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+)
+
+// Franchise groups anime that share a franchise in the anime metadata
+// database, e.g. every Fate series. It is created the first time an anime in
+// the franchise is imported; its name is the user's to change afterwards.
+type Franchise struct {
+	ID   uint   `gorm:"primarykey"`
+	Name string `gorm:"not null"`
+	// MetadataFranchiseID is the franchise id in the anime metadata database,
+	// e.g. "fate". It is nil for a franchise the user created by hand.
+	MetadataFranchiseID *string `gorm:"uniqueIndex"`
+	CreatedAt           uint    `gorm:"autoCreateTime"`
+	UpdatedAt           uint    `gorm:"autoUpdateTime"`
+}
+
+type FranchiseList []Franchise
+
+type FranchiseClient struct {
+	*ORMClient[Franchise]
+}
+
+func (client *Client) Franchise() *FranchiseClient {
+	return &FranchiseClient{
+		ORMClient: &ORMClient[Franchise]{
+			connection: client.connection,
+		},
+	}
+}
+
+// FindByMetadataFranchiseID returns the franchise linked to the given id in
+// the anime metadata database, or ErrRecordNotFound.
+func (client FranchiseClient) FindByMetadataFranchiseID(ctx context.Context, metadataFranchiseID string) (Franchise, error) {
+	var value Franchise
+	err := client.getTransaction(ctx).
+		Where("metadata_franchise_id = ?", metadataFranchiseID).
+		Take(&value).
+		Error
+	return value, err
+}
@@ -0,0 +1,28 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFranchiseClient_FindByMetadataFranchiseID(t *testing.T) {
+	testClient := NewTestClient(t)
+	testClient.Truncate(t, Franchise{})
+	ctx := context.Background()
+
+	fate := "fate"
+	LoadTestData(t, testClient, []Franchise{
+		{ID: 1, Name: "Fate", MetadataFranchiseID: &fate},
+		{ID: 2, Name: "Hand-made"},
+	})
+
+	got, err := testClient.Franchise().FindByMetadataFranchiseID(ctx, "fate")
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), got.ID)
+
+	_, err = testClient.Franchise().FindByMetadataFranchiseID(ctx, "gundam")
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+}
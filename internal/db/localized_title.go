@@ -0,0 +1,170 @@
+package db
+
+import (
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LocalizedTitleEntity is the kind of row a LocalizedTitle belongs to.
+type LocalizedTitleEntity string
+
+const (
+	LocalizedTitleEntityAnime     LocalizedTitleEntity = "anime"
+	LocalizedTitleEntityFile      LocalizedTitleEntity = "file"
+	LocalizedTitleEntityCharacter LocalizedTitleEntity = "character"
+)
+
+// LocalizedTitle is the name of an anime, season folder or character in one
+// language, as imported from the anime metadata database. The row's own name
+// (and, for a folder, its name on disk) is left alone; these only back search
+// and the display language the UI picks.
+type LocalizedTitle struct {
+	ID         uint                 `gorm:"primarykey"`
+	EntityType LocalizedTitleEntity `gorm:"uniqueIndex:idx_localized_title_entity_language;not null"`
+	EntityID   uint                 `gorm:"uniqueIndex:idx_localized_title_entity_language;not null"`
+	Language   string               `gorm:"uniqueIndex:idx_localized_title_entity_language;not null"`
+	// AnimeID is the anime the entity belongs to, so a season or character
+	// found by title resolves to its anime without walking the folder tree.
+	AnimeID uint   `gorm:"index;not null"`
+	Title   string `gorm:"not null"`
+	// SearchTitle is Title folded to lower case, as SQLite's LOWER folds only
+	// ASCII letters. It is set whenever a title is saved.
+	SearchTitle string `gorm:"not null;default:''"`
+	CreatedAt   uint   `gorm:"autoCreateTime"`
+	UpdatedAt   uint   `gorm:"autoUpdateTime"`
+}
+
+func (title *LocalizedTitle) BeforeSave(tx *gorm.DB) error {
+	title.SearchTitle = toSearchTitle(title.Title)
+	return nil
+}
+
+func toSearchTitle(title string) string {
+	return strings.ToLower(title)
+}
+
+type LocalizedTitleList []LocalizedTitle
+
+// ToMap groups titles by entity id and then by language.
+func (titles LocalizedTitleList) ToMap() map[uint]map[string]string {
+	result := make(map[uint]map[string]string)
+	for _, title := range titles {
+		if _, ok := result[title.EntityID]; !ok {
+			result[title.EntityID] = make(map[string]string)
+		}
+		result[title.EntityID][title.Language] = title.Title
+	}
+	return result
+}
+
+type LocalizedTitleClient struct {
+	*ORMClient[LocalizedTitle]
+}
+
+func (client *Client) LocalizedTitle() *LocalizedTitleClient {
+	return &LocalizedTitleClient{
+		ORMClient: &ORMClient[LocalizedTitle]{
+			connection: client.connection,
+		},
+	}
+}
+
+// BatchUpsert inserts titles, replacing the title of an entity that already
+// has one in the same language.
+func (client LocalizedTitleClient) BatchUpsert(ctx context.Context, titles []LocalizedTitle) error {
+	if len(titles) == 0 {
+		return nil
+	}
+	return client.getTransaction(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{
+				{Name: "entity_type"},
+				{Name: "entity_id"},
+				{Name: "language"},
+			},
+			DoUpdates: clause.AssignmentColumns([]string{"title", "search_title", "anime_id", "updated_at"}),
+		}).
+		Create(&titles).
+		Error
+}
+
+func (client LocalizedTitleClient) FindByEntityIDs(
+	ctx context.Context,
+	entityType LocalizedTitleEntity,
+	entityIDs []uint,
+) (LocalizedTitleList, error) {
+	if len(entityIDs) == 0 {
+		return nil, nil
+	}
+	var values []LocalizedTitle
+	err := client.getTransaction(ctx).
+		Where("entity_type = ? AND entity_id IN ?", entityType, entityIDs).
+		Find(&values).
+		Error
+	return values, err
+}
+
+// SearchByTitle finds titles in any language that contain query,
+// case-insensitively for scripts that have case, e.g. Cyrillic or accented
+// Latin letters as well as ASCII.
+func (client LocalizedTitleClient) SearchByTitle(ctx context.Context, query string) (LocalizedTitleList, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, nil
+	}
+	var values []LocalizedTitle
+	err := client.getTransaction(ctx).
+		Where("search_title LIKE ? ESCAPE '\\'", "%"+escapeLike(toSearchTitle(query))+"%").
+		Order("anime_id, entity_type, entity_id, language").
+		Find(&values).
+		Error
+	return values, err
+}
+
+func (client LocalizedTitleClient) DeleteByEntityIDs(
+	ctx context.Context,
+	entityType LocalizedTitleEntity,
+	entityIDs []uint,
+) error {
+	if len(entityIDs) == 0 {
+		return nil
+	}
+	return client.getTransaction(ctx).
+		Where("entity_type = ? AND entity_id IN ?", entityType, entityIDs).
+		Delete(&LocalizedTitle{}).
+		Error
+}
+
+func (client LocalizedTitleClient) DeleteByAnimeID(ctx context.Context, animeID uint) error {
+	return client.getTransaction(ctx).
+		Where("anime_id = ?", animeID).
+		Delete(&LocalizedTitle{}).
+		Error
+}
+
+// UpdateAnimeID moves the titles of the given entities from one anime to
+// another, e.g. for a shared character whose anime is deleted.
+func (client LocalizedTitleClient) UpdateAnimeID(
+	ctx context.Context,
+	entityType LocalizedTitleEntity,
+	entityIDs []uint,
+	fromAnimeID uint,
+	toAnimeID uint,
+) error {
+	if len(entityIDs) == 0 {
+		return nil
+	}
+	return client.getTransaction(ctx).
+		Model(&LocalizedTitle{}).
+		Where("entity_type = ? AND entity_id IN ? AND anime_id = ?", entityType, entityIDs, fromAnimeID).
+		Update("anime_id", toAnimeID).
+		Error
+}
+
+// escapeLike escapes the LIKE wildcards in a user-supplied search string.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
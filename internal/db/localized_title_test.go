@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalizedTitleClient_BatchUpsert(t *testing.T) {
+	testClient := NewTestClient(t)
+	testClient.Truncate(t, LocalizedTitle{})
+	ctx := context.Background()
+	client := testClient.LocalizedTitle()
+
+	require.NoError(t, client.BatchUpsert(ctx, []LocalizedTitle{
+		{EntityType: LocalizedTitleEntityAnime, EntityID: 1, AnimeID: 1, Language: "en", Title: "Frieren"},
+		{EntityType: LocalizedTitleEntityAnime, EntityID: 1, AnimeID: 1, Language: "ja", Title: "葬送のフリーレン"},
+	}))
+	require.NoError(t, client.BatchUpsert(ctx, []LocalizedTitle{
+		{EntityType: LocalizedTitleEntityAnime, EntityID: 1, AnimeID: 1, Language: "en", Title: "Frieren: Beyond Journey's End"},
+	}))
+	require.NoError(t, client.BatchUpsert(ctx, nil))
+
+	got, err := client.FindByEntityIDs(ctx, LocalizedTitleEntityAnime, []uint{1})
+	require.NoError(t, err)
+	assert.Equal(t, map[uint]map[string]string{
+		1: {
+			"en": "Frieren: Beyond Journey's End",
+			"ja": "葬送のフリーレン",
+		},
+	}, got.ToMap())
+}
+
+func TestLocalizedTitleClient_SearchByTitle(t *testing.T) {
+	testClient := NewTestClient(t)
+	testClient.Truncate(t, LocalizedTitle{})
+	LoadTestData(t, testClient, []LocalizedTitle{
+		{ID: 1, EntityType: LocalizedTitleEntityAnime, EntityID: 1, AnimeID: 1, Language: "ja-Latn", Title: "Sousou no Frieren"},
+		{ID: 2, EntityType: LocalizedTitleEntityCharacter, EntityID: 5, AnimeID: 1, Language: "ja", Title: "フリーレン"},
+		{ID: 3, EntityType: LocalizedTitleEntityFile, EntityID: 9, AnimeID: 2, Language: "en", Title: "100% Season"},
+		{ID: 4, EntityType: LocalizedTitleEntityCharacter, EntityID: 6, AnimeID: 3, Language: "ru", Title: "Ёжик"},
+		{ID: 5, EntityType: LocalizedTitleEntityCharacter, EntityID: 7, AnimeID: 3, Language: "fr", Title: "ÉLODIE"},
+	})
+	client := testClient.LocalizedTitle()
+	ctx := context.Background()
+
+	testCases := []struct {
+		name  string
+		query string
+		want  []uint
+	}{
+		{name: "case-insensitive romaji", query: "sousou", want: []uint{1}},
+		{name: "japanese", query: "フリーレン", want: []uint{2}},
+		{name: "case-insensitive cyrillic", query: "ёЖИК", want: []uint{4}},
+		{name: "case-insensitive accented latin", query: "élodie", want: []uint{5}},
+		{name: "wildcards are literal", query: "100%", want: []uint{3}},
+		{name: "underscore is literal", query: "_", want: nil},
+		{name: "blank query", query: "  ", want: nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := client.SearchByTitle(ctx, tc.query)
+			require.NoError(t, err)
+			var gotIDs []uint
+			for _, title := range got {
+				gotIDs = append(gotIDs, title.ID)
+			}
+			assert.Equal(t, tc.want, gotIDs)
+		})
+	}
+}
+
+func TestLocalizedTitleClient_Delete(t *testing.T) {
+	testClient := NewTestClient(t)
+	testClient.Truncate(t, LocalizedTitle{})
+	LoadTestData(t, testClient, []LocalizedTitle{
+		{ID: 1, EntityType: LocalizedTitleEntityAnime, EntityID: 1, AnimeID: 1, Language: "en", Title: "A"},
+		{ID: 2, EntityType: LocalizedTitleEntityCharacter, EntityID: 5, AnimeID: 1, Language: "en", Title: "B"},
+		{ID: 3, EntityType: LocalizedTitleEntityCharacter, EntityID: 6, AnimeID: 2, Language: "en", Title: "C"},
+		{ID: 4, EntityType: LocalizedTitleEntityAnime, EntityID: 2, AnimeID: 2, Language: "en", Title: "D"},
+	})
+	client := testClient.LocalizedTitle()
+	ctx := context.Background()
+
+	require.NoError(t, client.DeleteByEntityIDs(ctx, LocalizedTitleEntityCharacter, []uint{6}))
+	require.NoError(t, client.DeleteByEntityIDs(ctx, LocalizedTitleEntityCharacter, nil))
+	require.NoError(t, client.DeleteByAnimeID(ctx, 1))
+
+	got := MustGetAll[LocalizedTitle](t, testClient)
+	require.Len(t, got, 1)
+	assert.Equal(t, uint(4), got[0].ID)
+}
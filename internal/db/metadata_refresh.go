@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+)
+
+// MetadataRefreshRun is one pass of the background refresher that re-imports
+// every anime linked to the anime metadata database. StartedAt is also what
+// schedules the next pass, so a restart does not trigger an early refresh.
+type MetadataRefreshRun struct {
+	ID          uint `gorm:"primarykey"`
+	StartedAt   uint `gorm:"index"`
+	FinishedAt  uint
+	AnimeCount  int
+	FailedCount int
+	CreatedAt   uint `gorm:"autoCreateTime"`
+}
+
+// MetadataRefreshChange is the change log of one anime within a run. Anime
+// that a run left untouched get no row, so the log only lists what actually
+// changed or failed.
+type MetadataRefreshChange struct {
+	ID                uint `gorm:"primarykey"`
+	RunID             uint `gorm:"index;not null"`
+	AnimeID           uint `gorm:"index;not null"`
+	SeasonsCreated    int
+	SeasonsUpdated    int
+	CharactersCreated int
+	CharactersUpdated int
+	// NewSeasons holds the names of the folders the run created, one per line.
+	NewSeasons string
+	// Error is set when the import for this anime failed.
+	Error     string
+	CreatedAt uint `gorm:"autoCreateTime"`
+}
+
+type MetadataRefreshRunClient struct {
+	*ORMClient[MetadataRefreshRun]
+}
+
+func (client *Client) MetadataRefreshRun() *MetadataRefreshRunClient {
+	return &MetadataRefreshRunClient{
+		ORMClient: &ORMClient[MetadataRefreshRun]{
+			connection: client.connection,
+		},
+	}
+}
+
+// FindLatest returns the most recently started runs, newest first.
+func (client MetadataRefreshRunClient) FindLatest(ctx context.Context, limit int) ([]MetadataRefreshRun, error) {
+	var values []MetadataRefreshRun
+	err := client.getTransaction(ctx).
+		Order("started_at DESC, id DESC").
+		Limit(limit).
+		Find(&values).
+		Error
+	return values, err
+}
+
+type MetadataRefreshChangeClient struct {
+	*ORMClient[MetadataRefreshChange]
+}
+
+func (client *Client) MetadataRefreshChange() *MetadataRefreshChangeClient {
+	return &MetadataRefreshChangeClient{
+		ORMClient: &ORMClient[MetadataRefreshChange]{
+			connection: client.connection,
+		},
+	}
+}
+
+func (client MetadataRefreshChangeClient) FindByRunIDs(ctx context.Context, runIDs []uint) ([]MetadataRefreshChange, error) {
+	if len(runIDs) == 0 {
+		return nil, nil
+	}
+	var values []MetadataRefreshChange
+	err := client.getTransaction(ctx).
+		Where("run_id IN ?", runIDs).
+		Order("id").
+		Find(&values).
+		Error
+	return values, err
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataRefreshRunClient_FindLatest(t *testing.T) {
+	testClient := NewTestClient(t)
+	testClient.Truncate(t, MetadataRefreshRun{})
+
+	LoadTestData(t, testClient, []MetadataRefreshRun{
+		{ID: 1, StartedAt: 100},
+		{ID: 2, StartedAt: 300},
+		{ID: 3, StartedAt: 200},
+	})
+	ctx := context.Background()
+
+	t.Run("newest first", func(t *testing.T) {
+		got, err := testClient.MetadataRefreshRun().FindLatest(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, got, 3)
+		assert.Equal(t, []uint{2, 3, 1}, []uint{got[0].ID, got[1].ID, got[2].ID})
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		got, err := testClient.MetadataRefreshRun().FindLatest(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, uint(2), got[0].ID)
+	})
+}
+
+func TestMetadataRefreshChangeClient_FindByRunIDs(t *testing.T) {
+	testClient := NewTestClient(t)
+	testClient.Truncate(t, MetadataRefreshChange{})
+
+	LoadTestData(t, testClient, []MetadataRefreshChange{
+		{ID: 1, RunID: 10, AnimeID: 1, SeasonsCreated: 1},
+		{ID: 2, RunID: 10, AnimeID: 2, Error: "boom"},
+		{ID: 3, RunID: 20, AnimeID: 1, CharactersUpdated: 2},
+	})
+	ctx := context.Background()
+
+	t.Run("find by run IDs", func(t *testing.T) {
+		got, err := testClient.MetadataRefreshChange().FindByRunIDs(ctx, []uint{10})
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.Equal(t, "boom", got[1].Error)
+	})
+
+	t.Run("empty input returns nil", func(t *testing.T) {
+		got, err := testClient.MetadataRefreshChange().FindByRunIDs(ctx, nil)
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+}
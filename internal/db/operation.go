@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+)
+
+// Operation is one entry of the edit history: a batch of tag, character or
+// file edits made by one user action. Change holds what the operation did so
+// that it can be reverted and re-applied; it is cleared once the operation
+// falls out of the undo window or a new edit discards it from the redo stack,
+// leaving the row as history only.
+type Operation struct {
+	ID       uint   `gorm:"primarykey"`
+	Kind     string `gorm:"not null"`
+	Summary  string
+	UserName string `gorm:"index"`
+	Change   string
+	// UndoneAt is set while the operation is undone, and cleared again when
+	// it is redone.
+	UndoneAt  uint
+	UndoneBy  string
+	CreatedAt uint `gorm:"autoCreateTime"`
+}
+
+type OperationClient struct {
+	*ORMClient[Operation]
+}
+
+func (client *Client) Operation() *OperationClient {
+	return &OperationClient{
+		ORMClient: &ORMClient[Operation]{
+			connection: client.connection,
+		},
+	}
+}
+
+// FindLatest returns a page of the history, newest first.
+func (client OperationClient) FindLatest(ctx context.Context, limit int, offset int) ([]Operation, error) {
+	var values []Operation
+	err := client.getTransaction(ctx).
+		Order("id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&values).
+		Error
+	return values, err
+}
+
+// FindLastUndoable returns the newest operation that can be undone, or
+// ErrRecordNotFound.
+func (client OperationClient) FindLastUndoable(ctx context.Context) (Operation, error) {
+	var value Operation
+	err := client.getTransaction(ctx).
+		Where("undone_at = 0 AND change != ''").
+		Order("id DESC").
+		Take(&value).
+		Error
+	return value, err
+}
+
+// FindFirstRedoable returns the oldest undone operation that can be redone,
+// or ErrRecordNotFound.
+func (client OperationClient) FindFirstRedoable(ctx context.Context) (Operation, error) {
+	var value Operation
+	err := client.getTransaction(ctx).
+		Where("undone_at != 0 AND change != ''").
+		Order("id").
+		Take(&value).
+		Error
+	return value, err
+}
+
+// ClearRedoable drops every undone operation from the redo stack.
+func (client OperationClient) ClearRedoable(ctx context.Context) error {
+	return client.getTransaction(ctx).
+		Model(&Operation{}).
+		Where("undone_at != 0 AND change != ''").
+		Update("change", "").
+		Error
+}
+
+// ClearUndoableBeyond keeps only the newest keep operations undoable.
+func (client OperationClient) ClearUndoableBeyond(ctx context.Context, keep int) error {
+	newest := client.getTransaction(ctx).
+		Model(&Operation{}).
+		Select("id").
+		Where("change != ''").
+		Order("id DESC").
+		Limit(keep)
+	return client.getTransaction(ctx).
+		Model(&Operation{}).
+		Where("change != '' AND id NOT IN (?)", newest).
+		Update("change", "").
+		Error
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperationClient(t *testing.T) {
+	ctx := context.Background()
+	ids := func(operations []Operation) []uint {
+		result := make([]uint, len(operations))
+		for i, operation := range operations {
+			result[i] = operation.ID
+		}
+		return result
+	}
+
+	testCases := []struct {
+		name              string
+		operations        []Operation
+		wantSecondPage    []uint
+		wantUndoable      uint
+		wantRedoable      uint
+		wantAfterClear    []uint
+		wantAfterKeepingN []uint
+	}{
+		{
+			name: "undo takes the newest done operation and redo the oldest undone one",
+			operations: []Operation{
+				{ID: 1, Kind: "a", Change: "{}"},
+				{ID: 2, Kind: "a", Change: "{}"},
+				{ID: 3, Kind: "a", Change: "{}", UndoneAt: 10},
+				{ID: 4, Kind: "a", Change: "{}", UndoneAt: 9},
+			},
+			wantSecondPage:    []uint{3, 2},
+			wantUndoable:      2,
+			wantRedoable:      3,
+			wantAfterClear:    []uint{1, 2},
+			wantAfterKeepingN: []uint{2},
+		},
+		{
+			name: "history without a change is skipped",
+			operations: []Operation{
+				{ID: 1, Kind: "a", Change: "{}"},
+				{ID: 2, Kind: "a"},
+				{ID: 3, Kind: "a", UndoneAt: 10},
+			},
+			wantSecondPage:    []uint{2, 1},
+			wantUndoable:      1,
+			wantAfterClear:    []uint{1},
+			wantAfterKeepingN: []uint{1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testClient := NewTestClient(t)
+			testClient.Truncate(t, Operation{})
+			LoadTestData(t, testClient, tc.operations)
+			client := testClient.Operation()
+
+			latest, err := client.FindLatest(ctx, 2, 1)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantSecondPage, ids(latest), "the page skips the newest operation")
+
+			got, err := client.FindLastUndoable(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantUndoable, got.ID)
+
+			got, err = client.FindFirstRedoable(ctx)
+			if tc.wantRedoable == 0 {
+				assert.ErrorIs(t, err, ErrRecordNotFound)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.wantRedoable, got.ID)
+			}
+
+			undoable := func() []uint {
+				result := make([]uint, 0)
+				for _, operation := range MustGetAll[Operation](t, testClient) {
+					if operation.Change != "" {
+						result = append(result, operation.ID)
+					}
+				}
+				return result
+			}
+			require.NoError(t, client.ClearRedoable(ctx))
+			assert.Equal(t, tc.wantAfterClear, undoable())
+			require.NoError(t, client.ClearUndoableBeyond(ctx, 1))
+			assert.Equal(t, tc.wantAfterKeepingN, undoable())
+		})
+	}
+}
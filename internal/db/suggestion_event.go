@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+)
+
+type SuggestionDecision string
+
+const (
+	SuggestionDecisionAccepted SuggestionDecision = "accepted"
+	SuggestionDecisionRejected SuggestionDecision = "rejected"
+	// SuggestionDecisionAutoApplied is a suggestion added by the auto-tagging
+	// job without a review
+	SuggestionDecisionAutoApplied SuggestionDecision = "auto-applied"
+)
+
+// SuggestionEvent records what a user decided on a tag or character suggested
+// for a file, so that rejected suggestions can be used as hard negatives for
+// retraining and the precision of each model can be measured.
+// Either TagID or CharacterID is set. Events are kept after their file is
+// deleted, to measure the precision over time.
+type SuggestionEvent struct {
+	ID          uint `gorm:"primarykey"`
+	FileID      uint `gorm:"index;not null"`
+	TagID       uint `gorm:"index"`
+	CharacterID uint `gorm:"index"`
+	Score       float64
+	// ModelName and ModelVersion are empty for a plugin which doesn't tell
+	// its model
+	ModelName    string
+	ModelVersion string             `gorm:"index"`
+	Decision     SuggestionDecision `gorm:"index;not null"`
+	CreatedAt    uint               `gorm:"autoCreateTime"`
+}
+
+type SuggestionEventClient struct {
+	*ORMClient[SuggestionEvent]
+}
+
+func (client *Client) SuggestionEvent() *SuggestionEventClient {
+	return &SuggestionEventClient{
+		ORMClient: &ORMClient[SuggestionEvent]{
+			connection: client.connection,
+		},
+	}
+}
+
+// FindByDecision returns the events with the decision, oldest first.
+func (client SuggestionEventClient) FindByDecision(ctx context.Context, decision SuggestionDecision) ([]SuggestionEvent, error) {
+	var values []SuggestionEvent
+	err := client.getTransaction(ctx).
+		Where("decision = ?", decision).
+		Order("id").
+		Find(&values).
+		Error
+	return values, err
+}
+
+// FindSince returns the events created at or after the unix time, oldest
+// first.
+func (client SuggestionEventClient) FindSince(ctx context.Context, createdAt uint) ([]SuggestionEvent, error) {
+	var values []SuggestionEvent
+	err := client.getTransaction(ctx).
+		Where("created_at >= ?", createdAt).
+		Order("id").
+		Find(&values).
+		Error
+	return values, err
+}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggestionEventClient(t *testing.T) {
+	ctx := context.Background()
+	testClient := NewTestClient(t)
+	testClient.Truncate(t, SuggestionEvent{})
+	LoadTestData(t, testClient, []SuggestionEvent{
+		{ID: 1, FileID: 10, TagID: 1, Score: 0.9, Decision: SuggestionDecisionAccepted, CreatedAt: 100},
+		{ID: 2, FileID: 10, TagID: 2, Score: 0.6, Decision: SuggestionDecisionRejected, CreatedAt: 100},
+		{ID: 3, FileID: 11, CharacterID: 3, Score: 0.7, Decision: SuggestionDecisionRejected, CreatedAt: 200},
+	})
+	client := testClient.SuggestionEvent()
+
+	ids := func(events []SuggestionEvent) []uint {
+		result := make([]uint, len(events))
+		for i, event := range events {
+			result[i] = event.ID
+		}
+		return result
+	}
+
+	got, err := client.FindByDecision(ctx, SuggestionDecisionRejected)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{2, 3}, ids(got))
+
+	got, err = client.FindSince(ctx, 150)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{3}, ids(got))
+}
@@ -0,0 +1,173 @@
+package db
+
+import (
+	"context"
+)
+
+// SuggestionReview is a tag suggested by the auto-tagging job with a score
+// too low to be added without a review. It's deleted once a user accepts or
+// rejects it.
+type SuggestionReview struct {
+	ID           uint `gorm:"primarykey"`
+	FileID       uint `gorm:"uniqueIndex:idx_suggestion_review_file_tag;not null"`
+	TagID        uint `gorm:"uniqueIndex:idx_suggestion_review_file_tag;not null"`
+	Score        float64
+	ModelName    string
+	ModelVersion string
+	CreatedAt    uint `gorm:"autoCreateTime"`
+}
+
+type SuggestionReviewClient struct {
+	*ORMClient[SuggestionReview]
+}
+
+func (client *Client) SuggestionReview() *SuggestionReviewClient {
+	return &SuggestionReviewClient{
+		ORMClient: &ORMClient[SuggestionReview]{
+			connection: client.connection,
+		},
+	}
+}
+
+// FindLatest returns a page of the reviews, those with higher scores first.
+func (client SuggestionReviewClient) FindLatest(ctx context.Context, limit int, offset int) ([]SuggestionReview, error) {
+	var values []SuggestionReview
+	err := client.getTransaction(ctx).
+		Order("score DESC, id").
+		Limit(limit).
+		Offset(offset).
+		Find(&values).
+		Error
+	return values, err
+}
+
+func (client SuggestionReviewClient) FindByIDs(ctx context.Context, ids []uint) ([]SuggestionReview, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var values []SuggestionReview
+	err := client.getTransaction(ctx).
+		Where("id IN ?", ids).
+		Order("id").
+		Find(&values).
+		Error
+	return values, err
+}
+
+func (client SuggestionReviewClient) DeleteByIDs(ctx context.Context, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return client.getTransaction(ctx).
+		Where("id IN ?", ids).
+		Delete(&SuggestionReview{}).
+		Error
+}
+
+func (client SuggestionReviewClient) DeleteByFileIDs(ctx context.Context, fileIDs []uint) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	return client.getTransaction(ctx).
+		Where("file_id IN ?", fileIDs).
+		Delete(&SuggestionReview{}).
+		Error
+}
+
+func (client SuggestionReviewClient) DeleteByTagIDs(ctx context.Context, tagIDs []uint) error {
+	if len(tagIDs) == 0 {
+		return nil
+	}
+	return client.getTransaction(ctx).
+		Where("tag_id IN ?", tagIDs).
+		Delete(&SuggestionReview{}).
+		Error
+}
+
+// AutoTaggedFile marks an image which the auto-tagging job suggested tags for,
+// so that it isn't suggested again. ModelVersion is empty for an image that
+// couldn't be read.
+type AutoTaggedFile struct {
+	FileID       uint `gorm:"primaryKey;autoIncrement:false"`
+	ModelVersion string
+	CreatedAt    uint `gorm:"autoCreateTime"`
+}
+
+type AutoTaggedFileClient struct {
+	*ORMClient[AutoTaggedFile]
+}
+
+func (client *Client) AutoTaggedFile() *AutoTaggedFileClient {
+	return &AutoTaggedFileClient{
+		ORMClient: &ORMClient[AutoTaggedFile]{
+			connection: client.connection,
+		},
+	}
+}
+
+// FindUntaggedImageIDs returns the IDs of the images which weren't auto-tagged
+// yet, and have no tags or were created at or after createdAt, oldest first.
+func (client AutoTaggedFileClient) FindUntaggedImageIDs(ctx context.Context, createdAt uint, limit int) ([]uint, error) {
+	var ids []uint
+	err := client.getTransaction(ctx).
+		Model(&File{}).
+		Where("type = ?", FileTypeImage).
+		Where("id NOT IN (SELECT file_id FROM auto_tagged_files)").
+		Where("(id NOT IN (SELECT file_id FROM file_tags) OR created_at >= ?)", createdAt).
+		Order("id").
+		Limit(limit).
+		Pluck("id", &ids).
+		Error
+	return ids, err
+}
+
+func (client AutoTaggedFileClient) DeleteByFileIDs(ctx context.Context, fileIDs []uint) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	return client.getTransaction(ctx).
+		Where("file_id IN ?", fileIDs).
+		Delete(&AutoTaggedFile{}).
+		Error
+}
+
+// AutoTaggingRun is a pass of the auto-tagging job. The first run is kept
+// even if it didn't tag any image, because images created after it start
+// being auto-tagged even if they have tags. Later runs are kept only if they
+// auto-tagged images.
+type AutoTaggingRun struct {
+	ID           uint `gorm:"primarykey"`
+	StartedAt    uint `gorm:"index"`
+	FinishedAt   uint
+	ImageCount   int
+	AppliedCount int
+	QueuedCount  int
+	CreatedAt    uint `gorm:"autoCreateTime"`
+}
+
+type AutoTaggingRunClient struct {
+	*ORMClient[AutoTaggingRun]
+}
+
+func (client *Client) AutoTaggingRun() *AutoTaggingRunClient {
+	return &AutoTaggingRunClient{
+		ORMClient: &ORMClient[AutoTaggingRun]{
+			connection: client.connection,
+		},
+	}
+}
+
+// FindFirstStartedAt returns when the first run started, or 0 if there was
+// no run.
+func (client AutoTaggingRunClient) FindFirstStartedAt(ctx context.Context) (uint, error) {
+	var startedAt *uint
+	err := client.getTransaction(ctx).
+		Model(&AutoTaggingRun{}).
+		Select("MIN(started_at)").
+		Scan(&startedAt).
+		Error
+	if err != nil || startedAt == nil {
+		return 0, err
+	}
+	return *startedAt, nil
+}
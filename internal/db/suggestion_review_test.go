@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggestionReviewClient(t *testing.T) {
+	ctx := context.Background()
+	testClient := NewTestClient(t)
+	testClient.Truncate(t, SuggestionReview{})
+	LoadTestData(t, testClient, []SuggestionReview{
+		{ID: 1, FileID: 10, TagID: 1, Score: 0.6},
+		{ID: 2, FileID: 10, TagID: 2, Score: 0.8},
+		{ID: 3, FileID: 11, TagID: 1, Score: 0.7},
+	})
+	client := testClient.SuggestionReview()
+
+	ids := func(reviews []SuggestionReview) []uint {
+		result := make([]uint, len(reviews))
+		for i, review := range reviews {
+			result[i] = review.ID
+		}
+		return result
+	}
+
+	got, err := client.FindLatest(ctx, 2, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{2, 3}, ids(got))
+
+	got, err = client.FindByIDs(ctx, []uint{3, 1})
+	require.NoError(t, err)
+	assert.Equal(t, []uint{1, 3}, ids(got))
+
+	require.NoError(t, client.DeleteByIDs(ctx, []uint{1}))
+	assert.Equal(t, []uint{2, 3}, ids(MustGetAll[SuggestionReview](t, testClient)))
+
+	require.NoError(t, client.DeleteByTagIDs(ctx, []uint{2}))
+	assert.Equal(t, []uint{3}, ids(MustGetAll[SuggestionReview](t, testClient)))
+
+	require.NoError(t, client.DeleteByFileIDs(ctx, []uint{11}))
+	assert.Empty(t, MustGetAll[SuggestionReview](t, testClient))
+}
+
+func TestAutoTaggedFileClient(t *testing.T) {
+	ctx := context.Background()
+	testClient := NewTestClient(t)
+	testClient.Truncate(t, File{}, FileTag{}, AutoTaggedFile{})
+	client := testClient.AutoTaggedFile()
+
+	LoadTestData(t, testClient, []File{
+		{ID: 1, Name: "directory", Type: FileTypeDirectory},
+		{ID: 10, Name: "untagged.jpg", ParentID: 1, Type: FileTypeImage, CreatedAt: 100},
+		{ID: 11, Name: "tagged.jpg", ParentID: 1, Type: FileTypeImage, CreatedAt: 100},
+		{ID: 12, Name: "new tagged.jpg", ParentID: 1, Type: FileTypeImage, CreatedAt: 300},
+		{ID: 13, Name: "auto-tagged.jpg", ParentID: 1, Type: FileTypeImage, CreatedAt: 300},
+		{ID: 14, Name: "another untagged.jpg", ParentID: 1, Type: FileTypeImage, CreatedAt: 100},
+	})
+	LoadTestData(t, testClient, []FileTag{
+		{FileID: 11, TagID: 1},
+		{FileID: 12, TagID: 1},
+	})
+	LoadTestData(t, testClient, []AutoTaggedFile{
+		{FileID: 13, CreatedAt: 200},
+	})
+
+	gotIDs, err := client.FindUntaggedImageIDs(ctx, 200, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{10, 12, 14}, gotIDs)
+
+	gotIDs, err = client.FindUntaggedImageIDs(ctx, 200, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{10, 12}, gotIDs)
+
+	require.NoError(t, client.DeleteByFileIDs(ctx, []uint{13}))
+	assert.Empty(t, MustGetAll[AutoTaggedFile](t, testClient))
+}
+
+func TestAutoTaggingRunClient(t *testing.T) {
+	ctx := context.Background()
+	testClient := NewTestClient(t)
+	testClient.Truncate(t, AutoTaggingRun{})
+	client := testClient.AutoTaggingRun()
+
+	got, err := client.FindFirstStartedAt(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, got)
+
+	LoadTestData(t, testClient, []AutoTaggingRun{
+		{ID: 1, StartedAt: 300},
+		{ID: 2, StartedAt: 200},
+	})
+	got, err = client.FindFirstStartedAt(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint(200), got)
+}
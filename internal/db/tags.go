@@ -172,6 +172,15 @@ func (client *FileTagClient) BatchDelete(ctx context.Context, tagIDs []uint, fil
 	return client.ORMClient.BatchDelete(ctx, fileTags)
 }
 
+// DeleteFileTags deletes exactly the given file and tag pairs, unlike
+// BatchDelete which deletes every combination of its tag and file IDs.
+func (client *FileTagClient) DeleteFileTags(ctx context.Context, fileTags []FileTag) error {
+	if len(fileTags) == 0 {
+		return nil
+	}
+	return client.ORMClient.BatchDelete(ctx, fileTags)
+}
+
 func (client *FileTagClient) DeleteByTagIDs(ctx context.Context, tagIDs []uint) error {
 	if len(tagIDs) == 0 {
 		return nil
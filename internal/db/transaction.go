@@ -19,9 +19,34 @@ func transactionFromContext(ctx context.Context) *gorm.DB {
 	return tx
 }
 
+type afterCommitKeyType struct{}
+
+var afterCommitKey = afterCommitKeyType{}
+
 func NewTransaction(ctx context.Context, client *Client, f func(context.Context) error) error {
-	return client.connection.Transaction(func(tx *gorm.DB) error {
+	afterCommit := make([]func(context.Context), 0)
+	err := client.connection.Transaction(func(tx *gorm.DB) error {
 		txWithContext := tx.WithContext(ctx)
-		return f(withTransaction(ctx, txWithContext))
+		txContext := context.WithValue(withTransaction(ctx, txWithContext), afterCommitKey, &afterCommit)
+		return f(txContext)
 	})
+	if err != nil {
+		return err
+	}
+	for _, callback := range afterCommit {
+		callback(ctx)
+	}
+	return nil
+}
+
+// AfterCommit calls f once the transaction in ctx has been committed, with a
+// context outside of the transaction. f is never called if the transaction
+// is rolled back, and it is called right away outside of a transaction.
+func AfterCommit(ctx context.Context, f func(context.Context)) {
+	afterCommit, ok := ctx.Value(afterCommitKey).(*[]func(context.Context))
+	if !ok {
+		f(ctx)
+		return
+	}
+	*afterCommit = append(*afterCommit, f)
 }
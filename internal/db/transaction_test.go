@@ -106,3 +106,55 @@ func TestNewTransaction(t *testing.T) {
 		testClient.Truncate(t, File{}, Tag{})
 	})
 }
+
+func TestAfterCommit(t *testing.T) {
+	testClient := NewTestClient(t)
+
+	testCases := []struct {
+		name           string
+		transactionErr error
+		wantCalled     bool
+	}{
+		{
+			name:       "called after a commit",
+			wantCalled: true,
+		},
+		{
+			name:           "not called after a rollback",
+			transactionErr: errors.New("intentional rollback"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testClient.Truncate(t, Tag{})
+			ctx := context.Background()
+			tagClient := testClient.Tag()
+
+			called := false
+			err := NewTransaction(ctx, testClient.Client, func(txCtx context.Context) error {
+				if err := tagClient.Create(txCtx, &Tag{ID: 9600, Name: "after-commit-tag"}); err != nil {
+					return err
+				}
+				AfterCommit(txCtx, func(ctx context.Context) {
+					called = true
+					// the callback runs outside of the transaction, and sees the commit
+					got, err := tagClient.FindByValue(ctx, &Tag{ID: 9600})
+					assert.NoError(t, err)
+					assert.Equal(t, "after-commit-tag", got.Name)
+				})
+				assert.False(t, called, "not called inside the transaction")
+				return tc.transactionErr
+			})
+			assert.Equal(t, tc.transactionErr, err)
+			assert.Equal(t, tc.wantCalled, called)
+		})
+	}
+
+	t.Run("called right away outside a transaction", func(t *testing.T) {
+		called := false
+		AfterCommit(context.Background(), func(ctx context.Context) {
+			called = true
+		})
+		assert.True(t, called)
+	})
+}
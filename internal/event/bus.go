@@ -0,0 +1,196 @@
+package event
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/history"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+)
+
+// queueSize is how many events can wait for a subscriber. Events are dropped
+// while its queue is full, so that a hook which is down doesn't slow the app.
+const queueSize = 256
+
+// Handler handles an event. An error is logged.
+type Handler func(ctx context.Context, event Event) error
+
+type subscriber struct {
+	name string
+	// types are the types of the events to handle, or every type if it's
+	// empty
+	types   []Type
+	handler Handler
+	queue   chan Event
+}
+
+// Bus delivers the events of library changes to subscribers, like webhooks.
+// Events are published after the transaction of a change is committed, and
+// each subscriber handles them in order in its own goroutine, so that a slow
+// hook doesn't delay the app or the other hooks. A nil Bus publishes nothing.
+type Bus struct {
+	logger      *slog.Logger
+	subscribers []*subscriber
+}
+
+func NewBus(logger *slog.Logger) *Bus {
+	return &Bus{
+		logger: logger,
+	}
+}
+
+// ParseTypes returns the types of the names of events in the config
+func ParseTypes(names []string) ([]Type, error) {
+	types := make([]Type, len(names))
+	for index, name := range names {
+		if !slices.Contains(Types, Type(name)) {
+			return nil, fmt.Errorf("%w: unknown event: %s", xerrors.ErrInvalidArgument, name)
+		}
+		types[index] = Type(name)
+	}
+	return types, nil
+}
+
+// Subscribe registers a handler of the events of the types, or of every event
+// if types are empty. Subscribers are registered on startup, before Start.
+func (bus *Bus) Subscribe(name string, types []Type, handler Handler) {
+	bus.subscribers = append(bus.subscribers, &subscriber{
+		name:    name,
+		types:   types,
+		handler: handler,
+		queue:   make(chan Event, queueSize),
+	})
+}
+
+// Start delivers events to the subscribers until ctx is done. Events which
+// aren't delivered by then are dropped.
+func (bus *Bus) Start(ctx context.Context) {
+	for _, subscriber := range bus.subscribers {
+		go bus.run(ctx, subscriber)
+	}
+}
+
+func (bus *Bus) run(ctx context.Context, subscriber *subscriber) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-subscriber.queue:
+			if err := subscriber.handler(ctx, event); err != nil {
+				bus.logger.ErrorContext(ctx, "failed to handle an event",
+					"subscriber", subscriber.name,
+					"event", event.Type,
+					"eventID", event.ID,
+					"error", err,
+				)
+			}
+		}
+	}
+}
+
+// Publish publishes an event once the transaction in ctx is committed
+func (bus *Bus) Publish(ctx context.Context, eventType Type, data any) {
+	if bus == nil || len(bus.subscribers) == 0 {
+		return
+	}
+	db.AfterCommit(ctx, func(ctx context.Context) {
+		event := Event{
+			ID:        newID(),
+			Type:      eventType,
+			CreatedAt: time.Now(),
+			Data:      data,
+		}
+		for _, subscriber := range bus.subscribers {
+			if len(subscriber.types) > 0 && !slices.Contains(subscriber.types, eventType) {
+				continue
+			}
+			select {
+			case subscriber.queue <- event:
+			default:
+				bus.logger.WarnContext(ctx, "dropped an event for a busy subscriber",
+					"subscriber", subscriber.name,
+					"event", event.Type,
+					"eventID", event.ID,
+				)
+			}
+		}
+	})
+}
+
+// OnChange publishes the events of a change recorded in the history. It's a
+// listener of history.Journal.
+func (bus *Bus) OnChange(ctx context.Context, change history.Change) {
+	tagsUpdated := TagsUpdated{
+		CreatedTags:           newTags(change.CreatedTags),
+		DeletedTags:           newTags(change.DeletedTags),
+		AddedFileTags:         newFileTags(change.AddedFileTags),
+		DeletedFileTags:       newFileTags(change.DeletedFileTags),
+		AddedFileCharacters:   newFileCharacters(change.AddedFileCharacters),
+		DeletedFileCharacters: newFileCharacters(change.DeletedFileCharacters),
+	}
+	if len(tagsUpdated.CreatedTags) > 0 ||
+		len(tagsUpdated.DeletedTags) > 0 ||
+		len(tagsUpdated.AddedFileTags) > 0 ||
+		len(tagsUpdated.DeletedFileTags) > 0 ||
+		len(tagsUpdated.AddedFileCharacters) > 0 ||
+		len(tagsUpdated.DeletedFileCharacters) > 0 {
+		bus.Publish(ctx, TypeTagsUpdated, tagsUpdated)
+	}
+	if len(change.MovedFiles) > 0 {
+		bus.Publish(ctx, TypeFilesMoved, FilesMoved{Files: change.MovedFiles})
+	}
+}
+
+func newID() string {
+	bytes := make([]byte, 16)
+	// crypto/rand.Read never returns an error
+	_, _ = rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
+
+// NewImages returns the images of the events
+func NewImages(imageFiles []image.ImageFile) []Image {
+	images := make([]Image, 0, len(imageFiles))
+	for _, imageFile := range imageFiles {
+		if imageFile.ID == 0 {
+			continue
+		}
+		images = append(images, Image{
+			ID:   imageFile.ID,
+			Name: imageFile.Name,
+			Path: imageFile.LocalFilePath,
+		})
+	}
+	return images
+}
+
+func newTags(tags []db.Tag) []Tag {
+	result := make([]Tag, len(tags))
+	for index, tag := range tags {
+		result[index] = Tag{ID: tag.ID, Name: tag.Name}
+	}
+	return result
+}
+
+func newFileTags(fileTags []db.FileTag) []FileTag {
+	result := make([]FileTag, len(fileTags))
+	for index, fileTag := range fileTags {
+		result[index] = FileTag{FileID: fileTag.FileID, TagID: fileTag.TagID}
+	}
+	return result
+}
+
+func newFileCharacters(fileCharacters []db.FileCharacter) []FileCharacter {
+	result := make([]FileCharacter, len(fileCharacters))
+	for index, fileCharacter := range fileCharacters {
+		result[index] = FileCharacter{FileID: fileCharacter.FileID, CharacterID: fileCharacter.CharacterID}
+	}
+	return result
+}
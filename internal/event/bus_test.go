@@ -0,0 +1,138 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/history"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// receive returns the events sent to a channel until none arrives for a while
+func receive(t *testing.T, events <-chan Event) []Event {
+	t.Helper()
+	result := make([]Event, 0)
+	for {
+		select {
+		case event := <-events:
+			result = append(result, event)
+		case <-time.After(100 * time.Millisecond):
+			return result
+		}
+	}
+}
+
+func TestBus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bus := NewBus(newTestLogger())
+	allEvents := make(chan Event, 10)
+	bus.Subscribe("all", nil, func(ctx context.Context, event Event) error {
+		allEvents <- event
+		return nil
+	})
+	imageEvents := make(chan Event, 10)
+	bus.Subscribe("images", []Type{TypeImagesImported, TypeImagesDeleted}, func(ctx context.Context, event Event) error {
+		imageEvents <- event
+		// an error of a subscriber doesn't stop the others
+		return errors.New("error")
+	})
+	bus.Start(ctx)
+
+	bus.Publish(ctx, TypeImagesImported, ImagesImported{DirectoryID: 1})
+	bus.Publish(ctx, TypeAnimeCreated, Anime{ID: 1, Name: "anime"})
+	bus.OnChange(ctx, history.Change{
+		AddedFileTags: []db.FileTag{{FileID: 10, TagID: 1, AddedBy: db.FileTagAddedByUser}},
+		MovedFiles:    []history.FileMove{{FileID: 10, FromParentID: 1, ToParentID: 2}},
+	})
+	// a change without tags is not published as tags.updated
+	bus.OnChange(ctx, history.Change{
+		MovedFiles: []history.FileMove{{FileID: 11, FromParentID: 1, ToParentID: 2}},
+	})
+
+	got := receive(t, allEvents)
+	require.Len(t, got, 5)
+	types := make([]Type, len(got))
+	for index, event := range got {
+		types[index] = event.Type
+		assert.Len(t, event.ID, 32)
+		assert.False(t, event.CreatedAt.IsZero())
+	}
+	assert.Equal(t, []Type{
+		TypeImagesImported,
+		TypeAnimeCreated,
+		TypeTagsUpdated,
+		TypeFilesMoved,
+		TypeFilesMoved,
+	}, types)
+	assert.NotEqual(t, got[0].ID, got[1].ID)
+	assert.Equal(t, TagsUpdated{
+		CreatedTags:           []Tag{},
+		DeletedTags:           []Tag{},
+		AddedFileTags:         []FileTag{{FileID: 10, TagID: 1}},
+		DeletedFileTags:       []FileTag{},
+		AddedFileCharacters:   []FileCharacter{},
+		DeletedFileCharacters: []FileCharacter{},
+	}, got[2].Data)
+
+	gotImageEvents := receive(t, imageEvents)
+	require.Len(t, gotImageEvents, 1)
+	assert.Equal(t, got[0], gotImageEvents[0])
+}
+
+func TestBus_Publish_AfterCommit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dbClient := db.NewTestClient(t)
+
+	bus := NewBus(newTestLogger())
+	events := make(chan Event, 10)
+	bus.Subscribe("all", nil, func(ctx context.Context, event Event) error {
+		events <- event
+		return nil
+	})
+	bus.Start(ctx)
+
+	err := db.NewTransaction(ctx, dbClient.Client, func(ctx context.Context) error {
+		bus.Publish(ctx, TypeAnimeDeleted, Anime{ID: 1})
+		return errors.New("rollback")
+	})
+	require.Error(t, err)
+	assert.Empty(t, receive(t, events))
+
+	require.NoError(t, db.NewTransaction(ctx, dbClient.Client, func(ctx context.Context) error {
+		bus.Publish(ctx, TypeAnimeDeleted, Anime{ID: 2})
+		return nil
+	}))
+	got := receive(t, events)
+	require.Len(t, got, 1)
+	assert.Equal(t, Anime{ID: 2}, got[0].Data)
+}
+
+func TestBus_Publish_Nil(t *testing.T) {
+	var bus *Bus
+	assert.NotPanics(t, func() {
+		bus.Publish(context.Background(), TypeAnimeCreated, Anime{ID: 1})
+	})
+}
+
+func TestParseTypes(t *testing.T) {
+	got, err := ParseTypes([]string{"images.imported", "anime.deleted"})
+	require.NoError(t, err)
+	assert.Equal(t, []Type{TypeImagesImported, TypeAnimeDeleted}, got)
+
+	_, err = ParseTypes([]string{"images.imported", "unknown"})
+	assert.ErrorIs(t, err, xerrors.ErrInvalidArgument)
+}
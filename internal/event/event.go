@@ -0,0 +1,125 @@
+package event
+
+import (
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/history"
+)
+
+// Type is the name of an event, which hooks subscribe to in the config
+type Type string
+
+const (
+	TypeImagesImported Type = "images.imported"
+	TypeImagesDeleted  Type = "images.deleted"
+	// TypeTagsUpdated is published when tags or characters are added to or
+	// removed from files, or tags are created or deleted, including by undo
+	// and redo
+	TypeTagsUpdated           Type = "tags.updated"
+	TypeFilesMoved            Type = "files.moved"
+	TypeAnimeCreated          Type = "anime.created"
+	TypeAnimeRenamed          Type = "anime.renamed"
+	TypeAnimeDeleted          Type = "anime.deleted"
+	TypeAnimeFolderAssigned   Type = "anime.folder_assigned"
+	TypeAnimeFolderUnassigned Type = "anime.folder_unassigned"
+	TypeAnimeMetadataImported Type = "anime.metadata_imported"
+)
+
+// Types are all types of events
+var Types = []Type{
+	TypeImagesImported,
+	TypeImagesDeleted,
+	TypeTagsUpdated,
+	TypeFilesMoved,
+	TypeAnimeCreated,
+	TypeAnimeRenamed,
+	TypeAnimeDeleted,
+	TypeAnimeFolderAssigned,
+	TypeAnimeFolderUnassigned,
+	TypeAnimeMetadataImported,
+}
+
+// Event is a change of the library. It's sent to hooks as JSON.
+type Event struct {
+	// ID is unique for each event, so that a receiver can ignore a webhook
+	// which is retried after it was received
+	ID        string    `json:"id"`
+	Type      Type      `json:"type"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Data is one of the structs below, by the type
+	Data any `json:"data"`
+}
+
+type Image struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	// Path is the path of the image file on disk
+	Path string `json:"path"`
+}
+
+// ImagesImported is the data of images.imported. Images are imported into
+// a directory. LinkedImageIDs are the images in the library which got the
+// tags of a duplicate or a name conflict, instead of a copy being imported.
+type ImagesImported struct {
+	DirectoryID    uint    `json:"directoryId"`
+	Images         []Image `json:"images"`
+	LinkedImageIDs []uint  `json:"linkedImageIds,omitempty"`
+}
+
+// ImagesDeleted is the data of images.deleted. Images are empty if the files
+// were already missing.
+type ImagesDeleted struct {
+	ImageIDs []uint  `json:"imageIds"`
+	Images   []Image `json:"images"`
+}
+
+type Tag struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+type FileTag struct {
+	FileID uint `json:"fileId"`
+	TagID  uint `json:"tagId"`
+}
+
+type FileCharacter struct {
+	FileID      uint `json:"fileId"`
+	CharacterID uint `json:"characterId"`
+}
+
+// TagsUpdated is the data of tags.updated
+type TagsUpdated struct {
+	CreatedTags           []Tag           `json:"createdTags,omitempty"`
+	DeletedTags           []Tag           `json:"deletedTags,omitempty"`
+	AddedFileTags         []FileTag       `json:"addedFileTags,omitempty"`
+	DeletedFileTags       []FileTag       `json:"deletedFileTags,omitempty"`
+	AddedFileCharacters   []FileCharacter `json:"addedFileCharacters,omitempty"`
+	DeletedFileCharacters []FileCharacter `json:"deletedFileCharacters,omitempty"`
+}
+
+// FilesMoved is the data of files.moved
+type FilesMoved struct {
+	Files []history.FileMove `json:"files"`
+}
+
+// Anime is the data of anime.created, anime.renamed and anime.deleted
+type Anime struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// AnimeFolder is the data of anime.folder_assigned and
+// anime.folder_unassigned
+type AnimeFolder struct {
+	AnimeID  uint `json:"animeId"`
+	FolderID uint `json:"folderId"`
+}
+
+// AnimeMetadataImported is the data of anime.metadata_imported
+type AnimeMetadataImported struct {
+	AnimeID  uint   `json:"animeId"`
+	SeriesID string `json:"seriesId"`
+	// NewSeasons are the folders of the seasons the import created
+	NewSeasons []string `json:"newSeasons"`
+}
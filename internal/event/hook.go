@@ -0,0 +1,185 @@
+package event
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+)
+
+const (
+	// HeaderEvent, HeaderDelivery and HeaderSignature are the headers of a
+	// webhook request, with the type and the ID of the event, and the
+	// signature of the body
+	HeaderEvent     = "X-Anime-Image-Viewer-Event"
+	HeaderDelivery  = "X-Anime-Image-Viewer-Delivery"
+	HeaderSignature = "X-Anime-Image-Viewer-Signature-256"
+
+	// EnvEventType and EnvEventID are the environment variables of an exec
+	// hook
+	EnvEventType = "ANIME_IMAGE_VIEWER_EVENT"
+	EnvEventID   = "ANIME_IMAGE_VIEWER_EVENT_ID"
+)
+
+// SubscribeHooks subscribes the webhooks and the exec hooks of the config
+func (bus *Bus) SubscribeHooks(conf config.HooksConfig) error {
+	for _, webhookConfig := range conf.Webhooks {
+		if webhookConfig.URL == "" {
+			return fmt.Errorf("%w: url of a webhook is required", xerrors.ErrInvalidArgument)
+		}
+		types, err := ParseTypes(webhookConfig.Events)
+		if err != nil {
+			return fmt.Errorf("webhook %s: %w", webhookConfig.URL, err)
+		}
+		webhook := newWebhook(bus.logger, webhookConfig)
+		bus.Subscribe("webhook "+webhookConfig.URL, types, webhook.handle)
+	}
+	for _, execConfig := range conf.Exec {
+		if execConfig.Command == "" {
+			return fmt.Errorf("%w: command of an exec hook is required", xerrors.ErrInvalidArgument)
+		}
+		types, err := ParseTypes(execConfig.Events)
+		if err != nil {
+			return fmt.Errorf("exec hook %s: %w", execConfig.Command, err)
+		}
+		hook := &execHook{logger: bus.logger, config: execConfig}
+		bus.Subscribe("exec "+execConfig.Command, types, hook.handle)
+	}
+	return nil
+}
+
+// Sign returns the signature of a webhook body, which a receiver compares
+// with the header HeaderSignature
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// errPermanent is a response of a webhook which fails again if it's retried
+var errPermanent = errors.New("permanent failure")
+
+type webhook struct {
+	logger         *slog.Logger
+	config         config.WebhookConfig
+	client         *http.Client
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+func newWebhook(logger *slog.Logger, webhookConfig config.WebhookConfig) *webhook {
+	return &webhook{
+		logger: logger,
+		config: webhookConfig,
+		client: &http.Client{
+			Timeout: time.Duration(webhookConfig.TimeoutSeconds) * time.Second,
+		},
+		initialBackoff: time.Second,
+		maxBackoff:     5 * time.Minute,
+	}
+}
+
+func (webhook *webhook) handle(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	nextBackoff := webhook.initialBackoff
+	for retry := 0; ; retry++ {
+		err = webhook.send(ctx, event, body)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, errPermanent) || retry >= webhook.config.MaxRetries {
+			return err
+		}
+		webhook.logger.WarnContext(ctx, "retrying a webhook",
+			"url", webhook.config.URL,
+			"eventID", event.ID,
+			"error", err,
+			"retryAfter", nextBackoff,
+		)
+		select {
+		case <-ctx.Done():
+			return errors.Join(ctx.Err(), err)
+		case <-time.After(nextBackoff):
+		}
+		nextBackoff = min(nextBackoff*2, webhook.maxBackoff)
+	}
+}
+
+func (webhook *webhook) send(ctx context.Context, event Event, body []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: http.NewRequestWithContext: %w", errPermanent, err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderEvent, string(event.Type))
+	request.Header.Set(HeaderDelivery, event.ID)
+	if webhook.config.Secret != "" {
+		request.Header.Set(HeaderSignature, Sign(webhook.config.Secret, body))
+	}
+
+	response, err := webhook.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("client.Do: %w", err)
+	}
+	defer response.Body.Close()
+	// read the body to reuse the connection
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return nil
+	}
+	if response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests || response.StatusCode == http.StatusRequestTimeout {
+		return fmt.Errorf("webhook responded %s", response.Status)
+	}
+	return fmt.Errorf("%w: webhook responded %s", errPermanent, response.Status)
+}
+
+type execHook struct {
+	logger *slog.Logger
+	config config.ExecHookConfig
+}
+
+func (hook *execHook) handle(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(hook.config.TimeoutSeconds)*time.Second)
+	defer cancel()
+	command := exec.CommandContext(ctx, hook.config.Command, hook.config.Args...)
+	command.Dir = hook.config.Directory
+	command.Env = append(os.Environ(),
+		EnvEventType+"="+string(event.Type),
+		EnvEventID+"="+event.ID,
+	)
+	command.Stdin = bytes.NewReader(body)
+	output, err := command.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("command.CombinedOutput: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	hook.logger.DebugContext(ctx, "ran an exec hook",
+		"command", hook.config.Command,
+		"eventID", event.ID,
+		"output", strings.TrimSpace(string(output)),
+	)
+	return nil
+}
@@ -0,0 +1,207 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook(t *testing.T) {
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	testEvent := Event{
+		ID:        "id",
+		Type:      TypeImagesDeleted,
+		CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Data:      ImagesDeleted{ImageIDs: []uint{1}},
+	}
+
+	testCases := []struct {
+		name         string
+		config       config.WebhookConfig
+		statusCodes  []int
+		wantRequests int
+		wantErr      bool
+	}{
+		{
+			name:         "signed",
+			config:       config.WebhookConfig{Secret: "secret", MaxRetries: 3},
+			statusCodes:  []int{http.StatusOK},
+			wantRequests: 1,
+		},
+		{
+			name:         "retry after server errors",
+			config:       config.WebhookConfig{MaxRetries: 3},
+			statusCodes:  []int{http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusNoContent},
+			wantRequests: 3,
+		},
+		{
+			name:         "give up after retries",
+			config:       config.WebhookConfig{MaxRetries: 1},
+			statusCodes:  []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusOK},
+			wantRequests: 2,
+			wantErr:      true,
+		},
+		{
+			name:         "no retry",
+			config:       config.WebhookConfig{MaxRetries: -1},
+			statusCodes:  []int{http.StatusBadGateway, http.StatusOK},
+			wantRequests: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "a client error isn't retried",
+			config:       config.WebhookConfig{MaxRetries: 3},
+			statusCodes:  []int{http.StatusBadRequest, http.StatusOK},
+			wantRequests: 1,
+			wantErr:      true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var mutex sync.Mutex
+			requests := make([]request, 0)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				mutex.Lock()
+				defer mutex.Unlock()
+				w.WriteHeader(tc.statusCodes[len(requests)])
+				requests = append(requests, request{header: r.Header, body: body})
+			}))
+			defer server.Close()
+
+			webhookConfig := tc.config
+			webhookConfig.URL = server.URL
+			webhookConfig.TimeoutSeconds = 5
+			webhook := newWebhook(newTestLogger(), webhookConfig)
+			webhook.initialBackoff = time.Millisecond
+
+			err := webhook.handle(context.Background(), testEvent)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			require.Len(t, requests, tc.wantRequests)
+
+			got := requests[0]
+			assert.Equal(t, "application/json", got.header.Get("Content-Type"))
+			assert.Equal(t, "images.deleted", got.header.Get(HeaderEvent))
+			assert.Equal(t, "id", got.header.Get(HeaderDelivery))
+			assert.JSONEq(t, `{
+				"id": "id",
+				"type": "images.deleted",
+				"createdAt": "2024-01-01T00:00:00Z",
+				"data": {"imageIds": [1], "images": null}
+			}`, string(got.body))
+			if tc.config.Secret == "" {
+				assert.Empty(t, got.header.Get(HeaderSignature))
+			} else {
+				assert.Equal(t, Sign(tc.config.Secret, got.body), got.header.Get(HeaderSignature))
+			}
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{"id":"id"}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t,
+		"sha256=efe1bbdc434e3e6a9121b20275e6aa7d1f16dfb4ad21592750f8ed0a6c9b446a",
+		Sign("secret", []byte(`{"id":"id"}`)),
+	)
+}
+
+func TestExecHook(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not found")
+	}
+	directory := t.TempDir()
+	hook := &execHook{
+		logger: newTestLogger(),
+		config: config.ExecHookConfig{
+			Command:        "sh",
+			Args:           []string{"-c", `cat > event.json && echo "$ANIME_IMAGE_VIEWER_EVENT $ANIME_IMAGE_VIEWER_EVENT_ID" > env.txt`},
+			Directory:      directory,
+			TimeoutSeconds: 10,
+		},
+	}
+	require.NoError(t, hook.handle(context.Background(), Event{
+		ID:   "id",
+		Type: TypeAnimeCreated,
+		Data: Anime{ID: 1, Name: "anime"},
+	}))
+
+	content, err := os.ReadFile(filepath.Join(directory, "event.json"))
+	require.NoError(t, err)
+	var got Event
+	require.NoError(t, json.Unmarshal(content, &got))
+	assert.Equal(t, TypeAnimeCreated, got.Type)
+	assert.Equal(t, map[string]any{"id": float64(1), "name": "anime"}, got.Data)
+	env, err := os.ReadFile(filepath.Join(directory, "env.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "anime.created id\n", string(env))
+
+	hook.config.Args = []string{"-c", "echo failed >&2; exit 1"}
+	err = hook.handle(context.Background(), Event{ID: "id", Type: TypeAnimeCreated})
+	assert.ErrorContains(t, err, "failed")
+}
+
+func TestBus_SubscribeHooks(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  config.HooksConfig
+		wantErr error
+	}{
+		{
+			name: "webhooks and exec hooks",
+			config: config.HooksConfig{
+				Webhooks: []config.WebhookConfig{{URL: "http://localhost", Events: []string{"images.imported"}}},
+				Exec:     []config.ExecHookConfig{{Command: "hook"}},
+			},
+		},
+		{
+			name:    "no url",
+			config:  config.HooksConfig{Webhooks: []config.WebhookConfig{{}}},
+			wantErr: xerrors.ErrInvalidArgument,
+		},
+		{
+			name:    "no command",
+			config:  config.HooksConfig{Exec: []config.ExecHookConfig{{}}},
+			wantErr: xerrors.ErrInvalidArgument,
+		},
+		{
+			name:    "unknown event",
+			config:  config.HooksConfig{Exec: []config.ExecHookConfig{{Command: "hook", Events: []string{"unknown"}}}},
+			wantErr: xerrors.ErrInvalidArgument,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bus := NewBus(newTestLogger())
+			err := bus.SubscribeHooks(tc.config)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, bus.subscribers, 2)
+			assert.Equal(t, []Type{TypeImagesImported}, bus.subscribers[0].types)
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	goimage "image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/michael-freling/anime-image-viewer/internal/tag"
+	"golang.org/x/image/draw"
+)
+
+const (
+	defaultBucketStep  = 64
+	captionJpegQuality = 95
+)
+
+// CaptionOptions configures CaptionFormat.
+type CaptionOptions struct {
+	// TriggerWords start every caption, e.g. the token a LoRA is trained to
+	// respond to.
+	TriggerWords []string
+	// CategoryOrder orders the tags of a caption by category. Tags of the
+	// other categories follow, ordered by category name.
+	CategoryOrder []string
+	// ExcludedTags are tag names left out of captions, compared
+	// case-insensitively.
+	ExcludedTags []string
+
+	// Resolution, when not zero, resizes and crops each image to the aspect
+	// ratio bucket of about Resolution x Resolution pixels, e.g. 1024 for
+	// SDXL. Images are never scaled up. A resized image keeps its format,
+	// except one Go cannot encode, which is written as PNG with a .png
+	// extension.
+	Resolution int
+	// BucketStep is what the sides of a bucket are multiples of. It defaults
+	// to 64.
+	BucketStep int
+}
+
+// CaptionFormat writes a kohya-style dataset to fine-tune diffusion models:
+// each image with a .txt caption of comma-separated words next to it. A
+// caption has the trigger words, the character names, the anime name and
+// then the tags.
+type CaptionFormat struct {
+	options       CaptionOptions
+	excludedTags  map[string]struct{}
+	categoryRanks map[string]int
+}
+
+func NewCaptionFormat(options CaptionOptions) *CaptionFormat {
+	if options.BucketStep == 0 {
+		options.BucketStep = defaultBucketStep
+	}
+	excludedTags := make(map[string]struct{}, len(options.ExcludedTags))
+	for _, name := range options.ExcludedTags {
+		excludedTags[strings.ToLower(strings.TrimSpace(name))] = struct{}{}
+	}
+	categoryRanks := make(map[string]int, len(options.CategoryOrder))
+	for rank, category := range options.CategoryOrder {
+		if _, ok := categoryRanks[category]; !ok {
+			categoryRanks[category] = rank
+		}
+	}
+	return &CaptionFormat{
+		options:       options,
+		excludedTags:  excludedTags,
+		categoryRanks: categoryRanks,
+	}
+}
+
+// resizedImageExtensions are the extensions of the images which keep their
+// format when they are resized.
+var resizedImageExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+}
+
+// exportFileName names an image which is resized into PNG with a .png
+// extension, so that its name matches its content.
+func (format *CaptionFormat) exportFileName(imageFile image.ImageFile) string {
+	fileName := exportFileName(imageFile)
+	extension := filepath.Ext(fileName)
+	if format.options.Resolution == 0 || resizedImageExtensions[extension] {
+		return fileName
+	}
+	return strings.TrimSuffix(fileName, extension) + ".png"
+}
+
+func captionFileName(fileName string) string {
+	return strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ".txt"
+}
+
+// Caption returns the caption of an image.
+func (format *CaptionFormat) Caption(exportedImage ExportedImage) string {
+	tags := make([]tag.Tag, 0, len(exportedImage.Tags))
+	for _, t := range exportedImage.Tags {
+		if _, ok := format.excludedTags[strings.ToLower(t.Name)]; ok {
+			continue
+		}
+		tags = append(tags, t)
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		rankI, okI := format.categoryRanks[tags[i].Category]
+		rankJ, okJ := format.categoryRanks[tags[j].Category]
+		if okI != okJ {
+			return okI
+		}
+		if okI && rankI != rankJ {
+			return rankI < rankJ
+		}
+		if tags[i].Category != tags[j].Category {
+			return tags[i].Category < tags[j].Category
+		}
+		return tags[i].Name < tags[j].Name
+	})
+
+	words := make([]string, 0, len(format.options.TriggerWords)+len(exportedImage.Characters)+1+len(tags))
+	words = append(words, format.options.TriggerWords...)
+	for _, character := range exportedImage.Characters {
+		words = append(words, character.Name)
+	}
+	words = append(words, exportedImage.Metadata.Anime)
+	for _, t := range tags {
+		words = append(words, t.Name)
+	}
+
+	// A name can be a character, an anime and a tag at once, and a comma
+	// would split a name into two words.
+	seen := make(map[string]struct{}, len(words))
+	result := make([]string, 0, len(words))
+	for _, word := range words {
+		word = strings.Join(strings.Fields(strings.ReplaceAll(word, ",", " ")), " ")
+		if word == "" {
+			continue
+		}
+		key := strings.ToLower(word)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		result = append(result, word)
+	}
+	return strings.Join(result, ", ")
+}
+
+func (format *CaptionFormat) CopyImage(splitDirectory string, exportedImage ExportedImage) error {
+	destinationFilePath := filepath.Join(splitDirectory, exportedImage.Metadata.FileName)
+	if format.options.Resolution == 0 {
+		if _, err := image.Copy(exportedImage.ImageFile.LocalFilePath, destinationFilePath); err != nil {
+			return fmt.Errorf("copy: %w", err)
+		}
+		return nil
+	}
+	if err := format.resizeToBucket(exportedImage.ImageFile.LocalFilePath, destinationFilePath); err != nil {
+		return fmt.Errorf("resizeToBucket: %w", err)
+	}
+	return nil
+}
+
+func (format *CaptionFormat) RemoveImage(splitDirectory string, fileName string) error {
+	if err := removeIfExists(filepath.Join(splitDirectory, fileName)); err != nil {
+		return err
+	}
+	return removeIfExists(filepath.Join(splitDirectory, captionFileName(fileName)))
+}
+
+func (format *CaptionFormat) WriteLabels(splitDirectory string, exportedImages []ExportedImage) error {
+	for _, exportedImage := range exportedImages {
+		captionFilePath := filepath.Join(splitDirectory, captionFileName(exportedImage.Metadata.FileName))
+		if err := os.WriteFile(captionFilePath, []byte(format.Caption(exportedImage)), 0644); err != nil {
+			return fmt.Errorf("os.WriteFile: %w", err)
+		}
+	}
+	return nil
+}
+
+// bucketSize returns the size of the bucket for an image: the same aspect
+// ratio, as closely as sides that are multiples of step allow, and at most
+// resolution x resolution pixels.
+func bucketSize(width int, height int, resolution int, step int) (int, int) {
+	scale := math.Min(1, math.Sqrt(float64(resolution*resolution)/float64(width*height)))
+	bucketWidth := int(float64(width)*scale) / step * step
+	bucketHeight := int(float64(height)*scale) / step * step
+	return max(bucketWidth, step), max(bucketHeight, step)
+}
+
+// resizeToBucket scales an image to cover its bucket and crops the center,
+// and writes it in the format of the extension of destinationFilePath.
+func (format *CaptionFormat) resizeToBucket(sourceFilePath string, destinationFilePath string) error {
+	sourceFile, err := os.Open(sourceFilePath)
+	if err != nil {
+		return fmt.Errorf("os.Open: %w", err)
+	}
+	defer sourceFile.Close()
+	sourceImage, _, err := goimage.Decode(bufio.NewReader(sourceFile))
+	if err != nil {
+		return fmt.Errorf("image.Decode: %w", err)
+	}
+
+	bounds := sourceImage.Bounds()
+	width, height := bucketSize(bounds.Dx(), bounds.Dy(), format.options.Resolution, format.options.BucketStep)
+	cropRect := bounds
+	if bounds.Dx()*height > bounds.Dy()*width {
+		cropWidth := bounds.Dy() * width / height
+		cropRect.Min.X += (bounds.Dx() - cropWidth) / 2
+		cropRect.Max.X = cropRect.Min.X + cropWidth
+	} else {
+		cropHeight := bounds.Dx() * height / width
+		cropRect.Min.Y += (bounds.Dy() - cropHeight) / 2
+		cropRect.Max.Y = cropRect.Min.Y + cropHeight
+	}
+	destinationImage := goimage.NewRGBA(goimage.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(destinationImage, destinationImage.Rect, sourceImage, cropRect, draw.Src, nil)
+
+	destinationFile, err := os.Create(destinationFilePath)
+	if err != nil {
+		return fmt.Errorf("os.Create: %w", err)
+	}
+	defer destinationFile.Close()
+	buffer := bufio.NewWriter(destinationFile)
+	// the image is written in the format of its name, which is PNG for one
+	// Go has no encoder for, e.g. WebP
+	switch strings.ToLower(filepath.Ext(destinationFilePath)) {
+	case ".jpg", ".jpeg":
+		err = jpeg.Encode(buffer, destinationImage, &jpeg.Options{Quality: captionJpegQuality})
+	case ".gif":
+		err = gif.Encode(buffer, destinationImage, nil)
+	default:
+		err = png.Encode(buffer, destinationImage)
+	}
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	if err := buffer.Flush(); err != nil {
+		return fmt.Errorf("buffer.Flush: %w", err)
+	}
+	return destinationFile.Close()
+}
@@ -0,0 +1,245 @@
+package export
+
+import (
+	"context"
+	goimage "image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/michael-freling/anime-image-viewer/internal/tag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/bmp"
+)
+
+func TestCaptionFormat_Caption(t *testing.T) {
+	exportedImage := ExportedImage{
+		Metadata: Metadata{Anime: "Frieren"},
+		Tags: []tag.Tag{
+			{ID: 1, Name: "night", Category: "scene"},
+			{ID: 2, Name: "smile", Category: "expression"},
+			{ID: 3, Name: "sky", Category: "scene"},
+			{ID: 4, Name: "masterpiece", Category: "quality"},
+			{ID: 5, Name: "Frieren", Category: "character"},
+			{ID: 6, Name: "staff, wooden", Category: "item"},
+		},
+		Characters: []Character{
+			{ID: 1, Name: "Frieren"},
+			{ID: 2, Name: "Fern"},
+		},
+	}
+
+	testCases := []struct {
+		name    string
+		options CaptionOptions
+		want    string
+	}{
+		{
+			name: "tags are ordered by category name by default",
+			want: "Frieren, Fern, smile, staff wooden, masterpiece, night, sky",
+		},
+		{
+			name: "trigger words, category order and excluded tags",
+			options: CaptionOptions{
+				TriggerWords:  []string{"frrn style"},
+				CategoryOrder: []string{"quality", "scene"},
+				ExcludedTags:  []string{"Smile"},
+			},
+			want: "frrn style, Frieren, Fern, masterpiece, night, sky, staff wooden",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := NewCaptionFormat(tc.options).Caption(exportedImage)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestBucketSize(t *testing.T) {
+	testCases := []struct {
+		name       string
+		width      int
+		height     int
+		wantWidth  int
+		wantHeight int
+	}{
+		{name: "square", width: 2048, height: 2048, wantWidth: 1024, wantHeight: 1024},
+		{name: "16:9", width: 1920, height: 1080, wantWidth: 1344, wantHeight: 768},
+		{name: "portrait", width: 1080, height: 1920, wantWidth: 768, wantHeight: 1344},
+		{name: "a small image is not scaled up", width: 500, height: 300, wantWidth: 448, wantHeight: 256},
+		{name: "a side shorter than a step", width: 1000, height: 10, wantWidth: 960, wantHeight: 64},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotWidth, gotHeight := bucketSize(tc.width, tc.height, 1024, 64)
+			assert.Equal(t, tc.wantWidth, gotWidth)
+			assert.Equal(t, tc.wantHeight, gotHeight)
+		})
+	}
+}
+
+func TestCaptionFormat_resizeToBucket(t *testing.T) {
+	sourceImage := goimage.NewRGBA(goimage.Rect(0, 0, 32, 32))
+	for x := range 32 {
+		for y := range 32 {
+			sourceImage.Set(x, y, color.RGBA{R: uint8(x * 8), G: uint8(y * 8), A: 255})
+		}
+	}
+
+	testCases := []struct {
+		name       string
+		sourceName string
+		encode     func(io.Writer, goimage.Image) error
+
+		wantFileName string
+		wantFormat   string
+	}{
+		{
+			name:       "jpeg",
+			sourceName: "image.JPG",
+			encode: func(writer io.Writer, img goimage.Image) error {
+				return jpeg.Encode(writer, img, nil)
+			},
+			wantFileName: "12.jpg",
+			wantFormat:   "jpeg",
+		},
+		{
+			name:         "png",
+			sourceName:   "image.png",
+			encode:       png.Encode,
+			wantFileName: "12.png",
+			wantFormat:   "png",
+		},
+		{
+			name:       "gif",
+			sourceName: "image.gif",
+			encode: func(writer io.Writer, img goimage.Image) error {
+				return gif.Encode(writer, img, nil)
+			},
+			wantFileName: "12.gif",
+			wantFormat:   "gif",
+		},
+		{
+			name:         "a format without an encoder is written as png with its extension",
+			sourceName:   "image.bmp",
+			encode:       bmp.Encode,
+			wantFileName: "12.png",
+			wantFormat:   "png",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			directory := t.TempDir()
+			sourceFilePath := filepath.Join(directory, tc.sourceName)
+			sourceFile, err := os.Create(sourceFilePath)
+			require.NoError(t, err)
+			require.NoError(t, tc.encode(sourceFile, sourceImage))
+			require.NoError(t, sourceFile.Close())
+
+			format := NewCaptionFormat(CaptionOptions{Resolution: 16, BucketStep: 8})
+			fileName := format.exportFileName(image.ImageFile{ID: 12, Name: tc.sourceName})
+			assert.Equal(t, tc.wantFileName, fileName)
+			splitDirectory := filepath.Join(directory, "train")
+			require.NoError(t, os.Mkdir(splitDirectory, 0755))
+			require.NoError(t, format.CopyImage(splitDirectory, ExportedImage{
+				ImageFile: image.ImageFile{ID: 12, Name: tc.sourceName, LocalFilePath: sourceFilePath},
+				Metadata:  Metadata{FileName: fileName},
+			}))
+
+			destinationFile, err := os.Open(filepath.Join(splitDirectory, tc.wantFileName))
+			require.NoError(t, err)
+			defer destinationFile.Close()
+			config, gotFormat, err := goimage.DecodeConfig(destinationFile)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantFormat, gotFormat)
+			assert.Equal(t, []int{16, 16}, []int{config.Width, config.Height})
+		})
+	}
+
+	t.Run("an image which isn't resized keeps its name", func(t *testing.T) {
+		format := NewCaptionFormat(CaptionOptions{})
+		assert.Equal(t, "12.bmp", format.exportFileName(image.ImageFile{ID: 12, Name: "image.bmp"}))
+	})
+}
+
+func TestBatchImageExporter_Export_CaptionFormat(t *testing.T) {
+	tester := newTester(t)
+	fileCreator := tester.newFileCreator(t)
+	fileCreator.CreateDirectory(image.Directory{ID: 1, Name: "Frieren"})
+	fileCreator.CreateImage(image.ImageFile{ID: 11, Name: "a.jpg", ParentID: 1}, image.TestImageFileJpeg)
+	fileCreator.CreateImage(image.ImageFile{ID: 12, Name: "b.jpg", ParentID: 1}, image.TestImageFileJpeg)
+
+	animeID := uint(1)
+	animeDirectory := fileCreator.BuildDBDirectory(1)
+	animeDirectory.AnimeID = &animeID
+	tester.dbClient.Truncate(t, &db.File{}, &db.Tag{}, &db.FileTag{}, &db.Anime{}, &db.Character{}, &db.FileCharacter{})
+	db.LoadTestData(t, tester.dbClient, []db.File{
+		animeDirectory,
+		fileCreator.BuildDBImageFile(11),
+		fileCreator.BuildDBImageFile(12),
+	})
+	db.LoadTestData(t, tester.dbClient, []db.Anime{{ID: 1, Name: "Frieren: Beyond Journey's End"}})
+	db.LoadTestData(t, tester.dbClient, []db.Tag{
+		{ID: 1, Name: "smile", Category: "expression"},
+		{ID: 2, Name: "night", Category: "scene"},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.FileTag{
+		{FileID: 11, TagID: 1},
+		{FileID: 11, TagID: 2},
+		{FileID: 12, TagID: 2},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.Character{{ID: 1, Name: "Fern", AnimeID: 1}})
+	db.LoadTestData(t, tester.dbClient, []db.FileCharacter{{FileID: 11, CharacterID: 1}})
+
+	exportDirectory := t.TempDir()
+	export := func() {
+		batchExporter := tester.getBatchImageExporter(BatchImageExporterOptions{
+			Incremental: true,
+			Format: NewCaptionFormat(CaptionOptions{
+				TriggerWords:  []string{"frrn"},
+				CategoryOrder: []string{"scene"},
+				Resolution:    16,
+				BucketStep:    8,
+			}),
+			progressSleepDuration: time.Millisecond,
+		})
+		require.NoError(t, batchExporter.Export(context.Background(), exportDirectory))
+	}
+	export()
+
+	trainDirectory := filepath.Join(exportDirectory, "train")
+	caption, err := os.ReadFile(filepath.Join(trainDirectory, "11.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "frrn, Fern, Frieren: Beyond Journey's End, night, smile", string(caption))
+	caption, err = os.ReadFile(filepath.Join(trainDirectory, "12.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "frrn, Frieren: Beyond Journey's End, night", string(caption))
+	assert.NoFileExists(t, filepath.Join(trainDirectory, "metadata.jsonl"))
+
+	imageFile, err := os.Open(filepath.Join(trainDirectory, "11.jpg"))
+	require.NoError(t, err)
+	defer imageFile.Close()
+	config, _, err := goimage.DecodeConfig(imageFile)
+	require.NoError(t, err)
+	assert.Equal(t, []int{16, 16}, []int{config.Width, config.Height}, "the 32x32 image is scaled down")
+
+	// Untagging an image removes its caption with it.
+	tester.dbClient.Truncate(t, &db.FileTag{})
+	db.LoadTestData(t, tester.dbClient, []db.FileTag{{FileID: 11, TagID: 1}})
+	export()
+	assert.NoFileExists(t, filepath.Join(trainDirectory, "12.jpg"))
+	assert.NoFileExists(t, filepath.Join(trainDirectory, "12.txt"))
+	caption, err = os.ReadFile(filepath.Join(trainDirectory, "11.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "frrn, Fern, Frieren: Beyond Journey's End, smile", string(caption))
+}
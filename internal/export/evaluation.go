@@ -0,0 +1,318 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/tag"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+)
+
+// curveThresholds are the thresholds of the precision and recall curves
+var curveThresholds = []float64{0.05, 0.1, 0.15, 0.2, 0.25, 0.3, 0.35, 0.4, 0.45, 0.5, 0.55, 0.6, 0.65, 0.7, 0.75, 0.8, 0.85, 0.9, 0.95}
+
+// SuggestionEvaluator measures a tag suggestion model against the tags users
+// added to held-out images.
+type SuggestionEvaluator struct {
+	logger            *slog.Logger
+	dbClient          *db.Client
+	exporter          *BatchImageExporter
+	suggestionService *tag.SuggestionService
+	options           SuggestionEvaluatorOptions
+}
+
+type SuggestionEvaluatorOptions struct {
+	// IsDirectoryTagExcluded, Split and Query select the images in the same
+	// way as BatchImageExporterOptions, so that the split exported to train
+	// a model can be held out.
+	IsDirectoryTagExcluded bool
+	Split                  SplitOptions
+	Query                  Query
+	// EvaluatedSplit is the split to evaluate, e.g. test. Every selected
+	// image is evaluated if it's empty.
+	EvaluatedSplit string
+
+	// Threshold is the score at which a tag is suggested
+	Threshold float64
+	// BatchSize is the number of images sent to the plugin at once
+	BatchSize int
+}
+
+func NewSuggestionEvaluator(
+	logger *slog.Logger,
+	conf config.Config,
+	dbClient *db.Client,
+	suggestionService *tag.SuggestionService,
+	options SuggestionEvaluatorOptions,
+) *SuggestionEvaluator {
+	return &SuggestionEvaluator{
+		logger:   logger,
+		dbClient: dbClient,
+		exporter: NewBatchImageExporter(logger, conf, dbClient, BatchImageExporterOptions{
+			IsDirectoryTagExcluded: options.IsDirectoryTagExcluded,
+			Split:                  options.Split,
+			Query:                  options.Query,
+		}),
+		suggestionService: suggestionService,
+		options:           options,
+	}
+}
+
+// ThresholdMetrics are the metrics of the tags suggested at a threshold
+type ThresholdMetrics struct {
+	Threshold      float64 `json:"threshold"`
+	TruePositives  int     `json:"true_positives"`
+	FalsePositives int     `json:"false_positives"`
+	FalseNegatives int     `json:"false_negatives"`
+	Precision      float64 `json:"precision"`
+	Recall         float64 `json:"recall"`
+	F1             float64 `json:"f1"`
+}
+
+func newThresholdMetrics(threshold float64, truePositives int, falsePositives int, falseNegatives int) ThresholdMetrics {
+	metrics := ThresholdMetrics{
+		Threshold:      threshold,
+		TruePositives:  truePositives,
+		FalsePositives: falsePositives,
+		FalseNegatives: falseNegatives,
+	}
+	if truePositives+falsePositives > 0 {
+		metrics.Precision = float64(truePositives) / float64(truePositives+falsePositives)
+	}
+	if truePositives+falseNegatives > 0 {
+		metrics.Recall = float64(truePositives) / float64(truePositives+falseNegatives)
+	}
+	if metrics.Precision+metrics.Recall > 0 {
+		metrics.F1 = 2 * metrics.Precision * metrics.Recall / (metrics.Precision + metrics.Recall)
+	}
+	return metrics
+}
+
+// TagEvaluation is how well a tag is suggested
+type TagEvaluation struct {
+	TagID   uint   `json:"tag_id"`
+	TagName string `json:"tag_name"`
+	// Support is the number of the evaluated images with the tag
+	Support int `json:"support"`
+	ThresholdMetrics
+	AveragePrecision float64            `json:"average_precision"`
+	Curve            []ThresholdMetrics `json:"curve"`
+}
+
+// SuggestionEvaluation is the result of an evaluation. Metrics are at the
+// threshold of the options, except for the curves.
+type SuggestionEvaluation struct {
+	Model tag.SuggestionModel `json:"model"`
+	// ImageCount is the number of the evaluated images. Images without a tag
+	// added by a user aren't evaluated.
+	ImageCount int `json:"image_count"`
+	// Micro is the metrics of all tags together
+	Micro ThresholdMetrics `json:"micro"`
+	// MeanAveragePrecision is the mean of the average precisions of the tags
+	// with support
+	MeanAveragePrecision float64            `json:"mean_average_precision"`
+	Curve                []ThresholdMetrics `json:"curve"`
+	Tags                 []TagEvaluation    `json:"tags"`
+}
+
+// Evaluate asks the plugin for the tags of the held-out images, and compares
+// them with the tags users added.
+func (evaluator SuggestionEvaluator) Evaluate(ctx context.Context) (SuggestionEvaluation, error) {
+	if evaluator.options.BatchSize <= 0 {
+		return SuggestionEvaluation{}, fmt.Errorf("%w: batch size must be positive: %d", xerrors.ErrInvalidArgument, evaluator.options.BatchSize)
+	}
+
+	imageFiles, err := evaluator.exporter.SelectImages(ctx, evaluator.options.EvaluatedSplit)
+	if err != nil {
+		return SuggestionEvaluation{}, fmt.Errorf("exporter.SelectImages: %w", err)
+	}
+	imageFileIDs := make([]uint, len(imageFiles))
+	for index, imageFile := range imageFiles {
+		imageFileIDs[index] = imageFile.ID
+	}
+
+	fileTags, err := evaluator.dbClient.FileTag().FindAllByFileID(imageFileIDs)
+	if err != nil {
+		return SuggestionEvaluation{}, fmt.Errorf("FileTag.FindAllByFileID: %w", err)
+	}
+	truths := make(map[uint]map[uint]struct{})
+	for _, fileTag := range fileTags {
+		if fileTag.AddedBy != db.FileTagAddedByUser {
+			continue
+		}
+		if _, ok := truths[fileTag.FileID]; !ok {
+			truths[fileTag.FileID] = make(map[uint]struct{})
+		}
+		truths[fileTag.FileID][fileTag.TagID] = struct{}{}
+	}
+	labelledIDs := make([]uint, 0, len(truths))
+	for _, imageFileID := range imageFileIDs {
+		if _, ok := truths[imageFileID]; ok {
+			labelledIDs = append(labelledIDs, imageFileID)
+		}
+	}
+	evaluator.logger.InfoContext(ctx, "Selected images to evaluate",
+		"images", len(labelledIDs),
+		"unlabelled", len(imageFileIDs)-len(labelledIDs),
+	)
+
+	scores := make(map[uint]map[uint]float64, len(labelledIDs))
+	allTags := make(map[uint]tag.Tag)
+	var model tag.SuggestionModel
+	for start := 0; start < len(labelledIDs); start += evaluator.options.BatchSize {
+		batch := labelledIDs[start:min(start+evaluator.options.BatchSize, len(labelledIDs))]
+		response, err := evaluator.suggestionService.SuggestTags(ctx, batch)
+		if err != nil {
+			return SuggestionEvaluation{}, fmt.Errorf("suggestionService.SuggestTags: %w", err)
+		}
+		if len(response.Suggestions) == 0 {
+			return SuggestionEvaluation{}, fmt.Errorf("the plugin didn't suggest tags for the images: %v", batch)
+		}
+		for _, imageFileID := range batch {
+			scores[imageFileID] = make(map[uint]float64, len(response.Suggestions[imageFileID]))
+			for _, suggestion := range response.Suggestions[imageFileID] {
+				scores[imageFileID][suggestion.TagID] = suggestion.Score
+			}
+		}
+		for tagID, t := range response.AllTags {
+			allTags[tagID] = t
+		}
+		model = response.Model
+		evaluator.logger.InfoContext(ctx, "Evaluation is in progress",
+			"completed", start+len(batch),
+			"total", len(labelledIDs),
+		)
+	}
+
+	evaluation := evaluateSuggestions(scores, truths, allTags, evaluator.options.Threshold)
+	evaluation.Model = model
+	return evaluation, nil
+}
+
+// evaluateSuggestions compares the scores of tags with the true tags, both
+// by image file ID. Every image in scores is evaluated.
+func evaluateSuggestions(
+	scores map[uint]map[uint]float64,
+	truths map[uint]map[uint]struct{},
+	allTags map[uint]tag.Tag,
+	threshold float64,
+) SuggestionEvaluation {
+	type scoredImage struct {
+		imageFileID uint
+		score       float64
+		hasTag      bool
+	}
+	tagImages := make(map[uint][]scoredImage)
+	supports := make(map[uint]int)
+	for imageFileID, imageScores := range scores {
+		for tagID, score := range imageScores {
+			_, hasTag := truths[imageFileID][tagID]
+			tagImages[tagID] = append(tagImages[tagID], scoredImage{
+				imageFileID: imageFileID,
+				score:       score,
+				hasTag:      hasTag,
+			})
+		}
+		for tagID := range truths[imageFileID] {
+			supports[tagID]++
+		}
+	}
+
+	// an image which the plugin didn't score for a tag is never suggested it
+	countAt := func(images []scoredImage, support int, threshold float64) ThresholdMetrics {
+		truePositives, falsePositives := 0, 0
+		for _, scored := range images {
+			if scored.score < threshold {
+				continue
+			}
+			if scored.hasTag {
+				truePositives++
+			} else {
+				falsePositives++
+			}
+		}
+		return newThresholdMetrics(threshold, truePositives, falsePositives, support-truePositives)
+	}
+
+	tagEvaluations := make([]TagEvaluation, 0)
+	var averagePrecisionSum float64
+	var supportedTagCount int
+	for tagID, t := range allTags {
+		images := tagImages[tagID]
+		support := supports[tagID]
+		// a tag neither on an image nor suggested at any threshold isn't
+		// reported
+		if support == 0 && countAt(images, 0, min(threshold, curveThresholds[0])).FalsePositives == 0 {
+			continue
+		}
+
+		sort.Slice(images, func(i, j int) bool {
+			if images[i].score != images[j].score {
+				return images[i].score > images[j].score
+			}
+			return images[i].imageFileID < images[j].imageFileID
+		})
+		evaluation := TagEvaluation{
+			TagID:            tagID,
+			TagName:          t.Name,
+			Support:          support,
+			ThresholdMetrics: countAt(images, support, threshold),
+			Curve:            make([]ThresholdMetrics, len(curveThresholds)),
+		}
+		if support > 0 {
+			hitCount := 0
+			var precisionSum float64
+			for rank, scored := range images {
+				if !scored.hasTag {
+					continue
+				}
+				hitCount++
+				precisionSum += float64(hitCount) / float64(rank+1)
+			}
+			evaluation.AveragePrecision = precisionSum / float64(support)
+			averagePrecisionSum += evaluation.AveragePrecision
+			supportedTagCount++
+		}
+		for index, curveThreshold := range curveThresholds {
+			evaluation.Curve[index] = countAt(images, support, curveThreshold)
+		}
+		tagEvaluations = append(tagEvaluations, evaluation)
+	}
+	sort.Slice(tagEvaluations, func(i, j int) bool {
+		if tagEvaluations[i].Support != tagEvaluations[j].Support {
+			return tagEvaluations[i].Support > tagEvaluations[j].Support
+		}
+		return tagEvaluations[i].TagName < tagEvaluations[j].TagName
+	})
+
+	result := SuggestionEvaluation{
+		ImageCount: len(scores),
+		Micro:      sumThresholdMetrics(threshold, tagEvaluations, func(evaluation TagEvaluation) ThresholdMetrics { return evaluation.ThresholdMetrics }),
+		Curve:      make([]ThresholdMetrics, len(curveThresholds)),
+		Tags:       tagEvaluations,
+	}
+	if supportedTagCount > 0 {
+		result.MeanAveragePrecision = averagePrecisionSum / float64(supportedTagCount)
+	}
+	for index, curveThreshold := range curveThresholds {
+		result.Curve[index] = sumThresholdMetrics(curveThreshold, tagEvaluations, func(evaluation TagEvaluation) ThresholdMetrics {
+			return evaluation.Curve[index]
+		})
+	}
+	return result
+}
+
+func sumThresholdMetrics(threshold float64, tagEvaluations []TagEvaluation, getMetrics func(TagEvaluation) ThresholdMetrics) ThresholdMetrics {
+	truePositives, falsePositives, falseNegatives := 0, 0, 0
+	for _, evaluation := range tagEvaluations {
+		metrics := getMetrics(evaluation)
+		truePositives += metrics.TruePositives
+		falsePositives += metrics.FalsePositives
+		falseNegatives += metrics.FalseNegatives
+	}
+	return newThresholdMetrics(threshold, truePositives, falsePositives, falseNegatives)
+}
@@ -0,0 +1,153 @@
+package export
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/michael-freling/anime-image-viewer/internal/tag"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+	tag_suggestionv1 "github.com/michael-freling/anime-image-viewer/plugins/plugins-protos/gen/go/tag_suggestion/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
+)
+
+func TestSuggestionEvaluator_Evaluate(t *testing.T) {
+	tester := newTester(t)
+	fileCreator := tester.newFileCreator(t).
+		CreateDirectory(image.Directory{ID: 1, Name: "dir1"}).
+		CreateImage(image.ImageFile{ID: 11, Name: "image11.jpg", ParentID: 1}, image.TestImageFileJpeg).
+		CreateImage(image.ImageFile{ID: 12, Name: "image12.jpg", ParentID: 1}, image.TestImageFileJpeg).
+		CreateImage(image.ImageFile{ID: 13, Name: "image13.jpg", ParentID: 1}, image.TestImageFileJpeg)
+
+	tester.dbClient.Truncate(t, db.File{}, db.Tag{}, db.FileTag{})
+	db.LoadTestData(t, tester.dbClient, []db.File{
+		fileCreator.BuildDBDirectory(1),
+		fileCreator.BuildDBImageFile(11),
+		fileCreator.BuildDBImageFile(12),
+		fileCreator.BuildDBImageFile(13),
+	})
+	db.LoadTestData(t, tester.dbClient, []db.Tag{
+		{ID: 1, Name: "tag1"},
+		{ID: 2, Name: "tag2"},
+		{ID: 3, Name: "tag3"},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.FileTag{
+		{FileID: 11, TagID: 1, AddedBy: db.FileTagAddedByUser},
+		{FileID: 11, TagID: 2, AddedBy: db.FileTagAddedByUser},
+		{FileID: 12, TagID: 1, AddedBy: db.FileTagAddedByUser},
+		// tags which were not added by a user are not the truth
+		{FileID: 12, TagID: 3, AddedBy: db.FileTagAddedBySuggestion},
+		{FileID: 13, TagID: 3, AddedBy: db.FileTagAddedByImport},
+	})
+
+	scores := map[string][]*tag_suggestionv1.SuggestionScore{
+		"image11.jpg": {
+			{TagId: 1, Score: 0.9},
+			{TagId: 3, Score: 0.6},
+			{TagId: 2, Score: 0.3},
+		},
+		"image12.jpg": {
+			{TagId: 2, Score: 0.7},
+			{TagId: 1, Score: 0.4},
+		},
+	}
+	newEvaluator := func(t *testing.T, options SuggestionEvaluatorOptions) *SuggestionEvaluator {
+		mockClient := tag_suggestionv1.NewMockTagSuggestionServiceClient(gomock.NewController(t))
+		mockClient.EXPECT().
+			Suggest(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, request *tag_suggestionv1.SuggestRequest, _ ...grpc.CallOption) (*tag_suggestionv1.SuggestResponse, error) {
+				response := &tag_suggestionv1.SuggestResponse{}
+				for _, imageURL := range request.ImageUrls {
+					response.Suggestions = append(response.Suggestions, &tag_suggestionv1.Suggestion{
+						ImageUrl: imageURL,
+						Scores:   scores[filepath.Base(imageURL)],
+					})
+				}
+				return response, nil
+			}).
+			AnyTimes()
+
+		conf := config.Config{
+			ImageRootDirectory: tester.imageRootDirectory,
+		}
+		directoryReader := tester.getDirectoryReader()
+		suggestionService := tag.NewSuggestionService(
+			tester.dbClient.Client,
+			mockClient,
+			tester.getTagReader(),
+			image.NewReader(tester.dbClient.Client, directoryReader, image.NewImageFileConverter(conf)),
+		)
+		return NewSuggestionEvaluator(
+			slog.New(slog.NewTextHandler(io.Discard, nil)),
+			conf,
+			tester.dbClient.Client,
+			suggestionService,
+			options,
+		)
+	}
+
+	t.Run("evaluate every image", func(t *testing.T) {
+		evaluator := newEvaluator(t, SuggestionEvaluatorOptions{
+			IsDirectoryTagExcluded: true,
+			Threshold:              0.5,
+			BatchSize:              1,
+		})
+		got, err := evaluator.Evaluate(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, got.ImageCount)
+		assert.InDelta(t, 0.75, got.MeanAveragePrecision, 0.001)
+		assert.Equal(t, 1, got.Micro.TruePositives)
+		assert.Equal(t, 2, got.Micro.FalsePositives)
+		assert.Equal(t, 2, got.Micro.FalseNegatives)
+		assert.InDelta(t, 1.0/3, got.Micro.Precision, 0.001)
+		assert.InDelta(t, 1.0/3, got.Micro.Recall, 0.001)
+
+		require.Len(t, got.Tags, 3)
+		tag1 := got.Tags[0]
+		assert.Equal(t, "tag1", tag1.TagName)
+		assert.Equal(t, 2, tag1.Support)
+		assert.InDelta(t, 1.0, tag1.Precision, 0.001)
+		assert.InDelta(t, 0.5, tag1.Recall, 0.001)
+		assert.InDelta(t, 2.0/3, tag1.F1, 0.001)
+		assert.InDelta(t, 1.0, tag1.AveragePrecision, 0.001)
+
+		tag2 := got.Tags[1]
+		assert.Equal(t, "tag2", tag2.TagName)
+		assert.Equal(t, 1, tag2.Support)
+		assert.Equal(t, 1, tag2.FalsePositives)
+		assert.Equal(t, 0.0, tag2.F1)
+		assert.InDelta(t, 0.5, tag2.AveragePrecision, 0.001)
+
+		tag3 := got.Tags[2]
+		assert.Equal(t, "tag3", tag3.TagName)
+		assert.Equal(t, 0, tag3.Support)
+		assert.Equal(t, 1, tag3.FalsePositives)
+
+		require.Len(t, got.Curve, len(curveThresholds))
+		assert.Equal(t, 3, got.Curve[0].TruePositives)
+		assert.Equal(t, 2, got.Curve[0].FalsePositives)
+		assert.Equal(t, 0, got.Curve[0].FalseNegatives)
+		assert.InDelta(t, 0.75, got.Curve[0].F1, 0.001)
+		assert.Equal(t, got.Micro, got.Curve[9])
+	})
+
+	t.Run("a split without a ratio", func(t *testing.T) {
+		evaluator := newEvaluator(t, SuggestionEvaluatorOptions{
+			IsDirectoryTagExcluded: true,
+			EvaluatedSplit:         "test",
+			Threshold:              0.5,
+			BatchSize:              1,
+		})
+		_, err := evaluator.Evaluate(context.Background())
+		assert.ErrorIs(t, err, xerrors.ErrInvalidArgument)
+	})
+}
@@ -1,13 +1,16 @@
 package export
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -15,6 +18,8 @@ import (
 	"github.com/michael-freling/anime-image-viewer/internal/db"
 	"github.com/michael-freling/anime-image-viewer/internal/image"
 	"github.com/michael-freling/anime-image-viewer/internal/tag"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+	"github.com/michael-freling/anime-image-viewer/internal/xslices"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -28,7 +33,23 @@ type BatchImageExporter struct {
 
 type BatchImageExporterOptions struct {
 	IsDirectoryTagExcluded bool
-	progressSleepDuration  time.Duration
+	Split                  SplitOptions
+	LabelFormat            LabelFormat
+
+	// Query selects the images to export. The zero value exports every
+	// tagged image.
+	Query Query
+
+	// Format is the layout of the export. It defaults to HuggingFaceFormat.
+	Format Format
+
+	// Incremental exports into a directory exported to before, copying only
+	// new and changed images and removing the images no longer exported.
+	// Labels are always written again, but images copied by a Format with
+	// other options, e.g. another resolution, are not.
+	Incremental bool
+
+	progressSleepDuration time.Duration
 }
 
 func NewBatchImageExporter(logger *slog.Logger, conf config.Config, dbClient *db.Client, options BatchImageExporterOptions) *BatchImageExporter {
@@ -53,8 +74,26 @@ func NewBatchImageExporter(logger *slog.Logger, conf config.Config, dbClient *db
 // This is compatible with transformers' metadata
 // See https://huggingface.co/docs/datasets/video_dataset
 type Metadata struct {
-	FileName string    `json:"file_name"`
-	Tags     []float64 `json:"tags"`
+	FileName string `json:"file_name"`
+	// OriginalPath is the path of the image relative to the image root
+	// directory, since FileName is derived from its ID.
+	OriginalPath string `json:"original_path"`
+
+	// Tags and Characters are set with LabelFormatDense, indexed by the IDs
+	// in tags.json and characters.json.
+	Tags       []float64 `json:"tags,omitempty"`
+	Characters []float64 `json:"characters,omitempty"`
+	// TagNames and CharacterNames are set with LabelFormatSparse.
+	TagNames       []string `json:"tag_names,omitempty"`
+	CharacterNames []string `json:"character_names,omitempty"`
+
+	AnimeID      uint   `json:"anime_id"`
+	Anime        string `json:"anime"`
+	Season       string `json:"season"`
+	SeasonType   string `json:"season_type"`
+	SeasonNumber *uint  `json:"season_number"`
+	AiringSeason string `json:"airing_season"`
+	AiringYear   *uint  `json:"airing_year"`
 }
 
 func (batchExporter BatchImageExporter) Export(ctx context.Context, exportDirectory string) error {
@@ -64,30 +103,32 @@ func (batchExporter BatchImageExporter) Export(ctx context.Context, exportDirect
 	if err != nil {
 		return fmt.Errorf("tagReader.ReadAllTags: %w", err)
 	}
-	alLTagsMarshaled, err := json.Marshal(allTags)
+	allCharacters, err := batchExporter.readAllCharacters()
 	if err != nil {
-		return fmt.Errorf("json.Marshal: %w", err)
+		return fmt.Errorf("readAllCharacters: %w", err)
 	}
 
-	if err := batchExporter.ExportImages(ctx, exportDirectory, allTags); err != nil {
+	if err := batchExporter.ExportImages(ctx, exportDirectory, allTags, allCharacters); err != nil {
 		return fmt.Errorf("service.ExportImages: %w", err)
 	}
+	return nil
+}
 
-	file, err := os.Create(exportDirectory + "/tags.json")
+// ExportImages exports the images and their labels, with tags.json and
+// characters.json listing the tags and characters of the exported images.
+func (batchExporter BatchImageExporter) ExportImages(
+	ctx context.Context,
+	rootExportDirectory string,
+	allTags []tag.Tag,
+	allCharacters []Character,
+) error {
+	splits, shares, err := batchExporter.options.Split.splits()
 	if err != nil {
-		return fmt.Errorf("os.Create: %w", err)
+		return fmt.Errorf("%w: %w", xerrors.ErrInvalidArgument, err)
 	}
-	defer file.Close()
-	_, err = file.Write(alLTagsMarshaled)
-	if err != nil {
-		return fmt.Errorf("file.Write: %w", err)
+	if err := batchExporter.options.LabelFormat.validate(); err != nil {
+		return fmt.Errorf("%w: %w", xerrors.ErrInvalidArgument, err)
 	}
-	return nil
-}
-
-func (batchExporter BatchImageExporter) ExportImages(ctx context.Context, rootExportDirectory string, allTags []tag.Tag) error {
-	const trainSplit = "train"
-	splits := []string{trainSplit}
 	for _, split := range splits {
 		exportDirectory := filepath.Join(rootExportDirectory, split)
 		if err := os.MkdirAll(exportDirectory, 0755); err != nil {
@@ -96,9 +137,222 @@ func (batchExporter BatchImageExporter) ExportImages(ctx context.Context, rootEx
 	}
 
 	maxTagID := tag.GetMaxTagID(allTags)
+	maxCharacterID := getMaxCharacterID(allCharacters)
+	tagMap := tag.ConvertTagsToMap(allTags)
+	characterMap := make(map[uint]Character, len(allCharacters))
+	for _, character := range allCharacters {
+		characterMap[character.ID] = character
+	}
+
+	selection, err := batchExporter.selectImages(ctx, splits, shares)
+	if err != nil {
+		return fmt.Errorf("selectImages: %w", err)
+	}
+	rootDirectory := selection.rootDirectory
+	allImages := selection.images
+	batchTagChecker := selection.batchTagChecker
+	imageCharacterIDs := selection.imageCharacterIDs
+	animeContexts := selection.animeContexts
+	imageSplits := selection.imageSplits
+
+	previousManifest := manifest{}
+	if batchExporter.options.Incremental {
+		previousManifest, err = readManifest(rootExportDirectory)
+		if err != nil {
+			return fmt.Errorf("readManifest: %w", err)
+		}
+	}
+
+	// Validate every image before copying any of them, and find the images
+	// an incremental export can skip.
+	sourceFiles := make([]os.FileInfo, len(allImages))
+	isUnchanged := make([]bool, len(allImages))
+	eg, _ := errgroup.WithContext(ctx)
+	for index, imageFile := range allImages {
+		eg.Go(func() error {
+			sourceFile, err := os.Stat(imageFile.LocalFilePath)
+			if err != nil {
+				return fmt.Errorf("os.Stat: %w for %s", err, imageFile.LocalFilePath)
+			}
+			sourceFiles[index] = sourceFile
+
+			split := imageSplits[imageFile.ID]
+			fileName := batchExporter.exportFileName(imageFile)
+			destinationFilePath := filepath.Join(rootExportDirectory, split, fileName)
+			if _, err := os.Stat(destinationFilePath); err != nil {
+				return nil
+			}
+			if !batchExporter.options.Incremental {
+				return fmt.Errorf("file already exists: %s", destinationFilePath)
+			}
+			if entry, ok := previousManifest[imageFile.ID]; ok {
+				isUnchanged[index] = entry.isUnchanged(split, fileName, sourceFile)
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return fmt.Errorf("validation errors: %w", err)
+	}
+
+	relativePaths := make(map[uint]string)
+	resolveRelativePaths(&rootDirectory, relativePaths)
+
+	newManifest := make(manifest, len(allImages))
+	imagesToCopy := make([]ExportedImage, 0, len(allImages))
+	allExportedImages := make(map[string][]ExportedImage, 0)
+	for index, imageFile := range allImages {
+		split := imageSplits[imageFile.ID]
+		metadata := Metadata{
+			FileName:     batchExporter.exportFileName(imageFile),
+			OriginalPath: filepath.ToSlash(filepath.Join(relativePaths[imageFile.ParentID], imageFile.Name)),
+		}
+		tagIDs := batchTagChecker.GetTagCheckerForImageFileID(imageFile.ID).GetDirectTags()
+		sort.Slice(tagIDs, func(i, j int) bool {
+			return tagIDs[i] < tagIDs[j]
+		})
+		characterIDs := imageCharacterIDs[imageFile.ID]
+		exportedImage := ExportedImage{
+			ImageFile:  imageFile,
+			Tags:       make([]tag.Tag, 0, len(tagIDs)),
+			Characters: make([]Character, 0, len(characterIDs)),
+		}
+		for _, tagID := range tagIDs {
+			exportedImage.Tags = append(exportedImage.Tags, tagMap[tagID])
+		}
+		for _, characterID := range characterIDs {
+			exportedImage.Characters = append(exportedImage.Characters, characterMap[characterID])
+		}
+		if batchExporter.options.LabelFormat == LabelFormatSparse {
+			metadata.TagNames = make([]string, 0, len(tagIDs))
+			for _, t := range exportedImage.Tags {
+				metadata.TagNames = append(metadata.TagNames, t.Name)
+			}
+			metadata.CharacterNames = make([]string, 0, len(characterIDs))
+			for _, character := range exportedImage.Characters {
+				metadata.CharacterNames = append(metadata.CharacterNames, character.Name)
+			}
+		} else {
+			metadata.Tags = make([]float64, maxTagID+1)
+			for _, tagID := range tagIDs {
+				metadata.Tags[tagID] = 1.0
+			}
+			metadata.Characters = make([]float64, maxCharacterID+1)
+			for _, characterID := range characterIDs {
+				metadata.Characters[characterID] = 1.0
+			}
+		}
+		animeContexts[imageFile.ParentID].applyTo(&metadata)
+		exportedImage.Metadata = metadata
+		allExportedImages[split] = append(allExportedImages[split], exportedImage)
+
+		newManifest[imageFile.ID] = manifestEntry{
+			Split:      split,
+			FileName:   metadata.FileName,
+			Size:       sourceFiles[index].Size(),
+			ModifiedAt: sourceFiles[index].ModTime(),
+		}
+		if !isUnchanged[index] {
+			imagesToCopy = append(imagesToCopy, exportedImage)
+		}
+	}
+
+	// Images that were moved to another split or are no longer exported are
+	// removed, so that each split only has the images in its labels.
+	format := batchExporter.format()
+	for imageFileID, entry := range previousManifest {
+		if newEntry, ok := newManifest[imageFileID]; ok && newEntry.Split == entry.Split && newEntry.FileName == entry.FileName {
+			continue
+		}
+		if err := format.RemoveImage(filepath.Join(rootExportDirectory, entry.Split), entry.FileName); err != nil {
+			return fmt.Errorf("RemoveImage: %w", err)
+		}
+	}
+
+	batchExporter.logger.Info("Validation completed successfully. Start exporting images",
+		"exportDirectory", rootExportDirectory,
+		"copy", len(imagesToCopy),
+		"unchanged", len(allImages)-len(imagesToCopy),
+	)
+
+	var copiedImageCount int64
+	var copyWaitGroup sync.WaitGroup
+	copyWaitGroup.Add(len(imagesToCopy))
+	eg, _ = errgroup.WithContext(ctx)
+	for _, exportedImage := range imagesToCopy {
+		split := imageSplits[exportedImage.ImageFile.ID]
+		eg.Go(func() error {
+			defer copyWaitGroup.Done()
+			err := format.CopyImage(filepath.Join(rootExportDirectory, split), exportedImage)
+			atomic.AddInt64(&copiedImageCount, 1)
+			if err != nil {
+				return fmt.Errorf("CopyImage: %w", err)
+			}
+			return nil
+		})
+	}
+	for _, split := range splits {
+		exportDirectory := filepath.Join(rootExportDirectory, split)
+		eg.Go(func() error {
+			if err := format.WriteLabels(exportDirectory, allExportedImages[split]); err != nil {
+				return fmt.Errorf("WriteLabels: %w", err)
+			}
+			return nil
+		})
+	}
+	copyDone := make(chan struct{})
+	go func() {
+		copyWaitGroup.Wait()
+		close(copyDone)
+	}()
+	eg.Go(func() error {
+		totalCopyImageCount := len(imagesToCopy)
+		for atomic.LoadInt64(&copiedImageCount) != int64(totalCopyImageCount) {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-copyDone:
+				return nil
+			case <-time.After(batchExporter.options.progressSleepDuration):
+				batchExporter.logger.Info("Copying images is in progress",
+					"completed", atomic.LoadInt64(&copiedImageCount),
+					"total", totalCopyImageCount,
+					"percentage", float64(atomic.LoadInt64(&copiedImageCount))/float64(totalCopyImageCount)*100,
+				)
+			}
+		}
+		return nil
+	})
+	if err := eg.Wait(); err != nil {
+		return fmt.Errorf("export errors: %w", err)
+	}
+	if err := writeVocabularies(rootExportDirectory, allExportedImages); err != nil {
+		return fmt.Errorf("writeVocabularies: %w", err)
+	}
+	if err := writeManifest(rootExportDirectory, newManifest); err != nil {
+		return fmt.Errorf("writeManifest: %w", err)
+	}
+
+	return nil
+}
+
+// imageSelection is the images to export with what was read to select them
+type imageSelection struct {
+	rootDirectory     image.Directory
+	images            []image.ImageFile
+	batchTagChecker   tag.BatchImageTagChecker
+	imageCharacterIDs map[uint][]uint
+	animeContexts     map[uint]animeContext
+	// imageSplits maps image file IDs to their splits
+	imageSplits map[uint]string
+}
+
+// selectImages reads the tagged images matching the query, and assigns them
+// to the splits.
+func (batchExporter BatchImageExporter) selectImages(ctx context.Context, splits []string, shares []float64) (imageSelection, error) {
 	rootDirectory, err := batchExporter.directoryReader.ReadDirectoryTree()
 	if err != nil {
-		return fmt.Errorf("readDirectoryTree: %w", err)
+		return imageSelection{}, fmt.Errorf("readDirectoryTree: %w", err)
 	}
 
 	eg, _ := errgroup.WithContext(ctx)
@@ -114,7 +368,7 @@ func (batchExporter BatchImageExporter) ExportImages(ctx context.Context, rootEx
 		})
 	}
 	if err := eg.Wait(); err != nil {
-		return fmt.Errorf("validation errors: %w", err)
+		return imageSelection{}, fmt.Errorf("validation errors: %w", err)
 	}
 
 	allImageFileIDs := make([]uint, 0)
@@ -125,11 +379,10 @@ func (batchExporter BatchImageExporter) ExportImages(ctx context.Context, rootEx
 	}
 	batchTagChecker, err := batchExporter.tagReader.CreateBatchTagCheckerByFileIDs(ctx, allImageFileIDs)
 	if err != nil {
-		return fmt.Errorf("ReadTagsByFileIDs: %w", err)
+		return imageSelection{}, fmt.Errorf("ReadTagsByFileIDs: %w", err)
 	}
 
 	allImages := make([]image.ImageFile, 0)
-	allImageFileIDs = make([]uint, 0)
 	for _, imageFiles := range allImageFiles {
 		for _, imageFile := range imageFiles {
 			tagChecker := batchTagChecker.GetTagCheckerForImageFileID(imageFile.ID)
@@ -143,113 +396,240 @@ func (batchExporter BatchImageExporter) ExportImages(ctx context.Context, rootEx
 			}
 
 			allImages = append(allImages, imageFile)
-			allImageFileIDs = append(allImageFileIDs, imageFile.ID)
 		}
 	}
 
-	eg, _ = errgroup.WithContext(ctx)
-	for _, imageFile := range allImages {
-		eg.Go(func() error {
-			if _, err := os.Stat(imageFile.LocalFilePath); err != nil {
-				return fmt.Errorf("os.Stat: %w for %s", err, imageFile.LocalFilePath)
-			}
-			for _, split := range splits {
-				destinationFilePath := filepath.Join(rootExportDirectory, split, imageFile.Name)
-				if _, err := os.Stat(destinationFilePath); err == nil {
-					return fmt.Errorf("file already exists: %s", destinationFilePath)
-				}
-			}
-
-			return nil
-		})
+	animeContexts, err := batchExporter.readAnimeContexts(rootDirectory)
+	if err != nil {
+		return imageSelection{}, fmt.Errorf("readAnimeContexts: %w", err)
 	}
-	if err := eg.Wait(); err != nil {
-		return fmt.Errorf("validation errors: %w", err)
+	imageCharacterIDs, err := batchExporter.readCharacterIDs(allImages)
+	if err != nil {
+		return imageSelection{}, fmt.Errorf("readCharacterIDs: %w", err)
 	}
+	matcher := newQueryMatcher(batchExporter.options.Query, rootDirectory, animeContexts, imageCharacterIDs, batchTagChecker)
+	allImages = xslices.Filter(allImages, matcher.matches)
 
-	batchExporter.logger.Info("Validation completed successfully. Start exporting images",
-		"exportDirectory", rootExportDirectory)
+	imageSplits, err := batchExporter.assignSplits(ctx, allImages, batchTagChecker, imageCharacterIDs, animeContexts, splits, shares)
+	if err != nil {
+		return imageSelection{}, fmt.Errorf("assignSplits: %w", err)
+	}
 
-	var copiedImageCount int64
-	eg, _ = errgroup.WithContext(ctx)
-	allMetadata := make(map[string][]Metadata, 0)
-	for _, imageFile := range allImages {
-		metadata := Metadata{
-			FileName: imageFile.Name,
-			Tags:     make([]float64, maxTagID+1),
+	return imageSelection{
+		rootDirectory:     rootDirectory,
+		images:            allImages,
+		batchTagChecker:   batchTagChecker,
+		imageCharacterIDs: imageCharacterIDs,
+		animeContexts:     animeContexts,
+		imageSplits:       imageSplits,
+	}, nil
+}
+
+// SelectImages returns the images that Export exports into the split, e.g.
+// the test split to evaluate a model on images it wasn't trained on. The
+// same options and library select the same images. An empty split selects
+// the images of every split.
+func (batchExporter BatchImageExporter) SelectImages(ctx context.Context, split string) ([]image.ImageFile, error) {
+	if split == "" {
+		selection, err := batchExporter.selectImages(ctx, []string{trainSplit}, []float64{1})
+		if err != nil {
+			return nil, fmt.Errorf("selectImages: %w", err)
 		}
+		return selection.images, nil
+	}
 
-		split := trainSplit
-		tagChecker := batchTagChecker.GetTagCheckerForImageFileID(imageFile.ID)
-		for _, tagID := range tagChecker.GetDirectTags() {
-			metadata.Tags[tagID] = 1.0
+	splits, shares, err := batchExporter.options.Split.splits()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", xerrors.ErrInvalidArgument, err)
+	}
+	if !slices.Contains(splits, split) {
+		return nil, fmt.Errorf("%w: split %s has no ratio", xerrors.ErrInvalidArgument, split)
+	}
+	selection, err := batchExporter.selectImages(ctx, splits, shares)
+	if err != nil {
+		return nil, fmt.Errorf("selectImages: %w", err)
+	}
+	return xslices.Filter(selection.images, func(imageFile image.ImageFile) bool {
+		return selection.imageSplits[imageFile.ID] == split
+	}), nil
+}
+
+func (batchExporter BatchImageExporter) format() Format {
+	if batchExporter.options.Format == nil {
+		return HuggingFaceFormat{}
+	}
+	return batchExporter.options.Format
+}
+
+// exportFileName returns the name of an image in a split directory of the
+// format.
+func (batchExporter BatchImageExporter) exportFileName(imageFile image.ImageFile) string {
+	if namer, ok := batchExporter.format().(fileNamer); ok {
+		return namer.exportFileName(imageFile)
+	}
+	return exportFileName(imageFile)
+}
+
+func (batchExporter *BatchImageExporter) exportImageFile(imageFile image.ImageFile, exportDirectory string) error {
+	return batchExporter.format().CopyImage(exportDirectory, ExportedImage{
+		ImageFile: imageFile,
+		Metadata: Metadata{
+			FileName: batchExporter.exportFileName(imageFile),
+		},
+	})
+}
+
+// readCharacterIDs returns the characters of each image, by image file ID.
+func (batchExporter BatchImageExporter) readCharacterIDs(images []image.ImageFile) (map[uint][]uint, error) {
+	imageFileIDs := make([]uint, len(images))
+	for i, imageFile := range images {
+		imageFileIDs[i] = imageFile.ID
+	}
+	fileCharacters, err := batchExporter.dbClient.FileCharacter().FindByFileIDs(imageFileIDs)
+	if err != nil {
+		return nil, fmt.Errorf("FileCharacter.FindByFileIDs: %w", err)
+	}
+
+	result := make(map[uint][]uint)
+	for _, fileCharacter := range fileCharacters {
+		result[fileCharacter.FileID] = append(result[fileCharacter.FileID], fileCharacter.CharacterID)
+	}
+	for _, characterIDs := range result {
+		sort.Slice(characterIDs, func(i, j int) bool {
+			return characterIDs[i] < characterIDs[j]
+		})
+	}
+	return result, nil
+}
+
+// assignSplits returns the split of each image, by image file ID. The hashes
+// that keep related images together are only read when there is more than
+// one split.
+func (batchExporter BatchImageExporter) assignSplits(
+	ctx context.Context,
+	images []image.ImageFile,
+	batchTagChecker tag.BatchImageTagChecker,
+	imageCharacterIDs map[uint][]uint,
+	animeContexts map[uint]animeContext,
+	splits []string,
+	shares []float64,
+) (map[uint]string, error) {
+	samples := make([]splitSample, len(images))
+	for i, imageFile := range images {
+		samples[i] = splitSample{
+			imageFile:    imageFile,
+			tagIDs:       batchTagChecker.GetTagCheckerForImageFileID(imageFile.ID).GetDirectTags(),
+			characterIDs: imageCharacterIDs[imageFile.ID],
+			animeID:      animeContexts[imageFile.ParentID].animeID,
 		}
+	}
+	// The order of the samples decides ties, so it must not depend on the
+	// order the directories were read in.
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].imageFile.ID < samples[j].imageFile.ID
+	})
+	if len(splits) == 1 {
+		return assignSplits(samples, splits, shares, batchExporter.options.Split.Seed), nil
+	}
 
-		allMetadata[split] = append(allMetadata[split], metadata)
-		imageFile := imageFile
+	imageIDs := make([]uint, len(samples))
+	for i, sample := range samples {
+		imageIDs[i] = sample.imageFile.ID
+	}
+	dbFiles, err := batchExporter.dbClient.File().FindImageFilesByIDs(imageIDs)
+	if err != nil {
+		return nil, fmt.Errorf("File.FindImageFilesByIDs: %w", err)
+	}
+	contentHashes := make(map[uint]string, len(dbFiles))
+	for _, dbFile := range dbFiles {
+		contentHashes[dbFile.ID] = dbFile.ContentHash
+	}
+
+	eg, _ := errgroup.WithContext(ctx)
+	eg.SetLimit(runtime.NumCPU())
+	for i := range samples {
+		sample := &samples[i]
+		sample.contentHash = contentHashes[sample.imageFile.ID]
+		sample.episodeKey = episodeKey(sample.imageFile)
 		eg.Go(func() error {
-			err := batchExporter.exportImageFile(imageFile, filepath.Join(rootExportDirectory, split))
-			atomic.AddInt64(&copiedImageCount, 1)
+			hash, err := image.ComputePerceptualHash(sample.imageFile.LocalFilePath)
 			if err != nil {
-				return fmt.Errorf("exportImageFile: %w", err)
+				// The image is still exported; it just cannot be matched
+				// with its near-duplicates.
+				batchExporter.logger.Warn("failed to compute a perceptual hash",
+					"imageFile", sample.imageFile.LocalFilePath,
+					"error", err,
+				)
+				return nil
 			}
+			sample.perceptualHash = hash
+			sample.hasPerceptualHash = true
 			return nil
 		})
 	}
-	for _, split := range splits {
-		exportDirectory := filepath.Join(rootExportDirectory, split)
-		eg.Go(func() error {
-			metadataFile, err := os.OpenFile(
-				filepath.Join(exportDirectory, "metadata.jsonl"),
-				os.O_RDWR|os.O_CREATE|os.O_TRUNC,
-				0644,
-			)
-			if err != nil {
-				return fmt.Errorf("os.Open: %w", err)
-			}
-			defer metadataFile.Close()
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
 
-			buffer := bufio.NewWriter(metadataFile)
-			metadataJsonEncoder := json.NewEncoder(buffer)
-			for _, metadata := range allMetadata[split] {
-				if err := metadataJsonEncoder.Encode(metadata); err != nil {
-					return fmt.Errorf("json.Encode: %w", err)
-				}
-			}
-			if err := buffer.Flush(); err != nil {
-				return fmt.Errorf("buffer.Flush: %w", err)
-			}
-			return nil
-		})
+	result := assignSplits(samples, splits, shares, batchExporter.options.Split.Seed)
+	counts := make(map[string]int, len(splits))
+	for _, split := range result {
+		counts[split]++
 	}
-	eg.Go(func() error {
-		totalCopyImageCount := len(allImageFileIDs)
-		for atomic.LoadInt64(&copiedImageCount) != int64(totalCopyImageCount) {
-			select {
-			case <-ctx.Done():
-				return nil
-			case <-time.After(batchExporter.options.progressSleepDuration):
-				batchExporter.logger.Info("Copying images is in progress",
-					"completed", atomic.LoadInt64(&copiedImageCount),
-					"total", totalCopyImageCount,
-					"percentage", float64(atomic.LoadInt64(&copiedImageCount))/float64(totalCopyImageCount)*100,
-				)
+	batchExporter.logger.Info("Assigned images to splits", "counts", counts)
+	return result, nil
+}
+
+// writeVocabularies writes tags.json and characters.json with the tags and
+// characters of the exported images, ordered by ID.
+func writeVocabularies(rootExportDirectory string, allExportedImages map[string][]ExportedImage) error {
+	tagMap := make(map[uint]tag.Tag)
+	characterMap := make(map[uint]Character)
+	for _, exportedImages := range allExportedImages {
+		for _, exportedImage := range exportedImages {
+			for _, t := range exportedImage.Tags {
+				tagMap[t.ID] = t
+			}
+			for _, character := range exportedImage.Characters {
+				characterMap[character.ID] = character
 			}
 		}
-		return nil
+	}
+	tags := make([]tag.Tag, 0, len(tagMap))
+	for _, t := range tagMap {
+		tags = append(tags, t)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].ID < tags[j].ID
 	})
-	if err := eg.Wait(); err != nil {
-		return fmt.Errorf("export errors: %w", err)
+	characters := make([]Character, 0, len(characterMap))
+	for _, character := range characterMap {
+		characters = append(characters, character)
 	}
+	sort.Slice(characters, func(i, j int) bool {
+		return characters[i].ID < characters[j].ID
+	})
 
+	for fileName, vocabulary := range map[string]any{
+		"tags.json":       tags,
+		"characters.json": characters,
+	} {
+		content, err := json.Marshal(vocabulary)
+		if err != nil {
+			return fmt.Errorf("json.Marshal: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(rootExportDirectory, fileName), content, 0644); err != nil {
+			return fmt.Errorf("os.WriteFile: %w", err)
+		}
+	}
 	return nil
 }
 
-func (batchExporter *BatchImageExporter) exportImageFile(imageFile image.ImageFile, exportDirectory string) error {
-	destinationFilePath := fmt.Sprintf("%s/%s", exportDirectory, imageFile.Name)
-	if _, err := image.Copy(imageFile.LocalFilePath, destinationFilePath); err != nil {
-		return fmt.Errorf("copy: %w", err)
+// resolveRelativePaths maps each directory to its path relative to the image
+// root directory.
+func resolveRelativePaths(directory *image.Directory, result map[uint]string) {
+	result[directory.ID] = directory.RelativePath
+	for _, child := range directory.Children {
+		resolveRelativePaths(child, result)
 	}
-
-	return nil
 }
@@ -16,6 +16,7 @@ import (
 	"github.com/michael-freling/anime-image-viewer/internal/image"
 	"github.com/michael-freling/anime-image-viewer/internal/tag"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type tester struct {
@@ -170,12 +171,12 @@ func TestBatchImageExporter_Export(t *testing.T) {
 			},
 
 			wantImages: []string{
-				filepath.Join(excludeTagDir, "train", fileCreator.BuildImageFile(11).Name),
-				filepath.Join(excludeTagDir, "train", fileCreator.BuildImageFile(101).Name),
+				filepath.Join(excludeTagDir, "train", "11.jpg"),
+				filepath.Join(excludeTagDir, "train", "101.jpg"),
 			},
 			wantMetadatas: []Metadata{
-				{FileName: fileCreator.BuildImageFile(11).Name, Tags: []float64{0, 1, 0, 0, 0, 0}},
-				{FileName: fileCreator.BuildImageFile(101).Name, Tags: []float64{0, 0, 0, 0, 0, 1}},
+				{FileName: "11.jpg", OriginalPath: "dir1/image11.jpg", Tags: []float64{0, 1, 0, 0, 0, 0}, Characters: []float64{0}},
+				{FileName: "101.jpg", OriginalPath: "dir1/child 10/image101.jpg", Tags: []float64{0, 0, 0, 0, 0, 1}, Characters: []float64{0}},
 			},
 		},
 		{
@@ -204,13 +205,13 @@ func TestBatchImageExporter_Export(t *testing.T) {
 				{FileID: 101, TagID: 5}, // a leaf tag to a file
 			},
 			wantImages: []string{
-				filepath.Join(includeTagDir, "train", fileCreator.BuildImageFile(11).Name),
-				filepath.Join(includeTagDir, "train", fileCreator.BuildImageFile(101).Name),
+				filepath.Join(includeTagDir, "train", "11.jpg"),
+				filepath.Join(includeTagDir, "train", "101.jpg"),
 			},
 			wantMetadatas: []Metadata{
-				{FileName: fileCreator.BuildImageFile(11).Name, Tags: []float64{0, 1, 0, 0, 0, 0}},
+				{FileName: "11.jpg", OriginalPath: "dir1/image11.jpg", Tags: []float64{0, 1, 0, 0, 0, 0}, Characters: []float64{0}},
 				// image12 has no direct tags, so it's excluded from export
-				{FileName: fileCreator.BuildImageFile(101).Name, Tags: []float64{0, 0, 0, 0, 0, 1}},
+				{FileName: "101.jpg", OriginalPath: "dir1/child 10/image101.jpg", Tags: []float64{0, 0, 0, 0, 0, 1}, Characters: []float64{0}},
 			},
 		},
 		{
@@ -235,7 +236,7 @@ func TestBatchImageExporter_Export(t *testing.T) {
 	trainDir := filepath.Join(existingFileDir, "train")
 	assert.NoError(t, os.MkdirAll(trainDir, 0755))
 	// Create a file with same name as would be exported
-	existingFilePath := filepath.Join(trainDir, fileCreator.BuildImageFile(11).Name)
+	existingFilePath := filepath.Join(trainDir, exportFileName(fileCreator.BuildImageFile(11)))
 	assert.NoError(t, os.WriteFile(existingFilePath, []byte("existing"), 0644))
 
 	testCases = append(testCases, exportTestCase{
@@ -321,6 +322,9 @@ func TestBatchImageExporter_Export(t *testing.T) {
 					tagFileExists = true
 					return nil
 				}
+				if filepath.Base(path) == manifestFileName || filepath.Base(path) == "characters.json" {
+					return nil
+				}
 				if filepath.Base(path) == "metadata.jsonl" {
 					metadataFilePath = path
 					return nil
@@ -399,7 +403,7 @@ func TestBatchImageExporter_exportImageFile(t *testing.T) {
 		err := batchExporter.exportImageFile(imgFile, exportDir)
 		assert.NoError(t, err)
 
-		destPath := filepath.Join(exportDir, imgFile.Name)
+		destPath := filepath.Join(exportDir, "11.jpg")
 		assert.FileExists(t, destPath)
 	})
 
@@ -454,7 +458,7 @@ func TestBatchImageExporter_ExportImages_InvalidDirectory(t *testing.T) {
 	})
 
 	// Use a path under /dev/null which is not a directory on Linux
-	err = batchExporter.ExportImages(context.Background(), "/dev/null/invalid/path", allTags)
+	err = batchExporter.ExportImages(context.Background(), "/dev/null/invalid/path", allTags, nil)
 	assert.Error(t, err)
 }
 
@@ -494,12 +498,80 @@ func TestBatchImageExporter_ExportImages_CancelledContext(t *testing.T) {
 	cancel() // cancel immediately
 
 	// ExportImages should still complete since errgroups ignore context
-	err = batchExporter.ExportImages(ctx, exportDir, allTags)
+	err = batchExporter.ExportImages(ctx, exportDir, allTags, nil)
 	// The export might succeed or fail depending on timing,
 	// but the progress goroutine should hit ctx.Done()
 	_ = err
 }
 
+func TestBatchImageExporter_Export_Incremental(t *testing.T) {
+	tester := newTester(t)
+	fileCreator := tester.newFileCreator(t)
+	for _, directory := range []image.Directory{
+		{ID: 1, Name: "frieren"},
+		{ID: 10, Name: "Season 1", ParentID: 1},
+		{ID: 20, Name: "Season 2", ParentID: 1},
+	} {
+		fileCreator.CreateDirectory(directory)
+	}
+	// Both seasons have an image with the same name.
+	fileCreator.CreateImage(image.ImageFile{ID: 11, Name: "00001.jpg", ParentID: 10}, image.TestImageFileJpeg)
+	fileCreator.CreateImage(image.ImageFile{ID: 21, Name: "00001.jpg", ParentID: 20}, image.TestImageFileJpeg)
+
+	tester.dbClient.Truncate(t, &db.File{}, &db.Tag{}, &db.FileTag{})
+	db.LoadTestData(t, tester.dbClient, []db.File{
+		fileCreator.BuildDBDirectory(1),
+		fileCreator.BuildDBDirectory(10),
+		fileCreator.BuildDBDirectory(20),
+		fileCreator.BuildDBImageFile(11),
+		fileCreator.BuildDBImageFile(21),
+	})
+	db.LoadTestData(t, tester.dbClient, []db.Tag{{ID: 1, Name: "tag1"}})
+	db.LoadTestData(t, tester.dbClient, []db.FileTag{
+		{FileID: 11, TagID: 1},
+		{FileID: 21, TagID: 1},
+	})
+
+	exportDirectory := t.TempDir()
+	export := func(incremental bool) error {
+		return tester.getBatchImageExporter(BatchImageExporterOptions{
+			Incremental:           incremental,
+			progressSleepDuration: time.Millisecond,
+		}).Export(context.Background(), exportDirectory)
+	}
+	require.NoError(t, export(true))
+	assert.Equal(t, []Metadata{
+		{FileName: "11.jpg", OriginalPath: "frieren/Season 1/00001.jpg", Tags: []float64{0, 1}, Characters: []float64{0}},
+		{FileName: "21.jpg", OriginalPath: "frieren/Season 2/00001.jpg", Tags: []float64{0, 1}, Characters: []float64{0}},
+	}, readMetadataFile(t, filepath.Join(exportDirectory, "train", "metadata.jsonl")))
+
+	assert.Error(t, export(false), "a full export does not overwrite images")
+
+	// Mark the exported copies to tell which ones are copied again.
+	for _, fileName := range []string{"11.jpg", "21.jpg"} {
+		require.NoError(t, os.WriteFile(filepath.Join(exportDirectory, "train", fileName), []byte("exported"), 0644))
+	}
+	modifiedAt := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(fileCreator.BuildImageFile(21).LocalFilePath, modifiedAt, modifiedAt))
+	tester.dbClient.Truncate(t, &db.FileTag{})
+	db.LoadTestData(t, tester.dbClient, []db.FileTag{{FileID: 21, TagID: 1}})
+
+	require.NoError(t, export(true))
+	assert.NoFileExists(t, filepath.Join(exportDirectory, "train", "11.jpg"), "an image no longer exported is removed")
+	content, err := os.ReadFile(filepath.Join(exportDirectory, "train", "21.jpg"))
+	require.NoError(t, err)
+	assert.NotEqual(t, "exported", string(content), "a changed image is copied again")
+	assert.Equal(t, []Metadata{
+		{FileName: "21.jpg", OriginalPath: "frieren/Season 2/00001.jpg", Tags: []float64{0, 1}, Characters: []float64{0}},
+	}, readMetadataFile(t, filepath.Join(exportDirectory, "train", "metadata.jsonl")))
+
+	require.NoError(t, os.WriteFile(filepath.Join(exportDirectory, "train", "21.jpg"), []byte("exported"), 0644))
+	require.NoError(t, export(true))
+	content, err = os.ReadFile(filepath.Join(exportDirectory, "train", "21.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, "exported", string(content), "an unchanged image is not copied")
+}
+
 func readMetadataFile(t *testing.T, metadataFilePath string) []Metadata {
 	metadataFile, err := os.Open(metadataFilePath)
 	assert.NoError(t, err)
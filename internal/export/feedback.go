@@ -0,0 +1,265 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+)
+
+// feedbackBatchSize is the number of IDs read in a query
+const feedbackBatchSize = 500
+
+// SuggestionFeedbackExporter exports what users decided on tag suggestions,
+// to retrain and evaluate a tag suggestion model.
+type SuggestionFeedbackExporter struct {
+	dbClient        *db.Client
+	directoryReader *image.DirectoryReader
+}
+
+func NewSuggestionFeedbackExporter(conf config.Config, dbClient *db.Client) *SuggestionFeedbackExporter {
+	return &SuggestionFeedbackExporter{
+		dbClient:        dbClient,
+		directoryReader: image.NewDirectoryReader(conf, dbClient),
+	}
+}
+
+// HardNegative is a suggestion rejected for an image, which is written as a
+// line of JSON. Either Tag or Character is set.
+type HardNegative struct {
+	// OriginalPath is the path of the image relative to the image root
+	// directory, like Metadata.OriginalPath
+	OriginalPath string    `json:"original_path"`
+	Tag          string    `json:"tag,omitempty"`
+	Character    string    `json:"character,omitempty"`
+	Score        float64   `json:"score"`
+	ModelName    string    `json:"model_name"`
+	ModelVersion string    `json:"model_version"`
+	RejectedAt   time.Time `json:"rejected_at"`
+}
+
+// ExportHardNegatives writes rejected suggestions as JSON lines, and returns
+// how many were written. If modelVersion is set, only the suggestions of the
+// model version are written.
+// Suggestions of images, tags or characters which were deleted, and those
+// which were added to the image after all, are skipped.
+func (exporter SuggestionFeedbackExporter) ExportHardNegatives(ctx context.Context, writer io.Writer, modelVersion string) (int, error) {
+	events, err := exporter.dbClient.SuggestionEvent().FindByDecision(ctx, db.SuggestionDecisionRejected)
+	if err != nil {
+		return 0, fmt.Errorf("SuggestionEvent.FindByDecision: %w", err)
+	}
+	if modelVersion != "" {
+		filtered := make([]db.SuggestionEvent, 0, len(events))
+		for _, event := range events {
+			if event.ModelVersion == modelVersion {
+				filtered = append(filtered, event)
+			}
+		}
+		events = filtered
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	fileIDs := make([]uint, 0, len(events))
+	tagIDs := make([]uint, 0)
+	characterIDs := make([]uint, 0)
+	isFileIDAdded := make(map[uint]bool)
+	isTagIDAdded := make(map[uint]bool)
+	isCharacterIDAdded := make(map[uint]bool)
+	for _, event := range events {
+		if !isFileIDAdded[event.FileID] {
+			isFileIDAdded[event.FileID] = true
+			fileIDs = append(fileIDs, event.FileID)
+		}
+		if event.TagID != 0 && !isTagIDAdded[event.TagID] {
+			isTagIDAdded[event.TagID] = true
+			tagIDs = append(tagIDs, event.TagID)
+		}
+		if event.CharacterID != 0 && !isCharacterIDAdded[event.CharacterID] {
+			isCharacterIDAdded[event.CharacterID] = true
+			characterIDs = append(characterIDs, event.CharacterID)
+		}
+	}
+
+	imageFiles, err := findInBatches(fileIDs, exporter.dbClient.File().FindImageFilesByIDs)
+	if err != nil {
+		return 0, fmt.Errorf("File.FindImageFilesByIDs: %w", err)
+	}
+	imageFileMap := make(map[uint]db.File, len(imageFiles))
+	for _, imageFile := range imageFiles {
+		imageFileMap[imageFile.ID] = imageFile
+	}
+	tags, err := findInBatches(tagIDs, exporter.dbClient.Tag().FindAllByTagIDs)
+	if err != nil {
+		return 0, fmt.Errorf("Tag.FindAllByTagIDs: %w", err)
+	}
+	tagNames := make(map[uint]string, len(tags))
+	for _, tag := range tags {
+		tagNames[tag.ID] = tag.Name
+	}
+	characters, err := findInBatches(characterIDs, exporter.dbClient.Character().FindByIDs)
+	if err != nil {
+		return 0, fmt.Errorf("Character.FindByIDs: %w", err)
+	}
+	characterNames := make(map[uint]string, len(characters))
+	for _, character := range characters {
+		characterNames[character.ID] = character.Name
+	}
+
+	fileTags, err := findInBatches(fileIDs, exporter.dbClient.FileTag().FindAllByFileID)
+	if err != nil {
+		return 0, fmt.Errorf("FileTag.FindAllByFileID: %w", err)
+	}
+	fileTagMap := fileTags.ToFileMap()
+	fileCharacters, err := findInBatches(fileIDs, exporter.dbClient.FileCharacter().FindByFileIDs)
+	if err != nil {
+		return 0, fmt.Errorf("FileCharacter.FindByFileIDs: %w", err)
+	}
+	hasCharacter := make(map[[2]uint]bool, len(fileCharacters))
+	for _, fileCharacter := range fileCharacters {
+		hasCharacter[[2]uint{fileCharacter.FileID, fileCharacter.CharacterID}] = true
+	}
+
+	rootDirectory, err := exporter.directoryReader.ReadDirectoryTree()
+	if err != nil {
+		return 0, fmt.Errorf("directoryReader.ReadDirectoryTree: %w", err)
+	}
+	relativePaths := make(map[uint]string)
+	resolveRelativePaths(&rootDirectory, relativePaths)
+
+	encoder := json.NewEncoder(writer)
+	count := 0
+	for _, event := range events {
+		imageFile, ok := imageFileMap[event.FileID]
+		if !ok {
+			continue
+		}
+		hardNegative := HardNegative{
+			OriginalPath: filepath.ToSlash(filepath.Join(relativePaths[imageFile.ParentID], imageFile.Name)),
+			Score:        event.Score,
+			ModelName:    event.ModelName,
+			ModelVersion: event.ModelVersion,
+			RejectedAt:   time.Unix(int64(event.CreatedAt), 0).UTC(),
+		}
+		if event.TagID != 0 {
+			name, ok := tagNames[event.TagID]
+			if !ok {
+				continue
+			}
+			if _, ok := fileTagMap[event.FileID][event.TagID]; ok {
+				continue
+			}
+			hardNegative.Tag = name
+		} else {
+			name, ok := characterNames[event.CharacterID]
+			if !ok {
+				continue
+			}
+			if hasCharacter[[2]uint{event.FileID, event.CharacterID}] {
+				continue
+			}
+			hardNegative.Character = name
+		}
+
+		if err := encoder.Encode(hardNegative); err != nil {
+			return count, fmt.Errorf("encoder.Encode: %w", err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// findInBatches finds the rows of the IDs with at most feedbackBatchSize IDs
+// in a query, so that a query doesn't exceed the variables SQLite allows.
+func findInBatches[S ~[]T, T any](ids []uint, find func(ids []uint) (S, error)) (S, error) {
+	var result S
+	for start := 0; start < len(ids); start += feedbackBatchSize {
+		values, err := find(ids[start:min(start+feedbackBatchSize, len(ids))])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, values...)
+	}
+	return result, nil
+}
+
+// SuggestionPrecision is how many suggestions of a model were accepted and
+// rejected on a day
+type SuggestionPrecision struct {
+	ModelName    string
+	ModelVersion string
+	// Date is the day in UTC, like 2024-12-01
+	Date     string
+	Accepted int
+	Rejected int
+}
+
+// Precision is the share of the accepted suggestions
+func (precision SuggestionPrecision) Precision() float64 {
+	total := precision.Accepted + precision.Rejected
+	if total == 0 {
+		return 0
+	}
+	return float64(precision.Accepted) / float64(total)
+}
+
+// MeasurePrecision returns the precision of the suggestions decided since the
+// time for each model version and day, ordered by day and model.
+func (exporter SuggestionFeedbackExporter) MeasurePrecision(ctx context.Context, since time.Time) ([]SuggestionPrecision, error) {
+	events, err := exporter.dbClient.SuggestionEvent().FindSince(ctx, uint(since.Unix()))
+	if err != nil {
+		return nil, fmt.Errorf("SuggestionEvent.FindSince: %w", err)
+	}
+
+	type key struct {
+		modelName    string
+		modelVersion string
+		date         string
+	}
+	precisions := make(map[key]*SuggestionPrecision)
+	for _, event := range events {
+		k := key{
+			modelName:    event.ModelName,
+			modelVersion: event.ModelVersion,
+			date:         time.Unix(int64(event.CreatedAt), 0).UTC().Format(time.DateOnly),
+		}
+		precision, ok := precisions[k]
+		if !ok {
+			precision = &SuggestionPrecision{
+				ModelName:    k.modelName,
+				ModelVersion: k.modelVersion,
+				Date:         k.date,
+			}
+			precisions[k] = precision
+		}
+		switch event.Decision {
+		case db.SuggestionDecisionAccepted:
+			precision.Accepted++
+		case db.SuggestionDecisionRejected:
+			precision.Rejected++
+		}
+	}
+
+	result := make([]SuggestionPrecision, 0, len(precisions))
+	for _, precision := range precisions {
+		result = append(result, *precision)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Date != result[j].Date {
+			return result[i].Date < result[j].Date
+		}
+		if result[i].ModelName != result[j].ModelName {
+			return result[i].ModelName < result[j].ModelName
+		}
+		return result[i].ModelVersion < result[j].ModelVersion
+	})
+	return result, nil
+}
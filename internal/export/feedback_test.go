@@ -0,0 +1,133 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggestionFeedbackExporter(t *testing.T) {
+	tester := newTester(t)
+	fileCreator := tester.newFileCreator(t).
+		CreateDirectory(image.Directory{ID: 1, Name: "dir1"}).
+		CreateDirectory(image.Directory{ID: 10, Name: "child 10", ParentID: 1}).
+		CreateImage(image.ImageFile{ID: 11, Name: "image11.jpg", ParentID: 1}, image.TestImageFileJpeg).
+		CreateImage(image.ImageFile{ID: 101, Name: "image101.jpg", ParentID: 10}, image.TestImageFileJpeg)
+
+	day1 := uint(time.Date(2024, 12, 1, 10, 0, 0, 0, time.UTC).Unix())
+	day2 := uint(time.Date(2024, 12, 2, 10, 0, 0, 0, time.UTC).Unix())
+	tester.dbClient.Truncate(t, db.File{}, db.Tag{}, db.FileTag{}, db.Character{}, db.FileCharacter{}, db.SuggestionEvent{})
+	db.LoadTestData(t, tester.dbClient, []db.File{
+		fileCreator.BuildDBDirectory(1),
+		fileCreator.BuildDBDirectory(10),
+		fileCreator.BuildDBImageFile(11),
+		fileCreator.BuildDBImageFile(101),
+	})
+	db.LoadTestData(t, tester.dbClient, []db.Tag{
+		{ID: 1, Name: "tag1"},
+		{ID: 2, Name: "tag2"},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.Character{
+		{ID: 1, Name: "Character 1", AnimeID: 1},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.FileTag{
+		// the tag was added after it was rejected
+		{FileID: 101, TagID: 2, AddedBy: db.FileTagAddedByUser},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.SuggestionEvent{
+		{FileID: 11, TagID: 1, Score: 0.9, ModelName: "model", ModelVersion: "v1", Decision: db.SuggestionDecisionAccepted, CreatedAt: day1},
+		{FileID: 11, TagID: 2, Score: 0.6, ModelName: "model", ModelVersion: "v1", Decision: db.SuggestionDecisionRejected, CreatedAt: day1},
+		{FileID: 101, TagID: 2, Score: 0.5, ModelName: "model", ModelVersion: "v1", Decision: db.SuggestionDecisionRejected, CreatedAt: day1},
+		{FileID: 101, CharacterID: 1, Score: 0.7, ModelName: "model", ModelVersion: "v2", Decision: db.SuggestionDecisionRejected, CreatedAt: day2},
+		{FileID: 101, TagID: 1, Score: 0.8, ModelName: "model", ModelVersion: "v2", Decision: db.SuggestionDecisionAccepted, CreatedAt: day2},
+		// the image was deleted
+		{FileID: 999, TagID: 1, Score: 0.4, ModelName: "model", ModelVersion: "v2", Decision: db.SuggestionDecisionRejected, CreatedAt: day2},
+	})
+	exporter := &SuggestionFeedbackExporter{
+		dbClient:        tester.dbClient.Client,
+		directoryReader: tester.getDirectoryReader(),
+	}
+
+	t.Run("export hard negatives", func(t *testing.T) {
+		testCases := []struct {
+			name         string
+			modelVersion string
+			want         []HardNegative
+		}{
+			{
+				name: "all model versions",
+				want: []HardNegative{
+					{OriginalPath: "dir1/image11.jpg", Tag: "tag2", Score: 0.6, ModelName: "model", ModelVersion: "v1", RejectedAt: time.Unix(int64(day1), 0).UTC()},
+					{OriginalPath: "dir1/child 10/image101.jpg", Character: "Character 1", Score: 0.7, ModelName: "model", ModelVersion: "v2", RejectedAt: time.Unix(int64(day2), 0).UTC()},
+				},
+			},
+			{
+				name:         "a model version",
+				modelVersion: "v2",
+				want: []HardNegative{
+					{OriginalPath: "dir1/child 10/image101.jpg", Character: "Character 1", Score: 0.7, ModelName: "model", ModelVersion: "v2", RejectedAt: time.Unix(int64(day2), 0).UTC()},
+				},
+			},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				var buffer bytes.Buffer
+				count, err := exporter.ExportHardNegatives(context.Background(), &buffer, tc.modelVersion)
+				require.NoError(t, err)
+				assert.Equal(t, len(tc.want), count)
+
+				got := make([]HardNegative, 0)
+				decoder := json.NewDecoder(&buffer)
+				for decoder.More() {
+					var hardNegative HardNegative
+					require.NoError(t, decoder.Decode(&hardNegative))
+					got = append(got, hardNegative)
+				}
+				assert.Equal(t, tc.want, got)
+			})
+		}
+	})
+
+	t.Run("measure precision", func(t *testing.T) {
+		got, err := exporter.MeasurePrecision(context.Background(), time.Unix(int64(day1), 0))
+		require.NoError(t, err)
+		assert.Equal(t, []SuggestionPrecision{
+			{ModelName: "model", ModelVersion: "v1", Date: "2024-12-01", Accepted: 1, Rejected: 2},
+			{ModelName: "model", ModelVersion: "v2", Date: "2024-12-02", Accepted: 1, Rejected: 2},
+		}, got)
+		assert.InDelta(t, 1.0/3, got[0].Precision(), 0.001)
+
+		got, err = exporter.MeasurePrecision(context.Background(), time.Unix(int64(day2), 0))
+		require.NoError(t, err)
+		assert.Len(t, got, 1)
+	})
+}
+
+func TestFindInBatches(t *testing.T) {
+	ids := make([]uint, feedbackBatchSize*2+1)
+	for i := range ids {
+		ids[i] = uint(i + 1)
+	}
+	batchSizes := make([]int, 0)
+	got, err := findInBatches(ids, func(ids []uint) ([]uint, error) {
+		batchSizes = append(batchSizes, len(ids))
+		return ids, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ids, got)
+	assert.Equal(t, []int{feedbackBatchSize, feedbackBatchSize, 1}, batchSizes)
+
+	got, err = findInBatches(nil, func(ids []uint) ([]uint, error) {
+		t.Fatal("no query for no IDs")
+		return nil, nil
+	})
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
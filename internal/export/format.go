@@ -0,0 +1,99 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/michael-freling/anime-image-viewer/internal/tag"
+)
+
+// ExportedImage is an image in an export, with its labels.
+type ExportedImage struct {
+	ImageFile  image.ImageFile
+	Metadata   Metadata
+	Tags       []tag.Tag
+	Characters []Character
+}
+
+// Format writes exported images and their labels into a split directory in
+// the layout a training tool expects.
+type Format interface {
+	// CopyImage writes an image into the split directory under
+	// Metadata.FileName. An incremental export skips it for images that
+	// have not changed.
+	CopyImage(splitDirectory string, exportedImage ExportedImage) error
+
+	// RemoveImage removes an image that is no longer exported from the split
+	// directory, with anything written for it.
+	RemoveImage(splitDirectory string, fileName string) error
+
+	// WriteLabels writes the labels of every image in the split. It is called
+	// on each export, because labels change without the image changing.
+	WriteLabels(splitDirectory string, exportedImages []ExportedImage) error
+}
+
+// fileNamer is implemented by a format which writes an image under another
+// name than exportFileName, e.g. with the extension of another image format.
+type fileNamer interface {
+	exportFileName(imageFile image.ImageFile) string
+}
+
+var (
+	_ fileNamer = (*CaptionFormat)(nil)
+	_ Format    = HuggingFaceFormat{}
+	_ Format    = (*CaptionFormat)(nil)
+	_ Format    = (*WebDatasetFormat)(nil)
+)
+
+// HuggingFaceFormat writes the labels into metadata.jsonl, which the
+// datasets library loads as the columns of an imagefolder dataset. This is
+// the default format.
+type HuggingFaceFormat struct{}
+
+func (format HuggingFaceFormat) CopyImage(splitDirectory string, exportedImage ExportedImage) error {
+	destinationFilePath := filepath.Join(splitDirectory, exportedImage.Metadata.FileName)
+	if _, err := image.Copy(exportedImage.ImageFile.LocalFilePath, destinationFilePath); err != nil {
+		return fmt.Errorf("copy: %w", err)
+	}
+	return nil
+}
+
+func (format HuggingFaceFormat) RemoveImage(splitDirectory string, fileName string) error {
+	return removeIfExists(filepath.Join(splitDirectory, fileName))
+}
+
+func (format HuggingFaceFormat) WriteLabels(splitDirectory string, exportedImages []ExportedImage) error {
+	metadataFile, err := os.OpenFile(
+		filepath.Join(splitDirectory, "metadata.jsonl"),
+		os.O_RDWR|os.O_CREATE|os.O_TRUNC,
+		0644,
+	)
+	if err != nil {
+		return fmt.Errorf("os.Open: %w", err)
+	}
+	defer metadataFile.Close()
+
+	buffer := bufio.NewWriter(metadataFile)
+	metadataJsonEncoder := json.NewEncoder(buffer)
+	for _, exportedImage := range exportedImages {
+		if err := metadataJsonEncoder.Encode(exportedImage.Metadata); err != nil {
+			return fmt.Errorf("json.Encode: %w", err)
+		}
+	}
+	if err := buffer.Flush(); err != nil {
+		return fmt.Errorf("buffer.Flush: %w", err)
+	}
+	return nil
+}
+
+func removeIfExists(filePath string) error {
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("os.Remove: %w", err)
+	}
+	return nil
+}
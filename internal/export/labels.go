@@ -0,0 +1,151 @@
+package export
+
+import (
+	"fmt"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+)
+
+// LabelFormat is how tags and characters are written in metadata.jsonl.
+type LabelFormat string
+
+const (
+	// LabelFormatDense writes a vector per image indexed by tag or character
+	// ID, with 1 for each label the image has. This is the default.
+	LabelFormatDense LabelFormat = "dense"
+	// LabelFormatSparse writes the names of the labels an image has.
+	LabelFormatSparse LabelFormat = "sparse"
+)
+
+func (format LabelFormat) validate() error {
+	switch format {
+	case "", LabelFormatDense, LabelFormatSparse:
+		return nil
+	}
+	return fmt.Errorf("unknown label format: %q", format)
+}
+
+// Character is an entry of characters.json, the vocabulary of the character
+// labels.
+type Character struct {
+	ID      uint   `json:"id"`
+	Name    string `json:"name"`
+	AnimeID uint   `json:"anime_id"`
+	Anime   string `json:"anime"`
+}
+
+func getMaxCharacterID(characters []Character) uint {
+	maxID := uint(0)
+	for _, character := range characters {
+		if character.ID > maxID {
+			maxID = character.ID
+		}
+	}
+	return maxID
+}
+
+func (batchExporter BatchImageExporter) readAllCharacters() ([]Character, error) {
+	dbCharacters, err := db.GetAll[db.Character](batchExporter.dbClient)
+	if err != nil {
+		return nil, fmt.Errorf("db.GetAll[db.Character]: %w", err)
+	}
+	animeNames, err := batchExporter.readAnimeNames()
+	if err != nil {
+		return nil, fmt.Errorf("readAnimeNames: %w", err)
+	}
+
+	result := make([]Character, len(dbCharacters))
+	for i, dbCharacter := range dbCharacters {
+		result[i] = Character{
+			ID:      dbCharacter.ID,
+			Name:    dbCharacter.Name,
+			AnimeID: dbCharacter.AnimeID,
+			Anime:   animeNames[dbCharacter.AnimeID],
+		}
+	}
+	return result, nil
+}
+
+func (batchExporter BatchImageExporter) readAnimeNames() (map[uint]string, error) {
+	animeList, err := db.GetAll[db.Anime](batchExporter.dbClient)
+	if err != nil {
+		return nil, fmt.Errorf("db.GetAll[db.Anime]: %w", err)
+	}
+	result := make(map[uint]string, len(animeList))
+	for _, anime := range animeList {
+		result[anime.ID] = anime.Name
+	}
+	return result, nil
+}
+
+// animeContext is the anime and season an image belongs to. The season is
+// the closest ancestor folder with a season type, and the anime is the one
+// assigned to the closest ancestor folder.
+type animeContext struct {
+	animeID   uint
+	animeName string
+	season    *db.File
+}
+
+func (animeContext animeContext) applyTo(metadata *Metadata) {
+	metadata.AnimeID = animeContext.animeID
+	metadata.Anime = animeContext.animeName
+	if animeContext.season == nil {
+		return
+	}
+	metadata.Season = animeContext.season.Name
+	metadata.SeasonType = animeContext.season.SeasonType
+	metadata.SeasonNumber = animeContext.season.SeasonNumber
+	metadata.AiringSeason = animeContext.season.AiringSeason
+	metadata.AiringYear = animeContext.season.AiringYear
+}
+
+// readAnimeContexts returns the anime context of each directory, by
+// directory ID.
+func (batchExporter BatchImageExporter) readAnimeContexts(rootDirectory image.Directory) (map[uint]animeContext, error) {
+	directoryIDs := make([]uint, 0)
+	for directoryID := range rootDirectory.ToFlatIDMap() {
+		directoryIDs = append(directoryIDs, directoryID)
+	}
+	dbDirectories, err := batchExporter.dbClient.File().FindDirectoriesByIDs(directoryIDs)
+	if err != nil {
+		return nil, fmt.Errorf("File.FindDirectoriesByIDs: %w", err)
+	}
+	dbDirectoryMap := make(map[uint]*db.File, len(dbDirectories))
+	for i := range dbDirectories {
+		dbDirectoryMap[dbDirectories[i].ID] = &dbDirectories[i]
+	}
+	animeNames, err := batchExporter.readAnimeNames()
+	if err != nil {
+		return nil, fmt.Errorf("readAnimeNames: %w", err)
+	}
+
+	result := make(map[uint]animeContext, len(directoryIDs))
+	resolveAnimeContexts(&rootDirectory, animeContext{}, dbDirectoryMap, animeNames, result)
+	return result, nil
+}
+
+func resolveAnimeContexts(
+	directory *image.Directory,
+	inherited animeContext,
+	dbDirectories map[uint]*db.File,
+	animeNames map[uint]string,
+	result map[uint]animeContext,
+) {
+	if dbDirectory, ok := dbDirectories[directory.ID]; ok {
+		if dbDirectory.AnimeID != nil {
+			inherited = animeContext{
+				animeID:   *dbDirectory.AnimeID,
+				animeName: animeNames[*dbDirectory.AnimeID],
+			}
+		}
+		if dbDirectory.SeasonType != "" {
+			inherited.season = dbDirectory
+		}
+	}
+	result[directory.ID] = inherited
+	for _, child := range directory.Children {
+		resolveAnimeContexts(child, inherited, dbDirectories, animeNames, result)
+	}
+}
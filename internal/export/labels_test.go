@@ -0,0 +1,152 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchImageExporter_Export_Labels(t *testing.T) {
+	tester := newTester(t)
+	fileCreator := tester.newFileCreator(t)
+	for _, directory := range []image.Directory{
+		{ID: 1, Name: "Frieren"},
+		{ID: 10, Name: "Season 1", ParentID: 1},
+		{ID: 2, Name: "unsorted"},
+	} {
+		fileCreator.CreateDirectory(directory)
+	}
+	fileCreator.CreateImage(image.ImageFile{ID: 11, Name: "a.jpg", ParentID: 10}, image.TestImageFileJpeg)
+	fileCreator.CreateImage(image.ImageFile{ID: 21, Name: "b.jpg", ParentID: 2}, image.TestImageFileJpeg)
+
+	animeID := uint(1)
+	seasonNumber := uint(1)
+	airingYear := uint(2023)
+	animeDirectory := fileCreator.BuildDBDirectory(1)
+	animeDirectory.AnimeID = &animeID
+	seasonDirectory := fileCreator.BuildDBDirectory(10)
+	seasonDirectory.SeasonType = "season"
+	seasonDirectory.SeasonNumber = &seasonNumber
+	seasonDirectory.AiringSeason = "FALL"
+	seasonDirectory.AiringYear = &airingYear
+
+	tester.dbClient.Truncate(t, &db.File{}, &db.Tag{}, &db.FileTag{}, &db.Anime{}, &db.Character{}, &db.FileCharacter{})
+	db.LoadTestData(t, tester.dbClient, []db.File{
+		animeDirectory,
+		seasonDirectory,
+		fileCreator.BuildDBDirectory(2),
+		fileCreator.BuildDBImageFile(11),
+		fileCreator.BuildDBImageFile(21),
+	})
+	db.LoadTestData(t, tester.dbClient, []db.Anime{{ID: 1, Name: "Frieren"}})
+	db.LoadTestData(t, tester.dbClient, []db.Tag{
+		{ID: 1, Name: "smile"},
+		{ID: 2, Name: "night"},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.FileTag{
+		{FileID: 11, TagID: 1},
+		{FileID: 11, TagID: 2},
+		{FileID: 21, TagID: 2},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.Character{
+		{ID: 1, Name: "Frieren", AnimeID: 1},
+		{ID: 3, Name: "Fern", AnimeID: 1},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.FileCharacter{
+		{FileID: 11, CharacterID: 3},
+		{FileID: 11, CharacterID: 1},
+	})
+
+	seasonMetadata := Metadata{
+		AnimeID:      1,
+		Anime:        "Frieren",
+		Season:       "Season 1",
+		SeasonType:   "season",
+		SeasonNumber: &seasonNumber,
+		AiringSeason: "FALL",
+		AiringYear:   &airingYear,
+	}
+	testCases := []struct {
+		name          string
+		labelFormat   LabelFormat
+		wantMetadatas []Metadata
+	}{
+		{
+			name: "dense vectors by default",
+			wantMetadatas: []Metadata{
+				func() Metadata {
+					metadata := seasonMetadata
+					metadata.FileName = "11.jpg"
+					metadata.OriginalPath = "Frieren/Season 1/a.jpg"
+					metadata.Tags = []float64{0, 1, 1}
+					metadata.Characters = []float64{0, 1, 0, 1}
+					return metadata
+				}(),
+				{
+					FileName:     "21.jpg",
+					OriginalPath: "unsorted/b.jpg",
+					Tags:         []float64{0, 0, 1},
+					Characters:   []float64{0, 0, 0, 0},
+				},
+			},
+		},
+		{
+			name:        "sparse label names",
+			labelFormat: LabelFormatSparse,
+			wantMetadatas: []Metadata{
+				func() Metadata {
+					metadata := seasonMetadata
+					metadata.FileName = "11.jpg"
+					metadata.OriginalPath = "Frieren/Season 1/a.jpg"
+					metadata.TagNames = []string{"smile", "night"}
+					metadata.CharacterNames = []string{"Frieren", "Fern"}
+					return metadata
+				}(),
+				{
+					FileName:     "21.jpg",
+					OriginalPath: "unsorted/b.jpg",
+					TagNames:     []string{"night"},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exportDirectory := t.TempDir()
+			batchExporter := tester.getBatchImageExporter(BatchImageExporterOptions{
+				LabelFormat:           tc.labelFormat,
+				progressSleepDuration: time.Millisecond,
+			})
+			require.NoError(t, batchExporter.Export(context.Background(), exportDirectory))
+
+			gotMetadatas := readMetadataFile(t, filepath.Join(exportDirectory, "train", "metadata.jsonl"))
+			assert.ElementsMatch(t, tc.wantMetadatas, gotMetadatas)
+
+			content, err := os.ReadFile(filepath.Join(exportDirectory, "characters.json"))
+			require.NoError(t, err)
+			var gotCharacters []Character
+			require.NoError(t, json.Unmarshal(content, &gotCharacters))
+			assert.Equal(t, []Character{
+				{ID: 1, Name: "Frieren", AnimeID: 1, Anime: "Frieren"},
+				{ID: 3, Name: "Fern", AnimeID: 1, Anime: "Frieren"},
+			}, gotCharacters)
+		})
+	}
+
+	t.Run("unknown label format", func(t *testing.T) {
+		batchExporter := tester.getBatchImageExporter(BatchImageExporterOptions{
+			LabelFormat: "one-hot",
+		})
+		err := batchExporter.Export(context.Background(), t.TempDir())
+		assert.ErrorIs(t, err, xerrors.ErrInvalidArgument)
+	})
+}
@@ -0,0 +1,75 @@
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+)
+
+// manifestFileName is written at the root of an export directory and records
+// where each exported image came from, so that an incremental export can
+// skip images that have not changed since.
+const manifestFileName = "manifest.json"
+
+type manifestEntry struct {
+	Split    string `json:"split"`
+	FileName string `json:"file_name"`
+
+	// Size and ModifiedAt are of the source image when it was copied.
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// manifest is keyed by image file ID.
+type manifest map[uint]manifestEntry
+
+// exportFileName is the name of an image in an export. It is derived from
+// the file ID, so it is unique across directories and does not change when
+// the image is renamed.
+func exportFileName(imageFile image.ImageFile) string {
+	return fmt.Sprintf("%d%s", imageFile.ID, strings.ToLower(filepath.Ext(imageFile.Name)))
+}
+
+// isUnchanged reports whether the image was already exported to the split
+// and has not been modified since.
+func (entry manifestEntry) isUnchanged(split string, fileName string, source os.FileInfo) bool {
+	return entry.Split == split &&
+		entry.FileName == fileName &&
+		entry.Size == source.Size() &&
+		entry.ModifiedAt.Equal(source.ModTime())
+}
+
+// readManifest returns an empty manifest if the directory has not been
+// exported to yet.
+func readManifest(exportDirectory string) (manifest, error) {
+	content, err := os.ReadFile(filepath.Join(exportDirectory, manifestFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	result := manifest{}
+	if err := json.Unmarshal(content, &result); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	return result, nil
+}
+
+func writeManifest(exportDirectory string, entries manifest) error {
+	content, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(exportDirectory, manifestFileName), content, 0644); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+	return nil
+}
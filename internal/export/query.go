@@ -0,0 +1,119 @@
+package export
+
+import (
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/michael-freling/anime-image-viewer/internal/tag"
+)
+
+// Query selects the images to export. An image is exported only if it
+// matches every condition that is set, so the zero value exports the whole
+// library.
+type Query struct {
+	// AnimeIDs selects the images of any of the anime.
+	AnimeIDs []uint `json:"animeIds"`
+	// DirectoryIDs selects the images under any of the folders, e.g. some
+	// seasons of an anime.
+	DirectoryIDs []uint `json:"directoryIds"`
+	// ImageFileIDs selects the images by ID.
+	ImageFileIDs []uint `json:"imageFileIds"`
+	// TagIDs selects the images that have all of the tags.
+	TagIDs []uint `json:"tagIds"`
+	// ExcludedTagIDs leaves out the images that have any of the tags.
+	ExcludedTagIDs []uint `json:"excludedTagIds"`
+	// CharacterIDs selects the images of all of the characters.
+	CharacterIDs []uint `json:"characterIds"`
+}
+
+// queryMatcher tells whether an image matches a Query.
+type queryMatcher struct {
+	query             Query
+	animeIDs          map[uint]struct{}
+	directoryIDs      map[uint]struct{}
+	imageFileIDs      map[uint]struct{}
+	animeContexts     map[uint]animeContext
+	imageCharacterIDs map[uint][]uint
+	batchTagChecker   tag.BatchImageTagChecker
+}
+
+func newQueryMatcher(
+	query Query,
+	rootDirectory image.Directory,
+	animeContexts map[uint]animeContext,
+	imageCharacterIDs map[uint][]uint,
+	batchTagChecker tag.BatchImageTagChecker,
+) queryMatcher {
+	matcher := queryMatcher{
+		query:             query,
+		animeIDs:          toSet(query.AnimeIDs),
+		imageFileIDs:      toSet(query.ImageFileIDs),
+		animeContexts:     animeContexts,
+		imageCharacterIDs: imageCharacterIDs,
+		batchTagChecker:   batchTagChecker,
+	}
+	if len(query.DirectoryIDs) > 0 {
+		matcher.directoryIDs = make(map[uint]struct{})
+		selectDirectories(&rootDirectory, false, toSet(query.DirectoryIDs), matcher.directoryIDs)
+	}
+	return matcher
+}
+
+// selectDirectories adds the selected directories and their descendants to
+// result.
+func selectDirectories(directory *image.Directory, isSelected bool, selected map[uint]struct{}, result map[uint]struct{}) {
+	if _, ok := selected[directory.ID]; ok {
+		isSelected = true
+	}
+	if isSelected {
+		result[directory.ID] = struct{}{}
+	}
+	for _, child := range directory.Children {
+		selectDirectories(child, isSelected, selected, result)
+	}
+}
+
+func (matcher queryMatcher) matches(imageFile image.ImageFile) bool {
+	if len(matcher.query.AnimeIDs) > 0 {
+		if _, ok := matcher.animeIDs[matcher.animeContexts[imageFile.ParentID].animeID]; !ok {
+			return false
+		}
+	}
+	if len(matcher.query.DirectoryIDs) > 0 {
+		if _, ok := matcher.directoryIDs[imageFile.ParentID]; !ok {
+			return false
+		}
+	}
+	if len(matcher.query.ImageFileIDs) > 0 {
+		if _, ok := matcher.imageFileIDs[imageFile.ID]; !ok {
+			return false
+		}
+	}
+
+	tagChecker := matcher.batchTagChecker.GetTagCheckerForImageFileID(imageFile.ID)
+	for _, tagID := range matcher.query.TagIDs {
+		if !tagChecker.HasTag(tagID) {
+			return false
+		}
+	}
+	for _, tagID := range matcher.query.ExcludedTagIDs {
+		if tagChecker.HasTag(tagID) {
+			return false
+		}
+	}
+	if len(matcher.query.CharacterIDs) > 0 {
+		characterIDs := toSet(matcher.imageCharacterIDs[imageFile.ID])
+		for _, characterID := range matcher.query.CharacterIDs {
+			if _, ok := characterIDs[characterID]; !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func toSet(ids []uint) map[uint]struct{} {
+	result := make(map[uint]struct{}, len(ids))
+	for _, id := range ids {
+		result[id] = struct{}{}
+	}
+	return result
+}
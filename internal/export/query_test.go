@@ -0,0 +1,152 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/michael-freling/anime-image-viewer/internal/tag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchImageExporter_Export_Query(t *testing.T) {
+	tester := newTester(t)
+	fileCreator := tester.newFileCreator(t)
+	for _, directory := range []image.Directory{
+		{ID: 1, Name: "Frieren"},
+		{ID: 10, Name: "Season 1", ParentID: 1},
+		{ID: 20, Name: "Season 2", ParentID: 1},
+		{ID: 2, Name: "Bocchi the Rock!"},
+	} {
+		fileCreator.CreateDirectory(directory)
+	}
+	fileCreator.CreateImage(image.ImageFile{ID: 11, Name: "a.jpg", ParentID: 10}, image.TestImageFileJpeg)
+	fileCreator.CreateImage(image.ImageFile{ID: 21, Name: "b.jpg", ParentID: 20}, image.TestImageFileJpeg)
+	fileCreator.CreateImage(image.ImageFile{ID: 31, Name: "c.jpg", ParentID: 2}, image.TestImageFileJpeg)
+
+	frierenID, bocchiID := uint(1), uint(2)
+	frierenDirectory := fileCreator.BuildDBDirectory(1)
+	frierenDirectory.AnimeID = &frierenID
+	bocchiDirectory := fileCreator.BuildDBDirectory(2)
+	bocchiDirectory.AnimeID = &bocchiID
+	tester.dbClient.Truncate(t, &db.File{}, &db.Tag{}, &db.FileTag{}, &db.Anime{}, &db.Character{}, &db.FileCharacter{})
+	db.LoadTestData(t, tester.dbClient, []db.File{
+		frierenDirectory,
+		fileCreator.BuildDBDirectory(10),
+		fileCreator.BuildDBDirectory(20),
+		bocchiDirectory,
+		fileCreator.BuildDBImageFile(11),
+		fileCreator.BuildDBImageFile(21),
+		fileCreator.BuildDBImageFile(31),
+	})
+	db.LoadTestData(t, tester.dbClient, []db.Anime{
+		{ID: 1, Name: "Frieren"},
+		{ID: 2, Name: "Bocchi the Rock!"},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.Tag{
+		{ID: 1, Name: "smile"},
+		{ID: 2, Name: "night"},
+		{ID: 3, Name: "unused"},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.FileTag{
+		{FileID: 11, TagID: 1},
+		{FileID: 21, TagID: 1},
+		{FileID: 21, TagID: 2},
+		{FileID: 31, TagID: 2},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.Character{{ID: 1, Name: "Fern", AnimeID: 1}})
+	db.LoadTestData(t, tester.dbClient, []db.FileCharacter{{FileID: 11, CharacterID: 1}})
+
+	testCases := []struct {
+		name          string
+		query         Query
+		wantFileNames []string
+		wantTagIDs    []uint
+	}{
+		{
+			name:          "whole library",
+			wantFileNames: []string{"11.jpg", "21.jpg", "31.jpg"},
+			wantTagIDs:    []uint{1, 2},
+		},
+		{
+			name:          "an anime",
+			query:         Query{AnimeIDs: []uint{1}},
+			wantFileNames: []string{"11.jpg", "21.jpg"},
+			wantTagIDs:    []uint{1, 2},
+		},
+		{
+			name:          "a season",
+			query:         Query{DirectoryIDs: []uint{20}},
+			wantFileNames: []string{"21.jpg"},
+			wantTagIDs:    []uint{1, 2},
+		},
+		{
+			name:          "image IDs",
+			query:         Query{ImageFileIDs: []uint{11, 31}},
+			wantFileNames: []string{"11.jpg", "31.jpg"},
+			wantTagIDs:    []uint{1, 2},
+		},
+		{
+			name:          "all of the tags",
+			query:         Query{TagIDs: []uint{1, 2}},
+			wantFileNames: []string{"21.jpg"},
+			wantTagIDs:    []uint{1, 2},
+		},
+		{
+			name:          "excluded tags",
+			query:         Query{ExcludedTagIDs: []uint{2}},
+			wantFileNames: []string{"11.jpg"},
+			wantTagIDs:    []uint{1},
+		},
+		{
+			name:          "a character",
+			query:         Query{CharacterIDs: []uint{1}},
+			wantFileNames: []string{"11.jpg"},
+			wantTagIDs:    []uint{1},
+		},
+		{
+			name:          "conditions are combined",
+			query:         Query{DirectoryIDs: []uint{1, 2}, TagIDs: []uint{2}},
+			wantFileNames: []string{"21.jpg", "31.jpg"},
+			wantTagIDs:    []uint{1, 2},
+		},
+		{
+			name:          "nothing matches",
+			query:         Query{AnimeIDs: []uint{2}, CharacterIDs: []uint{1}},
+			wantFileNames: []string{},
+			wantTagIDs:    []uint{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exportDirectory := t.TempDir()
+			batchExporter := tester.getBatchImageExporter(BatchImageExporterOptions{
+				Query:                 tc.query,
+				progressSleepDuration: time.Millisecond,
+			})
+			require.NoError(t, batchExporter.Export(context.Background(), exportDirectory))
+
+			gotFileNames := make([]string, 0)
+			for _, metadata := range readMetadataFile(t, filepath.Join(exportDirectory, "train", "metadata.jsonl")) {
+				gotFileNames = append(gotFileNames, metadata.FileName)
+			}
+			assert.ElementsMatch(t, tc.wantFileNames, gotFileNames)
+
+			content, err := os.ReadFile(filepath.Join(exportDirectory, "tags.json"))
+			require.NoError(t, err)
+			var gotTags []tag.Tag
+			require.NoError(t, json.Unmarshal(content, &gotTags))
+			gotTagIDs := make([]uint, 0)
+			for _, gotTag := range gotTags {
+				gotTagIDs = append(gotTagIDs, gotTag.ID)
+			}
+			assert.Equal(t, tc.wantTagIDs, gotTagIDs)
+		})
+	}
+}
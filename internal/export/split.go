@@ -0,0 +1,324 @@
+package export
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"sort"
+
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+)
+
+const (
+	trainSplit      = "train"
+	validationSplit = "validation"
+	testSplit       = "test"
+
+	// nearDuplicateDistance is the largest perceptual hash distance at which
+	// two images are treated as the same picture, e.g. a re-encoded or
+	// resized copy.
+	nearDuplicateDistance = 6
+	// perceptualHashSegments is how many parts a perceptual hash is split
+	// into to find near duplicates. Two hashes within nearDuplicateDistance
+	// have at least one equal part, so only images sharing a part are
+	// compared.
+	perceptualHashSegments = nearDuplicateDistance + 1
+	// maxNearDuplicateGroupSize stops near duplicates, like the frames of a
+	// still scene, from chaining into a group too large to be balanced
+	// between the splits.
+	maxNearDuplicateGroupSize = 256
+)
+
+// SplitOptions divides the exported images into train, validation and test
+// splits. Ratios are relative weights and do not have to add up to 1; the
+// zero value exports everything into train. The same Seed and library
+// always produce the same splits.
+type SplitOptions struct {
+	TrainRatio      float64
+	ValidationRatio float64
+	TestRatio       float64
+	Seed            uint64
+}
+
+// splits returns the splits with a non-zero ratio and their share of the
+// images.
+func (options SplitOptions) splits() ([]string, []float64, error) {
+	ratios := map[string]float64{
+		trainSplit:      options.TrainRatio,
+		validationSplit: options.ValidationRatio,
+		testSplit:       options.TestRatio,
+	}
+	var total float64
+	for split, ratio := range ratios {
+		if ratio < 0 {
+			return nil, nil, fmt.Errorf("%s ratio must not be negative: %v", split, ratio)
+		}
+		total += ratio
+	}
+	if total == 0 {
+		return []string{trainSplit}, []float64{1}, nil
+	}
+
+	splits := make([]string, 0, len(ratios))
+	shares := make([]float64, 0, len(ratios))
+	for _, split := range []string{trainSplit, validationSplit, testSplit} {
+		if ratios[split] == 0 {
+			continue
+		}
+		splits = append(splits, split)
+		shares = append(shares, ratios[split]/total)
+	}
+	return splits, shares, nil
+}
+
+// splitSample is an exported image with what decides its split.
+type splitSample struct {
+	imageFile    image.ImageFile
+	tagIDs       []uint
+	characterIDs []uint
+	animeID      uint
+
+	// Images with the same content hash, episode key or a close perceptual
+	// hash are kept in one split so that the model is not evaluated on what
+	// it was trained on.
+	contentHash       string
+	episodeKey        string
+	perceptualHash    uint64
+	hasPerceptualHash bool
+}
+
+// episodeNumberPattern finds the episode number in names such as
+// "frieren_ep03_0012.jpg", "S01E03 123.png" or "Frieren - 03 - 0012.jpg".
+var episodeNumberPattern = regexp.MustCompile(`(?i)(?:(?:^|[^a-z])(?:e|ep|episode)[ _.-]?(\d{1,4})|\s-\s(\d{1,4})(?:\D|$))`)
+
+// episodeKey returns a key shared by frames of one episode in one folder, or
+// "" if the name has no episode number.
+func episodeKey(imageFile image.ImageFile) string {
+	match := episodeNumberPattern.FindStringSubmatch(imageFile.Name)
+	if match == nil {
+		return ""
+	}
+	number := match[1]
+	if number == "" {
+		number = match[2]
+	}
+	return fmt.Sprintf("%d/%s", imageFile.ParentID, number)
+}
+
+// perceptualHashSegment returns the index-th of the perceptualHashSegments
+// parts of a hash, with the index in the top byte so that equal bits of
+// different parts are different keys.
+func perceptualHashSegment(hash uint64, index int) uint64 {
+	start := index * 64 / perceptualHashSegments
+	end := (index + 1) * 64 / perceptualHashSegments
+	mask := uint64(1)<<(end-start) - 1
+	return uint64(index)<<56 | (hash>>start)&mask
+}
+
+// groupSamples returns the indexes of samples that have to stay in one split
+// together. Near duplicates are grouped up to maxNearDuplicateGroupSize
+// images, while images with the same content hash or episode key always are.
+func groupSamples(samples []splitSample) [][]int {
+	parents := make([]int, len(samples))
+	sizes := make([]int, len(samples))
+	for i := range parents {
+		parents[i] = i
+		sizes[i] = 1
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+	union := func(i, j int, maxSize int) {
+		rootI, rootJ := find(i), find(j)
+		if rootI == rootJ || (maxSize > 0 && sizes[rootI]+sizes[rootJ] > maxSize) {
+			return
+		}
+		if rootI < rootJ {
+			parents[rootJ] = rootI
+			sizes[rootI] += sizes[rootJ]
+		} else {
+			parents[rootI] = rootJ
+			sizes[rootJ] += sizes[rootI]
+		}
+	}
+
+	byContentHash := make(map[string]int)
+	byEpisode := make(map[string]int)
+	byPerceptualHash := make(map[uint64]int)
+	// the samples of distinct perceptual hashes by the parts of their hashes
+	bySegment := make(map[uint64][]int)
+	for i, sample := range samples {
+		if sample.contentHash != "" {
+			if j, ok := byContentHash[sample.contentHash]; ok {
+				union(i, j, 0)
+			} else {
+				byContentHash[sample.contentHash] = i
+			}
+		}
+		if sample.episodeKey != "" {
+			if j, ok := byEpisode[sample.episodeKey]; ok {
+				union(i, j, 0)
+			} else {
+				byEpisode[sample.episodeKey] = i
+			}
+		}
+		if !sample.hasPerceptualHash {
+			continue
+		}
+		if j, ok := byPerceptualHash[sample.perceptualHash]; ok {
+			union(i, j, maxNearDuplicateGroupSize)
+			continue
+		}
+		byPerceptualHash[sample.perceptualHash] = i
+
+		isCompared := make(map[int]bool)
+		for index := 0; index < perceptualHashSegments; index++ {
+			segment := perceptualHashSegment(sample.perceptualHash, index)
+			for _, j := range bySegment[segment] {
+				if isCompared[j] {
+					continue
+				}
+				isCompared[j] = true
+				if image.HammingDistance(sample.perceptualHash, samples[j].perceptualHash) <= nearDuplicateDistance {
+					union(i, j, maxNearDuplicateGroupSize)
+				}
+			}
+			bySegment[segment] = append(bySegment[segment], i)
+		}
+	}
+
+	groupIndexes := make(map[int]int)
+	groups := make([][]int, 0)
+	for i := range samples {
+		root := find(i)
+		index, ok := groupIndexes[root]
+		if !ok {
+			index = len(groups)
+			groupIndexes[root] = index
+			groups = append(groups, nil)
+		}
+		groups[index] = append(groups[index], i)
+	}
+	return groups
+}
+
+// sampleLabels are what the splits are stratified by: each tag and character,
+// and the anime an image belongs to.
+func sampleLabels(sample splitSample) []string {
+	labels := make([]string, 0, len(sample.tagIDs)+len(sample.characterIDs)+1)
+	for _, tagID := range sample.tagIDs {
+		labels = append(labels, fmt.Sprintf("tag:%d", tagID))
+	}
+	for _, characterID := range sample.characterIDs {
+		labels = append(labels, fmt.Sprintf("character:%d", characterID))
+	}
+	if sample.animeID != 0 {
+		labels = append(labels, fmt.Sprintf("anime:%d", sample.animeID))
+	}
+	return labels
+}
+
+// assignSplits returns the split of each sample, by image file ID.
+//
+// Groups of samples are assigned one at a time, those with the rarest label
+// first, each to the split that still lacks the most images of that label
+// for its share. A label with at least as many groups as there are splits is
+// first given one group in every split, so that rare tags can be evaluated.
+func assignSplits(samples []splitSample, splits []string, shares []float64, seed uint64) map[uint]string {
+	result := make(map[uint]string, len(samples))
+	if len(splits) == 1 {
+		for _, sample := range samples {
+			result[sample.imageFile.ID] = splits[0]
+		}
+		return result
+	}
+
+	groups := groupSamples(samples)
+	labelCounts := make(map[string]int)
+	labelGroupCounts := make(map[string]int)
+	groupLabels := make([]map[string]int, len(groups))
+	for g, group := range groups {
+		groupLabels[g] = make(map[string]int)
+		for _, i := range group {
+			for _, label := range sampleLabels(samples[i]) {
+				groupLabels[g][label]++
+				labelCounts[label]++
+			}
+		}
+		for label := range groupLabels[g] {
+			labelGroupCounts[label]++
+		}
+	}
+
+	rarestLabel := func(g int) (string, int) {
+		rarest, count := "", len(samples)+1
+		for label := range groupLabels[g] {
+			if labelCounts[label] < count || (labelCounts[label] == count && label < rarest) {
+				rarest, count = label, labelCounts[label]
+			}
+		}
+		return rarest, count
+	}
+	order := rand.New(rand.NewPCG(seed, seed)).Perm(len(groups))
+	sort.SliceStable(order, func(i, j int) bool {
+		_, countI := rarestLabel(order[i])
+		_, countJ := rarestLabel(order[j])
+		return countI < countJ
+	})
+
+	labelDemands := make([]map[string]float64, len(splits))
+	sizeDemands := make([]float64, len(splits))
+	labelSeen := make([]map[string]int, len(splits))
+	for s, share := range shares {
+		labelDemands[s] = make(map[string]float64, len(labelCounts))
+		for label, count := range labelCounts {
+			labelDemands[s][label] = share * float64(count)
+		}
+		sizeDemands[s] = share * float64(len(samples))
+		labelSeen[s] = make(map[string]int)
+	}
+
+	for _, g := range order {
+		label, _ := rarestLabel(g)
+		candidates := make([]int, 0, len(splits))
+		if label != "" && labelGroupCounts[label] >= len(splits) {
+			for s := range splits {
+				if labelSeen[s][label] == 0 {
+					candidates = append(candidates, s)
+				}
+			}
+		}
+		if len(candidates) == 0 {
+			for s := range splits {
+				candidates = append(candidates, s)
+			}
+		}
+
+		best := candidates[0]
+		for _, s := range candidates[1:] {
+			if label != "" && labelDemands[s][label] != labelDemands[best][label] {
+				if labelDemands[s][label] > labelDemands[best][label] {
+					best = s
+				}
+				continue
+			}
+			if sizeDemands[s] > sizeDemands[best] {
+				best = s
+			}
+		}
+
+		for label, count := range groupLabels[g] {
+			labelDemands[best][label] -= float64(count)
+			labelSeen[best][label]++
+		}
+		sizeDemands[best] -= float64(len(groups[g]))
+		for _, i := range groups[g] {
+			result[samples[i].imageFile.ID] = splits[best]
+		}
+	}
+	return result
+}
@@ -0,0 +1,229 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitOptions_splits(t *testing.T) {
+	testCases := []struct {
+		name       string
+		options    SplitOptions
+		wantSplits []string
+		wantShares []float64
+		wantErr    bool
+	}{
+		{
+			name:       "zero value exports everything to train",
+			wantSplits: []string{"train"},
+			wantShares: []float64{1},
+		},
+		{
+			name:       "ratios are normalized",
+			options:    SplitOptions{TrainRatio: 8, ValidationRatio: 1, TestRatio: 1},
+			wantSplits: []string{"train", "validation", "test"},
+			wantShares: []float64{0.8, 0.1, 0.1},
+		},
+		{
+			name:       "a zero ratio drops the split",
+			options:    SplitOptions{TrainRatio: 0.75, TestRatio: 0.25},
+			wantSplits: []string{"train", "test"},
+			wantShares: []float64{0.75, 0.25},
+		},
+		{
+			name:    "negative ratio",
+			options: SplitOptions{TrainRatio: 1, TestRatio: -1},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotSplits, gotShares, err := tc.options.splits()
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantSplits, gotSplits)
+			assert.InDeltaSlice(t, tc.wantShares, gotShares, 1e-9)
+		})
+	}
+}
+
+func TestEpisodeKey(t *testing.T) {
+	testCases := []struct {
+		name string
+		want string
+	}{
+		{name: "frieren_ep03_0012.jpg", want: "7/03"},
+		{name: "S01E12 0001.png", want: "7/12"},
+		{name: "Frieren - 03 - 0012.jpg", want: "7/03"},
+		{name: "Episode 4.jpg", want: "7/4"},
+		{name: "image11.jpg", want: ""},
+		{name: "scene12.jpg", want: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, episodeKey(image.ImageFile{Name: tc.name, ParentID: 7}))
+		})
+	}
+}
+
+func TestGroupSamples(t *testing.T) {
+	samples := []splitSample{
+		{imageFile: image.ImageFile{ID: 1}, contentHash: "a"},
+		{imageFile: image.ImageFile{ID: 2}, contentHash: "a"},
+		{imageFile: image.ImageFile{ID: 3}, episodeKey: "1/03"},
+		{imageFile: image.ImageFile{ID: 4}, episodeKey: "1/03", perceptualHash: 0xff, hasPerceptualHash: true},
+		{imageFile: image.ImageFile{ID: 5}, perceptualHash: 0xfe, hasPerceptualHash: true},
+		{imageFile: image.ImageFile{ID: 6}, perceptualHash: 0xff00ff00, hasPerceptualHash: true},
+		{imageFile: image.ImageFile{ID: 7}},
+	}
+	assert.Equal(t, [][]int{{0, 1}, {2, 3, 4}, {5}, {6}}, groupSamples(samples))
+
+	t.Run("near duplicates differing in every part of the hash", func(t *testing.T) {
+		// a bit flipped in all but one of the parts
+		var hash uint64
+		for index := 0; index < perceptualHashSegments-1; index++ {
+			hash |= 1 << (index * 64 / perceptualHashSegments)
+		}
+		samples := []splitSample{
+			{imageFile: image.ImageFile{ID: 1}, perceptualHash: 0, hasPerceptualHash: true},
+			{imageFile: image.ImageFile{ID: 2}, perceptualHash: hash, hasPerceptualHash: true},
+			{imageFile: image.ImageFile{ID: 3}, perceptualHash: hash | 1<<63, hasPerceptualHash: true},
+		}
+		assert.Equal(t, [][]int{{0, 1, 2}}, groupSamples(samples))
+	})
+
+	t.Run("near duplicates are grouped up to a size", func(t *testing.T) {
+		samples := make([]splitSample, maxNearDuplicateGroupSize+1)
+		for i := range samples {
+			samples[i] = splitSample{
+				imageFile:         image.ImageFile{ID: uint(i + 1)},
+				perceptualHash:    0xff,
+				hasPerceptualHash: true,
+			}
+		}
+		groups := groupSamples(samples)
+		require.Len(t, groups, 2)
+		assert.Len(t, groups[0], maxNearDuplicateGroupSize)
+		assert.Equal(t, []int{maxNearDuplicateGroupSize}, groups[1])
+	})
+}
+
+func TestAssignSplits(t *testing.T) {
+	splits := []string{"train", "validation", "test"}
+	shares := []float64{0.6, 0.2, 0.2}
+
+	// 30 images of two anime; tag 1 is on every image and tag 2 on only
+	// three, all of anime 2.
+	samples := make([]splitSample, 0)
+	for id := uint(1); id <= 30; id++ {
+		sample := splitSample{
+			imageFile: image.ImageFile{ID: id},
+			tagIDs:    []uint{1},
+			animeID:   1 + id%2,
+		}
+		if id%10 == 0 {
+			sample.tagIDs = append(sample.tagIDs, 2)
+		}
+		samples = append(samples, sample)
+	}
+	// Two copies of one picture.
+	samples[4].contentHash = "same"
+	samples[5].contentHash = "same"
+
+	got := assignSplits(samples, splits, shares, 42)
+	require.Len(t, got, 30)
+
+	counts := make(map[string]int)
+	rareTagSplits := make(map[string]bool)
+	animeCounts := make(map[string]map[uint]int)
+	for _, sample := range samples {
+		split := got[sample.imageFile.ID]
+		counts[split]++
+		if len(sample.tagIDs) == 2 {
+			rareTagSplits[split] = true
+		}
+		if animeCounts[split] == nil {
+			animeCounts[split] = make(map[uint]int)
+		}
+		animeCounts[split][sample.animeID]++
+	}
+	assert.Equal(t, map[string]int{"train": 18, "validation": 6, "test": 6}, counts)
+	assert.Equal(t, map[string]bool{"train": true, "validation": true, "test": true}, rareTagSplits,
+		"the rare tag is in every split")
+	for _, split := range splits {
+		assert.Equal(t, animeCounts[split][1], animeCounts[split][2], "anime are balanced in %s", split)
+	}
+	assert.Equal(t, got[5], got[6], "duplicates stay in one split")
+
+	assert.Equal(t, got, assignSplits(samples, splits, shares, 42), "the same seed gives the same splits")
+}
+
+func TestBatchImageExporter_Export_Splits(t *testing.T) {
+	tester := newTester(t)
+	fileCreator := tester.newFileCreator(t)
+	fileCreator.CreateDirectory(image.Directory{ID: 1, Name: "frieren"})
+
+	files := []db.File{fileCreator.BuildDBDirectory(1)}
+	fileTags := make([]db.FileTag, 0)
+	for id := uint(11); id <= 30; id++ {
+		// Frames of four episodes, five each.
+		name := fmt.Sprintf("frieren_ep%02d_%04d.jpg", (id-11)/5+1, id)
+		fileCreator.CreateImage(image.ImageFile{ID: id, Name: name, ParentID: 1}, image.TestImageFileJpeg)
+		files = append(files, fileCreator.BuildDBImageFile(id))
+		fileTags = append(fileTags, db.FileTag{FileID: id, TagID: 1})
+	}
+	tester.dbClient.Truncate(t, &db.File{}, &db.Tag{}, &db.FileTag{})
+	db.LoadTestData(t, tester.dbClient, files)
+	db.LoadTestData(t, tester.dbClient, []db.Tag{{ID: 1, Name: "frieren"}})
+	db.LoadTestData(t, tester.dbClient, fileTags)
+
+	exportDirectory := t.TempDir()
+	batchExporter := tester.getBatchImageExporter(BatchImageExporterOptions{
+		Split: SplitOptions{
+			TrainRatio:      2,
+			ValidationRatio: 1,
+			TestRatio:       1,
+			Seed:            1,
+		},
+		progressSleepDuration: time.Millisecond,
+	})
+	require.NoError(t, batchExporter.Export(context.Background(), exportDirectory))
+
+	episodeSplits := make(map[string]string)
+	total := 0
+	for _, split := range []string{"train", "validation", "test"} {
+		metadatas := readMetadataFile(t, filepath.Join(exportDirectory, split, "metadata.jsonl"))
+		total += len(metadatas)
+		for _, metadata := range metadatas {
+			assert.FileExists(t, filepath.Join(exportDirectory, split, metadata.FileName))
+			episode := filepath.Base(metadata.OriginalPath)[:len("frieren_ep01")]
+			if previous, ok := episodeSplits[episode]; ok {
+				assert.Equal(t, previous, split, "frames of %s are in one split", episode)
+			}
+			episodeSplits[episode] = split
+		}
+	}
+	assert.Equal(t, 20, total)
+	// Every frame is the same test image, so near-duplicate detection alone
+	// would keep all of them in one split.
+	assert.Len(t, episodeSplits, 4)
+
+	t.Run("negative ratio", func(t *testing.T) {
+		batchExporter := tester.getBatchImageExporter(BatchImageExporterOptions{
+			Split: SplitOptions{TrainRatio: -1},
+		})
+		err := batchExporter.Export(context.Background(), t.TempDir())
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,248 @@
+package export
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	defaultShardMaxSamples = 10000
+	defaultShardMaxBytes   = 1 << 30
+
+	// shardIndexFileName lists the shards of a split with their checksums.
+	shardIndexFileName = "index.json"
+
+	// tarBlockSize is what tar pads every header and file to.
+	tarBlockSize = 512
+)
+
+// WebDatasetOptions configures WebDatasetFormat.
+type WebDatasetOptions struct {
+	// ShardMaxSamples is the most images in a shard. It defaults to 10000.
+	ShardMaxSamples int
+	// ShardMaxBytes is the largest size of a shard, unless a single image is
+	// larger. It defaults to 1 GiB.
+	ShardMaxBytes int64
+}
+
+// WebDatasetFormat writes each split into tar shards in the WebDataset
+// layout: a <key>.jpg or <key>.png image and a <key>.json of its Metadata
+// per sample. Images are streamed from the image root directory into the
+// shards, and an index.json lists the shards with their SHA256 checksums.
+//
+// Shards are written again on every export, so an incremental export only
+// saves the validation of unchanged images.
+type WebDatasetFormat struct {
+	options WebDatasetOptions
+}
+
+func NewWebDatasetFormat(options WebDatasetOptions) *WebDatasetFormat {
+	if options.ShardMaxSamples <= 0 {
+		options.ShardMaxSamples = defaultShardMaxSamples
+	}
+	if options.ShardMaxBytes <= 0 {
+		options.ShardMaxBytes = defaultShardMaxBytes
+	}
+	return &WebDatasetFormat{
+		options: options,
+	}
+}
+
+// ShardIndex is the content of index.json.
+type ShardIndex struct {
+	Samples int     `json:"samples"`
+	Shards  []Shard `json:"shards"`
+}
+
+type Shard struct {
+	FileName string `json:"file_name"`
+	Samples  int    `json:"samples"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+}
+
+// CopyImage does nothing, since images are written into the shards by
+// WriteLabels.
+func (format *WebDatasetFormat) CopyImage(splitDirectory string, exportedImage ExportedImage) error {
+	return nil
+}
+
+// RemoveImage does nothing, since the shards are written again.
+func (format *WebDatasetFormat) RemoveImage(splitDirectory string, fileName string) error {
+	return nil
+}
+
+type shardSample struct {
+	exportedImage ExportedImage
+	source        os.FileInfo
+	metadata      []byte
+}
+
+func (sample shardSample) tarSize() int64 {
+	return 2*tarBlockSize + padToTarBlock(sample.source.Size()) + padToTarBlock(int64(len(sample.metadata)))
+}
+
+func padToTarBlock(size int64) int64 {
+	return (size + tarBlockSize - 1) / tarBlockSize * tarBlockSize
+}
+
+func (format *WebDatasetFormat) WriteLabels(splitDirectory string, exportedImages []ExportedImage) error {
+	samples := make([]shardSample, len(exportedImages))
+	for i, exportedImage := range exportedImages {
+		source, err := os.Stat(exportedImage.ImageFile.LocalFilePath)
+		if err != nil {
+			return fmt.Errorf("os.Stat: %w", err)
+		}
+		metadata, err := json.Marshal(exportedImage.Metadata)
+		if err != nil {
+			return fmt.Errorf("json.Marshal: %w", err)
+		}
+		samples[i] = shardSample{
+			exportedImage: exportedImage,
+			source:        source,
+			metadata:      metadata,
+		}
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].exportedImage.ImageFile.ID < samples[j].exportedImage.ImageFile.ID
+	})
+
+	shardSamples := make([][]shardSample, 0)
+	var shardBytes int64
+	for _, sample := range samples {
+		last := len(shardSamples) - 1
+		if last < 0 ||
+			len(shardSamples[last]) >= format.options.ShardMaxSamples ||
+			shardBytes+sample.tarSize() > format.options.ShardMaxBytes {
+			shardSamples = append(shardSamples, nil)
+			last++
+			shardBytes = 0
+		}
+		shardSamples[last] = append(shardSamples[last], sample)
+		shardBytes += sample.tarSize()
+	}
+
+	// Shards of the previous export are removed, in case there are fewer now.
+	split := filepath.Base(splitDirectory)
+	previousShards, err := filepath.Glob(filepath.Join(splitDirectory, split+"-*.tar"))
+	if err != nil {
+		return fmt.Errorf("filepath.Glob: %w", err)
+	}
+	for _, previousShard := range previousShards {
+		if err := removeIfExists(previousShard); err != nil {
+			return err
+		}
+	}
+
+	index := ShardIndex{
+		Samples: len(samples),
+		Shards:  make([]Shard, len(shardSamples)),
+	}
+	eg, _ := errgroup.WithContext(context.Background())
+	eg.SetLimit(runtime.NumCPU())
+	for shardIndex, samples := range shardSamples {
+		eg.Go(func() error {
+			shard, err := writeShard(filepath.Join(splitDirectory, fmt.Sprintf("%s-%06d.tar", split, shardIndex)), samples)
+			if err != nil {
+				return fmt.Errorf("writeShard: %w", err)
+			}
+			index.Shards[shardIndex] = shard
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.MarshalIndent: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(splitDirectory, shardIndexFileName), content, 0644); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+	return nil
+}
+
+func writeShard(shardFilePath string, samples []shardSample) (Shard, error) {
+	shardFile, err := os.Create(shardFilePath)
+	if err != nil {
+		return Shard{}, fmt.Errorf("os.Create: %w", err)
+	}
+	defer shardFile.Close()
+
+	hash := sha256.New()
+	buffer := bufio.NewWriter(io.MultiWriter(shardFile, hash))
+	tarWriter := tar.NewWriter(buffer)
+	for _, sample := range samples {
+		fileName := sample.exportedImage.Metadata.FileName
+		key := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+		modifiedAt := sample.source.ModTime()
+
+		if err := tarWriter.WriteHeader(&tar.Header{
+			Name:    fileName,
+			Mode:    0644,
+			Size:    sample.source.Size(),
+			ModTime: modifiedAt,
+		}); err != nil {
+			return Shard{}, fmt.Errorf("tarWriter.WriteHeader: %w", err)
+		}
+		if err := copyFileTo(tarWriter, sample.exportedImage.ImageFile.LocalFilePath); err != nil {
+			return Shard{}, fmt.Errorf("copyFileTo: %w", err)
+		}
+
+		if err := tarWriter.WriteHeader(&tar.Header{
+			Name:    key + ".json",
+			Mode:    0644,
+			Size:    int64(len(sample.metadata)),
+			ModTime: modifiedAt,
+		}); err != nil {
+			return Shard{}, fmt.Errorf("tarWriter.WriteHeader: %w", err)
+		}
+		if _, err := tarWriter.Write(sample.metadata); err != nil {
+			return Shard{}, fmt.Errorf("tarWriter.Write: %w", err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return Shard{}, fmt.Errorf("tarWriter.Close: %w", err)
+	}
+	if err := buffer.Flush(); err != nil {
+		return Shard{}, fmt.Errorf("buffer.Flush: %w", err)
+	}
+	shardStat, err := shardFile.Stat()
+	if err != nil {
+		return Shard{}, fmt.Errorf("shardFile.Stat: %w", err)
+	}
+
+	return Shard{
+		FileName: filepath.Base(shardFilePath),
+		Samples:  len(samples),
+		Size:     shardStat.Size(),
+		SHA256:   hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+func copyFileTo(writer io.Writer, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("os.Open: %w", err)
+	}
+	defer file.Close()
+	if _, err := io.Copy(writer, file); err != nil {
+		return fmt.Errorf("io.Copy: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package export
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchImageExporter_Export_WebDatasetFormat(t *testing.T) {
+	tester := newTester(t)
+	fileCreator := tester.newFileCreator(t)
+	fileCreator.CreateDirectory(image.Directory{ID: 1, Name: "Frieren"})
+	fileCreator.CreateImage(image.ImageFile{ID: 11, Name: "a.jpg", ParentID: 1}, image.TestImageFileJpeg)
+	fileCreator.CreateImage(image.ImageFile{ID: 12, Name: "b.png", ParentID: 1}, image.TestImageFilePng)
+	fileCreator.CreateImage(image.ImageFile{ID: 13, Name: "c.jpg", ParentID: 1}, image.TestImageFileJpeg)
+
+	tester.dbClient.Truncate(t, &db.File{}, &db.Tag{}, &db.FileTag{}, &db.Anime{}, &db.Character{}, &db.FileCharacter{})
+	db.LoadTestData(t, tester.dbClient, []db.File{
+		fileCreator.BuildDBDirectory(1),
+		fileCreator.BuildDBImageFile(11),
+		fileCreator.BuildDBImageFile(12),
+		fileCreator.BuildDBImageFile(13),
+	})
+	db.LoadTestData(t, tester.dbClient, []db.Tag{
+		{ID: 1, Name: "smile"},
+		{ID: 2, Name: "night"},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.FileTag{
+		{FileID: 11, TagID: 1},
+		{FileID: 12, TagID: 2},
+		{FileID: 13, TagID: 1},
+	})
+
+	exportDirectory := t.TempDir()
+	trainDirectory := filepath.Join(exportDirectory, "train")
+	export := func(query Query) {
+		batchExporter := tester.getBatchImageExporter(BatchImageExporterOptions{
+			Query:       query,
+			Incremental: true,
+			Format: NewWebDatasetFormat(WebDatasetOptions{
+				ShardMaxSamples: 2,
+			}),
+			progressSleepDuration: time.Millisecond,
+		})
+		require.NoError(t, batchExporter.Export(context.Background(), exportDirectory))
+	}
+	readIndex := func() ShardIndex {
+		content, err := os.ReadFile(filepath.Join(trainDirectory, shardIndexFileName))
+		require.NoError(t, err)
+		var index ShardIndex
+		require.NoError(t, json.Unmarshal(content, &index))
+		return index
+	}
+
+	export(Query{})
+	index := readIndex()
+	assert.Equal(t, 3, index.Samples)
+	require.Len(t, index.Shards, 2)
+
+	gotFileNames := make([]string, 0)
+	gotMetadata := make(map[string]Metadata)
+	for i, shard := range index.Shards {
+		content, err := os.ReadFile(filepath.Join(trainDirectory, shard.FileName))
+		require.NoError(t, err)
+		checksum := sha256.Sum256(content)
+		assert.Equal(t, hex.EncodeToString(checksum[:]), shard.SHA256)
+		assert.EqualValues(t, len(content), shard.Size)
+
+		file, err := os.Open(filepath.Join(trainDirectory, shard.FileName))
+		require.NoError(t, err)
+		defer file.Close()
+		tarReader := tar.NewReader(file)
+		sampleCount := 0
+		for {
+			header, err := tarReader.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			body, err := io.ReadAll(tarReader)
+			require.NoError(t, err)
+
+			gotFileNames = append(gotFileNames, header.Name)
+			if filepath.Ext(header.Name) != ".json" {
+				sampleCount++
+				continue
+			}
+			var metadata Metadata
+			require.NoError(t, json.Unmarshal(body, &metadata))
+			gotMetadata[header.Name] = metadata
+		}
+		assert.Equal(t, shard.Samples, sampleCount, "shard %d", i)
+	}
+	assert.Equal(t, []string{
+		"11.jpg", "11.json",
+		"12.png", "12.json",
+		"13.jpg", "13.json",
+	}, gotFileNames)
+	assert.Equal(t, []float64{0, 1, 0}, gotMetadata["11.json"].Tags)
+	assert.Equal(t, "Frieren/b.png", gotMetadata["12.json"].OriginalPath)
+	assert.NoFileExists(t, filepath.Join(trainDirectory, "11.jpg"), "images are only written into shards")
+
+	// Shards that are no longer needed are removed.
+	export(Query{TagIDs: []uint{1}})
+	index = readIndex()
+	assert.Equal(t, 2, index.Samples)
+	require.Len(t, index.Shards, 1)
+	assert.Equal(t, "train-000000.tar", index.Shards[0].FileName)
+	assert.NoFileExists(t, filepath.Join(trainDirectory, "train-000001.tar"))
+}
+
+func TestWebDatasetFormat_ShardMaxBytes(t *testing.T) {
+	tester := newTester(t)
+	fileCreator := tester.newFileCreator(t)
+	fileCreator.CreateDirectory(image.Directory{ID: 1, Name: "Frieren"})
+	fileCreator.CreateImage(image.ImageFile{ID: 11, Name: "a.jpg", ParentID: 1}, image.TestImageFileJpeg)
+	fileCreator.CreateImage(image.ImageFile{ID: 12, Name: "b.jpg", ParentID: 1}, image.TestImageFileJpeg)
+
+	exportedImages := make([]ExportedImage, 0)
+	for _, imageFile := range []image.ImageFile{
+		fileCreator.BuildImageFile(11),
+		fileCreator.BuildImageFile(12),
+	} {
+		exportedImages = append(exportedImages, ExportedImage{
+			ImageFile: imageFile,
+			Metadata:  Metadata{FileName: exportFileName(imageFile)},
+		})
+	}
+
+	splitDirectory := filepath.Join(t.TempDir(), "train")
+	require.NoError(t, os.MkdirAll(splitDirectory, 0755))
+	format := NewWebDatasetFormat(WebDatasetOptions{ShardMaxBytes: 1})
+	require.NoError(t, format.WriteLabels(splitDirectory, exportedImages))
+
+	content, err := os.ReadFile(filepath.Join(splitDirectory, shardIndexFileName))
+	require.NoError(t, err)
+	var index ShardIndex
+	require.NoError(t, json.Unmarshal(content, &index))
+	require.Len(t, index.Shards, 2, "an image larger than the limit gets a shard of its own")
+	for _, shard := range index.Shards {
+		assert.Equal(t, 1, shard.Samples)
+		assert.FileExists(t, filepath.Join(splitDirectory, shard.FileName))
+	}
+}
@@ -0,0 +1,45 @@
+package frontend
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/plugin"
+)
+
+// AnalysisService exposes the image analysis plugins in analysis.plugins of
+// the configuration, like OCR or upscaling, to the frontend.
+type AnalysisService struct {
+	registry *plugin.Registry
+}
+
+func NewAnalysisService(registry *plugin.Registry) *AnalysisService {
+	return &AnalysisService{
+		registry: registry,
+	}
+}
+
+// ReadCapabilities returns what the analysis plugins can do for images.
+func (service *AnalysisService) ReadCapabilities(ctx context.Context) []plugin.Capability {
+	return service.registry.Capabilities(ctx)
+}
+
+// Analyze runs a capability on the images and replaces their annotations by
+// it.
+func (service *AnalysisService) Analyze(ctx context.Context, capabilityName string, imageFileIDs []uint) ([]plugin.AnalysisResult, error) {
+	results, err := service.registry.Analyze(ctx, capabilityName, imageFileIDs)
+	if err != nil {
+		return nil, fmt.Errorf("registry.Analyze: %w", err)
+	}
+	return results, nil
+}
+
+// ReadAnnotations returns the annotations of the images by their IDs.
+func (service *AnalysisService) ReadAnnotations(ctx context.Context, imageFileIDs []uint) (map[uint][]db.FileAnnotation, error) {
+	annotations, err := service.registry.ReadAnnotations(ctx, imageFileIDs)
+	if err != nil {
+		return nil, fmt.Errorf("registry.ReadAnnotations: %w", err)
+	}
+	return annotations, nil
+}
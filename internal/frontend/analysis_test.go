@@ -0,0 +1,76 @@
+package frontend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/michael-freling/anime-image-viewer/internal/plugin"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+	pluginsv1 "github.com/michael-freling/anime-image-viewer/plugins/plugins-protos/gen/go/plugins/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAnalysisService(t *testing.T) {
+	tester := newTester(t)
+	fileBuilder := tester.newFileCreator(t).
+		CreateDirectory(image.Directory{ID: 1, Name: "Directory 1"}).
+		CreateImage(image.ImageFile{ID: 11, Name: "image_file_11.jpg", ParentID: 1}, image.TestImageFileJpeg)
+	tester.dbClient.Truncate(t, &db.File{}, &db.FileAnnotation{})
+	db.LoadTestData(t, tester.dbClient, []db.File{
+		fileBuilder.BuildDBDirectory(1),
+		fileBuilder.BuildDBImageFile(11),
+	})
+
+	controller := gomock.NewController(t)
+	client := pluginsv1.NewMockPluginServiceClient(controller)
+	client.EXPECT().
+		GetCapabilities(gomock.Any(), gomock.Any()).
+		Return(&pluginsv1.GetCapabilitiesResponse{
+			Version: "1.0",
+			Capabilities: []*pluginsv1.Capability{
+				{
+					Name:            "ocr",
+					AnnotationTypes: []pluginsv1.AnnotationType{pluginsv1.AnnotationType_ANNOTATION_TYPE_TEXT},
+				},
+			},
+		}, nil).
+		Times(1)
+	client.EXPECT().
+		Analyze(gomock.Any(), gomock.Any()).
+		Return(&pluginsv1.AnalyzeResponse{
+			Annotations: []*pluginsv1.Annotation{
+				{Value: &pluginsv1.Annotation_Text{Text: &pluginsv1.TextAnnotation{Text: "subtitle"}}},
+			},
+		}, nil).
+		Times(1)
+
+	service := NewAnalysisService(plugin.NewRegistry(
+		tester.logger,
+		tester.dbClient.Client,
+		tester.getFileReader(),
+		t.TempDir(),
+		[]plugin.Analyzer{{Name: "ocr", Client: client}},
+	))
+	ctx := context.Background()
+
+	capabilities := service.ReadCapabilities(ctx)
+	require.Len(t, capabilities, 1)
+	assert.Equal(t, "ocr", capabilities[0].Name)
+
+	results, err := service.Analyze(ctx, "ocr", []uint{11})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "ocr", results[0].PluginName)
+
+	annotations, err := service.ReadAnnotations(ctx, []uint{11})
+	require.NoError(t, err)
+	require.Len(t, annotations[11], 1)
+	assert.Equal(t, "subtitle", annotations[11][0].Text)
+
+	_, err = service.Analyze(ctx, "upscale", []uint{11})
+	assert.ErrorIs(t, err, xerrors.ErrInvalidArgument)
+}
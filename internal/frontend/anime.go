@@ -8,6 +8,7 @@ import (
 
 	"github.com/michael-freling/anime-image-viewer/internal/anime"
 	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/history"
 	"github.com/michael-freling/anime-image-viewer/internal/image"
 	"github.com/michael-freling/anime-image-viewer/internal/tag"
 )
@@ -20,6 +21,11 @@ type Anime struct {
 	MetadataSeriesID *string `json:"metadataSeriesId"`
 	// AniListID backs the outbound anilist.co link only.
 	AniListID *int `json:"aniListId"`
+	// FranchiseID is the franchise the anime belongs to, if any.
+	FranchiseID *uint `json:"franchiseId"`
+	// Titles holds the imported titles keyed by language, for the UI to show
+	// in the user's display language instead of Name.
+	Titles map[string]string `json:"titles"`
 }
 
 // AnimeListItem is an anime row plus its image count for the list page.
@@ -28,6 +34,8 @@ type AnimeListItem struct {
 	Name           string `json:"name"`
 	ImageCount     uint   `json:"imageCount"`
 	CoverImagePath string `json:"coverImagePath"`
+	// Titles holds the imported titles keyed by language.
+	Titles map[string]string `json:"titles"`
 }
 
 // AnimeTagInfo is a derived tag computed from the images in the anime's folder
@@ -46,6 +54,11 @@ type AnimeCharacterInfo struct {
 	Name          string `json:"name"`
 	ImageCount    uint   `json:"imageCount"`
 	ThumbnailPath string `json:"thumbnailPath"`
+	// AnimeIDs lists every anime the character belongs to; more than one
+	// means it is shared with other anime.
+	AnimeIDs []uint `json:"animeIds"`
+	// Titles holds the imported names keyed by language.
+	Titles map[string]string `json:"titles"`
 }
 
 // AnimeFolderInfo is a folder mapped to an anime, with the inheritance flag.
@@ -83,6 +96,9 @@ type AnimeSeasonInfo struct {
 	AiringYear   *uint             `json:"airingYear"`
 	ImageCount   uint              `json:"imageCount"`
 	Children     []AnimeSeasonInfo `json:"children"`
+	// Titles holds the imported titles keyed by language. Only linked
+	// seasons that upstream gives a title have any.
+	Titles map[string]string `json:"titles"`
 }
 
 // AnimeDetailsResponse is the payload of the landing page request.
@@ -102,6 +118,7 @@ type AnimeService struct {
 	directoryReader *image.DirectoryReader
 	tagReader       *tag.Reader
 	imageReader     *image.Reader
+	journal         *history.Journal
 }
 
 func NewAnimeService(
@@ -110,6 +127,7 @@ func NewAnimeService(
 	directoryReader *image.DirectoryReader,
 	tagReader *tag.Reader,
 	imageReader *image.Reader,
+	journal *history.Journal,
 ) *AnimeService {
 	return &AnimeService{
 		core:            core,
@@ -117,6 +135,7 @@ func NewAnimeService(
 		directoryReader: directoryReader,
 		tagReader:       tagReader,
 		imageReader:     imageReader,
+		journal:         journal,
 	}
 }
 
@@ -151,6 +170,15 @@ func (s *AnimeService) ListAnime(ctx context.Context) ([]AnimeListItem, error) {
 	// Resolve one cover image per anime from the folder tree.
 	coverPaths := s.resolveCoverImages(rows)
 
+	animeIDs := make([]uint, len(rows))
+	for i, r := range rows {
+		animeIDs[i] = r.ID
+	}
+	titles, err := s.core.ReadLocalizedTitles(ctx, db.LocalizedTitleEntityAnime, animeIDs)
+	if err != nil {
+		return nil, err
+	}
+
 	result := make([]AnimeListItem, len(rows))
 	for i, r := range rows {
 		result[i] = AnimeListItem{
@@ -158,6 +186,7 @@ func (s *AnimeService) ListAnime(ctx context.Context) ([]AnimeListItem, error) {
 			Name:           r.Name,
 			ImageCount:     imageCounts[r.ID],
 			CoverImagePath: coverPaths[r.ID],
+			Titles:         titles[r.ID],
 		}
 	}
 	sort.SliceStable(result, func(i, j int) bool {
@@ -208,7 +237,7 @@ func (s *AnimeService) GetAnimeDetails(ctx context.Context, id uint) (AnimeDetai
 	})
 
 	// Derive characters for this anime
-	derivedChars, err := s.core.DeriveCharactersForAnime(id)
+	derivedChars, err := s.core.DeriveCharactersForAnime(ctx, id)
 	if err != nil {
 		return AnimeDetailsResponse{}, fmt.Errorf("core.DeriveCharactersForAnime: %w", err)
 	}
@@ -218,6 +247,7 @@ func (s *AnimeService) GetAnimeDetails(ctx context.Context, id uint) (AnimeDetai
 			ID:         dc.CharacterID,
 			Name:       dc.CharacterName,
 			ImageCount: dc.ImageCount,
+			AnimeIDs:   dc.AnimeIDs,
 		})
 	}
 	// Resolve character thumbnails
@@ -226,8 +256,13 @@ func (s *AnimeService) GetAnimeDetails(ctx context.Context, id uint) (AnimeDetai
 		charIDs = append(charIDs, ci.ID)
 	}
 	charThumbnailPaths := s.resolveCharacterThumbnails(charIDs)
+	charTitles, err := s.core.ReadLocalizedTitles(ctx, db.LocalizedTitleEntityCharacter, charIDs)
+	if err != nil {
+		return AnimeDetailsResponse{}, err
+	}
 	for i := range charInfos {
 		charInfos[i].ThumbnailPath = charThumbnailPaths[charInfos[i].ID]
+		charInfos[i].Titles = charTitles[charInfos[i].ID]
 	}
 	sort.SliceStable(charInfos, func(i, j int) bool {
 		return strings.ToLower(charInfos[i].Name) < strings.ToLower(charInfos[j].Name)
@@ -256,6 +291,16 @@ func (s *AnimeService) GetAnimeDetails(ctx context.Context, id uint) (AnimeDetai
 		return AnimeDetailsResponse{}, fmt.Errorf("core.GetAnimeSeasons: %w", err)
 	}
 	seasonInfos := convertSeasons(coreSeasons)
+	seasonTitles, err := s.core.ReadLocalizedTitles(ctx, db.LocalizedTitleEntityFile, collectSeasonIDs(seasonInfos))
+	if err != nil {
+		return AnimeDetailsResponse{}, err
+	}
+	applySeasonTitles(seasonInfos, seasonTitles)
+
+	animeTitles, err := s.core.ReadLocalizedTitles(ctx, db.LocalizedTitleEntityAnime, []uint{id})
+	if err != nil {
+		return AnimeDetailsResponse{}, err
+	}
 
 	return AnimeDetailsResponse{
 		Anime: Anime{
@@ -263,6 +308,8 @@ func (s *AnimeService) GetAnimeDetails(ctx context.Context, id uint) (AnimeDetai
 			Name:             a.Name,
 			MetadataSeriesID: dbAnime.MetadataSeriesID,
 			AniListID:        dbAnime.AniListID,
+			FranchiseID:      dbAnime.FranchiseID,
+			Titles:           animeTitles[id],
 		},
 		Tags:       tagInfos,
 		Characters: charInfos,
@@ -686,8 +733,28 @@ func (s *AnimeService) MoveFilesToSeason(ctx context.Context, fileIDs []uint, ta
 		}
 	}
 
+	moves := make([]history.FileMove, 0, len(images))
+	for _, img := range images {
+		if img.ParentID == targetFolderID {
+			continue
+		}
+		moves = append(moves, history.FileMove{
+			FileID:       img.ID,
+			FromParentID: img.ParentID,
+			ToParentID:   targetFolderID,
+		})
+	}
+
 	// Move the files.
-	return fileClient.MoveFiles(ctx, fileIDs, targetFolderID)
+	return db.NewTransaction(ctx, s.dbClient, func(ctx context.Context) error {
+		if err := fileClient.MoveFiles(ctx, fileIDs, targetFolderID); err != nil {
+			return fmt.Errorf("File.MoveFiles: %w", err)
+		}
+		return s.journal.Record(ctx, history.KindMoveFiles,
+			fmt.Sprintf("Moved %d files to %q", len(moves), targetFolder.Name),
+			history.Change{MovedFiles: moves},
+		)
+	})
 }
 
 // GetImageTagIDs returns a map from image ID to the list of tag IDs for each image.
@@ -855,9 +922,49 @@ func (s *AnimeService) ImportFromMetadata(ctx context.Context, animeID uint, ser
 		SeasonsUpdated:    result.SeasonsUpdated,
 		CharactersCreated: result.CharactersCreated,
 		CharactersUpdated: result.CharactersUpdated,
+		NewSeasons:        result.NewSeasons,
 	}, nil
 }
 
+// SearchTitles finds anime, seasons and characters by any of their imported
+// titles, e.g. a series by its Japanese title.
+func (s *AnimeService) SearchTitles(ctx context.Context, query string) ([]TitleMatch, error) {
+	matches, err := s.core.SearchTitles(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	out := make([]TitleMatch, len(matches))
+	for i, m := range matches {
+		out[i] = TitleMatch{
+			EntityType: m.EntityType,
+			EntityID:   m.EntityID,
+			AnimeID:    m.AnimeID,
+			Language:   m.Language,
+			Title:      m.Title,
+		}
+	}
+	return out, nil
+}
+
+func collectSeasonIDs(seasons []AnimeSeasonInfo) []uint {
+	ids := make([]uint, 0, len(seasons))
+	for _, season := range seasons {
+		ids = append(ids, season.ID)
+		ids = append(ids, collectSeasonIDs(season.Children)...)
+	}
+	return ids
+}
+
+func applySeasonTitles(seasons []AnimeSeasonInfo, titles map[uint]map[string]string) {
+	for i := range seasons {
+		seasons[i].Titles = titles[seasons[i].ID]
+		applySeasonTitles(seasons[i].Children, titles)
+	}
+}
+
 func convertSeasons(seasons []anime.AnimeSeason) []AnimeSeasonInfo {
 	if seasons == nil {
 		return nil
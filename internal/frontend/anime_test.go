@@ -79,6 +79,35 @@ func TestAnimeService_ListAnime_Sorted(t *testing.T) {
 	assert.Equal(t, "Charlie", list[2].Name)
 }
 
+func TestAnimeService_LocalizedTitles(t *testing.T) {
+	tester := newTester(t)
+	tester.dbClient.Truncate(t, db.File{}, db.Tag{}, db.Anime{}, db.FileTag{}, db.LocalizedTitle{})
+	svc := tester.getAnimeService()
+	ctx := context.Background()
+
+	a, err := svc.CreateAnime(ctx, "Frieren")
+	require.NoError(t, err)
+	db.LoadTestData(t, tester.dbClient, []db.LocalizedTitle{
+		{EntityType: db.LocalizedTitleEntityAnime, EntityID: a.ID, AnimeID: a.ID, Language: "en", Title: "Frieren"},
+		{EntityType: db.LocalizedTitleEntityAnime, EntityID: a.ID, AnimeID: a.ID, Language: "ja", Title: "葬送のフリーレン"},
+	})
+
+	list, err := svc.ListAnime(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, map[string]string{"en": "Frieren", "ja": "葬送のフリーレン"}, list[0].Titles)
+
+	details, err := svc.GetAnimeDetails(ctx, a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "葬送のフリーレン", details.Anime.Titles["ja"])
+
+	matches, err := svc.SearchTitles(ctx, "葬送")
+	require.NoError(t, err)
+	assert.Equal(t, []TitleMatch{
+		{EntityType: "anime", EntityID: a.ID, AnimeID: a.ID, Language: "ja", Title: "葬送のフリーレン"},
+	}, matches)
+}
+
 // TestAnimeService_ListAnime_CoverImageFromSubfolder verifies that the cover
 // image resolution in ListAnime walks into child subfolders when the root
 // folder has no direct images. This exercises the recursive child traversal
@@ -1138,6 +1167,10 @@ func (m *mockMetadataClient) GetSeries(_ context.Context, id string) (*animemeta
 	return series, nil
 }
 
+func (m *mockMetadataClient) GetSeriesInLanguage(ctx context.Context, id string, _ string) (*animemetadata.Series, error) {
+	return m.GetSeries(ctx, id)
+}
+
 func TestAnimeService_UpdateSeasonAiringInfo(t *testing.T) {
 	tester := newTester(t)
 	tester.dbClient.Truncate(t, db.File{}, db.Tag{}, db.Anime{}, db.FileTag{})
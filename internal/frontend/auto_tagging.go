@@ -0,0 +1,30 @@
+package frontend
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/michael-freling/anime-image-viewer/internal/tag"
+)
+
+// AutoTaggingService lets a user run auto-tagging without waiting for the
+// next scheduled run.
+type AutoTaggingService struct {
+	autoTagger *tag.AutoTagger
+}
+
+func NewAutoTaggingService(autoTagger *tag.AutoTagger) *AutoTaggingService {
+	return &AutoTaggingService{
+		autoTagger: autoTagger,
+	}
+}
+
+// Run suggests tags for every image which wasn't auto-tagged yet. It returns
+// tag.ErrAutoTaggingInProgress while another run is going.
+func (service *AutoTaggingService) Run(ctx context.Context) (tag.AutoTaggingResult, error) {
+	result, err := service.autoTagger.Run(ctx)
+	if err != nil {
+		return result, fmt.Errorf("autoTagger.Run: %w", err)
+	}
+	return result, nil
+}
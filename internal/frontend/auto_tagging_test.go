@@ -0,0 +1,20 @@
+package frontend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/tag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoTaggingService_Run(t *testing.T) {
+	tester := newTester(t)
+	// without a tag suggestion plugin
+	service := NewAutoTaggingService(tag.NewAutoTagger(tester.logger, tester.dbClient.Client, nil, tester.getJournal(), config.Config{}))
+
+	got, err := service.Run(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, tag.AutoTaggingResult{}, got)
+}
@@ -3,10 +3,12 @@ package frontend
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 
 	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/history"
 )
 
 // CharacterInfo is a character exposed to the frontend.
@@ -15,16 +17,21 @@ type CharacterInfo struct {
 	Name       string `json:"name"`
 	AnimeID    uint   `json:"animeId"`
 	ImageCount uint   `json:"imageCount"`
+	// AnimeIDs lists every anime the character belongs to, including
+	// AnimeID. It is only set when reading characters.
+	AnimeIDs []uint `json:"animeIds"`
 }
 
 // CharacterService is the Wails-bound service for character CRUD.
 type CharacterService struct {
 	dbClient *db.Client
+	journal  *history.Journal
 }
 
-func NewCharacterService(dbClient *db.Client) *CharacterService {
+func NewCharacterService(dbClient *db.Client, journal *history.Journal) *CharacterService {
 	return &CharacterService{
 		dbClient: dbClient,
+		journal:  journal,
 	}
 }
 
@@ -94,6 +101,12 @@ func (s *CharacterService) DeleteCharacter(ctx context.Context, id uint) error {
 		if err := s.dbClient.Character().DeleteByID(ctx, id); err != nil {
 			return fmt.Errorf("Character.DeleteByID: %w", err)
 		}
+		if err := s.dbClient.AnimeCharacter().DeleteByCharacterIDs(ctx, []uint{id}); err != nil {
+			return fmt.Errorf("AnimeCharacter.DeleteByCharacterIDs: %w", err)
+		}
+		if err := s.dbClient.LocalizedTitle().DeleteByEntityIDs(ctx, db.LocalizedTitleEntityCharacter, []uint{id}); err != nil {
+			return fmt.Errorf("LocalizedTitle.DeleteByEntityIDs: %w", err)
+		}
 		return nil
 	})
 }
@@ -141,14 +154,12 @@ func (s *CharacterService) BatchUpdateCharactersForFiles(ctx context.Context, fi
 		}
 	}
 
-	// Prepare rows to delete.
+	// Prepare rows to delete. Only existing rows are deleted, so that the
+	// journal restores them with their AddedBy.
 	deleted := make([]db.FileCharacter, 0)
-	for _, charID := range deletedCharacterIDs {
-		for _, fileID := range fileIDs {
-			deleted = append(deleted, db.FileCharacter{
-				CharacterID: charID,
-				FileID:      fileID,
-			})
+	for _, fc := range existing {
+		if slices.Contains(deletedCharacterIDs, fc.CharacterID) {
+			deleted = append(deleted, fc)
 		}
 	}
 
@@ -168,7 +179,13 @@ func (s *CharacterService) BatchUpdateCharactersForFiles(ctx context.Context, fi
 				return fmt.Errorf("FileCharacter.BatchCreate: %w", err)
 			}
 		}
-		return nil
+		return s.journal.Record(ctx, history.KindBatchUpdateCharacters,
+			fmt.Sprintf("Added %d and removed %d characters on %d files", len(addedCharacterIDs), len(deletedCharacterIDs), len(fileIDs)),
+			history.Change{
+				AddedFileCharacters:   created,
+				DeletedFileCharacters: deleted,
+			},
+		)
 	})
 }
 
@@ -239,6 +256,9 @@ func (s *CharacterService) ConvertTagToCharacter(ctx context.Context, tagID uint
 		if err := fileTagClient.DeleteByTagIDs(ctx, []uint{tagID}); err != nil {
 			return fmt.Errorf("FileTagClient.DeleteByTagIDs: %w", err)
 		}
+		if err := s.dbClient.SuggestionReview().DeleteByTagIDs(ctx, []uint{tagID}); err != nil {
+			return fmt.Errorf("SuggestionReview.DeleteByTagIDs: %w", err)
+		}
 
 		// 6. Delete the tag
 		if err := tagClient.DeleteByID(ctx, tagID); err != nil {
@@ -314,6 +334,9 @@ func (s *CharacterService) ConvertCharacterToTag(ctx context.Context, characterI
 		if err := charClient.DeleteByID(ctx, characterID); err != nil {
 			return fmt.Errorf("CharacterClient.DeleteByID: %w", err)
 		}
+		if err := s.dbClient.AnimeCharacter().DeleteByCharacterIDs(ctx, []uint{characterID}); err != nil {
+			return fmt.Errorf("AnimeCharacterClient.DeleteByCharacterIDs: %w", err)
+		}
 
 		result = Tag{
 			ID:       newTag.ID,
@@ -328,19 +351,25 @@ func (s *CharacterService) ConvertCharacterToTag(ctx context.Context, characterI
 	return result, nil
 }
 
-// ReadCharactersByAnimeID reads all characters for an anime, sorted by name.
-// ImageCount is set to 0; the detail page computes counts separately.
+// ReadCharactersByAnimeID reads all characters for an anime, including those
+// shared with it by another anime, sorted by name. ImageCount is set to 0; the
+// detail page computes counts separately.
 func (s *CharacterService) ReadCharactersByAnimeID(ctx context.Context, animeID uint) ([]CharacterInfo, error) {
-	characters, err := s.dbClient.Character().FindByAnimeID(animeID)
+	characters, err := s.dbClient.Character().FindByAnimeID(ctx, animeID)
 	if err != nil {
 		return nil, fmt.Errorf("Character.FindByAnimeID: %w", err)
 	}
+	animeIDs, err := s.dbClient.AnimeCharacter().FindAnimeIDsByCharacters(ctx, characters)
+	if err != nil {
+		return nil, fmt.Errorf("AnimeCharacter.FindAnimeIDsByCharacters: %w", err)
+	}
 	result := make([]CharacterInfo, len(characters))
 	for i, c := range characters {
 		result[i] = CharacterInfo{
-			ID:      c.ID,
-			Name:    c.Name,
-			AnimeID: c.AnimeID,
+			ID:       c.ID,
+			Name:     c.Name,
+			AnimeID:  c.AnimeID,
+			AnimeIDs: animeIDs[c.ID],
 		}
 	}
 	sort.SliceStable(result, func(i, j int) bool {
@@ -348,3 +377,63 @@ func (s *CharacterService) ReadCharactersByAnimeID(ctx context.Context, animeID
 	})
 	return result, nil
 }
+
+// AddCharacterToAnime shares a character with another anime, e.g. for a
+// crossover, so one character row carries its images across both.
+func (s *CharacterService) AddCharacterToAnime(ctx context.Context, characterID uint, animeID uint) error {
+	character, err := s.dbClient.Character().FindByValue(ctx, &db.Character{ID: characterID})
+	if err != nil {
+		return fmt.Errorf("Character.FindByValue: %w", err)
+	}
+	if _, err := s.dbClient.Anime().FindByValue(ctx, &db.Anime{ID: animeID}); err != nil {
+		return fmt.Errorf("Anime.FindByValue: %w", err)
+	}
+	if character.AnimeID == animeID {
+		return nil
+	}
+	return s.dbClient.AnimeCharacter().BatchCreateIgnoringExisting(ctx, []db.AnimeCharacter{
+		{AnimeID: animeID, CharacterID: characterID},
+	})
+}
+
+// RemoveCharacterFromAnime stops sharing a character with an anime. Removing
+// it from the anime it was created under moves it to another of its anime; a
+// character that belongs to only one anime has to be deleted instead.
+func (s *CharacterService) RemoveCharacterFromAnime(ctx context.Context, characterID uint, animeID uint) error {
+	return db.NewTransaction(ctx, s.dbClient, func(ctx context.Context) error {
+		character, err := s.dbClient.Character().FindByValue(ctx, &db.Character{ID: characterID})
+		if err != nil {
+			return fmt.Errorf("Character.FindByValue: %w", err)
+		}
+		animeIDs, err := s.dbClient.AnimeCharacter().FindAnimeIDsByCharacters(ctx, []db.Character{character})
+		if err != nil {
+			return fmt.Errorf("AnimeCharacter.FindAnimeIDsByCharacters: %w", err)
+		}
+		remaining := make([]uint, 0)
+		for _, id := range animeIDs[characterID] {
+			if id != animeID {
+				remaining = append(remaining, id)
+			}
+		}
+		if len(remaining) == len(animeIDs[characterID]) {
+			return nil
+		}
+		if len(remaining) == 0 {
+			return fmt.Errorf("%w: character %d belongs to no other anime", ErrInvalidArgument, characterID)
+		}
+
+		if character.AnimeID == animeID {
+			character.AnimeID = remaining[0]
+			if err := s.dbClient.Character().Update(ctx, &character); err != nil {
+				return fmt.Errorf("Character.Update: %w", err)
+			}
+		}
+		if err := s.dbClient.AnimeCharacter().BatchDelete(ctx, []db.AnimeCharacter{
+			{AnimeID: animeID, CharacterID: characterID},
+			{AnimeID: character.AnimeID, CharacterID: characterID},
+		}); err != nil {
+			return fmt.Errorf("AnimeCharacter.BatchDelete: %w", err)
+		}
+		return nil
+	})
+}
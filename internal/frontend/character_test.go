@@ -12,7 +12,7 @@ import (
 func TestCharacterService_CreateCharacter(t *testing.T) {
 	tester := newTester(t)
 	tester.dbClient.Truncate(t, db.Character{}, db.FileCharacter{})
-	svc := NewCharacterService(tester.dbClient.Client)
+	svc := NewCharacterService(tester.dbClient.Client, tester.getJournal())
 	ctx := context.Background()
 
 	t.Run("happy path", func(t *testing.T) {
@@ -46,7 +46,7 @@ func TestCharacterService_CreateCharacter(t *testing.T) {
 func TestCharacterService_RenameCharacter(t *testing.T) {
 	tester := newTester(t)
 	tester.dbClient.Truncate(t, db.Character{}, db.FileCharacter{}, db.Anime{})
-	svc := NewCharacterService(tester.dbClient.Client)
+	svc := NewCharacterService(tester.dbClient.Client, tester.getJournal())
 	ctx := context.Background()
 
 	animeRow := db.Anime{Name: "RenameTestAnime"}
@@ -79,7 +79,7 @@ func TestCharacterService_RenameCharacter(t *testing.T) {
 func TestCharacterService_DeleteCharacter(t *testing.T) {
 	tester := newTester(t)
 	tester.dbClient.Truncate(t, db.Character{}, db.FileCharacter{}, db.Anime{})
-	svc := NewCharacterService(tester.dbClient.Client)
+	svc := NewCharacterService(tester.dbClient.Client, tester.getJournal())
 	ctx := context.Background()
 
 	animeRow := db.Anime{Name: "DeleteTestAnime"}
@@ -112,7 +112,7 @@ func TestCharacterService_DeleteCharacter(t *testing.T) {
 func TestCharacterService_GetCharacterFileCount(t *testing.T) {
 	tester := newTester(t)
 	tester.dbClient.Truncate(t, db.Character{}, db.FileCharacter{})
-	svc := NewCharacterService(tester.dbClient.Client)
+	svc := NewCharacterService(tester.dbClient.Client, tester.getJournal())
 
 	characters := []db.Character{
 		{ID: 5001, Name: "CharWithFiles", AnimeID: 1},
@@ -143,7 +143,7 @@ func TestCharacterService_GetCharacterFileCount(t *testing.T) {
 func TestCharacterService_BatchUpdateCharactersForFiles(t *testing.T) {
 	tester := newTester(t)
 	tester.dbClient.Truncate(t, db.Character{}, db.FileCharacter{})
-	svc := NewCharacterService(tester.dbClient.Client)
+	svc := NewCharacterService(tester.dbClient.Client, tester.getJournal())
 	ctx := context.Background()
 
 	characters := []db.Character{
@@ -200,7 +200,7 @@ func TestCharacterService_BatchUpdateCharactersForFiles(t *testing.T) {
 func TestCharacterService_GetImageCharacterIDs(t *testing.T) {
 	tester := newTester(t)
 	tester.dbClient.Truncate(t, db.Character{}, db.FileCharacter{})
-	svc := NewCharacterService(tester.dbClient.Client)
+	svc := NewCharacterService(tester.dbClient.Client, tester.getJournal())
 	ctx := context.Background()
 
 	fcs := []db.FileCharacter{
@@ -228,7 +228,7 @@ func TestCharacterService_GetImageCharacterIDs(t *testing.T) {
 func TestCharacterService_ReadCharactersByAnimeID(t *testing.T) {
 	tester := newTester(t)
 	tester.dbClient.Truncate(t, db.Character{}, db.FileCharacter{})
-	svc := NewCharacterService(tester.dbClient.Client)
+	svc := NewCharacterService(tester.dbClient.Client, tester.getJournal())
 	ctx := context.Background()
 
 	characters := []db.Character{
@@ -266,10 +266,50 @@ func TestCharacterService_ReadCharactersByAnimeID(t *testing.T) {
 	})
 }
 
+func TestCharacterService_ShareCharacterWithAnime(t *testing.T) {
+	tester := newTester(t)
+	tester.dbClient.Truncate(t, db.Character{}, db.FileCharacter{}, db.Anime{}, db.AnimeCharacter{})
+	svc := NewCharacterService(tester.dbClient.Client, tester.getJournal())
+	ctx := context.Background()
+
+	db.LoadTestData(t, tester.dbClient, []db.Anime{
+		{ID: 1, Name: "Fate Zero"},
+		{ID: 2, Name: "Fate Stay Night"},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.Character{
+		{ID: 7101, Name: "Saber", AnimeID: 1},
+		{ID: 7102, Name: "Shirou", AnimeID: 2},
+	})
+
+	require.NoError(t, svc.AddCharacterToAnime(ctx, 7101, 2))
+	require.NoError(t, svc.AddCharacterToAnime(ctx, 7101, 2), "adding twice is a no-op")
+	got, err := svc.ReadCharactersByAnimeID(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, "Saber", got[0].Name)
+	assert.Equal(t, []uint{1, 2}, got[0].AnimeIDs)
+	assert.Equal(t, []uint{2}, got[1].AnimeIDs)
+
+	// Removing Saber from the anime it was created under moves it.
+	require.NoError(t, svc.RemoveCharacterFromAnime(ctx, 7101, 1))
+	got, err = svc.ReadCharactersByAnimeID(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+	got, err = svc.ReadCharactersByAnimeID(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, uint(2), got[0].AnimeID)
+	assert.Empty(t, db.MustGetAll[db.AnimeCharacter](t, tester.dbClient))
+
+	err = svc.RemoveCharacterFromAnime(ctx, 7101, 2)
+	assert.ErrorIs(t, err, ErrInvalidArgument, "the last anime cannot be removed")
+	assert.Error(t, svc.AddCharacterToAnime(ctx, 7101, 999))
+}
+
 func TestCharacterService_ConvertTagToCharacter(t *testing.T) {
 	tester := newTester(t)
 	tester.dbClient.Truncate(t, db.Character{}, db.FileCharacter{}, db.Tag{}, db.FileTag{}, db.Anime{})
-	svc := NewCharacterService(tester.dbClient.Client)
+	svc := NewCharacterService(tester.dbClient.Client, tester.getJournal())
 	ctx := context.Background()
 
 	// Create an anime
@@ -362,7 +402,7 @@ func TestCharacterService_ConvertTagToCharacter(t *testing.T) {
 func TestCharacterService_ConvertCharacterToTag(t *testing.T) {
 	tester := newTester(t)
 	tester.dbClient.Truncate(t, db.Character{}, db.FileCharacter{}, db.Tag{}, db.FileTag{}, db.Anime{})
-	svc := NewCharacterService(tester.dbClient.Client)
+	svc := NewCharacterService(tester.dbClient.Client, tester.getJournal())
 	ctx := context.Background()
 
 	// Create an anime
@@ -458,7 +498,7 @@ func TestCharacterService_ConvertCharacterToTag(t *testing.T) {
 // returns an error when the database write fails.
 func TestCharacterService_CreateCharacter_DBError(t *testing.T) {
 	tester := newTester(t)
-	svc := NewCharacterService(tester.dbClient.Client)
+	svc := NewCharacterService(tester.dbClient.Client, tester.getJournal())
 	ctx := context.Background()
 
 	// Drop the characters table so db.Create fails
@@ -474,7 +514,7 @@ func TestCharacterService_CreateCharacter_DBError(t *testing.T) {
 func TestCharacterService_DeleteCharacter_FileCharacterDeleteError(t *testing.T) {
 	tester := newTester(t)
 	tester.dbClient.Truncate(t, db.Character{}, db.FileCharacter{})
-	svc := NewCharacterService(tester.dbClient.Client)
+	svc := NewCharacterService(tester.dbClient.Client, tester.getJournal())
 	ctx := context.Background()
 
 	// Create a character first
@@ -494,7 +534,7 @@ func TestCharacterService_DeleteCharacter_FileCharacterDeleteError(t *testing.T)
 func TestCharacterService_DeleteCharacter_CharacterDeleteError(t *testing.T) {
 	tester := newTester(t)
 	tester.dbClient.Truncate(t, db.Character{}, db.FileCharacter{})
-	svc := NewCharacterService(tester.dbClient.Client)
+	svc := NewCharacterService(tester.dbClient.Client, tester.getJournal())
 	ctx := context.Background()
 
 	// Create a character first (no file associations, so FileCharacter delete succeeds)
@@ -513,7 +553,7 @@ func TestCharacterService_DeleteCharacter_CharacterDeleteError(t *testing.T) {
 // GetCharacterFileCount returns an error when the database query fails.
 func TestCharacterService_GetCharacterFileCount_DBError(t *testing.T) {
 	tester := newTester(t)
-	svc := NewCharacterService(tester.dbClient.Client)
+	svc := NewCharacterService(tester.dbClient.Client, tester.getJournal())
 
 	// Drop the file_characters table so FindByCharacterIDs fails
 	tester.dbClient.DropTable(t, &db.FileCharacter{})
@@ -527,7 +567,7 @@ func TestCharacterService_GetCharacterFileCount_DBError(t *testing.T) {
 // GetImageCharacterIDs returns an error when the database query fails.
 func TestCharacterService_GetImageCharacterIDs_DBError(t *testing.T) {
 	tester := newTester(t)
-	svc := NewCharacterService(tester.dbClient.Client)
+	svc := NewCharacterService(tester.dbClient.Client, tester.getJournal())
 	ctx := context.Background()
 
 	// Drop the file_characters table so FindByFileIDs fails
@@ -542,7 +582,7 @@ func TestCharacterService_GetImageCharacterIDs_DBError(t *testing.T) {
 // ReadCharactersByAnimeID returns an error when the database query fails.
 func TestCharacterService_ReadCharactersByAnimeID_DBError(t *testing.T) {
 	tester := newTester(t)
-	svc := NewCharacterService(tester.dbClient.Client)
+	svc := NewCharacterService(tester.dbClient.Client, tester.getJournal())
 	ctx := context.Background()
 
 	// Drop the characters table so FindByAnimeID fails
@@ -557,7 +597,7 @@ func TestCharacterService_ReadCharactersByAnimeID_DBError(t *testing.T) {
 // BatchUpdateCharactersForFiles returns an error when the initial DB query fails.
 func TestCharacterService_BatchUpdateCharactersForFiles_DBError(t *testing.T) {
 	tester := newTester(t)
-	svc := NewCharacterService(tester.dbClient.Client)
+	svc := NewCharacterService(tester.dbClient.Client, tester.getJournal())
 	ctx := context.Background()
 
 	// Drop the file_characters table so FindByFileIDs fails
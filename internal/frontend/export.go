@@ -0,0 +1,68 @@
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/export"
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+// ExportSelectionRequest is a request to export the images selected in the
+// frontend as a dataset.
+type ExportSelectionRequest struct {
+	ExportDirectory string       `json:"exportDirectory"`
+	Query           export.Query `json:"query"`
+	Incremental     bool         `json:"incremental"`
+	// LabelFormat is "dense" or "sparse". It defaults to "dense".
+	LabelFormat string `json:"labelFormat"`
+}
+
+type ExportService struct {
+	logger   *slog.Logger
+	config   config.Config
+	dbClient *db.Client
+}
+
+func NewExportService(logger *slog.Logger, conf config.Config, dbClient *db.Client) *ExportService {
+	return &ExportService{
+		logger:   logger,
+		config:   conf,
+		dbClient: dbClient,
+	}
+}
+
+// ExportSelection exports the images that match the query of the request,
+// e.g. the selected images or an anime.
+func (s *ExportService) ExportSelection(ctx context.Context, request ExportSelectionRequest) error {
+	if request.ExportDirectory == "" {
+		return fmt.Errorf("%w: exportDirectory required", ErrInvalidArgument)
+	}
+	exporter := export.NewBatchImageExporter(s.logger, s.config, s.dbClient, export.BatchImageExporterOptions{
+		IsDirectoryTagExcluded: true,
+		LabelFormat:            export.LabelFormat(request.LabelFormat),
+		Query:                  request.Query,
+		Incremental:            request.Incremental,
+	})
+	if err := exporter.Export(ctx, request.ExportDirectory); err != nil {
+		return fmt.Errorf("exporter.Export: %w", err)
+	}
+	return nil
+}
+
+// SelectDirectory opens a native directory picker dialog and returns the selected path.
+func (s *ExportService) SelectDirectory(ctx context.Context) (string, error) {
+	path, err := application.OpenFileDialog().
+		CanChooseDirectories(true).
+		CanChooseFiles(false).
+		CanCreateDirectories(true).
+		AttachToWindow(application.Get().CurrentWindow()).
+		PromptForSingleSelection()
+	if err != nil {
+		return "", fmt.Errorf("application.OpenFileDialog: %w", err)
+	}
+	return path, nil
+}
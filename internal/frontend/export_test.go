@@ -0,0 +1,56 @@
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/export"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportService_ExportSelection(t *testing.T) {
+	tester := newTester(t)
+	fileCreator := tester.newFileCreator(t)
+	fileCreator.CreateDirectory(image.Directory{ID: 1, Name: "Frieren"})
+	fileCreator.CreateImage(image.ImageFile{ID: 11, Name: "a.jpg", ParentID: 1}, image.TestImageFileJpeg)
+	fileCreator.CreateImage(image.ImageFile{ID: 12, Name: "b.jpg", ParentID: 1}, image.TestImageFileJpeg)
+
+	tester.dbClient.Truncate(t, db.File{}, db.Tag{}, db.FileTag{})
+	db.LoadTestData(t, tester.dbClient, []db.File{
+		fileCreator.BuildDBDirectory(1),
+		fileCreator.BuildDBImageFile(11),
+		fileCreator.BuildDBImageFile(12),
+	})
+	db.LoadTestData(t, tester.dbClient, []db.Tag{{ID: 1, Name: "smile"}})
+	db.LoadTestData(t, tester.dbClient, []db.FileTag{
+		{FileID: 11, TagID: 1},
+		{FileID: 12, TagID: 1},
+	})
+
+	service := NewExportService(tester.logger, tester.config, tester.dbClient.Client)
+	exportDirectory := t.TempDir()
+	require.NoError(t, service.ExportSelection(context.Background(), ExportSelectionRequest{
+		ExportDirectory: exportDirectory,
+		Query:           export.Query{ImageFileIDs: []uint{12}},
+		LabelFormat:     string(export.LabelFormatSparse),
+	}))
+
+	content, err := os.ReadFile(filepath.Join(exportDirectory, "train", "metadata.jsonl"))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 1)
+	var metadata export.Metadata
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &metadata))
+	assert.Equal(t, "12.jpg", metadata.FileName)
+	assert.Equal(t, []string{"smile"}, metadata.TagNames)
+
+	err = service.ExportSelection(context.Background(), ExportSelectionRequest{})
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+}
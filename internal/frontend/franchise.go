@@ -0,0 +1,246 @@
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/michael-freling/anime-image-viewer/internal/anime"
+)
+
+// Franchise is the JSON-friendly franchise model exposed to the frontend.
+type Franchise struct {
+	ID                  uint   `json:"id"`
+	Name                string `json:"name"`
+	MetadataFranchiseID string `json:"metadataFranchiseId"`
+}
+
+// FranchiseListItem is a franchise row for the list page, with totals over
+// its anime.
+type FranchiseListItem struct {
+	ID             uint   `json:"id"`
+	Name           string `json:"name"`
+	AnimeCount     uint   `json:"animeCount"`
+	ImageCount     uint   `json:"imageCount"`
+	CoverImagePath string `json:"coverImagePath"`
+}
+
+// FranchiseDetailsResponse is the payload of the franchise page. Tags and
+// characters are aggregated over every anime of the franchise.
+type FranchiseDetailsResponse struct {
+	Franchise  Franchise            `json:"franchise"`
+	Anime      []AnimeListItem      `json:"anime"`
+	Tags       []AnimeTagInfo       `json:"tags"`
+	Characters []AnimeCharacterInfo `json:"characters"`
+}
+
+func newFranchiseResponse(f anime.Franchise) Franchise {
+	return Franchise{
+		ID:                  f.ID,
+		Name:                f.Name,
+		MetadataFranchiseID: f.MetadataFranchiseID,
+	}
+}
+
+// ListFranchises returns every franchise with its anime and image counts,
+// sorted by name.
+func (s *AnimeService) ListFranchises(ctx context.Context) ([]FranchiseListItem, error) {
+	franchises, err := s.core.ReadFranchises(ctx)
+	if err != nil || len(franchises) == 0 {
+		return nil, err
+	}
+	imageCounts, err := s.core.CountImagesForAnimeFolders()
+	if err != nil {
+		return nil, fmt.Errorf("CountImagesForAnimeFolders: %w", err)
+	}
+
+	// The cover is the first anime of the franchise that has one.
+	animeRows := make([]anime.Anime, 0)
+	for _, f := range franchises {
+		for _, animeID := range f.AnimeIDs {
+			animeRows = append(animeRows, anime.Anime{ID: animeID})
+		}
+	}
+	coverPaths := s.resolveCoverImages(animeRows)
+
+	result := make([]FranchiseListItem, len(franchises))
+	for i, f := range franchises {
+		item := FranchiseListItem{
+			ID:         f.ID,
+			Name:       f.Name,
+			AnimeCount: uint(len(f.AnimeIDs)),
+		}
+		for _, animeID := range f.AnimeIDs {
+			item.ImageCount += imageCounts[animeID]
+			if item.CoverImagePath == "" {
+				item.CoverImagePath = coverPaths[animeID]
+			}
+		}
+		result[i] = item
+	}
+	return result, nil
+}
+
+// GetFranchiseDetails returns the franchise page payload: its anime, and the
+// tags and characters derived from all of their images.
+func (s *AnimeService) GetFranchiseDetails(ctx context.Context, id uint) (FranchiseDetailsResponse, error) {
+	franchise, err := s.core.ReadFranchise(ctx, id)
+	if err != nil {
+		return FranchiseDetailsResponse{}, err
+	}
+
+	animeList, err := s.ListAnime(ctx)
+	if err != nil {
+		return FranchiseDetailsResponse{}, err
+	}
+	members := make(map[uint]bool, len(franchise.AnimeIDs))
+	for _, animeID := range franchise.AnimeIDs {
+		members[animeID] = true
+	}
+	animeItems := make([]AnimeListItem, 0, len(franchise.AnimeIDs))
+	for _, item := range animeList {
+		if members[item.ID] {
+			animeItems = append(animeItems, item)
+		}
+	}
+
+	// Anime folder trees do not overlap, so per-anime counts add up, also
+	// for a character shared by several anime of the franchise.
+	tagsByID := make(map[uint]*AnimeTagInfo)
+	charsByID := make(map[uint]*AnimeCharacterInfo)
+	for _, animeID := range franchise.AnimeIDs {
+		derivedTags, err := s.core.DeriveTagsForAnime(animeID)
+		if err != nil {
+			return FranchiseDetailsResponse{}, fmt.Errorf("core.DeriveTagsForAnime: %w", err)
+		}
+		for _, dt := range derivedTags {
+			if info, ok := tagsByID[dt.TagID]; ok {
+				info.ImageCount += dt.ImageCount
+				continue
+			}
+			tagsByID[dt.TagID] = &AnimeTagInfo{
+				ID:         dt.TagID,
+				Name:       dt.TagName,
+				Category:   dt.TagCategory,
+				ImageCount: dt.ImageCount,
+			}
+		}
+
+		derivedChars, err := s.core.DeriveCharactersForAnime(ctx, animeID)
+		if err != nil {
+			return FranchiseDetailsResponse{}, fmt.Errorf("core.DeriveCharactersForAnime: %w", err)
+		}
+		for _, dc := range derivedChars {
+			if info, ok := charsByID[dc.CharacterID]; ok {
+				info.ImageCount += dc.ImageCount
+				continue
+			}
+			charsByID[dc.CharacterID] = &AnimeCharacterInfo{
+				ID:         dc.CharacterID,
+				Name:       dc.CharacterName,
+				ImageCount: dc.ImageCount,
+				AnimeIDs:   dc.AnimeIDs,
+			}
+		}
+	}
+
+	tagInfos := make([]AnimeTagInfo, 0, len(tagsByID))
+	tagIDs := make([]uint, 0, len(tagsByID))
+	for _, info := range tagsByID {
+		tagInfos = append(tagInfos, *info)
+		tagIDs = append(tagIDs, info.ID)
+	}
+	thumbnailPaths := s.resolveTagThumbnails(tagIDs)
+	for i := range tagInfos {
+		tagInfos[i].ThumbnailPath = thumbnailPaths[tagInfos[i].ID]
+	}
+	sort.SliceStable(tagInfos, func(i, j int) bool {
+		return strings.ToLower(tagInfos[i].Name) < strings.ToLower(tagInfos[j].Name)
+	})
+
+	charInfos := make([]AnimeCharacterInfo, 0, len(charsByID))
+	charIDs := make([]uint, 0, len(charsByID))
+	for _, info := range charsByID {
+		charInfos = append(charInfos, *info)
+		charIDs = append(charIDs, info.ID)
+	}
+	charThumbnailPaths := s.resolveCharacterThumbnails(charIDs)
+	for i := range charInfos {
+		charInfos[i].ThumbnailPath = charThumbnailPaths[charInfos[i].ID]
+	}
+	sort.SliceStable(charInfos, func(i, j int) bool {
+		return strings.ToLower(charInfos[i].Name) < strings.ToLower(charInfos[j].Name)
+	})
+
+	return FranchiseDetailsResponse{
+		Franchise:  newFranchiseResponse(franchise),
+		Anime:      animeItems,
+		Tags:       tagInfos,
+		Characters: charInfos,
+	}, nil
+}
+
+// SearchImagesByFranchise returns the images of every anime in a franchise.
+func (s *AnimeService) SearchImagesByFranchise(ctx context.Context, id uint) (SearchImagesResponse, error) {
+	franchise, err := s.core.ReadFranchise(ctx, id)
+	if err != nil {
+		return SearchImagesResponse{}, err
+	}
+	if len(franchise.AnimeIDs) == 0 {
+		return SearchImagesResponse{}, nil
+	}
+
+	resolved, err := s.core.ResolveFolderAnimeMap()
+	if err != nil {
+		return SearchImagesResponse{}, err
+	}
+	tree, err := s.directoryReader.ReadDirectoryTree()
+	if err != nil {
+		return SearchImagesResponse{}, fmt.Errorf("directoryReader.ReadDirectoryTree: %w", err)
+	}
+
+	imageIDs := make([]uint, 0)
+	for _, animeID := range franchise.AnimeIDs {
+		collectImageIDsForAnime(&tree, animeID, resolved, &imageIDs)
+	}
+	if len(imageIDs) == 0 {
+		return SearchImagesResponse{}, nil
+	}
+
+	imageFiles, err := s.imageReader.ReadImagesByIDs(imageIDs)
+	if err != nil {
+		return SearchImagesResponse{}, fmt.Errorf("imageReader.ReadImagesByIDs: %w", err)
+	}
+	results := make([]Image, 0, len(imageFiles))
+	for _, f := range imageFiles {
+		results = append(results, newImageConverterFromImageFiles(f).Convert())
+	}
+	return SearchImagesResponse{Images: results}, nil
+}
+
+// CreateFranchise creates a franchise by hand, for anime the metadata
+// database does not group.
+func (s *AnimeService) CreateFranchise(ctx context.Context, name string) (Franchise, error) {
+	f, err := s.core.CreateFranchise(ctx, name)
+	if err != nil {
+		return Franchise{}, err
+	}
+	return newFranchiseResponse(f), nil
+}
+
+// RenameFranchise changes a franchise's display name.
+func (s *AnimeService) RenameFranchise(ctx context.Context, id uint, name string) error {
+	return s.core.RenameFranchise(ctx, id, name)
+}
+
+// DeleteFranchise deletes a franchise, keeping its anime.
+func (s *AnimeService) DeleteFranchise(ctx context.Context, id uint) error {
+	return s.core.DeleteFranchise(ctx, id)
+}
+
+// SetAnimeFranchise moves an anime into a franchise, or out of any franchise
+// when franchiseID is 0.
+func (s *AnimeService) SetAnimeFranchise(ctx context.Context, animeID uint, franchiseID uint) error {
+	return s.core.SetAnimeFranchise(ctx, animeID, franchiseID)
+}
@@ -0,0 +1,99 @@
+package frontend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnimeService_Franchise(t *testing.T) {
+	tester := newTester(t)
+	tester.dbClient.Truncate(t, db.File{}, db.Tag{}, db.Anime{}, db.FileTag{}, db.Character{}, db.FileCharacter{}, db.Franchise{})
+	svc := tester.getAnimeService()
+	ctx := context.Background()
+
+	zero, err := svc.CreateAnime(ctx, "Fate Zero")
+	require.NoError(t, err)
+	stayNight, err := svc.CreateAnime(ctx, "Fate Stay Night")
+	require.NoError(t, err)
+	other, err := svc.CreateAnime(ctx, "Other")
+	require.NoError(t, err)
+
+	fileCreator := tester.newFileCreator(t).
+		CreateDirectory(image.Directory{ID: 14010, Name: "zero"}).
+		CreateDirectory(image.Directory{ID: 14011, Name: "stay"}).
+		CreateDirectory(image.Directory{ID: 14012, Name: "other"})
+	fileCreator.CreateImage(image.ImageFile{ID: 14100, ParentID: 14010, Name: "zero.jpg"}, image.TestImageFileJpeg)
+	fileCreator.CreateImage(image.ImageFile{ID: 14101, ParentID: 14011, Name: "stay.jpg"}, image.TestImageFileJpeg)
+	fileCreator.CreateImage(image.ImageFile{ID: 14102, ParentID: 14012, Name: "other.jpg"}, image.TestImageFileJpeg)
+	db.LoadTestData(t, tester.dbClient, []db.File{
+		fileCreator.BuildDBDirectory(14010),
+		fileCreator.BuildDBDirectory(14011),
+		fileCreator.BuildDBDirectory(14012),
+		fileCreator.BuildDBImageFile(14100),
+		fileCreator.BuildDBImageFile(14101),
+		fileCreator.BuildDBImageFile(14102),
+	})
+	require.NoError(t, svc.AssignFolderToAnime(ctx, zero.ID, 14010))
+	require.NoError(t, svc.AssignFolderToAnime(ctx, stayNight.ID, 14011))
+	require.NoError(t, svc.AssignFolderToAnime(ctx, other.ID, 14012))
+	db.LoadTestData(t, tester.dbClient, []db.Tag{
+		{ID: 14000, Name: "saber"},
+		{ID: 14001, Name: "night"},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.FileTag{
+		{FileID: 14100, TagID: 14000, AddedBy: db.FileTagAddedByUser},
+		{FileID: 14101, TagID: 14000, AddedBy: db.FileTagAddedByUser},
+		{FileID: 14101, TagID: 14001, AddedBy: db.FileTagAddedByUser},
+		{FileID: 14102, TagID: 14001, AddedBy: db.FileTagAddedByUser},
+	})
+
+	franchise, err := svc.CreateFranchise(ctx, "Fate")
+	require.NoError(t, err)
+	require.NoError(t, svc.SetAnimeFranchise(ctx, zero.ID, franchise.ID))
+	require.NoError(t, svc.SetAnimeFranchise(ctx, stayNight.ID, franchise.ID))
+
+	t.Run("list totals over the franchise's anime", func(t *testing.T) {
+		list, err := svc.ListFranchises(ctx)
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		assert.Equal(t, "Fate", list[0].Name)
+		assert.Equal(t, uint(2), list[0].AnimeCount)
+		assert.Equal(t, uint(2), list[0].ImageCount)
+		assert.NotEmpty(t, list[0].CoverImagePath)
+	})
+
+	t.Run("details aggregate tags across anime", func(t *testing.T) {
+		details, err := svc.GetFranchiseDetails(ctx, franchise.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Fate", details.Franchise.Name)
+		require.Len(t, details.Anime, 2)
+		assert.Equal(t, "Fate Stay Night", details.Anime[0].Name)
+		assert.Equal(t, "Fate Zero", details.Anime[1].Name)
+
+		require.Len(t, details.Tags, 2)
+		assert.Equal(t, "night", details.Tags[0].Name)
+		assert.Equal(t, uint(1), details.Tags[0].ImageCount, "the other anime's image is not counted")
+		assert.Equal(t, "saber", details.Tags[1].Name)
+		assert.Equal(t, uint(2), details.Tags[1].ImageCount)
+
+		animeDetails, err := svc.GetAnimeDetails(ctx, zero.ID)
+		require.NoError(t, err)
+		require.NotNil(t, animeDetails.Anime.FranchiseID)
+		assert.Equal(t, franchise.ID, *animeDetails.Anime.FranchiseID)
+	})
+
+	t.Run("images of every anime in the franchise", func(t *testing.T) {
+		got, err := svc.SearchImagesByFranchise(ctx, franchise.ID)
+		require.NoError(t, err)
+		ids := make([]uint, len(got.Images))
+		for i, img := range got.Images {
+			ids[i] = img.ID
+		}
+		assert.ElementsMatch(t, []uint{14100, 14101}, ids)
+	})
+}
@@ -0,0 +1,86 @@
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/history"
+)
+
+// defaultOperationHistoryLimit caps how many operations ReadOperationHistory
+// returns when the caller does not specify a limit.
+const defaultOperationHistoryLimit = 50
+
+// Operation is an entry of the tag, character and file edit history.
+type Operation struct {
+	ID        uint   `json:"id"`
+	Kind      string `json:"kind"`
+	Summary   string `json:"summary"`
+	UserName  string `json:"userName"`
+	CreatedAt string `json:"createdAt"`
+	Undone    bool   `json:"undone"`
+	UndoneBy  string `json:"undoneBy"`
+	CanUndo   bool   `json:"canUndo"`
+	CanRedo   bool   `json:"canRedo"`
+}
+
+// HistoryService exposes the edit history to the frontend, with undo and
+// redo of the latest operations.
+type HistoryService struct {
+	journal *history.Journal
+}
+
+func NewHistoryService(journal *history.Journal) *HistoryService {
+	return &HistoryService{
+		journal: journal,
+	}
+}
+
+// ReadOperationHistory returns a page of the history, newest first.
+func (s *HistoryService) ReadOperationHistory(ctx context.Context, limit int, offset int) ([]Operation, error) {
+	if limit <= 0 {
+		limit = defaultOperationHistoryLimit
+	}
+	operations, err := s.journal.List(ctx, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("Journal.List: %w", err)
+	}
+	result := make([]Operation, len(operations))
+	for i, operation := range operations {
+		result[i] = convertOperation(operation)
+	}
+	return result, nil
+}
+
+// Undo reverts the latest operation and returns it.
+func (s *HistoryService) Undo(ctx context.Context) (Operation, error) {
+	operation, err := s.journal.Undo(ctx)
+	if err != nil {
+		return Operation{}, fmt.Errorf("Journal.Undo: %w", err)
+	}
+	return convertOperation(operation), nil
+}
+
+// Redo re-applies the operation undone last and returns it.
+func (s *HistoryService) Redo(ctx context.Context) (Operation, error) {
+	operation, err := s.journal.Redo(ctx)
+	if err != nil {
+		return Operation{}, fmt.Errorf("Journal.Redo: %w", err)
+	}
+	return convertOperation(operation), nil
+}
+
+func convertOperation(operation history.Operation) Operation {
+	return Operation{
+		ID:        operation.ID,
+		Kind:      string(operation.Kind),
+		Summary:   operation.Summary,
+		UserName:  operation.UserName,
+		CreatedAt: operation.CreatedAt.Format(time.RFC3339),
+		Undone:    operation.Undone,
+		UndoneBy:  operation.UndoneBy,
+		CanUndo:   operation.CanUndo,
+		CanRedo:   operation.CanRedo,
+	}
+}
@@ -0,0 +1,71 @@
+package frontend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/history"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryService(t *testing.T) {
+	tester := newTester(t)
+	tester.dbClient.Truncate(t, db.File{}, db.Anime{}, db.Character{}, db.FileCharacter{}, db.Operation{})
+	journal := tester.getJournal()
+	historyService := NewHistoryService(journal)
+	animeService := tester.getAnimeService()
+	characterService := NewCharacterService(tester.dbClient.Client, journal)
+	ctx := context.Background()
+
+	db.LoadTestData(t, tester.dbClient, []db.File{
+		{ID: 21001, Name: "Season 1", Type: db.FileTypeDirectory},
+		{ID: 21002, Name: "Season 2", Type: db.FileTypeDirectory},
+		{ID: 21100, Name: "a.jpg", ParentID: 21001, Type: db.FileTypeImage},
+		{ID: 21101, Name: "b.jpg", ParentID: 21001, Type: db.FileTypeImage},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.Character{
+		{ID: 21200, Name: "Saber", AnimeID: 1},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.FileCharacter{
+		{CharacterID: 21200, FileID: 21100, AddedBy: db.FileTagAddedBySuggestion},
+	})
+
+	require.NoError(t, characterService.BatchUpdateCharactersForFiles(ctx, []uint{21100, 21101}, nil, []uint{21200}))
+	require.NoError(t, animeService.MoveFilesToSeason(ctx, []uint{21100, 21101}, 21002))
+
+	operations, err := historyService.ReadOperationHistory(ctx, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, operations, 2)
+	assert.Equal(t, string(history.KindMoveFiles), operations[0].Kind)
+	assert.Equal(t, `Moved 2 files to "Season 2"`, operations[0].Summary)
+	assert.Equal(t, "tester", operations[0].UserName)
+	assert.True(t, operations[0].CanUndo)
+	assert.Equal(t, string(history.KindBatchUpdateCharacters), operations[1].Kind)
+
+	parentIDs := func() []uint {
+		files, err := tester.dbClient.Client.File().FindImageFilesByIDs([]uint{21100, 21101})
+		require.NoError(t, err)
+		return []uint{files[0].ParentID, files[1].ParentID}
+	}
+
+	undone, err := historyService.Undo(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, operations[0].ID, undone.ID)
+	assert.Equal(t, []uint{21001, 21001}, parentIDs())
+
+	_, err = historyService.Undo(ctx)
+	require.NoError(t, err)
+	fileCharacters := db.MustGetAll[db.FileCharacter](t, tester.dbClient)
+	require.Len(t, fileCharacters, 1, "only the link that existed comes back")
+	assert.Equal(t, db.FileTagAddedBySuggestion, fileCharacters[0].AddedBy)
+
+	_, err = historyService.Undo(ctx)
+	assert.ErrorIs(t, err, history.ErrNothingToUndo)
+
+	_, err = historyService.Redo(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, db.MustGetAll[db.FileCharacter](t, tester.dbClient))
+	assert.Equal(t, []uint{21001, 21001}, parentIDs(), "the move is still undone")
+}
@@ -7,19 +7,25 @@ import (
 	"os"
 
 	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/event"
 	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/michael-freling/anime-image-viewer/internal/plugin"
 	"github.com/wailsapp/wails/v3/pkg/application"
 )
 
 type ImageService struct {
 	imageReader *image.Reader
 	dbClient    *db.Client
+	eventBus    *event.Bus
+	registry    *plugin.Registry
 }
 
-func NewImageService(imageReader *image.Reader, dbClient *db.Client) *ImageService {
+func NewImageService(imageReader *image.Reader, dbClient *db.Client, eventBus *event.Bus, registry *plugin.Registry) *ImageService {
 	return &ImageService{
 		imageReader: imageReader,
 		dbClient:    dbClient,
+		eventBus:    eventBus,
+		registry:    registry,
 	}
 }
 
@@ -61,8 +67,9 @@ func (service *ImageService) ShowImageInExplorer(ctx context.Context, imageID ui
 
 // DeleteImages removes images from the database and from disk.
 // It deletes all associated tag and character links within a transaction,
-// then removes the file records, and finally deletes the physical files
-// from disk (best-effort: missing files are logged and skipped).
+// then removes the file records, and finally deletes the physical files and
+// the derived images of their annotations from disk (best-effort: missing
+// files are logged and skipped).
 func (service *ImageService) DeleteImages(ctx context.Context, imageIDs []uint) error {
 	if len(imageIDs) == 0 {
 		return nil
@@ -79,6 +86,12 @@ func (service *ImageService) DeleteImages(ctx context.Context, imageIDs []uint)
 		imageFiles = nil
 	}
 
+	// The derived images of the annotations are removed with the images.
+	annotations, err := service.dbClient.FileAnnotation().FindByFileIDs(ctx, imageIDs)
+	if err != nil {
+		return fmt.Errorf("FindByFileIDs (annotations): %w", err)
+	}
+
 	// Delete DB records in a transaction.
 	if err := db.NewTransaction(ctx, service.dbClient, func(txCtx context.Context) error {
 		if err := service.dbClient.FileTag().DeleteByFileIDs(txCtx, imageIDs); err != nil {
@@ -87,9 +100,22 @@ func (service *ImageService) DeleteImages(ctx context.Context, imageIDs []uint)
 		if err := service.dbClient.FileCharacter().DeleteByFileIDs(txCtx, imageIDs); err != nil {
 			return fmt.Errorf("DeleteByFileIDs (characters): %w", err)
 		}
+		if err := service.dbClient.FileAnnotation().DeleteByFileIDs(txCtx, imageIDs); err != nil {
+			return fmt.Errorf("DeleteByFileIDs (annotations): %w", err)
+		}
+		if err := service.dbClient.SuggestionReview().DeleteByFileIDs(txCtx, imageIDs); err != nil {
+			return fmt.Errorf("DeleteByFileIDs (suggestion reviews): %w", err)
+		}
+		if err := service.dbClient.AutoTaggedFile().DeleteByFileIDs(txCtx, imageIDs); err != nil {
+			return fmt.Errorf("DeleteByFileIDs (auto-tagged files): %w", err)
+		}
 		if err := service.dbClient.File().DeleteByIDs(txCtx, imageIDs); err != nil {
 			return fmt.Errorf("DeleteByIDs: %w", err)
 		}
+		service.eventBus.Publish(txCtx, event.TypeImagesDeleted, event.ImagesDeleted{
+			ImageIDs: imageIDs,
+			Images:   event.NewImages(imageFiles),
+		})
 		return nil
 	}); err != nil {
 		return fmt.Errorf("transaction: %w", err)
@@ -107,6 +133,18 @@ func (service *ImageService) DeleteImages(ctx context.Context, imageIDs []uint)
 			)
 		}
 	}
+	for _, annotation := range annotations {
+		if annotation.DerivedImagePath == "" {
+			continue
+		}
+		derivedImageFilePath := service.registry.DerivedImageFilePath(annotation)
+		if err := os.Remove(derivedImageFilePath); err != nil && !os.IsNotExist(err) {
+			slog.Warn("failed to delete a derived image from disk",
+				"path", derivedImageFilePath,
+				"error", err,
+			)
+		}
+	}
 
 	return nil
 }
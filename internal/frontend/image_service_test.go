@@ -9,12 +9,13 @@ import (
 
 	"github.com/michael-freling/anime-image-viewer/internal/db"
 	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/michael-freling/anime-image-viewer/internal/plugin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func (tester tester) getImageService() *ImageService {
-	return NewImageService(tester.getFileReader(), tester.dbClient.Client)
+	return NewImageService(tester.getFileReader(), tester.dbClient.Client, nil, tester.getPluginRegistry())
 }
 
 func TestImageService_ReadImagesByIDs(t *testing.T) {
@@ -95,7 +96,7 @@ func TestImageService_ReadImagesByIDs(t *testing.T) {
 
 func TestNewImageService(t *testing.T) {
 	tester := newTester(t)
-	service := NewImageService(tester.getFileReader(), tester.dbClient.Client)
+	service := NewImageService(tester.getFileReader(), tester.dbClient.Client, nil, tester.getPluginRegistry())
 	assert.NotNil(t, service)
 	assert.NotNil(t, service.imageReader)
 }
@@ -303,12 +304,20 @@ func TestImageService_DeleteImages(t *testing.T) {
 	}
 
 	t.Run("deletes images from DB and disk", func(t *testing.T) {
-		dbClient.Truncate(t, &db.File{}, &db.FileTag{}, &db.FileCharacter{})
+		dbClient.Truncate(t, &db.File{}, &db.FileTag{}, &db.FileCharacter{}, &db.FileAnnotation{}, &db.SuggestionReview{}, &db.AutoTaggedFile{})
 		db.LoadTestData(t, dbClient, []db.File{
 			fileBuilder.BuildDBDirectory(1),
 			fileBuilder.BuildDBImageFile(11),
 			fileBuilder.BuildDBImageFile(12),
 		})
+		derivedImagePath := filepath.Join("upscaler", "upscale", "11-0.png")
+		derivedImageFilePath := filepath.Join(plugin.AnnotationDirectory(tester.config), derivedImagePath)
+		require.NoError(t, os.MkdirAll(filepath.Dir(derivedImageFilePath), 0755))
+		require.NoError(t, os.WriteFile(derivedImageFilePath, []byte("upscaled"), 0644))
+		db.LoadTestData(t, dbClient, []db.FileAnnotation{
+			{ID: 1, FileID: 11, PluginName: "upscaler", Capability: "upscale", Type: db.FileAnnotationTypeDerivedImage, DerivedImagePath: derivedImagePath},
+			{ID: 2, FileID: 12, PluginName: "ocr", Capability: "ocr", Type: db.FileAnnotationTypeText, Text: "subtitle"},
+		})
 		db.LoadTestData(t, dbClient, []db.FileTag{
 			{TagID: 100, FileID: 11, AddedBy: db.FileTagAddedByUser},
 			{TagID: 100, FileID: 12, AddedBy: db.FileTagAddedByUser},
@@ -316,6 +325,12 @@ func TestImageService_DeleteImages(t *testing.T) {
 		db.LoadTestData(t, dbClient, []db.FileCharacter{
 			{CharacterID: 200, FileID: 11, AddedBy: db.FileTagAddedByUser},
 		})
+		db.LoadTestData(t, dbClient, []db.SuggestionReview{
+			{ID: 1, TagID: 101, FileID: 12, Score: 0.7},
+		})
+		db.LoadTestData(t, dbClient, []db.AutoTaggedFile{
+			{FileID: 12},
+		})
 
 		service := tester.getImageService()
 		err := service.DeleteImages(context.Background(), []uint{11, 12})
@@ -335,6 +350,14 @@ func TestImageService_DeleteImages(t *testing.T) {
 		remainingChars, err := dbClient.Client.FileCharacter().FindByFileIDs([]uint{11, 12})
 		require.NoError(t, err)
 		assert.Empty(t, remainingChars)
+
+		// Verify auto-tagging rows are removed.
+		assert.Empty(t, db.MustGetAll[db.SuggestionReview](t, dbClient))
+		assert.Empty(t, db.MustGetAll[db.AutoTaggedFile](t, dbClient))
+
+		// Verify annotations and their derived images are removed.
+		assert.Empty(t, db.MustGetAll[db.FileAnnotation](t, dbClient))
+		assert.NoFileExists(t, derivedImageFilePath)
 	})
 
 	t.Run("no error for empty IDs", func(t *testing.T) {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strings"
 
 	"github.com/michael-freling/anime-image-viewer/internal/image"
 	"github.com/michael-freling/anime-image-viewer/internal/import_images"
@@ -37,13 +38,15 @@ type ImportProgressEventFailure struct {
 type ImportProgressEvent struct {
 	Total     int                          `json:"total"`
 	Completed int                          `json:"completed"`
+	Skipped   int                          `json:"skipped"`
 	Failed    int                          `json:"failed"`
 	Failures  []ImportProgressEventFailure `json:"failures"`
 }
 
 // ImportImages imports images from the selected paths in a dialog shown in this method
+// The options choose how an image whose name is taken or that is already in the library is imported
 // This method emits an ImportImages:progress event to show the progress of importing images on the frontend
-func (service BatchImportImageService) ImportImages(ctx context.Context, directoryID uint) ([]Image, error) {
+func (service BatchImportImageService) ImportImages(ctx context.Context, directoryID uint, options import_images.ImportOptions) ([]Image, error) {
 	directory, err := service.directoryReader.ReadDirectory(directoryID)
 	if err != nil {
 		return nil, fmt.Errorf("service.ReadDirectory: %w", err)
@@ -76,24 +79,71 @@ func (service BatchImportImageService) ImportImages(ctx context.Context, directo
 	done := make(chan struct{})
 	defer close(done)
 	go progressNotifier.Run(done, func() {
-		failures := make([]ImportProgressEventFailure, 0)
-		for i, path := range progressNotifier.FailedPaths {
-			failures = append(failures, ImportProgressEventFailure{
-				Path:  path,
-				Error: progressNotifier.FailedErrors[i].Error(),
-			})
-		}
-
-		app.EmitEvent("ImportImages:progress", ImportProgressEvent{
-			Total:     total,
-			Completed: progressNotifier.Completed,
-			Failed:    progressNotifier.Failed,
-			Failures:  failures,
-		})
+		emitImportProgress(app, total, progressNotifier)
 	})
-	images, err := service.batchImageImporter.ImportImages(ctx, directory, paths, progressNotifier)
+	images, err := service.batchImageImporter.ImportImages(ctx, directory, paths, options, progressNotifier)
 	if err != nil {
 		return nil, fmt.Errorf("service.batchImageImporter.ImportImages: %w", err)
 	}
 	return newBatchImageConverter(images).Convert(), nil
 }
+
+// ImportArchive imports images from a zip or tar archive selected in a dialog
+// shown in this method. The folders in the archive become sub-directories of
+// the directory.
+// This method emits ImportImages:progress events as ImportImages does
+func (service BatchImportImageService) ImportArchive(ctx context.Context, directoryID uint, options import_images.ImportOptions) ([]Image, error) {
+	directory, err := service.directoryReader.ReadDirectory(directoryID)
+	if err != nil {
+		return nil, fmt.Errorf("service.ReadDirectory: %w", err)
+	}
+
+	archiveFilter := "*" + strings.Join(import_images.ArchiveExtensions, ";*")
+	path, err := application.OpenFileDialog().
+		AddFilter("Archives", archiveFilter).
+		AddFilter("All files", "*").
+		AttachToWindow(application.Get().CurrentWindow()).
+		PromptForSingleSelection()
+	if err != nil {
+		return nil, fmt.Errorf("application.OpenFileDialog: %w", err)
+	}
+	if path == "" {
+		return nil, nil
+	}
+
+	app := application.Get()
+	service.logger.DebugContext(ctx, "ImportArchive",
+		"directory", directory.Path,
+		"archive", path,
+	)
+
+	progressNotifier := import_images.NewProgressNotifier()
+	done := make(chan struct{})
+	defer close(done)
+	go progressNotifier.Run(done, func() {
+		emitImportProgress(app, progressNotifier.Total, progressNotifier)
+	})
+	images, err := service.batchImageImporter.ImportArchive(ctx, directory, path, options, progressNotifier)
+	if err != nil {
+		return nil, fmt.Errorf("service.batchImageImporter.ImportArchive: %w", err)
+	}
+	return newBatchImageConverter(images).Convert(), nil
+}
+
+func emitImportProgress(app *application.App, total int, progressNotifier *import_images.ProgressNotifier) {
+	failures := make([]ImportProgressEventFailure, 0)
+	for i, path := range progressNotifier.FailedPaths {
+		failures = append(failures, ImportProgressEventFailure{
+			Path:  path,
+			Error: progressNotifier.FailedErrors[i].Error(),
+		})
+	}
+
+	app.EmitEvent("ImportImages:progress", ImportProgressEvent{
+		Total:     total,
+		Completed: progressNotifier.Completed,
+		Skipped:   progressNotifier.Skipped,
+		Failed:    progressNotifier.Failed,
+		Failures:  failures,
+	})
+}
@@ -18,6 +18,7 @@ func TestNewBatchImportImageService(t *testing.T) {
 		tester.dbClient.Client,
 		tester.getImageConverter(),
 		tester.getTagReader(),
+		nil,
 	)
 
 	service := NewBatchImportImageService(
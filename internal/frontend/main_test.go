@@ -9,7 +9,9 @@ import (
 	"github.com/michael-freling/anime-image-viewer/internal/animemetadata"
 	"github.com/michael-freling/anime-image-viewer/internal/config"
 	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/history"
 	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/michael-freling/anime-image-viewer/internal/plugin"
 	"github.com/michael-freling/anime-image-viewer/internal/search"
 	"github.com/michael-freling/anime-image-viewer/internal/tag"
 )
@@ -40,6 +42,7 @@ func newTester(t *testing.T, opts ...newTesterOption) tester {
 	dbClient := db.NewTestClient(t)
 	cfg := config.Config{
 		ImageRootDirectory: t.TempDir(),
+		ConfigDirectory:    t.TempDir(),
 	}
 
 	return tester{
@@ -83,6 +86,16 @@ func (tester tester) getFileReader() *image.Reader {
 	)
 }
 
+func (tester tester) getPluginRegistry() *plugin.Registry {
+	return plugin.NewRegistry(
+		tester.logger,
+		tester.dbClient.Client,
+		tester.getFileReader(),
+		plugin.AnnotationDirectory(tester.config),
+		nil,
+	)
+}
+
 func (tester tester) getDirectoryReader() *image.DirectoryReader {
 	return image.NewDirectoryReader(tester.config, tester.dbClient.Client)
 }
@@ -107,11 +120,18 @@ func (tester tester) getTagService() *TagService {
 }
 
 func (tester tester) getAnimeCoreService() *anime.Service {
-	return anime.NewService(tester.dbClient.Client, tester.getDirectoryReader(), tester.config, nil)
+	return anime.NewService(tester.dbClient.Client, tester.getDirectoryReader(), tester.config, nil, nil)
 }
 
 func (tester tester) getAnimeCoreServiceWithMetadata(client animemetadata.Client) *anime.Service {
-	return anime.NewService(tester.dbClient.Client, tester.getDirectoryReader(), tester.config, client)
+	return anime.NewService(tester.dbClient.Client, tester.getDirectoryReader(), tester.config, client, nil)
+}
+
+func (tester tester) getJournal() *history.Journal {
+	return history.NewJournal(tester.dbClient.Client, config.HistoryConfig{
+		UserName:  "tester",
+		UndoLimit: 10,
+	})
 }
 
 func (tester tester) getAnimeService() *AnimeService {
@@ -121,6 +141,7 @@ func (tester tester) getAnimeService() *AnimeService {
 		tester.getDirectoryReader(),
 		tester.getTagReader(),
 		tester.getFileReader(),
+		tester.getJournal(),
 	)
 }
 
@@ -131,6 +152,7 @@ func (tester tester) getAnimeServiceWithMetadata(client animemetadata.Client) *A
 		tester.getDirectoryReader(),
 		tester.getTagReader(),
 		tester.getFileReader(),
+		tester.getJournal(),
 	)
 }
 
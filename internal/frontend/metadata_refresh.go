@@ -0,0 +1,114 @@
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/anime"
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+// defaultRefreshHistoryLimit caps how many runs ReadRefreshHistory returns
+// when the caller does not specify a limit.
+const defaultRefreshHistoryLimit = 20
+
+// MetadataRefreshChange is what one refresh run changed on one anime.
+type MetadataRefreshChange struct {
+	AnimeID   uint                 `json:"animeId"`
+	AnimeName string               `json:"animeName"`
+	Result    MetadataImportResult `json:"result"`
+	Error     string               `json:"error"`
+}
+
+// MetadataRefreshRun is one pass of the background metadata refresh.
+type MetadataRefreshRun struct {
+	ID          uint                    `json:"id"`
+	StartedAt   string                  `json:"startedAt"`
+	FinishedAt  string                  `json:"finishedAt"`
+	AnimeCount  int                     `json:"animeCount"`
+	FailedCount int                     `json:"failedCount"`
+	Changes     []MetadataRefreshChange `json:"changes"`
+}
+
+// MetadataRefreshService exposes the background metadata refresh to the
+// frontend: its change log, and a way to run it now.
+type MetadataRefreshService struct {
+	refresher *anime.MetadataRefresher
+}
+
+func NewMetadataRefreshService(refresher *anime.MetadataRefresher) *MetadataRefreshService {
+	return &MetadataRefreshService{
+		refresher: refresher,
+	}
+}
+
+// RefreshNow re-imports every linked anime immediately instead of waiting for
+// the next scheduled run.
+func (s *MetadataRefreshService) RefreshNow(ctx context.Context) (MetadataRefreshRun, error) {
+	run, err := s.refresher.Refresh(ctx)
+	if err != nil {
+		return MetadataRefreshRun{}, fmt.Errorf("MetadataRefresher.Refresh: %w", err)
+	}
+	return convertRefreshRun(run), nil
+}
+
+// ReadRefreshHistory returns the latest refresh runs, newest first.
+func (s *MetadataRefreshService) ReadRefreshHistory(ctx context.Context, limit int) ([]MetadataRefreshRun, error) {
+	if limit <= 0 {
+		limit = defaultRefreshHistoryLimit
+	}
+	runs, err := s.refresher.ReadRuns(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("MetadataRefresher.ReadRuns: %w", err)
+	}
+	result := make([]MetadataRefreshRun, len(runs))
+	for i, run := range runs {
+		result[i] = convertRefreshRun(run)
+	}
+	return result, nil
+}
+
+func convertRefreshRun(run anime.RefreshRun) MetadataRefreshRun {
+	result := MetadataRefreshRun{
+		ID:          run.ID,
+		StartedAt:   run.StartedAt.Format(time.RFC3339),
+		AnimeCount:  run.AnimeCount,
+		FailedCount: run.FailedCount,
+		Changes:     make([]MetadataRefreshChange, len(run.Changes)),
+	}
+	if !run.FinishedAt.IsZero() {
+		result.FinishedAt = run.FinishedAt.Format(time.RFC3339)
+	}
+	for i, change := range run.Changes {
+		result.Changes[i] = MetadataRefreshChange{
+			AnimeID:   change.AnimeID,
+			AnimeName: change.AnimeName,
+			Result: MetadataImportResult{
+				SeasonsCreated:    change.SeasonsCreated,
+				SeasonsUpdated:    change.SeasonsUpdated,
+				CharactersCreated: change.CharactersCreated,
+				CharactersUpdated: change.CharactersUpdated,
+				NewSeasons:        change.NewSeasons,
+			},
+			Error: change.Error,
+		}
+	}
+	return result
+}
+
+// MetadataRefreshNotifier forwards seasons created by a background refresh to
+// the frontend as a MetadataRefresh:newSeasons event.
+type MetadataRefreshNotifier struct{}
+
+func NewMetadataRefreshNotifier() MetadataRefreshNotifier {
+	return MetadataRefreshNotifier{}
+}
+
+func (MetadataRefreshNotifier) NotifyNewSeasons(ctx context.Context, event anime.NewSeasonsEvent) {
+	app := application.Get()
+	if app == nil {
+		return
+	}
+	app.EmitEvent("MetadataRefresh:newSeasons", event)
+}
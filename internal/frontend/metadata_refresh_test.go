@@ -0,0 +1,56 @@
+package frontend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/michael-freling/anime-image-viewer/internal/anime"
+	"github.com/michael-freling/anime-image-viewer/internal/animemetadata"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataRefreshService(t *testing.T) {
+	tester := newTester(t)
+	tester.dbClient.Truncate(t, db.File{}, db.Anime{}, db.Character{}, db.MetadataRefreshRun{}, db.MetadataRefreshChange{})
+	ctx := context.Background()
+
+	mock := &mockMetadataClient{
+		series: map[string]*animemetadata.Series{
+			"bocchi": {
+				ID:      "bocchi",
+				Seasons: []animemetadata.Season{{ID: "bocchi-s1", Number: 1}},
+			},
+		},
+	}
+	core := tester.getAnimeCoreServiceWithMetadata(mock)
+	a, err := core.Create(ctx, "Bocchi")
+	require.NoError(t, err)
+	require.NoError(t, core.LinkMetadataSeries(ctx, a.ID, "bocchi", 0))
+
+	service := NewMetadataRefreshService(
+		anime.NewMetadataRefresher(tester.logger, core, tester.dbClient.Client, tester.config, nil),
+	)
+
+	run, err := service.RefreshNow(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, run.AnimeCount)
+	assert.NotEmpty(t, run.StartedAt)
+	assert.NotEmpty(t, run.FinishedAt)
+	assert.Equal(t, []MetadataRefreshChange{
+		{
+			AnimeID:   a.ID,
+			AnimeName: "Bocchi",
+			Result: MetadataImportResult{
+				SeasonsCreated: 1,
+				NewSeasons:     []string{"Season 1"},
+			},
+		},
+	}, run.Changes)
+
+	history, err := service.ReadRefreshHistory(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, run, history[0])
+}
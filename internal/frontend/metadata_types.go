@@ -17,4 +17,17 @@ type MetadataImportResult struct {
 	SeasonsUpdated    int `json:"seasonsUpdated"`
 	CharactersCreated int `json:"charactersCreated"`
 	CharactersUpdated int `json:"charactersUpdated"`
+	// NewSeasons names the folders the import created.
+	NewSeasons []string `json:"newSeasons"`
+}
+
+// TitleMatch is an anime, season or character whose imported title matches a
+// title search. EntityType is "anime", "file" for a season folder, or
+// "character"; AnimeID is the anime it belongs to either way.
+type TitleMatch struct {
+	EntityType string `json:"entityType"`
+	EntityID   uint   `json:"entityId"`
+	AnimeID    uint   `json:"animeId"`
+	Language   string `json:"language"`
+	Title      string `json:"title"`
 }
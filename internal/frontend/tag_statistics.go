@@ -0,0 +1,77 @@
+package frontend
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/michael-freling/anime-image-viewer/internal/statistics"
+)
+
+// defaultRelatedTagLimit caps how many tags ReadRelatedTags returns when the
+// caller does not specify a limit.
+const defaultRelatedTagLimit = 10
+
+// TagRankingRequest selects the pairs of tags or of a tag and a character to
+// rank by how strongly they are associated.
+type TagRankingRequest struct {
+	// Metric is "lift", "pmi", "count" or "confidence". It defaults to "lift".
+	Metric   string `json:"metric"`
+	MinCount uint   `json:"minCount"`
+	Limit    int    `json:"limit"`
+	// TagID ranks only the pairs with the tag if it's not 0.
+	TagID uint `json:"tagId"`
+}
+
+func (request TagRankingRequest) options() statistics.RankingOptions {
+	return statistics.RankingOptions{
+		Metric:   statistics.Metric(request.Metric),
+		MinCount: request.MinCount,
+		Limit:    request.Limit,
+		TagID:    request.TagID,
+	}
+}
+
+// TagStatisticsService exposes the co-occurrence of tags and characters to
+// the frontend, to suggest the tags frequently used together while editing
+// the tags of images.
+type TagStatisticsService struct {
+	statisticsService *statistics.Service
+}
+
+func NewTagStatisticsService(statisticsService *statistics.Service) *TagStatisticsService {
+	return &TagStatisticsService{
+		statisticsService: statisticsService,
+	}
+}
+
+// ReadRelatedTags returns the tags frequently used together with the tags and
+// characters of the selected images, the most confident first.
+func (s *TagStatisticsService) ReadRelatedTags(ctx context.Context, fileIDs []uint, limit int) ([]statistics.RelatedTag, error) {
+	if limit <= 0 {
+		limit = defaultRelatedTagLimit
+	}
+	relatedTags, err := s.statisticsService.ReadRelatedTags(ctx, fileIDs, statistics.RankingOptions{Limit: limit})
+	if err != nil {
+		return nil, fmt.Errorf("statisticsService.ReadRelatedTags: %w", err)
+	}
+	return relatedTags, nil
+}
+
+// RankTagPairs returns the pairs of tags on the same images by the metric.
+func (s *TagStatisticsService) RankTagPairs(ctx context.Context, request TagRankingRequest) ([]statistics.TagPair, error) {
+	pairs, err := s.statisticsService.RankTagPairs(ctx, request.options())
+	if err != nil {
+		return nil, fmt.Errorf("statisticsService.RankTagPairs: %w", err)
+	}
+	return pairs, nil
+}
+
+// RankTagCharacterPairs returns the pairs of a tag and a character on the
+// same images by the metric.
+func (s *TagStatisticsService) RankTagCharacterPairs(ctx context.Context, request TagRankingRequest) ([]statistics.TagCharacterPair, error) {
+	pairs, err := s.statisticsService.RankTagCharacterPairs(ctx, request.options())
+	if err != nil {
+		return nil, fmt.Errorf("statisticsService.RankTagCharacterPairs: %w", err)
+	}
+	return pairs, nil
+}
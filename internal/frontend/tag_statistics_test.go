@@ -0,0 +1,51 @@
+package frontend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/statistics"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagStatisticsService(t *testing.T) {
+	tester := newTester(t)
+	tester.dbClient.Truncate(t, db.File{}, db.Tag{}, db.FileTag{}, db.Character{}, db.FileCharacter{})
+	db.LoadTestData(t, tester.dbClient, []db.File{
+		{ID: 22001, Name: "Frieren", Type: db.FileTypeDirectory},
+		{ID: 22100, Name: "a.jpg", ParentID: 22001, Type: db.FileTypeImage},
+		{ID: 22101, Name: "b.jpg", ParentID: 22001, Type: db.FileTypeImage},
+		{ID: 22102, Name: "c.jpg", ParentID: 22001, Type: db.FileTypeImage},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.Tag{
+		{ID: 22200, Name: "staff"},
+		{ID: 22201, Name: "cloak"},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.FileTag{
+		{FileID: 22100, TagID: 22200},
+		{FileID: 22100, TagID: 22201},
+		{FileID: 22101, TagID: 22200},
+		{FileID: 22101, TagID: 22201},
+		{FileID: 22102, TagID: 22200},
+	})
+	service := NewTagStatisticsService(statistics.NewService(tester.dbClient.Client))
+	ctx := context.Background()
+
+	relatedTags, err := service.ReadRelatedTags(ctx, []uint{22102}, 0)
+	require.NoError(t, err)
+	require.Len(t, relatedTags, 1)
+	assert.Equal(t, "cloak", relatedTags[0].TagName)
+	assert.Equal(t, uint(22200), relatedTags[0].SourceTagID)
+	assert.InDelta(t, 2.0/3, relatedTags[0].Confidence, 1e-9)
+
+	pairs, err := service.RankTagPairs(ctx, TagRankingRequest{Metric: "count"})
+	require.NoError(t, err)
+	require.Len(t, pairs, 1)
+	assert.Equal(t, uint(2), pairs[0].Count)
+
+	_, err = service.RankTagCharacterPairs(ctx, TagRankingRequest{Metric: "unknown"})
+	assert.ErrorIs(t, err, xerrors.ErrInvalidArgument)
+}
@@ -0,0 +1,253 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+)
+
+// FileMove is a file moved from one folder to another.
+type FileMove struct {
+	FileID       uint `json:"fileId"`
+	FromParentID uint `json:"fromParentId"`
+	ToParentID   uint `json:"toParentId"`
+}
+
+// Change is the set of rows an operation created, deleted or moved. Rows are
+// recorded in full so that a deleted tag or file link comes back exactly as
+// it was, with its ID and AddedBy.
+type Change struct {
+	CreatedTags           []db.Tag           `json:"createdTags,omitempty"`
+	DeletedTags           []db.Tag           `json:"deletedTags,omitempty"`
+	AddedFileTags         []db.FileTag       `json:"addedFileTags,omitempty"`
+	DeletedFileTags       []db.FileTag       `json:"deletedFileTags,omitempty"`
+	AddedFileCharacters   []db.FileCharacter `json:"addedFileCharacters,omitempty"`
+	DeletedFileCharacters []db.FileCharacter `json:"deletedFileCharacters,omitempty"`
+	MovedFiles            []FileMove         `json:"movedFiles,omitempty"`
+}
+
+// IsEmpty reports whether the change did nothing, in which case it is not
+// worth recording.
+func (change Change) IsEmpty() bool {
+	return len(change.CreatedTags) == 0 &&
+		len(change.DeletedTags) == 0 &&
+		len(change.AddedFileTags) == 0 &&
+		len(change.DeletedFileTags) == 0 &&
+		len(change.AddedFileCharacters) == 0 &&
+		len(change.DeletedFileCharacters) == 0 &&
+		len(change.MovedFiles) == 0
+}
+
+// Inverse returns the change that reverts this one.
+func (change Change) Inverse() Change {
+	moves := make([]FileMove, len(change.MovedFiles))
+	for i, move := range change.MovedFiles {
+		moves[i] = FileMove{
+			FileID:       move.FileID,
+			FromParentID: move.ToParentID,
+			ToParentID:   move.FromParentID,
+		}
+	}
+	return Change{
+		CreatedTags:           change.DeletedTags,
+		DeletedTags:           change.CreatedTags,
+		AddedFileTags:         change.DeletedFileTags,
+		DeletedFileTags:       change.AddedFileTags,
+		AddedFileCharacters:   change.DeletedFileCharacters,
+		DeletedFileCharacters: change.AddedFileCharacters,
+		MovedFiles:            moves,
+	}
+}
+
+// prune drops the links and moves of files, tags and characters deleted
+// since the change was recorded, as there is nothing left to revert for them.
+// It returns ErrInvalidArgument if a tag cannot be restored with its ID, or a
+// file cannot be moved back because its folder was deleted or has another
+// file with the same name now.
+func (change Change) prune(ctx context.Context, dbClient *db.Client) (Change, error) {
+	tagIDs := make([]uint, 0)
+	for _, tag := range change.CreatedTags {
+		tagIDs = append(tagIDs, tag.ID)
+	}
+	existingTags, err := dbClient.Tag().FindAllByTagIDs(tagIDs)
+	if err != nil {
+		return Change{}, fmt.Errorf("Tag.FindAllByTagIDs: %w", err)
+	}
+	if len(existingTags) > 0 {
+		return Change{}, fmt.Errorf("%w: tag %d was created again", xerrors.ErrInvalidArgument, existingTags[0].ID)
+	}
+
+	fileIDs := make([]uint, 0)
+	tagIDs = tagIDs[:0]
+	characterIDs := make([]uint, 0)
+	for _, fileTag := range change.AddedFileTags {
+		fileIDs = append(fileIDs, fileTag.FileID)
+		tagIDs = append(tagIDs, fileTag.TagID)
+	}
+	for _, fileCharacter := range change.AddedFileCharacters {
+		fileIDs = append(fileIDs, fileCharacter.FileID)
+		characterIDs = append(characterIDs, fileCharacter.CharacterID)
+	}
+	for _, move := range change.MovedFiles {
+		fileIDs = append(fileIDs, move.FileID)
+	}
+	files, err := dbClient.File().FindImageFilesByIDs(fileIDs)
+	if err != nil {
+		return Change{}, fmt.Errorf("File.FindImageFilesByIDs: %w", err)
+	}
+	tags, err := dbClient.Tag().FindAllByTagIDs(tagIDs)
+	if err != nil {
+		return Change{}, fmt.Errorf("Tag.FindAllByTagIDs: %w", err)
+	}
+	characters, err := dbClient.Character().FindByIDs(characterIDs)
+	if err != nil {
+		return Change{}, fmt.Errorf("Character.FindByIDs: %w", err)
+	}
+
+	existingFiles := make(map[uint]db.File, len(files))
+	for _, file := range files {
+		existingFiles[file.ID] = file
+	}
+	existingTagIDs := make(map[uint]bool, len(tags)+len(change.CreatedTags))
+	for _, tag := range tags {
+		existingTagIDs[tag.ID] = true
+	}
+	for _, tag := range change.CreatedTags {
+		existingTagIDs[tag.ID] = true
+	}
+	existingCharacterIDs := make(map[uint]bool, len(characters))
+	for _, character := range characters {
+		existingCharacterIDs[character.ID] = true
+	}
+
+	result := change
+	result.AddedFileTags = nil
+	for _, fileTag := range change.AddedFileTags {
+		if _, ok := existingFiles[fileTag.FileID]; ok && existingTagIDs[fileTag.TagID] {
+			result.AddedFileTags = append(result.AddedFileTags, fileTag)
+		}
+	}
+	result.AddedFileCharacters = nil
+	for _, fileCharacter := range change.AddedFileCharacters {
+		if _, ok := existingFiles[fileCharacter.FileID]; ok && existingCharacterIDs[fileCharacter.CharacterID] {
+			result.AddedFileCharacters = append(result.AddedFileCharacters, fileCharacter)
+		}
+	}
+	result.MovedFiles, err = pruneMoves(dbClient, change.MovedFiles, existingFiles)
+	if err != nil {
+		return Change{}, err
+	}
+	return result, nil
+}
+
+// pruneMoves drops the moves of deleted files, and checks that the others
+// can be moved into their folder without a name conflict.
+func pruneMoves(dbClient *db.Client, moves []FileMove, existingFiles map[uint]db.File) ([]FileMove, error) {
+	var result []FileMove
+	movedFileIDs := make(map[uint]bool, len(moves))
+	parentIDs := make([]uint, 0)
+	for _, move := range moves {
+		if _, ok := existingFiles[move.FileID]; !ok {
+			continue
+		}
+		result = append(result, move)
+		movedFileIDs[move.FileID] = true
+		if move.ToParentID != 0 {
+			parentIDs = append(parentIDs, move.ToParentID)
+		}
+	}
+	if len(result) == 0 {
+		return result, nil
+	}
+
+	directories, err := dbClient.File().FindDirectoriesByIDs(parentIDs)
+	if err != nil {
+		return nil, fmt.Errorf("File.FindDirectoriesByIDs: %w", err)
+	}
+	existingDirectoryIDs := make(map[uint]bool, len(directories))
+	for _, directory := range directories {
+		existingDirectoryIDs[directory.ID] = true
+	}
+	children, err := dbClient.File().FindFilesByParentIDs(parentIDs)
+	if err != nil {
+		return nil, fmt.Errorf("File.FindFilesByParentIDs: %w", err)
+	}
+	// names in each folder once the files are moved
+	names := make(map[uint]map[string]bool)
+	addName := func(parentID uint, name string) bool {
+		if names[parentID] == nil {
+			names[parentID] = make(map[string]bool)
+		}
+		if names[parentID][name] {
+			return false
+		}
+		names[parentID][name] = true
+		return true
+	}
+	for _, child := range children {
+		if movedFileIDs[child.ID] {
+			continue
+		}
+		addName(child.ParentID, child.Name)
+	}
+	for _, move := range result {
+		if move.ToParentID != 0 && !existingDirectoryIDs[move.ToParentID] {
+			return nil, fmt.Errorf("%w: folder %d was deleted", xerrors.ErrInvalidArgument, move.ToParentID)
+		}
+		name := existingFiles[move.FileID].Name
+		if !addName(move.ToParentID, name) {
+			return nil, fmt.Errorf("%w: file %q already exists in folder %d", xerrors.ErrInvalidArgument, name, move.ToParentID)
+		}
+	}
+	return result, nil
+}
+
+// apply makes the change in the database. Tags are created before the links
+// that point at them and deleted after, so that an inverse change can apply
+// in the same order. Links are created ignoring those that exist already, as
+// the user may have re-added one by hand since.
+func (change Change) apply(ctx context.Context, dbClient *db.Client) error {
+	for _, tag := range change.CreatedTags {
+		if err := dbClient.Tag().Create(ctx, &tag); err != nil {
+			return fmt.Errorf("Tag.Create: %w", err)
+		}
+	}
+	if err := dbClient.FileTag().DeleteFileTags(ctx, change.DeletedFileTags); err != nil {
+		return fmt.Errorf("FileTag.DeleteFileTags: %w", err)
+	}
+	if len(change.DeletedFileCharacters) > 0 {
+		if err := dbClient.FileCharacter().BatchDelete(ctx, change.DeletedFileCharacters); err != nil {
+			return fmt.Errorf("FileCharacter.BatchDelete: %w", err)
+		}
+	}
+	if err := dbClient.FileTag().BatchCreateIgnoringExisting(ctx, change.AddedFileTags); err != nil {
+		return fmt.Errorf("FileTag.BatchCreateIgnoringExisting: %w", err)
+	}
+	if err := dbClient.FileCharacter().BatchCreateIgnoringExisting(ctx, change.AddedFileCharacters); err != nil {
+		return fmt.Errorf("FileCharacter.BatchCreateIgnoringExisting: %w", err)
+	}
+	if len(change.DeletedTags) > 0 {
+		if err := dbClient.Tag().BatchDelete(ctx, change.DeletedTags); err != nil {
+			return fmt.Errorf("Tag.BatchDelete: %w", err)
+		}
+	}
+
+	fileIDsByParentID := make(map[uint][]uint)
+	for _, move := range change.MovedFiles {
+		fileIDsByParentID[move.ToParentID] = append(fileIDsByParentID[move.ToParentID], move.FileID)
+	}
+	parentIDs := make([]uint, 0, len(fileIDsByParentID))
+	for parentID := range fileIDsByParentID {
+		parentIDs = append(parentIDs, parentID)
+	}
+	sort.Slice(parentIDs, func(i, j int) bool { return parentIDs[i] < parentIDs[j] })
+	for _, parentID := range parentIDs {
+		if err := dbClient.File().MoveFiles(ctx, fileIDsByParentID[parentID], parentID); err != nil {
+			return fmt.Errorf("File.MoveFiles: %w", err)
+		}
+	}
+	return nil
+}
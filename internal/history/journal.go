@@ -0,0 +1,251 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/user"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+)
+
+// Kind names what kind of user action an operation was.
+type Kind string
+
+const (
+	KindBatchUpdateTags       Kind = "batch_update_tags"
+	KindBatchUpdateCharacters Kind = "batch_update_characters"
+	KindMergeTags             Kind = "merge_tags"
+	KindDeleteTag             Kind = "delete_tag"
+	KindMoveFiles             Kind = "move_files"
+	// KindAddSuggestedTags and KindAddSuggestedCharacters are suggestions
+	// a user selected, KindAutoTagImages is tags added by the auto-tagging
+	// job, and KindResolveSuggestionReviews is those a user accepted from its
+	// queue.
+	KindAddSuggestedTags         Kind = "add_suggested_tags"
+	KindAddSuggestedCharacters   Kind = "add_suggested_characters"
+	KindAutoTagImages            Kind = "auto_tag_images"
+	KindResolveSuggestionReviews Kind = "resolve_suggestion_reviews"
+)
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+// Operation is an entry of the edit history.
+type Operation struct {
+	ID        uint
+	Kind      Kind
+	Summary   string
+	UserName  string
+	CreatedAt time.Time
+	// Undone is set while the operation is undone, by UndoneBy.
+	Undone   bool
+	UndoneBy string
+	// CanUndo and CanRedo tell whether the operation is still within the
+	// undo window.
+	CanUndo bool
+	CanRedo bool
+}
+
+func newOperation(row db.Operation) Operation {
+	undoable := row.Change != ""
+	return Operation{
+		ID:        row.ID,
+		Kind:      Kind(row.Kind),
+		Summary:   row.Summary,
+		UserName:  row.UserName,
+		CreatedAt: time.Unix(int64(row.CreatedAt), 0),
+		Undone:    row.UndoneAt != 0,
+		UndoneBy:  row.UndoneBy,
+		CanUndo:   undoable && row.UndoneAt == 0,
+		CanRedo:   undoable && row.UndoneAt != 0,
+	}
+}
+
+// Journal records edits as they are made, and reverts or re-applies the
+// latest ones. Callers record an operation inside the transaction that makes
+// the edit, so that the history never disagrees with the data.
+type Journal struct {
+	dbClient  *db.Client
+	userName  string
+	undoLimit int
+	now       func() time.Time
+	listeners []func(context.Context, Change)
+}
+
+func NewJournal(dbClient *db.Client, conf config.HistoryConfig) *Journal {
+	userName := conf.UserName
+	if userName == "" {
+		if current, err := user.Current(); err == nil {
+			userName = current.Username
+		}
+	}
+	return &Journal{
+		dbClient:  dbClient,
+		userName:  userName,
+		undoLimit: conf.UndoLimit,
+		now:       time.Now,
+	}
+}
+
+// OnChange registers a listener that is called with each change that is
+// recorded, undone or redone, after its transaction is committed. Undoing
+// calls it with the inverse of the change. Listeners are registered on
+// startup, before any edit.
+func (journal *Journal) OnChange(listener func(ctx context.Context, change Change)) {
+	journal.listeners = append(journal.listeners, listener)
+}
+
+func (journal *Journal) notify(ctx context.Context, change Change) {
+	if len(journal.listeners) == 0 {
+		return
+	}
+	db.AfterCommit(ctx, func(ctx context.Context) {
+		for _, listener := range journal.listeners {
+			listener(ctx, change)
+		}
+	})
+}
+
+// Record adds an operation to the history. It discards the operations that
+// were undone, since they can no longer be redone on top of the new edit, and
+// those that fell out of the undo window. An empty change is not recorded,
+// and a nil Journal records nothing.
+func (journal *Journal) Record(ctx context.Context, kind Kind, summary string, change Change) error {
+	if journal == nil || change.IsEmpty() {
+		return nil
+	}
+	encoded, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	operationClient := journal.dbClient.Operation()
+	if err := operationClient.ClearRedoable(ctx); err != nil {
+		return fmt.Errorf("Operation.ClearRedoable: %w", err)
+	}
+	if err := operationClient.Create(ctx, &db.Operation{
+		Kind:     string(kind),
+		Summary:  summary,
+		UserName: journal.userName,
+		Change:   string(encoded),
+	}); err != nil {
+		return fmt.Errorf("Operation.Create: %w", err)
+	}
+	if journal.undoLimit > 0 {
+		if err := operationClient.ClearUndoableBeyond(ctx, journal.undoLimit); err != nil {
+			return fmt.Errorf("Operation.ClearUndoableBeyond: %w", err)
+		}
+	}
+	journal.notify(ctx, change)
+	return nil
+}
+
+// Undo reverts the latest operation that has not been undone yet. Links of
+// files, tags and characters deleted since are skipped, and an operation that
+// cannot be reverted, such as a move back to a name taken since, fails with
+// ErrInvalidArgument and stays undoable.
+func (journal *Journal) Undo(ctx context.Context) (Operation, error) {
+	var result db.Operation
+	err := db.NewTransaction(ctx, journal.dbClient, func(ctx context.Context) error {
+		operationClient := journal.dbClient.Operation()
+		row, err := operationClient.FindLastUndoable(ctx)
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return ErrNothingToUndo
+		}
+		if err != nil {
+			return fmt.Errorf("Operation.FindLastUndoable: %w", err)
+		}
+
+		change, err := decodeChange(row)
+		if err != nil {
+			return err
+		}
+		inverse, err := change.Inverse().prune(ctx, journal.dbClient)
+		if err != nil {
+			return err
+		}
+		if err := inverse.apply(ctx, journal.dbClient); err != nil {
+			return err
+		}
+		journal.notify(ctx, inverse)
+
+		row.UndoneAt = uint(journal.now().Unix())
+		row.UndoneBy = journal.userName
+		if err := operationClient.Update(ctx, &row); err != nil {
+			return fmt.Errorf("Operation.Update: %w", err)
+		}
+		result = row
+		return nil
+	})
+	if err != nil {
+		return Operation{}, err
+	}
+	return newOperation(result), nil
+}
+
+// Redo re-applies the operation undone first among those still undone.
+func (journal *Journal) Redo(ctx context.Context) (Operation, error) {
+	var result db.Operation
+	err := db.NewTransaction(ctx, journal.dbClient, func(ctx context.Context) error {
+		operationClient := journal.dbClient.Operation()
+		row, err := operationClient.FindFirstRedoable(ctx)
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return ErrNothingToRedo
+		}
+		if err != nil {
+			return fmt.Errorf("Operation.FindFirstRedoable: %w", err)
+		}
+
+		change, err := decodeChange(row)
+		if err != nil {
+			return err
+		}
+		change, err = change.prune(ctx, journal.dbClient)
+		if err != nil {
+			return err
+		}
+		if err := change.apply(ctx, journal.dbClient); err != nil {
+			return err
+		}
+		journal.notify(ctx, change)
+
+		row.UndoneAt = 0
+		row.UndoneBy = ""
+		if err := operationClient.Update(ctx, &row); err != nil {
+			return fmt.Errorf("Operation.Update: %w", err)
+		}
+		result = row
+		return nil
+	})
+	if err != nil {
+		return Operation{}, err
+	}
+	return newOperation(result), nil
+}
+
+// List returns a page of the history, newest first.
+func (journal *Journal) List(ctx context.Context, limit int, offset int) ([]Operation, error) {
+	rows, err := journal.dbClient.Operation().FindLatest(ctx, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("Operation.FindLatest: %w", err)
+	}
+	result := make([]Operation, len(rows))
+	for i, row := range rows {
+		result[i] = newOperation(row)
+	}
+	return result, nil
+}
+
+func decodeChange(row db.Operation) (Change, error) {
+	var change Change
+	if err := json.Unmarshal([]byte(row.Change), &change); err != nil {
+		return Change{}, fmt.Errorf("json.Unmarshal operation %d: %w", row.ID, err)
+	}
+	return change, nil
+}
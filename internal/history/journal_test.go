@@ -0,0 +1,260 @@
+package history
+
+import (
+	"context"
+	"testing"
+
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestJournal(t *testing.T, undoLimit int) (*Journal, db.TestClient) {
+	t.Helper()
+	dbClient := db.NewTestClient(t)
+	dbClient.Truncate(t, db.Tag{}, db.FileTag{}, db.Character{}, db.FileCharacter{}, db.File{}, db.Operation{})
+	return NewJournal(dbClient.Client, config.HistoryConfig{
+		UserName:  "tester",
+		UndoLimit: undoLimit,
+	}), dbClient
+}
+
+// record makes the change and records it in one transaction, as callers do.
+func record(t *testing.T, journal *Journal, change Change) {
+	t.Helper()
+	ctx := context.Background()
+	require.NoError(t, db.NewTransaction(ctx, journal.dbClient, func(ctx context.Context) error {
+		if err := change.apply(ctx, journal.dbClient); err != nil {
+			return err
+		}
+		return journal.Record(ctx, KindMergeTags, "merge", change)
+	}))
+}
+
+func TestJournal_UndoRedo(t *testing.T) {
+	ctx := context.Background()
+	journal, dbClient := newTestJournal(t, 10)
+
+	db.LoadTestData(t, dbClient, []db.File{
+		{ID: 1, Name: "season 1", Type: db.FileTypeDirectory},
+		{ID: 2, Name: "season 2", Type: db.FileTypeDirectory},
+		{ID: 10, Name: "a.jpg", ParentID: 1, Type: db.FileTypeImage},
+	})
+	db.LoadTestData(t, dbClient, []db.Tag{
+		{ID: 1, Name: "source"},
+		{ID: 2, Name: "target"},
+	})
+	db.LoadTestData(t, dbClient, []db.FileTag{
+		{TagID: 1, FileID: 10, AddedBy: db.FileTagAddedBySuggestion},
+	})
+
+	record(t, journal, Change{
+		DeletedTags:     []db.Tag{{ID: 1, Name: "source"}},
+		AddedFileTags:   []db.FileTag{{TagID: 2, FileID: 10, AddedBy: db.FileTagAddedBySuggestion}},
+		DeletedFileTags: []db.FileTag{{TagID: 1, FileID: 10, AddedBy: db.FileTagAddedBySuggestion}},
+		MovedFiles:      []FileMove{{FileID: 10, FromParentID: 1, ToParentID: 2}},
+	})
+	fileTagIDs := func() []uint {
+		ids := make([]uint, 0)
+		for _, fileTag := range db.MustGetAll[db.FileTag](t, dbClient) {
+			ids = append(ids, fileTag.TagID)
+		}
+		return ids
+	}
+	parentID := func() uint {
+		file, err := dbClient.File().FindByValue(ctx, &db.File{ID: 10})
+		require.NoError(t, err)
+		return file.ParentID
+	}
+	require.Equal(t, []uint{2}, fileTagIDs())
+	require.Len(t, db.MustGetAll[db.Tag](t, dbClient), 1)
+	require.Equal(t, uint(2), parentID())
+
+	undone, err := journal.Undo(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "tester", undone.UndoneBy)
+	assert.True(t, undone.CanRedo)
+	tags := db.MustGetAll[db.Tag](t, dbClient)
+	require.Len(t, tags, 2, "the merged tag comes back")
+	assert.Equal(t, "source", tags[0].Name)
+	fileTags := db.MustGetAll[db.FileTag](t, dbClient)
+	require.Len(t, fileTags, 1)
+	assert.Equal(t, db.FileTag{TagID: 1, FileID: 10, AddedBy: db.FileTagAddedBySuggestion}, db.FileTag{
+		TagID: fileTags[0].TagID, FileID: fileTags[0].FileID, AddedBy: fileTags[0].AddedBy,
+	})
+	assert.Equal(t, uint(1), parentID())
+
+	_, err = journal.Undo(ctx)
+	assert.ErrorIs(t, err, ErrNothingToUndo)
+
+	redone, err := journal.Redo(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, undone.ID, redone.ID)
+	assert.False(t, redone.Undone)
+	assert.Equal(t, []uint{2}, fileTagIDs())
+	assert.Equal(t, uint(2), parentID())
+
+	_, err = journal.Redo(ctx)
+	assert.ErrorIs(t, err, ErrNothingToRedo)
+}
+
+func TestJournal_Record(t *testing.T) {
+	ctx := context.Background()
+	change := func(fileID uint) Change {
+		return Change{AddedFileCharacters: []db.FileCharacter{{CharacterID: 1, FileID: fileID}}}
+	}
+
+	t.Run("a new edit discards the redo stack", func(t *testing.T) {
+		journal, _ := newTestJournal(t, 10)
+		record(t, journal, change(1))
+		record(t, journal, change(2))
+		_, err := journal.Undo(ctx)
+		require.NoError(t, err)
+
+		record(t, journal, change(3))
+
+		_, err = journal.Redo(ctx)
+		assert.ErrorIs(t, err, ErrNothingToRedo)
+		history, err := journal.List(ctx, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, history, 3, "the undone operation stays in the history")
+		assert.True(t, history[1].Undone)
+		assert.False(t, history[1].CanRedo)
+	})
+
+	t.Run("only the latest operations can be undone", func(t *testing.T) {
+		journal, _ := newTestJournal(t, 2)
+		for fileID := uint(1); fileID <= 3; fileID++ {
+			record(t, journal, change(fileID))
+		}
+
+		history, err := journal.List(ctx, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+		assert.Equal(t, []bool{true, true, false}, []bool{history[0].CanUndo, history[1].CanUndo, history[2].CanUndo})
+		assert.Equal(t, "tester", history[2].UserName)
+
+		for range 2 {
+			_, err := journal.Undo(ctx)
+			require.NoError(t, err)
+		}
+		_, err = journal.Undo(ctx)
+		assert.ErrorIs(t, err, ErrNothingToUndo)
+	})
+
+	t.Run("empty changes and a nil journal record nothing", func(t *testing.T) {
+		journal, dbClient := newTestJournal(t, 10)
+		require.NoError(t, journal.Record(ctx, KindBatchUpdateTags, "", Change{}))
+		var nilJournal *Journal
+		require.NoError(t, nilJournal.Record(ctx, KindBatchUpdateTags, "", change(1)))
+		assert.Empty(t, db.MustGetAll[db.Operation](t, dbClient))
+	})
+}
+
+func TestJournal_OnChange(t *testing.T) {
+	ctx := context.Background()
+	journal, dbClient := newTestJournal(t, 10)
+	db.LoadTestData(t, dbClient, []db.File{
+		{ID: 1, Name: "a.jpg", Type: db.FileTypeImage},
+	})
+	db.LoadTestData(t, dbClient, []db.Character{
+		{ID: 1, Name: "character"},
+	})
+	got := make([]Change, 0)
+	journal.OnChange(func(ctx context.Context, change Change) {
+		got = append(got, change)
+	})
+
+	change := Change{AddedFileCharacters: []db.FileCharacter{{CharacterID: 1, FileID: 1}}}
+	record(t, journal, change)
+	_, err := journal.Undo(ctx)
+	require.NoError(t, err)
+	_, err = journal.Redo(ctx)
+	require.NoError(t, err)
+	require.NoError(t, journal.Record(ctx, KindBatchUpdateTags, "", Change{}))
+
+	// Listeners are not called when a transaction is rolled back.
+	require.Error(t, db.NewTransaction(ctx, journal.dbClient, func(ctx context.Context) error {
+		if err := journal.Record(ctx, KindBatchUpdateTags, "", change); err != nil {
+			return err
+		}
+		return assert.AnError
+	}))
+
+	want := []Change{change, change.Inverse(), change}
+	require.Len(t, got, len(want))
+	for i := range want {
+		assert.Equal(t, want[i].AddedFileCharacters, got[i].AddedFileCharacters)
+		assert.Equal(t, want[i].DeletedFileCharacters, got[i].DeletedFileCharacters)
+	}
+}
+
+func TestJournal_UndoStaleChange(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("links of deleted files and tags are skipped", func(t *testing.T) {
+		journal, dbClient := newTestJournal(t, 10)
+		db.LoadTestData(t, dbClient, []db.File{
+			{ID: 10, Name: "a.jpg", Type: db.FileTypeImage},
+			{ID: 11, Name: "b.jpg", Type: db.FileTypeImage},
+		})
+		db.LoadTestData(t, dbClient, []db.Tag{
+			{ID: 1, Name: "deleted"},
+			{ID: 2, Name: "kept"},
+		})
+		record(t, journal, Change{
+			DeletedFileTags: []db.FileTag{
+				{TagID: 1, FileID: 10},
+				{TagID: 2, FileID: 10},
+				{TagID: 2, FileID: 11},
+			},
+		})
+		require.NoError(t, dbClient.Tag().DeleteByID(ctx, 1))
+		require.NoError(t, dbClient.File().DeleteByIDs(ctx, []uint{11}))
+
+		_, err := journal.Undo(ctx)
+		require.NoError(t, err)
+		fileTags := db.MustGetAll[db.FileTag](t, dbClient)
+		require.Len(t, fileTags, 1)
+		assert.Equal(t, uint(2), fileTags[0].TagID)
+		assert.Equal(t, uint(10), fileTags[0].FileID)
+	})
+
+	t.Run("a move back to a name taken since fails", func(t *testing.T) {
+		journal, dbClient := newTestJournal(t, 10)
+		db.LoadTestData(t, dbClient, []db.File{
+			{ID: 1, Name: "season 1", Type: db.FileTypeDirectory},
+			{ID: 2, Name: "season 2", Type: db.FileTypeDirectory},
+			{ID: 10, Name: "a.jpg", ParentID: 2, Type: db.FileTypeImage},
+			{ID: 11, Name: "a.jpg", ParentID: 1, Type: db.FileTypeImage},
+		})
+		record(t, journal, Change{
+			MovedFiles: []FileMove{{FileID: 10, FromParentID: 1, ToParentID: 2}},
+		})
+
+		_, err := journal.Undo(ctx)
+		assert.ErrorIs(t, err, xerrors.ErrInvalidArgument)
+		file, err := dbClient.File().FindByValue(ctx, &db.File{ID: 10})
+		require.NoError(t, err)
+		assert.Equal(t, uint(2), file.ParentID)
+		history, err := journal.List(ctx, 10, 0)
+		require.NoError(t, err)
+		assert.True(t, history[0].CanUndo, "the operation can be undone once the conflict is resolved")
+	})
+
+	t.Run("a move back to a deleted folder fails", func(t *testing.T) {
+		journal, dbClient := newTestJournal(t, 10)
+		db.LoadTestData(t, dbClient, []db.File{
+			{ID: 2, Name: "season 2", Type: db.FileTypeDirectory},
+			{ID: 10, Name: "a.jpg", ParentID: 2, Type: db.FileTypeImage},
+		})
+		record(t, journal, Change{
+			MovedFiles: []FileMove{{FileID: 10, FromParentID: 1, ToParentID: 2}},
+		})
+
+		_, err := journal.Undo(ctx)
+		assert.ErrorIs(t, err, xerrors.ErrInvalidArgument)
+	})
+}
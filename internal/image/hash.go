@@ -1,11 +1,16 @@
 package image
 
 import (
+	"bufio"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	goimage "image"
 	"io"
+	"math/bits"
 	"os"
+
+	"golang.org/x/image/draw"
 )
 
 // ComputeFileHash computes a SHA256 hash of the file at the given path and
@@ -24,3 +29,46 @@ func ComputeFileHash(path string) (string, error) {
 
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
+
+// ComputePerceptualHash computes a 64-bit difference hash of the image at the
+// given path. Unlike ComputeFileHash it survives re-encoding and resizing:
+// the image is shrunk to 9x8 grayscale pixels and each bit tells whether a
+// pixel is darker than its right neighbour, so near-duplicates differ in only
+// a few bits (see HammingDistance).
+func ComputePerceptualHash(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("open file for hashing: %w", err)
+	}
+	defer f.Close()
+
+	sourceImage, _, err := goimage.Decode(bufio.NewReader(f))
+	if err != nil {
+		return 0, fmt.Errorf("image.Decode: %w", err)
+	}
+	return PerceptualHash(sourceImage), nil
+}
+
+// PerceptualHash computes the same hash as ComputePerceptualHash of an image
+// which is already decoded, e.g. from bytes received over the network.
+func PerceptualHash(sourceImage goimage.Image) uint64 {
+	const width, height = 9, 8
+	small := goimage.NewGray(goimage.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(small, small.Rect, sourceImage, sourceImage.Bounds(), draw.Src, nil)
+
+	var hash uint64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width-1; x++ {
+			hash <<= 1
+			if small.GrayAt(x, y).Y < small.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HammingDistance returns the number of bits two perceptual hashes differ in.
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
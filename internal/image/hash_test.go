@@ -3,6 +3,12 @@ package image
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	goimage "image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"testing"
@@ -73,3 +79,49 @@ func TestComputeFileHash(t *testing.T) {
 		assert.Equal(t, hex.EncodeToString(expected[:]), hash)
 	})
 }
+
+func TestComputePerceptualHash(t *testing.T) {
+	// writeWave writes a wave pattern, with dark and light swapped when inverted.
+	writeWave := func(t *testing.T, name string, width int, inverted bool, encode func(io.Writer, goimage.Image) error) string {
+		t.Helper()
+		height := width / 2
+		img := goimage.NewGray(goimage.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				fx := float64(x) / float64(width)
+				fy := float64(y) / float64(height)
+				value := 128 + 100*math.Sin(fx*3*math.Pi)*math.Cos(fy*2*math.Pi)
+				if inverted {
+					value = 255 - value
+				}
+				img.SetGray(x, y, color.Gray{Y: uint8(value)})
+			}
+		}
+		filePath := filepath.Join(t.TempDir(), name)
+		f, err := os.Create(filePath)
+		require.NoError(t, err)
+		defer f.Close()
+		require.NoError(t, encode(f, img))
+		return filePath
+	}
+	encodeJpeg := func(w io.Writer, img goimage.Image) error {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 70})
+	}
+
+	original, err := ComputePerceptualHash(writeWave(t, "original.png", 64, false, png.Encode))
+	require.NoError(t, err)
+	resized, err := ComputePerceptualHash(writeWave(t, "resized.jpg", 256, false, encodeJpeg))
+	require.NoError(t, err)
+	inverted, err := ComputePerceptualHash(writeWave(t, "inverted.png", 64, true, png.Encode))
+	require.NoError(t, err)
+
+	assert.LessOrEqual(t, HammingDistance(original, resized), 4, "a resized, re-encoded copy")
+	assert.Greater(t, HammingDistance(original, inverted), 32, "a different image")
+
+	_, err = ComputePerceptualHash("/nonexistent/file.png")
+	assert.Error(t, err)
+	textFile := filepath.Join(t.TempDir(), "a.txt")
+	require.NoError(t, os.WriteFile(textFile, []byte("not an image"), 0644))
+	_, err = ComputePerceptualHash(textFile)
+	assert.Error(t, err)
+}
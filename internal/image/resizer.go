@@ -15,6 +15,10 @@ import (
 	"golang.org/x/image/draw"
 )
 
+// ErrUnsupportedImageFormat is returned for an image that cannot be decoded,
+// or whose format has no encoder to write a resized image.
+var ErrUnsupportedImageFormat = errors.New("unsupported image format")
+
 type Resizer struct {
 	logger *slog.Logger
 }
@@ -46,7 +50,7 @@ func (service *Resizer) ResizeImage(
 			service.logger.ErrorContext(ctx, "an uploaded image file was not supported",
 				"local file path", localImageFilePath,
 			)
-			return nil, fmt.Errorf("unsupported image file: %w", err)
+			return nil, fmt.Errorf("%w: %w", ErrUnsupportedImageFormat, err)
 		}
 		return nil, fmt.Errorf("image.Decode: %w", err)
 	}
@@ -81,7 +85,7 @@ func (service *Resizer) ResizeImage(
 	}
 	encodeFunc, ok := encoders[imageFormat]
 	if !ok {
-		return nil, fmt.Errorf("unsupported image format for an encoder: %s", imageFormat)
+		return nil, fmt.Errorf("%w for an encoder: %s", ErrUnsupportedImageFormat, imageFormat)
 	}
 	return encodeFunc(destImage), nil
 }
@@ -0,0 +1,431 @@
+package import_images
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+)
+
+var (
+	ErrUnsupportedArchive   = errors.New("unsupported archive")
+	ErrUnsafeArchiveEntry   = errors.New("archive entry outside of the archive")
+	ErrArchiveTooLarge      = errors.New("archive too large")
+	errArchiveEntryRejected = errors.New("archive entry rejected")
+)
+
+type archiveFormat int
+
+const (
+	archiveFormatUnknown archiveFormat = iota
+	archiveFormatZip
+	archiveFormatTar
+	archiveFormatTarGzip
+)
+
+// archiveLimits bound what an archive can write into the staging directory,
+// so that an archive with a high compression ratio doesn't fill the disk.
+type archiveLimits struct {
+	// maxEntries is the number of the images and sidecars
+	maxEntries int
+	// maxSize is the total uncompressed size of the entries in bytes
+	maxSize int64
+}
+
+var defaultArchiveLimits = archiveLimits{
+	maxEntries: 100000,
+	maxSize:    64 << 30,
+}
+
+// ArchiveExtensions are the extensions of the archives ImportArchive reads.
+// A .cbz comic book archive is a zip archive.
+var ArchiveExtensions = []string{".zip", ".cbz", ".tar", ".tar.gz", ".tgz"}
+
+func getArchiveFormat(archivePath string) archiveFormat {
+	name := strings.ToLower(archivePath)
+	switch {
+	case strings.HasSuffix(name, ".zip"), strings.HasSuffix(name, ".cbz"):
+		return archiveFormatZip
+	case strings.HasSuffix(name, ".tar"):
+		return archiveFormatTar
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return archiveFormatTarGzip
+	}
+	return archiveFormatUnknown
+}
+
+// IsArchiveFile reports whether a file is an archive ImportArchive reads.
+func IsArchiveFile(filePath string) bool {
+	return getArchiveFormat(filePath) != archiveFormatUnknown
+}
+
+// archiveEntry is a regular file in an archive.
+type archiveEntry struct {
+	// name is the slash-separated path in the archive
+	name    string
+	modTime time.Time
+	open    func() (io.ReadCloser, error)
+}
+
+// walkArchive calls walk with each regular file in an archive in order. An
+// entry of a tar archive can be read only until walk returns.
+func walkArchive(archivePath string, walk func(entry archiveEntry) error) error {
+	switch getArchiveFormat(archivePath) {
+	case archiveFormatZip:
+		zipReader, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return fmt.Errorf("zip.OpenReader: %w", err)
+		}
+		defer zipReader.Close()
+
+		for _, file := range zipReader.File {
+			if !file.Mode().IsRegular() {
+				continue
+			}
+			if err := walk(archiveEntry{
+				name:    file.Name,
+				modTime: file.Modified,
+				open:    file.Open,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case archiveFormatTar, archiveFormatTarGzip:
+		file, err := os.Open(archivePath)
+		if err != nil {
+			return fmt.Errorf("os.Open: %w", err)
+		}
+		defer file.Close()
+
+		var reader io.Reader = file
+		if getArchiveFormat(archivePath) == archiveFormatTarGzip {
+			gzipReader, err := gzip.NewReader(file)
+			if err != nil {
+				return fmt.Errorf("gzip.NewReader: %w", err)
+			}
+			defer gzipReader.Close()
+			reader = gzipReader
+		}
+
+		tarReader := tar.NewReader(reader)
+		for {
+			header, err := tarReader.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("tarReader.Next: %w", err)
+			}
+			// links are skipped, as they can point outside of the archive
+			if header.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err := walk(archiveEntry{
+				name:    header.Name,
+				modTime: header.ModTime,
+				open: func() (io.ReadCloser, error) {
+					return io.NopCloser(tarReader), nil
+				},
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupportedArchive, archivePath)
+}
+
+// cleanArchiveEntryName returns the path of an entry relative to the root of
+// an archive. It returns errArchiveEntryRejected for an entry that isn't an
+// image or a sidecar, and ErrUnsafeArchiveEntry for one outside of the root.
+func cleanArchiveEntryName(name string) (string, error) {
+	// some tools on Windows write backslashes into zip archives
+	name = strings.ReplaceAll(name, `\`, "/")
+	if path.IsAbs(name) {
+		return "", fmt.Errorf("%w: %s", ErrUnsafeArchiveEntry, name)
+	}
+	name = path.Clean(name)
+	if name == ".." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("%w: %s", ErrUnsafeArchiveEntry, name)
+	}
+	for _, element := range strings.Split(name, "/") {
+		// metadata of macOS and hidden files aren't images
+		if element == "__MACOSX" || strings.HasPrefix(element, ".") {
+			return "", errArchiveEntryRejected
+		}
+	}
+	return name, nil
+}
+
+func isSidecarFile(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".xmp", ".txt":
+		return true
+	}
+	return false
+}
+
+// ImportArchive imports the images in a zip or tar archive without extracting
+// it manually. A folder in the archive is imported as a sub-directory of the
+// destination directory, which is reused if it already exists, and XMP and
+// caption files next to the images are read as they are for images on disk.
+//
+// The entries are streamed into a temporary directory before they are
+// imported, so a failure of an entry, which is added to the progress notifier
+// with the path of the entry in the archive, doesn't stop the import. An
+// archive with more entries or more uncompressed bytes than the limits fails
+// with ErrArchiveTooLarge before anything is imported. Conflicts are resolved
+// by the options as ImportImages does.
+func (batchImporter *BatchImageImporter) ImportArchive(
+	ctx context.Context,
+	destinationParentDirectory image.Directory,
+	archivePath string,
+	options ImportOptions,
+	progressNotifier *ProgressNotifier,
+) ([]image.ImageFile, error) {
+	if !IsArchiveFile(archivePath) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedArchive, archivePath)
+	}
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	stagingDirectory, err := os.MkdirTemp("", "anime-image-viewer-archive-*")
+	if err != nil {
+		return nil, fmt.Errorf("os.MkdirTemp: %w", err)
+	}
+	defer os.RemoveAll(stagingDirectory)
+
+	imagePaths, err := batchImporter.extractArchive(ctx, archivePath, stagingDirectory, defaultArchiveLimits, progressNotifier)
+	if err != nil {
+		return nil, errors.Join(
+			fmt.Errorf("extractArchive: %w", err),
+			errors.Join(progressNotifier.FailedErrors...),
+		)
+	}
+
+	// import the images of parent directories first
+	relativeDirectories := make([]string, 0, len(imagePaths))
+	for relativeDirectory := range imagePaths {
+		relativeDirectories = append(relativeDirectories, relativeDirectory)
+	}
+	sort.Strings(relativeDirectories)
+
+	directories := map[string]image.Directory{
+		".": destinationParentDirectory,
+	}
+	resultImageFiles := make([]image.ImageFile, 0)
+	for _, relativeDirectory := range relativeDirectories {
+		directory, err := batchImporter.findOrCreateDirectories(ctx, directories, relativeDirectory)
+		if err != nil {
+			for _, imagePath := range imagePaths[relativeDirectory] {
+				progressNotifier.addFailure(
+					filepath.Join(archivePath, relativeDirectory, filepath.Base(imagePath)),
+					fmt.Errorf("findOrCreateDirectories: %w", err),
+				)
+			}
+			continue
+		}
+
+		failedCount := progressNotifier.Failed
+		imageFiles, err := batchImporter.importImages(ctx, directory, imagePaths[relativeDirectory], options, progressNotifier)
+		progressNotifier.replaceFailedPathPrefix(failedCount, stagingDirectory, archivePath)
+		if err != nil {
+			return nil, errors.Join(
+				fmt.Errorf("importImages: %w", err),
+				errors.Join(progressNotifier.FailedErrors...),
+			)
+		}
+		resultImageFiles = append(resultImageFiles, imageFiles...)
+	}
+	if len(progressNotifier.FailedErrors) > 0 {
+		return resultImageFiles, errors.Join(progressNotifier.FailedErrors...)
+	}
+	return resultImageFiles, nil
+}
+
+// extractArchive writes the entries of an archive under the staging
+// directory, and returns the paths of the images by the directory in the
+// archive. It stops with ErrArchiveTooLarge once the entries exceed limits.
+func (batchImporter *BatchImageImporter) extractArchive(
+	ctx context.Context,
+	archivePath string,
+	stagingDirectory string,
+	limits archiveLimits,
+	progressNotifier *ProgressNotifier,
+) (map[string][]string, error) {
+	result := make(map[string][]string)
+	entryCount := 0
+	remainingSize := limits.maxSize
+	err := walkArchive(archivePath, func(entry archiveEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name, err := cleanArchiveEntryName(entry.name)
+		if errors.Is(err, errArchiveEntryRejected) {
+			return nil
+		}
+		isImage := !isSidecarFile(entry.name)
+		if isImage {
+			progressNotifier.Total++
+		}
+		if err != nil {
+			// the error has the name, which cannot be joined to the path
+			progressNotifier.addFailure(archivePath, err)
+			return nil
+		}
+
+		entryCount++
+		if entryCount > limits.maxEntries {
+			return fmt.Errorf("%w: more than %d entries", ErrArchiveTooLarge, limits.maxEntries)
+		}
+		stagedFilePath := filepath.Join(stagingDirectory, filepath.FromSlash(name))
+		written, err := writeArchiveEntry(entry, stagedFilePath, remainingSize)
+		remainingSize -= written
+		if errors.Is(err, ErrArchiveTooLarge) {
+			return fmt.Errorf("%w: more than %d bytes", err, limits.maxSize)
+		}
+		if err != nil {
+			progressNotifier.addFailure(
+				filepath.Join(archivePath, filepath.FromSlash(name)),
+				fmt.Errorf("writeArchiveEntry: %w", err),
+			)
+			return nil
+		}
+		if isImage {
+			relativeDirectory := path.Dir(name)
+			result[relativeDirectory] = append(result[relativeDirectory], stagedFilePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walkArchive: %w", err)
+	}
+	return result, nil
+}
+
+// writeArchiveEntry writes an entry to filePath, and returns the number of
+// bytes written. It returns ErrArchiveTooLarge, and removes the file, if the
+// entry is larger than maxSize.
+func writeArchiveEntry(entry archiveEntry, filePath string, maxSize int64) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return 0, fmt.Errorf("os.MkdirAll: %w", err)
+	}
+	reader, err := entry.open()
+	if err != nil {
+		return 0, fmt.Errorf("entry.open: %w", err)
+	}
+	defer reader.Close()
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("os.Create: %w", err)
+	}
+	// a byte more than the limit is read to tell whether the entry exceeds it
+	written, err := io.Copy(file, io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		file.Close()
+		return written, fmt.Errorf("io.Copy: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return written, fmt.Errorf("file.Close: %w", err)
+	}
+	if written > maxSize {
+		os.Remove(filePath)
+		return written, ErrArchiveTooLarge
+	}
+	// the modification time is the creation time of an image without metadata
+	if !entry.modTime.IsZero() {
+		if err := os.Chtimes(filePath, entry.modTime, entry.modTime); err != nil {
+			return written, fmt.Errorf("os.Chtimes: %w", err)
+		}
+	}
+	return written, nil
+}
+
+// findOrCreateDirectories returns the directory at a slash-separated path
+// relative to the destination, creating the directories that don't exist.
+// directories caches the directories by the relative path.
+func (batchImporter *BatchImageImporter) findOrCreateDirectories(
+	ctx context.Context,
+	directories map[string]image.Directory,
+	relativePath string,
+) (image.Directory, error) {
+	if directory, ok := directories[relativePath]; ok {
+		return directory, nil
+	}
+	parentDirectory, err := batchImporter.findOrCreateDirectories(ctx, directories, path.Dir(relativePath))
+	if err != nil {
+		return image.Directory{}, err
+	}
+
+	name := path.Base(relativePath)
+	directory := image.Directory{
+		Name:     name,
+		ParentID: parentDirectory.ID,
+		Path:     filepath.Join(parentDirectory.Path, name),
+	}
+	err = db.NewTransaction(ctx, batchImporter.dbClient, func(ctx context.Context) error {
+		ormClient := batchImporter.dbClient.File()
+		record, err := ormClient.FindByValue(ctx, &db.File{
+			Name:     name,
+			ParentID: parentDirectory.ID,
+		})
+		if err != nil && !errors.Is(err, db.ErrRecordNotFound) {
+			return fmt.Errorf("ormClient.FindByValue: %w", err)
+		}
+		if record.ID != 0 {
+			if record.Type != db.FileTypeDirectory {
+				return fmt.Errorf("%w: %s", image.ErrFileAlreadyExists, directory.Path)
+			}
+			directory.ID = record.ID
+		} else {
+			newDirectory := db.File{
+				Name:     name,
+				ParentID: parentDirectory.ID,
+				Type:     db.FileTypeDirectory,
+			}
+			if err := ormClient.Create(ctx, &newDirectory); err != nil {
+				return fmt.Errorf("ormClient.Create: %w", err)
+			}
+			directory.ID = newDirectory.ID
+		}
+
+		if err := os.MkdirAll(directory.Path, 0755); err != nil {
+			return fmt.Errorf("os.MkdirAll: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return image.Directory{}, fmt.Errorf("db.NewTransaction: %w", err)
+	}
+	directories[relativePath] = directory
+	return directory, nil
+}
+
+// replaceFailedPathPrefix replaces the prefix of the paths failed after the
+// start, so that a staged file is reported with its path in the archive.
+func (notifier *ProgressNotifier) replaceFailedPathPrefix(start int, oldPrefix string, newPrefix string) {
+	notifier.failureMutex.Lock()
+	defer notifier.failureMutex.Unlock()
+	for i := start; i < len(notifier.FailedPaths); i++ {
+		relativePath, err := filepath.Rel(oldPrefix, notifier.FailedPaths[i])
+		if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+			continue
+		}
+		notifier.FailedPaths[i] = filepath.Join(newPrefix, relativePath)
+	}
+}
@@ -0,0 +1,251 @@
+package import_images
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testArchiveEntry struct {
+	name    string
+	content []byte
+}
+
+func writeTestZip(t *testing.T, filePath string, entries []testArchiveEntry, modTime time.Time) {
+	file, err := os.Create(filePath)
+	require.NoError(t, err)
+	defer file.Close()
+
+	zipWriter := zip.NewWriter(file)
+	for _, entry := range entries {
+		writer, err := zipWriter.CreateHeader(&zip.FileHeader{
+			Name:     entry.name,
+			Method:   zip.Deflate,
+			Modified: modTime,
+		})
+		require.NoError(t, err)
+		_, err = writer.Write(entry.content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zipWriter.Close())
+}
+
+func writeTestTarGzip(t *testing.T, filePath string, entries []testArchiveEntry, modTime time.Time) {
+	file, err := os.Create(filePath)
+	require.NoError(t, err)
+	defer file.Close()
+
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+	require.NoError(t, tarWriter.WriteHeader(&tar.Header{
+		Name:     "pack/",
+		Typeflag: tar.TypeDir,
+		Mode:     0755,
+		ModTime:  modTime,
+	}))
+	require.NoError(t, tarWriter.WriteHeader(&tar.Header{
+		Name:     "pack/link.jpg",
+		Typeflag: tar.TypeSymlink,
+		Linkname: "/etc/passwd",
+		ModTime:  modTime,
+	}))
+	for _, entry := range entries {
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{
+			Name:     entry.name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(entry.content)),
+			ModTime:  modTime,
+		}))
+		_, err = tarWriter.Write(entry.content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, gzipWriter.Close())
+}
+
+func TestBatchImageImporter_ImportArchive(t *testing.T) {
+	tester := newTester(t)
+	dbClient := tester.dbClient
+
+	readTestFile := func(name string) []byte {
+		content, err := os.ReadFile(tester.getTestFilePath(name))
+		require.NoError(t, err)
+		return content
+	}
+	entries := []testArchiveEntry{
+		{name: "pack/a.jpg", content: readTestFile("image.jpg")},
+		{name: "pack/a.jpg.xmp", content: readTestFile("image.jpg.xmp")},
+		{name: "pack/sub/b.png", content: readTestFile("image.png")},
+		{name: "pack/sub/b.txt", content: []byte("Frieren, night sky")},
+		{name: "c.png", content: readTestFile("image.png")},
+		{name: "readme.md", content: []byte("# Screenshots")},
+		{name: "../escaped.png", content: readTestFile("image.png")},
+		{name: "__MACOSX/pack/._a.jpg", content: []byte("resource fork")},
+		{name: "pack/.DS_Store", content: []byte("finder")},
+	}
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		archiveName  string
+		writeArchive func(t *testing.T, filePath string, entries []testArchiveEntry, modTime time.Time)
+	}{
+		{
+			name:         "a zip archive",
+			archiveName:  "pack.zip",
+			writeArchive: writeTestZip,
+		},
+		{
+			name:         "a cbz archive",
+			archiveName:  "pack.cbz",
+			writeArchive: writeTestZip,
+		},
+		{
+			name:         "a tar.gz archive",
+			archiveName:  "pack.tar.gz",
+			writeArchive: writeTestTarGzip,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dbClient.Truncate(t, db.File{}, db.Tag{}, db.FileTag{})
+			// the images of each test case are imported into a new directory
+			destinationDirectory := image.Directory{ID: 1, Name: tc.name}
+			fileCreator := tester.newFileCreator(t).
+				CreateDirectory(destinationDirectory).
+				// an existing directory is reused
+				CreateDirectory(image.Directory{ID: 2, Name: "pack", ParentID: 1})
+			db.LoadTestData(t, dbClient, []db.File{
+				fileCreator.BuildDBDirectory(1),
+				fileCreator.BuildDBDirectory(2),
+			})
+			destinationDirectory = fileCreator.BuildDirectory(1)
+
+			archivePath := filepath.Join(t.TempDir(), tc.archiveName)
+			tc.writeArchive(t, archivePath, entries, modTime)
+
+			progressNotifier := NewProgressNotifier()
+			got, gotErr := tester.getBatchImageImporter().ImportArchive(context.Background(), destinationDirectory, archivePath, ImportOptions{}, progressNotifier)
+			require.Error(t, gotErr)
+			assert.ErrorIs(t, gotErr, ErrUnsafeArchiveEntry)
+			assert.ErrorIs(t, gotErr, image.ErrUnsupportedImageFile)
+			assert.Equal(t, 5, progressNotifier.Total)
+			assert.Equal(t, 3, progressNotifier.Completed)
+			assert.ElementsMatch(t, []string{
+				filepath.Join(archivePath, "readme.md"),
+				archivePath,
+			}, progressNotifier.FailedPaths)
+
+			gotImagePaths := make([]string, 0, len(got))
+			for _, imageFile := range got {
+				gotImagePaths = append(gotImagePaths, imageFile.LocalFilePath)
+				assert.FileExists(t, imageFile.LocalFilePath)
+			}
+			sort.Strings(gotImagePaths)
+			assert.Equal(t, []string{
+				filepath.Join(destinationDirectory.Path, "c.png"),
+				filepath.Join(destinationDirectory.Path, "pack", "a.jpg"),
+				filepath.Join(destinationDirectory.Path, "pack", "sub", "b.png"),
+			}, gotImagePaths)
+
+			gotFiles := db.MustGetAll[db.File](t, dbClient)
+			gotFileByName := make(map[string]db.File, len(gotFiles))
+			for _, file := range gotFiles {
+				gotFileByName[file.Name] = file
+			}
+			require.Len(t, gotFiles, 6)
+			assert.Equal(t, db.FileTypeDirectory, gotFileByName["sub"].Type)
+			assert.Equal(t, uint(2), gotFileByName["sub"].ParentID)
+			assert.Equal(t, uint(2), gotFileByName["a.jpg"].ParentID)
+			assert.Equal(t, gotFileByName["sub"].ID, gotFileByName["b.png"].ParentID)
+			assert.Equal(t, uint(1), gotFileByName["c.png"].ParentID)
+			assert.Equal(t, uint(modTime.Unix()), gotFileByName["c.png"].ImageCreatedAt, "the modification time of an entry is kept")
+
+			gotTagNames := make([]string, 0)
+			for _, gotTag := range db.MustGetAll[db.Tag](t, dbClient) {
+				gotTagNames = append(gotTagNames, gotTag.Name)
+			}
+			assert.Subset(t, gotTagNames, []string{"Frieren", "night sky"}, "a caption is imported as tags")
+			assert.Greater(t, len(gotTagNames), 2, "an XMP sidecar is imported as tags")
+		})
+	}
+}
+
+func TestBatchImageImporter_ImportArchive_unsupported(t *testing.T) {
+	tester := newTester(t)
+	destinationDirectory := tester.newFileCreator(t).
+		CreateDirectory(image.Directory{ID: 1, Name: "Directory 1"}).
+		BuildDirectory(1)
+
+	archivePath := filepath.Join(t.TempDir(), "pack.rar")
+	require.NoError(t, os.WriteFile(archivePath, []byte("Rar!"), 0644))
+	_, gotErr := tester.getBatchImageImporter().ImportArchive(context.Background(), destinationDirectory, archivePath, ImportOptions{}, NewProgressNotifier())
+	assert.ErrorIs(t, gotErr, ErrUnsupportedArchive)
+
+	archivePath = filepath.Join(t.TempDir(), "broken.zip")
+	require.NoError(t, os.WriteFile(archivePath, []byte("PK"), 0644))
+	_, gotErr = tester.getBatchImageImporter().ImportArchive(context.Background(), destinationDirectory, archivePath, ImportOptions{}, NewProgressNotifier())
+	assert.True(t, errors.Is(gotErr, zip.ErrFormat) || errors.Is(gotErr, io.ErrUnexpectedEOF), "got %v", gotErr)
+}
+
+func TestBatchImageImporter_extractArchive_limits(t *testing.T) {
+	tester := newTester(t)
+	archivePath := filepath.Join(t.TempDir(), "pack.zip")
+	writeTestZip(t, archivePath, []testArchiveEntry{
+		{name: "image1.jpg", content: make([]byte, 100)},
+		{name: "image2.jpg", content: make([]byte, 100)},
+		{name: "image2.xmp", content: make([]byte, 10)},
+	}, time.Now())
+
+	testCases := []struct {
+		name    string
+		limits  archiveLimits
+		wantErr error
+	}{
+		{
+			name:   "entries within the limits are extracted",
+			limits: archiveLimits{maxEntries: 3, maxSize: 210},
+		},
+		{
+			name:    "too many entries",
+			limits:  archiveLimits{maxEntries: 2, maxSize: 210},
+			wantErr: ErrArchiveTooLarge,
+		},
+		{
+			name:    "too many uncompressed bytes",
+			limits:  archiveLimits{maxEntries: 3, maxSize: 209},
+			wantErr: ErrArchiveTooLarge,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stagingDirectory := t.TempDir()
+			got, gotErr := tester.getBatchImageImporter().extractArchive(context.Background(), archivePath, stagingDirectory, tc.limits, NewProgressNotifier())
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, gotErr, tc.wantErr)
+				return
+			}
+			require.NoError(t, gotErr)
+			assert.Equal(t, map[string][]string{
+				".": {
+					filepath.Join(stagingDirectory, "image1.jpg"),
+					filepath.Join(stagingDirectory, "image2.jpg"),
+				},
+			}, got)
+		})
+	}
+}
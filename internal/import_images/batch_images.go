@@ -8,10 +8,12 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/event"
 	"github.com/michael-freling/anime-image-viewer/internal/image"
 	"github.com/michael-freling/anime-image-viewer/internal/tag"
 	"golang.org/x/sync/errgroup"
@@ -21,6 +23,19 @@ type importImage struct {
 	image          db.File
 	sourceFilePath string
 	xmp            *XMP
+	embedded       embeddedMetadata
+	// captionKeywords are the words in a caption file next to the image
+	captionKeywords []string
+
+	action importAction
+	// existingFileID is the image replaced or linked to by the import
+	existingFileID uint
+	// stagedFilePath is a copy of the source next to the image it replaces,
+	// which is renamed over the image
+	stagedFilePath string
+	// originalFilePath is the image it replaced, which is moved aside until
+	// the import is committed and moved back if it is rolled back
+	originalFilePath string
 }
 
 type BatchImageImporter struct {
@@ -29,6 +44,7 @@ type BatchImageImporter struct {
 
 	imageFileConverter *image.ImageFileConverter
 	tagReader          *tag.Reader
+	eventBus           *event.Bus
 }
 
 func NewBatchImageImporter(
@@ -36,6 +52,7 @@ func NewBatchImageImporter(
 	dbClient *db.Client,
 	imageFileConverter *image.ImageFileConverter,
 	tagReader *tag.Reader,
+	eventBus *event.Bus,
 ) *BatchImageImporter {
 	return &BatchImageImporter{
 		logger:   logger,
@@ -43,6 +60,7 @@ func NewBatchImageImporter(
 
 		imageFileConverter: imageFileConverter,
 		tagReader:          tagReader,
+		eventBus:           eventBus,
 	}
 }
 
@@ -84,16 +102,43 @@ func (batchImporter *BatchImageImporter) readImageFilePaths(
 				)
 			}
 
+			embedded, err := readEmbeddedMetadata(sourceFilePath)
+			if err != nil {
+				batchImporter.logger.InfoContext(ctx, "failed to read metadata embedded in an image",
+					"error", err,
+					"image", sourceFilePath,
+				)
+			}
+
+			captionKeywords, err := readCaption(captionFilePath(sourceFilePath))
+			if err != nil {
+				batchImporter.logger.InfoContext(ctx, "failed to read a caption file for an image",
+					"error", err,
+					"image", sourceFilePath,
+				)
+			}
+
 			importImage := importImage{
 				image: db.File{
-					Name:           filepath.Base(sourceFilePath),
-					ParentID:       destinationParentDirectory.ID,
-					Type:           db.FileTypeImage,
-					ImageCreatedAt: uint(pathStat.ModTime().Unix()),
+					Name:     filepath.Base(sourceFilePath),
+					ParentID: destinationParentDirectory.ID,
+					Type:     db.FileTypeImage,
 				},
-				sourceFilePath: sourceFilePath,
-				xmp:            xmpFile,
+				sourceFilePath:  sourceFilePath,
+				xmp:             xmpFile,
+				embedded:        embedded,
+				captionKeywords: captionKeywords,
 			}
+			// The time an image was taken is used if its metadata has it,
+			// since the modification time changes when a file is copied.
+			metadata := importImage.metadata()
+			createdAt := metadata.createdAt
+			if createdAt.IsZero() {
+				createdAt = pathStat.ModTime()
+			}
+			importImage.image.ImageCreatedAt = uint(createdAt.Unix())
+			importImage.image.Rating = metadata.rating
+			importImage.image.Description = metadata.description
 			importImages[index] = importImage
 			return nil
 		})
@@ -119,10 +164,36 @@ func (batchImporter *BatchImageImporter) readImageFilePaths(
 
 }
 
+// ImportImages imports images into a directory. A conflict with an image in
+// the library is resolved by the policies in the options.
 func (batchImporter *BatchImageImporter) ImportImages(
 	ctx context.Context,
 	destinationParentDirectory image.Directory,
 	paths []string,
+	options ImportOptions,
+	progressNotifier *ProgressNotifier,
+) ([]image.ImageFile, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	resultImageFiles, err := batchImporter.importImages(ctx, destinationParentDirectory, paths, options, progressNotifier)
+	if err != nil {
+		return nil, errors.Join(err, errors.Join(progressNotifier.FailedErrors...))
+	}
+	if len(progressNotifier.FailedErrors) > 0 {
+		return resultImageFiles, errors.Join(progressNotifier.FailedErrors...)
+	}
+	return resultImageFiles, nil
+}
+
+// importImages imports images into a directory, and returns an error only if
+// the import cannot continue. An image that fails to be imported is added to
+// the progress notifier.
+func (batchImporter *BatchImageImporter) importImages(
+	ctx context.Context,
+	destinationParentDirectory image.Directory,
+	paths []string,
+	options ImportOptions,
 	progressNotifier *ProgressNotifier,
 ) ([]image.ImageFile, error) {
 	importedImages, err := batchImporter.readImageFilePaths(ctx, paths, destinationParentDirectory, progressNotifier)
@@ -138,6 +209,7 @@ func (batchImporter *BatchImageImporter) ImportImages(
 		ctx,
 		importedImages,
 		destinationParentDirectory,
+		options,
 	)
 	if err != nil {
 		// unexpected error occurred and not to keep running the process
@@ -149,22 +221,16 @@ func (batchImporter *BatchImageImporter) ImportImages(
 		// "newImages", newImportedImages,
 	)
 	if len(newImportedImages) == 0 {
-		return nil, errors.Join(progressNotifier.FailedErrors...)
+		return nil, nil
 	}
 
-	// Compute content hashes and image dimensions from source files before
-	// inserting into DB. This avoids a separate UPDATE after the concurrent
-	// file copy.
+	// Compute image dimensions from source files before inserting into DB.
+	// This avoids a separate UPDATE after the concurrent file copy. Content
+	// hashes are computed by the validator to find duplicates.
 	for i, img := range newImportedImages {
-		hash, err := image.ComputeFileHash(img.sourceFilePath)
-		if err != nil {
-			batchImporter.logger.Warn("failed to compute hash on import",
-				"path", img.sourceFilePath, "error", err,
-			)
+		if img.action == importActionLinkTags {
 			continue
 		}
-		newImportedImages[i].image.ContentHash = hash
-
 		w, h, dimErr := image.DecodeImageDimensions(img.sourceFilePath)
 		if dimErr != nil {
 			batchImporter.logger.Warn("failed to decode dimensions on import",
@@ -176,18 +242,67 @@ func (batchImporter *BatchImageImporter) ImportImages(
 		newImportedImages[i].image.ImageHeight = &h
 	}
 
-	if err := db.NewTransaction(ctx, batchImporter.dbClient, func(ctx context.Context) error {
-		files := make([]db.File, len(newImportedImages))
-		for index, newImage := range newImportedImages {
-			files[index] = newImage.image
+	// an image is replaced by renaming a complete copy over it, so that the
+	// original is kept if the copy fails
+	newImportedImages = stageReplacingImages(newImportedImages, destinationParentDirectory, progressNotifier)
+	defer func() {
+		for _, newImage := range newImportedImages {
+			if newImage.stagedFilePath != "" {
+				// the copy is left only if the image wasn't replaced
+				_ = os.Remove(newImage.stagedFilePath)
+			}
+			if newImage.originalFilePath != "" {
+				// the original is left only once the import is committed
+				_ = os.Remove(newImage.originalFilePath)
+			}
 		}
+	}()
 
-		if err := batchImporter.dbClient.File().BatchCreate(ctx, files); err != nil {
-			return fmt.Errorf("BatchCreate: %w", err)
+	if err := db.NewTransaction(ctx, batchImporter.dbClient, func(ctx context.Context) error {
+		// the images are replaced before their records are updated, so that a
+		// record isn't updated for an image which failed to be replaced
+		newImportedImages = replaceStagedImages(newImportedImages, destinationParentDirectory, progressNotifier)
+
+		files := make([]db.File, 0, len(newImportedImages))
+		for _, newImage := range newImportedImages {
+			if newImage.action == importActionCreate {
+				files = append(files, newImage.image)
+			}
+		}
+		if len(files) > 0 {
+			if err := batchImporter.dbClient.File().BatchCreate(ctx, files); err != nil {
+				return fmt.Errorf("BatchCreate: %w", err)
+			}
 		}
+
 		// set an id back
-		for index := range newImportedImages {
-			newImportedImages[index].image.ID = files[index].ID
+		createdFileIDByHash := make(map[string]uint)
+		fileIndex := 0
+		for index, newImage := range newImportedImages {
+			if newImage.action != importActionCreate {
+				continue
+			}
+			newImportedImages[index].image.ID = files[fileIndex].ID
+			if _, ok := createdFileIDByHash[newImage.image.ContentHash]; !ok {
+				createdFileIDByHash[newImage.image.ContentHash] = files[fileIndex].ID
+			}
+			fileIndex++
+		}
+		for index, newImage := range newImportedImages {
+			switch newImage.action {
+			case importActionReplace:
+				newImportedImages[index].image.ID = newImage.existingFileID
+				if err := batchImporter.dbClient.File().UpdateImageContent(ctx, newImportedImages[index].image); err != nil {
+					return fmt.Errorf("File.UpdateImageContent: %w", err)
+				}
+			case importActionLinkTags:
+				if newImage.existingFileID == 0 {
+					// a duplicate of another image in the import
+					newImportedImages[index].image.ID = createdFileIDByHash[newImage.image.ContentHash]
+				} else {
+					newImportedImages[index].image.ID = newImage.existingFileID
+				}
+			}
 		}
 
 		tagImporter := newBatchTagImporter(batchImporter.dbClient, batchImporter.tagReader)
@@ -197,22 +312,35 @@ func (batchImporter *BatchImageImporter) ImportImages(
 
 		return nil
 	}); err != nil {
-		return nil, errors.Join(
-			fmt.Errorf("NewTransaction: %w", err),
-			errors.Join(progressNotifier.FailedErrors...),
-		)
+		restoreReplacedImages(newImportedImages, destinationParentDirectory, batchImporter.logger)
+		return nil, fmt.Errorf("NewTransaction: %w", err)
 	}
 
 	eg, _ := errgroup.WithContext(ctx)
-	resultImageFiles := make([]image.ImageFile, len(newImportedImages))
-	for index, newImage := range newImportedImages {
-		sourceFilePath := newImportedImages[index].sourceFilePath
+	copiedImages := make([]importImage, 0, len(newImportedImages))
+	linkedImageIDs := make([]uint, 0)
+	for _, newImage := range newImportedImages {
+		if newImage.action == importActionLinkTags {
+			// the tags of the image were linked, and nothing is copied
+			if !slices.Contains(linkedImageIDs, newImage.image.ID) {
+				linkedImageIDs = append(linkedImageIDs, newImage.image.ID)
+			}
+			progressNotifier.addSuccess()
+			continue
+		}
+		copiedImages = append(copiedImages, newImage)
+	}
+	resultImageFiles := make([]image.ImageFile, len(copiedImages))
+	for index, newImage := range copiedImages {
+		sourceFilePath := newImage.sourceFilePath
 		destinationFilePath := filepath.Join(destinationParentDirectory.Path, newImage.image.Name)
 
 		eg.Go(func() error {
-			if _, err := image.Copy(sourceFilePath, destinationFilePath); err != nil {
-				progressNotifier.addFailure(sourceFilePath, fmt.Errorf("image.copy: %w", err))
-				return nil
+			if newImage.action == importActionCreate {
+				if _, err := image.Copy(sourceFilePath, destinationFilePath); err != nil {
+					progressNotifier.addFailure(sourceFilePath, fmt.Errorf("image.copy: %w", err))
+					return nil
+				}
 			}
 
 			resultImage, err := batchImporter.imageFileConverter.ConvertImageFile(destinationParentDirectory, newImage.image)
@@ -227,20 +355,137 @@ func (batchImporter *BatchImageImporter) ImportImages(
 		})
 	}
 	if err := eg.Wait(); err != nil {
-		return nil, errors.Join(
-			fmt.Errorf("errgroup.Wait: %w", err),
-			errors.Join(progressNotifier.FailedErrors...),
-		)
+		return nil, fmt.Errorf("errgroup.Wait: %w", err)
 	}
-	if len(progressNotifier.FailedErrors) > 0 {
-		return resultImageFiles, errors.Join(progressNotifier.FailedErrors...)
+	if images := event.NewImages(resultImageFiles); len(images) > 0 || len(linkedImageIDs) > 0 {
+		batchImporter.eventBus.Publish(ctx, event.TypeImagesImported, event.ImagesImported{
+			DirectoryID:    destinationParentDirectory.ID,
+			Images:         images,
+			LinkedImageIDs: linkedImageIDs,
+		})
 	}
-
 	return resultImageFiles, nil
 }
 
+// stageReplacingImages copies the images replacing others into temporary
+// files in the destination directory. An image which fails to be copied isn't
+// imported.
+func stageReplacingImages(
+	importImages []importImage,
+	destinationDirectory image.Directory,
+	progressNotifier *ProgressNotifier,
+) []importImage {
+	result := make([]importImage, 0, len(importImages))
+	for _, importImage := range importImages {
+		if importImage.action != importActionReplace {
+			result = append(result, importImage)
+			continue
+		}
+		stagedFilePath, err := stageImage(importImage.sourceFilePath, destinationDirectory.Path, importImage.image.Name)
+		if err != nil {
+			progressNotifier.addFailure(importImage.sourceFilePath, fmt.Errorf("stageImage: %w", err))
+			continue
+		}
+		importImage.stagedFilePath = stagedFilePath
+		result = append(result, importImage)
+	}
+	return result
+}
+
+func stageImage(sourceFilePath string, directoryPath string, name string) (string, error) {
+	stagedFilePath, err := createTempFile(directoryPath, name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := image.Copy(sourceFilePath, stagedFilePath); err != nil {
+		_ = os.Remove(stagedFilePath)
+		return "", fmt.Errorf("image.Copy: %w", err)
+	}
+	return stagedFilePath, nil
+}
+
+// createTempFile creates an empty hidden file next to an image, and returns
+// its path.
+func createTempFile(directoryPath string, name string) (string, error) {
+	file, err := os.CreateTemp(directoryPath, "."+name+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("os.CreateTemp: %w", err)
+	}
+	filePath := file.Name()
+	if err := file.Close(); err != nil {
+		_ = os.Remove(filePath)
+		return "", fmt.Errorf("file.Close: %w", err)
+	}
+	return filePath, nil
+}
+
+// replaceStagedImages moves the images to be replaced aside and renames the
+// staged copies over them. An image which fails to be replaced isn't
+// imported.
+func replaceStagedImages(
+	importImages []importImage,
+	destinationDirectory image.Directory,
+	progressNotifier *ProgressNotifier,
+) []importImage {
+	result := make([]importImage, 0, len(importImages))
+	for _, importImage := range importImages {
+		if importImage.action != importActionReplace {
+			result = append(result, importImage)
+			continue
+		}
+		destinationFilePath := filepath.Join(destinationDirectory.Path, importImage.image.Name)
+		originalFilePath, err := createTempFile(destinationDirectory.Path, importImage.image.Name)
+		if err != nil {
+			_ = os.Remove(importImage.stagedFilePath)
+			progressNotifier.addFailure(importImage.sourceFilePath, fmt.Errorf("createTempFile: %w", err))
+			continue
+		}
+		if err := os.Rename(destinationFilePath, originalFilePath); err != nil {
+			_ = os.Remove(originalFilePath)
+			_ = os.Remove(importImage.stagedFilePath)
+			progressNotifier.addFailure(importImage.sourceFilePath, fmt.Errorf("os.Rename: %w", err))
+			continue
+		}
+		if err := os.Rename(importImage.stagedFilePath, destinationFilePath); err != nil {
+			_ = os.Rename(originalFilePath, destinationFilePath)
+			_ = os.Remove(importImage.stagedFilePath)
+			progressNotifier.addFailure(importImage.sourceFilePath, fmt.Errorf("os.Rename: %w", err))
+			continue
+		}
+		importImage.stagedFilePath = ""
+		importImage.originalFilePath = originalFilePath
+		result = append(result, importImage)
+	}
+	return result
+}
+
+// restoreReplacedImages moves the replaced images back, after the import
+// failed to be committed.
+func restoreReplacedImages(importImages []importImage, destinationDirectory image.Directory, logger *slog.Logger) {
+	for index, importImage := range importImages {
+		if importImage.originalFilePath == "" {
+			continue
+		}
+		destinationFilePath := filepath.Join(destinationDirectory.Path, importImage.image.Name)
+		if err := os.Rename(importImage.originalFilePath, destinationFilePath); err != nil {
+			// the original is left next to the image so that it isn't lost
+			logger.Error("failed to restore a replaced image",
+				"path", destinationFilePath,
+				"original", importImage.originalFilePath,
+				"error", err,
+			)
+		}
+		importImages[index].originalFilePath = ""
+	}
+}
+
 type ProgressNotifier struct {
-	Completed    int
+	// Total is the number of images in an archive, which is known only after
+	// ImportArchive reads its entries
+	Total     int
+	Completed int
+	// Skipped is the number of images skipped by a conflict policy
+	Skipped      int
 	Failed       int
 	FailedPaths  []string
 	FailedErrors []error
@@ -262,6 +507,12 @@ func (notifier *ProgressNotifier) addSuccess() {
 	notifier.successMutex.Unlock()
 }
 
+func (notifier *ProgressNotifier) addSkip() {
+	notifier.successMutex.Lock()
+	notifier.Skipped++
+	notifier.successMutex.Unlock()
+}
+
 func (notifier *ProgressNotifier) addFailure(path string, err error) {
 	notifier.failureMutex.Lock()
 	notifier.Failed++
@@ -299,20 +550,32 @@ func (batchValidator batchImportImageValidator) validateImages(
 	ctx context.Context,
 	sourceImageFilePaths []importImage,
 	destinationParentDirectory image.Directory,
+	options ImportOptions,
 ) (
 	[]importImage,
 	error,
 ) {
 	validatedImages := make([]importImage, len(sourceImageFilePaths))
+	hasNameConflict := make([]bool, len(sourceImageFilePaths))
+	isNameConflictResolved := options.OnNameConflict != ConflictPolicyDefault &&
+		options.OnNameConflict != ConflictPolicyFail
 	eg, _ := errgroup.WithContext(ctx)
 	for index, importImage := range sourceImageFilePaths {
 		eg.Go(func() error {
 			sourceFilePath := importImage.sourceFilePath
 			if err := batchValidator.validateImportImageFile(sourceFilePath, destinationParentDirectory); err != nil {
-				batchValidator.progressNotifier.addFailure(sourceFilePath, err)
-				return nil
+				if !isNameConflictResolved || !errors.Is(err, image.ErrFileAlreadyExists) {
+					batchValidator.progressNotifier.addFailure(sourceFilePath, err)
+					return nil
+				}
+				hasNameConflict[index] = true
 			}
 
+			// An image whose hash cannot be computed is imported without
+			// it, and fails to be copied if it cannot be read.
+			if hash, err := image.ComputeFileHash(sourceFilePath); err == nil {
+				importImage.image.ContentHash = hash
+			}
 			validatedImages[index] = importImage
 			return nil
 		})
@@ -324,16 +587,8 @@ func (batchValidator batchImportImageValidator) validateImages(
 		)
 	}
 
-	// filter failed images
-	resultImages := make([]importImage, 0, len(validatedImages))
-	for index := range validatedImages {
-		if validatedImages[index].sourceFilePath == "" {
-			continue
-		}
-
-		resultImages = append(resultImages, validatedImages[index])
-	}
-	return resultImages, nil
+	// failed images are filtered while resolving conflicts
+	return batchValidator.resolveConflicts(validatedImages, hasNameConflict, destinationParentDirectory, options)
 }
 
 func (validator *batchImportImageValidator) validateImportImageFile(
@@ -171,7 +171,7 @@ func TestBatchImageImporter_importImageFiles(t *testing.T) {
 
 			batchImporter := tester.getBatchImageImporter()
 			progressNotifier := NewProgressNotifier()
-			got, gotErrs := batchImporter.ImportImages(context.Background(), tc.destinationDirectory, tc.sourceFilePaths, progressNotifier)
+			got, gotErrs := batchImporter.ImportImages(context.Background(), tc.destinationDirectory, tc.sourceFilePaths, ImportOptions{}, progressNotifier)
 			if len(tc.wantErrors) > 0 {
 				uw, ok := gotErrs.(interface{ Unwrap() []error })
 				assert.True(t, ok)
@@ -270,6 +270,7 @@ func TestBatchImageImporter_importImageFiles(t *testing.T) {
 			context.Background(),
 			destDir,
 			[]string{sourceFile},
+			ImportOptions{},
 			progressNotifier,
 		)
 		// Should return images (possibly with zero values for failed copies) and errors
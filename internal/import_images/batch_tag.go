@@ -3,10 +3,10 @@ package import_images
 import (
 	"context"
 	"fmt"
-	"strings"
 
 	"github.com/michael-freling/anime-image-viewer/internal/db"
 	"github.com/michael-freling/anime-image-viewer/internal/tag"
+	"github.com/michael-freling/anime-image-viewer/internal/xmp"
 )
 
 type batchTagImporter struct {
@@ -21,6 +21,11 @@ func newBatchTagImporter(dbClient *db.Client, tagReader *tag.Reader) batchTagImp
 	}
 }
 
+// importTags tags the images with the keywords in their metadata. Every name
+// in a keyword hierarchy becomes a tag, and an image is tagged with the leaf.
+// A keyword under "Characters", as the XMP writer writes a character, links
+// the image to the character of the anime it is imported into, creating the
+// character if the anime has none with the name.
 func (batchImporter batchTagImporter) importTags(
 	ctx context.Context,
 	importedImages []importImage,
@@ -35,32 +40,41 @@ func (batchImporter batchTagImporter) importTags(
 	for i := range allTags {
 		tagByName[allTags[i].Name] = &allTags[i]
 	}
+	characterImporter := newCharacterImporter(batchImporter.dbClient)
 
 	tagORMClient := batchImporter.dbClient.Tag()
 	newFileTags := make([]db.FileTag, 0)
-OUTER_LOOP:
+	newFileCharacters := make([]db.FileCharacter, 0)
+	isFileTagAdded := make(map[db.FileTag]bool)
+	isFileCharacterAdded := make(map[db.FileCharacter]bool)
 	for _, importedImage := range importedImages {
 		if importedImage.image.ID == 0 {
 			continue
 		}
-		if importedImage.xmp == nil {
-			continue
-		}
 
-		for _, t := range importedImage.xmp.RDF.TagsList {
-			if strings.TrimSpace(t) == "" {
-				continue OUTER_LOOP
-			}
-			importedTags := strings.Split(t, "/")
-			if len(importedTags) == 0 {
-				continue
+		for _, importedTags := range importedImage.metadata().keywords {
+			if len(importedTags) >= 2 && importedTags[0] == xmp.CharactersKeyword {
+				characterID, ok, err := characterImporter.findOrCreate(ctx, importedImage.image.ParentID, importedTags[len(importedTags)-1])
+				if err != nil {
+					return fmt.Errorf("characterImporter.findOrCreate: %w", err)
+				}
+				if ok {
+					fileCharacter := db.FileCharacter{
+						FileID:      importedImage.image.ID,
+						CharacterID: characterID,
+						AddedBy:     db.FileTagAddedByImport,
+					}
+					if !isFileCharacterAdded[fileCharacter] {
+						isFileCharacterAdded[fileCharacter] = true
+						newFileCharacters = append(newFileCharacters, fileCharacter)
+					}
+					continue
+				}
+				// without an anime, a character is imported as tags
 			}
 
 			// Create all tags in the path as flat tags
 			for _, tagName := range importedTags {
-				if tagName == "" {
-					continue
-				}
 				if _, exists := tagByName[tagName]; exists {
 					continue
 				}
@@ -78,25 +92,91 @@ OUTER_LOOP:
 			}
 
 			// Tag the image with the leaf tag (last in path)
-			leafTagName := importedTags[len(importedTags)-1]
-			if leafTagName == "" && len(importedTags) > 1 {
-				leafTagName = importedTags[len(importedTags)-2]
+			leafTag := tagByName[importedTags[len(importedTags)-1]]
+			fileTag := db.FileTag{
+				FileID:  importedImage.image.ID,
+				TagID:   leafTag.ID,
+				AddedBy: db.FileTagAddedByImport,
 			}
-			if leafTag, ok := tagByName[leafTagName]; ok {
-				newFileTags = append(newFileTags, db.FileTag{
-					FileID:  importedImage.image.ID,
-					TagID:   leafTag.ID,
-					AddedBy: db.FileTagAddedByImport,
-				})
+			if !isFileTagAdded[fileTag] {
+				isFileTagAdded[fileTag] = true
+				newFileTags = append(newFileTags, fileTag)
 			}
 		}
 	}
-	if len(newFileTags) == 0 {
-		return nil
-	}
 
-	if err := batchImporter.dbClient.FileTag().BatchCreate(ctx, newFileTags); err != nil {
-		return fmt.Errorf("FileTag.BatchCreate: %w", err)
+	if len(newFileTags) > 0 {
+		// an existing image that tags are linked onto can have them already
+		if err := batchImporter.dbClient.FileTag().BatchCreateIgnoringExisting(ctx, newFileTags); err != nil {
+			return fmt.Errorf("FileTag.BatchCreateIgnoringExisting: %w", err)
+		}
+	}
+	if len(newFileCharacters) > 0 {
+		if err := batchImporter.dbClient.FileCharacter().BatchCreateIgnoringExisting(ctx, newFileCharacters); err != nil {
+			return fmt.Errorf("FileCharacter.BatchCreateIgnoringExisting: %w", err)
+		}
 	}
 	return nil
 }
+
+// characterImporter finds the characters of the anime images are imported
+// into by name.
+type characterImporter struct {
+	dbClient *db.Client
+
+	// animeIDs caches the anime of each directory, 0 if it has none
+	animeIDs map[uint]uint
+	// characterIDs caches the characters by anime ID and name
+	characterIDs map[uint]map[string]uint
+}
+
+func newCharacterImporter(dbClient *db.Client) *characterImporter {
+	return &characterImporter{
+		dbClient:     dbClient,
+		animeIDs:     make(map[uint]uint),
+		characterIDs: make(map[uint]map[string]uint),
+	}
+}
+
+// findOrCreate returns the ID of the character with the name in the anime of
+// the directory, or false if the directory doesn't belong to any anime.
+func (importer *characterImporter) findOrCreate(ctx context.Context, directoryID uint, name string) (uint, bool, error) {
+	animeID, ok := importer.animeIDs[directoryID]
+	if !ok {
+		animeIDs, err := importer.dbClient.File().FindAnimeIDsByDirectoryIDs(ctx, []uint{directoryID})
+		if err != nil {
+			return 0, false, fmt.Errorf("File.FindAnimeIDsByDirectoryIDs: %w", err)
+		}
+		animeID = animeIDs[directoryID]
+		importer.animeIDs[directoryID] = animeID
+	}
+	if animeID == 0 {
+		return 0, false, nil
+	}
+
+	characterIDs, ok := importer.characterIDs[animeID]
+	if !ok {
+		characters, err := importer.dbClient.Character().FindByAnimeID(ctx, animeID)
+		if err != nil {
+			return 0, false, fmt.Errorf("Character.FindByAnimeID: %w", err)
+		}
+		characterIDs = make(map[string]uint, len(characters))
+		for _, character := range characters {
+			characterIDs[character.Name] = character.ID
+		}
+		importer.characterIDs[animeID] = characterIDs
+	}
+	if characterID, ok := characterIDs[name]; ok {
+		return characterID, true, nil
+	}
+
+	character := db.Character{
+		Name:    name,
+		AnimeID: animeID,
+	}
+	if err := importer.dbClient.Character().Create(ctx, &character); err != nil {
+		return 0, false, fmt.Errorf("Character.Create: %w", err)
+	}
+	characterIDs[name] = character.ID
+	return character.ID, true, nil
+}
@@ -2,11 +2,14 @@ package import_images
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/michael-freling/anime-image-viewer/internal/db"
 	"github.com/michael-freling/anime-image-viewer/internal/image"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBatchTagImporter_importTags(t *testing.T) {
@@ -153,3 +156,148 @@ func TestBatchTagImporter_importTags(t *testing.T) {
 		})
 	}
 }
+
+func TestBatchTagImporter_importTags_characters(t *testing.T) {
+	tester := newTester(t)
+	ctx := context.Background()
+	animeID := uint(1)
+	tester.dbClient.Truncate(t, db.File{}, db.Tag{}, db.FileTag{}, db.Character{}, db.FileCharacter{})
+	db.LoadTestData(t, tester.dbClient, []db.File{
+		{ID: 1, Name: "Frieren", Type: db.FileTypeDirectory, AnimeID: &animeID},
+		{ID: 2, Name: "Season 1", ParentID: 1, Type: db.FileTypeDirectory},
+		{ID: 3, Name: "Unassigned", Type: db.FileTypeDirectory},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.Character{
+		{ID: 1, Name: "Frieren", AnimeID: animeID},
+	})
+
+	batchTagImporter := tester.getBatchTagImporter()
+	gotErr := batchTagImporter.importTags(ctx, []importImage{
+		{
+			image: db.File{ID: 11, ParentID: 2},
+			xmp: &XMP{RDF: RDF{
+				TagsList: []string{"Characters/Frieren", "Characters/Fern"},
+				// flat keywords written along with the hierarchy are skipped
+				Subjects: []string{"Frieren", "Fern"},
+			}},
+		},
+		{
+			image: db.File{ID: 12, ParentID: 1},
+			embedded: embeddedMetadata{
+				xmp: &XMP{RDF: RDF{
+					HierarchicalSubjects: []string{"Characters|Fern"},
+				}},
+				iptcKeywords: []string{"night", "night"},
+			},
+		},
+		{
+			// an image outside of any anime gets tags instead
+			image: db.File{ID: 13, ParentID: 3},
+			xmp:   &XMP{RDF: RDF{TagsList: []string{"Characters/Himmel"}}},
+		},
+	})
+	assert.NoError(t, gotErr)
+
+	gotCharacters := db.MustGetAll[db.Character](t, tester.dbClient)
+	gotCharacterNames := make(map[uint]string, len(gotCharacters))
+	for _, gotCharacter := range gotCharacters {
+		assert.Equal(t, animeID, gotCharacter.AnimeID)
+		gotCharacterNames[gotCharacter.ID] = gotCharacter.Name
+	}
+	assert.Equal(t, map[uint]string{1: "Frieren", 2: "Fern"}, gotCharacterNames)
+	gotFileCharacters := db.MustGetAll[db.FileCharacter](t, tester.dbClient)
+	for i := range gotFileCharacters {
+		gotFileCharacters[i].CreatedAt = 0
+	}
+	assert.ElementsMatch(t, []db.FileCharacter{
+		{FileID: 11, CharacterID: 1, AddedBy: db.FileTagAddedByImport},
+		{FileID: 11, CharacterID: 2, AddedBy: db.FileTagAddedByImport},
+		{FileID: 12, CharacterID: 2, AddedBy: db.FileTagAddedByImport},
+	}, gotFileCharacters)
+
+	gotTags := db.MustGetAll[db.Tag](t, tester.dbClient)
+	gotTagNames := make([]string, len(gotTags))
+	for i, gotTag := range gotTags {
+		gotTagNames[i] = gotTag.Name
+	}
+	assert.Equal(t, []string{"night", "Characters", "Himmel"}, gotTagNames)
+	gotFileTags := db.MustGetAll[db.FileTag](t, tester.dbClient)
+	gotFileTagIDs := make([][2]uint, len(gotFileTags))
+	for i, gotFileTag := range gotFileTags {
+		gotFileTagIDs[i] = [2]uint{gotFileTag.FileID, gotFileTag.TagID}
+	}
+	assert.Equal(t, [][2]uint{{12, 1}, {13, 3}}, gotFileTagIDs)
+}
+
+func TestBatchImageImporter_ImportImages_characters(t *testing.T) {
+	tester := newTester(t)
+	animeID := uint(1)
+	fileBuilder := tester.newFileCreator(t).
+		CreateDirectory(image.Directory{ID: 1, Name: "Frieren"}).
+		CreateDirectory(image.Directory{ID: 2, Name: "Season 1", ParentID: 1}).
+		CreateDirectory(image.Directory{ID: 3, Name: "source"}).
+		CreateImage(image.ImageFile{ID: 30, Name: "image.jpg", ParentID: 3}, image.TestImageFileJpeg)
+	sourceDirectory := fileBuilder.BuildDirectory(3)
+	// a sidecar as the XMP writer writes for an image with a character
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDirectory.Path, "image.jpg.xmp"), []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:digiKam="http://www.digikam.org/ns/1.0/">
+   <digiKam:TagsList>
+    <rdf:Seq>
+     <rdf:li>Characters/Frieren</rdf:li>
+     <rdf:li>Characters/Fern</rdf:li>
+     <rdf:li>night</rdf:li>
+    </rdf:Seq>
+   </digiKam:TagsList>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`), 0644))
+
+	tester.dbClient.Truncate(t, db.File{}, db.Tag{}, db.FileTag{}, db.Character{}, db.FileCharacter{})
+	db.LoadTestData(t, tester.dbClient, []db.File{
+		{ID: 1, Name: "Frieren", Type: db.FileTypeDirectory, AnimeID: &animeID},
+		{ID: 2, Name: "Season 1", ParentID: 1, Type: db.FileTypeDirectory},
+	})
+	db.LoadTestData(t, tester.dbClient, []db.Character{
+		{ID: 1, Name: "Frieren", AnimeID: animeID},
+	})
+
+	progressNotifier := NewProgressNotifier()
+	_, err := tester.getBatchImageImporter().ImportImages(
+		context.Background(),
+		fileBuilder.BuildDirectory(2),
+		[]string{filepath.Join(sourceDirectory.Path, "image.jpg")},
+		ImportOptions{},
+		progressNotifier,
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 1, progressNotifier.Completed)
+
+	var importedFileID uint
+	for _, file := range db.MustGetAll[db.File](t, tester.dbClient) {
+		if file.Type == db.FileTypeImage {
+			importedFileID = file.ID
+		}
+	}
+	require.NotZero(t, importedFileID)
+
+	gotCharacters := db.MustGetAll[db.Character](t, tester.dbClient)
+	gotCharacterNames := make(map[uint]string, len(gotCharacters))
+	for _, gotCharacter := range gotCharacters {
+		assert.Equal(t, animeID, gotCharacter.AnimeID)
+		gotCharacterNames[gotCharacter.ID] = gotCharacter.Name
+	}
+	assert.Equal(t, map[uint]string{1: "Frieren", 2: "Fern"}, gotCharacterNames)
+	gotFileCharacters := db.MustGetAll[db.FileCharacter](t, tester.dbClient)
+	for i := range gotFileCharacters {
+		gotFileCharacters[i].CreatedAt = 0
+	}
+	assert.ElementsMatch(t, []db.FileCharacter{
+		{FileID: importedFileID, CharacterID: 1, AddedBy: db.FileTagAddedByImport},
+		{FileID: importedFileID, CharacterID: 2, AddedBy: db.FileTagAddedByImport},
+	}, gotFileCharacters)
+
+	gotTags := db.MustGetAll[db.Tag](t, tester.dbClient)
+	require.Len(t, gotTags, 1)
+	assert.Equal(t, "night", gotTags[0].Name)
+}
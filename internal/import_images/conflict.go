@@ -0,0 +1,239 @@
+package import_images
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+)
+
+// ConflictPolicy is how an image that conflicts with another one is imported.
+type ConflictPolicy string
+
+const (
+	// ConflictPolicyDefault fails an image whose name is taken, and imports
+	// a duplicate as another image.
+	ConflictPolicyDefault ConflictPolicy = ""
+	// ConflictPolicyFail reports the image as a failure.
+	ConflictPolicyFail ConflictPolicy = "fail"
+	// ConflictPolicySkip leaves the image out without reporting a failure.
+	ConflictPolicySkip ConflictPolicy = "skip"
+	// ConflictPolicyRename imports the image under a free name, e.g.
+	// "image (1).jpg". It is only for a name conflict.
+	ConflictPolicyRename ConflictPolicy = "rename"
+	// ConflictPolicyReplace overwrites the image with the same name, which
+	// keeps its ID and tags. It is only for a name conflict.
+	ConflictPolicyReplace ConflictPolicy = "replace"
+	// ConflictPolicyLinkTags doesn't import the image, but adds its tags and
+	// characters onto the existing image.
+	ConflictPolicyLinkTags ConflictPolicy = "link-tags"
+
+	maxRenameAttempts = 10000
+)
+
+var (
+	ErrDuplicateImage        = errors.New("duplicate image")
+	ErrInvalidConflictPolicy = errors.New("invalid conflict policy")
+)
+
+// ImportOptions are how an import resolves conflicts.
+type ImportOptions struct {
+	// OnNameConflict is applied to an image whose name is taken in the
+	// destination directory.
+	OnNameConflict ConflictPolicy `json:"onNameConflict"`
+	// OnDuplicate is applied to an image with the same content as an image
+	// in the library or another image in the same import. It is checked
+	// before the name.
+	OnDuplicate ConflictPolicy `json:"onDuplicate"`
+}
+
+func (options ImportOptions) validate() error {
+	switch options.OnNameConflict {
+	case ConflictPolicyDefault, ConflictPolicyFail, ConflictPolicySkip,
+		ConflictPolicyRename, ConflictPolicyReplace, ConflictPolicyLinkTags:
+	default:
+		return fmt.Errorf("%w on a name conflict: %s", ErrInvalidConflictPolicy, options.OnNameConflict)
+	}
+	switch options.OnDuplicate {
+	case ConflictPolicyDefault, ConflictPolicyFail, ConflictPolicySkip, ConflictPolicyLinkTags:
+	default:
+		return fmt.Errorf("%w on a duplicate: %s", ErrInvalidConflictPolicy, options.OnDuplicate)
+	}
+	return nil
+}
+
+type importAction int
+
+const (
+	// importActionCreate creates a new image
+	importActionCreate importAction = iota
+	// importActionReplace overwrites an existing image
+	importActionReplace
+	// importActionLinkTags only adds tags onto an existing image
+	importActionLinkTags
+)
+
+// resolveConflicts applies the policies to the images in order, so that the
+// first of the duplicates in an import is the one imported.
+func (batchValidator batchImportImageValidator) resolveConflicts(
+	importImages []importImage,
+	hasNameConflict []bool,
+	destinationDirectory image.Directory,
+	options ImportOptions,
+) ([]importImage, error) {
+	contentHashes := make([]string, 0, len(importImages))
+	for _, importImage := range importImages {
+		if importImage.image.ContentHash != "" {
+			contentHashes = append(contentHashes, importImage.image.ContentHash)
+		}
+	}
+	existingImages, err := batchValidator.dbClient.File().FindImageFilesByContentHashes(contentHashes)
+	if err != nil {
+		return nil, fmt.Errorf("File.FindImageFilesByContentHashes: %w", err)
+	}
+	// the oldest image is the original, unless another one is in the
+	// destination directory
+	existingImageByHash := make(map[string]db.File)
+	for _, existingImage := range existingImages {
+		original, ok := existingImageByHash[existingImage.ContentHash]
+		if ok && (original.ParentID == destinationDirectory.ID || existingImage.ParentID != destinationDirectory.ID) {
+			continue
+		}
+		existingImageByHash[existingImage.ContentHash] = existingImage
+	}
+
+	result := make([]importImage, 0, len(importImages))
+	isHashImported := make(map[string]bool)
+	isNameUsed := make(map[string]bool)
+	for index, importImage := range importImages {
+		if importImage.sourceFilePath == "" {
+			continue
+		}
+		sourceFilePath := importImage.sourceFilePath
+		contentHash := importImage.image.ContentHash
+
+		existingImage, isDuplicate := existingImageByHash[contentHash]
+		if contentHash != "" && (isDuplicate || isHashImported[contentHash]) {
+			switch options.OnDuplicate {
+			case ConflictPolicyFail:
+				original := "another image in the import"
+				if isDuplicate {
+					original = fmt.Sprintf("the image %d, %s", existingImage.ID, existingImage.Name)
+				}
+				batchValidator.progressNotifier.addFailure(sourceFilePath,
+					fmt.Errorf("%w: %s is the same as %s", ErrDuplicateImage, sourceFilePath, original),
+				)
+				continue
+			case ConflictPolicySkip:
+				batchValidator.progressNotifier.addSkip()
+				continue
+			case ConflictPolicyLinkTags:
+				importImage.action = importActionLinkTags
+				// the ID of another image in the import is set once it's created
+				importImage.existingFileID = existingImage.ID
+				result = append(result, importImage)
+				continue
+			}
+		}
+
+		name := importImage.image.Name
+		if hasNameConflict[index] || isNameUsed[name] {
+			destinationFilePath := filepath.Join(destinationDirectory.Path, name)
+			switch options.OnNameConflict {
+			case ConflictPolicySkip:
+				batchValidator.progressNotifier.addSkip()
+				continue
+			case ConflictPolicyRename:
+				newName, err := batchValidator.findFreeName(name, destinationDirectory, isNameUsed)
+				if err != nil {
+					batchValidator.progressNotifier.addFailure(sourceFilePath, fmt.Errorf("findFreeName: %w", err))
+					continue
+				}
+				importImage.image.Name = newName
+			case ConflictPolicyReplace, ConflictPolicyLinkTags:
+				if isNameUsed[name] {
+					batchValidator.progressNotifier.addFailure(sourceFilePath,
+						fmt.Errorf("%w: %s in the import", image.ErrFileAlreadyExists, destinationFilePath),
+					)
+					continue
+				}
+				record, err := db.FindByValue(batchValidator.dbClient, &db.File{
+					Name:     name,
+					ParentID: destinationDirectory.ID,
+				})
+				if err != nil && !errors.Is(err, db.ErrRecordNotFound) {
+					return nil, fmt.Errorf("db.FindByValue: %w", err)
+				}
+				if record.ID == 0 && options.OnNameConflict == ConflictPolicyReplace {
+					// a file only on disk is overwritten by a new image
+					break
+				}
+				if record.ID == 0 || record.Type != db.FileTypeImage {
+					batchValidator.progressNotifier.addFailure(sourceFilePath,
+						fmt.Errorf("%w: %s is not an image in DB", image.ErrFileAlreadyExists, destinationFilePath),
+					)
+					continue
+				}
+				importImage.existingFileID = record.ID
+				if options.OnNameConflict == ConflictPolicyReplace {
+					importImage.action = importActionReplace
+				} else {
+					importImage.action = importActionLinkTags
+					result = append(result, importImage)
+					continue
+				}
+			default:
+				batchValidator.progressNotifier.addFailure(sourceFilePath,
+					fmt.Errorf("%w: %s", image.ErrFileAlreadyExists, destinationFilePath),
+				)
+				continue
+			}
+		}
+
+		isNameUsed[importImage.image.Name] = true
+		if contentHash != "" {
+			isHashImported[contentHash] = true
+		}
+		result = append(result, importImage)
+	}
+	return result, nil
+}
+
+// findFreeName returns a name like "image (1).jpg", which isn't taken in the
+// directory or by another image in the import.
+func (batchValidator batchImportImageValidator) findFreeName(
+	name string,
+	destinationDirectory image.Directory,
+	isNameUsed map[string]bool,
+) (string, error) {
+	extension := filepath.Ext(name)
+	baseName := strings.TrimSuffix(name, extension)
+	for i := 1; i <= maxRenameAttempts; i++ {
+		newName := fmt.Sprintf("%s (%d)%s", baseName, i, extension)
+		if isNameUsed[newName] {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(destinationDirectory.Path, newName)); err == nil {
+			continue
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("os.Stat: %w", err)
+		}
+		record, err := db.FindByValue(batchValidator.dbClient, &db.File{
+			Name:     newName,
+			ParentID: destinationDirectory.ID,
+		})
+		if err != nil && !errors.Is(err, db.ErrRecordNotFound) {
+			return "", fmt.Errorf("db.FindByValue: %w", err)
+		}
+		if record.ID != 0 {
+			continue
+		}
+		return newName, nil
+	}
+	return "", fmt.Errorf("%w: no free name for %s", image.ErrFileAlreadyExists, name)
+}
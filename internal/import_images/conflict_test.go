@@ -0,0 +1,310 @@
+package import_images
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/michael-freling/anime-image-viewer/internal/tag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchImageImporter_ImportImages_conflicts(t *testing.T) {
+	jpegHash, err := image.ComputeFileHash(filepath.Join("..", "..", "testdata", string(image.TestImageFileJpeg)))
+	require.NoError(t, err)
+	pngHash, err := image.ComputeFileHash(filepath.Join("..", "..", "testdata", string(image.TestImageFilePng)))
+	require.NoError(t, err)
+
+	type wantFile struct {
+		ID          uint
+		Name        string
+		ContentHash string
+	}
+	testCases := []struct {
+		name        string
+		sourceNames []string
+		options     ImportOptions
+
+		wantFiles     []wantFile
+		wantFileTags  map[uint]int
+		wantCompleted int
+		wantSkipped   int
+		wantErrors    []error
+	}{
+		{
+			name:        "fail a name conflict by default",
+			sourceNames: []string{"taken.png"},
+			wantFiles: []wantFile{
+				{ID: 10, Name: "taken.png", ContentHash: "old"},
+				{ID: 11, Name: "original.jpg", ContentHash: jpegHash},
+			},
+			wantErrors: []error{image.ErrFileAlreadyExists},
+		},
+		{
+			name:        "skip a name conflict",
+			sourceNames: []string{"taken.png"},
+			options:     ImportOptions{OnNameConflict: ConflictPolicySkip},
+			wantFiles: []wantFile{
+				{ID: 10, Name: "taken.png", ContentHash: "old"},
+				{ID: 11, Name: "original.jpg", ContentHash: jpegHash},
+			},
+			wantSkipped: 1,
+		},
+		{
+			name:        "rename an image on a name conflict",
+			sourceNames: []string{"taken.png"},
+			options:     ImportOptions{OnNameConflict: ConflictPolicyRename},
+			wantFiles: []wantFile{
+				{ID: 10, Name: "taken.png", ContentHash: "old"},
+				{ID: 11, Name: "original.jpg", ContentHash: jpegHash},
+				{ID: 12, Name: "taken (1).png", ContentHash: pngHash},
+			},
+			wantFileTags:  map[uint]int{10: 1, 12: 2},
+			wantCompleted: 1,
+		},
+		{
+			name:        "replace an image on a name conflict",
+			sourceNames: []string{"taken.png"},
+			options:     ImportOptions{OnNameConflict: ConflictPolicyReplace},
+			wantFiles: []wantFile{
+				{ID: 10, Name: "taken.png", ContentHash: pngHash},
+				{ID: 11, Name: "original.jpg", ContentHash: jpegHash},
+			},
+			wantFileTags:  map[uint]int{10: 3},
+			wantCompleted: 1,
+		},
+		{
+			name:        "link tags onto an image on a name conflict",
+			sourceNames: []string{"taken.png"},
+			options:     ImportOptions{OnNameConflict: ConflictPolicyLinkTags},
+			wantFiles: []wantFile{
+				{ID: 10, Name: "taken.png", ContentHash: "old"},
+				{ID: 11, Name: "original.jpg", ContentHash: jpegHash},
+			},
+			wantFileTags:  map[uint]int{10: 3},
+			wantCompleted: 1,
+		},
+		{
+			name:        "import a duplicate as another image by default",
+			sourceNames: []string{"copy.jpg"},
+			wantFiles: []wantFile{
+				{ID: 10, Name: "taken.png", ContentHash: "old"},
+				{ID: 11, Name: "original.jpg", ContentHash: jpegHash},
+				{ID: 12, Name: "copy.jpg", ContentHash: jpegHash},
+			},
+			wantFileTags:  map[uint]int{10: 1, 12: 1},
+			wantCompleted: 1,
+		},
+		{
+			name:        "fail a duplicate",
+			sourceNames: []string{"copy.jpg"},
+			options:     ImportOptions{OnDuplicate: ConflictPolicyFail},
+			wantFiles: []wantFile{
+				{ID: 10, Name: "taken.png", ContentHash: "old"},
+				{ID: 11, Name: "original.jpg", ContentHash: jpegHash},
+			},
+			wantFileTags: map[uint]int{10: 1},
+			wantErrors:   []error{ErrDuplicateImage},
+		},
+		{
+			name:        "skip duplicates in the library and in the import",
+			sourceNames: []string{"copy.jpg", "taken.png", "copy.png"},
+			options:     ImportOptions{OnNameConflict: ConflictPolicyRename, OnDuplicate: ConflictPolicySkip},
+			wantFiles: []wantFile{
+				{ID: 10, Name: "taken.png", ContentHash: "old"},
+				{ID: 11, Name: "original.jpg", ContentHash: jpegHash},
+				{ID: 12, Name: "taken (1).png", ContentHash: pngHash},
+			},
+			wantFileTags:  map[uint]int{10: 1, 12: 2},
+			wantCompleted: 1,
+			wantSkipped:   2,
+		},
+		{
+			name:        "link tags of a duplicate onto the image in the library",
+			sourceNames: []string{"copy.jpg"},
+			options:     ImportOptions{OnDuplicate: ConflictPolicyLinkTags},
+			wantFiles: []wantFile{
+				{ID: 10, Name: "taken.png", ContentHash: "old"},
+				{ID: 11, Name: "original.jpg", ContentHash: jpegHash},
+			},
+			wantFileTags:  map[uint]int{10: 1, 11: 1},
+			wantCompleted: 1,
+		},
+		{
+			name:        "link tags of a duplicate onto an image in the same import",
+			sourceNames: []string{"taken.png", "copy.png"},
+			options:     ImportOptions{OnNameConflict: ConflictPolicyRename, OnDuplicate: ConflictPolicyLinkTags},
+			wantFiles: []wantFile{
+				{ID: 10, Name: "taken.png", ContentHash: "old"},
+				{ID: 11, Name: "original.jpg", ContentHash: jpegHash},
+				{ID: 12, Name: "taken (1).png", ContentHash: pngHash},
+			},
+			wantFileTags:  map[uint]int{10: 1, 12: 2},
+			wantCompleted: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tester := newTester(t)
+			fileBuilder := tester.newFileCreator(t).
+				CreateDirectory(image.Directory{ID: 1, Name: "destination"}).
+				CreateImage(image.ImageFile{ID: 10, Name: "taken.png", ParentID: 1}, image.TestImageFilePng).
+				CreateImage(image.ImageFile{ID: 11, Name: "original.jpg", ParentID: 1}, image.TestImageFileJpeg).
+				CreateDirectory(image.Directory{ID: 2, Name: "source"}).
+				CreateImage(image.ImageFile{ID: 20, Name: "taken.png", ParentID: 2}, image.TestImageFilePng).
+				CreateImage(image.ImageFile{ID: 21, Name: "copy.jpg", ParentID: 2}, image.TestImageFileJpeg).
+				CreateImage(image.ImageFile{ID: 22, Name: "copy.png", ParentID: 2}, image.TestImageFilePng)
+			sourceDirectory := fileBuilder.BuildDirectory(2)
+			tester.copyXMPFile(t, image.TestImageFilePng, filepath.Join(sourceDirectory.Path, "taken.png.xmp"))
+			tester.copyXMPFile(t, image.TestImageFileJpeg, filepath.Join(sourceDirectory.Path, "copy.jpg.xmp"))
+
+			tester.dbClient.Truncate(t, db.File{}, db.Tag{}, db.FileTag{})
+			db.LoadTestData(t, tester.dbClient, []db.File{
+				{ID: 1, Name: "destination", Type: db.FileTypeDirectory},
+				{ID: 10, Name: "taken.png", ParentID: 1, Type: db.FileTypeImage, ContentHash: "old"},
+				{ID: 11, Name: "original.jpg", ParentID: 1, Type: db.FileTypeImage, ContentHash: jpegHash},
+			})
+			db.LoadTestData(t, tester.dbClient, []db.Tag{{ID: 1, Name: "Existing"}})
+			db.LoadTestData(t, tester.dbClient, []db.FileTag{{FileID: 10, TagID: 1, AddedBy: db.FileTagAddedByUser}})
+
+			sourceFilePaths := make([]string, len(tc.sourceNames))
+			for i, name := range tc.sourceNames {
+				sourceFilePaths[i] = filepath.Join(sourceDirectory.Path, name)
+			}
+			progressNotifier := NewProgressNotifier()
+			_, gotErr := tester.getBatchImageImporter().ImportImages(
+				context.Background(),
+				fileBuilder.BuildDirectory(1),
+				sourceFilePaths,
+				tc.options,
+				progressNotifier,
+			)
+			if len(tc.wantErrors) > 0 {
+				for _, wantErr := range tc.wantErrors {
+					assert.ErrorIs(t, gotErr, wantErr)
+				}
+			} else {
+				assert.NoError(t, gotErr)
+			}
+
+			gotFiles := make([]wantFile, 0)
+			for _, file := range db.MustGetAll[db.File](t, tester.dbClient) {
+				if file.Type != db.FileTypeImage {
+					continue
+				}
+				gotFiles = append(gotFiles, wantFile{ID: file.ID, Name: file.Name, ContentHash: file.ContentHash})
+				assert.FileExists(t, filepath.Join(fileBuilder.BuildDirectory(1).Path, file.Name))
+			}
+			assert.Equal(t, tc.wantFiles, gotFiles)
+			entries, err := os.ReadDir(fileBuilder.BuildDirectory(1).Path)
+			require.NoError(t, err)
+			for _, entry := range entries {
+				assert.NotContains(t, entry.Name(), ".tmp", "a staged copy is left")
+			}
+
+			gotFileTags := make(map[uint]int)
+			for _, fileTag := range db.MustGetAll[db.FileTag](t, tester.dbClient) {
+				gotFileTags[fileTag.FileID]++
+			}
+			wantFileTags := tc.wantFileTags
+			if wantFileTags == nil {
+				wantFileTags = map[uint]int{10: 1}
+			}
+			assert.Equal(t, wantFileTags, gotFileTags)
+
+			assert.Equal(t, tc.wantCompleted, progressNotifier.Completed)
+			assert.Equal(t, tc.wantSkipped, progressNotifier.Skipped)
+			assert.Equal(t, len(tc.wantErrors), progressNotifier.Failed)
+		})
+	}
+
+	t.Run("an image failing to be replaced is kept", func(t *testing.T) {
+		destinationDirectory := image.Directory{Path: t.TempDir()}
+		originalFilePath := filepath.Join(destinationDirectory.Path, "taken.png")
+		require.NoError(t, os.WriteFile(originalFilePath, []byte("original"), 0644))
+
+		progressNotifier := NewProgressNotifier()
+		got := replaceStagedImages([]importImage{
+			{
+				image:          db.File{Name: "taken.png"},
+				sourceFilePath: "source/taken.png",
+				action:         importActionReplace,
+				stagedFilePath: filepath.Join(destinationDirectory.Path, ".taken.png.missing.tmp"),
+			},
+			{image: db.File{Name: "new.png"}, action: importActionCreate},
+		}, destinationDirectory, progressNotifier)
+		require.Len(t, got, 1)
+		assert.Equal(t, "new.png", got[0].image.Name)
+		assert.Equal(t, 1, progressNotifier.Failed)
+
+		content, err := os.ReadFile(originalFilePath)
+		require.NoError(t, err)
+		assert.Equal(t, "original", string(content))
+	})
+
+	t.Run("a replaced image is restored if the import fails after it is replaced", func(t *testing.T) {
+		tester := newTester(t)
+		fileBuilder := tester.newFileCreator(t).
+			CreateDirectory(image.Directory{ID: 1, Name: "destination"}).
+			CreateImage(image.ImageFile{ID: 10, Name: "taken.png", ParentID: 1}, image.TestImageFileJpeg).
+			CreateDirectory(image.Directory{ID: 2, Name: "source"}).
+			CreateImage(image.ImageFile{ID: 20, Name: "taken.png", ParentID: 2}, image.TestImageFilePng)
+		destinationDirectory := fileBuilder.BuildDirectory(1)
+		originalFilePath := filepath.Join(destinationDirectory.Path, "taken.png")
+		original, err := os.ReadFile(originalFilePath)
+		require.NoError(t, err)
+
+		tester.dbClient.Truncate(t, db.File{}, db.Tag{}, db.FileTag{})
+		db.LoadTestData(t, tester.dbClient, []db.File{
+			{ID: 1, Name: "destination", Type: db.FileTypeDirectory},
+			{ID: 10, Name: "taken.png", ParentID: 1, Type: db.FileTypeImage, ContentHash: "old"},
+		})
+
+		// tags are imported after the image is replaced, and fail with a
+		// database without tables
+		emptyClient, err := db.NewClient(db.DSNFromFilePath(t.TempDir(), "empty.sqlite"), db.WithNopLogger())
+		require.NoError(t, err)
+		batchImporter := NewBatchImageImporter(
+			tester.logger,
+			tester.dbClient.Client,
+			tester.getImageFileConverter(),
+			tag.NewReader(emptyClient, tester.getDirectoryReader()),
+			nil,
+		)
+		_, gotErr := batchImporter.ImportImages(
+			context.Background(),
+			destinationDirectory,
+			[]string{filepath.Join(fileBuilder.BuildDirectory(2).Path, "taken.png")},
+			ImportOptions{OnNameConflict: ConflictPolicyReplace},
+			NewProgressNotifier(),
+		)
+		require.Error(t, gotErr)
+
+		got, err := os.ReadFile(originalFilePath)
+		require.NoError(t, err)
+		assert.Equal(t, original, got, "the original image is restored")
+		entries, err := os.ReadDir(destinationDirectory.Path)
+		require.NoError(t, err)
+		require.Len(t, entries, 1, "neither the copy nor the original is left")
+		files := db.MustGetAll[db.File](t, tester.dbClient)
+		require.Len(t, files, 2)
+		assert.Equal(t, "old", files[1].ContentHash)
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		tester := newTester(t)
+		_, gotErr := tester.getBatchImageImporter().ImportImages(
+			context.Background(),
+			image.Directory{ID: 1},
+			nil,
+			ImportOptions{OnDuplicate: ConflictPolicyRename},
+			NewProgressNotifier(),
+		)
+		assert.ErrorIs(t, gotErr, ErrInvalidConflictPolicy)
+	})
+}
@@ -0,0 +1,397 @@
+package import_images
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// embeddedMetadata is the metadata inside an image file: an XMP packet, IPTC
+// keywords and a caption, and the EXIF date the image was taken.
+type embeddedMetadata struct {
+	xmp              *XMP
+	iptcKeywords     []string
+	iptcCaption      string
+	dateTimeOriginal time.Time
+}
+
+var (
+	jpegXMPHeader   = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	jpegExifHeader  = []byte("Exif\x00\x00")
+	photoshopHeader = []byte("Photoshop 3.0\x00")
+	pngSignature    = []byte("\x89PNG\r\n\x1a\n")
+
+	errMalformedMetadata = errors.New("malformed metadata")
+)
+
+const (
+	pngXMPKeyword = "XML:com.adobe.xmp"
+	// pngMaxMetadataLength bounds the chunks with metadata and their
+	// decompressed text. A larger chunk is skipped without being read.
+	pngMaxMetadataLength = 16 << 20
+
+	photoshopIPTCResourceID = 0x0404
+
+	iptcRecordApplication = 2
+	iptcDatasetKeywords   = 25
+	iptcDatasetCaption    = 120
+
+	exifTagExifIFD            = 0x8769
+	exifTagDateTimeOriginal   = 0x9003
+	exifTagOffsetTimeOriginal = 0x9011
+	exifDateTimeLayout        = "2006:01:02 15:04:05"
+	exifTypeASCII             = 2
+	exifTypeLong              = 4
+	exifIFDEntrySize          = 12
+	exifMaxIFDEntries         = 1000
+)
+
+// readEmbeddedMetadata reads the metadata of a JPEG or PNG image. Metadata
+// that cannot be parsed is skipped rather than failing the import.
+func readEmbeddedMetadata(filePath string) (embeddedMetadata, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return embeddedMetadata{}, fmt.Errorf("os.Open: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	header, err := reader.Peek(len(pngSignature))
+	if err != nil {
+		return embeddedMetadata{}, nil
+	}
+	if bytes.HasPrefix(header, []byte{0xFF, 0xD8}) {
+		return readJPEGMetadata(reader)
+	}
+	if bytes.Equal(header, pngSignature) {
+		return readPNGMetadata(reader)
+	}
+	return embeddedMetadata{}, nil
+}
+
+// readJPEGMetadata reads the APP1 segments with EXIF and XMP, and the APP13
+// segment with IPTC, until the image data starts.
+func readJPEGMetadata(reader *bufio.Reader) (embeddedMetadata, error) {
+	var result embeddedMetadata
+	if _, err := reader.Discard(2); err != nil {
+		return result, fmt.Errorf("reader.Discard: %w", err)
+	}
+	for {
+		marker, err := readJPEGMarker(reader)
+		if err != nil {
+			return result, err
+		}
+		switch {
+		case marker == 0xD9 || marker == 0xDA:
+			// the end of the image, or the start of the image data
+			return result, nil
+		case marker == 0x01 || (0xD0 <= marker && marker <= 0xD7):
+			// markers without a segment
+			continue
+		}
+
+		var length uint16
+		if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+			return result, fmt.Errorf("binary.Read: %w", err)
+		}
+		if length < 2 {
+			return result, fmt.Errorf("%w: JPEG segment length %d", errMalformedMetadata, length)
+		}
+		segment := make([]byte, length-2)
+		if _, err := io.ReadFull(reader, segment); err != nil {
+			return result, fmt.Errorf("io.ReadFull: %w", err)
+		}
+
+		switch {
+		case marker == 0xE1 && bytes.HasPrefix(segment, jpegXMPHeader):
+			if xmp, err := parseXMP(bytes.TrimPrefix(segment, jpegXMPHeader)); err == nil {
+				result.xmp = xmp
+			}
+		case marker == 0xE1 && bytes.HasPrefix(segment, jpegExifHeader):
+			result.dateTimeOriginal = parseExifDateTimeOriginal(bytes.TrimPrefix(segment, jpegExifHeader))
+		case marker == 0xED && bytes.HasPrefix(segment, photoshopHeader):
+			iptc := findPhotoshopResource(bytes.TrimPrefix(segment, photoshopHeader), photoshopIPTCResourceID)
+			result.iptcKeywords, result.iptcCaption = parseIPTC(iptc)
+		}
+	}
+}
+
+func readJPEGMarker(reader *bufio.Reader) (byte, error) {
+	prefix, err := reader.ReadByte()
+	if err != nil {
+		return 0, fmt.Errorf("reader.ReadByte: %w", err)
+	}
+	if prefix != 0xFF {
+		return 0, fmt.Errorf("%w: JPEG marker 0x%02X", errMalformedMetadata, prefix)
+	}
+	for {
+		marker, err := reader.ReadByte()
+		if err != nil {
+			return 0, fmt.Errorf("reader.ReadByte: %w", err)
+		}
+		// 0xFF can be repeated as a fill byte
+		if marker != 0xFF {
+			return marker, nil
+		}
+	}
+}
+
+// readPNGMetadata reads the iTXt chunk with XMP and the eXIf chunk, which can
+// be either before or after the image data.
+func readPNGMetadata(reader *bufio.Reader) (embeddedMetadata, error) {
+	var result embeddedMetadata
+	if _, err := reader.Discard(len(pngSignature)); err != nil {
+		return result, fmt.Errorf("reader.Discard: %w", err)
+	}
+	for {
+		var header struct {
+			Length    uint32
+			ChunkType [4]byte
+		}
+		if err := binary.Read(reader, binary.BigEndian, &header); err != nil {
+			return result, fmt.Errorf("binary.Read: %w", err)
+		}
+		chunkType := string(header.ChunkType[:])
+		if chunkType == "IEND" {
+			return result, nil
+		}
+		if (chunkType != "iTXt" && chunkType != "eXIf") || header.Length > pngMaxMetadataLength {
+			// skip the data and the CRC
+			if _, err := reader.Discard(int(header.Length) + 4); err != nil {
+				return result, fmt.Errorf("reader.Discard: %w", err)
+			}
+			continue
+		}
+
+		// the length can be larger than the rest of a truncated file
+		data, err := io.ReadAll(io.LimitReader(reader, int64(header.Length)))
+		if err != nil {
+			return result, fmt.Errorf("io.ReadAll: %w", err)
+		}
+		if len(data) != int(header.Length) {
+			return result, fmt.Errorf("%w: %s chunk: %w", errMalformedMetadata, chunkType, io.ErrUnexpectedEOF)
+		}
+		if _, err := reader.Discard(4); err != nil {
+			return result, fmt.Errorf("reader.Discard: %w", err)
+		}
+		if chunkType == "eXIf" {
+			result.dateTimeOriginal = parseExifDateTimeOriginal(data)
+			continue
+		}
+		if text, ok := parsePNGInternationalText(data, pngXMPKeyword); ok {
+			if xmp, err := parseXMP(text); err == nil {
+				result.xmp = xmp
+			}
+		}
+	}
+}
+
+// parsePNGInternationalText returns the text of an iTXt chunk with the
+// keyword.
+func parsePNGInternationalText(data []byte, keyword string) ([]byte, bool) {
+	fields := bytes.SplitN(data, []byte{0}, 2)
+	if len(fields) != 2 || string(fields[0]) != keyword || len(fields[1]) < 2 {
+		return nil, false
+	}
+	isCompressed := fields[1][0] == 1
+	// skip the compression method, the language tag and the translated keyword
+	rest := bytes.SplitN(fields[1][2:], []byte{0}, 3)
+	if len(rest) != 3 {
+		return nil, false
+	}
+	text := rest[2]
+	if !isCompressed {
+		return text, true
+	}
+	zlibReader, err := zlib.NewReader(bytes.NewReader(text))
+	if err != nil {
+		return nil, false
+	}
+	defer zlibReader.Close()
+	text, err = io.ReadAll(io.LimitReader(zlibReader, pngMaxMetadataLength+1))
+	if err != nil || len(text) > pngMaxMetadataLength {
+		return nil, false
+	}
+	return text, true
+}
+
+// findPhotoshopResource returns the data of an image resource block, "8BIM",
+// in a Photoshop APP13 segment.
+func findPhotoshopResource(data []byte, resourceID uint16) []byte {
+	for len(data) >= 12 && bytes.HasPrefix(data, []byte("8BIM")) {
+		id := binary.BigEndian.Uint16(data[4:6])
+		// the name is a Pascal string padded to an even length
+		nameLength := int(data[6]) + 1
+		nameLength += nameLength % 2
+		offset := 6 + nameLength
+		if len(data) < offset+4 {
+			return nil
+		}
+		size := int(binary.BigEndian.Uint32(data[offset : offset+4]))
+		offset += 4
+		if size < 0 || len(data) < offset+size {
+			return nil
+		}
+		if id == resourceID {
+			return data[offset : offset+size]
+		}
+		offset += size + size%2
+		if len(data) < offset {
+			return nil
+		}
+		data = data[offset:]
+	}
+	return nil
+}
+
+// parseIPTC returns the keywords and the caption of IPTC-IIM data sets.
+func parseIPTC(data []byte) ([]string, string) {
+	keywords := make([]string, 0)
+	caption := ""
+	for len(data) >= 5 && data[0] == 0x1C {
+		record, dataset := data[1], data[2]
+		size := int(binary.BigEndian.Uint16(data[3:5]))
+		offset := 5
+		if size&0x8000 != 0 {
+			// an extended data set, whose size is in the next bytes
+			sizeLength := size & 0x7FFF
+			if sizeLength > 4 || len(data) < offset+sizeLength {
+				break
+			}
+			size = 0
+			for _, b := range data[offset : offset+sizeLength] {
+				size = size<<8 | int(b)
+			}
+			offset += sizeLength
+		}
+		if len(data) < offset+size {
+			break
+		}
+		value := decodeIPTCString(data[offset : offset+size])
+		if record == iptcRecordApplication {
+			switch dataset {
+			case iptcDatasetKeywords:
+				keywords = append(keywords, value)
+			case iptcDatasetCaption:
+				caption = value
+			}
+		}
+		data = data[offset+size:]
+	}
+	return keywords, caption
+}
+
+// decodeIPTCString decodes a value as UTF-8, or as Latin-1 written by older
+// tools if it isn't valid UTF-8.
+func decodeIPTCString(value []byte) string {
+	if utf8.Valid(value) {
+		return strings.TrimSpace(string(value))
+	}
+	runes := make([]rune, len(value))
+	for i, b := range value {
+		runes[i] = rune(b)
+	}
+	return strings.TrimSpace(string(runes))
+}
+
+// parseExifDateTimeOriginal returns DateTimeOriginal of EXIF data in the TIFF
+// layout, or the zero time if it isn't found. The time is in the offset the
+// camera recorded, or in local time if it recorded none.
+func parseExifDateTimeOriginal(data []byte) time.Time {
+	if len(data) < 8 {
+		return time.Time{}
+	}
+	var byteOrder binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		byteOrder = binary.LittleEndian
+	case "MM":
+		byteOrder = binary.BigEndian
+	default:
+		return time.Time{}
+	}
+	if byteOrder.Uint16(data[2:4]) != 42 {
+		return time.Time{}
+	}
+
+	ifd0 := readExifIFD(data, byteOrder, byteOrder.Uint32(data[4:8]))
+	exifIFDOffset, ok := ifd0[exifTagExifIFD]
+	if !ok || exifIFDOffset.valueType != exifTypeLong {
+		return time.Time{}
+	}
+	exifIFD := readExifIFD(data, byteOrder, byteOrder.Uint32(exifIFDOffset.value))
+	dateTimeOriginal, ok := exifIFD[exifTagDateTimeOriginal]
+	if !ok {
+		return time.Time{}
+	}
+	value := readExifASCII(data, byteOrder, dateTimeOriginal)
+
+	location := time.Local
+	if offsetTime, ok := exifIFD[exifTagOffsetTimeOriginal]; ok {
+		if offset, err := time.Parse("-07:00", readExifASCII(data, byteOrder, offsetTime)); err == nil {
+			location = offset.Location()
+		}
+	}
+	result, err := time.ParseInLocation(exifDateTimeLayout, value, location)
+	if err != nil {
+		return time.Time{}
+	}
+	return result
+}
+
+type exifIFDEntry struct {
+	valueType uint16
+	count     uint32
+	// value is the value itself if it fits in 4 bytes, or its offset
+	value []byte
+}
+
+func readExifIFD(data []byte, byteOrder binary.ByteOrder, offset uint32) map[uint16]exifIFDEntry {
+	result := make(map[uint16]exifIFDEntry)
+	if uint64(offset)+2 > uint64(len(data)) {
+		return result
+	}
+	count := int(byteOrder.Uint16(data[offset : offset+2]))
+	if count > exifMaxIFDEntries {
+		return result
+	}
+	start := int(offset) + 2
+	for i := 0; i < count; i++ {
+		entryOffset := start + i*exifIFDEntrySize
+		if entryOffset+exifIFDEntrySize > len(data) {
+			break
+		}
+		entry := data[entryOffset : entryOffset+exifIFDEntrySize]
+		result[byteOrder.Uint16(entry[0:2])] = exifIFDEntry{
+			valueType: byteOrder.Uint16(entry[2:4]),
+			count:     byteOrder.Uint32(entry[4:8]),
+			value:     entry[8:12],
+		}
+	}
+	return result
+}
+
+func readExifASCII(data []byte, byteOrder binary.ByteOrder, entry exifIFDEntry) string {
+	if entry.valueType != exifTypeASCII {
+		return ""
+	}
+	var value []byte
+	if entry.count <= 4 {
+		value = entry.value[:entry.count]
+	} else {
+		offset := uint64(byteOrder.Uint32(entry.value))
+		if offset+uint64(entry.count) > uint64(len(data)) {
+			return ""
+		}
+		value = data[offset : offset+uint64(entry.count)]
+	}
+	return strings.TrimSpace(string(bytes.TrimRight(value, "\x00")))
+}
@@ -0,0 +1,271 @@
+package import_images
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testEmbeddedXMP = `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+    xmlns:digiKam="http://www.digikam.org/ns/1.0/"
+    xmp:Rating="4">
+   <digiKam:TagsList>
+    <rdf:Seq>
+     <rdf:li>scene/night</rdf:li>
+    </rdf:Seq>
+   </digiKam:TagsList>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`
+
+// buildTestExif builds EXIF data in the TIFF layout with DateTimeOriginal
+// and OffsetTimeOriginal in the Exif IFD.
+func buildTestExif(byteOrder binary.ByteOrder, dateTimeOriginal, offsetTimeOriginal string) []byte {
+	const (
+		ifd0Offset    = 8
+		exifIFDOffset = ifd0Offset + 2 + exifIFDEntrySize + 4
+		valuesOffset  = exifIFDOffset + 2 + 2*exifIFDEntrySize + 4
+	)
+	dateTimeValue := append([]byte(dateTimeOriginal), 0)
+	offsetTimeValue := append([]byte(offsetTimeOriginal), 0)
+
+	var buffer bytes.Buffer
+	if byteOrder == binary.LittleEndian {
+		buffer.WriteString("II")
+	} else {
+		buffer.WriteString("MM")
+	}
+	write := func(values ...any) {
+		for _, value := range values {
+			_ = binary.Write(&buffer, byteOrder, value)
+		}
+	}
+	write(uint16(42))
+	write(uint32(ifd0Offset))
+
+	write(uint16(1))
+	write(uint16(exifTagExifIFD), uint16(exifTypeLong), uint32(1), uint32(exifIFDOffset))
+	write(uint32(0))
+
+	write(uint16(2))
+	write(uint16(exifTagDateTimeOriginal), uint16(exifTypeASCII), uint32(len(dateTimeValue)), uint32(valuesOffset))
+	write(uint16(exifTagOffsetTimeOriginal), uint16(exifTypeASCII), uint32(len(offsetTimeValue)), uint32(valuesOffset+len(dateTimeValue)))
+	write(uint32(0))
+
+	buffer.Write(dateTimeValue)
+	buffer.Write(offsetTimeValue)
+	return buffer.Bytes()
+}
+
+func buildTestIPTC(keywords []string, caption string) []byte {
+	var iptc bytes.Buffer
+	writeDataset := func(dataset byte, value string) {
+		iptc.Write([]byte{0x1C, iptcRecordApplication, dataset})
+		_ = binary.Write(&iptc, binary.BigEndian, uint16(len(value)))
+		iptc.WriteString(value)
+	}
+	for _, keyword := range keywords {
+		writeDataset(iptcDatasetKeywords, keyword)
+	}
+	writeDataset(iptcDatasetCaption, caption)
+
+	var result bytes.Buffer
+	result.Write(photoshopHeader)
+	// an unrelated resource before the IPTC one
+	result.WriteString("8BIM")
+	_ = binary.Write(&result, binary.BigEndian, uint16(0x03ED))
+	result.Write([]byte{0, 0})
+	_ = binary.Write(&result, binary.BigEndian, uint32(3))
+	result.Write([]byte{1, 2, 3, 0})
+
+	result.WriteString("8BIM")
+	_ = binary.Write(&result, binary.BigEndian, uint16(photoshopIPTCResourceID))
+	result.Write([]byte{0, 0})
+	_ = binary.Write(&result, binary.BigEndian, uint32(iptc.Len()))
+	result.Write(iptc.Bytes())
+	return result.Bytes()
+}
+
+func buildTestJPEG(segments map[byte][][]byte) []byte {
+	var buffer bytes.Buffer
+	buffer.Write([]byte{0xFF, 0xD8})
+	for _, marker := range []byte{0xE1, 0xED} {
+		for _, segment := range segments[marker] {
+			buffer.Write([]byte{0xFF, marker})
+			_ = binary.Write(&buffer, binary.BigEndian, uint16(len(segment)+2))
+			buffer.Write(segment)
+		}
+	}
+	// the start of the image data, which isn't read
+	buffer.Write([]byte{0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9})
+	return buffer.Bytes()
+}
+
+func buildTestPNG(chunks ...[]byte) []byte {
+	var buffer bytes.Buffer
+	buffer.Write(pngSignature)
+	writeChunk := func(chunkType string, data []byte) {
+		_ = binary.Write(&buffer, binary.BigEndian, uint32(len(data)))
+		buffer.WriteString(chunkType)
+		buffer.Write(data)
+		_ = binary.Write(&buffer, binary.BigEndian, crc32.ChecksumIEEE(append([]byte(chunkType), data...)))
+	}
+	writeChunk("IHDR", make([]byte, 13))
+	for i := 0; i+1 < len(chunks); i += 2 {
+		writeChunk(string(chunks[i]), chunks[i+1])
+	}
+	writeChunk("IEND", nil)
+	return buffer.Bytes()
+}
+
+func buildTestPNGInternationalText(keyword string, text string, isCompressed bool) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString(keyword)
+	buffer.WriteByte(0)
+	if !isCompressed {
+		buffer.Write([]byte{0, 0})
+		buffer.Write([]byte{0, 0})
+		buffer.WriteString(text)
+		return buffer.Bytes()
+	}
+
+	buffer.Write([]byte{1, 0})
+	buffer.Write([]byte{0, 0})
+	zlibWriter := zlib.NewWriter(&buffer)
+	_, _ = zlibWriter.Write([]byte(text))
+	_ = zlibWriter.Close()
+	return buffer.Bytes()
+}
+
+func TestReadEmbeddedMetadata(t *testing.T) {
+	wantXMP, err := parseXMP([]byte(testEmbeddedXMP))
+	require.NoError(t, err)
+	wantDateTimeOriginal := time.Date(2024, 3, 1, 12, 34, 56, 0, time.FixedZone("", 9*60*60))
+
+	testCases := []struct {
+		name      string
+		content   []byte
+		want      embeddedMetadata
+		wantError bool
+	}{
+		{
+			name: "a JPEG with XMP, IPTC and EXIF",
+			content: buildTestJPEG(map[byte][][]byte{
+				0xE1: {
+					append(append([]byte{}, jpegExifHeader...), buildTestExif(binary.BigEndian, "2024:03:01 12:34:56", "+09:00")...),
+					append(append([]byte{}, jpegXMPHeader...), testEmbeddedXMP...),
+				},
+				0xED: {
+					buildTestIPTC([]string{"night", "Frieren"}, " A night sky "),
+				},
+			}),
+			want: embeddedMetadata{
+				xmp:              wantXMP,
+				iptcKeywords:     []string{"night", "Frieren"},
+				iptcCaption:      "A night sky",
+				dateTimeOriginal: wantDateTimeOriginal,
+			},
+		},
+		{
+			name: "IPTC written in Latin-1",
+			content: buildTestJPEG(map[byte][][]byte{
+				0xED: {
+					buildTestIPTC([]string{"caf\xe9"}, ""),
+				},
+			}),
+			want: embeddedMetadata{
+				iptcKeywords: []string{"café"},
+			},
+		},
+		{
+			name: "a PNG with XMP and EXIF",
+			content: buildTestPNG(
+				[]byte("tEXt"), []byte("Comment\x00ignored"),
+				[]byte("iTXt"), buildTestPNGInternationalText(pngXMPKeyword, testEmbeddedXMP, false),
+				[]byte("eXIf"), buildTestExif(binary.LittleEndian, "2024:03:01 12:34:56", "+09:00"),
+			),
+			want: embeddedMetadata{
+				xmp:              wantXMP,
+				dateTimeOriginal: wantDateTimeOriginal,
+			},
+		},
+		{
+			name: "a PNG with compressed XMP",
+			content: buildTestPNG(
+				[]byte("iTXt"), buildTestPNGInternationalText("Description", "ignored", false),
+				[]byte("iTXt"), buildTestPNGInternationalText(pngXMPKeyword, testEmbeddedXMP, true),
+			),
+			want: embeddedMetadata{
+				xmp: wantXMP,
+			},
+		},
+		{
+			name: "invalid metadata is skipped",
+			content: buildTestJPEG(map[byte][][]byte{
+				0xE1: {
+					append(append([]byte{}, jpegExifHeader...), "MM\x00\x2a\xff\xff\xff\xff"...),
+					append(append([]byte{}, jpegXMPHeader...), "<x:xmpmeta"...),
+				},
+			}),
+		},
+		{
+			name: "a PNG with XMP decompressed beyond the limit",
+			content: buildTestPNG(
+				[]byte("iTXt"), buildTestPNGInternationalText(pngXMPKeyword, testEmbeddedXMP+strings.Repeat(" ", pngMaxMetadataLength), true),
+				[]byte("eXIf"), buildTestExif(binary.LittleEndian, "2024:03:01 12:34:56", "+09:00"),
+			),
+			want: embeddedMetadata{
+				dateTimeOriginal: wantDateTimeOriginal,
+			},
+		},
+		{
+			name: "a truncated PNG with a chunk longer than the file",
+			content: func() []byte {
+				content := buildTestPNG([]byte("iTXt"), buildTestPNGInternationalText(pngXMPKeyword, testEmbeddedXMP, false))
+				chunkLengthOffset := len(pngSignature) + 4 + 4 + 13 + 4
+				binary.BigEndian.PutUint32(content[chunkLengthOffset:], pngMaxMetadataLength)
+				return content
+			}(),
+			wantError: true,
+		},
+		{
+			name:    "not an image",
+			content: []byte("not an image"),
+		},
+		{
+			name: "a truncated JPEG",
+			content: buildTestJPEG(map[byte][][]byte{
+				0xE1: {jpegXMPHeader},
+			})[:10],
+			wantError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "image")
+			require.NoError(t, os.WriteFile(filePath, tc.content, 0644))
+
+			got, gotErr := readEmbeddedMetadata(filePath)
+			if tc.wantError {
+				assert.Error(t, gotErr)
+				return
+			}
+			require.NoError(t, gotErr)
+			assert.True(t, tc.want.dateTimeOriginal.Equal(got.dateTimeOriginal), "dateTimeOriginal: %v", got.dateTimeOriginal)
+			got.dateTimeOriginal = tc.want.dateTimeOriginal
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
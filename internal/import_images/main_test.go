@@ -59,6 +59,7 @@ func (tester Tester) getBatchImageImporter() *BatchImageImporter {
 		tester.dbClient.Client,
 		tester.getImageFileConverter(),
 		tester.getTagReader(),
+		nil,
 	)
 }
 
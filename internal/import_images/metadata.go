@@ -0,0 +1,187 @@
+package import_images
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// imageMetadata is what is imported from the metadata of an image.
+type imageMetadata struct {
+	// keywords are paths in a keyword hierarchy, from the root to the leaf.
+	// A flat keyword is a path of one name.
+	keywords    [][]string
+	rating      *uint
+	description string
+	createdAt   time.Time
+}
+
+var xmpDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// metadata merges the metadata of an image. A value in the XMP sidecar wins
+// over the one embedded in the image, as the sidecar is what DigiKam and
+// Lightroom edit, then the embedded XMP wins over IPTC and EXIF. The words of
+// a caption file are added as flat keywords.
+func (importImage importImage) metadata() imageMetadata {
+	xmps := make([]*XMP, 0, 2)
+	if importImage.xmp != nil {
+		xmps = append(xmps, importImage.xmp)
+	}
+	if importImage.embedded.xmp != nil {
+		xmps = append(xmps, importImage.embedded.xmp)
+	}
+
+	var result imageMetadata
+	hierarchicalKeywords := make([][]string, 0)
+	flatKeywords := make([]string, 0)
+	for _, xmp := range xmps {
+		hierarchicalKeywords = append(hierarchicalKeywords, xmp.RDF.hierarchicalKeywords()...)
+		flatKeywords = append(flatKeywords, xmp.RDF.Subjects...)
+
+		if result.rating == nil {
+			result.rating = xmp.RDF.rating()
+		}
+		if result.description == "" {
+			result.description = firstNonEmpty(xmp.RDF.Descriptions...)
+		}
+		if result.createdAt.IsZero() {
+			result.createdAt = xmp.RDF.createdAt()
+		}
+	}
+	flatKeywords = append(flatKeywords, importImage.embedded.iptcKeywords...)
+	flatKeywords = append(flatKeywords, importImage.captionKeywords...)
+	if result.description == "" {
+		result.description = strings.TrimSpace(importImage.embedded.iptcCaption)
+	}
+	if result.createdAt.IsZero() {
+		result.createdAt = importImage.embedded.dateTimeOriginal
+	}
+
+	// Tools write each name in a hierarchy into the flat keywords as well,
+	// so only the flat keywords that aren't in any hierarchy are added.
+	isAdded := make(map[string]bool)
+	isInHierarchy := make(map[string]bool)
+	for _, keyword := range hierarchicalKeywords {
+		path := strings.Join(keyword, "/")
+		if isAdded[path] {
+			continue
+		}
+		isAdded[path] = true
+		result.keywords = append(result.keywords, keyword)
+		for _, name := range keyword {
+			isInHierarchy[name] = true
+		}
+	}
+	for _, keyword := range flatKeywords {
+		keyword = strings.TrimSpace(keyword)
+		if keyword == "" || isInHierarchy[keyword] || isAdded[keyword] {
+			continue
+		}
+		isAdded[keyword] = true
+		result.keywords = append(result.keywords, []string{keyword})
+	}
+	return result
+}
+
+// hierarchicalKeywords returns the DigiKam keywords, or the Lightroom ones if
+// there are none, since tools write the same hierarchy into both. Empty names
+// in a path are skipped.
+func (rdf RDF) hierarchicalKeywords() [][]string {
+	values, separator := rdf.TagsList, "/"
+	if len(values) == 0 {
+		values, separator = rdf.HierarchicalSubjects, "|"
+	}
+	result := make([][]string, 0, len(values))
+	for _, value := range values {
+		path := make([]string, 0)
+		for _, name := range strings.Split(value, separator) {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			path = append(path, name)
+		}
+		if len(path) == 0 {
+			continue
+		}
+		result = append(result, path)
+	}
+	return result
+}
+
+// rating returns 1 to 5 stars, or nil for an unrated or rejected image.
+func (rdf RDF) rating() *uint {
+	value := firstNonEmpty(rdf.Rating, rdf.RatingElement)
+	if value == "" {
+		return nil
+	}
+	// some tools write a rating as a decimal, e.g. "3.0"
+	rating, err := strconv.ParseFloat(value, 64)
+	if err != nil || rating < 1 || rating > 5 {
+		return nil
+	}
+	result := uint(rating)
+	return &result
+}
+
+func (rdf RDF) createdAt() time.Time {
+	value := firstNonEmpty(
+		rdf.DateTimeOriginal,
+		rdf.DateTimeOriginalElement,
+		rdf.DateCreated,
+		rdf.DateCreatedElement,
+	)
+	if value == "" {
+		return time.Time{}
+	}
+	for _, layout := range xmpDateLayouts {
+		if result, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return result
+		}
+	}
+	return time.Time{}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// captionFilePath returns the path of the kohya-style caption of an image,
+// e.g. image.txt for image.jpg.
+func captionFilePath(imageFilePath string) string {
+	return strings.TrimSuffix(imageFilePath, filepath.Ext(imageFilePath)) + ".txt"
+}
+
+// readCaption returns the comma-separated words in a caption file, or nil if
+// the image has no caption.
+func readCaption(filePath string) ([]string, error) {
+	content, err := os.ReadFile(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	result := make([]string, 0)
+	for _, word := range strings.Split(string(content), ",") {
+		if word = strings.TrimSpace(word); word != "" {
+			result = append(result, word)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,118 @@
+package import_images
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportImage_metadata(t *testing.T) {
+	sidecarTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	embeddedTime := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	exifTime := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	three, four := uint(3), uint(4)
+
+	testCases := []struct {
+		name        string
+		importImage importImage
+		want        imageMetadata
+	}{
+		{
+			name: "the sidecar wins over the embedded metadata",
+			importImage: importImage{
+				xmp: &XMP{RDF: RDF{
+					TagsList:         []string{"scene/night"},
+					Descriptions:     []string{"from the sidecar"},
+					Rating:           "3",
+					DateTimeOriginal: sidecarTime.Format(time.RFC3339),
+				}},
+				embedded: embeddedMetadata{
+					xmp: &XMP{RDF: RDF{
+						TagsList:      []string{"scene/night", "scene/day"},
+						Descriptions:  []string{"embedded"},
+						RatingElement: "4",
+						DateCreated:   embeddedTime.Format(time.RFC3339),
+					}},
+					iptcKeywords:     []string{"rain"},
+					iptcCaption:      "IPTC",
+					dateTimeOriginal: exifTime,
+				},
+			},
+			want: imageMetadata{
+				keywords: [][]string{
+					{"scene", "night"},
+					{"scene", "day"},
+					{"rain"},
+				},
+				rating:      &three,
+				description: "from the sidecar",
+				createdAt:   sidecarTime,
+			},
+		},
+		{
+			name: "the embedded XMP wins over IPTC and EXIF",
+			importImage: importImage{
+				embedded: embeddedMetadata{
+					xmp: &XMP{RDF: RDF{
+						HierarchicalSubjects: []string{"Characters|Frieren"},
+						Subjects:             []string{"Frieren", "smile"},
+						Descriptions:         []string{"embedded"},
+						Rating:               "4.0",
+						DateCreated:          embeddedTime.Format("2006-01-02T15:04:05Z07:00"),
+					}},
+					iptcKeywords:     []string{"smile", " "},
+					iptcCaption:      "IPTC",
+					dateTimeOriginal: exifTime,
+				},
+			},
+			want: imageMetadata{
+				keywords: [][]string{
+					{"Characters", "Frieren"},
+					{"smile"},
+				},
+				rating:      &four,
+				description: "embedded",
+				createdAt:   embeddedTime,
+			},
+		},
+		{
+			name: "IPTC and EXIF",
+			importImage: importImage{
+				embedded: embeddedMetadata{
+					iptcKeywords:     []string{"rain"},
+					iptcCaption:      " IPTC ",
+					dateTimeOriginal: exifTime,
+				},
+			},
+			want: imageMetadata{
+				keywords:    [][]string{{"rain"}},
+				description: "IPTC",
+				createdAt:   exifTime,
+			},
+		},
+		{
+			name: "unrated, rejected and invalid values are skipped",
+			importImage: importImage{
+				xmp: &XMP{RDF: RDF{
+					TagsList:         []string{"/"},
+					Rating:           "0",
+					DateTimeOriginal: "yesterday",
+				}},
+				embedded: embeddedMetadata{
+					xmp: &XMP{RDF: RDF{
+						Rating: "-1",
+					}},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.importImage.metadata()
+			assert.True(t, tc.want.createdAt.Equal(got.createdAt), "createdAt: %v", got.createdAt)
+			got.createdAt = tc.want.createdAt
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -15,8 +15,28 @@ type XMP struct {
 	RDF     RDF      `xml:"RDF>Description"`
 }
 
+// RDF is what is imported from an XMP packet. Keywords are read from each of
+// the properties tools write them into, as not every tool writes all of them.
 type RDF struct {
+	// TagsList is the keyword hierarchy of DigiKam, e.g. "Test 1/Test 10".
 	TagsList []string `xml:"TagsList>Seq>li"`
+	// HierarchicalSubjects is the keyword hierarchy of Lightroom, e.g.
+	// "Test 1|Test 10".
+	HierarchicalSubjects []string `xml:"hierarchicalSubject>Bag>li"`
+	// Subjects are flat keywords.
+	Subjects []string `xml:"subject>Bag>li"`
+	// Descriptions are the captions in each language.
+	Descriptions []string `xml:"description>Alt>li"`
+
+	// Rating is 1 to 5 stars, 0 for unrated and -1 for rejected. Each
+	// property is written either as an attribute or as an element.
+	Rating        string `xml:"http://ns.adobe.com/xap/1.0/ Rating,attr"`
+	RatingElement string `xml:"http://ns.adobe.com/xap/1.0/ Rating"`
+
+	DateTimeOriginal        string `xml:"http://ns.adobe.com/exif/1.0/ DateTimeOriginal,attr"`
+	DateTimeOriginalElement string `xml:"http://ns.adobe.com/exif/1.0/ DateTimeOriginal"`
+	DateCreated             string `xml:"http://ns.adobe.com/photoshop/1.0/ DateCreated,attr"`
+	DateCreatedElement      string `xml:"http://ns.adobe.com/photoshop/1.0/ DateCreated"`
 }
 
 type XMPReader struct {
@@ -44,10 +64,13 @@ func (reader *XMPReader) read(filePath string) (*XMP, error) {
 	if err != nil {
 		return nil, fmt.Errorf("io.ReadAll: %w", err)
 	}
+	return parseXMP(contents)
+}
+
+func parseXMP(contents []byte) (*XMP, error) {
 	var xmp XMP
 	if err := xml.Unmarshal(contents, &xmp); err != nil {
 		return nil, fmt.Errorf("xml.Unmarshal: %w", err)
 	}
-
 	return &xmp, nil
 }
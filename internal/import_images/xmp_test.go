@@ -31,6 +31,12 @@ func TestXmpSidecarReader_read(t *testing.T) {
 					TagsList: []string{
 						"Test 1/Test 10/Test 100",
 					},
+					HierarchicalSubjects: []string{
+						"Test 1|Test 10|Test 100",
+					},
+					Subjects: []string{
+						"Test 100",
+					},
 				},
 			},
 		},
@@ -44,6 +50,14 @@ func TestXmpSidecarReader_read(t *testing.T) {
 						"Test 2",
 						"Test 2/Test 20",
 					},
+					HierarchicalSubjects: []string{
+						"Test 2",
+						"Test 2|Test 20",
+					},
+					Subjects: []string{
+						"Test 2",
+						"Test 20",
+					},
 				},
 			},
 		},
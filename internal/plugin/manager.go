@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+	"google.golang.org/grpc"
+)
+
+var (
+	ErrPluginNotFound = errors.New("plugin is not found")
+	// ErrManagerStopped is returned when a plugin is used after the app
+	// started shutting down
+	ErrManagerStopped = errors.New("plugin manager is stopped")
+)
+
+// Manager runs the plugin processes defined in the config. A plugin is
+// started when its connection is used first, restarted when it crashes or
+// stops being healthy, and stopped by Stop. The output of the plugins is
+// written to the log of the app.
+type Manager struct {
+	logger    *slog.Logger
+	processes map[string]*process
+}
+
+func NewManager(logger *slog.Logger, conf config.Config) (*Manager, error) {
+	processes := make(map[string]*process, len(conf.Plugins))
+	for _, pluginConfig := range conf.Plugins {
+		if err := validatePluginConfig(pluginConfig); err != nil {
+			return nil, fmt.Errorf("%w: plugin %q: %w", xerrors.ErrInvalidArgument, pluginConfig.Name, err)
+		}
+		if _, ok := processes[pluginConfig.Name]; ok {
+			return nil, fmt.Errorf("%w: plugin %q is defined more than once", xerrors.ErrInvalidArgument, pluginConfig.Name)
+		}
+		process, err := newProcess(logger.With("plugin", pluginConfig.Name), pluginConfig)
+		if err != nil {
+			return nil, fmt.Errorf("newProcess: %w", err)
+		}
+		processes[pluginConfig.Name] = process
+	}
+	return &Manager{
+		logger:    logger,
+		processes: processes,
+	}, nil
+}
+
+func validatePluginConfig(pluginConfig config.PluginConfig) error {
+	if pluginConfig.Name == "" {
+		return errors.New("name is required")
+	}
+	if pluginConfig.Command == "" {
+		return errors.New("command is required")
+	}
+	if (pluginConfig.Port == 0) == (pluginConfig.Socket == "") {
+		return errors.New("either port or socket is required")
+	}
+	if pluginConfig.Port < 0 || pluginConfig.Port > 65535 {
+		return fmt.Errorf("port is out of range: %d", pluginConfig.Port)
+	}
+	return nil
+}
+
+// Dial returns a connection to the plugin for gRPC clients. The plugin is
+// started when the connection is used first, and a call waits until the
+// plugin becomes healthy.
+func (manager *Manager) Dial(name string) (grpc.ClientConnInterface, error) {
+	process, ok := manager.processes[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPluginNotFound, name)
+	}
+	return process, nil
+}
+
+// Stop stops every plugin and waits for them to exit. Plugins aren't started
+// again after it.
+func (manager *Manager) Stop() {
+	var waitGroup sync.WaitGroup
+	for _, process := range manager.processes {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			process.stop()
+		}()
+	}
+	waitGroup.Wait()
+}
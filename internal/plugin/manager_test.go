@@ -0,0 +1,193 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// helperProcessEnv makes the test binary run as a plugin serving the health
+// service, on the address in its last argument
+const helperProcessEnv = "PLUGIN_TEST_HELPER_PROCESS"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(helperProcessEnv) == "1" {
+		runHelperProcess()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func runHelperProcess() {
+	address := os.Args[len(os.Args)-1]
+	listener, err := net.Listen("unix", strings.TrimPrefix(address, "unix:"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	fmt.Printf("serving on %s\n", address)
+	if err := server.Serve(listener); err != nil {
+		os.Exit(1)
+	}
+}
+
+// syncBuffer is written by the goroutines of plugins
+type syncBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (buffer *syncBuffer) Write(p []byte) (int, error) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	return buffer.buffer.Write(p)
+}
+
+func (buffer *syncBuffer) String() string {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	return buffer.buffer.String()
+}
+
+func TestNewManager(t *testing.T) {
+	testCases := []struct {
+		name    string
+		plugins []config.PluginConfig
+		wantErr error
+	}{
+		{
+			name: "a port and a socket",
+			plugins: []config.PluginConfig{
+				{Name: "plugin1", Command: "plugin", Port: 50051},
+				{Name: "plugin2", Command: "plugin", Socket: "/tmp/plugin.sock"},
+			},
+		},
+		{
+			name:    "no command",
+			plugins: []config.PluginConfig{{Name: "plugin", Port: 50051}},
+			wantErr: xerrors.ErrInvalidArgument,
+		},
+		{
+			name:    "neither a port nor a socket",
+			plugins: []config.PluginConfig{{Name: "plugin", Command: "plugin"}},
+			wantErr: xerrors.ErrInvalidArgument,
+		},
+		{
+			name:    "both a port and a socket",
+			plugins: []config.PluginConfig{{Name: "plugin", Command: "plugin", Port: 50051, Socket: "/tmp/plugin.sock"}},
+			wantErr: xerrors.ErrInvalidArgument,
+		},
+		{
+			name: "duplicated names",
+			plugins: []config.PluginConfig{
+				{Name: "plugin", Command: "plugin", Port: 50051},
+				{Name: "plugin", Command: "plugin", Port: 50052},
+			},
+			wantErr: xerrors.ErrInvalidArgument,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			manager, err := NewManager(slog.Default(), config.Config{Plugins: tc.plugins})
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			defer manager.Stop()
+
+			for _, plugin := range tc.plugins {
+				_, err := manager.Dial(plugin.Name)
+				assert.NoError(t, err)
+			}
+			_, err = manager.Dial("unknown")
+			assert.ErrorIs(t, err, ErrPluginNotFound)
+		})
+	}
+}
+
+func TestManager(t *testing.T) {
+	t.Setenv(helperProcessEnv, "1")
+	var logs syncBuffer
+	manager, err := NewManager(slog.New(slog.NewTextHandler(&logs, nil)), config.Config{
+		Plugins: []config.PluginConfig{
+			{
+				Name:                "helper",
+				Command:             os.Args[0],
+				Args:                []string{"--address", "{address}"},
+				Socket:              filepath.Join(t.TempDir(), "plugin.sock"),
+				StartTimeoutSeconds: 10,
+			},
+		},
+	})
+	require.NoError(t, err)
+	process := manager.processes["helper"]
+	process.initialBackoff = 10 * time.Millisecond
+	process.healthPollInterval = 10 * time.Millisecond
+
+	connection, err := manager.Dial("helper")
+	require.NoError(t, err)
+	healthClient := healthpb.NewHealthClient(connection)
+	ctx := context.Background()
+
+	t.Run("start on demand", func(t *testing.T) {
+		assert.Nil(t, process.command)
+
+		response, err := healthClient.Check(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, response.Status)
+		assert.Eventually(t, func() bool {
+			return strings.Contains(logs.String(), "serving on unix:")
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("restart after a crash", func(t *testing.T) {
+		process.mutex.Lock()
+		crashedCommand := process.command
+		process.mutex.Unlock()
+		require.NoError(t, crashedCommand.Process.Kill())
+
+		assert.Eventually(t, func() bool {
+			process.mutex.Lock()
+			defer process.mutex.Unlock()
+			return process.command != nil && process.command != crashedCommand
+		}, 5*time.Second, 10*time.Millisecond)
+		response, err := healthClient.Check(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, response.Status)
+		assert.Contains(t, logs.String(), "plugin exited unexpectedly")
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		process.mutex.Lock()
+		exited := process.exited
+		process.mutex.Unlock()
+
+		manager.Stop()
+		select {
+		case <-exited:
+		default:
+			t.Fatal("the plugin is still running")
+		}
+		_, err := healthClient.Check(ctx, &healthpb.HealthCheckRequest{})
+		assert.ErrorIs(t, err, ErrManagerStopped)
+	})
+}
@@ -0,0 +1,386 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// unhealthyLimit is how many health checks in a row can fail before a
+	// plugin is restarted
+	unhealthyLimit = 3
+	// maxLogLineLength splits a long line of the output of a plugin, so that
+	// it's not buffered without a limit
+	maxLogLineLength = 64 * 1024
+)
+
+// process is a plugin process and the connection to it. It implements
+// grpc.ClientConnInterface to start the plugin when it's used first.
+type process struct {
+	logger     *slog.Logger
+	config     config.PluginConfig
+	connection *grpc.ClientConn
+	health     healthpb.HealthClient
+
+	mutex     sync.Mutex
+	isStarted bool
+	isHealthy bool
+	command   *exec.Cmd
+	// exited is closed when command exits
+	exited   chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+
+	initialBackoff      time.Duration
+	maxBackoff          time.Duration
+	healthCheckInterval time.Duration
+	healthPollInterval  time.Duration
+	stopTimeout         time.Duration
+}
+
+func newProcess(logger *slog.Logger, pluginConfig config.PluginConfig) (*process, error) {
+	// the plugin runs on the same machine, so it's reconnected quickly after
+	// it's restarted
+	connection, err := grpc.NewClient(
+		pluginAddress(pluginConfig),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff: backoff.Config{
+				BaseDelay:  100 * time.Millisecond,
+				Multiplier: 1.6,
+				Jitter:     0.2,
+				MaxDelay:   time.Second,
+			},
+			MinConnectTimeout: time.Second,
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("grpc.NewClient: %w", err)
+	}
+	return &process{
+		logger:     logger,
+		config:     pluginConfig,
+		connection: connection,
+		health:     healthpb.NewHealthClient(connection),
+		stopped:    make(chan struct{}),
+
+		initialBackoff:      time.Second,
+		maxBackoff:          time.Minute,
+		healthCheckInterval: 30 * time.Second,
+		healthPollInterval:  200 * time.Millisecond,
+		stopTimeout:         10 * time.Second,
+	}, nil
+}
+
+// pluginAddress returns the address which the plugin listens on, in the
+// syntax of gRPC
+func pluginAddress(pluginConfig config.PluginConfig) string {
+	if pluginConfig.Socket != "" {
+		return "unix:" + pluginConfig.Socket
+	}
+	return fmt.Sprintf("localhost:%d", pluginConfig.Port)
+}
+
+func (process *process) Invoke(ctx context.Context, method string, args any, reply any, opts ...grpc.CallOption) error {
+	if err := process.start(ctx); err != nil {
+		return err
+	}
+	return process.connection.Invoke(ctx, method, args, reply, opts...)
+}
+
+func (process *process) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if err := process.start(ctx); err != nil {
+		return nil, err
+	}
+	return process.connection.NewStream(ctx, desc, method, opts...)
+}
+
+func (process *process) isStopped() bool {
+	select {
+	case <-process.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+// start starts the plugin unless it's running, and waits until it becomes
+// healthy
+func (process *process) start(ctx context.Context) error {
+	process.mutex.Lock()
+	if process.isHealthy {
+		process.mutex.Unlock()
+		return nil
+	}
+	if !process.isStarted {
+		command, err := process.launch()
+		if err != nil {
+			process.mutex.Unlock()
+			return fmt.Errorf("launch: %w", err)
+		}
+		process.isStarted = true
+		go process.supervise(command)
+		go process.monitor()
+	}
+	process.mutex.Unlock()
+
+	return process.waitUntilHealthy(ctx)
+}
+
+// launch starts the command of the plugin. It must be called with the mutex
+// locked.
+func (process *process) launch() (*exec.Cmd, error) {
+	if process.isStopped() {
+		return nil, ErrManagerStopped
+	}
+	if process.config.Socket != "" {
+		// a socket left by a crashed plugin prevents it from listening
+		if err := os.Remove(process.config.Socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("os.Remove: %w", err)
+		}
+	}
+
+	address := pluginAddress(process.config)
+	args := make([]string, len(process.config.Args))
+	for index, arg := range process.config.Args {
+		args[index] = strings.ReplaceAll(arg, "{address}", address)
+	}
+	command := exec.Command(process.config.Command, args...)
+	command.Dir = process.config.Directory
+	command.Env = os.Environ()
+	envNames := make([]string, 0, len(process.config.Env))
+	for name := range process.config.Env {
+		envNames = append(envNames, name)
+	}
+	sort.Strings(envNames)
+	for _, name := range envNames {
+		command.Env = append(command.Env, name+"="+process.config.Env[name])
+	}
+	command.Stdout = &logWriter{logger: process.logger, stream: "stdout"}
+	command.Stderr = &logWriter{logger: process.logger, stream: "stderr"}
+	setProcessGroup(command)
+	if err := command.Start(); err != nil {
+		return nil, fmt.Errorf("command.Start: %w", err)
+	}
+	process.logger.Info("started a plugin", "pid", command.Process.Pid, "address", address)
+
+	process.command = command
+	process.exited = make(chan struct{})
+	process.connection.ResetConnectBackoff()
+	return command, nil
+}
+
+// supervise waits for the command to exit, and restarts it with a backoff
+// until the plugin is stopped
+func (process *process) supervise(command *exec.Cmd) {
+	nextBackoff := process.initialBackoff
+	for {
+		startedAt := time.Now()
+		err := command.Wait()
+		command.Stdout.(*logWriter).flush()
+		command.Stderr.(*logWriter).flush()
+
+		process.mutex.Lock()
+		process.isHealthy = false
+		process.command = nil
+		close(process.exited)
+		process.mutex.Unlock()
+		if process.isStopped() {
+			process.logger.Info("stopped a plugin")
+			return
+		}
+
+		// a plugin which ran for a while crashed for another reason than
+		// the last time
+		if time.Since(startedAt) >= process.maxBackoff {
+			nextBackoff = process.initialBackoff
+		}
+		process.logger.Error("plugin exited unexpectedly", "error", err, "restartAfter", nextBackoff)
+		for {
+			select {
+			case <-process.stopped:
+				return
+			case <-time.After(nextBackoff):
+			}
+			nextBackoff = min(nextBackoff*2, process.maxBackoff)
+
+			process.mutex.Lock()
+			command, err = process.launch()
+			process.mutex.Unlock()
+			if err == nil {
+				break
+			}
+			if errors.Is(err, ErrManagerStopped) {
+				return
+			}
+			process.logger.Error("failed to restart a plugin", "error", err, "restartAfter", nextBackoff)
+		}
+	}
+}
+
+// monitor checks the health of the plugin periodically, and kills it when
+// it stops being healthy, to be restarted by supervise
+func (process *process) monitor() {
+	ticker := time.NewTicker(process.healthCheckInterval)
+	defer ticker.Stop()
+	failureCount := 0
+	for {
+		select {
+		case <-process.stopped:
+			return
+		case <-ticker.C:
+		}
+
+		process.mutex.Lock()
+		command := process.command
+		isHealthy := process.isHealthy
+		process.mutex.Unlock()
+		// a plugin is restarted only after it became healthy, since it can
+		// take long to start
+		if command == nil || !isHealthy {
+			failureCount = 0
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), process.healthCheckInterval)
+		err := process.checkHealth(ctx)
+		cancel()
+		if err == nil {
+			failureCount = 0
+			continue
+		}
+		failureCount++
+		process.logger.Warn("plugin health check failed", "error", err, "failures", failureCount)
+		if failureCount < unhealthyLimit {
+			continue
+		}
+		failureCount = 0
+		process.logger.Error("restarting an unhealthy plugin")
+		if err := kill(command); err != nil {
+			process.logger.Error("failed to kill an unhealthy plugin", "error", err)
+		}
+	}
+}
+
+// checkHealth returns nil if the plugin is serving. A plugin without the
+// health service is treated as serving once it accepts a call, in the same
+// way as the client-side health checking of gRPC.
+func (process *process) checkHealth(ctx context.Context) error {
+	response, err := process.health.Check(ctx, &healthpb.HealthCheckRequest{})
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("health.Check: %w", err)
+	}
+	if response.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("plugin is %s", response.Status)
+	}
+	return nil
+}
+
+func (process *process) waitUntilHealthy(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(process.config.StartTimeoutSeconds)*time.Second)
+	defer cancel()
+	for {
+		err := process.checkHealth(ctx)
+		if err == nil {
+			process.mutex.Lock()
+			process.isHealthy = process.command != nil
+			process.mutex.Unlock()
+			return nil
+		}
+		if process.isStopped() {
+			return ErrManagerStopped
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("plugin %s didn't become healthy: %w", process.config.Name, errors.Join(ctx.Err(), err))
+		case <-time.After(process.healthPollInterval):
+		}
+	}
+}
+
+// stop asks the plugin to shut down, and kills it if it doesn't exit in time
+func (process *process) stop() {
+	process.stopOnce.Do(func() {
+		process.mutex.Lock()
+		close(process.stopped)
+		command := process.command
+		exited := process.exited
+		process.mutex.Unlock()
+
+		if command != nil {
+			if err := terminate(command); err != nil {
+				process.logger.Warn("failed to terminate a plugin", "error", err)
+			}
+			select {
+			case <-exited:
+			case <-time.After(process.stopTimeout):
+				process.logger.Warn("killing a plugin which didn't exit in time")
+				if err := kill(command); err != nil {
+					process.logger.Error("failed to kill a plugin", "error", err)
+				}
+				<-exited
+			}
+		}
+		if err := process.connection.Close(); err != nil {
+			process.logger.Warn("failed to close the connection to a plugin", "error", err)
+		}
+	})
+}
+
+// logWriter writes each line of the output of a plugin to the log
+type logWriter struct {
+	logger *slog.Logger
+	stream string
+	buffer []byte
+}
+
+func (writer *logWriter) Write(p []byte) (int, error) {
+	writer.buffer = append(writer.buffer, p...)
+	for {
+		index := bytes.IndexByte(writer.buffer, '\n')
+		if index < 0 {
+			break
+		}
+		writer.log(writer.buffer[:index])
+		writer.buffer = writer.buffer[index+1:]
+	}
+	if len(writer.buffer) >= maxLogLineLength {
+		writer.flush()
+	}
+	return len(p), nil
+}
+
+func (writer *logWriter) flush() {
+	if len(writer.buffer) > 0 {
+		writer.log(writer.buffer)
+	}
+	writer.buffer = nil
+}
+
+func (writer *logWriter) log(line []byte) {
+	writer.logger.Info("plugin output",
+		"stream", writer.stream,
+		"line", strings.TrimRight(string(line), "\r"),
+	)
+}
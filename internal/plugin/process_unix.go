@@ -0,0 +1,23 @@
+//go:build !windows
+
+package plugin
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the plugin in its own process group, so that the
+// processes it starts, e.g. python under `pdm run`, are stopped with it.
+func setProcessGroup(command *exec.Cmd) {
+	command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// terminate asks the plugin to shut down gracefully
+func terminate(command *exec.Cmd) error {
+	return syscall.Kill(-command.Process.Pid, syscall.SIGTERM)
+}
+
+func kill(command *exec.Cmd) error {
+	return syscall.Kill(-command.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package plugin
+
+import (
+	"os/exec"
+)
+
+func setProcessGroup(command *exec.Cmd) {
+}
+
+// terminate kills the plugin, since a console process on Windows can't be
+// interrupted by another process
+func terminate(command *exec.Cmd) error {
+	return command.Process.Kill()
+}
+
+func kill(command *exec.Cmd) error {
+	return command.Process.Kill()
+}
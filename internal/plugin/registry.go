@@ -0,0 +1,379 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+	pluginsv1 "github.com/michael-freling/anime-image-viewer/plugins/plugins-protos/gen/go/plugins/v1"
+)
+
+var ErrCapabilityNotFound = errors.New("no plugin has the capability")
+
+// Analyzer is an image analysis plugin of plugins.v1
+type Analyzer struct {
+	Name   string
+	Client pluginsv1.PluginServiceClient
+}
+
+// DialAnalyzers returns the analyzers of the managed plugins, in the order of
+// the names.
+func (manager *Manager) DialAnalyzers(names []string) ([]Analyzer, error) {
+	analyzers := make([]Analyzer, 0, len(names))
+	for _, name := range names {
+		connection, err := manager.Dial(name)
+		if err != nil {
+			return nil, fmt.Errorf("Dial: %w", err)
+		}
+		analyzers = append(analyzers, Analyzer{
+			Name:   name,
+			Client: pluginsv1.NewPluginServiceClient(connection),
+		})
+	}
+	return analyzers, nil
+}
+
+// Capability is what an analyzer can do for an image
+type Capability struct {
+	PluginName      string                  `json:"pluginName"`
+	PluginVersion   string                  `json:"pluginVersion"`
+	Name            string                  `json:"name"`
+	Description     string                  `json:"description"`
+	AnnotationTypes []db.FileAnnotationType `json:"annotationTypes"`
+	// ContentTypes are the MIME types of the images the capability accepts.
+	// Any image is accepted if it's empty.
+	ContentTypes []string `json:"contentTypes"`
+}
+
+func (capability Capability) accepts(contentType string) bool {
+	return len(capability.ContentTypes) == 0 || slices.Contains(capability.ContentTypes, contentType)
+}
+
+// Registry routes images to the analyzers capable of what is requested, and
+// stores their results as annotations of the files. The capabilities of an
+// analyzer are asked when they are needed first, so that a plugin isn't
+// started until then.
+type Registry struct {
+	logger      *slog.Logger
+	dbClient    *db.Client
+	imageReader *image.Reader
+	// annotationDirectory is where derived images are stored
+	annotationDirectory string
+	analyzers           []Analyzer
+
+	mutex        sync.Mutex
+	capabilities map[string][]Capability
+}
+
+// AnnotationDirectory returns where the derived images of annotations are
+// stored
+func AnnotationDirectory(conf config.Config) string {
+	return filepath.Join(conf.ConfigDirectory, "annotations")
+}
+
+func NewRegistry(
+	logger *slog.Logger,
+	dbClient *db.Client,
+	imageReader *image.Reader,
+	annotationDirectory string,
+	analyzers []Analyzer,
+) *Registry {
+	return &Registry{
+		logger:              logger,
+		dbClient:            dbClient,
+		imageReader:         imageReader,
+		annotationDirectory: annotationDirectory,
+		analyzers:           analyzers,
+		capabilities:        make(map[string][]Capability, len(analyzers)),
+	}
+}
+
+// Capabilities returns the capabilities of every analyzer, in the order of
+// the analyzers. An analyzer which fails to answer is skipped, and asked
+// again next time.
+func (registry *Registry) Capabilities(ctx context.Context) []Capability {
+	result := make([]Capability, 0)
+	for _, analyzer := range registry.analyzers {
+		capabilities, err := registry.getCapabilities(ctx, analyzer)
+		if err != nil {
+			registry.logger.WarnContext(ctx, "failed to get the capabilities of a plugin",
+				"plugin", analyzer.Name,
+				"error", err,
+			)
+			continue
+		}
+		result = append(result, capabilities...)
+	}
+	return result
+}
+
+func (registry *Registry) getCapabilities(ctx context.Context, analyzer Analyzer) ([]Capability, error) {
+	registry.mutex.Lock()
+	capabilities, ok := registry.capabilities[analyzer.Name]
+	registry.mutex.Unlock()
+	if ok {
+		return capabilities, nil
+	}
+
+	response, err := analyzer.Client.GetCapabilities(ctx, &pluginsv1.GetCapabilitiesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("GetCapabilities: %w", err)
+	}
+	capabilities = make([]Capability, 0, len(response.Capabilities))
+	for _, capability := range response.Capabilities {
+		annotationTypes := make([]db.FileAnnotationType, 0, len(capability.AnnotationTypes))
+		for _, annotationType := range capability.AnnotationTypes {
+			switch annotationType {
+			case pluginsv1.AnnotationType_ANNOTATION_TYPE_TEXT:
+				annotationTypes = append(annotationTypes, db.FileAnnotationTypeText)
+			case pluginsv1.AnnotationType_ANNOTATION_TYPE_BOUNDING_BOX:
+				annotationTypes = append(annotationTypes, db.FileAnnotationTypeBoundingBox)
+			case pluginsv1.AnnotationType_ANNOTATION_TYPE_DERIVED_IMAGE:
+				annotationTypes = append(annotationTypes, db.FileAnnotationTypeDerivedImage)
+			}
+		}
+		capabilities = append(capabilities, Capability{
+			PluginName:      analyzer.Name,
+			PluginVersion:   response.Version,
+			Name:            capability.Name,
+			Description:     capability.Description,
+			AnnotationTypes: annotationTypes,
+			ContentTypes:    capability.ContentTypes,
+		})
+	}
+
+	registry.mutex.Lock()
+	registry.capabilities[analyzer.Name] = capabilities
+	registry.mutex.Unlock()
+	return capabilities, nil
+}
+
+// AnalysisResult is the annotations of an image by a plugin. PluginName is
+// empty if no plugin accepts the image.
+type AnalysisResult struct {
+	ImageFileID uint                `json:"imageFileId"`
+	PluginName  string              `json:"pluginName"`
+	Annotations []db.FileAnnotation `json:"annotations"`
+}
+
+// Analyze runs a capability on the images, each by the first analyzer which
+// accepts its content type, and replaces the annotations of the images by the
+// capability of the analyzer.
+func (registry *Registry) Analyze(ctx context.Context, capabilityName string, imageFileIDs []uint) ([]AnalysisResult, error) {
+	capabilities := make([]Capability, 0)
+	for _, capability := range registry.Capabilities(ctx) {
+		if capability.Name == capabilityName {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	if len(capabilities) == 0 {
+		return nil, fmt.Errorf("%w: %w: %s", xerrors.ErrInvalidArgument, ErrCapabilityNotFound, capabilityName)
+	}
+	clients := make(map[string]pluginsv1.PluginServiceClient, len(registry.analyzers))
+	for _, analyzer := range registry.analyzers {
+		clients[analyzer.Name] = analyzer.Client
+	}
+
+	imageFiles, err := registry.imageReader.ReadImagesByIDs(imageFileIDs)
+	if err != nil {
+		return nil, fmt.Errorf("imageReader.ReadImagesByIDs: %w", err)
+	}
+	results := make([]AnalysisResult, 0, len(imageFiles))
+	for _, imageFile := range imageFiles {
+		index := slices.IndexFunc(capabilities, func(capability Capability) bool {
+			return capability.accepts(imageFile.ContentType)
+		})
+		if index < 0 {
+			registry.logger.WarnContext(ctx, "no plugin accepts an image",
+				"capability", capabilityName,
+				"imageFileID", imageFile.ID,
+				"contentType", imageFile.ContentType,
+			)
+			results = append(results, AnalysisResult{ImageFileID: imageFile.ID})
+			continue
+		}
+		capability := capabilities[index]
+
+		annotations, err := registry.analyze(ctx, clients[capability.PluginName], capability, imageFile)
+		if err != nil {
+			return results, fmt.Errorf("analyze %s by %s: %w", imageFile.Path, capability.PluginName, err)
+		}
+		results = append(results, AnalysisResult{
+			ImageFileID: imageFile.ID,
+			PluginName:  capability.PluginName,
+			Annotations: annotations,
+		})
+	}
+	return results, nil
+}
+
+func (registry *Registry) analyze(
+	ctx context.Context,
+	client pluginsv1.PluginServiceClient,
+	capability Capability,
+	imageFile image.ImageFile,
+) ([]db.FileAnnotation, error) {
+	content, err := os.ReadFile(imageFile.LocalFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	response, err := client.Analyze(ctx, &pluginsv1.AnalyzeRequest{
+		Capability: capability.Name,
+		Image: &pluginsv1.Image{
+			Content:     content,
+			ContentType: imageFile.ContentType,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Analyze: %w", err)
+	}
+
+	annotations := make([]db.FileAnnotation, 0, len(response.Annotations))
+	derivedImagePaths := make(map[string]struct{})
+	for index, annotation := range response.Annotations {
+		fileAnnotation := db.FileAnnotation{
+			FileID:        imageFile.ID,
+			PluginName:    capability.PluginName,
+			PluginVersion: capability.PluginVersion,
+			Capability:    capability.Name,
+			Score:         annotation.Score,
+		}
+		switch value := annotation.Value.(type) {
+		case *pluginsv1.Annotation_Text:
+			fileAnnotation.Type = db.FileAnnotationTypeText
+			fileAnnotation.Text = value.Text.Text
+			fileAnnotation.Language = value.Text.Language
+			setRegion(&fileAnnotation, value.Text.Region)
+		case *pluginsv1.Annotation_BoundingBox:
+			fileAnnotation.Type = db.FileAnnotationTypeBoundingBox
+			fileAnnotation.Label = value.BoundingBox.Label
+			setRegion(&fileAnnotation, value.BoundingBox.Box)
+		case *pluginsv1.Annotation_DerivedImage:
+			fileAnnotation.Type = db.FileAnnotationTypeDerivedImage
+			derivedImagePath, err := registry.writeDerivedImage(capability, imageFile.ID, index, value.DerivedImage.Image)
+			if err != nil {
+				return nil, fmt.Errorf("writeDerivedImage: %w", err)
+			}
+			fileAnnotation.DerivedImagePath = derivedImagePath
+			derivedImagePaths[derivedImagePath] = struct{}{}
+		default:
+			registry.logger.WarnContext(ctx, "skipping an annotation without a value",
+				"plugin", capability.PluginName,
+				"capability", capability.Name,
+				"imageFileID", imageFile.ID,
+			)
+			continue
+		}
+		annotations = append(annotations, fileAnnotation)
+	}
+
+	var previousAnnotations []db.FileAnnotation
+	if err := db.NewTransaction(ctx, registry.dbClient, func(ctx context.Context) error {
+		var err error
+		previousAnnotations, err = registry.dbClient.FileAnnotation().FindByCapability(ctx, imageFile.ID, capability.PluginName, capability.Name)
+		if err != nil {
+			return fmt.Errorf("FileAnnotation.FindByCapability: %w", err)
+		}
+		if err := registry.dbClient.FileAnnotation().DeleteByCapability(ctx, imageFile.ID, capability.PluginName, capability.Name); err != nil {
+			return fmt.Errorf("FileAnnotation.DeleteByCapability: %w", err)
+		}
+		if len(annotations) == 0 {
+			return nil
+		}
+		if err := registry.dbClient.FileAnnotation().BatchCreate(ctx, annotations); err != nil {
+			return fmt.Errorf("FileAnnotation.BatchCreate: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("db.NewTransaction: %w", err)
+	}
+
+	// the derived images which weren't overwritten are no longer used
+	for _, previousAnnotation := range previousAnnotations {
+		if previousAnnotation.DerivedImagePath == "" {
+			continue
+		}
+		if _, ok := derivedImagePaths[previousAnnotation.DerivedImagePath]; ok {
+			continue
+		}
+		if err := os.Remove(registry.DerivedImageFilePath(previousAnnotation)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			registry.logger.WarnContext(ctx, "failed to delete a derived image",
+				"path", previousAnnotation.DerivedImagePath,
+				"error", err,
+			)
+		}
+	}
+	return annotations, nil
+}
+
+func setRegion(fileAnnotation *db.FileAnnotation, box *pluginsv1.BoundingBox) {
+	if box == nil {
+		return
+	}
+	fileAnnotation.X = box.X
+	fileAnnotation.Y = box.Y
+	fileAnnotation.Width = box.Width
+	fileAnnotation.Height = box.Height
+}
+
+// writeDerivedImage writes a derived image, and returns its path relative to
+// the annotation directory
+func (registry *Registry) writeDerivedImage(capability Capability, imageFileID uint, index int, derivedImage *pluginsv1.Image) (string, error) {
+	if derivedImage == nil || len(derivedImage.Content) == 0 {
+		return "", fmt.Errorf("%w: a derived image has no content", xerrors.ErrInvalidArgument)
+	}
+	extension := ""
+	switch derivedImage.ContentType {
+	case "image/jpeg":
+		extension = ".jpg"
+	case "image/png":
+		extension = ".png"
+	default:
+		extensions, err := mime.ExtensionsByType(derivedImage.ContentType)
+		if err == nil && len(extensions) > 0 {
+			extension = extensions[0]
+		}
+	}
+
+	relativePath := filepath.Join(
+		capability.PluginName,
+		capability.Name,
+		fmt.Sprintf("%d-%d%s", imageFileID, index, extension),
+	)
+	filePath := filepath.Join(registry.annotationDirectory, relativePath)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return "", fmt.Errorf("os.MkdirAll: %w", err)
+	}
+	if err := os.WriteFile(filePath, derivedImage.Content, 0644); err != nil {
+		return "", fmt.Errorf("os.WriteFile: %w", err)
+	}
+	return relativePath, nil
+}
+
+// DerivedImageFilePath returns the path of the derived image of an annotation
+func (registry *Registry) DerivedImageFilePath(annotation db.FileAnnotation) string {
+	return filepath.Join(registry.annotationDirectory, annotation.DerivedImagePath)
+}
+
+// ReadAnnotations returns the annotations of the files by their IDs
+func (registry *Registry) ReadAnnotations(ctx context.Context, fileIDs []uint) (map[uint][]db.FileAnnotation, error) {
+	annotations, err := registry.dbClient.FileAnnotation().FindByFileIDs(ctx, fileIDs)
+	if err != nil {
+		return nil, fmt.Errorf("FileAnnotation.FindByFileIDs: %w", err)
+	}
+	result := make(map[uint][]db.FileAnnotation)
+	for _, annotation := range annotations {
+		result[annotation.FileID] = append(result[annotation.FileID], annotation)
+	}
+	return result, nil
+}
@@ -0,0 +1,243 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+	pluginsv1 "github.com/michael-freling/anime-image-viewer/plugins/plugins-protos/gen/go/plugins/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
+)
+
+func TestRegistry(t *testing.T) {
+	dbClient := db.NewTestClient(t)
+	imageRootDirectory := t.TempDir()
+	annotationDirectory := t.TempDir()
+	fileCreator := image.NewFileCreator(t, imageRootDirectory).
+		CreateDirectory(image.Directory{ID: 1, Name: "dir1"}).
+		CreateImage(image.ImageFile{ID: 11, Name: "image11.jpg", ParentID: 1}, image.TestImageFileJpeg).
+		CreateImage(image.ImageFile{ID: 12, Name: "image12.png", ParentID: 1}, image.TestImageFilePng)
+	dbClient.Truncate(t, db.File{}, db.FileAnnotation{})
+	db.LoadTestData(t, dbClient, []db.File{
+		fileCreator.BuildDBDirectory(1),
+		fileCreator.BuildDBImageFile(11),
+		fileCreator.BuildDBImageFile(12),
+	})
+
+	conf := config.Config{ImageRootDirectory: imageRootDirectory}
+	imageReader := image.NewReader(
+		dbClient.Client,
+		image.NewDirectoryReader(conf, dbClient.Client),
+		image.NewImageFileConverter(conf),
+	)
+
+	controller := gomock.NewController(t)
+	ocrClient := pluginsv1.NewMockPluginServiceClient(controller)
+	ocrClient.EXPECT().
+		GetCapabilities(gomock.Any(), gomock.Any()).
+		Return(&pluginsv1.GetCapabilitiesResponse{
+			Name:    "ocr",
+			Version: "1.0",
+			Capabilities: []*pluginsv1.Capability{
+				{
+					Name:            "ocr",
+					AnnotationTypes: []pluginsv1.AnnotationType{pluginsv1.AnnotationType_ANNOTATION_TYPE_TEXT},
+					ContentTypes:    []string{"image/jpeg"},
+				},
+			},
+		}, nil).
+		Times(1)
+	ocrClient.EXPECT().
+		Analyze(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, request *pluginsv1.AnalyzeRequest, _ ...grpc.CallOption) (*pluginsv1.AnalyzeResponse, error) {
+			assert.Equal(t, "ocr", request.Capability)
+			assert.Equal(t, "image/jpeg", request.Image.ContentType)
+			assert.NotEmpty(t, request.Image.Content)
+			return &pluginsv1.AnalyzeResponse{
+				Annotations: []*pluginsv1.Annotation{
+					{
+						Score: 0.9,
+						Value: &pluginsv1.Annotation_Text{Text: &pluginsv1.TextAnnotation{
+							Text:     "subtitle",
+							Language: "ja",
+							Region:   &pluginsv1.BoundingBox{X: 0.1, Y: 0.8, Width: 0.8, Height: 0.1},
+						}},
+					},
+				},
+			}, nil
+		}).
+		AnyTimes()
+
+	toolClient := pluginsv1.NewMockPluginServiceClient(controller)
+	toolClient.EXPECT().
+		GetCapabilities(gomock.Any(), gomock.Any()).
+		Return(&pluginsv1.GetCapabilitiesResponse{
+			Name:    "tool",
+			Version: "2.0",
+			Capabilities: []*pluginsv1.Capability{
+				{
+					Name: "ocr",
+					AnnotationTypes: []pluginsv1.AnnotationType{
+						pluginsv1.AnnotationType_ANNOTATION_TYPE_TEXT,
+						pluginsv1.AnnotationType_ANNOTATION_TYPE_BOUNDING_BOX,
+					},
+				},
+				{
+					Name:            "upscale",
+					AnnotationTypes: []pluginsv1.AnnotationType{pluginsv1.AnnotationType_ANNOTATION_TYPE_DERIVED_IMAGE},
+				},
+			},
+		}, nil).
+		Times(1)
+	upscaleCount := 0
+	toolClient.EXPECT().
+		Analyze(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, request *pluginsv1.AnalyzeRequest, _ ...grpc.CallOption) (*pluginsv1.AnalyzeResponse, error) {
+			switch request.Capability {
+			case "ocr":
+				return &pluginsv1.AnalyzeResponse{
+					Annotations: []*pluginsv1.Annotation{
+						{Value: &pluginsv1.Annotation_BoundingBox{BoundingBox: &pluginsv1.BoundingBoxAnnotation{
+							Box:   &pluginsv1.BoundingBox{X: 0.2, Y: 0.3, Width: 0.4, Height: 0.5},
+							Label: "text",
+						}}},
+					},
+				}, nil
+			case "upscale":
+				upscaleCount++
+				annotations := []*pluginsv1.Annotation{
+					{Value: &pluginsv1.Annotation_DerivedImage{DerivedImage: &pluginsv1.DerivedImageAnnotation{
+						Image:  &pluginsv1.Image{Content: []byte("upscaled"), ContentType: "image/png"},
+						Width:  200,
+						Height: 100,
+					}}},
+				}
+				if upscaleCount == 1 {
+					annotations = append(annotations, &pluginsv1.Annotation{Value: &pluginsv1.Annotation_DerivedImage{DerivedImage: &pluginsv1.DerivedImageAnnotation{
+						Image: &pluginsv1.Image{Content: []byte("upscaled more"), ContentType: "image/png"},
+					}}})
+				}
+				return &pluginsv1.AnalyzeResponse{Annotations: annotations}, nil
+			}
+			return nil, errors.New("unexpected capability")
+		}).
+		AnyTimes()
+
+	registry := NewRegistry(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		dbClient.Client,
+		imageReader,
+		annotationDirectory,
+		[]Analyzer{
+			{Name: "ocr", Client: ocrClient},
+			{Name: "tool", Client: toolClient},
+		},
+	)
+	ctx := context.Background()
+
+	t.Run("capabilities", func(t *testing.T) {
+		got := registry.Capabilities(ctx)
+		assert.Equal(t, []Capability{
+			{
+				PluginName:      "ocr",
+				PluginVersion:   "1.0",
+				Name:            "ocr",
+				AnnotationTypes: []db.FileAnnotationType{db.FileAnnotationTypeText},
+				ContentTypes:    []string{"image/jpeg"},
+			},
+			{
+				PluginName:      "tool",
+				PluginVersion:   "2.0",
+				Name:            "ocr",
+				AnnotationTypes: []db.FileAnnotationType{db.FileAnnotationTypeText, db.FileAnnotationTypeBoundingBox},
+			},
+			{
+				PluginName:      "tool",
+				PluginVersion:   "2.0",
+				Name:            "upscale",
+				AnnotationTypes: []db.FileAnnotationType{db.FileAnnotationTypeDerivedImage},
+			},
+		}, got)
+		// the capabilities are cached
+		assert.Len(t, registry.Capabilities(ctx), 3)
+	})
+
+	t.Run("route images by their content types", func(t *testing.T) {
+		got, err := registry.Analyze(ctx, "ocr", []uint{11, 12})
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.Equal(t, uint(11), got[0].ImageFileID)
+		assert.Equal(t, "ocr", got[0].PluginName)
+		assert.Equal(t, uint(12), got[1].ImageFileID)
+		assert.Equal(t, "tool", got[1].PluginName)
+
+		annotations, err := registry.ReadAnnotations(ctx, []uint{11, 12})
+		require.NoError(t, err)
+		require.Len(t, annotations[11], 1)
+		text := annotations[11][0]
+		assert.Equal(t, db.FileAnnotationTypeText, text.Type)
+		assert.Equal(t, "ocr", text.PluginName)
+		assert.Equal(t, "1.0", text.PluginVersion)
+		assert.Equal(t, "subtitle", text.Text)
+		assert.Equal(t, "ja", text.Language)
+		assert.Equal(t, 0.9, text.Score)
+		assert.Equal(t, []float64{0.1, 0.8, 0.8, 0.1}, []float64{text.X, text.Y, text.Width, text.Height})
+
+		require.Len(t, annotations[12], 1)
+		box := annotations[12][0]
+		assert.Equal(t, db.FileAnnotationTypeBoundingBox, box.Type)
+		assert.Equal(t, "tool", box.PluginName)
+		assert.Equal(t, "text", box.Label)
+		assert.Equal(t, []float64{0.2, 0.3, 0.4, 0.5}, []float64{box.X, box.Y, box.Width, box.Height})
+
+		// analyzing again replaces the annotations
+		_, err = registry.Analyze(ctx, "ocr", []uint{11})
+		require.NoError(t, err)
+		annotations, err = registry.ReadAnnotations(ctx, []uint{11})
+		require.NoError(t, err)
+		assert.Len(t, annotations[11], 1)
+	})
+
+	t.Run("derived images", func(t *testing.T) {
+		got, err := registry.Analyze(ctx, "upscale", []uint{11})
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		require.Len(t, got[0].Annotations, 2)
+		first := got[0].Annotations[0]
+		assert.Equal(t, db.FileAnnotationTypeDerivedImage, first.Type)
+		assert.Equal(t, filepath.Join("tool", "upscale", "11-0.png"), first.DerivedImagePath)
+		content, err := os.ReadFile(registry.DerivedImageFilePath(first))
+		require.NoError(t, err)
+		assert.Equal(t, "upscaled", string(content))
+		second := registry.DerivedImageFilePath(got[0].Annotations[1])
+		assert.FileExists(t, second)
+
+		// a derived image which isn't returned again is deleted
+		got, err = registry.Analyze(ctx, "upscale", []uint{11})
+		require.NoError(t, err)
+		require.Len(t, got[0].Annotations, 1)
+		assert.FileExists(t, registry.DerivedImageFilePath(got[0].Annotations[0]))
+		assert.NoFileExists(t, second)
+
+		annotations, err := registry.ReadAnnotations(ctx, []uint{11})
+		require.NoError(t, err)
+		assert.Len(t, annotations[11], 2)
+	})
+
+	t.Run("an unknown capability", func(t *testing.T) {
+		_, err := registry.Analyze(ctx, "unknown", []uint{11})
+		assert.ErrorIs(t, err, ErrCapabilityNotFound)
+		assert.ErrorIs(t, err, xerrors.ErrInvalidArgument)
+	})
+}
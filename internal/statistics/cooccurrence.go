@@ -0,0 +1,474 @@
+package statistics
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/event"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+)
+
+// Metric is what pairs are ranked by
+type Metric string
+
+const (
+	// MetricLift is how many times more often two labels are on the same
+	// images than if they were independent
+	MetricLift Metric = "lift"
+	// MetricPMI is the pointwise mutual information, log2 of the lift
+	MetricPMI Metric = "pmi"
+	// MetricCount is the number of images with both labels
+	MetricCount Metric = "count"
+	// MetricConfidence is the share of the images with a label which also
+	// have the other one
+	MetricConfidence Metric = "confidence"
+
+	defaultMinCount = 2
+	defaultLimit    = 20
+)
+
+// invalidatingEventTypes are the events after which the statistics are
+// computed again
+var invalidatingEventTypes = []event.Type{
+	event.TypeImagesImported,
+	event.TypeImagesDeleted,
+	event.TypeTagsUpdated,
+	event.TypeAnimeDeleted,
+}
+
+// Service computes how often tags are on the same images as other tags and
+// characters. Only tags and characters added to images themselves are
+// counted, not those of their directories.
+// The statistics of the whole library are cached until a change of tags is
+// published.
+type Service struct {
+	dbClient *db.Client
+
+	mutex  sync.Mutex
+	matrix *cooccurrenceMatrix
+}
+
+func NewService(dbClient *db.Client) *Service {
+	return &Service{
+		dbClient: dbClient,
+	}
+}
+
+// Subscribe invalidates the cache when images or their tags change.
+// The bus delivers events asynchronously, so the statistics can be stale
+// right after a change.
+func (service *Service) Subscribe(bus *event.Bus) {
+	bus.Subscribe("tag statistics", invalidatingEventTypes, func(ctx context.Context, _ event.Event) error {
+		service.Invalidate()
+		return nil
+	})
+}
+
+// Invalidate drops the cache, and the statistics are computed again when
+// they are read next
+func (service *Service) Invalidate() {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	service.matrix = nil
+}
+
+// Score is how strongly a label is associated with another one
+type Score struct {
+	// Count is the number of images with both labels
+	Count uint `json:"count"`
+	// Confidence is the share of the images with the first label which also
+	// have the second one
+	Confidence float64 `json:"confidence"`
+	Lift       float64 `json:"lift"`
+	PMI        float64 `json:"pmi"`
+}
+
+// TagPair is two tags on the same images.
+// The confidence is of OtherTagID given TagID.
+type TagPair struct {
+	TagID        uint   `json:"tagId"`
+	TagName      string `json:"tagName"`
+	OtherTagID   uint   `json:"otherTagId"`
+	OtherTagName string `json:"otherTagName"`
+	Score
+}
+
+// TagCharacterPair is a tag and a character on the same images.
+// The confidence is of TagID given CharacterID.
+type TagCharacterPair struct {
+	TagID         uint   `json:"tagId"`
+	TagName       string `json:"tagName"`
+	CharacterID   uint   `json:"characterId"`
+	CharacterName string `json:"characterName"`
+	Score
+}
+
+// RankingOptions selects the pairs to rank
+type RankingOptions struct {
+	Metric Metric
+	// MinCount excludes pairs on fewer images, whose lift and PMI are
+	// unreliable. 0 is the default, 2.
+	MinCount uint
+	// Limit is the number of pairs. 0 is the default, 20, and a negative
+	// limit returns every pair.
+	Limit int
+	// TagID ranks only the pairs with the tag if it's not 0
+	TagID uint
+}
+
+func (options RankingOptions) withDefaults() (RankingOptions, error) {
+	switch options.Metric {
+	case "":
+		options.Metric = MetricLift
+	case MetricLift, MetricPMI, MetricCount, MetricConfidence:
+	default:
+		return RankingOptions{}, fmt.Errorf("%w: unknown metric %s", xerrors.ErrInvalidArgument, options.Metric)
+	}
+	if options.MinCount == 0 {
+		options.MinCount = defaultMinCount
+	}
+	if options.Limit == 0 {
+		options.Limit = defaultLimit
+	}
+	return options, nil
+}
+
+// RankTagPairs returns the pairs of tags by the metric in descending order.
+// Each pair is returned once, with the tag of the lower ID first unless the
+// options select a tag.
+func (service *Service) RankTagPairs(ctx context.Context, options RankingOptions) ([]TagPair, error) {
+	options, err := options.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	matrix, err := service.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make([]TagPair, 0)
+	for tagID, otherTags := range matrix.tagTags {
+		if options.TagID != 0 && tagID != options.TagID {
+			continue
+		}
+		for otherTagID, count := range otherTags {
+			if options.TagID == 0 && otherTagID < tagID {
+				continue
+			}
+			if count < options.MinCount {
+				continue
+			}
+			pairs = append(pairs, TagPair{
+				TagID:        tagID,
+				TagName:      matrix.tagNames[tagID],
+				OtherTagID:   otherTagID,
+				OtherTagName: matrix.tagNames[otherTagID],
+				Score:        matrix.score(count, matrix.tagCounts[tagID], matrix.tagCounts[otherTagID]),
+			})
+		}
+	}
+	slices.SortFunc(pairs, func(a, b TagPair) int {
+		return compareScores(options.Metric, a.Score, b.Score, [2]uint{a.TagID, a.OtherTagID}, [2]uint{b.TagID, b.OtherTagID})
+	})
+	return limit(pairs, options.Limit), nil
+}
+
+// RankTagCharacterPairs returns the pairs of a tag and a character by the
+// metric in descending order
+func (service *Service) RankTagCharacterPairs(ctx context.Context, options RankingOptions) ([]TagCharacterPair, error) {
+	options, err := options.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	matrix, err := service.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make([]TagCharacterPair, 0)
+	for characterID, tags := range matrix.characterTags {
+		for tagID, count := range tags {
+			if options.TagID != 0 && tagID != options.TagID {
+				continue
+			}
+			if count < options.MinCount {
+				continue
+			}
+			pairs = append(pairs, TagCharacterPair{
+				TagID:         tagID,
+				TagName:       matrix.tagNames[tagID],
+				CharacterID:   characterID,
+				CharacterName: matrix.characterNames[characterID],
+				Score:         matrix.score(count, matrix.characterCounts[characterID], matrix.tagCounts[tagID]),
+			})
+		}
+	}
+	slices.SortFunc(pairs, func(a, b TagCharacterPair) int {
+		return compareScores(options.Metric, a.Score, b.Score, [2]uint{a.TagID, a.CharacterID}, [2]uint{b.TagID, b.CharacterID})
+	})
+	return limit(pairs, options.Limit), nil
+}
+
+// RelatedTag is a tag frequently used together with the tags or the
+// characters of images. It's related by either SourceTagID or
+// SourceCharacterID, with the highest confidence.
+type RelatedTag struct {
+	TagID             uint   `json:"tagId"`
+	TagName           string `json:"tagName"`
+	SourceTagID       uint   `json:"sourceTagId,omitempty"`
+	SourceCharacterID uint   `json:"sourceCharacterId,omitempty"`
+	Score
+}
+
+// ReadRelatedTags returns the tags frequently used together with the tags and
+// the characters of the images, by the confidence unless the options set a
+// metric. Tags which every image already has are excluded.
+func (service *Service) ReadRelatedTags(ctx context.Context, imageFileIDs []uint, options RankingOptions) ([]RelatedTag, error) {
+	if options.Metric == "" {
+		options.Metric = MetricConfidence
+	}
+	options, err := options.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	if len(imageFileIDs) == 0 {
+		return []RelatedTag{}, nil
+	}
+	fileTags, err := service.dbClient.FileTag().FindAllByFileID(imageFileIDs)
+	if err != nil {
+		return nil, fmt.Errorf("FileTag.FindAllByFileID: %w", err)
+	}
+	fileCharacters, err := service.dbClient.FileCharacter().FindByFileIDs(imageFileIDs)
+	if err != nil {
+		return nil, fmt.Errorf("FileCharacter.FindByFileIDs: %w", err)
+	}
+	matrix, err := service.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tagFileCounts := make(map[uint]int)
+	for _, fileTag := range fileTags {
+		tagFileCounts[fileTag.TagID]++
+	}
+	characterIDs := make(map[uint]struct{})
+	for _, fileCharacter := range fileCharacters {
+		characterIDs[fileCharacter.CharacterID] = struct{}{}
+	}
+
+	related := make(map[uint]RelatedTag)
+	addRelated := func(candidate RelatedTag) {
+		if tagFileCounts[candidate.TagID] == len(imageFileIDs) || candidate.Count < options.MinCount {
+			return
+		}
+		current, ok := related[candidate.TagID]
+		if ok && (current.Confidence > candidate.Confidence ||
+			(current.Confidence == candidate.Confidence && current.Lift >= candidate.Lift)) {
+			return
+		}
+		related[candidate.TagID] = candidate
+	}
+	// sources are visited in order, so that the first of those with the same
+	// score is kept
+	sourceTagIDs := slices.Sorted(maps.Keys(tagFileCounts))
+	sourceCharacterIDs := slices.Sorted(maps.Keys(characterIDs))
+	for _, sourceTagID := range sourceTagIDs {
+		for tagID, count := range matrix.tagTags[sourceTagID] {
+			addRelated(RelatedTag{
+				TagID:       tagID,
+				TagName:     matrix.tagNames[tagID],
+				SourceTagID: sourceTagID,
+				Score:       matrix.score(count, matrix.tagCounts[sourceTagID], matrix.tagCounts[tagID]),
+			})
+		}
+	}
+	for _, sourceCharacterID := range sourceCharacterIDs {
+		for tagID, count := range matrix.characterTags[sourceCharacterID] {
+			addRelated(RelatedTag{
+				TagID:             tagID,
+				TagName:           matrix.tagNames[tagID],
+				SourceCharacterID: sourceCharacterID,
+				Score:             matrix.score(count, matrix.characterCounts[sourceCharacterID], matrix.tagCounts[tagID]),
+			})
+		}
+	}
+
+	result := make([]RelatedTag, 0, len(related))
+	for _, relatedTag := range related {
+		result = append(result, relatedTag)
+	}
+	slices.SortFunc(result, func(a, b RelatedTag) int {
+		return compareScores(options.Metric, a.Score, b.Score, [2]uint{a.TagID}, [2]uint{b.TagID})
+	})
+	return limit(result, options.Limit), nil
+}
+
+// cooccurrenceMatrix counts the tags and the characters of every image
+type cooccurrenceMatrix struct {
+	// imageCount is the number of images with any tag or character
+	imageCount      uint
+	tagCounts       map[uint]uint
+	characterCounts map[uint]uint
+	// tagTags counts the images with both tags, in both directions
+	tagTags map[uint]map[uint]uint
+	// characterTags counts the images with both a character and a tag
+	characterTags map[uint]map[uint]uint
+
+	tagNames       map[uint]string
+	characterNames map[uint]string
+}
+
+func (service *Service) read(ctx context.Context) (*cooccurrenceMatrix, error) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	if service.matrix != nil {
+		return service.matrix, nil
+	}
+	matrix, err := service.compute(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("compute: %w", err)
+	}
+	service.matrix = matrix
+	return matrix, nil
+}
+
+func (service *Service) compute(ctx context.Context) (*cooccurrenceMatrix, error) {
+	imageFiles, err := service.dbClient.File().FindAllImageFiles()
+	if err != nil {
+		return nil, fmt.Errorf("File.FindAllImageFiles: %w", err)
+	}
+	fileTags, err := service.dbClient.FileTag().GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("FileTag.GetAll: %w", err)
+	}
+	fileCharacters, err := service.dbClient.FileCharacter().GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("FileCharacter.GetAll: %w", err)
+	}
+	tags, err := service.dbClient.Tag().GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("Tag.GetAll: %w", err)
+	}
+	characters, err := service.dbClient.Character().GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("Character.GetAll: %w", err)
+	}
+
+	// tags of directories are in file_tags too
+	isImage := make(map[uint]bool, len(imageFiles))
+	for _, imageFile := range imageFiles {
+		isImage[imageFile.ID] = true
+	}
+	imageTags := make(map[uint][]uint)
+	for _, fileTag := range fileTags {
+		if isImage[fileTag.FileID] {
+			imageTags[fileTag.FileID] = append(imageTags[fileTag.FileID], fileTag.TagID)
+		}
+	}
+	imageCharacters := make(map[uint][]uint)
+	for _, fileCharacter := range fileCharacters {
+		if isImage[fileCharacter.FileID] {
+			imageCharacters[fileCharacter.FileID] = append(imageCharacters[fileCharacter.FileID], fileCharacter.CharacterID)
+		}
+	}
+
+	matrix := &cooccurrenceMatrix{
+		tagCounts:       make(map[uint]uint),
+		characterCounts: make(map[uint]uint),
+		tagTags:         make(map[uint]map[uint]uint),
+		characterTags:   make(map[uint]map[uint]uint),
+		tagNames:        make(map[uint]string, len(tags)),
+		characterNames:  make(map[uint]string, len(characters)),
+	}
+	for _, tag := range tags {
+		matrix.tagNames[tag.ID] = tag.Name
+	}
+	for _, character := range characters {
+		matrix.characterNames[character.ID] = character.Name
+	}
+	for _, imageFile := range imageFiles {
+		tagIDs := imageTags[imageFile.ID]
+		characterIDs := imageCharacters[imageFile.ID]
+		if len(tagIDs) == 0 && len(characterIDs) == 0 {
+			continue
+		}
+		matrix.imageCount++
+		for index, tagID := range tagIDs {
+			matrix.tagCounts[tagID]++
+			for _, otherTagID := range tagIDs[index+1:] {
+				increment(matrix.tagTags, tagID, otherTagID)
+				increment(matrix.tagTags, otherTagID, tagID)
+			}
+		}
+		for _, characterID := range characterIDs {
+			matrix.characterCounts[characterID]++
+			for _, tagID := range tagIDs {
+				increment(matrix.characterTags, characterID, tagID)
+			}
+		}
+	}
+	return matrix, nil
+}
+
+func increment(counts map[uint]map[uint]uint, key uint, otherKey uint) {
+	if counts[key] == nil {
+		counts[key] = make(map[uint]uint)
+	}
+	counts[key][otherKey]++
+}
+
+// score of the second label given the first one
+func (matrix *cooccurrenceMatrix) score(count uint, firstCount uint, secondCount uint) Score {
+	lift := float64(count) * float64(matrix.imageCount) / (float64(firstCount) * float64(secondCount))
+	return Score{
+		Count:      count,
+		Confidence: float64(count) / float64(firstCount),
+		Lift:       lift,
+		PMI:        math.Log2(lift),
+	}
+}
+
+// compareScores sorts scores by the metric in descending order, and then by
+// the lift, the count and IDs
+func compareScores(metric Metric, a Score, b Score, idsA [2]uint, idsB [2]uint) int {
+	var valueA, valueB float64
+	switch metric {
+	case MetricLift:
+		valueA, valueB = a.Lift, b.Lift
+	case MetricPMI:
+		valueA, valueB = a.PMI, b.PMI
+	case MetricCount:
+		valueA, valueB = float64(a.Count), float64(b.Count)
+	case MetricConfidence:
+		valueA, valueB = a.Confidence, b.Confidence
+	}
+	for _, values := range [][2]float64{{valueA, valueB}, {a.Lift, b.Lift}, {float64(a.Count), float64(b.Count)}} {
+		if values[0] > values[1] {
+			return -1
+		}
+		if values[0] < values[1] {
+			return 1
+		}
+	}
+	for index := range idsA {
+		if idsA[index] != idsB[index] {
+			if idsA[index] < idsB[index] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func limit[T any](values []T, count int) []T {
+	if count >= 0 && len(values) > count {
+		return values[:count]
+	}
+	return values
+}
@@ -0,0 +1,249 @@
+package statistics
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/event"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestLibrary(t *testing.T) db.TestClient {
+	t.Helper()
+	dbClient := db.NewTestClient(t)
+	dbClient.Truncate(t, &db.File{}, &db.Tag{}, &db.FileTag{}, &db.Character{}, &db.FileCharacter{})
+	db.LoadTestData(t, dbClient, []db.File{
+		{ID: 100, Name: "Fate", Type: db.FileTypeDirectory},
+		{ID: 1, Name: "1.jpg", ParentID: 100, Type: db.FileTypeImage},
+		{ID: 2, Name: "2.jpg", ParentID: 100, Type: db.FileTypeImage},
+		{ID: 3, Name: "3.jpg", ParentID: 100, Type: db.FileTypeImage},
+		{ID: 4, Name: "4.jpg", ParentID: 100, Type: db.FileTypeImage},
+		{ID: 5, Name: "5.jpg", ParentID: 100, Type: db.FileTypeImage},
+		{ID: 6, Name: "6.jpg", ParentID: 100, Type: db.FileTypeImage},
+		{ID: 7, Name: "7.jpg", ParentID: 100, Type: db.FileTypeImage},
+		// an image without tags or characters isn't counted
+		{ID: 8, Name: "8.jpg", ParentID: 100, Type: db.FileTypeImage},
+	})
+	db.LoadTestData(t, dbClient, []db.Tag{
+		{ID: 1, Name: "sword"},
+		{ID: 2, Name: "armor"},
+		{ID: 3, Name: "smile"},
+		{ID: 4, Name: "night"},
+		{ID: 9, Name: "directory tag"},
+	})
+	db.LoadTestData(t, dbClient, []db.FileTag{
+		{FileID: 1, TagID: 1}, {FileID: 1, TagID: 2},
+		{FileID: 2, TagID: 1}, {FileID: 2, TagID: 2}, {FileID: 2, TagID: 3},
+		{FileID: 3, TagID: 1}, {FileID: 3, TagID: 2},
+		{FileID: 4, TagID: 3}, {FileID: 4, TagID: 4},
+		{FileID: 5, TagID: 3}, {FileID: 5, TagID: 4},
+		{FileID: 6, TagID: 3},
+		// a tag of a directory isn't counted
+		{FileID: 100, TagID: 9}, {FileID: 100, TagID: 1},
+	})
+	db.LoadTestData(t, dbClient, []db.Character{
+		{ID: 1, Name: "Saber", AnimeID: 1},
+	})
+	db.LoadTestData(t, dbClient, []db.FileCharacter{
+		{FileID: 1, CharacterID: 1},
+		{FileID: 2, CharacterID: 1},
+		{FileID: 3, CharacterID: 1},
+		{FileID: 7, CharacterID: 1},
+	})
+	return dbClient
+}
+
+func TestService_RankTagPairs(t *testing.T) {
+	dbClient := loadTestLibrary(t)
+	service := NewService(dbClient.Client)
+
+	swordArmor := TagPair{
+		TagID: 1, TagName: "sword", OtherTagID: 2, OtherTagName: "armor",
+		// 3 * 7 images / (3 * 3)
+		Score: Score{Count: 3, Confidence: 1, Lift: 7.0 / 3, PMI: math.Log2(7.0 / 3)},
+	}
+	smileNight := TagPair{
+		TagID: 3, TagName: "smile", OtherTagID: 4, OtherTagName: "night",
+		// 2 * 7 images / (4 * 2)
+		Score: Score{Count: 2, Confidence: 0.5, Lift: 1.75, PMI: math.Log2(1.75)},
+	}
+
+	testCases := []struct {
+		name    string
+		options RankingOptions
+		want    []TagPair
+		wantErr error
+	}{
+		{
+			name: "by lift, without pairs on a single image",
+			want: []TagPair{swordArmor, smileNight},
+		},
+		{
+			name:    "by count",
+			options: RankingOptions{Metric: MetricCount, Limit: 1},
+			want:    []TagPair{swordArmor},
+		},
+		{
+			name:    "pairs of a tag",
+			options: RankingOptions{Metric: MetricPMI, MinCount: 1, TagID: 3},
+			want: []TagPair{
+				{
+					TagID: 3, TagName: "smile", OtherTagID: 4, OtherTagName: "night",
+					Score: smileNight.Score,
+				},
+				{
+					TagID: 3, TagName: "smile", OtherTagID: 1, OtherTagName: "sword",
+					// 1 * 7 / (4 * 3)
+					Score: Score{Count: 1, Confidence: 0.25, Lift: 7.0 / 12, PMI: math.Log2(7.0 / 12)},
+				},
+				{
+					TagID: 3, TagName: "smile", OtherTagID: 2, OtherTagName: "armor",
+					Score: Score{Count: 1, Confidence: 0.25, Lift: 7.0 / 12, PMI: math.Log2(7.0 / 12)},
+				},
+			},
+		},
+		{
+			name:    "unknown metric",
+			options: RankingOptions{Metric: "unknown"},
+			wantErr: xerrors.ErrInvalidArgument,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := service.RankTagPairs(context.Background(), tc.options)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestService_RankTagCharacterPairs(t *testing.T) {
+	dbClient := loadTestLibrary(t)
+	service := NewService(dbClient.Client)
+
+	got, err := service.RankTagCharacterPairs(context.Background(), RankingOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []TagCharacterPair{
+		{
+			TagID: 1, TagName: "sword", CharacterID: 1, CharacterName: "Saber",
+			// 3 * 7 / (4 * 3)
+			Score: Score{Count: 3, Confidence: 0.75, Lift: 1.75, PMI: math.Log2(1.75)},
+		},
+		{
+			TagID: 2, TagName: "armor", CharacterID: 1, CharacterName: "Saber",
+			Score: Score{Count: 3, Confidence: 0.75, Lift: 1.75, PMI: math.Log2(1.75)},
+		},
+	}, got)
+}
+
+func TestService_ReadRelatedTags(t *testing.T) {
+	dbClient := loadTestLibrary(t)
+	service := NewService(dbClient.Client)
+
+	testCases := []struct {
+		name         string
+		imageFileIDs []uint
+		want         []RelatedTag
+	}{
+		{
+			name:         "tags used together with a tag",
+			imageFileIDs: []uint{6},
+			want: []RelatedTag{
+				{
+					TagID: 4, TagName: "night", SourceTagID: 3,
+					Score: Score{Count: 2, Confidence: 0.5, Lift: 1.75, PMI: math.Log2(1.75)},
+				},
+			},
+		},
+		{
+			name:         "tags which some of the images have",
+			imageFileIDs: []uint{1, 6},
+			want: []RelatedTag{
+				{
+					// more confident than by the character Saber
+					TagID: 1, TagName: "sword", SourceTagID: 2,
+					Score: Score{Count: 3, Confidence: 1, Lift: 7.0 / 3, PMI: math.Log2(7.0 / 3)},
+				},
+				{
+					TagID: 2, TagName: "armor", SourceTagID: 1,
+					Score: Score{Count: 3, Confidence: 1, Lift: 7.0 / 3, PMI: math.Log2(7.0 / 3)},
+				},
+				{
+					TagID: 4, TagName: "night", SourceTagID: 3,
+					Score: Score{Count: 2, Confidence: 0.5, Lift: 1.75, PMI: math.Log2(1.75)},
+				},
+			},
+		},
+		{
+			name:         "tags by a character",
+			imageFileIDs: []uint{7},
+			want: []RelatedTag{
+				{
+					TagID: 1, TagName: "sword", SourceCharacterID: 1,
+					Score: Score{Count: 3, Confidence: 0.75, Lift: 1.75, PMI: math.Log2(1.75)},
+				},
+				{
+					TagID: 2, TagName: "armor", SourceCharacterID: 1,
+					Score: Score{Count: 3, Confidence: 0.75, Lift: 1.75, PMI: math.Log2(1.75)},
+				},
+			},
+		},
+		{
+			name:         "an image without tags or characters",
+			imageFileIDs: []uint{8},
+			want:         []RelatedTag{},
+		},
+		{
+			name:         "every image has the related tags",
+			imageFileIDs: []uint{4, 5},
+			want:         []RelatedTag{},
+		},
+		{
+			name: "no image",
+			want: []RelatedTag{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := service.ReadRelatedTags(context.Background(), tc.imageFileIDs, RankingOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestService_Subscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dbClient := loadTestLibrary(t)
+	service := NewService(dbClient.Client)
+	bus := event.NewBus(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	service.Subscribe(bus)
+	bus.Start(ctx)
+
+	countNightPairs := func() int {
+		pairs, err := service.RankTagPairs(ctx, RankingOptions{TagID: 4, MinCount: 1})
+		require.NoError(t, err)
+		return len(pairs)
+	}
+	require.Equal(t, 1, countNightPairs())
+
+	db.LoadTestData(t, dbClient, []db.FileTag{{FileID: 6, TagID: 4}, {FileID: 1, TagID: 4}})
+	assert.Equal(t, 1, countNightPairs(), "the statistics are cached")
+
+	bus.Publish(ctx, event.TypeTagsUpdated, event.TagsUpdated{})
+	assert.Eventually(t, func() bool {
+		return countNightPairs() == 3
+	}, time.Second, 10*time.Millisecond)
+}
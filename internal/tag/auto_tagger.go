@@ -0,0 +1,342 @@
+package tag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/event"
+	"github.com/michael-freling/anime-image-viewer/internal/history"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+)
+
+// ErrAutoTaggingInProgress is returned when auto-tagging is requested while
+// another run is still going.
+var ErrAutoTaggingInProgress = errors.New("auto-tagging is already in progress")
+
+// AutoTaggingResult is what one auto-tagging run did
+type AutoTaggingResult struct {
+	ImageCount   int `json:"imageCount"`
+	AppliedCount int `json:"appliedCount"`
+	QueuedCount  int `json:"queuedCount"`
+}
+
+func (result *AutoTaggingResult) add(other AutoTaggingResult) {
+	result.ImageCount += other.ImageCount
+	result.AppliedCount += other.AppliedCount
+	result.QueuedCount += other.QueuedCount
+}
+
+// AutoTagger suggests tags for untagged and newly imported images in the
+// background, on an interval and when images are imported. Tags scored above their thresholds are added to images as
+// added by a suggestion, and those with a lower score above the review
+// threshold are queued as SuggestionReview for a user.
+//
+// Each image is auto-tagged once. Before the first run, only untagged
+// images are, so that a library tagged by hand isn't changed. An image that
+// can't be read is skipped and isn't tried again, while one the plugin didn't
+// respond for is tried again on the next run.
+type AutoTagger struct {
+	logger            *slog.Logger
+	dbClient          *db.Client
+	suggestionService *SuggestionService
+	journal           *history.Journal
+	config            config.AutoTaggingConfig
+
+	// mutex serialises the scheduled runs and the runs on imports with ones
+	// requested by a user
+	mutex sync.Mutex
+	now   func() time.Time
+}
+
+// NewAutoTagger creates an AutoTagger. suggestionService may be nil, if no
+// plugin is configured. The tags it adds are recorded in journal, so that
+// they can be undone like the tags added by a user.
+func NewAutoTagger(
+	logger *slog.Logger,
+	dbClient *db.Client,
+	suggestionService *SuggestionService,
+	journal *history.Journal,
+	conf config.Config,
+) *AutoTagger {
+	return &AutoTagger{
+		logger:            logger,
+		dbClient:          dbClient,
+		suggestionService: suggestionService,
+		journal:           journal,
+		config:            conf.TagSuggestion.AutoTagging,
+		now:               time.Now,
+	}
+}
+
+// Start launches the auto-tagging loop. It returns immediately, and the loop
+// stops when ctx is cancelled.
+func (autoTagger *AutoTagger) Start(ctx context.Context) {
+	if !autoTagger.config.Enabled {
+		autoTagger.logger.InfoContext(ctx, "auto-tagging is disabled")
+		return
+	}
+	if autoTagger.suggestionService == nil {
+		autoTagger.logger.WarnContext(ctx, "auto-tagging is enabled without a tag suggestion plugin")
+		return
+	}
+	go autoTagger.run(ctx)
+}
+
+// Subscribe auto-tags images once they are imported, instead of on the next
+// scheduled run. Images imported while a run is going are left to it or to
+// the next one.
+func (autoTagger *AutoTagger) Subscribe(bus *event.Bus) {
+	if !autoTagger.config.Enabled || autoTagger.suggestionService == nil {
+		return
+	}
+	bus.Subscribe("auto-tagging", []event.Type{event.TypeImagesImported}, func(ctx context.Context, _ event.Event) error {
+		return autoTagger.runAndLog(ctx)
+	})
+}
+
+func (autoTagger *AutoTagger) interval() time.Duration {
+	return time.Duration(autoTagger.config.IntervalMinutes) * time.Minute
+}
+
+func (autoTagger *AutoTagger) run(ctx context.Context) {
+	ticker := time.NewTicker(autoTagger.interval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := autoTagger.runAndLog(ctx); err != nil {
+			autoTagger.logger.ErrorContext(ctx, "auto-tagging failed", "error", err)
+		}
+	}
+}
+
+// runAndLog runs auto-tagging unless it's in progress, and logs what it did
+func (autoTagger *AutoTagger) runAndLog(ctx context.Context) error {
+	result, err := autoTagger.Run(ctx)
+	if errors.Is(err, ErrAutoTaggingInProgress) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if result.ImageCount > 0 {
+		autoTagger.logger.InfoContext(ctx, "auto-tagged images",
+			"images", result.ImageCount,
+			"applied", result.AppliedCount,
+			"queued", result.QueuedCount,
+		)
+	}
+	return nil
+}
+
+// Run suggests tags for every image which wasn't auto-tagged yet, in batches.
+// The batches finished before an error are kept.
+func (autoTagger *AutoTagger) Run(ctx context.Context) (AutoTaggingResult, error) {
+	if autoTagger.suggestionService == nil {
+		return AutoTaggingResult{}, fmt.Errorf("tag suggestion service is not available")
+	}
+	if !autoTagger.mutex.TryLock() {
+		return AutoTaggingResult{}, ErrAutoTaggingInProgress
+	}
+	defer autoTagger.mutex.Unlock()
+
+	runClient := autoTagger.dbClient.AutoTaggingRun()
+	since, err := runClient.FindFirstStartedAt(ctx)
+	if err != nil {
+		return AutoTaggingResult{}, fmt.Errorf("AutoTaggingRun.FindFirstStartedAt: %w", err)
+	}
+	run := db.AutoTaggingRun{
+		StartedAt: uint(autoTagger.now().Unix()),
+	}
+	if since == 0 {
+		// the first run is saved before tagging any image, so that images
+		// imported during it are auto-tagged by the next run
+		since = run.StartedAt
+		if err := runClient.Create(ctx, &run); err != nil {
+			return AutoTaggingResult{}, fmt.Errorf("AutoTaggingRun.Create: %w", err)
+		}
+	}
+
+	result, err := autoTagger.tagUntaggedImages(ctx, since)
+	if run.ID == 0 && result.ImageCount == 0 {
+		return result, err
+	}
+	run.FinishedAt = uint(autoTagger.now().Unix())
+	run.ImageCount = result.ImageCount
+	run.AppliedCount = result.AppliedCount
+	run.QueuedCount = result.QueuedCount
+	if saveErr := runClient.Update(ctx, &run); saveErr != nil {
+		return result, errors.Join(err, fmt.Errorf("AutoTaggingRun.Update: %w", saveErr))
+	}
+	return result, err
+}
+
+// tagUntaggedImages suggests tags for the images which weren't auto-tagged yet
+// and either have no tags or were created at or after since
+func (autoTagger *AutoTagger) tagUntaggedImages(ctx context.Context, since uint) (AutoTaggingResult, error) {
+	autoTaggedFileClient := autoTagger.dbClient.AutoTaggedFile()
+	var result AutoTaggingResult
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		imageFileIDs, err := autoTaggedFileClient.FindUntaggedImageIDs(ctx, since, autoTagger.config.BatchSize)
+		if err != nil {
+			return result, fmt.Errorf("AutoTaggedFile.FindUntaggedImageIDs: %w", err)
+		}
+		if len(imageFileIDs) == 0 {
+			return result, nil
+		}
+
+		batchResult, err := autoTagger.tagBatch(ctx, imageFileIDs)
+		if err != nil {
+			return result, fmt.Errorf("tagBatch: %w", err)
+		}
+		result.add(batchResult)
+		if batchResult.ImageCount == 0 {
+			// the plugin responded for none of the images, which would be
+			// selected again
+			return result, nil
+		}
+	}
+}
+
+// threshold returns the score to add a tag without a review
+func (autoTagger *AutoTagger) threshold(tag Tag) float64 {
+	if threshold, ok := autoTagger.config.TagThresholds[tag.Name]; ok {
+		return threshold
+	}
+	return autoTagger.config.Threshold
+}
+
+// tagBatch suggests tags for the images and marks those the plugin responded
+// for as auto-tagged. If an image can't be read, each image is suggested for
+// alone to find it, and it is marked without tags.
+func (autoTagger *AutoTagger) tagBatch(ctx context.Context, imageFileIDs []uint) (AutoTaggingResult, error) {
+	response, err := autoTagger.suggestionService.SuggestTags(ctx, imageFileIDs)
+	if errors.Is(err, ErrUnreadableImage) || errors.Is(err, image.ErrImageFileNotFound) {
+		if len(imageFileIDs) > 1 {
+			var result AutoTaggingResult
+			for _, imageFileID := range imageFileIDs {
+				imageResult, err := autoTagger.tagBatch(ctx, []uint{imageFileID})
+				if err != nil {
+					return result, err
+				}
+				result.add(imageResult)
+			}
+			return result, nil
+		}
+
+		autoTagger.logger.WarnContext(ctx, "skipped auto-tagging an image which can't be read",
+			"imageFileID", imageFileIDs[0],
+			"error", err,
+		)
+		if err := autoTagger.dbClient.AutoTaggedFile().Create(ctx, &db.AutoTaggedFile{
+			FileID:    imageFileIDs[0],
+			CreatedAt: uint(autoTagger.now().Unix()),
+		}); err != nil {
+			return AutoTaggingResult{}, fmt.Errorf("AutoTaggedFile.Create: %w", err)
+		}
+		return AutoTaggingResult{ImageCount: 1}, nil
+	}
+	if err != nil {
+		return AutoTaggingResult{}, fmt.Errorf("suggestionService.SuggestTags: %w", err)
+	}
+
+	fileTags := make([]db.FileTag, 0)
+	events := make([]db.SuggestionEvent, 0)
+	reviews := make([]db.SuggestionReview, 0)
+	autoTaggedFiles := make([]db.AutoTaggedFile, 0, len(imageFileIDs))
+	now := uint(autoTagger.now().Unix())
+	for _, imageFileID := range imageFileIDs {
+		suggestions, ok := response.Suggestions[imageFileID]
+		if !ok {
+			// the plugin didn't respond for the image
+			continue
+		}
+		// the time of the first run is when images start being auto-tagged
+		// even if they have tags, by the same clock
+		autoTaggedFiles = append(autoTaggedFiles, db.AutoTaggedFile{
+			FileID:       imageFileID,
+			ModelVersion: response.Model.Version,
+			CreatedAt:    now,
+		})
+
+		for _, suggestion := range suggestions {
+			if suggestion.HasTag {
+				continue
+			}
+			if suggestion.Score >= autoTagger.threshold(response.AllTags[suggestion.TagID]) {
+				fileTags = append(fileTags, db.FileTag{
+					FileID:  imageFileID,
+					TagID:   suggestion.TagID,
+					AddedBy: db.FileTagAddedBySuggestion,
+				})
+				events = append(events, db.SuggestionEvent{
+					FileID:       imageFileID,
+					TagID:        suggestion.TagID,
+					Score:        suggestion.Score,
+					ModelName:    response.Model.Name,
+					ModelVersion: response.Model.Version,
+					Decision:     db.SuggestionDecisionAutoApplied,
+				})
+				continue
+			}
+			if suggestion.Score >= autoTagger.config.ReviewThreshold {
+				reviews = append(reviews, db.SuggestionReview{
+					FileID:       imageFileID,
+					TagID:        suggestion.TagID,
+					Score:        suggestion.Score,
+					ModelName:    response.Model.Name,
+					ModelVersion: response.Model.Version,
+				})
+			}
+		}
+	}
+	if len(autoTaggedFiles) == 0 {
+		return AutoTaggingResult{}, nil
+	}
+
+	err = db.NewTransaction(ctx, autoTagger.dbClient, func(ctx context.Context) error {
+		if len(fileTags) > 0 {
+			if err := autoTagger.dbClient.FileTag().BatchCreate(ctx, fileTags); err != nil {
+				return fmt.Errorf("FileTag.BatchCreate: %w", err)
+			}
+			if err := autoTagger.dbClient.SuggestionEvent().BatchCreate(ctx, events); err != nil {
+				return fmt.Errorf("SuggestionEvent.BatchCreate: %w", err)
+			}
+		}
+		if len(reviews) > 0 {
+			if err := autoTagger.dbClient.SuggestionReview().BatchCreateIgnoringExisting(ctx, reviews); err != nil {
+				return fmt.Errorf("SuggestionReview.BatchCreateIgnoringExisting: %w", err)
+			}
+		}
+		if err := autoTagger.dbClient.AutoTaggedFile().BatchCreate(ctx, autoTaggedFiles); err != nil {
+			return fmt.Errorf("AutoTaggedFile.BatchCreate: %w", err)
+		}
+		return autoTagger.journal.Record(ctx, history.KindAutoTagImages,
+			fmt.Sprintf("Auto-tagged %d images with %d tags", len(autoTaggedFiles), len(fileTags)),
+			history.Change{
+				AddedFileTags: fileTags,
+			},
+		)
+	})
+	if err != nil {
+		return AutoTaggingResult{}, err
+	}
+	return AutoTaggingResult{
+		ImageCount:   len(autoTaggedFiles),
+		AppliedCount: len(fileTags),
+		QueuedCount:  len(reviews),
+	}, nil
+}
@@ -0,0 +1,454 @@
+package tag
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/event"
+	"github.com/michael-freling/anime-image-viewer/internal/history"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/michael-freling/anime-image-viewer/internal/xassert"
+	tag_suggestionv1 "github.com/michael-freling/anime-image-viewer/plugins/plugins-protos/gen/go/tag_suggestion/v1"
+	tag_suggestionv2 "github.com/michael-freling/anime-image-viewer/plugins/plugins-protos/gen/go/tag_suggestion/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
+)
+
+func TestAutoTagger_Run(t *testing.T) {
+	tester := newTester(t)
+	tester.newFileCreator(t).
+		CreateDirectory(image.Directory{ID: 1, Name: "Directory 1"}).
+		CreateImage(image.ImageFile{ID: 11, Name: "image11.jpg", ParentID: 1, ContentType: "image/jpeg"}, image.TestImageFileJpeg).
+		CreateImage(image.ImageFile{ID: 12, Name: "image12.jpg", ParentID: 1, ContentType: "image/jpeg"}, image.TestImageFileJpeg).
+		CreateImage(image.ImageFile{ID: 13, Name: "image13.jpg", ParentID: 1, ContentType: "image/jpeg"}, image.TestImageFileJpeg)
+
+	tester.dbClient.Truncate(&db.File{}, &db.Tag{}, &db.FileTag{}, &db.SuggestionEvent{}, &db.SuggestionReview{}, &db.AutoTaggedFile{}, &db.AutoTaggingRun{}, &db.Operation{})
+	t.Cleanup(func() {
+		tester.dbClient.Truncate(&db.File{}, &db.Tag{}, &db.FileTag{}, &db.SuggestionEvent{}, &db.SuggestionReview{}, &db.AutoTaggedFile{}, &db.AutoTaggingRun{}, &db.Operation{})
+	})
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.File{
+		{ID: 1, Name: "Directory 1", Type: db.FileTypeDirectory},
+		{ID: 11, Name: "image11.jpg", Type: db.FileTypeImage, ParentID: 1},
+		{ID: 12, Name: "image12.jpg", Type: db.FileTypeImage, ParentID: 1},
+		{ID: 13, Name: "image13.jpg", Type: db.FileTypeImage, ParentID: 1},
+	}))
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.Tag{
+		{ID: 1, Name: "tag1"},
+		{ID: 2, Name: "tag2"},
+		{ID: 3, Name: "tag3"},
+	}))
+	// an image tagged before auto-tagging is enabled isn't auto-tagged
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.FileTag{
+		{FileID: 12, TagID: 1, AddedBy: db.FileTagAddedByUser},
+	}))
+
+	model := &tag_suggestionv2.Model{Name: "tag-suggestion", Version: "2024-12-01"}
+	suggestionService := tester.getTagSuggestionServiceV2(t, 0, func(mock *tag_suggestionv2.MockTagSuggestionServiceClient) {
+		mock.EXPECT().
+			GetModel(gomock.Any(), gomock.Any()).
+			Return(&tag_suggestionv2.GetModelResponse{
+				Model: model,
+				Tags: []*tag_suggestionv2.Tag{
+					{Id: 1, Name: "tag1"},
+					{Id: 2, Name: "tag2"},
+					{Id: 3, Name: "tag3"},
+				},
+			}, nil)
+		mock.EXPECT().
+			Suggest(gomock.Any()).
+			Return(newFakeSuggestStream([]*tag_suggestionv2.SuggestResponse{
+				{ImageId: 11, Scores: []*tag_suggestionv2.SuggestionScore{
+					{TagId: 1, Score: 0.95},
+					{TagId: 3, Score: 0.7},
+					{TagId: 2, Score: 0.3},
+				}},
+				{ImageId: 13, Scores: []*tag_suggestionv2.SuggestionScore{
+					{TagId: 1, Score: 0.85},
+					{TagId: 2, Score: 0.65},
+				}},
+			}, nil), nil)
+	})
+	journal := history.NewJournal(tester.dbClient, config.HistoryConfig{UserName: "tester", UndoLimit: 10})
+	autoTagger := NewAutoTagger(tester.logger, tester.dbClient, suggestionService, journal, config.Config{
+		TagSuggestion: config.TagSuggestionConfig{
+			AutoTagging: config.AutoTaggingConfig{
+				Enabled:         true,
+				BatchSize:       10,
+				Threshold:       0.9,
+				TagThresholds:   map[string]float64{"tag2": 0.6},
+				ReviewThreshold: 0.5,
+			},
+		},
+	})
+
+	// the images were created before the first run
+	autoTagger.now = func() time.Time {
+		return time.Now().Add(time.Minute)
+	}
+
+	got, err := autoTagger.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, AutoTaggingResult{ImageCount: 2, AppliedCount: 2, QueuedCount: 2}, got)
+
+	xassert.ElementsMatch(t,
+		[]db.FileTag{
+			{FileID: 11, TagID: 1, AddedBy: db.FileTagAddedBySuggestion},
+			{FileID: 12, TagID: 1, AddedBy: db.FileTagAddedByUser},
+			{FileID: 13, TagID: 2, AddedBy: db.FileTagAddedBySuggestion},
+		},
+		db.MustGetAll[db.FileTag](t, db.TestClient{Client: tester.dbClient}),
+		cmpopts.SortSlices(func(a, b db.FileTag) bool { return a.FileID < b.FileID }),
+		cmpopts.IgnoreFields(db.FileTag{}, "CreatedAt"),
+	)
+	xassert.ElementsMatch(t,
+		[]db.SuggestionReview{
+			{FileID: 11, TagID: 3, Score: 0.7, ModelName: "tag-suggestion", ModelVersion: "2024-12-01"},
+			{FileID: 13, TagID: 1, Score: 0.85, ModelName: "tag-suggestion", ModelVersion: "2024-12-01"},
+		},
+		db.MustGetAll[db.SuggestionReview](t, db.TestClient{Client: tester.dbClient}),
+		cmpopts.SortSlices(func(a, b db.SuggestionReview) bool { return a.FileID < b.FileID }),
+		cmpopts.IgnoreFields(db.SuggestionReview{}, "ID", "CreatedAt"),
+	)
+	events := db.MustGetAll[db.SuggestionEvent](t, db.TestClient{Client: tester.dbClient})
+	require.Len(t, events, 2)
+	for _, event := range events {
+		assert.Equal(t, db.SuggestionDecisionAutoApplied, event.Decision)
+	}
+	operations := db.MustGetAll[db.Operation](t, db.TestClient{Client: tester.dbClient})
+	require.Len(t, operations, 1)
+	assert.Equal(t, string(history.KindAutoTagImages), operations[0].Kind)
+
+	// images are auto-tagged once
+	got, err = autoTagger.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, AutoTaggingResult{}, got)
+
+	t.Run("resolve reviews", func(t *testing.T) {
+		service := tester.getFrontendService(frontendServiceMocks{
+			suggestionService: suggestionService,
+		})
+		reviews, err := service.ReadSuggestionReviews(context.Background(), 0, 0)
+		require.NoError(t, err)
+		require.Len(t, reviews, 2)
+		assert.Equal(t, uint(13), reviews[0].FileID, "a review with a higher score comes first")
+
+		require.NoError(t, service.ResolveSuggestionReviews(context.Background(), ResolveSuggestionReviewsRequest{
+			AcceptedIDs: []uint{reviews[1].ID},
+			RejectedIDs: []uint{reviews[0].ID},
+		}))
+
+		fileTags, err := tester.dbClient.FileTag().FindAllByFileID([]uint{11, 13})
+		require.NoError(t, err)
+		assert.True(t, fileTags.ToFileMap()[11][3].AddedBy == db.FileTagAddedBySuggestion)
+		assert.Len(t, fileTags, 3)
+
+		rejected, err := tester.dbClient.SuggestionEvent().FindByDecision(context.Background(), db.SuggestionDecisionRejected)
+		require.NoError(t, err)
+		require.Len(t, rejected, 1)
+		assert.Equal(t, uint(13), rejected[0].FileID)
+		assert.Equal(t, 0.85, rejected[0].Score)
+
+		reviews, err = service.ReadSuggestionReviews(context.Background(), 0, 0)
+		require.NoError(t, err)
+		assert.Empty(t, reviews)
+
+		operations := db.MustGetAll[db.Operation](t, db.TestClient{Client: tester.dbClient})
+		require.Len(t, operations, 2)
+		assert.Equal(t, string(history.KindResolveSuggestionReviews), operations[1].Kind)
+
+		// undoing the resolution removes the accepted tag
+		_, err = service.journal.Undo(context.Background())
+		require.NoError(t, err)
+		fileTags, err = tester.dbClient.FileTag().FindAllByFileID([]uint{11})
+		require.NoError(t, err)
+		assert.Len(t, fileTags, 1)
+	})
+
+	t.Run("a review of a deleted image is dropped", func(t *testing.T) {
+		require.NoError(t, db.BatchCreate(tester.dbClient, []db.SuggestionReview{
+			{ID: 100, FileID: 999, TagID: 1, Score: 0.7},
+		}))
+		service := tester.getFrontendService(frontendServiceMocks{
+			suggestionService: suggestionService,
+		})
+		require.NoError(t, service.ResolveSuggestionReviews(context.Background(), ResolveSuggestionReviewsRequest{
+			AcceptedIDs: []uint{100},
+		}))
+
+		fileTags, err := tester.dbClient.FileTag().FindAllByFileID([]uint{999})
+		require.NoError(t, err)
+		assert.Empty(t, fileTags)
+		assert.Empty(t, db.MustGetAll[db.SuggestionReview](t, db.TestClient{Client: tester.dbClient}))
+	})
+}
+
+func TestAutoTagger_Run_unreadableImage(t *testing.T) {
+	tester := newTester(t)
+	tester.newFileCreator(t).
+		CreateDirectory(image.Directory{ID: 1, Name: "Directory 1"}).
+		CreateImage(image.ImageFile{ID: 12, Name: "image12.jpg", ParentID: 1, ContentType: "image/jpeg"}, image.TestImageFileJpeg)
+
+	truncate := func() {
+		tester.dbClient.Truncate(&db.File{}, &db.Tag{}, &db.FileTag{}, &db.SuggestionEvent{}, &db.SuggestionReview{}, &db.AutoTaggedFile{}, &db.AutoTaggingRun{})
+	}
+	truncate()
+	t.Cleanup(truncate)
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.File{
+		{ID: 1, Name: "Directory 1", Type: db.FileTypeDirectory},
+		// deleted outside the app
+		{ID: 11, Name: "image11.jpg", Type: db.FileTypeImage, ParentID: 1},
+		{ID: 12, Name: "image12.jpg", Type: db.FileTypeImage, ParentID: 1},
+	}))
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.Tag{
+		{ID: 1, Name: "tag1"},
+	}))
+
+	model := &tag_suggestionv2.Model{Name: "tag-suggestion", Version: "2024-12-01"}
+	suggestionService := tester.getTagSuggestionServiceV2(t, 0, func(mock *tag_suggestionv2.MockTagSuggestionServiceClient) {
+		mock.EXPECT().
+			GetModel(gomock.Any(), gomock.Any()).
+			Return(&tag_suggestionv2.GetModelResponse{
+				Model: model,
+				Tags:  []*tag_suggestionv2.Tag{{Id: 1, Name: "tag1"}},
+			}, nil).
+			Times(2)
+		responses := [][]*tag_suggestionv2.SuggestResponse{
+			// the batch fails on image11
+			nil,
+			{{ImageId: 12, Scores: []*tag_suggestionv2.SuggestionScore{{TagId: 1, Score: 0.95}}}},
+		}
+		mock.EXPECT().
+			Suggest(gomock.Any()).
+			DoAndReturn(func(ctx context.Context, _ ...grpc.CallOption) (grpc.BidiStreamingClient[tag_suggestionv2.SuggestRequest, tag_suggestionv2.SuggestResponse], error) {
+				stream := newFakeSuggestStream(responses[0], nil)
+				stream.ctx = ctx
+				responses = responses[1:]
+				return stream, nil
+			}).
+			Times(2)
+	})
+	autoTagger := NewAutoTagger(tester.logger, tester.dbClient, suggestionService, nil, config.Config{
+		TagSuggestion: config.TagSuggestionConfig{
+			AutoTagging: config.AutoTaggingConfig{
+				Enabled:         true,
+				BatchSize:       10,
+				Threshold:       0.9,
+				ReviewThreshold: 0.5,
+			},
+		},
+	})
+
+	got, err := autoTagger.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, AutoTaggingResult{ImageCount: 2, AppliedCount: 1}, got)
+	xassert.ElementsMatch(t,
+		[]db.AutoTaggedFile{
+			{FileID: 11},
+			{FileID: 12, ModelVersion: "2024-12-01"},
+		},
+		db.MustGetAll[db.AutoTaggedFile](t, db.TestClient{Client: tester.dbClient}),
+		cmpopts.SortSlices(func(a, b db.AutoTaggedFile) bool { return a.FileID < b.FileID }),
+		cmpopts.IgnoreFields(db.AutoTaggedFile{}, "CreatedAt"),
+	)
+
+	// the unreadable image isn't tried again
+	got, err = autoTagger.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, AutoTaggingResult{}, got)
+}
+
+func TestAutoTagger_Run_noResponse(t *testing.T) {
+	tester := newTester(t)
+	tester.newFileCreator(t).
+		CreateDirectory(image.Directory{ID: 1, Name: "Directory 1"}).
+		CreateImage(image.ImageFile{ID: 11, Name: "image11.jpg", ParentID: 1, ContentType: "image/jpeg"}, image.TestImageFileJpeg)
+
+	truncate := func() {
+		tester.dbClient.Truncate(&db.File{}, &db.Tag{}, &db.FileTag{}, &db.SuggestionEvent{}, &db.SuggestionReview{}, &db.AutoTaggedFile{}, &db.AutoTaggingRun{})
+	}
+	truncate()
+	t.Cleanup(truncate)
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.File{
+		{ID: 1, Name: "Directory 1", Type: db.FileTypeDirectory},
+		{ID: 11, Name: "image11.jpg", Type: db.FileTypeImage, ParentID: 1},
+	}))
+
+	suggestionService := tester.getTagSuggestionService(t, func(mock *tag_suggestionv1.MockTagSuggestionServiceClient) {
+		mock.EXPECT().
+			Suggest(gomock.Any(), gomock.Any()).
+			Return(&tag_suggestionv1.SuggestResponse{}, nil)
+	})
+	autoTagger := NewAutoTagger(tester.logger, tester.dbClient, suggestionService, nil, config.Config{
+		TagSuggestion: config.TagSuggestionConfig{
+			AutoTagging: config.AutoTaggingConfig{
+				Enabled:         true,
+				BatchSize:       10,
+				Threshold:       0.9,
+				ReviewThreshold: 0.5,
+			},
+		},
+	})
+
+	got, err := autoTagger.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, AutoTaggingResult{}, got)
+	assert.Empty(t, db.MustGetAll[db.AutoTaggedFile](t, db.TestClient{Client: tester.dbClient}),
+		"an image without a response is auto-tagged on the next run")
+}
+
+func TestAutoTagger_Run_firstRun(t *testing.T) {
+	tester := newTester(t)
+	tester.newFileCreator(t).
+		CreateDirectory(image.Directory{ID: 1, Name: "Directory 1"}).
+		CreateImage(image.ImageFile{ID: 11, Name: "image11.jpg", ParentID: 1, ContentType: "image/jpeg"}, image.TestImageFileJpeg).
+		CreateImage(image.ImageFile{ID: 12, Name: "image12.jpg", ParentID: 1, ContentType: "image/jpeg"}, image.TestImageFileJpeg)
+
+	truncate := func() {
+		tester.dbClient.Truncate(&db.File{}, &db.Tag{}, &db.FileTag{}, &db.SuggestionEvent{}, &db.SuggestionReview{}, &db.AutoTaggedFile{}, &db.AutoTaggingRun{})
+	}
+	truncate()
+	t.Cleanup(truncate)
+	firstRunAt := time.Unix(1_700_000_000, 0)
+	// a library tagged by hand
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.File{
+		{ID: 1, Name: "Directory 1", Type: db.FileTypeDirectory},
+		{ID: 11, Name: "image11.jpg", Type: db.FileTypeImage, ParentID: 1, CreatedAt: uint(firstRunAt.Unix()) - 60},
+	}))
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.Tag{
+		{ID: 1, Name: "tag1"},
+		{ID: 2, Name: "tag2"},
+	}))
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.FileTag{
+		{FileID: 11, TagID: 1, AddedBy: db.FileTagAddedByUser},
+	}))
+
+	suggestionService := tester.getTagSuggestionServiceV2(t, 0, func(mock *tag_suggestionv2.MockTagSuggestionServiceClient) {
+		mock.EXPECT().
+			GetModel(gomock.Any(), gomock.Any()).
+			Return(&tag_suggestionv2.GetModelResponse{
+				Model: &tag_suggestionv2.Model{Name: "tag-suggestion", Version: "2024-12-01"},
+				Tags:  []*tag_suggestionv2.Tag{{Id: 1, Name: "tag1"}, {Id: 2, Name: "tag2"}},
+			}, nil)
+		mock.EXPECT().
+			Suggest(gomock.Any()).
+			Return(newFakeSuggestStream([]*tag_suggestionv2.SuggestResponse{
+				{ImageId: 12, Scores: []*tag_suggestionv2.SuggestionScore{{TagId: 2, Score: 0.95}}},
+			}, nil), nil)
+	})
+	autoTagger := NewAutoTagger(tester.logger, tester.dbClient, suggestionService, nil, config.Config{
+		TagSuggestion: config.TagSuggestionConfig{
+			AutoTagging: config.AutoTaggingConfig{
+				Enabled:         true,
+				BatchSize:       10,
+				Threshold:       0.9,
+				ReviewThreshold: 0.5,
+			},
+		},
+	})
+
+	autoTagger.now = func() time.Time { return firstRunAt }
+	got, err := autoTagger.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, AutoTaggingResult{}, got)
+
+	// an image imported with tags after the first run is auto-tagged later
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.File{
+		{ID: 12, Name: "image12.jpg", Type: db.FileTypeImage, ParentID: 1, CreatedAt: uint(firstRunAt.Unix()) + 60},
+	}))
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.FileTag{
+		{FileID: 12, TagID: 1, AddedBy: db.FileTagAddedByImport},
+	}))
+	autoTagger.now = func() time.Time { return firstRunAt.Add(time.Hour) }
+	got, err = autoTagger.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, AutoTaggingResult{ImageCount: 1, AppliedCount: 1}, got)
+
+	// a run without any image isn't kept
+	got, err = autoTagger.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, AutoTaggingResult{}, got)
+	xassert.ElementsMatch(t,
+		[]db.AutoTaggingRun{
+			{StartedAt: uint(firstRunAt.Unix()), FinishedAt: uint(firstRunAt.Unix())},
+			{
+				StartedAt:    uint(firstRunAt.Add(time.Hour).Unix()),
+				FinishedAt:   uint(firstRunAt.Add(time.Hour).Unix()),
+				ImageCount:   1,
+				AppliedCount: 1,
+			},
+		},
+		db.MustGetAll[db.AutoTaggingRun](t, db.TestClient{Client: tester.dbClient}),
+		cmpopts.IgnoreFields(db.AutoTaggingRun{}, "ID", "CreatedAt"),
+	)
+}
+
+func TestAutoTagger_Subscribe(t *testing.T) {
+	tester := newTester(t)
+	tester.newFileCreator(t).
+		CreateDirectory(image.Directory{ID: 1, Name: "Directory 1"}).
+		CreateImage(image.ImageFile{ID: 11, Name: "image11.jpg", ParentID: 1, ContentType: "image/jpeg"}, image.TestImageFileJpeg)
+
+	truncate := func() {
+		tester.dbClient.Truncate(&db.File{}, &db.Tag{}, &db.FileTag{}, &db.SuggestionEvent{}, &db.SuggestionReview{}, &db.AutoTaggedFile{}, &db.AutoTaggingRun{})
+	}
+	truncate()
+	t.Cleanup(truncate)
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.File{
+		{ID: 1, Name: "Directory 1", Type: db.FileTypeDirectory},
+		{ID: 11, Name: "image11.jpg", Type: db.FileTypeImage, ParentID: 1},
+	}))
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.Tag{
+		{ID: 1, Name: "tag1"},
+	}))
+
+	suggestionService := tester.getTagSuggestionServiceV2(t, 0, func(mock *tag_suggestionv2.MockTagSuggestionServiceClient) {
+		mock.EXPECT().
+			GetModel(gomock.Any(), gomock.Any()).
+			Return(&tag_suggestionv2.GetModelResponse{
+				Model: &tag_suggestionv2.Model{Name: "tag-suggestion", Version: "2024-12-01"},
+				Tags:  []*tag_suggestionv2.Tag{{Id: 1, Name: "tag1"}},
+			}, nil)
+		mock.EXPECT().
+			Suggest(gomock.Any()).
+			Return(newFakeSuggestStream([]*tag_suggestionv2.SuggestResponse{
+				{ImageId: 11, Scores: []*tag_suggestionv2.SuggestionScore{{TagId: 1, Score: 0.95}}},
+			}, nil), nil)
+	})
+	autoTagger := NewAutoTagger(tester.logger, tester.dbClient, suggestionService, nil, config.Config{
+		TagSuggestion: config.TagSuggestionConfig{
+			AutoTagging: config.AutoTaggingConfig{
+				Enabled:         true,
+				IntervalMinutes: 60,
+				BatchSize:       10,
+				Threshold:       0.9,
+				ReviewThreshold: 0.5,
+			},
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := event.NewBus(tester.logger)
+	autoTagger.Subscribe(bus)
+	bus.Start(ctx)
+
+	// images are auto-tagged without waiting for the interval
+	bus.Publish(ctx, event.TypeImagesImported, event.ImagesImported{DirectoryID: 1})
+	require.Eventually(t, func() bool {
+		runs := db.MustGetAll[db.AutoTaggingRun](t, db.TestClient{Client: tester.dbClient})
+		return len(runs) == 1 && runs[0].ImageCount == 1
+	}, time.Second, 10*time.Millisecond)
+	xassert.ElementsMatch(t,
+		[]db.FileTag{
+			{FileID: 11, TagID: 1, AddedBy: db.FileTagAddedBySuggestion},
+		},
+		db.MustGetAll[db.FileTag](t, db.TestClient{Client: tester.dbClient}),
+		cmpopts.IgnoreFields(db.FileTag{}, "CreatedAt"),
+	)
+}
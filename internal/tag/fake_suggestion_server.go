@@ -0,0 +1,470 @@
+package tag
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	goimage "image"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	tag_suggestionv2 "github.com/michael-freling/anime-image-viewer/plugins/plugins-protos/gen/go/tag_suggestion/v2"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// FakeSuggestionModelName is the model name the fake server answers, so
+	// that its suggestions can be told from those of a real model
+	FakeSuggestionModelName    = "fake"
+	FakeSuggestionModelVersion = "heuristics"
+
+	// fakeFilenameScore is the score of a tag or a character whose name is
+	// in the file name of an image
+	fakeFilenameScore = 0.9
+)
+
+// FakeSuggestionServerOptions tunes the nearest neighbor search by
+// perceptual hashes
+type FakeSuggestionServerOptions struct {
+	// Neighbors is the number of the most similar images which vote
+	Neighbors int
+	// MaxDistance is the largest hamming distance of a neighbor out of 64 bits
+	MaxDistance int
+}
+
+// FakeSuggestionServer is a tag_suggestion.v2 plugin for development, which
+// suggests tags and characters from the library itself instead of a model,
+// so the suggest and accept flow works without the Python plugin.
+// A score of an image is the highest score of the heuristics:
+//   - the name of a tag or a character is in the file name of the image
+//   - the share of the other images in the same directory with the tag or
+//     the character, among those sharing a tag with the image if any
+//   - the weighted votes of the most similar images by perceptual hashes
+type FakeSuggestionServer struct {
+	tag_suggestionv2.UnimplementedTagSuggestionServiceServer
+
+	logger      *slog.Logger
+	dbClient    *db.Client
+	imageReader *image.Reader
+	options     FakeSuggestionServerOptions
+
+	mutex sync.Mutex
+	// perceptualHashes caches the hashes of library images by their IDs.
+	// An image which cannot be hashed is cached as nil not to read it again.
+	perceptualHashes map[uint]*uint64
+}
+
+func NewFakeSuggestionServer(
+	logger *slog.Logger,
+	dbClient *db.Client,
+	imageReader *image.Reader,
+	options FakeSuggestionServerOptions,
+) *FakeSuggestionServer {
+	return &FakeSuggestionServer{
+		logger:           logger,
+		dbClient:         dbClient,
+		imageReader:      imageReader,
+		options:          options,
+		perceptualHashes: make(map[uint]*uint64),
+	}
+}
+
+func (server *FakeSuggestionServer) GetModel(ctx context.Context, request *tag_suggestionv2.GetModelRequest) (*tag_suggestionv2.GetModelResponse, error) {
+	tags, err := server.dbClient.Tag().GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("Tag.GetAll: %w", err)
+	}
+	characters, err := server.dbClient.Character().GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("Character.GetAll: %w", err)
+	}
+
+	response := &tag_suggestionv2.GetModelResponse{
+		Model: &tag_suggestionv2.Model{
+			Name:    FakeSuggestionModelName,
+			Version: FakeSuggestionModelVersion,
+		},
+		Tags:       make([]*tag_suggestionv2.Tag, len(tags)),
+		Characters: make([]*tag_suggestionv2.Character, len(characters)),
+	}
+	for index, tag := range tags {
+		response.Tags[index] = &tag_suggestionv2.Tag{Id: uint64(tag.ID), Name: tag.Name}
+	}
+	for index, character := range characters {
+		response.Characters[index] = &tag_suggestionv2.Character{Id: uint64(character.ID), Name: character.Name}
+	}
+	return response, nil
+}
+
+func (server *FakeSuggestionServer) Suggest(stream tag_suggestionv2.TagSuggestionService_SuggestServer) error {
+	ctx := stream.Context()
+	// the library is read for each stream so that accepted suggestions are
+	// used for the next ones
+	index, err := server.readIndex(ctx)
+	if err != nil {
+		return fmt.Errorf("readIndex: %w", err)
+	}
+
+	for {
+		request, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("stream.Recv: %w", err)
+		}
+		if request.Image == nil {
+			continue
+		}
+		if err := stream.Send(server.suggest(index, request.Image)); err != nil {
+			return fmt.Errorf("stream.Send: %w", err)
+		}
+	}
+}
+
+// fakeSuggestionIndex is the library which the heuristics look up
+type fakeSuggestionIndex struct {
+	files           map[uint]db.File
+	filesByParent   map[uint][]uint
+	fileTags        map[uint][]uint
+	fileCharacters  map[uint][]uint
+	tagTokens       map[uint][]string
+	characterTokens map[uint][]string
+	characterAnime  map[uint][]uint
+	// perceptualHashes are of the images with any tag or character
+	perceptualHashes map[uint]uint64
+}
+
+func (server *FakeSuggestionServer) readIndex(ctx context.Context) (fakeSuggestionIndex, error) {
+	files, err := server.dbClient.File().FindAllImageFiles()
+	if err != nil {
+		return fakeSuggestionIndex{}, fmt.Errorf("File.FindAllImageFiles: %w", err)
+	}
+	fileTags, err := server.dbClient.FileTag().GetAll()
+	if err != nil {
+		return fakeSuggestionIndex{}, fmt.Errorf("FileTag.GetAll: %w", err)
+	}
+	fileCharacters, err := server.dbClient.FileCharacter().GetAll()
+	if err != nil {
+		return fakeSuggestionIndex{}, fmt.Errorf("FileCharacter.GetAll: %w", err)
+	}
+	tags, err := server.dbClient.Tag().GetAll()
+	if err != nil {
+		return fakeSuggestionIndex{}, fmt.Errorf("Tag.GetAll: %w", err)
+	}
+	characters, err := server.dbClient.Character().GetAll()
+	if err != nil {
+		return fakeSuggestionIndex{}, fmt.Errorf("Character.GetAll: %w", err)
+	}
+	animeCharacters, err := server.dbClient.AnimeCharacter().GetAll()
+	if err != nil {
+		return fakeSuggestionIndex{}, fmt.Errorf("AnimeCharacter.GetAll: %w", err)
+	}
+
+	index := fakeSuggestionIndex{
+		files:           make(map[uint]db.File, len(files)),
+		filesByParent:   make(map[uint][]uint),
+		fileTags:        make(map[uint][]uint),
+		fileCharacters:  make(map[uint][]uint),
+		tagTokens:       make(map[uint][]string, len(tags)),
+		characterTokens: make(map[uint][]string, len(characters)),
+		characterAnime:  make(map[uint][]uint, len(characters)),
+	}
+	for _, file := range files {
+		index.files[file.ID] = file
+		index.filesByParent[file.ParentID] = append(index.filesByParent[file.ParentID], file.ID)
+	}
+	for _, fileTag := range fileTags {
+		index.fileTags[fileTag.FileID] = append(index.fileTags[fileTag.FileID], fileTag.TagID)
+	}
+	for _, fileCharacter := range fileCharacters {
+		index.fileCharacters[fileCharacter.FileID] = append(index.fileCharacters[fileCharacter.FileID], fileCharacter.CharacterID)
+	}
+	for _, tag := range tags {
+		index.tagTokens[tag.ID] = tokenize(tag.Name)
+	}
+	for _, character := range characters {
+		index.characterTokens[character.ID] = tokenize(character.Name)
+		index.characterAnime[character.ID] = append(index.characterAnime[character.ID], character.AnimeID)
+	}
+	for _, animeCharacter := range animeCharacters {
+		index.characterAnime[animeCharacter.CharacterID] = append(index.characterAnime[animeCharacter.CharacterID], animeCharacter.AnimeID)
+	}
+
+	labeledFileIDs := make([]uint, 0)
+	for _, file := range files {
+		if len(index.fileTags[file.ID]) > 0 || len(index.fileCharacters[file.ID]) > 0 {
+			labeledFileIDs = append(labeledFileIDs, file.ID)
+		}
+	}
+	index.perceptualHashes, err = server.readPerceptualHashes(ctx, labeledFileIDs)
+	if err != nil {
+		return fakeSuggestionIndex{}, fmt.Errorf("readPerceptualHashes: %w", err)
+	}
+	return index, nil
+}
+
+// readPerceptualHashes computes the hashes of the images which haven't been
+// cached yet
+func (server *FakeSuggestionServer) readPerceptualHashes(ctx context.Context, imageFileIDs []uint) (map[uint]uint64, error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	uncachedIDs := make([]uint, 0)
+	for _, imageFileID := range imageFileIDs {
+		if _, ok := server.perceptualHashes[imageFileID]; !ok {
+			uncachedIDs = append(uncachedIDs, imageFileID)
+		}
+	}
+	if len(uncachedIDs) > 0 {
+		imageFiles, err := server.imageReader.ReadImagesByIDs(uncachedIDs)
+		if err != nil {
+			return nil, fmt.Errorf("imageReader.ReadImagesByIDs: %w", err)
+		}
+		hashes := make([]*uint64, len(imageFiles))
+		eg, _ := errgroup.WithContext(ctx)
+		eg.SetLimit(runtime.NumCPU())
+		for index, imageFile := range imageFiles {
+			eg.Go(func() error {
+				hash, err := image.ComputePerceptualHash(imageFile.LocalFilePath)
+				if err != nil {
+					server.logger.WarnContext(ctx, "failed to compute a perceptual hash",
+						"imageFile", imageFile.LocalFilePath,
+						"error", err,
+					)
+					return nil
+				}
+				hashes[index] = &hash
+				return nil
+			})
+		}
+		if err := eg.Wait(); err != nil {
+			return nil, err
+		}
+		for _, imageFileID := range uncachedIDs {
+			// images which couldn't be read aren't hashed either
+			server.perceptualHashes[imageFileID] = nil
+		}
+		for index, imageFile := range imageFiles {
+			server.perceptualHashes[imageFile.ID] = hashes[index]
+		}
+	}
+
+	result := make(map[uint]uint64, len(imageFileIDs))
+	for _, imageFileID := range imageFileIDs {
+		if hash := server.perceptualHashes[imageFileID]; hash != nil {
+			result[imageFileID] = *hash
+		}
+	}
+	return result, nil
+}
+
+func (server *FakeSuggestionServer) suggest(index fakeSuggestionIndex, requestImage *tag_suggestionv2.Image) *tag_suggestionv2.SuggestResponse {
+	imageFileID := uint(requestImage.Id)
+	tagScores := make(map[uint]float64)
+	characterScores := make(map[uint]float64)
+
+	if file, ok := index.files[imageFileID]; ok {
+		fileTokens := tokenize(strings.TrimSuffix(file.Name, filepath.Ext(file.Name)))
+		for tagID, tokens := range index.tagTokens {
+			if containsTokens(fileTokens, tokens) {
+				addScore(tagScores, tagID, fakeFilenameScore)
+			}
+		}
+		for characterID, tokens := range index.characterTokens {
+			if containsTokens(fileTokens, tokens) {
+				addScore(characterScores, characterID, fakeFilenameScore)
+			}
+		}
+
+		siblingIDs := make([]uint, 0)
+		for _, siblingID := range index.filesByParent[file.ParentID] {
+			if siblingID != imageFileID {
+				siblingIDs = append(siblingIDs, siblingID)
+			}
+		}
+		for id, score := range shares(siblingIDs, index.fileTags, index.fileTags[imageFileID]) {
+			addScore(tagScores, id, score)
+		}
+		for id, score := range shares(siblingIDs, index.fileCharacters, index.fileCharacters[imageFileID]) {
+			addScore(characterScores, id, score)
+		}
+	}
+
+	sourceImage, _, err := goimage.Decode(bytes.NewReader(requestImage.Content))
+	if err != nil {
+		// the other heuristics still work without the content
+		server.logger.Warn("failed to decode an image",
+			"imageFileID", imageFileID,
+			"contentType", requestImage.ContentType,
+			"error", err,
+		)
+	} else {
+		neighbors := server.findNeighbors(index, imageFileID, image.PerceptualHash(sourceImage))
+		for id, score := range vote(neighbors, index.fileTags) {
+			addScore(tagScores, id, score)
+		}
+		for id, score := range vote(neighbors, index.fileCharacters) {
+			addScore(characterScores, id, score)
+		}
+	}
+
+	response := &tag_suggestionv2.SuggestResponse{
+		ImageId:         requestImage.Id,
+		Scores:          make([]*tag_suggestionv2.SuggestionScore, 0, len(tagScores)),
+		CharacterScores: make([]*tag_suggestionv2.CharacterScore, 0, len(characterScores)),
+	}
+	for tagID, score := range tagScores {
+		response.Scores = append(response.Scores, &tag_suggestionv2.SuggestionScore{
+			TagId: uint64(tagID),
+			Score: score,
+		})
+	}
+	for characterID, score := range characterScores {
+		if requestImage.AnimeId != 0 && !slices.Contains(index.characterAnime[characterID], uint(requestImage.AnimeId)) {
+			continue
+		}
+		response.CharacterScores = append(response.CharacterScores, &tag_suggestionv2.CharacterScore{
+			CharacterId: uint64(characterID),
+			Score:       score,
+		})
+	}
+	slices.SortFunc(response.Scores, func(a, b *tag_suggestionv2.SuggestionScore) int {
+		return compareScores(a.Score, b.Score, a.TagId, b.TagId)
+	})
+	slices.SortFunc(response.CharacterScores, func(a, b *tag_suggestionv2.CharacterScore) int {
+		return compareScores(a.Score, b.Score, a.CharacterId, b.CharacterId)
+	})
+	return response
+}
+
+type fakeNeighbor struct {
+	imageFileID uint
+	distance    int
+}
+
+// findNeighbors returns the most similar images other than the image itself,
+// from the most similar one
+func (server *FakeSuggestionServer) findNeighbors(index fakeSuggestionIndex, imageFileID uint, hash uint64) []fakeNeighbor {
+	neighbors := make([]fakeNeighbor, 0)
+	for neighborID, neighborHash := range index.perceptualHashes {
+		if neighborID == imageFileID {
+			continue
+		}
+		distance := image.HammingDistance(hash, neighborHash)
+		if distance > server.options.MaxDistance {
+			continue
+		}
+		neighbors = append(neighbors, fakeNeighbor{imageFileID: neighborID, distance: distance})
+	}
+	slices.SortFunc(neighbors, func(a, b fakeNeighbor) int {
+		if a.distance != b.distance {
+			return a.distance - b.distance
+		}
+		return int(a.imageFileID) - int(b.imageFileID)
+	})
+	if len(neighbors) > server.options.Neighbors {
+		neighbors = neighbors[:server.options.Neighbors]
+	}
+	return neighbors
+}
+
+// vote scores each label by the similarities of the neighbors with it, so
+// that a label of every neighbor identical to the image scores 1
+func vote(neighbors []fakeNeighbor, labels map[uint][]uint) map[uint]float64 {
+	scores := make(map[uint]float64)
+	if len(neighbors) == 0 {
+		return scores
+	}
+	for _, neighbor := range neighbors {
+		similarity := 1 - float64(neighbor.distance)/64
+		for _, id := range labels[neighbor.imageFileID] {
+			scores[id] += similarity / float64(len(neighbors))
+		}
+	}
+	return scores
+}
+
+// shares scores each label by the share of the images with it. If any of
+// the images has one of the given labels, only those images are counted, so
+// that the labels used together with them score higher.
+func shares(imageFileIDs []uint, labels map[uint][]uint, givenLabels []uint) map[uint]float64 {
+	labeledIDs := make([]uint, 0)
+	cooccurringIDs := make([]uint, 0)
+	for _, imageFileID := range imageFileIDs {
+		if len(labels[imageFileID]) == 0 {
+			continue
+		}
+		labeledIDs = append(labeledIDs, imageFileID)
+		for _, id := range labels[imageFileID] {
+			if slices.Contains(givenLabels, id) {
+				cooccurringIDs = append(cooccurringIDs, imageFileID)
+				break
+			}
+		}
+	}
+	if len(cooccurringIDs) > 0 {
+		labeledIDs = cooccurringIDs
+	}
+
+	scores := make(map[uint]float64)
+	for _, imageFileID := range labeledIDs {
+		for _, id := range labels[imageFileID] {
+			scores[id] += 1 / float64(len(labeledIDs))
+		}
+	}
+	return scores
+}
+
+// addScore keeps the highest score of the heuristics
+func addScore(scores map[uint]float64, id uint, score float64) {
+	if score > scores[id] {
+		scores[id] = score
+	}
+}
+
+func compareScores(scoreA, scoreB float64, idA, idB uint64) int {
+	if scoreA != scoreB {
+		if scoreA > scoreB {
+			return -1
+		}
+		return 1
+	}
+	if idA < idB {
+		return -1
+	}
+	if idA > idB {
+		return 1
+	}
+	return 0
+}
+
+// tokenize splits a name into lower case words, e.g. "Saber_Alter-01" into
+// "saber", "alter" and "01"
+func tokenize(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// containsTokens reports whether the words of a name appear in a row
+func containsTokens(tokens []string, nameTokens []string) bool {
+	if len(nameTokens) == 0 || len(nameTokens) > len(tokens) {
+		return false
+	}
+	for start := 0; start+len(nameTokens) <= len(tokens); start++ {
+		if slices.Equal(tokens[start:start+len(nameTokens)], nameTokens) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package tag
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	tag_suggestionv2 "github.com/michael-freling/anime-image-viewer/plugins/plugins-protos/gen/go/tag_suggestion/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestFakeSuggestionServer(t *testing.T) {
+	tester := newTester(t)
+	tester.newFileCreator(t).
+		CreateDirectory(image.Directory{ID: 1, Name: "Anime 1"}).
+		CreateImage(image.ImageFile{ID: 11, Name: "saber_01.jpg", ParentID: 1, ContentType: "image/jpeg"}, image.TestImageFileJpeg).
+		CreateImage(image.ImageFile{ID: 12, Name: "image12.png", ParentID: 1, ContentType: "image/png"}, image.TestImageFilePng).
+		CreateImage(image.ImageFile{ID: 13, Name: "image13.jpg", ParentID: 1, ContentType: "image/jpeg"}, image.TestImageFileJpeg).
+		CreateDirectory(image.Directory{ID: 2, Name: "Anime 2"}).
+		CreateImage(image.ImageFile{ID: 21, Name: "image21.jpg", ParentID: 2, ContentType: "image/jpeg"}, image.TestImageFileJpeg)
+
+	truncate := func() {
+		tester.dbClient.Truncate(&db.Tag{}, &db.FileTag{}, &db.File{}, &db.Character{}, &db.FileCharacter{})
+	}
+	truncate()
+	t.Cleanup(truncate)
+
+	animeID := uint(100)
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.File{
+		{ID: 1, Name: "Anime 1", Type: db.FileTypeDirectory, AnimeID: &animeID},
+		{ID: 11, Name: "saber_01.jpg", Type: db.FileTypeImage, ParentID: 1},
+		{ID: 12, Name: "image12.png", Type: db.FileTypeImage, ParentID: 1},
+		{ID: 13, Name: "image13.jpg", Type: db.FileTypeImage, ParentID: 1},
+		{ID: 2, Name: "Anime 2", Type: db.FileTypeDirectory},
+		{ID: 21, Name: "image21.jpg", Type: db.FileTypeImage, ParentID: 2},
+	}))
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.Tag{
+		{ID: 1, Name: "tag1"},
+		{ID: 2, Name: "tag2"},
+		{ID: 3, Name: "tag3"},
+	}))
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.FileTag{
+		{FileID: 12, TagID: 1},
+		{FileID: 13, TagID: 1},
+		{FileID: 13, TagID: 2},
+		{FileID: 21, TagID: 3},
+	}))
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.Character{
+		{ID: 1, Name: "Character 1", AnimeID: animeID},
+		{ID: 2, Name: "Saber", AnimeID: animeID},
+		{ID: 3, Name: "Another anime's character", AnimeID: 200},
+	}))
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.FileCharacter{
+		{FileID: 12, CharacterID: 1},
+		{FileID: 21, CharacterID: 3},
+	}))
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	tag_suggestionv2.RegisterTagSuggestionServiceServer(server, NewFakeSuggestionServer(
+		tester.logger,
+		tester.dbClient,
+		tester.getImageReader(),
+		FakeSuggestionServerOptions{Neighbors: 5, MaxDistance: 10},
+	))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	connection, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { connection.Close() })
+
+	service := NewSuggestionServiceV2(
+		tester.dbClient,
+		tag_suggestionv2.NewTagSuggestionServiceClient(connection),
+		image.NewResizer(tester.logger),
+		0,
+		tester.getReader(),
+		tester.getImageReader(),
+	)
+	got, err := service.SuggestTags(context.Background(), []uint{11})
+	require.NoError(t, err)
+	assert.Equal(t, SuggestionModel{Name: FakeSuggestionModelName, Version: FakeSuggestionModelVersion}, got.Model)
+	assert.Equal(t, map[uint][]TagSuggestion{
+		11: {
+			// every labeled image in the directory has tag1
+			{TagID: 1, Score: 1},
+			// image13 and image21 are identical to the image and vote
+			{TagID: 2, Score: 0.5},
+			{TagID: 3, Score: 0.5},
+		},
+	}, got.Suggestions)
+	assert.Equal(t, map[uint][]CharacterSuggestion{
+		11: {
+			{CharacterID: 1, Score: 1},
+			// by the file name. The character of image21 is of another anime
+			{CharacterID: 2, Score: fakeFilenameScore},
+		},
+	}, got.CharacterSuggestions)
+}
+
+func TestContainsTokens(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fileName string
+		tagName  string
+		want     bool
+	}{
+		{name: "a word", fileName: "saber_01", tagName: "Saber", want: true},
+		{name: "words in a row", fileName: "01-Saber-Alter", tagName: "saber alter", want: true},
+		{name: "words not in a row", fileName: "saber_01_alter", tagName: "saber alter"},
+		{name: "a part of a word", fileName: "sabers", tagName: "saber"},
+		{name: "no word", fileName: "saber", tagName: "!"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, containsTokens(tokenize(tc.fileName), tokenize(tc.tagName)))
+		})
+	}
+}
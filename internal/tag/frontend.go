@@ -8,6 +8,7 @@ import (
 	"slices"
 
 	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/history"
 	"github.com/michael-freling/anime-image-viewer/internal/image"
 	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
 	"google.golang.org/grpc/codes"
@@ -20,6 +21,7 @@ type TagFrontendService struct {
 
 	reader            *Reader
 	suggestionService *SuggestionService
+	journal           *history.Journal
 }
 
 func NewFrontendService(
@@ -27,12 +29,14 @@ func NewFrontendService(
 	dbClient *db.Client,
 	reader *Reader,
 	suggestionService *SuggestionService,
+	journal *history.Journal,
 ) *TagFrontendService {
 	return &TagFrontendService{
 		logger:            logger,
 		dbClient:          dbClient,
 		reader:            reader,
 		suggestionService: suggestionService,
+		journal:           journal,
 	}
 }
 
@@ -157,13 +161,34 @@ func (service TagFrontendService) GetTagFileCount(tagID uint) (uint, error) {
 
 func (service TagFrontendService) DeleteTag(ctx context.Context, tagID uint) error {
 	return db.NewTransaction(ctx, service.dbClient, func(ctx context.Context) error {
+		deletedTag, err := service.dbClient.Tag().FindByValue(ctx, &db.Tag{ID: tagID})
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Tag.FindByValue: %w", err)
+		}
+		deletedFileTags, err := service.dbClient.FileTag().FindAllByTagIDs([]uint{tagID})
+		if err != nil {
+			return fmt.Errorf("FileTag.FindAllByTagIDs: %w", err)
+		}
+
 		if err := service.dbClient.FileTag().DeleteByTagIDs(ctx, []uint{tagID}); err != nil {
 			return fmt.Errorf("FileTag.DeleteByTagIDs: %w", err)
 		}
+		if err := service.dbClient.SuggestionReview().DeleteByTagIDs(ctx, []uint{tagID}); err != nil {
+			return fmt.Errorf("SuggestionReview.DeleteByTagIDs: %w", err)
+		}
 		if err := service.dbClient.Tag().BatchDelete(ctx, []db.Tag{{ID: tagID}}); err != nil {
 			return fmt.Errorf("Tag.BatchDelete: %w", err)
 		}
-		return nil
+		return service.journal.Record(ctx, history.KindDeleteTag,
+			fmt.Sprintf("Deleted tag %q", deletedTag.Name),
+			history.Change{
+				DeletedTags:     []db.Tag{deletedTag},
+				DeletedFileTags: deletedFileTags,
+			},
+		)
 	})
 }
 
@@ -175,10 +200,12 @@ func (service TagFrontendService) MergeTags(ctx context.Context, sourceTagID uin
 	return db.NewTransaction(ctx, service.dbClient, func(ctx context.Context) error {
 		// Verify both tags exist
 		tagClient := service.dbClient.Tag()
-		if _, err := tagClient.FindByValue(ctx, &db.Tag{ID: sourceTagID}); err != nil {
+		sourceTag, err := tagClient.FindByValue(ctx, &db.Tag{ID: sourceTagID})
+		if err != nil {
 			return fmt.Errorf("source tag not found: %w", err)
 		}
-		if _, err := tagClient.FindByValue(ctx, &db.Tag{ID: targetTagID}); err != nil {
+		targetTag, err := tagClient.FindByValue(ctx, &db.Tag{ID: targetTagID})
+		if err != nil {
 			return fmt.Errorf("target tag not found: %w", err)
 		}
 
@@ -220,13 +247,23 @@ func (service TagFrontendService) MergeTags(ctx context.Context, sourceTagID uin
 		if err := service.dbClient.FileTag().DeleteByTagIDs(ctx, []uint{sourceTagID}); err != nil {
 			return fmt.Errorf("FileTag.DeleteByTagIDs: %w", err)
 		}
+		if err := service.dbClient.SuggestionReview().DeleteByTagIDs(ctx, []uint{sourceTagID}); err != nil {
+			return fmt.Errorf("SuggestionReview.DeleteByTagIDs: %w", err)
+		}
 
 		// Delete the source tag
 		if err := tagClient.BatchDelete(ctx, []db.Tag{{ID: sourceTagID}}); err != nil {
 			return fmt.Errorf("Tag.BatchDelete: %w", err)
 		}
 
-		return nil
+		return service.journal.Record(ctx, history.KindMergeTags,
+			fmt.Sprintf("Merged tag %q into %q", sourceTag.Name, targetTag.Name),
+			history.Change{
+				DeletedTags:     []db.Tag{sourceTag},
+				AddedFileTags:   newFileTags,
+				DeletedFileTags: sourceFileTags,
+			},
+		)
 	})
 }
 
@@ -256,6 +293,15 @@ func (service TagFrontendService) BatchUpdateTagsForFiles(ctx context.Context, f
 		return nil
 	}
 
+	// Only the links that exist are recorded as deleted, with their AddedBy,
+	// so that undo restores them as they were.
+	deletedFileTags := make([]db.FileTag, 0)
+	for _, fileTag := range fileTags {
+		if slices.Contains(deletedTagIDs, fileTag.TagID) {
+			deletedFileTags = append(deletedFileTags, fileTag)
+		}
+	}
+
 	return db.NewTransaction(ctx, service.dbClient, func(ctx context.Context) error {
 		ormClient := service.dbClient.FileTag()
 		if len(deletedTagIDs) > 0 {
@@ -268,7 +314,13 @@ func (service TagFrontendService) BatchUpdateTagsForFiles(ctx context.Context, f
 				return fmt.Errorf("ormClient.BatchCreate: %w", err)
 			}
 		}
-		return nil
+		return service.journal.Record(ctx, history.KindBatchUpdateTags,
+			fmt.Sprintf("Added %d and removed %d tags on %d files", len(addedTagIDs), len(deletedTagIDs), len(fileIDs)),
+			history.Change{
+				AddedFileTags:   createdFileTags,
+				DeletedFileTags: deletedFileTags,
+			},
+		)
 	})
 }
 
@@ -280,6 +332,17 @@ type SuggestTagsResponse struct {
 
 	// AllTags maps tag IDs to tags
 	AllTags map[uint]Tag `json:"allTags"`
+
+	// CharacterSuggestions maps image file IDs to suggestions of the
+	// characters of their anime. It's nil for a plugin of tag_suggestion.v1
+	CharacterSuggestions map[uint][]CharacterSuggestion `json:"characterSuggestions"`
+
+	// AllCharacters maps the IDs of suggested characters to characters
+	AllCharacters map[uint]Character `json:"allCharacters"`
+
+	// Model is the model that suggested the tags. It's empty for a plugin
+	// of tag_suggestion.v1
+	Model SuggestionModel `json:"model"`
 }
 
 func (service TagFrontendService) SuggestTags(ctx context.Context, imageFileIDs []uint) (SuggestTagsResponse, error) {
@@ -289,7 +352,7 @@ func (service TagFrontendService) SuggestTags(ctx context.Context, imageFileIDs
 	if service.suggestionService == nil {
 		return SuggestTagsResponse{}, fmt.Errorf("%w: tag suggestion service is not available", xerrors.ErrInvalidArgument)
 	}
-	response, err := service.suggestionService.suggestTags(ctx, imageFileIDs)
+	response, err := service.suggestionService.SuggestTags(ctx, imageFileIDs)
 	if err != nil {
 		grpcStatusCode := status.Code(err)
 		unexpectedErrorCode := []codes.Code{
@@ -297,7 +360,7 @@ func (service TagFrontendService) SuggestTags(ctx context.Context, imageFileIDs
 			codes.Unknown,
 		}
 		if !slices.Contains(unexpectedErrorCode, grpcStatusCode) {
-			return SuggestTagsResponse{}, fmt.Errorf("suggestionService.suggestTags > %w", err)
+			return SuggestTagsResponse{}, fmt.Errorf("suggestionService.SuggestTags > %w", err)
 		}
 		if errors.Is(err, image.ErrImageFileNotFound) {
 			return SuggestTagsResponse{}, fmt.Errorf("failed to find an image: %w", err)
@@ -315,6 +378,14 @@ func (service TagFrontendService) SuggestTags(ctx context.Context, imageFileIDs
 type AddSuggestedTagsRequest struct {
 	// fileID -> tagID
 	SelectedTags map[uint][]uint `json:"selectedTags"`
+	// RejectedTags are the suggested tags which weren't selected.
+	// fileID -> tagID
+	RejectedTags map[uint][]uint `json:"rejectedTags"`
+	// Scores are the scores of the suggested tags.
+	// fileID -> tagID -> score
+	Scores map[uint]map[uint]float64 `json:"scores"`
+	// Model is the model that suggested the tags
+	Model SuggestionModel `json:"model"`
 }
 
 type AddSuggestedTagsResponse struct {
@@ -324,7 +395,7 @@ type AddSuggestedTagsResponse struct {
 }
 
 func (service TagFrontendService) AddSuggestedTags(ctx context.Context, request AddSuggestedTagsRequest) (AddSuggestedTagsResponse, error) {
-	if len(request.SelectedTags) == 0 {
+	if len(request.SelectedTags) == 0 && len(request.RejectedTags) == 0 {
 		return AddSuggestedTagsResponse{}, nil
 	}
 	if service.suggestionService == nil {
@@ -336,7 +407,11 @@ func (service TagFrontendService) AddSuggestedTags(ctx context.Context, request
 		"request", request,
 	)
 
-	duplicatedTags, err := service.suggestionService.addSuggestedTags(ctx, request.SelectedTags)
+	duplicatedTags, err := service.suggestionService.addSuggestedTags(ctx, service.journal, request.SelectedTags, suggestionFeedback{
+		rejected: request.RejectedTags,
+		scores:   request.Scores,
+		model:    request.Model,
+	})
 	if len(duplicatedTags) == 0 {
 		duplicatedTags = nil
 	}
@@ -353,3 +428,89 @@ func (service TagFrontendService) AddSuggestedTags(ctx context.Context, request
 	}
 	return response, nil
 }
+
+type AddSuggestedCharactersRequest struct {
+	// fileID -> characterID
+	SelectedCharacters map[uint][]uint `json:"selectedCharacters"`
+	// RejectedCharacters are the suggested characters which weren't selected.
+	// fileID -> characterID
+	RejectedCharacters map[uint][]uint `json:"rejectedCharacters"`
+	// Scores are the scores of the suggested characters.
+	// fileID -> characterID -> score
+	Scores map[uint]map[uint]float64 `json:"scores"`
+	// Model is the model that suggested the characters
+	Model SuggestionModel `json:"model"`
+}
+
+type AddSuggestedCharactersResponse struct {
+	DuplicatedCharacters map[uint][]uint `json:"duplicatedCharacters"`
+}
+
+func (service TagFrontendService) AddSuggestedCharacters(ctx context.Context, request AddSuggestedCharactersRequest) (AddSuggestedCharactersResponse, error) {
+	if len(request.SelectedCharacters) == 0 && len(request.RejectedCharacters) == 0 {
+		return AddSuggestedCharactersResponse{}, nil
+	}
+	if service.suggestionService == nil {
+		return AddSuggestedCharactersResponse{}, fmt.Errorf("%w: tag suggestion service is not available", xerrors.ErrInvalidArgument)
+	}
+
+	logger := service.logger
+	logger.DebugContext(ctx, "add suggested characters request",
+		"request", request,
+	)
+
+	duplicatedCharacters, err := service.suggestionService.addSuggestedCharacters(ctx, service.journal, request.SelectedCharacters, suggestionFeedback{
+		rejected: request.RejectedCharacters,
+		scores:   request.Scores,
+		model:    request.Model,
+	})
+	if len(duplicatedCharacters) == 0 {
+		duplicatedCharacters = nil
+	}
+	response := AddSuggestedCharactersResponse{
+		DuplicatedCharacters: duplicatedCharacters,
+	}
+	if errors.Is(err, xerrors.ErrInvalidArgument) {
+		return response, err
+	}
+	if err != nil {
+		logger.Error("failed to add suggested characters",
+			"request", request,
+			"error", err,
+		)
+		return response, fmt.Errorf("failed to add characters")
+	}
+	return response, nil
+}
+
+// ReadSuggestionReviews returns a page of the tags suggested by auto-tagging
+// which wait for a review, those with higher scores first
+func (service TagFrontendService) ReadSuggestionReviews(ctx context.Context, limit int, offset int) ([]SuggestionReview, error) {
+	if service.suggestionService == nil {
+		return nil, fmt.Errorf("%w: tag suggestion service is not available", xerrors.ErrInvalidArgument)
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	return service.suggestionService.readSuggestionReviews(ctx, limit, offset)
+}
+
+type ResolveSuggestionReviewsRequest struct {
+	AcceptedIDs []uint `json:"acceptedIds"`
+	RejectedIDs []uint `json:"rejectedIds"`
+}
+
+// ResolveSuggestionReviews adds the tags of accepted reviews to their images,
+// and removes the accepted and rejected reviews from the queue
+func (service TagFrontendService) ResolveSuggestionReviews(ctx context.Context, request ResolveSuggestionReviewsRequest) error {
+	if len(request.AcceptedIDs) == 0 && len(request.RejectedIDs) == 0 {
+		return nil
+	}
+	if service.suggestionService == nil {
+		return fmt.Errorf("%w: tag suggestion service is not available", xerrors.ErrInvalidArgument)
+	}
+	if err := service.suggestionService.resolveSuggestionReviews(ctx, service.journal, request.AcceptedIDs, request.RejectedIDs); err != nil {
+		return fmt.Errorf("suggestionService.resolveSuggestionReviews: %w", err)
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/history"
 	"github.com/michael-freling/anime-image-viewer/internal/image"
 	"github.com/michael-freling/anime-image-viewer/internal/xassert"
 	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
@@ -243,8 +244,12 @@ func TestTagFrontendService_GetTagFileCount(t *testing.T) {
 
 func TestTagFrontendService_DeleteTag(t *testing.T) {
 	tester := newTester(t)
-	tester.dbClient.Truncate(&db.Tag{}, &db.FileTag{})
+	tester.dbClient.Truncate(&db.Tag{}, &db.FileTag{}, &db.File{}, &db.SuggestionReview{}, &db.Operation{})
 
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.File{
+		{ID: 100, Name: "image100.jpg", Type: db.FileTypeImage},
+		{ID: 200, Name: "image200.jpg", Type: db.FileTypeImage},
+	}))
 	require.NoError(t, db.BatchCreate(tester.dbClient, []db.Tag{
 		{ID: 1, Name: "tag1"},
 		{ID: 2, Name: "tag2"},
@@ -253,6 +258,9 @@ func TestTagFrontendService_DeleteTag(t *testing.T) {
 		{TagID: 1, FileID: 100, AddedBy: db.FileTagAddedByUser},
 		{TagID: 1, FileID: 200, AddedBy: db.FileTagAddedByUser},
 	}))
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.SuggestionReview{
+		{ID: 1, TagID: 1, FileID: 300, Score: 0.7},
+	}))
 
 	ctx := context.Background()
 	service := tester.getFrontendService(frontendServiceMocks{})
@@ -271,6 +279,16 @@ func TestTagFrontendService_DeleteTag(t *testing.T) {
 	fileTags, err := tester.dbClient.FileTag().FindAllByTagIDs([]uint{1})
 	require.NoError(t, err)
 	assert.Empty(t, fileTags)
+	assert.Empty(t, db.MustGetAll[db.SuggestionReview](t, db.TestClient{Client: tester.dbClient}))
+
+	// Undo restores the tag and its files
+	_, err = service.journal.Undo(ctx)
+	require.NoError(t, err)
+	fileTags, err = tester.dbClient.FileTag().FindAllByTagIDs([]uint{1})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uint{100, 200}, fileTags.ToFileIDs())
+	_, err = service.journal.Redo(ctx)
+	require.NoError(t, err)
 
 	// Delete tag without file associations
 	err = service.DeleteTag(ctx, 2)
@@ -286,7 +304,7 @@ func TestTagFrontendService_MergeTags(t *testing.T) {
 	ctx := context.Background()
 
 	t.Run("merge with file associations", func(t *testing.T) {
-		tester.dbClient.Truncate(&db.Tag{}, &db.FileTag{})
+		tester.dbClient.Truncate(&db.Tag{}, &db.FileTag{}, &db.SuggestionReview{})
 
 		require.NoError(t, db.BatchCreate(tester.dbClient, []db.Tag{
 			{ID: 1, Name: "source"},
@@ -298,6 +316,10 @@ func TestTagFrontendService_MergeTags(t *testing.T) {
 			{TagID: 2, FileID: 200, AddedBy: db.FileTagAddedByUser}, // overlap
 			{TagID: 2, FileID: 300, AddedBy: db.FileTagAddedByUser},
 		}))
+		require.NoError(t, db.BatchCreate(tester.dbClient, []db.SuggestionReview{
+			{ID: 1, TagID: 1, FileID: 400, Score: 0.7},
+			{ID: 2, TagID: 2, FileID: 400, Score: 0.7},
+		}))
 
 		service := tester.getFrontendService(frontendServiceMocks{})
 		err := service.MergeTags(ctx, 1, 2)
@@ -318,6 +340,44 @@ func TestTagFrontendService_MergeTags(t *testing.T) {
 		targetFileTags, err := tester.dbClient.FileTag().FindAllByTagIDs([]uint{2})
 		require.NoError(t, err)
 		assert.Len(t, targetFileTags, 3)
+
+		reviews := db.MustGetAll[db.SuggestionReview](t, db.TestClient{Client: tester.dbClient})
+		require.Len(t, reviews, 1)
+		assert.Equal(t, uint(2), reviews[0].TagID, "reviews of the source tag are deleted")
+	})
+
+	t.Run("undo restores the source tag and its files", func(t *testing.T) {
+		tester.dbClient.Truncate(&db.Tag{}, &db.FileTag{}, &db.File{}, &db.Operation{})
+
+		require.NoError(t, db.BatchCreate(tester.dbClient, []db.File{
+			{ID: 100, Name: "image100.jpg", Type: db.FileTypeImage},
+			{ID: 200, Name: "image200.jpg", Type: db.FileTypeImage},
+		}))
+		require.NoError(t, db.BatchCreate(tester.dbClient, []db.Tag{
+			{ID: 1, Name: "source"},
+			{ID: 2, Name: "target"},
+		}))
+		require.NoError(t, db.BatchCreate(tester.dbClient, []db.FileTag{
+			{TagID: 1, FileID: 100, AddedBy: db.FileTagAddedByUser},
+			{TagID: 1, FileID: 200, AddedBy: db.FileTagAddedByUser},
+			{TagID: 2, FileID: 200, AddedBy: db.FileTagAddedByUser},
+		}))
+
+		service := tester.getFrontendService(frontendServiceMocks{})
+		require.NoError(t, service.MergeTags(ctx, 1, 2))
+		_, err := service.journal.Undo(ctx)
+		require.NoError(t, err)
+
+		tags, err := db.GetAll[db.Tag](tester.dbClient)
+		require.NoError(t, err)
+		require.Len(t, tags, 2)
+		assert.Equal(t, "source", tags[0].Name)
+		sourceFileTags, err := tester.dbClient.FileTag().FindAllByTagIDs([]uint{1})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []uint{100, 200}, sourceFileTags.ToFileIDs())
+		targetFileTags, err := tester.dbClient.FileTag().FindAllByTagIDs([]uint{2})
+		require.NoError(t, err)
+		assert.Equal(t, []uint{200}, targetFileTags.ToFileIDs(), "only the links the merge added are removed")
 	})
 
 	t.Run("merge same tag returns error", func(t *testing.T) {
@@ -905,6 +965,7 @@ func TestTagFrontendService_AddSuggestedTags(t *testing.T) {
 				&db.File{},
 				&db.Tag{},
 				&db.FileTag{},
+				&db.Operation{},
 			)
 			if len(tc.insertFiles) > 0 {
 				require.NoError(t, db.BatchCreate(dbClient, tc.insertFiles))
@@ -921,6 +982,16 @@ func TestTagFrontendService_AddSuggestedTags(t *testing.T) {
 				suggestionService: suggestionService,
 			})
 			got, gotErr := service.AddSuggestedTags(context.Background(), tc.request)
+			if gotErr == nil {
+				operations, err := db.GetAll[db.Operation](dbClient)
+				require.NoError(t, err)
+				if len(tc.wantInsertedFileTags) > 0 {
+					require.Len(t, operations, 1, "the added tags are recorded in the history")
+					assert.Equal(t, string(history.KindAddSuggestedTags), operations[0].Kind)
+				} else {
+					assert.Empty(t, operations)
+				}
+			}
 			if tc.wantErr != nil {
 				assert.ErrorIs(t, gotErr, tc.wantErr)
 				return
@@ -949,3 +1020,176 @@ func TestTagFrontendService_AddSuggestedTags(t *testing.T) {
 		})
 	}
 }
+
+func TestTagFrontendService_AddSuggestedCharacters(t *testing.T) {
+	tester := newTester(t)
+	dbClient := tester.dbClient
+
+	animeID := uint(100)
+	dbClient.Truncate(&db.File{}, &db.Character{})
+	require.NoError(t, db.BatchCreate(dbClient, []db.File{
+		{ID: 1, Name: "Anime 1", Type: db.FileTypeDirectory, AnimeID: &animeID},
+		{ID: 11, Name: "image11.jpg", Type: db.FileTypeImage, ParentID: 1},
+		{ID: 12, Name: "image12.jpg", Type: db.FileTypeImage, ParentID: 1},
+	}))
+	require.NoError(t, db.BatchCreate(dbClient, []db.Character{
+		{ID: 1, Name: "Character 1", AnimeID: animeID},
+		{ID: 2, Name: "Character 2", AnimeID: animeID},
+		{ID: 3, Name: "Another anime's character", AnimeID: 200},
+	}))
+
+	testCases := []struct {
+		name    string
+		request AddSuggestedCharactersRequest
+
+		want                       AddSuggestedCharactersResponse
+		wantInsertedFileCharacters []db.FileCharacter
+		wantOperations             int
+		wantErr                    error
+	}{
+		{
+			name:    "empty request returns empty response",
+			request: AddSuggestedCharactersRequest{},
+			want:    AddSuggestedCharactersResponse{},
+		},
+		{
+			name: "add characters as suggested and skip characters on the images",
+			request: AddSuggestedCharactersRequest{
+				SelectedCharacters: map[uint][]uint{
+					11: {1, 2},
+					12: {2},
+				},
+			},
+			want: AddSuggestedCharactersResponse{
+				DuplicatedCharacters: map[uint][]uint{
+					11: {1},
+				},
+			},
+			wantInsertedFileCharacters: []db.FileCharacter{
+				{FileID: 11, CharacterID: 2, AddedBy: db.FileTagAddedBySuggestion},
+				{FileID: 12, CharacterID: 2, AddedBy: db.FileTagAddedBySuggestion},
+			},
+			wantOperations: 1,
+		},
+		{
+			name: "a character of another anime is rejected",
+			request: AddSuggestedCharactersRequest{
+				SelectedCharacters: map[uint][]uint{
+					11: {2},
+					12: {3},
+				},
+			},
+			wantErr: xerrors.ErrInvalidArgument,
+		},
+		{
+			name: "a missing image is rejected",
+			request: AddSuggestedCharactersRequest{
+				SelectedCharacters: map[uint][]uint{
+					99: {1},
+				},
+			},
+			wantErr: xerrors.ErrInvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dbClient.Truncate(&db.FileCharacter{}, &db.Operation{})
+			insertFileCharacters := []db.FileCharacter{
+				{FileID: 11, CharacterID: 1, AddedBy: db.FileTagAddedByUser},
+			}
+			require.NoError(t, db.BatchCreate(dbClient, insertFileCharacters))
+
+			suggestionService := tester.getTagSuggestionService(t, func(mock *tag_suggestionv1.MockTagSuggestionServiceClient) {})
+			service := tester.getFrontendService(frontendServiceMocks{
+				suggestionService: suggestionService,
+			})
+			got, gotErr := service.AddSuggestedCharacters(context.Background(), tc.request)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, gotErr, tc.wantErr)
+			} else {
+				require.NoError(t, gotErr)
+				assert.Equal(t, tc.want, got)
+			}
+
+			gotFileCharacters, err := db.GetAll[db.FileCharacter](dbClient)
+			require.NoError(t, err)
+			xassert.ElementsMatch(t,
+				slices.Concat(insertFileCharacters, tc.wantInsertedFileCharacters),
+				gotFileCharacters,
+				cmpopts.SortSlices(func(a, b db.FileCharacter) bool {
+					if a.FileID == b.FileID {
+						return a.CharacterID < b.CharacterID
+					}
+					return a.FileID < b.FileID
+				}),
+				cmpopts.IgnoreFields(db.FileCharacter{}, "CreatedAt"),
+			)
+
+			gotOperations := db.MustGetAll[db.Operation](t, db.TestClient{Client: dbClient})
+			require.Len(t, gotOperations, tc.wantOperations)
+			for _, operation := range gotOperations {
+				assert.Equal(t, string(history.KindAddSuggestedCharacters), operation.Kind)
+			}
+		})
+	}
+}
+
+func TestTagFrontendService_AddSuggestedTags_suggestionEvents(t *testing.T) {
+	tester := newTester(t)
+	dbClient := tester.dbClient
+	dbClient.Truncate(&db.File{}, &db.Tag{}, &db.FileTag{}, &db.SuggestionEvent{})
+	require.NoError(t, db.BatchCreate(dbClient, []db.File{
+		{ID: 1, Name: "Directory 1", Type: db.FileTypeDirectory},
+		{ID: 11, Name: "image11.jpg", Type: db.FileTypeImage, ParentID: 1},
+		{ID: 12, Name: "image12.jpg", Type: db.FileTypeImage, ParentID: 1},
+	}))
+	require.NoError(t, db.BatchCreate(dbClient, []db.Tag{
+		{ID: 1, Name: "tag1"},
+		{ID: 2, Name: "tag2"},
+	}))
+	require.NoError(t, db.BatchCreate(dbClient, []db.FileTag{
+		{FileID: 11, TagID: 1, AddedBy: db.FileTagAddedByUser},
+	}))
+
+	suggestionService := tester.getTagSuggestionService(t, func(mock *tag_suggestionv1.MockTagSuggestionServiceClient) {})
+	service := tester.getFrontendService(frontendServiceMocks{
+		suggestionService: suggestionService,
+	})
+	got, gotErr := service.AddSuggestedTags(context.Background(), AddSuggestedTagsRequest{
+		SelectedTags: map[uint][]uint{11: {1, 2}},
+		RejectedTags: map[uint][]uint{12: {2}},
+		Scores: map[uint]map[uint]float64{
+			11: {1: 0.9, 2: 0.8},
+			12: {2: 0.6},
+		},
+		Model: SuggestionModel{Name: "tag-suggestion", Version: "2024-12-01"},
+	})
+	require.NoError(t, gotErr)
+	assert.Equal(t, AddSuggestedTagsResponse{
+		DuplicatedTags: map[uint][]uint{11: {1}},
+	}, got)
+
+	gotEvents, err := db.GetAll[db.SuggestionEvent](dbClient)
+	require.NoError(t, err)
+	xassert.ElementsMatch(t,
+		[]db.SuggestionEvent{
+			// a tag which was already on the image is still accepted
+			{FileID: 11, TagID: 1, Score: 0.9, ModelName: "tag-suggestion", ModelVersion: "2024-12-01", Decision: db.SuggestionDecisionAccepted},
+			{FileID: 11, TagID: 2, Score: 0.8, ModelName: "tag-suggestion", ModelVersion: "2024-12-01", Decision: db.SuggestionDecisionAccepted},
+			{FileID: 12, TagID: 2, Score: 0.6, ModelName: "tag-suggestion", ModelVersion: "2024-12-01", Decision: db.SuggestionDecisionRejected},
+		},
+		gotEvents,
+		cmpopts.SortSlices(func(a, b db.SuggestionEvent) bool {
+			if a.FileID == b.FileID {
+				return a.TagID < b.TagID
+			}
+			return a.FileID < b.FileID
+		}),
+		cmpopts.IgnoreFields(db.SuggestionEvent{}, "ID", "CreatedAt"),
+	)
+
+	gotFileTags, err := db.GetAll[db.FileTag](dbClient)
+	require.NoError(t, err)
+	assert.Len(t, gotFileTags, 2, "a rejected tag isn't added")
+}
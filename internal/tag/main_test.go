@@ -7,8 +7,10 @@ import (
 
 	"github.com/michael-freling/anime-image-viewer/internal/config"
 	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/history"
 	"github.com/michael-freling/anime-image-viewer/internal/image"
 	tag_suggestionv1 "github.com/michael-freling/anime-image-viewer/plugins/plugins-protos/gen/go/tag_suggestion/v1"
+	tag_suggestionv2 "github.com/michael-freling/anime-image-viewer/plugins/plugins-protos/gen/go/tag_suggestion/v2"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
@@ -82,6 +84,7 @@ func (tester Tester) getFrontendService(mocks frontendServiceMocks) *TagFrontend
 		tester.dbClient,
 		tester.getReader(),
 		mocks.suggestionService,
+		history.NewJournal(tester.dbClient, config.HistoryConfig{UserName: "tester", UndoLimit: 10}),
 	)
 }
 
@@ -114,6 +117,30 @@ func (tester Tester) getTagSuggestionService(
 	)
 }
 
+func (tester Tester) getTagSuggestionServiceV2(
+	t *testing.T,
+	thumbnailWidth uint,
+	setupMockClient func(*tag_suggestionv2.MockTagSuggestionServiceClient),
+) *SuggestionService {
+	t.Helper()
+
+	if tester.mockController == nil {
+		tester.mockController = gomock.NewController(t)
+		t.Cleanup(tester.mockController.Finish)
+	}
+	mockSuggestionClient := tag_suggestionv2.NewMockTagSuggestionServiceClient(tester.mockController)
+	setupMockClient(mockSuggestionClient)
+
+	return NewSuggestionServiceV2(
+		tester.dbClient,
+		mockSuggestionClient,
+		image.NewResizer(tester.logger),
+		thumbnailWidth,
+		tester.getReader(),
+		tester.getImageReader(),
+	)
+}
+
 func (tester Tester) newFileCreator(t *testing.T) *image.FileCreator {
 	return image.NewFileCreator(t, tester.config.ImageRootDirectory)
 }
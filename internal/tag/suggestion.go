@@ -6,7 +6,9 @@ import (
 	"log/slog"
 
 	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/history"
 	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/michael-freling/anime-image-viewer/internal/xerrors"
 	tag_suggestionv1 "github.com/michael-freling/anime-image-viewer/plugins/plugins-protos/gen/go/tag_suggestion/v1"
 	"golang.org/x/sync/errgroup"
 )
@@ -17,15 +19,72 @@ type TagSuggestion struct {
 	HasTag bool    `json:"hasTag"`
 }
 
+type CharacterSuggestion struct {
+	CharacterID  uint    `json:"characterId"`
+	Score        float64 `json:"score"`
+	HasCharacter bool    `json:"hasCharacter"`
+}
+
+// Character is a character that can be suggested for an image
+type Character struct {
+	ID      uint   `json:"id"`
+	Name    string `json:"name"`
+	AnimeID uint   `json:"animeId"`
+}
+
 type SuggestionService struct {
-	dbClient             *db.Client
-	suggestServiceClient tag_suggestionv1.TagSuggestionServiceClient
+	dbClient  *db.Client
+	suggester tagSuggester
 
 	reader *Reader
 
 	imageReader *image.Reader
 }
 
+// tagSuggestion is the scores of tags and characters for an image
+type tagSuggestion struct {
+	scores          []tagScore
+	characterScores []characterScore
+}
+
+type tagScore struct {
+	tagID uint
+	score float64
+}
+
+type characterScore struct {
+	characterID uint
+	score       float64
+}
+
+// tagSuggestions is what a plugin suggests for images
+type tagSuggestions struct {
+	// suggestions are in the same order as the images
+	suggestions []tagSuggestion
+	// vocabulary maps the IDs of the tags that the model can suggest to
+	// their names. It's nil if the plugin doesn't tell it.
+	vocabulary map[uint]string
+	// characterVocabulary maps the IDs of the characters that the model can
+	// suggest to their names, in the same way as vocabulary
+	characterVocabulary map[uint]string
+	model               SuggestionModel
+}
+
+// tagSuggester is implemented for each version of the tag suggestion protocol.
+// animeIDs maps image file IDs to the anime of their directories, and an image
+// without an anime isn't in it.
+type tagSuggester interface {
+	suggest(ctx context.Context, imageFiles []image.ImageFile, animeIDs map[uint]uint) (tagSuggestions, error)
+}
+
+// SuggestionModel is the model that suggested tags
+type SuggestionModel struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// NewSuggestionService creates a service for a plugin of tag_suggestion.v1,
+// which reads images from the same filesystem as this app.
 func NewSuggestionService(
 	dbClient *db.Client,
 	tagSuggestionClient tag_suggestionv1.TagSuggestionServiceClient,
@@ -33,8 +92,8 @@ func NewSuggestionService(
 	imageReader *image.Reader,
 ) *SuggestionService {
 	return &SuggestionService{
-		dbClient:             dbClient,
-		suggestServiceClient: tagSuggestionClient,
+		dbClient:  dbClient,
+		suggester: suggesterV1{client: tagSuggestionClient},
 
 		reader: reader,
 
@@ -42,7 +101,50 @@ func NewSuggestionService(
 	}
 }
 
-func (service *SuggestionService) suggestTags(ctx context.Context, imageFileIDs []uint) (SuggestTagsResponse, error) {
+type suggesterV1 struct {
+	client tag_suggestionv1.TagSuggestionServiceClient
+}
+
+// suggest doesn't suggest characters, which tag_suggestion.v1 doesn't support
+func (suggester suggesterV1) suggest(ctx context.Context, imageFiles []image.ImageFile, _ map[uint]uint) (tagSuggestions, error) {
+	imageUrls := make([]string, len(imageFiles))
+	for index, imageFile := range imageFiles {
+		imageUrls[index] = imageFile.LocalFilePath
+	}
+	response, err := suggester.client.Suggest(ctx, &tag_suggestionv1.SuggestRequest{
+		ImageUrls: imageUrls,
+	})
+	if err != nil {
+		return tagSuggestions{}, fmt.Errorf("suggestServiceClient.Suggest: %w", err)
+	}
+
+	logger := slog.Default()
+	logger.DebugContext(ctx, "suggest tag client response",
+		"imageUrls", imageUrls,
+		"response", response,
+	)
+	if len(response.Suggestions) != len(imageFiles) {
+		return tagSuggestions{}, nil
+	}
+
+	suggestions := make([]tagSuggestion, len(response.Suggestions))
+	for index, suggestion := range response.Suggestions {
+		scores := make([]tagScore, len(suggestion.Scores))
+		for scoreIndex, score := range suggestion.Scores {
+			scores[scoreIndex] = tagScore{
+				tagID: uint(score.TagId),
+				score: score.Score,
+			}
+		}
+		suggestions[index] = tagSuggestion{scores: scores}
+	}
+	return tagSuggestions{suggestions: suggestions}, nil
+}
+
+// SuggestTags asks the plugin for the scores of tags and characters of the
+// images. It returns an empty response if the plugin didn't suggest for every
+// image.
+func (service *SuggestionService) SuggestTags(ctx context.Context, imageFileIDs []uint) (SuggestTagsResponse, error) {
 	imageFiles, err := service.imageReader.ReadImagesByIDs(imageFileIDs)
 	if err != nil {
 		return SuggestTagsResponse{}, fmt.Errorf("imageReader.getImagesByIDs: %w", err)
@@ -52,20 +154,38 @@ func (service *SuggestionService) suggestTags(ctx context.Context, imageFileIDs
 	}
 
 	imageFileMap := imageFiles.ToMap()
-	imageUrls := make([]string, len(imageFileMap))
+	orderedImageFiles := make([]image.ImageFile, len(imageFileIDs))
 	for index, imageFileID := range imageFileIDs {
-		imageUrls[index] = imageFileMap[imageFileID].LocalFilePath
+		orderedImageFiles[index] = imageFileMap[imageFileID]
+	}
+	parentIDs := make(map[uint]uint, len(orderedImageFiles))
+	for _, imageFile := range orderedImageFiles {
+		parentIDs[imageFile.ID] = imageFile.ParentID
+	}
+	animeIDs, err := service.findAnimeIDs(ctx, parentIDs)
+	if err != nil {
+		return SuggestTagsResponse{}, fmt.Errorf("findAnimeIDs: %w", err)
 	}
 
 	eg, childCtx := errgroup.WithContext(ctx)
-	var response *tag_suggestionv1.SuggestResponse
+	var response tagSuggestions
 	eg.Go(func() error {
 		var err error
-		response, err = service.suggestServiceClient.Suggest(childCtx, &tag_suggestionv1.SuggestRequest{
-			ImageUrls: imageUrls,
-		})
+		response, err = service.suggester.suggest(childCtx, orderedImageFiles, animeIDs)
+		return err
+	})
+
+	var animeCharacters map[uint]map[uint]db.Character
+	var fileCharacters map[uint]map[uint]struct{}
+	eg.Go(func() error {
+		var err error
+		animeCharacters, err = service.readAnimeCharacters(childCtx, animeIDs)
+		if err != nil {
+			return fmt.Errorf("readAnimeCharacters: %w", err)
+		}
+		fileCharacters, err = service.readFileCharacters(imageFileIDs)
 		if err != nil {
-			return fmt.Errorf("suggestServiceClient.Suggest: %w", err)
+			return fmt.Errorf("readFileCharacters: %w", err)
 		}
 		return nil
 	})
@@ -91,52 +211,203 @@ func (service *SuggestionService) suggestTags(ctx context.Context, imageFileIDs
 	if err := eg.Wait(); err != nil {
 		return SuggestTagsResponse{}, fmt.Errorf("eg.Wait: %w", err)
 	}
-	if len(response.Suggestions) != len(imageFileIDs) {
+	if len(response.suggestions) != len(imageFileIDs) {
 		return SuggestTagsResponse{}, nil
 	}
 
 	logger := slog.Default()
-	logger.DebugContext(ctx, "suggest tag client response",
-		"imageFileIDs", imageFileIDs,
-		"imageUrls", imageUrls,
-		"response", response,
-	)
-
-	// imageFiles := make([]image.ImageFile, len(imageFileIDs))
-	suggestionsForImageFiles := make(map[uint][]TagSuggestion, len(response.Suggestions))
+	suggestionsForImageFiles := make(map[uint][]TagSuggestion, len(response.suggestions))
 	for index, imageFileID := range imageFileIDs {
-		// imageFile := imageFileMap[imageFileID]
-		// imageFiles[index] = imageFile
 		batchTagChecker := tagChecker.GetTagCheckerForImageFileID(imageFileID)
-		tagSuggestion := response.Suggestions[index]
-		suggestions := make([]TagSuggestion, 0, len(tagSuggestion.Scores))
-		for _, score := range tagSuggestion.Scores {
-			tag, ok := allTagMap[uint(score.TagId)]
+		tagSuggestion := response.suggestions[index]
+		suggestions := make([]TagSuggestion, 0, len(tagSuggestion.scores))
+		for _, score := range tagSuggestion.scores {
+			tag, ok := allTagMap[score.tagID]
 			if !ok {
 				logger.WarnContext(ctx, "tag was not found",
-					"tag_id", score.TagId,
+					"tag_id", score.tagID,
 				)
 				continue
 			}
+			if response.vocabulary != nil {
+				// a model trained before a tag was renamed or recreated
+				// suggests a different tag with the same ID
+				if name, ok := response.vocabulary[score.tagID]; !ok || name != tag.Name {
+					logger.WarnContext(ctx, "tag in the model doesn't match",
+						"tag_id", score.tagID,
+						"tag_name", tag.Name,
+						"model_tag_name", name,
+					)
+					continue
+				}
+			}
 
 			suggestions = append(suggestions, TagSuggestion{
-				TagID:  uint(score.TagId),
-				Score:  score.Score,
+				TagID:  score.tagID,
+				Score:  score.score,
 				HasTag: batchTagChecker.HasTag(tag.ID),
 			})
 		}
 		suggestionsForImageFiles[imageFileID] = suggestions
 	}
 
-	return SuggestTagsResponse{
-		// ImageFiles:  imageFiles,
+	characterSuggestionsForImageFiles := make(map[uint][]CharacterSuggestion, len(response.suggestions))
+	allCharacters := make(map[uint]Character)
+	for index, imageFileID := range imageFileIDs {
+		characterScores := response.suggestions[index].characterScores
+		if len(characterScores) == 0 {
+			continue
+		}
+
+		// only characters of the image's anime are suggested
+		characters := animeCharacters[animeIDs[imageFileID]]
+		suggestions := make([]CharacterSuggestion, 0, len(characterScores))
+		for _, score := range characterScores {
+			character, ok := characters[score.characterID]
+			if !ok {
+				logger.WarnContext(ctx, "character was not found in the anime",
+					"character_id", score.characterID,
+					"anime_id", animeIDs[imageFileID],
+				)
+				continue
+			}
+			if response.characterVocabulary != nil {
+				if name, ok := response.characterVocabulary[score.characterID]; !ok || name != character.Name {
+					logger.WarnContext(ctx, "character in the model doesn't match",
+						"character_id", score.characterID,
+						"character_name", character.Name,
+						"model_character_name", name,
+					)
+					continue
+				}
+			}
+
+			_, hasCharacter := fileCharacters[imageFileID][character.ID]
+			suggestions = append(suggestions, CharacterSuggestion{
+				CharacterID:  character.ID,
+				Score:        score.score,
+				HasCharacter: hasCharacter,
+			})
+			allCharacters[character.ID] = Character{
+				ID:      character.ID,
+				Name:    character.Name,
+				AnimeID: character.AnimeID,
+			}
+		}
+		characterSuggestionsForImageFiles[imageFileID] = suggestions
+	}
+
+	result := SuggestTagsResponse{
 		Suggestions: suggestionsForImageFiles,
 		AllTags:     allTagMap,
-	}, nil
+		Model:       response.model,
+	}
+	if len(characterSuggestionsForImageFiles) > 0 {
+		result.CharacterSuggestions = characterSuggestionsForImageFiles
+		result.AllCharacters = allCharacters
+	}
+	return result, nil
 }
 
-func (service *SuggestionService) addSuggestedTags(ctx context.Context, selectedTags map[uint][]uint) (map[uint][]uint, error) {
-	fileIDs := make([]uint, len(selectedTags))
+// findAnimeIDs returns the anime assigned to the closest ancestor directory
+// of each image, by the IDs of the images and their parent directories
+func (service *SuggestionService) findAnimeIDs(ctx context.Context, parentIDs map[uint]uint) (map[uint]uint, error) {
+	directoryIDs := make([]uint, 0, len(parentIDs))
+	for _, parentID := range parentIDs {
+		directoryIDs = append(directoryIDs, parentID)
+	}
+	directoryAnimeIDs, err := service.dbClient.File().FindAnimeIDsByDirectoryIDs(ctx, directoryIDs)
+	if err != nil {
+		return nil, fmt.Errorf("File.FindAnimeIDsByDirectoryIDs: %w", err)
+	}
+	result := make(map[uint]uint)
+	for imageFileID, parentID := range parentIDs {
+		if animeID, ok := directoryAnimeIDs[parentID]; ok {
+			result[imageFileID] = animeID
+		}
+	}
+	return result, nil
+}
+
+// readAnimeCharacters returns the characters of each anime by their IDs
+func (service *SuggestionService) readAnimeCharacters(ctx context.Context, animeIDs map[uint]uint) (map[uint]map[uint]db.Character, error) {
+	result := make(map[uint]map[uint]db.Character)
+	for _, animeID := range animeIDs {
+		if _, ok := result[animeID]; ok {
+			continue
+		}
+		characters, err := service.dbClient.Character().FindByAnimeID(ctx, animeID)
+		if err != nil {
+			return nil, fmt.Errorf("Character.FindByAnimeID: %w", err)
+		}
+		result[animeID] = make(map[uint]db.Character, len(characters))
+		for _, character := range characters {
+			result[animeID][character.ID] = character
+		}
+	}
+	return result, nil
+}
+
+// readFileCharacters returns the IDs of the characters on each file
+func (service *SuggestionService) readFileCharacters(fileIDs []uint) (map[uint]map[uint]struct{}, error) {
+	fileCharacters, err := service.dbClient.FileCharacter().FindByFileIDs(fileIDs)
+	if err != nil {
+		return nil, fmt.Errorf("FileCharacter.FindByFileIDs: %w", err)
+	}
+	result := make(map[uint]map[uint]struct{})
+	for _, fileCharacter := range fileCharacters {
+		if _, ok := result[fileCharacter.FileID]; !ok {
+			result[fileCharacter.FileID] = make(map[uint]struct{})
+		}
+		result[fileCharacter.FileID][fileCharacter.CharacterID] = struct{}{}
+	}
+	return result, nil
+}
+
+// suggestionFeedback is what a user decided on suggestions, besides the
+// selected ones, to record as suggestion events
+type suggestionFeedback struct {
+	// rejected maps file IDs to the IDs of suggested tags or characters which
+	// weren't selected
+	rejected map[uint][]uint
+	// scores maps file IDs and the IDs of tags or characters to their scores
+	scores map[uint]map[uint]float64
+	model  SuggestionModel
+}
+
+// events returns the events of the selected and rejected suggestions, with
+// setID setting the tag or character ID on each event
+func (feedback suggestionFeedback) events(selected map[uint][]uint, setID func(event *db.SuggestionEvent, id uint)) []db.SuggestionEvent {
+	events := make([]db.SuggestionEvent, 0)
+	for _, decision := range []struct {
+		ids      map[uint][]uint
+		decision db.SuggestionDecision
+	}{
+		{ids: selected, decision: db.SuggestionDecisionAccepted},
+		{ids: feedback.rejected, decision: db.SuggestionDecisionRejected},
+	} {
+		for fileID, ids := range decision.ids {
+			for _, id := range ids {
+				event := db.SuggestionEvent{
+					FileID:       fileID,
+					Score:        feedback.scores[fileID][id],
+					ModelName:    feedback.model.Name,
+					ModelVersion: feedback.model.Version,
+					Decision:     decision.decision,
+				}
+				setID(&event, id)
+				events = append(events, event)
+			}
+		}
+	}
+	return events
+}
+
+// addSuggestedTags adds the selected tags to files as added by a suggestion,
+// and returns the tags which were already on the files. The added tags are
+// recorded in journal.
+func (service *SuggestionService) addSuggestedTags(ctx context.Context, journal *history.Journal, selectedTags map[uint][]uint, feedback suggestionFeedback) (map[uint][]uint, error) {
+	fileIDs := make([]uint, 0, len(selectedTags))
 	for fileID := range selectedTags {
 		fileIDs = append(fileIDs, fileID)
 	}
@@ -165,12 +436,131 @@ func (service *SuggestionService) addSuggestedTags(ctx context.Context, selected
 			})
 		}
 	}
-	if len(fileTags) == 0 {
+	// a suggested tag which was already on a file is still a correct one
+	events := feedback.events(selectedTags, func(event *db.SuggestionEvent, id uint) {
+		event.TagID = id
+	})
+	if len(fileTags) == 0 && len(events) == 0 {
 		return duplicatedTags, nil
 	}
 
-	if err := db.BatchCreate(service.dbClient, fileTags); err != nil {
-		return duplicatedTags, fmt.Errorf("db.BatchCreate: %w", err)
+	err = db.NewTransaction(ctx, service.dbClient, func(ctx context.Context) error {
+		if len(fileTags) > 0 {
+			if err := service.dbClient.FileTag().BatchCreate(ctx, fileTags); err != nil {
+				return fmt.Errorf("FileTag.BatchCreate: %w", err)
+			}
+		}
+		if len(events) > 0 {
+			if err := service.dbClient.SuggestionEvent().BatchCreate(ctx, events); err != nil {
+				return fmt.Errorf("SuggestionEvent.BatchCreate: %w", err)
+			}
+		}
+		return journal.Record(ctx, history.KindAddSuggestedTags,
+			fmt.Sprintf("Added %d suggested tags to %d files", len(fileTags), len(fileTags.ToFileIDs())),
+			history.Change{
+				AddedFileTags: fileTags,
+			},
+		)
+	})
+	if err != nil {
+		return duplicatedTags, err
 	}
 	return duplicatedTags, nil
 }
+
+// addSuggestedCharacters links the selected characters to files as added by a
+// suggestion, and returns the characters which were already on the files. A
+// character must be one of the anime of the file. The added characters are
+// recorded in journal.
+func (service *SuggestionService) addSuggestedCharacters(ctx context.Context, journal *history.Journal, selectedCharacters map[uint][]uint, feedback suggestionFeedback) (map[uint][]uint, error) {
+	fileIDs := make([]uint, 0, len(selectedCharacters))
+	for fileID := range selectedCharacters {
+		fileIDs = append(fileIDs, fileID)
+	}
+	if err := service.validateCharacters(ctx, fileIDs, selectedCharacters); err != nil {
+		return nil, err
+	}
+	existingCharacters, err := service.readFileCharacters(fileIDs)
+	if err != nil {
+		return nil, fmt.Errorf("readFileCharacters: %w", err)
+	}
+
+	duplicatedCharacters := make(map[uint][]uint)
+	fileCharacters := make([]db.FileCharacter, 0)
+	for fileID, characterIDs := range selectedCharacters {
+		for _, characterID := range characterIDs {
+			if _, ok := existingCharacters[fileID][characterID]; ok {
+				duplicatedCharacters[fileID] = append(duplicatedCharacters[fileID], characterID)
+				continue
+			}
+
+			fileCharacters = append(fileCharacters, db.FileCharacter{
+				FileID:      fileID,
+				CharacterID: characterID,
+				AddedBy:     db.FileTagAddedBySuggestion,
+			})
+		}
+	}
+	events := feedback.events(selectedCharacters, func(event *db.SuggestionEvent, id uint) {
+		event.CharacterID = id
+	})
+	if len(fileCharacters) == 0 && len(events) == 0 {
+		return duplicatedCharacters, nil
+	}
+
+	err = db.NewTransaction(ctx, service.dbClient, func(ctx context.Context) error {
+		if len(fileCharacters) > 0 {
+			if err := service.dbClient.FileCharacter().BatchCreate(ctx, fileCharacters); err != nil {
+				return fmt.Errorf("FileCharacter.BatchCreate: %w", err)
+			}
+		}
+		if len(events) > 0 {
+			if err := service.dbClient.SuggestionEvent().BatchCreate(ctx, events); err != nil {
+				return fmt.Errorf("SuggestionEvent.BatchCreate: %w", err)
+			}
+		}
+		return journal.Record(ctx, history.KindAddSuggestedCharacters,
+			fmt.Sprintf("Added %d suggested characters", len(fileCharacters)),
+			history.Change{
+				AddedFileCharacters: fileCharacters,
+			},
+		)
+	})
+	if err != nil {
+		return duplicatedCharacters, err
+	}
+	return duplicatedCharacters, nil
+}
+
+// validateCharacters checks that each character selected for a file is one
+// of the anime the file belongs to
+func (service *SuggestionService) validateCharacters(ctx context.Context, fileIDs []uint, selectedCharacters map[uint][]uint) error {
+	files, err := service.dbClient.File().FindImageFilesByIDs(fileIDs)
+	if err != nil {
+		return fmt.Errorf("File.FindImageFilesByIDs: %w", err)
+	}
+	parentIDs := make(map[uint]uint, len(files))
+	for _, file := range files {
+		parentIDs[file.ID] = file.ParentID
+	}
+	animeIDs, err := service.findAnimeIDs(ctx, parentIDs)
+	if err != nil {
+		return fmt.Errorf("findAnimeIDs: %w", err)
+	}
+	animeCharacters, err := service.readAnimeCharacters(ctx, animeIDs)
+	if err != nil {
+		return fmt.Errorf("readAnimeCharacters: %w", err)
+	}
+
+	for fileID, characterIDs := range selectedCharacters {
+		if _, ok := parentIDs[fileID]; !ok {
+			return fmt.Errorf("%w: image %d is not found", xerrors.ErrInvalidArgument, fileID)
+		}
+		for _, characterID := range characterIDs {
+			if _, ok := animeCharacters[animeIDs[fileID]][characterID]; !ok {
+				return fmt.Errorf("%w: character %d is not of the anime of image %d", xerrors.ErrInvalidArgument, characterID, fileID)
+			}
+		}
+	}
+	return nil
+}
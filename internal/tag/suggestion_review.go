@@ -0,0 +1,166 @@
+package tag
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/history"
+)
+
+// SuggestionReview is a tag suggested by auto-tagging, which waits for a user
+// to accept or reject it
+type SuggestionReview struct {
+	ID     uint            `json:"id"`
+	FileID uint            `json:"fileId"`
+	TagID  uint            `json:"tagId"`
+	Score  float64         `json:"score"`
+	Model  SuggestionModel `json:"model"`
+}
+
+func (service *SuggestionService) readSuggestionReviews(ctx context.Context, limit int, offset int) ([]SuggestionReview, error) {
+	reviews, err := service.dbClient.SuggestionReview().FindLatest(ctx, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("SuggestionReview.FindLatest: %w", err)
+	}
+	result := make([]SuggestionReview, len(reviews))
+	for index, review := range reviews {
+		result[index] = SuggestionReview{
+			ID:     review.ID,
+			FileID: review.FileID,
+			TagID:  review.TagID,
+			Score:  review.Score,
+			Model: SuggestionModel{
+				Name:    review.ModelName,
+				Version: review.ModelVersion,
+			},
+		}
+	}
+	return result, nil
+}
+
+// resolveSuggestionReviews adds the tags of accepted reviews to their files,
+// records the decisions as suggestion events, and deletes the reviews. The
+// added tags are recorded in journal.
+func (service *SuggestionService) resolveSuggestionReviews(ctx context.Context, journal *history.Journal, acceptedIDs []uint, rejectedIDs []uint) error {
+	reviewClient := service.dbClient.SuggestionReview()
+	accepted, err := reviewClient.FindByIDs(ctx, acceptedIDs)
+	if err != nil {
+		return fmt.Errorf("SuggestionReview.FindByIDs: %w", err)
+	}
+	rejected, err := reviewClient.FindByIDs(ctx, rejectedIDs)
+	if err != nil {
+		return fmt.Errorf("SuggestionReview.FindByIDs: %w", err)
+	}
+
+	// a review of an image or a tag deleted since it was queued is dropped
+	accepted, staleIDs, err := service.filterExistingReviews(accepted)
+	if err != nil {
+		return fmt.Errorf("filterExistingReviews: %w", err)
+	}
+	fileIDs := make([]uint, len(accepted))
+	for index, review := range accepted {
+		fileIDs[index] = review.FileID
+	}
+	batchTagChecker, err := service.reader.CreateBatchTagCheckerByFileIDs(ctx, fileIDs)
+	if err != nil {
+		return fmt.Errorf("reader.CreateBatchTagCheckerByFileIDs: %w", err)
+	}
+
+	fileTags := make([]db.FileTag, 0, len(accepted))
+	events := make([]db.SuggestionEvent, 0, len(accepted)+len(rejected))
+	reviewIDs := append(make([]uint, 0, len(staleIDs)+len(accepted)+len(rejected)), staleIDs...)
+	for _, decision := range []struct {
+		reviews  []db.SuggestionReview
+		decision db.SuggestionDecision
+	}{
+		{reviews: accepted, decision: db.SuggestionDecisionAccepted},
+		{reviews: rejected, decision: db.SuggestionDecisionRejected},
+	} {
+		for _, review := range decision.reviews {
+			if decision.decision == db.SuggestionDecisionAccepted &&
+				!batchTagChecker.GetTagCheckerForImageFileID(review.FileID).HasTag(review.TagID) {
+				fileTags = append(fileTags, db.FileTag{
+					FileID:  review.FileID,
+					TagID:   review.TagID,
+					AddedBy: db.FileTagAddedBySuggestion,
+				})
+			}
+			events = append(events, db.SuggestionEvent{
+				FileID:       review.FileID,
+				TagID:        review.TagID,
+				Score:        review.Score,
+				ModelName:    review.ModelName,
+				ModelVersion: review.ModelVersion,
+				Decision:     decision.decision,
+			})
+			reviewIDs = append(reviewIDs, review.ID)
+		}
+	}
+	if len(reviewIDs) == 0 {
+		return nil
+	}
+
+	return db.NewTransaction(ctx, service.dbClient, func(ctx context.Context) error {
+		if len(fileTags) > 0 {
+			if err := service.dbClient.FileTag().BatchCreate(ctx, fileTags); err != nil {
+				return fmt.Errorf("FileTag.BatchCreate: %w", err)
+			}
+		}
+		if len(events) > 0 {
+			if err := service.dbClient.SuggestionEvent().BatchCreate(ctx, events); err != nil {
+				return fmt.Errorf("SuggestionEvent.BatchCreate: %w", err)
+			}
+		}
+		if err := reviewClient.DeleteByIDs(ctx, reviewIDs); err != nil {
+			return fmt.Errorf("SuggestionReview.DeleteByIDs: %w", err)
+		}
+		return journal.Record(ctx, history.KindResolveSuggestionReviews,
+			fmt.Sprintf("Accepted %d and rejected %d suggested tags", len(accepted), len(rejected)),
+			history.Change{
+				AddedFileTags: fileTags,
+			},
+		)
+	})
+}
+
+// filterExistingReviews returns the reviews whose image and tag still exist,
+// and the IDs of the others
+func (service *SuggestionService) filterExistingReviews(reviews []db.SuggestionReview) ([]db.SuggestionReview, []uint, error) {
+	if len(reviews) == 0 {
+		return reviews, nil, nil
+	}
+	fileIDs := make([]uint, len(reviews))
+	tagIDs := make([]uint, len(reviews))
+	for index, review := range reviews {
+		fileIDs[index] = review.FileID
+		tagIDs[index] = review.TagID
+	}
+	files, err := service.dbClient.File().FindImageFilesByIDs(fileIDs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("File.FindImageFilesByIDs: %w", err)
+	}
+	tags, err := service.dbClient.Tag().FindAllByTagIDs(tagIDs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Tag.FindAllByTagIDs: %w", err)
+	}
+	isFileFound := make(map[uint]bool, len(files))
+	for _, file := range files {
+		isFileFound[file.ID] = true
+	}
+	isTagFound := make(map[uint]bool, len(tags))
+	for _, tag := range tags {
+		isTagFound[tag.ID] = true
+	}
+
+	result := make([]db.SuggestionReview, 0, len(reviews))
+	staleIDs := make([]uint, 0)
+	for _, review := range reviews {
+		if !isFileFound[review.FileID] || !isTagFound[review.TagID] {
+			staleIDs = append(staleIDs, review.ID)
+			continue
+		}
+		result = append(result, review)
+	}
+	return result, staleIDs, nil
+}
@@ -0,0 +1,200 @@
+package tag
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	tag_suggestionv2 "github.com/michael-freling/anime-image-viewer/plugins/plugins-protos/gen/go/tag_suggestion/v2"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	ErrUnexpectedSuggestion = errors.New("unexpected suggestion")
+	// ErrUnreadableImage is returned when an image can't be read to be sent
+	// to the plugin, e.g. it was deleted outside the app
+	ErrUnreadableImage = errors.New("unreadable image")
+)
+
+// NewSuggestionServiceV2 creates a service for a plugin of
+// tag_suggestion.v2, which receives the bytes of images instead of their
+// paths, so that it can run on another machine.
+// Images wider than thumbnailWidth are resized before they are sent, and
+// 0 sends the original files.
+func NewSuggestionServiceV2(
+	dbClient *db.Client,
+	tagSuggestionClient tag_suggestionv2.TagSuggestionServiceClient,
+	resizer *image.Resizer,
+	thumbnailWidth uint,
+	reader *Reader,
+	imageReader *image.Reader,
+) *SuggestionService {
+	return &SuggestionService{
+		dbClient: dbClient,
+		suggester: suggesterV2{
+			client:         tagSuggestionClient,
+			resizer:        resizer,
+			thumbnailWidth: thumbnailWidth,
+		},
+
+		reader: reader,
+
+		imageReader: imageReader,
+	}
+}
+
+type suggesterV2 struct {
+	client         tag_suggestionv2.TagSuggestionServiceClient
+	resizer        *image.Resizer
+	thumbnailWidth uint
+}
+
+func (suggester suggesterV2) suggest(ctx context.Context, imageFiles []image.ImageFile, animeIDs map[uint]uint) (tagSuggestions, error) {
+	modelResponse, err := suggester.client.GetModel(ctx, &tag_suggestionv2.GetModelRequest{})
+	if err != nil {
+		return tagSuggestions{}, fmt.Errorf("suggestServiceClient.GetModel: %w", err)
+	}
+	vocabulary := make(map[uint]string, len(modelResponse.Tags))
+	for _, tag := range modelResponse.Tags {
+		vocabulary[uint(tag.Id)] = tag.Name
+	}
+	characterVocabulary := make(map[uint]string, len(modelResponse.Characters))
+	for _, character := range modelResponse.Characters {
+		characterVocabulary[uint(character.Id)] = character.Name
+	}
+
+	// the stream is canceled when either sending or receiving fails
+	eg, childCtx := errgroup.WithContext(ctx)
+	stream, err := suggester.client.Suggest(childCtx)
+	if err != nil {
+		return tagSuggestions{}, fmt.Errorf("suggestServiceClient.Suggest: %w", err)
+	}
+
+	indexes := make(map[uint]int, len(imageFiles))
+	for index, imageFile := range imageFiles {
+		indexes[imageFile.ID] = index
+	}
+
+	eg.Go(func() error {
+		for _, imageFile := range imageFiles {
+			content, err := suggester.readImage(childCtx, imageFile)
+			if err != nil {
+				return fmt.Errorf("%w: readImage: %w", ErrUnreadableImage, err)
+			}
+			if err := stream.Send(&tag_suggestionv2.SuggestRequest{
+				Image: &tag_suggestionv2.Image{
+					Id:          uint64(imageFile.ID),
+					Content:     content,
+					ContentType: imageFile.ContentType,
+					AnimeId:     uint64(animeIDs[imageFile.ID]),
+				},
+			}); err != nil {
+				if errors.Is(err, io.EOF) {
+					// the server closed the stream, and its error is
+					// returned by Recv
+					return nil
+				}
+				return fmt.Errorf("stream.Send: %w", err)
+			}
+		}
+		if err := stream.CloseSend(); err != nil {
+			return fmt.Errorf("stream.CloseSend: %w", err)
+		}
+		return nil
+	})
+
+	suggestions := make([]tagSuggestion, len(imageFiles))
+	isReceived := make([]bool, len(imageFiles))
+	eg.Go(func() error {
+		for {
+			response, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("stream.Recv: %w", err)
+			}
+			index, ok := indexes[uint(response.ImageId)]
+			if !ok {
+				return fmt.Errorf("%w for an image %d which wasn't sent", ErrUnexpectedSuggestion, response.ImageId)
+			}
+
+			scores := make([]tagScore, len(response.Scores))
+			for scoreIndex, score := range response.Scores {
+				scores[scoreIndex] = tagScore{
+					tagID: uint(score.TagId),
+					score: score.Score,
+				}
+			}
+			characterScores := make([]characterScore, len(response.CharacterScores))
+			for scoreIndex, score := range response.CharacterScores {
+				characterScores[scoreIndex] = characterScore{
+					characterID: uint(score.CharacterId),
+					score:       score.Score,
+				}
+			}
+			suggestions[index] = tagSuggestion{
+				scores:          scores,
+				characterScores: characterScores,
+			}
+			isReceived[index] = true
+		}
+	})
+	if err := eg.Wait(); err != nil {
+		return tagSuggestions{}, err
+	}
+	for index, imageFile := range imageFiles {
+		if !isReceived[index] {
+			return tagSuggestions{}, fmt.Errorf("%w: no suggestion for an image %d", ErrUnexpectedSuggestion, imageFile.ID)
+		}
+	}
+
+	result := tagSuggestions{
+		suggestions:         suggestions,
+		vocabulary:          vocabulary,
+		characterVocabulary: characterVocabulary,
+	}
+	if modelResponse.Model != nil {
+		result.model = SuggestionModel{
+			Name:    modelResponse.Model.Name,
+			Version: modelResponse.Model.Version,
+		}
+	}
+	return result, nil
+}
+
+// readImage returns the content of an image file, or its thumbnail if the
+// image is wider than the thumbnail width. An image in a format the resizer
+// cannot read or write is sent as it is, so that its content type still
+// matches.
+func (suggester suggesterV2) readImage(ctx context.Context, imageFile image.ImageFile) ([]byte, error) {
+	if suggester.thumbnailWidth == 0 || (imageFile.Width != 0 && imageFile.Width <= suggester.thumbnailWidth) {
+		return readImageFile(imageFile)
+	}
+
+	encoder, err := suggester.resizer.ResizeImage(ctx, imageFile.LocalFilePath, int(suggester.thumbnailWidth))
+	if errors.Is(err, image.ErrUnsupportedImageFormat) {
+		return readImageFile(imageFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("resizer.ResizeImage: %w", err)
+	}
+	var buffer bytes.Buffer
+	if err := encoder.Encode(&buffer); err != nil {
+		return nil, fmt.Errorf("encoder.Encode: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+func readImageFile(imageFile image.ImageFile) ([]byte, error) {
+	content, err := os.ReadFile(imageFile.LocalFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	return content, nil
+}
@@ -0,0 +1,373 @@
+package tag
+
+import (
+	"bytes"
+	"context"
+	goimage "image"
+	"image/color"
+	"image/gif"
+	_ "image/jpeg"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	tag_suggestionv2 "github.com/michael-freling/anime-image-viewer/plugins/plugins-protos/gen/go/tag_suggestion/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeSuggestStream replies the responses once all images were sent, or
+// fails once ctx is cancelled if it is set
+type fakeSuggestStream struct {
+	grpc.ClientStream
+
+	ctx       context.Context
+	requests  []*tag_suggestionv2.SuggestRequest
+	responses []*tag_suggestionv2.SuggestResponse
+	recvErr   error
+	closed    chan struct{}
+}
+
+func newFakeSuggestStream(responses []*tag_suggestionv2.SuggestResponse, recvErr error) *fakeSuggestStream {
+	return &fakeSuggestStream{
+		responses: responses,
+		recvErr:   recvErr,
+		closed:    make(chan struct{}),
+	}
+}
+
+func (stream *fakeSuggestStream) Send(request *tag_suggestionv2.SuggestRequest) error {
+	stream.requests = append(stream.requests, request)
+	return nil
+}
+
+func (stream *fakeSuggestStream) CloseSend() error {
+	close(stream.closed)
+	return nil
+}
+
+func (stream *fakeSuggestStream) Recv() (*tag_suggestionv2.SuggestResponse, error) {
+	if stream.ctx != nil {
+		select {
+		case <-stream.closed:
+		case <-stream.ctx.Done():
+			return nil, status.FromContextError(stream.ctx.Err()).Err()
+		}
+	}
+	<-stream.closed
+	if stream.recvErr != nil {
+		return nil, stream.recvErr
+	}
+	if len(stream.responses) == 0 {
+		return nil, io.EOF
+	}
+	response := stream.responses[0]
+	stream.responses = stream.responses[1:]
+	return response, nil
+}
+
+func TestSuggestionService_SuggestTags_v2(t *testing.T) {
+	tester := newTester(t)
+
+	tagBuilder := NewTestTagBuilder().
+		Add(Tag{ID: 1, Name: "tag1"}).
+		Add(Tag{ID: 2, Name: "tag2"}).
+		Add(Tag{ID: 10, Name: "tag 10"})
+
+	fileBuilder := tester.newFileCreator(t).
+		CreateDirectory(image.Directory{ID: 1, Name: "Directory 1"}).
+		CreateImage(image.ImageFile{ID: 11, Name: "image11.jpg", ParentID: 1, ContentType: "image/jpeg"}, image.TestImageFileJpeg).
+		CreateImage(image.ImageFile{ID: 12, Name: "image12.jpg", ParentID: 1, ContentType: "image/jpeg"}, image.TestImageFileJpeg)
+	jpegContent, err := os.ReadFile(fileBuilder.BuildImageFile(11).LocalFilePath)
+	require.NoError(t, err)
+
+	modelResponse := &tag_suggestionv2.GetModelResponse{
+		Model: &tag_suggestionv2.Model{Name: "tag-suggestion", Version: "2024-12-01"},
+		Tags: []*tag_suggestionv2.Tag{
+			{Id: 1, Name: "tag1"},
+			// the tag was renamed after the model was trained
+			{Id: 2, Name: "old tag2"},
+			{Id: 10, Name: "tag 10"},
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		thumbnailWidth uint
+		modelErr       error
+		responses      []*tag_suggestionv2.SuggestResponse
+		recvErr        error
+
+		wantImageWidth int
+		want           SuggestTagsResponse
+		wantErr        error
+	}{
+		{
+			name: "send image files and receive suggestions out of order",
+			responses: []*tag_suggestionv2.SuggestResponse{
+				{ImageId: 12, Scores: []*tag_suggestionv2.SuggestionScore{
+					{TagId: 1, Score: 0.7},
+				}},
+				{ImageId: 11, Scores: []*tag_suggestionv2.SuggestionScore{
+					{TagId: 10, Score: 0.9},
+					{TagId: 2, Score: 0.8},
+					{TagId: 1, Score: 0.5},
+				}},
+			},
+			want: SuggestTagsResponse{
+				Suggestions: map[uint][]TagSuggestion{
+					11: {
+						{TagID: 10, Score: 0.9, HasTag: true},
+						{TagID: 1, Score: 0.5},
+					},
+					12: {
+						{TagID: 1, Score: 0.7},
+					},
+				},
+				AllTags: map[uint]Tag{
+					1:  tagBuilder.Build(1),
+					2:  tagBuilder.Build(2),
+					10: tagBuilder.Build(10),
+				},
+				Model: SuggestionModel{Name: "tag-suggestion", Version: "2024-12-01"},
+			},
+		},
+		{
+			name:           "send thumbnails",
+			thumbnailWidth: 16,
+			responses: []*tag_suggestionv2.SuggestResponse{
+				{ImageId: 11},
+				{ImageId: 12},
+			},
+			wantImageWidth: 16,
+			want: SuggestTagsResponse{
+				Suggestions: map[uint][]TagSuggestion{
+					11: {},
+					12: {},
+				},
+				AllTags: map[uint]Tag{
+					1:  tagBuilder.Build(1),
+					2:  tagBuilder.Build(2),
+					10: tagBuilder.Build(10),
+				},
+				Model: SuggestionModel{Name: "tag-suggestion", Version: "2024-12-01"},
+			},
+		},
+		{
+			name: "a suggestion for an image is missing",
+			responses: []*tag_suggestionv2.SuggestResponse{
+				{ImageId: 11},
+			},
+			wantErr: ErrUnexpectedSuggestion,
+		},
+		{
+			name: "a suggestion for an image which wasn't sent",
+			responses: []*tag_suggestionv2.SuggestResponse{
+				{ImageId: 11},
+				{ImageId: 13},
+			},
+			wantErr: ErrUnexpectedSuggestion,
+		},
+		{
+			name:     "the model cannot be read",
+			modelErr: status.New(codes.Unavailable, assert.AnError.Error()).Err(),
+			wantErr:  status.New(codes.Unavailable, assert.AnError.Error()).Err(),
+		},
+		{
+			name:    "the stream fails",
+			recvErr: status.New(codes.Internal, assert.AnError.Error()).Err(),
+			wantErr: status.New(codes.Internal, assert.AnError.Error()).Err(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tester.dbClient.Truncate(&db.Tag{}, &db.FileTag{}, &db.File{})
+			require.NoError(t, db.BatchCreate(tester.dbClient, []db.File{
+				{ID: 1, Name: "Directory 1", Type: db.FileTypeDirectory},
+				{ID: 11, Name: "image11.jpg", Type: db.FileTypeImage, ParentID: 1},
+				{ID: 12, Name: "image12.jpg", Type: db.FileTypeImage, ParentID: 1},
+			}))
+			require.NoError(t, db.BatchCreate(tester.dbClient, []db.Tag{
+				{ID: 1, Name: "tag1"},
+				{ID: 2, Name: "tag2"},
+				{ID: 10, Name: "tag 10"},
+			}))
+			require.NoError(t, db.BatchCreate(tester.dbClient, []db.FileTag{
+				{FileID: 11, TagID: 10},
+			}))
+
+			stream := newFakeSuggestStream(tc.responses, tc.recvErr)
+			service := tester.getTagSuggestionServiceV2(t, tc.thumbnailWidth, func(mock *tag_suggestionv2.MockTagSuggestionServiceClient) {
+				if tc.modelErr != nil {
+					mock.EXPECT().
+						GetModel(gomock.Any(), gomock.Any()).
+						Return(nil, tc.modelErr)
+					return
+				}
+				mock.EXPECT().
+					GetModel(gomock.Any(), gomock.Any()).
+					Return(modelResponse, nil)
+				mock.EXPECT().
+					Suggest(gomock.Any()).
+					Return(stream, nil)
+			})
+
+			got, gotErr := service.SuggestTags(context.Background(), []uint{11, 12})
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, gotErr, tc.wantErr)
+				return
+			}
+			require.NoError(t, gotErr)
+			assert.Equal(t, tc.want, got)
+
+			require.Len(t, stream.requests, 2)
+			for index, imageFileID := range []uint{11, 12} {
+				gotImage := stream.requests[index].Image
+				assert.Equal(t, uint64(imageFileID), gotImage.Id)
+				assert.Equal(t, "image/jpeg", gotImage.ContentType)
+				if tc.wantImageWidth == 0 {
+					assert.Equal(t, jpegContent, gotImage.Content)
+					continue
+				}
+				config, _, err := goimage.DecodeConfig(bytes.NewReader(gotImage.Content))
+				require.NoError(t, err)
+				assert.Equal(t, tc.wantImageWidth, config.Width)
+			}
+		})
+	}
+}
+
+func TestSuggesterV2_readImage(t *testing.T) {
+	tester := newTester(t)
+	fileBuilder := tester.newFileCreator(t).
+		CreateDirectory(image.Directory{ID: 1, Name: "Directory 1"}).
+		CreateImage(image.ImageFile{ID: 11, Name: "image11.jpg", ParentID: 1, ContentType: "image/jpeg"}, image.TestImageFileJpeg)
+	jpegImageFile := fileBuilder.BuildImageFile(11)
+
+	// GIF has a decoder but no encoder in the resizer
+	gifFilePath := filepath.Join(t.TempDir(), "image.gif")
+	gifImage := goimage.NewPaletted(goimage.Rect(0, 0, 32, 32), color.Palette{color.Black, color.White})
+	var gifBuffer bytes.Buffer
+	require.NoError(t, gif.Encode(&gifBuffer, gifImage, nil))
+	require.NoError(t, os.WriteFile(gifFilePath, gifBuffer.Bytes(), 0644))
+
+	suggester := suggesterV2{
+		resizer:        image.NewResizer(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		thumbnailWidth: 16,
+	}
+
+	t.Run("a thumbnail of a jpeg image", func(t *testing.T) {
+		got, err := suggester.readImage(context.Background(), jpegImageFile)
+		require.NoError(t, err)
+		config, format, err := goimage.DecodeConfig(bytes.NewReader(got))
+		require.NoError(t, err)
+		assert.Equal(t, "jpeg", format)
+		assert.Equal(t, 16, config.Width)
+	})
+
+	t.Run("an image without an encoder is sent as it is", func(t *testing.T) {
+		got, err := suggester.readImage(context.Background(), image.ImageFile{
+			ID:            12,
+			LocalFilePath: gifFilePath,
+			ContentType:   "image/gif",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, gifBuffer.Bytes(), got)
+	})
+}
+
+func TestSuggestionService_SuggestTags_v2_characters(t *testing.T) {
+	tester := newTester(t)
+	tester.newFileCreator(t).
+		CreateDirectory(image.Directory{ID: 1, Name: "Anime 1"}).
+		CreateDirectory(image.Directory{ID: 2, Name: "Season 1", ParentID: 1}).
+		CreateImage(image.ImageFile{ID: 21, Name: "image21.jpg", ParentID: 2, ContentType: "image/jpeg"}, image.TestImageFileJpeg).
+		CreateImage(image.ImageFile{ID: 22, Name: "image22.jpg", ParentID: 2, ContentType: "image/jpeg"}, image.TestImageFileJpeg).
+		CreateDirectory(image.Directory{ID: 3, Name: "Unassigned"}).
+		CreateImage(image.ImageFile{ID: 31, Name: "image31.jpg", ParentID: 3, ContentType: "image/jpeg"}, image.TestImageFileJpeg)
+
+	animeID := uint(100)
+	tester.dbClient.Truncate(&db.Tag{}, &db.FileTag{}, &db.File{}, &db.Character{}, &db.AnimeCharacter{}, &db.FileCharacter{})
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.File{
+		{ID: 1, Name: "Anime 1", Type: db.FileTypeDirectory, AnimeID: &animeID},
+		{ID: 2, Name: "Season 1", Type: db.FileTypeDirectory, ParentID: 1},
+		{ID: 21, Name: "image21.jpg", Type: db.FileTypeImage, ParentID: 2},
+		{ID: 22, Name: "image22.jpg", Type: db.FileTypeImage, ParentID: 2},
+		{ID: 3, Name: "Unassigned", Type: db.FileTypeDirectory},
+		{ID: 31, Name: "image31.jpg", Type: db.FileTypeImage, ParentID: 3},
+	}))
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.Character{
+		{ID: 1, Name: "Character 1", AnimeID: animeID},
+		{ID: 2, Name: "Character 2", AnimeID: animeID},
+		{ID: 3, Name: "Shared character", AnimeID: 200},
+		{ID: 4, Name: "Another anime's character", AnimeID: 200},
+		{ID: 5, Name: "Renamed character", AnimeID: animeID},
+	}))
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.AnimeCharacter{
+		{AnimeID: animeID, CharacterID: 3},
+	}))
+	require.NoError(t, db.BatchCreate(tester.dbClient, []db.FileCharacter{
+		{FileID: 21, CharacterID: 2, AddedBy: db.FileTagAddedByUser},
+	}))
+
+	stream := newFakeSuggestStream([]*tag_suggestionv2.SuggestResponse{
+		{ImageId: 21, CharacterScores: []*tag_suggestionv2.CharacterScore{
+			{CharacterId: 1, Score: 0.9},
+			{CharacterId: 2, Score: 0.8},
+			{CharacterId: 3, Score: 0.7},
+			{CharacterId: 4, Score: 0.6},
+			{CharacterId: 5, Score: 0.5},
+		}},
+		{ImageId: 22},
+		{ImageId: 31, CharacterScores: []*tag_suggestionv2.CharacterScore{
+			{CharacterId: 1, Score: 0.9},
+		}},
+	}, nil)
+	service := tester.getTagSuggestionServiceV2(t, 0, func(mock *tag_suggestionv2.MockTagSuggestionServiceClient) {
+		mock.EXPECT().
+			GetModel(gomock.Any(), gomock.Any()).
+			Return(&tag_suggestionv2.GetModelResponse{
+				Characters: []*tag_suggestionv2.Character{
+					{Id: 1, Name: "Character 1"},
+					{Id: 2, Name: "Character 2"},
+					{Id: 3, Name: "Shared character"},
+					{Id: 4, Name: "Another anime's character"},
+					{Id: 5, Name: "Old name"},
+				},
+			}, nil)
+		mock.EXPECT().
+			Suggest(gomock.Any()).
+			Return(stream, nil)
+	})
+
+	got, gotErr := service.SuggestTags(context.Background(), []uint{21, 22, 31})
+	require.NoError(t, gotErr)
+	assert.Equal(t, map[uint][]CharacterSuggestion{
+		21: {
+			{CharacterID: 1, Score: 0.9},
+			{CharacterID: 2, Score: 0.8, HasCharacter: true},
+			{CharacterID: 3, Score: 0.7},
+		},
+		// an image without an anime has no characters to suggest
+		31: {},
+	}, got.CharacterSuggestions)
+	assert.Equal(t, map[uint]Character{
+		1: {ID: 1, Name: "Character 1", AnimeID: animeID},
+		2: {ID: 2, Name: "Character 2", AnimeID: animeID},
+		3: {ID: 3, Name: "Shared character", AnimeID: 200},
+	}, got.AllCharacters)
+
+	require.Len(t, stream.requests, 3)
+	assert.Equal(t, uint64(animeID), stream.requests[0].Image.AnimeId)
+	assert.Equal(t, uint64(animeID), stream.requests[1].Image.AnimeId)
+	assert.Zero(t, stream.requests[2].Image.AnimeId)
+}
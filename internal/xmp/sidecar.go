@@ -0,0 +1,178 @@
+package xmp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Keyword is a tag or a character in a sidecar, as a path from the root of
+// the tag hierarchy, e.g. ["Characters", "Frieren"].
+type Keyword []string
+
+var namespaces = []struct {
+	prefix string
+	uri    string
+}{
+	{prefix: "digiKam", uri: "http://www.digikam.org/ns/1.0/"},
+	{prefix: "lr", uri: "http://ns.adobe.com/lightroom/1.0/"},
+	{prefix: "dc", uri: "http://purl.org/dc/elements/1.1/"},
+}
+
+var (
+	// keywordProperties are the properties a sidecar is written with, and
+	// the other copies of the keywords DigiKam writes, which are removed so
+	// that they cannot disagree with the written ones.
+	keywordProperties = []*regexp.Regexp{
+		keywordPropertyRegexp("digiKam:TagsList"),
+		keywordPropertyRegexp("dc:subject"),
+		keywordPropertyRegexp("lr:hierarchicalSubject"),
+		keywordPropertyRegexp("MicrosoftPhoto:LastKeywordXMP"),
+		keywordPropertyRegexp("mediapro:CatalogSets"),
+	}
+	keywordAttribute = regexp.MustCompile(`\s+acdsee:categories="[^"]*"`)
+
+	descriptionStart = regexp.MustCompile(`<rdf:Description\b[^>]*?(/?)>`)
+)
+
+const descriptionEnd = "</rdf:Description>"
+
+func keywordPropertyRegexp(name string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(name)
+	return regexp.MustCompile(`(?s)\s*<` + quoted + `\b[^>]*?(?:/>|>.*?</` + quoted + `>)`)
+}
+
+// updateSidecar returns a sidecar with the keywords. The other properties of
+// an existing sidecar are kept as they are, so that what DigiKam or other
+// tools wrote into it is not lost.
+func updateSidecar(existing []byte, keywords []Keyword) ([]byte, error) {
+	if existing == nil {
+		return newSidecar(keywords), nil
+	}
+	if err := validateXML(existing); err != nil {
+		return nil, fmt.Errorf("validateXML: %w", err)
+	}
+
+	content := string(existing)
+	for _, property := range keywordProperties {
+		content = property.ReplaceAllString(content, "")
+	}
+	content = keywordAttribute.ReplaceAllString(content, "")
+
+	location := descriptionStart.FindStringSubmatchIndex(content)
+	if location == nil {
+		return nil, errors.New("rdf:Description not found")
+	}
+	startTag := content[location[0]:location[1]]
+	isSelfClosing := location[3] > location[2]
+	if isSelfClosing {
+		startTag = strings.TrimSuffix(startTag, "/>")
+	} else {
+		startTag = strings.TrimSuffix(startTag, ">")
+	}
+	for _, namespace := range namespaces {
+		if !strings.Contains(content, "xmlns:"+namespace.prefix+"=") {
+			startTag += fmt.Sprintf("\n    xmlns:%s=%q", namespace.prefix, namespace.uri)
+		}
+	}
+	startTag += ">"
+
+	var result strings.Builder
+	result.WriteString(content[:location[0]])
+	result.WriteString(startTag)
+	rest := content[location[1]:]
+	if isSelfClosing {
+		writeProperties(&result, keywords)
+		result.WriteString("\n  " + descriptionEnd)
+	} else {
+		end := strings.Index(rest, descriptionEnd)
+		if end < 0 {
+			return nil, errors.New("rdf:Description is not closed")
+		}
+		result.WriteString(strings.TrimRight(rest[:end], " \t\r\n"))
+		writeProperties(&result, keywords)
+		result.WriteString("\n  ")
+		rest = rest[end:]
+	}
+	result.WriteString(rest)
+	return []byte(result.String()), nil
+}
+
+func newSidecar(keywords []Keyword) []byte {
+	var result strings.Builder
+	result.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	result.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\" x:xmptk=\"anime-image-viewer\">\n")
+	result.WriteString(" <rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	result.WriteString("  <rdf:Description rdf:about=\"\"")
+	for _, namespace := range namespaces {
+		result.WriteString(fmt.Sprintf("\n    xmlns:%s=%q", namespace.prefix, namespace.uri))
+	}
+	result.WriteString(">")
+	writeProperties(&result, keywords)
+	result.WriteString("\n  " + descriptionEnd + "\n")
+	result.WriteString(" </rdf:RDF>\n")
+	result.WriteString("</x:xmpmeta>\n")
+	result.WriteString("<?xpacket end=\"w\"?>")
+	return []byte(result.String())
+}
+
+// writeProperties writes the keywords as DigiKam does: the paths into
+// digiKam:TagsList and lr:hierarchicalSubject, and the names into
+// dc:subject for tools without a tag hierarchy.
+func writeProperties(result *strings.Builder, keywords []Keyword) {
+	if len(keywords) == 0 {
+		return
+	}
+	tagsList := make([]string, 0, len(keywords))
+	hierarchicalSubjects := make([]string, 0, len(keywords))
+	subjects := make([]string, 0, len(keywords))
+	isSubjectAdded := make(map[string]bool)
+	for _, keyword := range keywords {
+		tagsList = append(tagsList, strings.Join(keyword, "/"))
+		hierarchicalSubjects = append(hierarchicalSubjects, strings.Join(keyword, "|"))
+		name := keyword[len(keyword)-1]
+		if isSubjectAdded[name] {
+			continue
+		}
+		isSubjectAdded[name] = true
+		subjects = append(subjects, name)
+	}
+	sort.Strings(tagsList)
+	sort.Strings(hierarchicalSubjects)
+	sort.Strings(subjects)
+
+	writeProperty(result, "digiKam:TagsList", "rdf:Seq", tagsList)
+	writeProperty(result, "lr:hierarchicalSubject", "rdf:Bag", hierarchicalSubjects)
+	writeProperty(result, "dc:subject", "rdf:Bag", subjects)
+}
+
+func writeProperty(result *strings.Builder, name string, container string, values []string) {
+	result.WriteString("\n   <" + name + ">")
+	result.WriteString("\n    <" + container + ">")
+	for _, value := range values {
+		var escaped bytes.Buffer
+		// xml.EscapeText only fails when the writer does
+		_ = xml.EscapeText(&escaped, []byte(value))
+		result.WriteString("\n     <rdf:li>" + escaped.String() + "</rdf:li>")
+	}
+	result.WriteString("\n    </" + container + ">")
+	result.WriteString("\n   </" + name + ">")
+}
+
+func validateXML(content []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		_, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package xmp
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSidecar is what tools read from a sidecar.
+type testSidecar struct {
+	Description struct {
+		Rating               string   `xml:"Rating,attr"`
+		Categories           string   `xml:"categories,attr"`
+		TagsList             []string `xml:"TagsList>Seq>li"`
+		HierarchicalSubjects []string `xml:"hierarchicalSubject>Bag>li"`
+		Subjects             []string `xml:"subject>Bag>li"`
+		LastKeywords         []string `xml:"LastKeywordXMP>Bag>li"`
+	} `xml:"RDF>Description"`
+}
+
+func TestUpdateSidecar(t *testing.T) {
+	digiKamSidecar, err := os.ReadFile(filepath.Join("..", "..", "testdata", "image.png.xmp"))
+	require.NoError(t, err)
+	keywords := []Keyword{
+		{"scene", "night"},
+		{CharactersKeyword, "Frieren"},
+		{"smile"},
+		{"expression", "smile"},
+	}
+
+	testCases := []struct {
+		name       string
+		existing   []byte
+		keywords   []Keyword
+		wantRating string
+		wantError  bool
+	}{
+		{
+			name:     "a new sidecar",
+			keywords: keywords,
+		},
+		{
+			name:     "a sidecar written by DigiKam",
+			existing: digiKamSidecar,
+			keywords: keywords,
+		},
+		{
+			name:       "a sidecar without keywords keeps other properties",
+			existing:   []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmp:Rating="3"/></rdf:RDF></x:xmpmeta>`),
+			keywords:   keywords,
+			wantRating: "3",
+		},
+		{
+			name:     "keywords are removed",
+			existing: digiKamSidecar,
+		},
+		{
+			name:      "an invalid sidecar is not overwritten",
+			existing:  []byte(`{"tags": ["smile"]}`),
+			keywords:  keywords,
+			wantError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, gotErr := updateSidecar(tc.existing, tc.keywords)
+			if tc.wantError {
+				require.Error(t, gotErr)
+				return
+			}
+			require.NoError(t, gotErr)
+
+			var sidecar testSidecar
+			require.NoError(t, xml.Unmarshal(got, &sidecar))
+			if len(tc.keywords) > 0 {
+				assert.Equal(t, []string{"Characters/Frieren", "expression/smile", "scene/night", "smile"}, sidecar.Description.TagsList)
+				assert.Equal(t, []string{"Characters|Frieren", "expression|smile", "scene|night", "smile"}, sidecar.Description.HierarchicalSubjects)
+				assert.Equal(t, []string{"Frieren", "night", "smile"}, sidecar.Description.Subjects)
+			} else {
+				assert.Empty(t, sidecar.Description.TagsList)
+				assert.Empty(t, sidecar.Description.HierarchicalSubjects)
+				assert.Empty(t, sidecar.Description.Subjects)
+			}
+			assert.Empty(t, sidecar.Description.LastKeywords, "stale copies of keywords are removed")
+			assert.Empty(t, sidecar.Description.Categories, "stale copies of keywords are removed")
+			assert.Equal(t, tc.wantRating, sidecar.Description.Rating)
+
+			updatedAgain, err := updateSidecar(got, tc.keywords)
+			require.NoError(t, err)
+			assert.Equal(t, string(got), string(updatedAgain), "an update is idempotent")
+		})
+	}
+}
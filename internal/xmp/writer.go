@@ -0,0 +1,290 @@
+package xmp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/event"
+	"github.com/michael-freling/anime-image-viewer/internal/history"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+)
+
+const (
+	// SidecarExtension is appended to the name of an image for its sidecar,
+	// e.g. image.jpg.xmp, as DigiKam does.
+	SidecarExtension = ".xmp"
+
+	// CharactersKeyword is the root of the keywords of characters.
+	CharactersKeyword = "Characters"
+
+	syncBatchSize = 500
+)
+
+// Writer writes the tags and characters of images into their XMP sidecars,
+// so that DigiKam and other tools see the edits made in this app.
+type Writer struct {
+	logger      *slog.Logger
+	dbClient    *db.Client
+	imageReader *image.Reader
+}
+
+func NewWriter(logger *slog.Logger, conf config.Config, dbClient *db.Client) *Writer {
+	directoryReader := image.NewDirectoryReader(conf, dbClient)
+	return &Writer{
+		logger:      logger,
+		dbClient:    dbClient,
+		imageReader: image.NewReader(dbClient, directoryReader, image.NewImageFileConverter(conf)),
+	}
+}
+
+// SyncResult counts the sidecars a sync looked at.
+type SyncResult struct {
+	Written   int
+	Unchanged int
+}
+
+// SyncAll writes the sidecars of every image in the library.
+func (writer *Writer) SyncAll(ctx context.Context) (SyncResult, error) {
+	imageFiles, err := writer.dbClient.File().FindAllImageFiles()
+	if err != nil {
+		return SyncResult{}, fmt.Errorf("File.FindAllImageFiles: %w", err)
+	}
+	imageFileIDs := make([]uint, len(imageFiles))
+	for i, imageFile := range imageFiles {
+		imageFileIDs[i] = imageFile.ID
+	}
+	return writer.Sync(ctx, imageFileIDs)
+}
+
+// Sync writes the sidecars of the images. A sidecar is created only for an
+// image with any tag or character, and one that has not changed is not
+// written again, so that DigiKam doesn't reload it.
+func (writer *Writer) Sync(ctx context.Context, imageFileIDs []uint) (SyncResult, error) {
+	var result SyncResult
+	for start := 0; start < len(imageFileIDs); start += syncBatchSize {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		end := min(start+syncBatchSize, len(imageFileIDs))
+		if err := writer.syncBatch(imageFileIDs[start:end], &result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// SyncChange writes the sidecars of the images whose tags or characters were
+// changed. It is a listener of history.Journal, so an error is only logged.
+func (writer *Writer) SyncChange(ctx context.Context, change history.Change) {
+	isAdded := make(map[uint]bool)
+	imageFileIDs := make([]uint, 0)
+	add := func(fileID uint) {
+		if isAdded[fileID] {
+			return
+		}
+		isAdded[fileID] = true
+		imageFileIDs = append(imageFileIDs, fileID)
+	}
+	for _, fileTags := range [][]db.FileTag{change.AddedFileTags, change.DeletedFileTags} {
+		for _, fileTag := range fileTags {
+			add(fileTag.FileID)
+		}
+	}
+	for _, fileCharacters := range [][]db.FileCharacter{change.AddedFileCharacters, change.DeletedFileCharacters} {
+		for _, fileCharacter := range fileCharacters {
+			add(fileCharacter.FileID)
+		}
+	}
+	if len(imageFileIDs) == 0 {
+		return
+	}
+
+	result, err := writer.Sync(ctx, imageFileIDs)
+	if err != nil {
+		writer.logger.ErrorContext(ctx, "failed to sync XMP sidecars",
+			"imageFileIDs", imageFileIDs,
+			"error", err,
+		)
+		return
+	}
+	writer.logger.DebugContext(ctx, "synced XMP sidecars",
+		"written", result.Written,
+		"unchanged", result.Unchanged,
+	)
+}
+
+// Subscribe writes the sidecars of imported images, and of images in the
+// library which got the tags of imported ones, and deletes the sidecars of
+// deleted images. Tag edits are synced by SyncChange instead.
+func (writer *Writer) Subscribe(bus *event.Bus) {
+	bus.Subscribe("XMP sidecars", []event.Type{event.TypeImagesImported, event.TypeImagesDeleted}, writer.handleEvent)
+}
+
+func (writer *Writer) handleEvent(ctx context.Context, e event.Event) error {
+	switch data := e.Data.(type) {
+	case event.ImagesImported:
+		imageFileIDs := make([]uint, 0, len(data.Images)+len(data.LinkedImageIDs))
+		for _, imported := range data.Images {
+			imageFileIDs = append(imageFileIDs, imported.ID)
+		}
+		imageFileIDs = append(imageFileIDs, data.LinkedImageIDs...)
+		if _, err := writer.Sync(ctx, imageFileIDs); err != nil {
+			return fmt.Errorf("Sync: %w", err)
+		}
+	case event.ImagesDeleted:
+		errs := make([]error, 0)
+		for _, deleted := range data.Images {
+			if deleted.Path == "" {
+				continue
+			}
+			if err := os.Remove(deleted.Path + SidecarExtension); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, fmt.Errorf("os.Remove: %w", err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+func (writer *Writer) syncBatch(imageFileIDs []uint, result *SyncResult) error {
+	imageFiles, err := writer.imageReader.ReadImagesByIDs(imageFileIDs)
+	if err != nil {
+		return fmt.Errorf("imageReader.ReadImagesByIDs: %w", err)
+	}
+	keywords, err := writer.readKeywords(imageFileIDs)
+	if err != nil {
+		return fmt.Errorf("readKeywords: %w", err)
+	}
+
+	for _, imageFile := range imageFiles {
+		isWritten, err := writeSidecar(imageFile.LocalFilePath+SidecarExtension, keywords[imageFile.ID])
+		if err != nil {
+			return fmt.Errorf("writeSidecar: %w for %s", err, imageFile.LocalFilePath)
+		}
+		if isWritten {
+			result.Written++
+		} else {
+			result.Unchanged++
+		}
+	}
+	return nil
+}
+
+// readKeywords returns the keywords of each image by image file ID. A tag
+// with a category is written under it, e.g. scene/night.
+func (writer *Writer) readKeywords(imageFileIDs []uint) (map[uint][]Keyword, error) {
+	fileTags, err := writer.dbClient.FileTag().FindAllByFileID(imageFileIDs)
+	if err != nil {
+		return nil, fmt.Errorf("FileTag.FindAllByFileID: %w", err)
+	}
+	fileCharacters, err := writer.dbClient.FileCharacter().FindByFileIDs(imageFileIDs)
+	if err != nil {
+		return nil, fmt.Errorf("FileCharacter.FindByFileIDs: %w", err)
+	}
+
+	tags, err := writer.dbClient.Tag().FindAllByTagIDs(uniqueIDs(fileTags, func(fileTag db.FileTag) uint {
+		return fileTag.TagID
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("Tag.FindAllByTagIDs: %w", err)
+	}
+	tagKeywords := make(map[uint]Keyword, len(tags))
+	for _, t := range tags {
+		if t.Category == "" {
+			tagKeywords[t.ID] = Keyword{t.Name}
+			continue
+		}
+		tagKeywords[t.ID] = Keyword{t.Category, t.Name}
+	}
+
+	characters, err := writer.dbClient.Character().FindByIDs(uniqueIDs(fileCharacters, func(fileCharacter db.FileCharacter) uint {
+		return fileCharacter.CharacterID
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("Character.FindByIDs: %w", err)
+	}
+	characterKeywords := make(map[uint]Keyword, len(characters))
+	for _, character := range characters {
+		characterKeywords[character.ID] = Keyword{CharactersKeyword, character.Name}
+	}
+
+	result := make(map[uint][]Keyword)
+	for _, fileTag := range fileTags {
+		if keyword, ok := tagKeywords[fileTag.TagID]; ok {
+			result[fileTag.FileID] = append(result[fileTag.FileID], keyword)
+		}
+	}
+	for _, fileCharacter := range fileCharacters {
+		if keyword, ok := characterKeywords[fileCharacter.CharacterID]; ok {
+			result[fileCharacter.FileID] = append(result[fileCharacter.FileID], keyword)
+		}
+	}
+	return result, nil
+}
+
+func uniqueIDs[T any](values []T, getID func(T) uint) []uint {
+	isAdded := make(map[uint]bool)
+	result := make([]uint, 0)
+	for _, value := range values {
+		id := getID(value)
+		if isAdded[id] {
+			continue
+		}
+		isAdded[id] = true
+		result = append(result, id)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+	return result
+}
+
+// writeSidecar creates or updates a sidecar, and reports whether it was
+// written. The sidecar is replaced by a rename, so that DigiKam never reads
+// a half-written one.
+func writeSidecar(filePath string, keywords []Keyword) (bool, error) {
+	existing, err := os.ReadFile(filePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	if existing == nil && len(keywords) == 0 {
+		return false, nil
+	}
+
+	content, err := updateSidecar(existing, keywords)
+	if err != nil {
+		return false, fmt.Errorf("updateSidecar: %w", err)
+	}
+	if bytes.Equal(existing, content) {
+		return false, nil
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return false, fmt.Errorf("os.CreateTemp: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+	if _, err := tempFile.Write(content); err != nil {
+		tempFile.Close()
+		return false, fmt.Errorf("tempFile.Write: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return false, fmt.Errorf("tempFile.Close: %w", err)
+	}
+	if err := os.Chmod(tempFile.Name(), 0644); err != nil {
+		return false, fmt.Errorf("os.Chmod: %w", err)
+	}
+	if err := os.Rename(tempFile.Name(), filePath); err != nil {
+		return false, fmt.Errorf("os.Rename: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,115 @@
+package xmp
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/michael-freling/anime-image-viewer/internal/config"
+	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/event"
+	"github.com/michael-freling/anime-image-viewer/internal/history"
+	"github.com/michael-freling/anime-image-viewer/internal/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter_Sync(t *testing.T) {
+	ctx := context.Background()
+	dbClient := db.NewTestClient(t)
+	imageRootDirectory := t.TempDir()
+	fileCreator := image.NewFileCreator(t, imageRootDirectory)
+	fileCreator.CreateDirectory(image.Directory{ID: 1, Name: "Frieren"})
+	fileCreator.CreateImage(image.ImageFile{ID: 11, Name: "a.jpg", ParentID: 1}, image.TestImageFileJpeg)
+	fileCreator.CreateImage(image.ImageFile{ID: 12, Name: "b.png", ParentID: 1}, image.TestImageFilePng)
+	fileCreator.CreateImage(image.ImageFile{ID: 13, Name: "c.jpg", ParentID: 1}, image.TestImageFileJpeg)
+
+	dbClient.Truncate(t, &db.File{}, &db.Tag{}, &db.FileTag{}, &db.Character{}, &db.FileCharacter{})
+	db.LoadTestData(t, dbClient, []db.File{
+		fileCreator.BuildDBDirectory(1),
+		fileCreator.BuildDBImageFile(11),
+		fileCreator.BuildDBImageFile(12),
+		fileCreator.BuildDBImageFile(13),
+	})
+	db.LoadTestData(t, dbClient, []db.Tag{
+		{ID: 1, Name: "smile", Category: "expression"},
+		{ID: 2, Name: "night"},
+	})
+	db.LoadTestData(t, dbClient, []db.FileTag{
+		{FileID: 11, TagID: 1},
+		{FileID: 11, TagID: 2},
+		{FileID: 12, TagID: 2},
+	})
+	db.LoadTestData(t, dbClient, []db.Character{{ID: 1, Name: "Fern", AnimeID: 1}})
+	db.LoadTestData(t, dbClient, []db.FileCharacter{{FileID: 11, CharacterID: 1}})
+
+	writer := NewWriter(slog.New(slog.NewTextHandler(io.Discard, nil)), config.Config{
+		ImageRootDirectory: imageRootDirectory,
+	}, dbClient.Client)
+	readTagsList := func(imageFileID uint) []string {
+		content, err := os.ReadFile(fileCreator.BuildImageFile(imageFileID).LocalFilePath + SidecarExtension)
+		require.NoError(t, err)
+		var sidecar testSidecar
+		require.NoError(t, xml.Unmarshal(content, &sidecar))
+		return sidecar.Description.TagsList
+	}
+
+	got, err := writer.SyncAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, SyncResult{Written: 2, Unchanged: 1}, got)
+	assert.Equal(t, []string{"Characters/Fern", "expression/smile", "night"}, readTagsList(11))
+	assert.Equal(t, []string{"night"}, readTagsList(12))
+	assert.NoFileExists(t, fileCreator.BuildImageFile(13).LocalFilePath+SidecarExtension, "an image without any tag gets no sidecar")
+
+	got, err = writer.Sync(ctx, []uint{11, 12})
+	require.NoError(t, err)
+	assert.Equal(t, SyncResult{Unchanged: 2}, got)
+
+	// An edit recorded in the history is written into the sidecars.
+	journal := history.NewJournal(dbClient.Client, config.HistoryConfig{})
+	journal.OnChange(writer.SyncChange)
+	deletedFileTags := []db.FileTag{{FileID: 11, TagID: 2}}
+	require.NoError(t, db.NewTransaction(ctx, dbClient.Client, func(ctx context.Context) error {
+		if err := dbClient.FileTag().DeleteFileTags(ctx, deletedFileTags); err != nil {
+			return err
+		}
+		return journal.Record(ctx, history.KindBatchUpdateTags, "", history.Change{
+			DeletedFileTags: deletedFileTags,
+		})
+	}))
+	assert.Equal(t, []string{"Characters/Fern", "expression/smile"}, readTagsList(11))
+
+	_, err = journal.Undo(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Characters/Fern", "expression/smile", "night"}, readTagsList(11))
+
+	// Imported images and those which got the tags of imported ones are
+	// written, and the sidecars of deleted images are deleted.
+	db.LoadTestData(t, dbClient, []db.FileTag{
+		{FileID: 12, TagID: 1},
+		{FileID: 13, TagID: 1},
+	})
+	require.NoError(t, writer.handleEvent(ctx, event.Event{
+		Type: event.TypeImagesImported,
+		Data: event.ImagesImported{
+			DirectoryID:    1,
+			Images:         []event.Image{{ID: 13}},
+			LinkedImageIDs: []uint{12},
+		},
+	}))
+	assert.Equal(t, []string{"expression/smile", "night"}, readTagsList(12))
+	assert.Equal(t, []string{"expression/smile"}, readTagsList(13))
+
+	deletedImage := fileCreator.BuildImageFile(13)
+	require.NoError(t, writer.handleEvent(ctx, event.Event{
+		Type: event.TypeImagesDeleted,
+		Data: event.ImagesDeleted{
+			ImageIDs: []uint{13},
+			Images:   []event.Image{{ID: 13, Path: deletedImage.LocalFilePath}},
+		},
+	}))
+	assert.NoFileExists(t, deletedImage.LocalFilePath+SidecarExtension)
+}
@@ -16,12 +16,21 @@ import (
 	"github.com/michael-freling/anime-image-viewer/internal/backup"
 	"github.com/michael-freling/anime-image-viewer/internal/config"
 	"github.com/michael-freling/anime-image-viewer/internal/db"
+	"github.com/michael-freling/anime-image-viewer/internal/event"
 	"github.com/michael-freling/anime-image-viewer/internal/frontend"
+	"github.com/michael-freling/anime-image-viewer/internal/history"
 	"github.com/michael-freling/anime-image-viewer/internal/image"
 	"github.com/michael-freling/anime-image-viewer/internal/import_images"
+	"github.com/michael-freling/anime-image-viewer/internal/plugin"
 	"github.com/michael-freling/anime-image-viewer/internal/search"
+	"github.com/michael-freling/anime-image-viewer/internal/statistics"
 	"github.com/michael-freling/anime-image-viewer/internal/tag"
+	"github.com/michael-freling/anime-image-viewer/internal/xmp"
+	tag_suggestionv1 "github.com/michael-freling/anime-image-viewer/plugins/plugins-protos/gen/go/tag_suggestion/v1"
+	tag_suggestionv2 "github.com/michael-freling/anime-image-viewer/plugins/plugins-protos/gen/go/tag_suggestion/v2"
 	"github.com/wailsapp/wails/v3/pkg/application"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 // Wails uses Go's `embed` package to embed the frontend files into the binary.
@@ -111,18 +120,80 @@ func runMain(conf config.Config, logger *slog.Logger) error {
 	directoryReader := image.NewDirectoryReader(conf, dbClient)
 	tagReader := tag.NewReader(dbClient, directoryReader)
 	imageReader := image.NewReader(dbClient, directoryReader, imageFileConverter)
-	imageService := frontend.NewImageService(imageReader, dbClient)
+	eventBus := event.NewBus(logger)
+	if err := eventBus.SubscribeHooks(conf.Hooks); err != nil {
+		return fmt.Errorf("eventBus.SubscribeHooks: %w", err)
+	}
+	statisticsService := statistics.NewService(dbClient)
+	statisticsService.Subscribe(eventBus)
 	directoryService := frontend.NewDirectoryService(
 		dbClient,
 		directoryReader,
 		tagReader,
 	)
 	tagService := frontend.NewTagService(tagReader)
+	journal := history.NewJournal(dbClient, conf.History)
+	if conf.XMP.SyncOnTagEdit {
+		xmpWriter := xmp.NewWriter(logger, conf, dbClient)
+		journal.OnChange(xmpWriter.SyncChange)
+		xmpWriter.Subscribe(eventBus)
+	}
+	journal.OnChange(eventBus.OnChange)
+	pluginManager, err := plugin.NewManager(logger, conf)
+	if err != nil {
+		return fmt.Errorf("plugin.NewManager: %w", err)
+	}
+	defer pluginManager.Stop()
+	analyzers, err := pluginManager.DialAnalyzers(conf.Analysis.Plugins)
+	if err != nil {
+		return fmt.Errorf("pluginManager.DialAnalyzers: %w", err)
+	}
+	pluginRegistry := plugin.NewRegistry(logger, dbClient, imageReader, plugin.AnnotationDirectory(conf), analyzers)
+	imageService := frontend.NewImageService(imageReader, dbClient, eventBus, pluginRegistry)
+	var suggestionConnection grpc.ClientConnInterface
+	if conf.TagSuggestion.Plugin != "" {
+		suggestionConnection, err = pluginManager.Dial(conf.TagSuggestion.Plugin)
+		if err != nil {
+			return fmt.Errorf("pluginManager.Dial: %w", err)
+		}
+	} else if conf.TagSuggestion.Address != "" {
+		connection, err := grpc.NewClient(
+			conf.TagSuggestion.Address,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		if err != nil {
+			return fmt.Errorf("grpc.NewClient: %w", err)
+		}
+		defer connection.Close()
+		suggestionConnection = connection
+	}
+	var suggestionService *tag.SuggestionService
+	if suggestionConnection != nil {
+		switch conf.TagSuggestion.Protocol {
+		case config.TagSuggestionProtocolV1:
+			suggestionService = tag.NewSuggestionService(
+				dbClient,
+				tag_suggestionv1.NewTagSuggestionServiceClient(suggestionConnection),
+				tagReader,
+				imageReader,
+			)
+		default:
+			suggestionService = tag.NewSuggestionServiceV2(
+				dbClient,
+				tag_suggestionv2.NewTagSuggestionServiceClient(suggestionConnection),
+				image.NewResizer(logger),
+				conf.TagSuggestion.ThumbnailWidth,
+				tagReader,
+				imageReader,
+			)
+		}
+	}
 	legacyTagFrontendService := tag.NewFrontendService(
 		logger,
 		dbClient,
 		tagReader,
-		nil,
+		suggestionService,
+		journal,
 	)
 	searchService := frontend.NewSearchService(
 		search.NewSearchRunner(
@@ -142,21 +213,39 @@ func runMain(conf config.Config, logger *slog.Logger) error {
 	appCtx, appCancel := context.WithCancel(context.Background())
 	defer appCancel()
 	scanner.Start(appCtx)
+	autoTagger := tag.NewAutoTagger(logger, dbClient, suggestionService, journal, conf)
+	autoTagger.Subscribe(eventBus)
+	eventBus.Start(appCtx)
+	autoTagger.Start(appCtx)
 	logger.Info("startup: service construction", "elapsed", time.Since(startPhase))
 
 	backupFrontendService := frontend.NewBackupFrontendService(logger, conf)
 	configFrontendService := frontend.NewConfigFrontendService(logger, conf)
 
-	metadataClient := animemetadata.NewHTTPClient(conf.AnimeMetadataAPIEndpoint)
-	animeCoreService := anime.NewService(dbClient, directoryReader, conf, metadataClient)
+	metadataClient := animemetadata.NewHTTPClient(
+		conf.AnimeMetadataAPIEndpoint,
+		animemetadata.WithLanguages(conf.MetadataLanguages),
+	)
+	animeCoreService := anime.NewService(dbClient, directoryReader, conf, metadataClient, eventBus)
 	animeFrontendService := frontend.NewAnimeService(
 		animeCoreService,
 		dbClient,
 		directoryReader,
 		tagReader,
 		imageReader,
+		journal,
+	)
+	characterFrontendService := frontend.NewCharacterService(dbClient, journal)
+
+	metadataRefresher := anime.NewMetadataRefresher(
+		logger,
+		animeCoreService,
+		dbClient,
+		conf,
+		frontend.NewMetadataRefreshNotifier(),
 	)
-	characterFrontendService := frontend.NewCharacterService(dbClient)
+	metadataRefresher.Start(appCtx)
+	metadataRefreshService := frontend.NewMetadataRefreshService(metadataRefresher)
 
 	startPhase = time.Now()
 	title := "anime-image-viewer"
@@ -184,12 +273,19 @@ func runMain(conf config.Config, logger *slog.Logger) error {
 					dbClient,
 					imageFileConverter,
 					tagReader,
+					eventBus,
 				),
 			)),
 			application.NewService(backupFrontendService),
 			application.NewService(configFrontendService),
 			application.NewService(animeFrontendService),
 			application.NewService(characterFrontendService),
+			application.NewService(metadataRefreshService),
+			application.NewService(frontend.NewHistoryService(journal)),
+			application.NewService(frontend.NewExportService(logger, conf, dbClient)),
+			application.NewService(frontend.NewTagStatisticsService(statisticsService)),
+			application.NewService(frontend.NewAnalysisService(pluginRegistry)),
+			application.NewService(frontend.NewAutoTaggingService(autoTagger)),
 		},
 		Assets: application.AssetOptions{
 			Handler:        application.AssetFileServerFS(assets),
@@ -203,6 +299,7 @@ func runMain(conf config.Config, logger *slog.Logger) error {
 		},
 		OnShutdown: func() {
 			appCancel()
+			pluginManager.Stop()
 			dbClient.Close()
 		},
 	})
@@ -0,0 +1,118 @@
+from google.protobuf.internal import containers as _containers
+from google.protobuf.internal import enum_type_wrapper as _enum_type_wrapper
+from google.protobuf import descriptor as _descriptor
+from google.protobuf import message as _message
+from typing import ClassVar as _ClassVar, Iterable as _Iterable, Mapping as _Mapping, Optional as _Optional, Union as _Union
+
+DESCRIPTOR: _descriptor.FileDescriptor
+
+class AnnotationType(int, metaclass=_enum_type_wrapper.EnumTypeWrapper):
+    __slots__ = ()
+    ANNOTATION_TYPE_UNSPECIFIED: _ClassVar[AnnotationType]
+    ANNOTATION_TYPE_TEXT: _ClassVar[AnnotationType]
+    ANNOTATION_TYPE_BOUNDING_BOX: _ClassVar[AnnotationType]
+    ANNOTATION_TYPE_DERIVED_IMAGE: _ClassVar[AnnotationType]
+ANNOTATION_TYPE_UNSPECIFIED: AnnotationType
+ANNOTATION_TYPE_TEXT: AnnotationType
+ANNOTATION_TYPE_BOUNDING_BOX: AnnotationType
+ANNOTATION_TYPE_DERIVED_IMAGE: AnnotationType
+
+class GetCapabilitiesRequest(_message.Message):
+    __slots__ = ()
+    def __init__(self) -> None: ...
+
+class Capability(_message.Message):
+    __slots__ = ("name", "description", "annotation_types", "content_types")
+    NAME_FIELD_NUMBER: _ClassVar[int]
+    DESCRIPTION_FIELD_NUMBER: _ClassVar[int]
+    ANNOTATION_TYPES_FIELD_NUMBER: _ClassVar[int]
+    CONTENT_TYPES_FIELD_NUMBER: _ClassVar[int]
+    name: str
+    description: str
+    annotation_types: _containers.RepeatedScalarFieldContainer[AnnotationType]
+    content_types: _containers.RepeatedScalarFieldContainer[str]
+    def __init__(self, name: _Optional[str] = ..., description: _Optional[str] = ..., annotation_types: _Optional[_Iterable[_Union[AnnotationType, str]]] = ..., content_types: _Optional[_Iterable[str]] = ...) -> None: ...
+
+class GetCapabilitiesResponse(_message.Message):
+    __slots__ = ("name", "version", "capabilities")
+    NAME_FIELD_NUMBER: _ClassVar[int]
+    VERSION_FIELD_NUMBER: _ClassVar[int]
+    CAPABILITIES_FIELD_NUMBER: _ClassVar[int]
+    name: str
+    version: str
+    capabilities: _containers.RepeatedCompositeFieldContainer[Capability]
+    def __init__(self, name: _Optional[str] = ..., version: _Optional[str] = ..., capabilities: _Optional[_Iterable[_Union[Capability, _Mapping]]] = ...) -> None: ...
+
+class Image(_message.Message):
+    __slots__ = ("content", "content_type")
+    CONTENT_FIELD_NUMBER: _ClassVar[int]
+    CONTENT_TYPE_FIELD_NUMBER: _ClassVar[int]
+    content: bytes
+    content_type: str
+    def __init__(self, content: _Optional[bytes] = ..., content_type: _Optional[str] = ...) -> None: ...
+
+class AnalyzeRequest(_message.Message):
+    __slots__ = ("capability", "image")
+    CAPABILITY_FIELD_NUMBER: _ClassVar[int]
+    IMAGE_FIELD_NUMBER: _ClassVar[int]
+    capability: str
+    image: Image
+    def __init__(self, capability: _Optional[str] = ..., image: _Optional[_Union[Image, _Mapping]] = ...) -> None: ...
+
+class BoundingBox(_message.Message):
+    __slots__ = ("x", "y", "width", "height")
+    X_FIELD_NUMBER: _ClassVar[int]
+    Y_FIELD_NUMBER: _ClassVar[int]
+    WIDTH_FIELD_NUMBER: _ClassVar[int]
+    HEIGHT_FIELD_NUMBER: _ClassVar[int]
+    x: float
+    y: float
+    width: float
+    height: float
+    def __init__(self, x: _Optional[float] = ..., y: _Optional[float] = ..., width: _Optional[float] = ..., height: _Optional[float] = ...) -> None: ...
+
+class TextAnnotation(_message.Message):
+    __slots__ = ("text", "language", "region")
+    TEXT_FIELD_NUMBER: _ClassVar[int]
+    LANGUAGE_FIELD_NUMBER: _ClassVar[int]
+    REGION_FIELD_NUMBER: _ClassVar[int]
+    text: str
+    language: str
+    region: BoundingBox
+    def __init__(self, text: _Optional[str] = ..., language: _Optional[str] = ..., region: _Optional[_Union[BoundingBox, _Mapping]] = ...) -> None: ...
+
+class BoundingBoxAnnotation(_message.Message):
+    __slots__ = ("box", "label")
+    BOX_FIELD_NUMBER: _ClassVar[int]
+    LABEL_FIELD_NUMBER: _ClassVar[int]
+    box: BoundingBox
+    label: str
+    def __init__(self, box: _Optional[_Union[BoundingBox, _Mapping]] = ..., label: _Optional[str] = ...) -> None: ...
+
+class DerivedImageAnnotation(_message.Message):
+    __slots__ = ("image", "width", "height")
+    IMAGE_FIELD_NUMBER: _ClassVar[int]
+    WIDTH_FIELD_NUMBER: _ClassVar[int]
+    HEIGHT_FIELD_NUMBER: _ClassVar[int]
+    image: Image
+    width: int
+    height: int
+    def __init__(self, image: _Optional[_Union[Image, _Mapping]] = ..., width: _Optional[int] = ..., height: _Optional[int] = ...) -> None: ...
+
+class Annotation(_message.Message):
+    __slots__ = ("score", "text", "bounding_box", "derived_image")
+    SCORE_FIELD_NUMBER: _ClassVar[int]
+    TEXT_FIELD_NUMBER: _ClassVar[int]
+    BOUNDING_BOX_FIELD_NUMBER: _ClassVar[int]
+    DERIVED_IMAGE_FIELD_NUMBER: _ClassVar[int]
+    score: float
+    text: TextAnnotation
+    bounding_box: BoundingBoxAnnotation
+    derived_image: DerivedImageAnnotation
+    def __init__(self, score: _Optional[float] = ..., text: _Optional[_Union[TextAnnotation, _Mapping]] = ..., bounding_box: _Optional[_Union[BoundingBoxAnnotation, _Mapping]] = ..., derived_image: _Optional[_Union[DerivedImageAnnotation, _Mapping]] = ...) -> None: ...
+
+class AnalyzeResponse(_message.Message):
+    __slots__ = ("annotations",)
+    ANNOTATIONS_FIELD_NUMBER: _ClassVar[int]
+    annotations: _containers.RepeatedCompositeFieldContainer[Annotation]
+    def __init__(self, annotations: _Optional[_Iterable[_Union[Annotation, _Mapping]]] = ...) -> None: ...
@@ -0,0 +1,88 @@
+from google.protobuf.internal import containers as _containers
+from google.protobuf import descriptor as _descriptor
+from google.protobuf import message as _message
+from typing import ClassVar as _ClassVar, Iterable as _Iterable, Mapping as _Mapping, Optional as _Optional, Union as _Union
+
+DESCRIPTOR: _descriptor.FileDescriptor
+
+class GetModelRequest(_message.Message):
+    __slots__ = ()
+    def __init__(self) -> None: ...
+
+class Model(_message.Message):
+    __slots__ = ("name", "version")
+    NAME_FIELD_NUMBER: _ClassVar[int]
+    VERSION_FIELD_NUMBER: _ClassVar[int]
+    name: str
+    version: str
+    def __init__(self, name: _Optional[str] = ..., version: _Optional[str] = ...) -> None: ...
+
+class Tag(_message.Message):
+    __slots__ = ("id", "name")
+    ID_FIELD_NUMBER: _ClassVar[int]
+    NAME_FIELD_NUMBER: _ClassVar[int]
+    id: int
+    name: str
+    def __init__(self, id: _Optional[int] = ..., name: _Optional[str] = ...) -> None: ...
+
+class Character(_message.Message):
+    __slots__ = ("id", "name")
+    ID_FIELD_NUMBER: _ClassVar[int]
+    NAME_FIELD_NUMBER: _ClassVar[int]
+    id: int
+    name: str
+    def __init__(self, id: _Optional[int] = ..., name: _Optional[str] = ...) -> None: ...
+
+class GetModelResponse(_message.Message):
+    __slots__ = ("model", "tags", "characters")
+    MODEL_FIELD_NUMBER: _ClassVar[int]
+    TAGS_FIELD_NUMBER: _ClassVar[int]
+    CHARACTERS_FIELD_NUMBER: _ClassVar[int]
+    model: Model
+    tags: _containers.RepeatedCompositeFieldContainer[Tag]
+    characters: _containers.RepeatedCompositeFieldContainer[Character]
+    def __init__(self, model: _Optional[_Union[Model, _Mapping]] = ..., tags: _Optional[_Iterable[_Union[Tag, _Mapping]]] = ..., characters: _Optional[_Iterable[_Union[Character, _Mapping]]] = ...) -> None: ...
+
+class Image(_message.Message):
+    __slots__ = ("id", "content", "content_type", "anime_id")
+    ID_FIELD_NUMBER: _ClassVar[int]
+    CONTENT_FIELD_NUMBER: _ClassVar[int]
+    CONTENT_TYPE_FIELD_NUMBER: _ClassVar[int]
+    ANIME_ID_FIELD_NUMBER: _ClassVar[int]
+    id: int
+    content: bytes
+    content_type: str
+    anime_id: int
+    def __init__(self, id: _Optional[int] = ..., content: _Optional[bytes] = ..., content_type: _Optional[str] = ..., anime_id: _Optional[int] = ...) -> None: ...
+
+class SuggestRequest(_message.Message):
+    __slots__ = ("image",)
+    IMAGE_FIELD_NUMBER: _ClassVar[int]
+    image: Image
+    def __init__(self, image: _Optional[_Union[Image, _Mapping]] = ...) -> None: ...
+
+class SuggestionScore(_message.Message):
+    __slots__ = ("tag_id", "score")
+    TAG_ID_FIELD_NUMBER: _ClassVar[int]
+    SCORE_FIELD_NUMBER: _ClassVar[int]
+    tag_id: int
+    score: float
+    def __init__(self, tag_id: _Optional[int] = ..., score: _Optional[float] = ...) -> None: ...
+
+class CharacterScore(_message.Message):
+    __slots__ = ("character_id", "score")
+    CHARACTER_ID_FIELD_NUMBER: _ClassVar[int]
+    SCORE_FIELD_NUMBER: _ClassVar[int]
+    character_id: int
+    score: float
+    def __init__(self, character_id: _Optional[int] = ..., score: _Optional[float] = ...) -> None: ...
+
+class SuggestResponse(_message.Message):
+    __slots__ = ("image_id", "scores", "character_scores")
+    IMAGE_ID_FIELD_NUMBER: _ClassVar[int]
+    SCORES_FIELD_NUMBER: _ClassVar[int]
+    CHARACTER_SCORES_FIELD_NUMBER: _ClassVar[int]
+    image_id: int
+    scores: _containers.RepeatedCompositeFieldContainer[SuggestionScore]
+    character_scores: _containers.RepeatedCompositeFieldContainer[CharacterScore]
+    def __init__(self, image_id: _Optional[int] = ..., scores: _Optional[_Iterable[_Union[SuggestionScore, _Mapping]]] = ..., character_scores: _Optional[_Iterable[_Union[CharacterScore, _Mapping]]] = ...) -> None: ...
@@ -2,13 +2,17 @@ import PIL
 
 
 class ImageProcessor:
-    def __init__(self, image_path: str):
+    # image_path is a path or a file object like io.BytesIO
+    def __init__(self, image_path):
         self.image_path = image_path
         self.image = None
 
     def __enter__(self):
         with PIL.Image.open(self.image_path) as img:
             img.verify()
+        if hasattr(self.image_path, 'seek'):
+            # verify() reads the file to the end
+            self.image_path.seek(0)
         self.image = PIL.Image.open(self.image_path)
         return self
 
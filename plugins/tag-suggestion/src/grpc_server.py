@@ -1,18 +1,23 @@
+import io
 import multiprocessing as mp
 import json
 import logging
+import os
 from typing import Any, Callable
 from grpc_interceptor import ServerInterceptor
 from concurrent import futures
 import grpc
+from grpc_health.v1 import health, health_pb2, health_pb2_grpc
 from inference import Inference
 import tag_suggestion.v1.service_pb2 as suggestion_pb2
 import tag_suggestion.v1.service_pb2_grpc as suggestion_pb2_grpc
+import tag_suggestion.v2.service_pb2 as suggestion_v2_pb2
+import tag_suggestion.v2.service_pb2_grpc as suggestion_v2_pb2_grpc
 
 
 class TagSuggestionService(suggestion_pb2_grpc.TagSuggestionServiceServicer):
-    def __init__(self, model_path: str, resize_image_width: int):
-        self.inference = Inference(model_path, resize_image_width)
+    def __init__(self, inference: Inference):
+        self.inference = inference
 
     def Suggest(self, request: suggestion_pb2.SuggestRequest, context) -> suggestion_pb2.SuggestResponse:
         prediction_result = self.inference.predict(map(
@@ -47,6 +52,50 @@ class TagSuggestionService(suggestion_pb2_grpc.TagSuggestionServiceServicer):
         return response
 
 
+class TagSuggestionServiceV2(suggestion_v2_pb2_grpc.TagSuggestionServiceServicer):
+    # Images are inferred in batches of batch_size, and the suggestions of a
+    # batch are sent before the next batch is received
+    def __init__(self, inference: Inference, model: suggestion_v2_pb2.Model, batch_size: int = 8):
+        self.inference = inference
+        self.model = model
+        self.batch_size = batch_size
+
+    def GetModel(self, request: suggestion_v2_pb2.GetModelRequest, context) -> suggestion_v2_pb2.GetModelResponse:
+        # The model is trained on tags only, so it suggests no characters
+        return suggestion_v2_pb2.GetModelResponse(
+            model=self.model,
+            tags=[
+                suggestion_v2_pb2.Tag(id=int(id), name=name)
+                for id, name in self.inference.model.config.id2label.items()
+            ],
+        )
+
+    def Suggest(self, request_iterator, context):
+        images = []
+        for request in request_iterator:
+            images.append(request.image)
+            if len(images) >= self.batch_size:
+                yield from self.suggest_images(images)
+                images = []
+        if images:
+            yield from self.suggest_images(images)
+
+    def suggest_images(self, images: list[suggestion_v2_pb2.Image]):
+        prediction_result = self.inference.predict([
+            io.BytesIO(image.content) for image in images
+        ])
+        for index, image in enumerate(images):
+            scores = prediction_result['scores'][index]
+            sorted_indices = prediction_result['sorted_indices'][index]
+            yield suggestion_v2_pb2.SuggestResponse(
+                image_id=image.id,
+                scores=[suggestion_v2_pb2.SuggestionScore(
+                    tag_id=tag_id,
+                    score=scores[tag_id],
+                ) for tag_id in sorted_indices],
+            )
+
+
 class ErrorLogInterceptor(ServerInterceptor):
     def intercept(self, method: Callable, request: Any, context: grpc.ServicerContext, method_name: str):
         try:
@@ -60,17 +109,29 @@ class ErrorLogInterceptor(ServerInterceptor):
             raise
 
 
-def start_grpc_server(model_path: str, resize_image_width: int):
+def start_grpc_server(model_path: str, resize_image_width: int, model_version: str, address: str):
     with futures.ThreadPoolExecutor(max_workers=mp.cpu_count() * 2) as executor:
         server = grpc.server(
             executor,
             interceptors=[ErrorLogInterceptor()],
         )
-        service = TagSuggestionService(model_path, resize_image_width)
+        inference = Inference(model_path, resize_image_width)
+        service = TagSuggestionService(inference)
         suggestion_pb2_grpc.add_TagSuggestionServiceServicer_to_server(
             service, server)
-        port = 50051
-        server.add_insecure_port(f'[::]:{port}')
-        print(f'Starting server. Listening on port {port}.')
+        service_v2 = TagSuggestionServiceV2(inference, suggestion_v2_pb2.Model(
+            name=os.path.basename(os.path.normpath(model_path)),
+            version=model_version,
+        ))
+        suggestion_v2_pb2_grpc.add_TagSuggestionServiceServicer_to_server(
+            service_v2, server)
+        # The app starts this server as a managed plugin and waits until the
+        # health service reports it as serving, which is after the model is
+        # loaded
+        health_servicer = health.HealthServicer()
+        health_servicer.set('', health_pb2.HealthCheckResponse.SERVING)
+        health_pb2_grpc.add_HealthServicer_to_server(health_servicer, server)
+        server.add_insecure_port(address)
+        print(f'Starting server. Listening on {address}.')
         server.start()
         server.wait_for_termination()
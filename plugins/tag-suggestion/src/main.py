@@ -46,8 +46,10 @@ def predict_image(model_path: str, image_paths: list[str], resize_image_width: i
 @cli.command('server')
 @click.argument('model_path', type=click.Path(exists=True))
 @click.option('--resize-image-width', default=224, help='Width to resize the input images to')
-def server(model_path: str, resize_image_width: int):
-    start_grpc_server(model_path, resize_image_width)
+@click.option('--model-version', default='', help='Version of the model returned to clients of tag_suggestion.v2')
+@click.option('--address', default='[::]:50051', help='Address to listen on, like localhost:50051 or unix:/tmp/tag-suggestion.sock')
+def server(model_path: str, resize_image_width: int, model_version: str, address: str):
+    start_grpc_server(model_path, resize_image_width, model_version, address)
 
 
 @cli.command('extract')